@@ -0,0 +1,314 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+// This package maintains the DTS audit log, which records security-relevant
+// events (e.g. transfer requests denied by egress policies) for review by DTS
+// administrators. Events are persisted in the data directory in the order in
+// which they occur.
+
+// outcomes of audited actions
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeDenied  = "denied"
+)
+
+// an audited event
+type Event struct {
+	// the time at which the event occurred
+	Time time.Time `json:"time"`
+	// the ORCID of the user who initiated the event
+	Orcid string `json:"orcid"`
+	// the audited action (e.g. "create_transfer")
+	Action string `json:"action"`
+	// the outcome of the action ("allowed" or "denied")
+	Outcome string `json:"outcome"`
+	// the name of the policy (if any) that determined the outcome
+	Policy string `json:"policy,omitempty"`
+	// a description of the event
+	Message string `json:"message"`
+}
+
+// opens the audit log (if it's not already open)
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "audit.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{eventsBucket, acceptancesBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+	return nil
+}
+
+// closes the audit log (if it's been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the audit log is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// records the given event, stamping it with the current time if it has none
+func Record(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// events are indexed by time, with a UUID distinguishing simultaneous ones
+	key := []byte(event.Time.Format(keyTimeFormat) + "/" + uuid.NewString())
+	return update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(eventsBucket)).Put(key, value)
+	})
+}
+
+// returns all events that occurred within the time range with the given
+// (inclusive) bounds, in the order in which they occurred
+func Events(start, stop time.Time) ([]Event, error) {
+	events := make([]Event, 0)
+	first := []byte(start.UTC().Format(keyTimeFormat))
+	last := []byte(stop.UTC().Format(keyTimeFormat) + "/~")
+	err := view(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(eventsBucket)).Cursor()
+		for key, value := cursor.Seek(first); key != nil && bytes.Compare(key, last) <= 0; key, value = cursor.Next() {
+			var event Event
+			if err := json.Unmarshal(value, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// records that the user with the given ORCID has accepted the given usage
+// terms (e.g. a URL for a data usage policy) of the given database, logging
+// the acceptance as an "accept_terms" event
+func RecordTermsAcceptance(orcid, database, terms string) error {
+	event := Event{
+		Time:    time.Now().UTC(),
+		Orcid:   orcid,
+		Action:  "accept_terms",
+		Outcome: OutcomeAllowed,
+		Policy:  database,
+		Message: fmt.Sprintf("accepted the usage terms of %s (%s)", database, terms),
+	}
+	if err := Record(event); err != nil {
+		return err
+	}
+	value, err := json.Marshal(acceptance{Terms: terms, Time: event.Time})
+	if err != nil {
+		return err
+	}
+	return update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(acceptancesBucket)).Put(acceptanceKey(orcid, database), value)
+	})
+}
+
+// returns true if the user with the given ORCID has accepted the given usage
+// terms of the given database, false if they haven't (or have accepted only
+// different terms)
+func HasAcceptedTerms(orcid, database, terms string) (bool, error) {
+	accepted := false
+	err := view(func(tx *bolt.Tx) error {
+		value := tx.Bucket([]byte(acceptancesBucket)).Get(acceptanceKey(orcid, database))
+		if value == nil {
+			return nil
+		}
+		var a acceptance
+		if err := json.Unmarshal(value, &a); err != nil {
+			return err
+		}
+		accepted = a.Terms == terms
+		return nil
+	})
+	return accepted, err
+}
+
+// anonymizes all events initiated by the user with the given ORCID and
+// forgets their acceptances of usage terms, returning the number of events
+// anonymized
+func AnonymizeUser(orcid string) (int, error) {
+	numAnonymized, err := anonymize(func(event Event) bool {
+		return event.Orcid == orcid
+	})
+	if err != nil {
+		return numAnonymized, err
+	}
+	err = update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(acceptancesBucket))
+		var keys [][]byte
+		err := bucket.ForEach(func(key, _ []byte) error {
+			if _, keyOrcid, _ := bytes.Cut(key, []byte{0}); string(keyOrcid) == orcid {
+				keys = append(keys, slices.Clone(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return numAnonymized, err
+}
+
+// anonymizes all events that occurred before the given time, returning the
+// number of events anonymized
+func AnonymizeBefore(t time.Time) (int, error) {
+	return anonymize(func(event Event) bool {
+		return event.Time.Before(t)
+	})
+}
+
+//-----------
+// Internals
+//-----------
+
+const eventsBucket = "events"
+
+// acceptances of databases' usage terms, by database and ORCID
+const acceptancesBucket = "acceptances"
+
+// a user's acceptance of a database's usage terms
+type acceptance struct {
+	Terms string    `json:"terms"`
+	Time  time.Time `json:"time"`
+}
+
+func acceptanceKey(orcid, database string) []byte {
+	return []byte(database + "\x00" + orcid)
+}
+
+// a fixed-width time format whose lexical order matches chronological order
+const keyTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+var db_ *bolt.DB
+var mutex_ sync.Mutex
+
+func update(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(f)
+}
+
+func view(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(f)
+}
+
+// replaces the ORCIDs in all events selected by the given function, returning
+// the number of events anonymized
+func anonymize(selected func(event Event) bool) (int, error) {
+	numAnonymized := 0
+	err := update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(eventsBucket))
+		updated := make(map[string][]byte)
+		err := bucket.ForEach(func(key, value []byte) error {
+			var event Event
+			if err := json.Unmarshal(value, &event); err != nil {
+				return err
+			}
+			if event.Orcid == auth.AnonymousOrcid || !selected(event) {
+				return nil
+			}
+			event.Orcid = auth.AnonymousOrcid
+			value, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			updated[string(key)] = value
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, value := range updated {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		numAnonymized = len(updated)
+		return nil
+	})
+	return numAnonymized, err
+}
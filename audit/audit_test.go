@@ -0,0 +1,177 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package audit
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+func TestRecordAndRetrieveEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	for i, action := range []string{"create_transfer", "create_transfer", "delete_transfer"} {
+		err := Record(Event{
+			Time:    now.Add(time.Duration(i) * time.Minute),
+			Orcid:   "1234-5678-9012-3456",
+			Action:  action,
+			Outcome: OutcomeDenied,
+			Message: "denied by policy",
+		})
+		assert.Nil(err)
+	}
+
+	events, err := Events(now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.Len(events, 2)
+	assert.True(events[0].Time.Equal(now))
+	assert.True(events[1].Time.Equal(now.Add(time.Minute)))
+
+	events, err = Events(now.Add(-time.Hour), now.Add(-time.Minute))
+	assert.Nil(err)
+	assert.Len(events, 0)
+}
+
+func TestRecordStampsEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	before := time.Now()
+	err := Record(Event{Orcid: "1234-5678-9012-3456", Action: "create_transfer", Outcome: OutcomeAllowed})
+	assert.Nil(err)
+	events, err := Events(before, time.Now())
+	assert.Nil(err)
+	assert.Len(events, 1)
+	assert.Equal("create_transfer", events[0].Action)
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Now().Add(time.Hour)
+	err := Record(Event{Time: start, Orcid: "5555-6666-7777-8888", Action: "create_transfer",
+		Outcome: OutcomeDenied})
+	assert.Nil(err)
+
+	numAnonymized, err := AnonymizeUser("5555-6666-7777-8888")
+	assert.Nil(err)
+	assert.Equal(1, numAnonymized)
+	events, err := Events(start, start)
+	assert.Nil(err)
+	assert.Len(events, 1)
+	assert.Equal(auth.AnonymousOrcid, events[0].Orcid)
+}
+
+func TestTermsAcceptance(t *testing.T) {
+	assert := assert.New(t)
+
+	const orcid = "1111-2222-3333-4444"
+	const terms = "https://example.org/policy"
+	accepted, err := HasAcceptedTerms(orcid, "jdp", terms)
+	assert.Nil(err)
+	assert.False(accepted)
+
+	start := time.Now()
+	assert.Nil(RecordTermsAcceptance(orcid, "jdp", terms))
+	accepted, err = HasAcceptedTerms(orcid, "jdp", terms)
+	assert.Nil(err)
+	assert.True(accepted)
+	events, err := Events(start, time.Now())
+	assert.Nil(err)
+	assert.Len(events, 1)
+	assert.Equal("accept_terms", events[0].Action)
+	assert.Equal("jdp", events[0].Policy)
+
+	// acceptances are specific to databases and terms
+	accepted, _ = HasAcceptedTerms(orcid, "nmdc", terms)
+	assert.False(accepted)
+	accepted, _ = HasAcceptedTerms(orcid, "jdp", "https://example.org/policy-v2")
+	assert.False(accepted)
+
+	// ...and are forgotten when a user is anonymized
+	_, err = AnonymizeUser(orcid)
+	assert.Nil(err)
+	accepted, _ = HasAcceptedTerms(orcid, "jdp", terms)
+	assert.False(accepted)
+}
+
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+
+	myConfig := strings.ReplaceAll(auditConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.InitSelected([]byte(myConfig), true, false, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open audit log: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// configuration
+const auditConfig string = `
+service:
+  name: test
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+`
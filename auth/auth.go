@@ -52,3 +52,7 @@ type User struct {
 	// true if this user is a Superuser
 	IsSuper bool
 }
+
+// the placeholder that replaces the ORCID (and name) of a user in historical
+// records that have been anonymized
+const AnonymousOrcid = "anonymous"
@@ -27,6 +27,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/kbase/dts/config"
 )
 
 // this type represents a proxy for the KBase Auth2 server
@@ -204,7 +206,7 @@ func (server KBaseAuthServer) get(resource string) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	var client http.Client
+	client := http.Client{Transport: config.HttpTransport()}
 	return client.Do(req)
 }
 
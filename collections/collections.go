@@ -0,0 +1,288 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package collections
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+// This package stores collections: named sets of file IDs saved by users, who
+// can share them read-only with other users and transfer their files by
+// referring to them in transfer requests.
+
+// a named set of file IDs belonging to a single database
+type Collection struct {
+	// UUID identifying the collection
+	Id uuid.UUID `json:"id"`
+	// a name for the collection chosen by its owner
+	Name string `json:"name"`
+	// the name of the database containing the collection's files
+	Database string `json:"database"`
+	// identifiers for the files in the collection
+	FileIds []string `json:"file_ids"`
+	// the ORCID of the user that owns the collection
+	Owner string `json:"owner"`
+	// ORCIDs of users with read-only access to the collection
+	SharedWith []string `json:"shared_with,omitempty"`
+	// the time at which the collection was created
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// returns true if the user with the given ORCID may read the collection
+func (c Collection) ReadableBy(orcid string) bool {
+	return c.Owner == orcid || slices.Contains(c.SharedWith, orcid)
+}
+
+// opens the collection store (if it's not already open)
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "collections.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+	return nil
+}
+
+// closes the collection store (if it's been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the collection store is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// stores a new collection owned by the given user, returning its UUID
+func Create(collection Collection) (uuid.UUID, error) {
+	collection.Id = uuid.New()
+	collection.CreationTime = time.Now()
+	return collection.Id, update(func(bucket *bolt.Bucket) error {
+		return put(bucket, collection)
+	})
+}
+
+// retrieves the collection with the given UUID on behalf of the user with the
+// given ORCID, who must own the collection or have it shared with them
+func Fetch(id uuid.UUID, orcid string) (Collection, error) {
+	var collection Collection
+	err := view(func(bucket *bolt.Bucket) error {
+		var err error
+		collection, err = get(bucket, id)
+		if err != nil {
+			return err
+		}
+		if !collection.ReadableBy(orcid) {
+			return &NotFoundError{Id: id} // don't reveal others' collections
+		}
+		return nil
+	})
+	return collection, err
+}
+
+// retrieves all collections owned by or shared with the user with the given
+// ORCID, in order of creation
+func List(orcid string) ([]Collection, error) {
+	collections := make([]Collection, 0)
+	err := view(func(bucket *bolt.Bucket) error {
+		return bucket.ForEach(func(_, value []byte) error {
+			var collection Collection
+			if err := json.Unmarshal(value, &collection); err != nil {
+				return err
+			}
+			if collection.ReadableBy(orcid) {
+				collections = append(collections, collection)
+			}
+			return nil
+		})
+	})
+	slices.SortFunc(collections, func(a, b Collection) int {
+		return a.CreationTime.Compare(b.CreationTime)
+	})
+	return collections, err
+}
+
+// replaces the set of users with whom the collection with the given UUID is
+// shared, on behalf of the user with the given ORCID, who must own it
+func Share(id uuid.UUID, orcid string, sharedWith []string) (Collection, error) {
+	var collection Collection
+	err := update(func(bucket *bolt.Bucket) error {
+		var err error
+		collection, err = owned(bucket, id, orcid)
+		if err != nil {
+			return err
+		}
+		collection.SharedWith = sharedWith
+		return put(bucket, collection)
+	})
+	return collection, err
+}
+
+// deletes the collection with the given UUID on behalf of the user with the
+// given ORCID, who must own it
+func Delete(id uuid.UUID, orcid string) error {
+	return update(func(bucket *bolt.Bucket) error {
+		if _, err := owned(bucket, id, orcid); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(id.String()))
+	})
+}
+
+// anonymizes the collections owned by the user with the given ORCID (which
+// remain readable by the users with whom they're shared) and stops sharing
+// other collections with the user, returning the number of collections changed
+func AnonymizeUser(orcid string) (int, error) {
+	numAnonymized := 0
+	err := update(func(bucket *bolt.Bucket) error {
+		var changed []Collection
+		err := bucket.ForEach(func(_, value []byte) error {
+			var collection Collection
+			if err := json.Unmarshal(value, &collection); err != nil {
+				return err
+			}
+			if collection.Owner != orcid && !slices.Contains(collection.SharedWith, orcid) {
+				return nil
+			}
+			if collection.Owner == orcid {
+				collection.Owner = auth.AnonymousOrcid
+			}
+			collection.SharedWith = slices.DeleteFunc(collection.SharedWith, func(sharee string) bool {
+				return sharee == orcid
+			})
+			changed = append(changed, collection)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, collection := range changed {
+			if err := put(bucket, collection); err != nil {
+				return err
+			}
+		}
+		numAnonymized = len(changed)
+		return nil
+	})
+	return numAnonymized, err
+}
+
+//-----------
+// Internals
+//-----------
+
+const bucketName = "collections"
+
+var db_ *bolt.DB
+var mutex_ sync.Mutex
+
+// runs the given function on the collections bucket in a read-only transaction
+func view(f func(bucket *bolt.Bucket) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(func(tx *bolt.Tx) error {
+		return f(tx.Bucket([]byte(bucketName)))
+	})
+}
+
+// runs the given function on the collections bucket in a read-write transaction
+func update(f func(bucket *bolt.Bucket) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(func(tx *bolt.Tx) error {
+		return f(tx.Bucket([]byte(bucketName)))
+	})
+}
+
+func get(bucket *bolt.Bucket, id uuid.UUID) (Collection, error) {
+	var collection Collection
+	value := bucket.Get([]byte(id.String()))
+	if value == nil {
+		return collection, &NotFoundError{Id: id}
+	}
+	err := json.Unmarshal(value, &collection)
+	return collection, err
+}
+
+// retrieves the collection with the given UUID, provided that it's owned by
+// the user with the given ORCID
+func owned(bucket *bolt.Bucket, id uuid.UUID, orcid string) (Collection, error) {
+	collection, err := get(bucket, id)
+	if err != nil {
+		return collection, err
+	}
+	if collection.Owner != orcid {
+		if collection.ReadableBy(orcid) {
+			return collection, &NotOwnerError{Id: id, Orcid: orcid}
+		}
+		return collection, &NotFoundError{Id: id}
+	}
+	return collection, nil
+}
+
+func put(bucket *bolt.Bucket, collection Collection) error {
+	value, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(collection.Id.String()), value)
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package collections
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+const owner = "1234-5678-9012-3456"
+const friend = "2345-6789-0123-4567"
+const stranger = "3456-7890-1234-5678"
+
+func TestCreateFetchAndList(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := Create(Collection{
+		Name:     "soil samples",
+		Database: "jdp",
+		FileIds:  []string{"file1", "file2"},
+		Owner:    owner,
+	})
+	assert.Nil(err)
+
+	collection, err := Fetch(id, owner)
+	assert.Nil(err)
+	assert.Equal("soil samples", collection.Name)
+	assert.Equal([]string{"file1", "file2"}, collection.FileIds)
+
+	// others can't see the collection until it's shared with them
+	_, err = Fetch(id, friend)
+	assert.IsType(&NotFoundError{}, err)
+	collections, err := List(friend)
+	assert.Nil(err)
+	assert.Empty(collections)
+
+	collections, err = List(owner)
+	assert.Nil(err)
+	assert.Len(collections, 1)
+
+	_, err = Fetch(uuid.New(), owner)
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestShareAndDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := Create(Collection{
+		Name:     "water samples",
+		Database: "nmdc",
+		FileIds:  []string{"file3"},
+		Owner:    owner,
+	})
+	assert.Nil(err)
+
+	// only the owner can share a collection
+	_, err = Share(id, stranger, []string{stranger})
+	assert.IsType(&NotFoundError{}, err)
+	collection, err := Share(id, owner, []string{friend})
+	assert.Nil(err)
+	assert.Equal([]string{friend}, collection.SharedWith)
+
+	// shared collections are readable but can't be modified
+	collection, err = Fetch(id, friend)
+	assert.Nil(err)
+	assert.Equal([]string{"file3"}, collection.FileIds)
+	_, err = Share(id, friend, []string{friend, stranger})
+	assert.IsType(&NotOwnerError{}, err)
+	err = Delete(id, friend)
+	assert.IsType(&NotOwnerError{}, err)
+
+	err = Delete(id, owner)
+	assert.Nil(err)
+	_, err = Fetch(id, owner)
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	assert := assert.New(t)
+
+	const departing = "4567-8901-2345-6789"
+	ownedId, err := Create(Collection{
+		Name:       "air samples",
+		Database:   "nmdc",
+		FileIds:    []string{"file4"},
+		Owner:      departing,
+		SharedWith: []string{friend},
+	})
+	assert.Nil(err)
+	sharedId, err := Create(Collection{
+		Name:       "ice samples",
+		Database:   "nmdc",
+		FileIds:    []string{"file5"},
+		Owner:      friend,
+		SharedWith: []string{departing, stranger},
+	})
+	assert.Nil(err)
+
+	numAnonymized, err := AnonymizeUser(departing)
+	assert.Nil(err)
+	assert.Equal(2, numAnonymized)
+
+	// the user's collections remain readable by those they're shared with...
+	collection, err := Fetch(ownedId, friend)
+	assert.Nil(err)
+	assert.Equal(auth.AnonymousOrcid, collection.Owner)
+
+	// ...and the user no longer shares others' collections
+	collection, err = Fetch(sharedId, friend)
+	assert.Nil(err)
+	assert.Equal([]string{stranger}, collection.SharedWith)
+	collections, err := List(departing)
+	assert.Nil(err)
+	assert.Empty(collections)
+
+	numAnonymized, err = AnonymizeUser(departing)
+	assert.Nil(err)
+	assert.Equal(0, numAnonymized)
+}
+
+// This runs setup, runs all tests, and does breakdown.
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+
+	myConfig := strings.ReplaceAll(collectionsConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.InitSelected([]byte(myConfig), true, false, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open collection store: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// configuration
+const collectionsConfig string = `
+service:
+  name: test
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+`
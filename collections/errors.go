@@ -0,0 +1,64 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package collections
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// indicates that the collection store is not open
+type NotOpenError struct{}
+
+func (e NotOpenError) Error() string {
+	return "The collection store is not open for reading or writing."
+}
+
+// indicates that the collection store cannot be opened
+type CantOpenError struct {
+	Message string
+}
+
+func (e CantOpenError) Error() string {
+	return fmt.Sprintf("Can't open collection store: %s", e.Message)
+}
+
+// indicates that the collection with the given ID was not found
+type NotFoundError struct {
+	Id uuid.UUID
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("The collection %s was not found.", e.Id.String())
+}
+
+// indicates that a user attempted to modify a collection they don't own
+type NotOwnerError struct {
+	Id    uuid.UUID
+	Orcid string
+}
+
+func (e NotOwnerError) Error() string {
+	return fmt.Sprintf("The collection %s is not owned by the user with ORCID %s.",
+		e.Id.String(), e.Orcid)
+}
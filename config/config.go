@@ -24,7 +24,11 @@ package config
 import (
 	"fmt"
 	"log"
+	"mime"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -62,6 +66,105 @@ type serviceConfig struct {
 	// flag indicating whether an endpoint double-checks that files are staged
 	// (if not set, the endpoint will trust a database for staging status)
 	DoubleCheckStaging bool `json:"double_check_staging" yaml:"double_check_staging"`
+	// maximum number of bulk (non-fast-lane) transfers processed at once, past
+	// which new bulk transfers are queued
+	// default: 0 (no limit)
+	MaxActiveTransfers int `json:"max_active_transfers,omitempty" yaml:"max_active_transfers,omitempty"`
+	// transfers with payloads no larger than this size (gigabytes) and no more
+	// than fast_lane_max_files files bypass the bulk transfer queue
+	// default: 0 (no size limit)
+	FastLaneMaxPayloadSize float64 `json:"fast_lane_max_payload_size,omitempty" yaml:"fast_lane_max_payload_size,omitempty"`
+	// maximum number of files in a fast-lane transfer
+	// default: 0 (no file limit; if both fast lane limits are 0, there is no fast lane)
+	FastLaneMaxFiles int `json:"fast_lane_max_files,omitempty" yaml:"fast_lane_max_files,omitempty"`
+	// stages with payloads no larger than this size (gigabytes) are downloaded
+	// directly over HTTPS from source endpoints that allow it to local
+	// destination endpoints, bypassing transfer tasks
+	// default: 0 (no direct downloads)
+	SmallPayloadMaxSize float64 `json:"small_payload_max_size,omitempty" yaml:"small_payload_max_size,omitempty"`
+	// interval at which the janitor removes orphaned scratch files (manifests
+	// for failed transfers, etc) from the manifest directory (seconds)
+	// default: 1 hour (0 disables the janitor)
+	JanitorInterval int `json:"janitor_interval" yaml:"janitor_interval"`
+	// interval at which saved searches are run to detect newly matching files
+	// (seconds)
+	// default: 1 day (0 disables the running of saved searches)
+	SavedSearchInterval int `json:"saved_search_interval" yaml:"saved_search_interval"`
+	// time after which a scratch file not referenced by a live transfer is
+	// considered orphaned (seconds)
+	// default: 1 day
+	ScratchRetention int `json:"scratch_retention" yaml:"scratch_retention"`
+	// time after which personal information (ORCIDs, names, email addresses)
+	// in the records of a completed transfer is anonymized (seconds)
+	// default: 0 (personal information is retained indefinitely)
+	UserDataRetention int `json:"user_data_retention,omitempty" yaml:"user_data_retention,omitempty"`
+	// time before a credential's expiration at which the DTS begins alerting
+	// administrators that it must be renewed (seconds)
+	// default: 14 days
+	CredentialExpiryWarning int `json:"credential_expiry_warning" yaml:"credential_expiry_warning"`
+	// minimum free space on the filesystems holding the data and manifest
+	// directories, below which new transfers are refused and writes are
+	// deferred (gigabytes)
+	// default: 1 GB (0 disables the check)
+	MinFreeDiskSpace float64 `json:"min_free_disk_space" yaml:"min_free_disk_space"`
+	// interval at which the DTS saves its state while running (seconds)
+	// default: 5 minutes (0 saves state only when the service stops)
+	CheckpointInterval int `json:"checkpoint_interval" yaml:"checkpoint_interval"`
+	// flag indicating whether checksums are computed for files whose sources
+	// don't provide them (at the source endpoint if possible, or at the
+	// destination endpoint after transfer) and recorded in manifests
+	ComputeMissingChecksums bool `json:"compute_missing_checksums" yaml:"compute_missing_checksums"`
+	// flag indicating whether hash algorithms and TLS configurations are
+	// restricted to FIPS-approved choices (MD5 checksums are not verified, and
+	// computed checksums use SHA-256)
+	FIPSMode bool `json:"fips_mode" yaml:"fips_mode"`
+	// flag indicating whether a transfer's manifest is sent concurrently with
+	// its payload (marked as pending by the task), instead of only after all
+	// files arrive, and replaced if it changes or retracted if the transfer fails
+	EarlyManifest bool `json:"early_manifest" yaml:"early_manifest"`
+	// method used to sanitize destination file paths containing spaces, commas,
+	// non-ASCII, or other special characters: "replace" substitutes underscores
+	// for them, and "encode" percent-encodes them
+	// default: "" (paths are not sanitized)
+	PathSanitization string `json:"path_sanitization,omitempty" yaml:"path_sanitization,omitempty"`
+	// policy for requested files that have been deleted from their source
+	// database after they were found: "strict" fails the transfer, and
+	// "lenient" omits them from the payload (recording them in the manifest)
+	// default: "strict"
+	MissingFilePolicy string `json:"missing_file_policy,omitempty" yaml:"missing_file_policy,omitempty"`
+	// transfers with payloads at least this size (gigabytes) have the
+	// reachability of their source endpoints and a sample of their file paths
+	// checked before staging begins, so they fail quickly if a source is down
+	// default: 0 (sources are not probed)
+	ProbeMinPayloadSize float64 `json:"probe_min_payload_size,omitempty" yaml:"probe_min_payload_size,omitempty"`
+	// secret used to sign the events POSTed to transfers' callback URLs
+	// (callbacks are disabled if this isn't set)
+	// DO NOT STORE THIS IN A CONFIG FILE! Use an environment variable instead
+	CallbackSecret string `json:"-" yaml:"callback_secret,omitempty"`
+	// flag indicating whether webhooks (transfer callbacks and saved search
+	// notifications) may be sent to private and local addresses, which is
+	// useful for testing and for deployments that serve only an intranet
+	AllowPrivateWebhooks bool `json:"allow_private_webhooks" yaml:"allow_private_webhooks"`
+	// numbers of workers updating transfer tasks concurrently in each stage
+	// of the transfer pipeline
+	Workers workersConfig `json:"workers" yaml:"workers"`
+	// policy for retrying transfer and manifest submissions that fail because
+	// of transient errors
+	Retries retryConfig `json:"retries" yaml:"retries"`
+	// settings for fetching credentials from HashiCorp Vault
+	Vault vaultConfig `json:"vault" yaml:"vault"`
+	// settings for exporting traces of requests and transfers via OTLP
+	Tracing tracingConfig `json:"tracing" yaml:"tracing"`
+	// settings for minting DOIs for delivered payloads with DataCite
+	DOI doiConfig `json:"doi" yaml:"doi"`
+	// quotas on the transfers requested by users and DTS clients
+	Quotas quotasConfig `json:"quotas" yaml:"quotas"`
+	// settings for simulation mode, in which databases and endpoints are
+	// replaced by deterministic fakes for integration testing
+	Simulation simulationConfig `json:"simulation" yaml:"simulation"`
+	// flag indicating whether faults can be injected into transfers via the
+	// API for chaos testing (never enable this in production!)
+	FaultInjection bool `json:"fault_injection" yaml:"fault_injection"`
 }
 
 // global config variables
@@ -69,14 +172,20 @@ var Service serviceConfig
 var Credentials map[string]credentialConfig
 var Endpoints map[string]endpointConfig
 var Databases map[string]databaseConfig
+var EgressPolicies map[string]egressPolicyConfig
+var Formats map[string]string
+var MimeTypes map[string]string
 
 // This struct performs the unmarshalling from the YAML config file and then
 // copies its fields to the globals above.
 type configFile struct {
-	Service     serviceConfig               `yaml:"service"`
-	Credentials map[string]credentialConfig `yaml:"credentials"`
-	Databases   map[string]databaseConfig   `yaml:"databases"`
-	Endpoints   map[string]endpointConfig   `yaml:"endpoints"`
+	Service        serviceConfig                 `yaml:"service"`
+	Credentials    map[string]credentialConfig   `yaml:"credentials"`
+	Databases      map[string]databaseConfig     `yaml:"databases"`
+	EgressPolicies map[string]egressPolicyConfig `yaml:"egress_policies"`
+	Endpoints      map[string]endpointConfig     `yaml:"endpoints"`
+	Formats        map[string]string             `yaml:"formats"`
+	MimeTypes      map[string]string             `yaml:"mimetypes"`
 }
 
 // This helper locates and reads the selected sections in a configuration file,
@@ -92,6 +201,19 @@ func readConfig(bytes []byte, service, credentials, databases, endpoints bool) e
 	conf.Service.MaxPayloadSize = 100.0 // gigabytes
 	conf.Service.PollInterval = int(time.Minute / time.Millisecond)
 	conf.Service.DeleteAfter = 7 * 24 * 3600
+	conf.Service.JanitorInterval = 3600
+	conf.Service.SavedSearchInterval = 86400
+	conf.Service.ScratchRetention = 24 * 3600
+	conf.Service.MinFreeDiskSpace = 1.0 // gigabytes
+	conf.Service.CheckpointInterval = 300
+	conf.Service.CredentialExpiryWarning = 14 * 24 * 3600
+	conf.Service.Workers = workersConfig{Create: 1, Staging: 1, Transfer: 1, Finalize: 1}
+	conf.Service.Retries = retryConfig{MaxAttempts: 1, InitialBackoff: 30, MaxBackoff: 600, Jitter: 0.1}
+	conf.Service.Vault.Mount = "secret"
+	conf.Service.Vault.RefreshInterval = 300
+	conf.Service.Tracing.ServiceName = "dts"
+	conf.Service.Tracing.ExportInterval = 5
+	conf.Service.DOI.Endpoint = "https://api.datacite.org"
 
 	err := yaml.Unmarshal(bytes, &conf)
 	if err != nil {
@@ -120,6 +242,20 @@ func readConfig(bytes []byte, service, credentials, databases, endpoints bool) e
 
 	if databases {
 		Databases = conf.Databases
+		EgressPolicies = conf.EgressPolicies
+	}
+
+	// file formats are optional and always read (suffixes are normalized to
+	// lower case without leading dots)
+	Formats = make(map[string]string)
+	for suffix, format := range conf.Formats {
+		Formats[strings.ToLower(strings.TrimLeft(suffix, "."))] = format
+	}
+
+	// media types are likewise optional and always read
+	MimeTypes = make(map[string]string)
+	for format, mimetype := range conf.MimeTypes {
+		MimeTypes[format] = strings.ToLower(mimetype)
 	}
 
 	return err
@@ -160,15 +296,214 @@ func validateServiceParameters(params serviceConfig) error {
 				params.DeleteAfter),
 		}
 	}
+	if params.MaxActiveTransfers < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid max_active_transfers: %d (must be non-negative)",
+				params.MaxActiveTransfers),
+		}
+	}
+	if params.FastLaneMaxPayloadSize < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid fast_lane_max_payload_size: %g (must be non-negative)",
+				params.FastLaneMaxPayloadSize),
+		}
+	}
+	if params.FastLaneMaxFiles < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid fast_lane_max_files: %d (must be non-negative)",
+				params.FastLaneMaxFiles),
+		}
+	}
+	if params.SmallPayloadMaxSize < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid small_payload_max_size: %g (must be non-negative)",
+				params.SmallPayloadMaxSize),
+		}
+	}
+	if params.JanitorInterval < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid janitor_interval: %d (must be non-negative)",
+				params.JanitorInterval),
+		}
+	}
+	if params.SavedSearchInterval < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid saved_search_interval: %d (must be non-negative)",
+				params.SavedSearchInterval),
+		}
+	}
+	if params.ScratchRetention <= 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid scratch_retention: %d (must be positive)",
+				params.ScratchRetention),
+		}
+	}
+	if params.UserDataRetention < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid user_data_retention: %d (must be non-negative)",
+				params.UserDataRetention),
+		}
+	}
+	if params.CredentialExpiryWarning < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid credential_expiry_warning: %d (must be non-negative)",
+				params.CredentialExpiryWarning),
+		}
+	}
+	if params.Vault.RefreshInterval <= 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid vault refresh_interval: %d (must be positive)",
+				params.Vault.RefreshInterval),
+		}
+	}
+	if params.MinFreeDiskSpace < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid min_free_disk_space: %g (must be non-negative)",
+				params.MinFreeDiskSpace),
+		}
+	}
+	if params.CheckpointInterval < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid checkpoint_interval: %d (must be non-negative)",
+				params.CheckpointInterval),
+		}
+	}
+	if !slices.Contains([]string{"", "none", "replace", "encode"}, params.PathSanitization) {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid path_sanitization: %s (must be replace or encode)",
+				params.PathSanitization),
+		}
+	}
+	if params.ProbeMinPayloadSize < 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid probe_min_payload_size: %g (must be non-negative)",
+				params.ProbeMinPayloadSize),
+		}
+	}
+	if !slices.Contains([]string{"", "strict", "lenient"}, params.MissingFilePolicy) {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid missing_file_policy: %s (must be strict or lenient)",
+				params.MissingFilePolicy),
+		}
+	}
+	for stage, numWorkers := range map[string]int{
+		"create":   params.Workers.Create,
+		"staging":  params.Workers.Staging,
+		"transfer": params.Workers.Transfer,
+		"finalize": params.Workers.Finalize,
+	} {
+		if numWorkers < 1 {
+			return &InvalidServiceConfigError{
+				Message: fmt.Sprintf("Invalid number of %s workers: %d (must be positive)",
+					stage, numWorkers),
+			}
+		}
+	}
+	if params.Retries.MaxAttempts < 1 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid retries max_attempts: %d (must be positive)",
+				params.Retries.MaxAttempts),
+		}
+	}
+	if params.Retries.InitialBackoff < 0 || params.Retries.MaxBackoff < params.Retries.InitialBackoff {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid retries backoff: %d-%d s (must be non-negative and ordered)",
+				params.Retries.InitialBackoff, params.Retries.MaxBackoff),
+		}
+	}
+	if params.Retries.Jitter < 0 || params.Retries.Jitter > 1 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid retries jitter: %g (must be between 0 and 1)",
+				params.Retries.Jitter),
+		}
+	}
+	if otlpEndpoint, err := url.Parse(params.Tracing.OTLPEndpoint); params.Tracing.OTLPEndpoint != "" &&
+		(err != nil || (otlpEndpoint.Scheme != "http" && otlpEndpoint.Scheme != "https") || otlpEndpoint.Host == "") {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid tracing otlp_endpoint: %s", params.Tracing.OTLPEndpoint),
+		}
+	}
+	if params.Tracing.ExportInterval <= 0 {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid tracing export_interval: %d (must be positive)",
+				params.Tracing.ExportInterval),
+		}
+	}
+	if doiEndpoint, err := url.Parse(params.DOI.Endpoint); params.DOI.Endpoint != "" &&
+		(err != nil || (doiEndpoint.Scheme != "http" && doiEndpoint.Scheme != "https") || doiEndpoint.Host == "") {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid doi endpoint: %s", params.DOI.Endpoint),
+		}
+	}
+	if params.DOI.Prefix != "" && !strings.HasPrefix(params.DOI.Prefix, "10.") {
+		return &InvalidServiceConfigError{
+			Message: fmt.Sprintf("Invalid doi prefix: %s (must begin with 10.)", params.DOI.Prefix),
+		}
+	}
+	quotas := map[string]quotaConfig{"default": params.Quotas.Default}
+	for orcid, quota := range params.Quotas.Users {
+		quotas["user "+orcid] = quota
+	}
+	for client, quota := range params.Quotas.Clients {
+		quotas["client "+client] = quota
+	}
+	for subject, quota := range quotas {
+		if quota.MaxConcurrentTransfers < 0 || quota.MaxDailyPayloadSize < 0 {
+			return &InvalidServiceConfigError{
+				Message: fmt.Sprintf("Invalid %s quota (limits must be non-negative)", subject),
+			}
+		}
+	}
+	if params.Simulation.Latency < 0 || params.Simulation.StagingDuration < 0 ||
+		params.Simulation.TransferDuration < 0 {
+		return &InvalidServiceConfigError{
+			Message: "Invalid simulation latency or duration (must be non-negative)",
+		}
+	}
+	for _, rate := range []float64{params.Simulation.StagingFailureRate, params.Simulation.TransferFailureRate} {
+		if rate < 0 || rate > 1 {
+			return &InvalidServiceConfigError{
+				Message: fmt.Sprintf("Invalid simulation failure rate: %g (must be between 0 and 1)", rate),
+			}
+		}
+	}
 	return nil
 }
 
 func validateCredentials(credentials map[string]credentialConfig) error {
 	for name, credential := range credentials {
-		if credential.Id == "" {
+		switch credential.Provider {
+		case "", "config":
+			if credential.Id == "" {
+				return &InvalidCredentialConfigError{
+					Credential: name,
+					Message:    "Invalid credential ID",
+				}
+			}
+		case "vault":
+			if credential.Path == "" {
+				return &InvalidCredentialConfigError{
+					Credential: name,
+					Message:    "No Vault secret path given",
+				}
+			}
+			if Service.Vault.Address == "" {
+				return &InvalidCredentialConfigError{
+					Credential: name,
+					Message:    "No Vault server address is configured (service.vault.address)",
+				}
+			}
+		case "file":
+			if credential.Path == "" {
+				return &InvalidCredentialConfigError{
+					Credential: name,
+					Message:    "No secret file path given",
+				}
+			}
+		default:
 			return &InvalidCredentialConfigError{
 				Credential: name,
-				Message:    "Invalid credential ID",
+				Message:    fmt.Sprintf("Invalid provider: %s", credential.Provider),
 			}
 		}
 	}
@@ -194,6 +529,43 @@ func validateEndpoints(endpoints map[string]endpointConfig) error {
 				Message:  "No provider specified",
 			}
 		}
+		if endpoint.Provider == "sftp" {
+			if endpoint.Host == "" {
+				return &InvalidEndpointConfigError{
+					Endpoint: name,
+					Message:  "No host specified for SFTP endpoint",
+				}
+			}
+			if endpoint.Credential == "" {
+				return &InvalidEndpointConfigError{
+					Endpoint: name,
+					Message:  "No credential (user and private key) specified for SFTP endpoint",
+				}
+			}
+		}
+		if endpoint.Provider == "https" && endpoint.Root != "" {
+			if rootUrl, err := url.Parse(endpoint.Root); err != nil ||
+				(rootUrl.Scheme != "https" && rootUrl.Scheme != "http") || rootUrl.Host == "" {
+				return &InvalidEndpointConfigError{
+					Endpoint: name,
+					Message:  fmt.Sprintf("Invalid root URL for HTTPS endpoint: %s", endpoint.Root),
+				}
+			}
+		}
+		if endpoint.Relay != "" {
+			if endpoint.Relay == name {
+				return &InvalidEndpointConfigError{
+					Endpoint: name,
+					Message:  "An endpoint can't relay its own transfers",
+				}
+			}
+			if _, found := endpoints[endpoint.Relay]; !found {
+				return &InvalidEndpointConfigError{
+					Endpoint: name,
+					Message:  fmt.Sprintf("Invalid relay endpoint: %s", endpoint.Relay),
+				}
+			}
+		}
 	}
 	return nil
 }
@@ -234,6 +606,179 @@ func validateDatabases(databases map[string]databaseConfig) error {
 				}
 			}
 		}
+		if db.Credential != "" {
+			if _, found := Credentials[db.Credential]; !found {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Invalid credential for database %s: %s", name, db.Credential),
+				}
+			}
+		}
+		if db.MintDOIs {
+			if Service.DOI.Prefix == "" {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Database %s mints DOIs, but no doi prefix is configured", name),
+				}
+			}
+			if _, found := Credentials[Service.DOI.Credential]; !found {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message: fmt.Sprintf("Database %s mints DOIs, but the doi credential is invalid: %s",
+						name, Service.DOI.Credential),
+				}
+			}
+		}
+		if db.MaxStagingRequests < 0 {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid max_staging_requests for database %s: %d", name, db.MaxStagingRequests),
+			}
+		}
+		if db.AccessURL != "" {
+			accessURL, err := url.Parse(db.AccessURL)
+			if err != nil || (accessURL.Scheme != "http" && accessURL.Scheme != "https") || accessURL.Host == "" {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Invalid access_url for database %s: %s", name, db.AccessURL),
+				}
+			}
+		}
+		if db.Terms != "" {
+			termsURL, err := url.Parse(db.Terms)
+			if err != nil || (termsURL.Scheme != "http" && termsURL.Scheme != "https") || termsURL.Host == "" {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Invalid terms URL for database %s: %s", name, db.Terms),
+				}
+			}
+		}
+		if strings.Contains(db.License, "://") {
+			licenseURL, err := url.Parse(db.License)
+			if err != nil || (licenseURL.Scheme != "http" && licenseURL.Scheme != "https") || licenseURL.Host == "" {
+				return &InvalidDatabaseConfigError{
+					Database: name,
+					Message:  fmt.Sprintf("Invalid license URL for database %s: %s", name, db.License),
+				}
+			}
+		} else if strings.ContainsAny(db.License, " \t") {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid license for database %s: %s (must be an SPDX identifier or URL)", name, db.License),
+			}
+		}
+		if db.Generic != nil {
+			if err := validateGenericDatabase(name, db); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateGenericDatabase(name string, db databaseConfig) error {
+	generic := db.Generic
+	for _, urlTemplate := range []string{generic.SearchURL, generic.RecordURL} {
+		u, err := url.Parse(urlTemplate)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("Invalid search_url or record_url for generic database %s: '%s'", name, urlTemplate),
+			}
+		}
+	}
+	if !strings.Contains(generic.RecordURL, "{id}") {
+		return &InvalidDatabaseConfigError{
+			Database: name,
+			Message:  fmt.Sprintf("The record_url for generic database %s has no {id} placeholder", name),
+		}
+	}
+	for _, field := range []string{"id", "url"} {
+		if generic.Fields[field] == "" {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("No '%s' field given for generic database %s", field, name),
+			}
+		}
+	}
+	for _, rule := range generic.EndpointRules {
+		if rule.URLPrefix == "" {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message:  fmt.Sprintf("An endpoint rule for generic database %s has no url_prefix", name),
+			}
+		}
+		// a rule names one of several endpoints, or none for a single endpoint
+		_, found := db.Endpoints[rule.Endpoint]
+		if (len(db.Endpoints) > 0 && !found) || (len(db.Endpoints) == 0 && rule.Endpoint != "") {
+			return &InvalidDatabaseConfigError{
+				Database: name,
+				Message: fmt.Sprintf("Invalid endpoint in rule for generic database %s: '%s'",
+					name, rule.Endpoint),
+			}
+		}
+	}
+	if len(db.Endpoints) > 0 && len(generic.EndpointRules) == 0 {
+		return &InvalidDatabaseConfigError{
+			Database: name,
+			Message:  fmt.Sprintf("Generic database %s has several endpoints but no endpoint rules", name),
+		}
+	}
+	return nil
+}
+
+func validateEgressPolicies(policies map[string]egressPolicyConfig) error {
+	for name, policy := range policies {
+		if _, found := Databases[policy.Source]; !found {
+			return &InvalidEgressPolicyConfigError{
+				Policy:  name,
+				Message: fmt.Sprintf("Invalid source database: %s", policy.Source),
+			}
+		}
+		for _, destination := range policy.Destinations {
+			if _, found := Databases[destination]; !found {
+				return &InvalidEgressPolicyConfigError{
+					Policy:  name,
+					Message: fmt.Sprintf("Invalid destination database: %s", destination),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateFormats(formats map[string]string) error {
+	for suffix, format := range formats {
+		if suffix == "" {
+			return &InvalidFormatConfigError{
+				Suffix:  suffix,
+				Message: "Empty file suffix",
+			}
+		}
+		if format == "" {
+			return &InvalidFormatConfigError{
+				Suffix:  suffix,
+				Message: "No format specified",
+			}
+		}
+	}
+	return nil
+}
+
+func validateMimeTypes(mimetypes map[string]string) error {
+	for format, mimetype := range mimetypes {
+		if format == "" {
+			return &InvalidMimeTypeConfigError{
+				Format:  format,
+				Message: "Empty format label",
+			}
+		}
+		if _, _, err := mime.ParseMediaType(mimetype); err != nil {
+			return &InvalidMimeTypeConfigError{
+				Format:  format,
+				Message: fmt.Sprintf("Invalid media type '%s'", mimetype),
+			}
+		}
 	}
 	return nil
 }
@@ -265,8 +810,21 @@ func validateConfig(service, credentials, databases, endpoints bool) error {
 
 	if databases {
 		err = validateDatabases(Databases)
+		if err != nil {
+			return err
+		}
+		err = validateEgressPolicies(EgressPolicies)
+		if err != nil {
+			return err
+		}
 	}
-	return err
+
+	err = validateFormats(Formats)
+	if err != nil {
+		return err
+	}
+
+	return validateMimeTypes(MimeTypes)
 }
 
 // Initializes the entire service configuration using the given YAML byte data.
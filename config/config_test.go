@@ -24,9 +24,12 @@ package config
 // These tests verify that we can properly configure the search service with
 // YAML input.
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -119,6 +122,128 @@ func TestInitRejectsBadDeletionPeriod(t *testing.T) {
 	assert.NotNil(t, err, "Config with bad deletion period didn't trigger an error.")
 }
 
+// tests whether config.Init reports an error for invalid fast lane parameters
+func TestInitRejectsBadFastLaneParameters(t *testing.T) {
+	for _, param := range []string{
+		"max_active_transfers: -1",
+		"fast_lane_max_payload_size: -1",
+		"fast_lane_max_files: -1",
+	} {
+		yaml := VALID_SERVICE + "  " + param + "\n" + VALID_ENDPOINTS + VALID_DATABASES
+		yaml = setTestEnvVars(yaml)
+		b := []byte(yaml)
+		err := Init(b)
+		assert.NotNil(t, err, fmt.Sprintf("Config with bad %s didn't trigger an error.", param))
+	}
+}
+
+// tests whether config.Init reports an error for an invalid small payload size
+func TestInitRejectsBadSmallPayloadMaxSize(t *testing.T) {
+	yaml := VALID_SERVICE + "  small_payload_max_size: -0.1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad small_payload_max_size didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative saved search interval
+func TestInitRejectsBadSavedSearchInterval(t *testing.T) {
+	yaml := VALID_SERVICE + "  saved_search_interval: -1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad saved_search_interval didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for invalid janitor parameters
+func TestInitRejectsBadJanitorParameters(t *testing.T) {
+	for _, param := range []string{
+		"janitor_interval: -1",
+		"scratch_retention: 0",
+	} {
+		yaml := VALID_SERVICE + "  " + param + "\n" + VALID_ENDPOINTS + VALID_DATABASES
+		yaml = setTestEnvVars(yaml)
+		b := []byte(yaml)
+		err := Init(b)
+		assert.NotNil(t, err, fmt.Sprintf("Config with bad %s didn't trigger an error.", param))
+	}
+}
+
+// tests whether config.Init reports an error for a negative disk space threshold
+func TestInitRejectsBadMinFreeDiskSpace(t *testing.T) {
+	yaml := VALID_SERVICE + "  min_free_disk_space: -1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad min_free_disk_space didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative checkpoint interval
+func TestInitRejectsBadCheckpointInterval(t *testing.T) {
+	yaml := VALID_SERVICE + "  checkpoint_interval: -1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad checkpoint_interval didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a negative probe threshold
+func TestInitRejectsBadProbeMinPayloadSize(t *testing.T) {
+	yaml := VALID_SERVICE + "  probe_min_payload_size: -1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad probe_min_payload_size didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for an unknown missing file policy
+func TestInitRejectsBadMissingFilePolicy(t *testing.T) {
+	yaml := VALID_SERVICE + "  missing_file_policy: forgiving\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad missing_file_policy didn't trigger an error.")
+}
+
+// tests whether config.Init reports an error for a pipeline stage without
+// workers, and fills in defaults for stages not given
+func TestInitWorkers(t *testing.T) {
+	yaml := VALID_SERVICE + "  workers:\n    create: 8\n    transfer: 0\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with no transfer workers didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  workers:\n    create: 8\n    transfer: 4\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	err = Init([]byte(yaml))
+	assert.Nil(t, err)
+	assert.Equal(t, workersConfig{Create: 8, Staging: 1, Transfer: 4, Finalize: 1}, Service.Workers)
+}
+
+// tests whether config.Init rejects an invalid retry policy and fills in
+// defaults for settings not given
+func TestInitRetries(t *testing.T) {
+	yaml := VALID_SERVICE + "  retries:\n    max_attempts: 0\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with no submission attempts didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  retries:\n    initial_backoff: 60\n    max_backoff: 30\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with misordered backoffs didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  retries:\n    jitter: 1.5\n" + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with excessive jitter didn't trigger an error.")
+
+	yaml = VALID_SERVICE + "  retries:\n    max_attempts: 5\n    max_backoff: 120\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, retryConfig{MaxAttempts: 5, InitialBackoff: 30, MaxBackoff: 120, Jitter: 0.1},
+		Service.Retries)
+}
+
 // tests whether config.Init reports an error for an invalid credential ID
 func TestInitRejectsBadCredentialID(t *testing.T) {
 	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + `
@@ -131,6 +256,35 @@ credentials:
 	assert.NotNil(t, err, "Config with bad credential ID didn't trigger an error.")
 }
 
+// tests whether config.Init reports an error for a negative credential expiry
+// warning period
+func TestInitRejectsBadCredentialExpiryWarning(t *testing.T) {
+	yaml := VALID_SERVICE + "  credential_expiry_warning: -1\n" + VALID_ENDPOINTS + VALID_DATABASES
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with bad credential_expiry_warning didn't trigger an error.")
+}
+
+// tests whether config.Init reads credential expiration times
+func TestInitReadsCredentialExpiration(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + `
+credentials:
+  expiring:
+    id: client
+    expires: 2026-06-30
+  non-expiring:
+    id: client
+`
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.Nil(t, err, fmt.Sprintf("Valid YAML input produced an error: %s", err))
+	assert.Equal(t, time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), Credentials["expiring"].Expires)
+	assert.True(t, Credentials["non-expiring"].Expires.IsZero())
+	assert.Equal(t, 14*24*3600, Service.CredentialExpiryWarning)
+}
+
 // tests whether config.Init reports an error for an invalid endpoint ID
 func TestInitRejectsBadEndpointID(t *testing.T) {
 	// omits the endpoint ID so it is initialized to the zero value
@@ -174,6 +328,56 @@ endpoints:
 	assert.NotNil(t, err, "Config with missing endpoint provider didn't trigger an error.")
 }
 
+// tests whether config.Init reports an error for an invalid relay endpoint
+func TestInitRejectsBadRelayEndpoint(t *testing.T) {
+	for _, relay := range []string{"nonexistent-endpoint", "my-globus-endpoint"} {
+		yaml := VALID_SERVICE + VALID_DATABASES + `
+endpoints:
+  my-globus-endpoint:
+    name: Globus test endpoint
+    id: ${DTS_GLOBUS_TEST_ENDPOINT}
+    provider: globus
+    relay: ` + relay + `
+`
+		yaml = setTestEnvVars(yaml)
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, "Config with bad relay endpoint didn't trigger an error.")
+	}
+}
+
+// tests whether config.Init reports an error for an SFTP endpoint without a
+// host or credential
+func TestInitRejectsIncompleteSFTPEndpoint(t *testing.T) {
+	for _, fields := range []string{
+		"    credential: sftp-key\n",
+		"    host: sftp.example.org\n",
+	} {
+		yaml := VALID_SERVICE + VALID_ENDPOINTS + `  my-sftp-endpoint:
+    name: SFTP test endpoint
+    id: 5e1ff9a4-8f3a-4b2e-9a55-2f6f4c1d7b3e
+    provider: sftp
+` + fields + VALID_DATABASES
+		yaml = setTestEnvVars(yaml)
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, "Config with incomplete SFTP endpoint didn't trigger an error.")
+	}
+}
+
+// tests whether config.Init reports an error for an HTTPS endpoint with an
+// invalid root URL
+func TestInitRejectsBadHTTPSEndpointRoot(t *testing.T) {
+	for _, root := range []string{"/data/files", "ftp://example.org/files", "https://"} {
+		yaml := VALID_SERVICE + VALID_ENDPOINTS + `  my-https-endpoint:
+    name: HTTPS test endpoint
+    id: 7b0c4d2e-1f3a-4c5b-8d6e-9f0a1b2c3d4e
+    provider: https
+    root: ` + root + "\n" + VALID_DATABASES
+		yaml = setTestEnvVars(yaml)
+		err := Init([]byte(yaml))
+		assert.NotNil(t, err, "Config with bad HTTPS endpoint root didn't trigger an error.")
+	}
+}
+
 // tests whether config.Init reports an error for an invalid max number of
 // processes
 func TestInitRejectsBadPort(t *testing.T) {
@@ -289,6 +493,249 @@ databases:
 	assert.NotNil(t, err, "Config with database with invalid endpoint didn't trigger an error.")
 }
 
+// tests whether config.Init rejects a configuration with a database that has
+// a negative limit on outstanding staging requests
+func TestInitRejectsDatabaseWithBadMaxStagingRequests(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    max_staging_requests: -1\n"
+	yaml = setTestEnvVars(yaml)
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with database with negative max_staging_requests didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    max_staging_requests: 4\n"
+	yaml = setTestEnvVars(yaml)
+	err = Init([]byte(yaml))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, Databases["jdp"].MaxStagingRequests)
+}
+
+// tests whether config.Init rejects a configuration with a database that has
+// a malformed access URL
+func TestInitRejectsDatabaseWithBadAccessURL(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    access_url: files.jgi.doe.gov\n"
+	yaml = setTestEnvVars(yaml)
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with database with malformed access_url didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    access_url: https://files.jgi.doe.gov/dts\n"
+	yaml = setTestEnvVars(yaml)
+	err = Init([]byte(yaml))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://files.jgi.doe.gov/dts", Databases["jdp"].AccessURL)
+}
+
+// tests whether config.Init rejects a database with a malformed default license
+func TestInitRejectsDatabaseWithBadLicense(t *testing.T) {
+	for _, license := range []string{"ftp://example.org/license", "Creative Commons"} {
+		yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    license: " + license + "\n"
+		err := Init([]byte(setTestEnvVars(yaml)))
+		assert.NotNil(t, err, "Config with database with malformed license didn't trigger an error.")
+	}
+
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    license: CC-BY-4.0\n"
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, "CC-BY-4.0", Databases["jdp"].License)
+}
+
+// tests whether config.Init rejects a database with malformed usage terms
+func TestInitRejectsDatabaseWithBadTerms(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    terms: jgi.doe.gov/policies\n"
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with database with malformed terms URL didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + "    terms: https://jgi.doe.gov/policies\n"
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://jgi.doe.gov/policies", Databases["jdp"].Terms)
+}
+
+// tests whether config.Init validates the catalog of a generic database
+func TestInitGenericDatabases(t *testing.T) {
+	generic := `
+    generic:
+      search_url: https://www.facebase.org/ermrest/catalog/1/entity/isa:file/*::ciregexp::{query}?limit={limit}
+      record_url: RECORD_URL
+      fields:
+        id: RID
+        url: url
+`
+	for _, recordURL := range []string{"www.facebase.org/entity/RID={id}",
+		"https://www.facebase.org/ermrest/catalog/1/entity/isa:file"} {
+		yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+			strings.ReplaceAll(generic, "RECORD_URL", recordURL)
+		err := Init([]byte(setTestEnvVars(yaml)))
+		assert.NotNil(t, err, "Config with invalid generic record_url didn't trigger an error.")
+	}
+
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		strings.ReplaceAll(strings.ReplaceAll(generic, "RECORD_URL",
+			"https://www.facebase.org/ermrest/catalog/1/entity/isa:file/RID={id}"),
+			"        url: url\n", "")
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with generic database without url field didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		strings.ReplaceAll(generic, "RECORD_URL",
+			"https://www.facebase.org/ermrest/catalog/1/entity/isa:file/RID={id}")
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, "RID", Databases["jdp"].Generic.Fields["id"])
+
+	// endpoint rules can't name endpoints for a database with a single endpoint
+	validGeneric := strings.ReplaceAll(generic, "RECORD_URL",
+		"https://www.facebase.org/ermrest/catalog/1/entity/isa:file/RID={id}")
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + validGeneric +
+		"      endpoint_rules:\n        - url_prefix: https://www.facebase.org/hatrac/\n" +
+		"          endpoint: mirror\n"
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with generic endpoint rule naming an unknown endpoint didn't trigger an error.")
+
+	yaml = VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + validGeneric +
+		"      endpoint_rules:\n        - url_prefix: https://www.facebase.org/hatrac/\n"
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+}
+
+// tests whether config.Init validates the DataCite settings needed by a
+// database that mints DOIs
+func TestInitDOIs(t *testing.T) {
+	dataciteCredential := `
+credentials:
+  datacite:
+    id: DTS.TEST
+    secret: shhh
+`
+	mintDOIs := "    mint_dois: true\n"
+
+	// no prefix
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + dataciteCredential + VALID_DATABASES + mintDOIs
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Database minting DOIs without a doi prefix didn't trigger an error.")
+
+	// bad prefix
+	yaml = VALID_SERVICE + "  doi:\n    prefix: 12345\n    credential: datacite\n" +
+		VALID_ENDPOINTS + dataciteCredential + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with invalid doi prefix didn't trigger an error.")
+
+	// missing credential
+	yaml = VALID_SERVICE + "  doi:\n    prefix: 10.12345\n    credential: nope\n" +
+		VALID_ENDPOINTS + dataciteCredential + VALID_DATABASES + mintDOIs
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Database minting DOIs with an invalid doi credential didn't trigger an error.")
+
+	// all good
+	yaml = VALID_SERVICE + "  doi:\n    prefix: 10.12345\n    credential: datacite\n" +
+		VALID_ENDPOINTS + dataciteCredential + VALID_DATABASES + mintDOIs
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://api.datacite.org", Service.DOI.Endpoint)
+	assert.Equal(t, "10.12345", Service.DOI.Prefix)
+	assert.True(t, Databases["jdp"].MintDOIs)
+}
+
+// tests whether config.Init reads and validates tracing settings
+func TestInitTracing(t *testing.T) {
+	// bad collector URL
+	yaml := VALID_SERVICE + "  tracing:\n    otlp_endpoint: otel-collector:4318\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with invalid otlp_endpoint didn't trigger an error.")
+
+	// bad export interval
+	yaml = VALID_SERVICE + "  tracing:\n    export_interval: -1\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with negative tracing export_interval didn't trigger an error.")
+
+	// all good
+	yaml = VALID_SERVICE + "  tracing:\n    otlp_endpoint: http://otel-collector:4318\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, "http://otel-collector:4318", Service.Tracing.OTLPEndpoint)
+	assert.Equal(t, "dts", Service.Tracing.ServiceName)
+	assert.Equal(t, 5, Service.Tracing.ExportInterval)
+}
+
+// tests whether config.Init reads and validates default, user, and client quotas
+func TestInitQuotas(t *testing.T) {
+	// negative limit
+	yaml := VALID_SERVICE + "  quotas:\n    users:\n      1234-5678-9012-3456:\n        max_concurrent_transfers: -1\n" +
+		VALID_ENDPOINTS + VALID_DATABASES
+	err := Init([]byte(setTestEnvVars(yaml)))
+	assert.NotNil(t, err, "Config with negative quota didn't trigger an error.")
+
+	// all good
+	yaml = VALID_SERVICE + `  quotas:
+    default:
+      max_concurrent_transfers: 2
+    clients:
+      kbase_pipeline:
+        max_daily_payload_size: 500
+` + VALID_ENDPOINTS + VALID_DATABASES
+	err = Init([]byte(setTestEnvVars(yaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, Service.Quotas.Default.MaxConcurrentTransfers)
+	assert.Equal(t, 500.0, Service.Quotas.Clients["kbase_pipeline"].MaxDailyPayloadSize)
+}
+
+// tests whether config.Init rejects an egress policy referring to a database
+// that isn't configured
+func TestInitRejectsEgressPolicyWithInvalidDatabase(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + `
+egress_policies:
+  jdp-private-data:
+    source: jdp
+    destinations: [kbase]
+`
+	yaml = setTestEnvVars(yaml)
+	err := Init([]byte(yaml))
+	assert.NotNil(t, err, "Config with egress policy with invalid destination didn't trigger an error.")
+	assert.IsType(t, &InvalidEgressPolicyConfigError{}, err)
+}
+
+// tests whether config.Init reads a valid egress policy
+func TestInitReadsEgressPolicies(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES + `
+egress_policies:
+  jdp-private-data:
+    description: JDP private data may only be transferred back to the JDP
+    source: jdp
+    match:
+      private: true
+    destinations: [jdp]
+`
+	yaml = setTestEnvVars(yaml)
+	err := Init([]byte(yaml))
+	assert.Nil(t, err)
+	policy, found := EgressPolicies["jdp-private-data"]
+	assert.True(t, found)
+	assert.Equal(t, "jdp", policy.Source)
+	assert.Equal(t, map[string]any{"private": true}, policy.Match)
+	assert.Equal(t, []string{"jdp"}, policy.Destinations)
+}
+
+// tests whether config.Init rejects a file format with no format label
+func TestInitRejectsFormatWithoutLabel(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"formats:\n  vcf: \"\"\n"
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with unlabeled file format didn't trigger an error.")
+}
+
+// tests whether config.Init rejects a malformed media type for a file format
+func TestInitRejectsInvalidMimeType(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"mimetypes:\n  vcf: \"not a media type\"\n"
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.NotNil(t, err, "Config with invalid media type didn't trigger an error.")
+}
+
 // Tests whether config.Init returns no error for a configuration that is
 // (ostensibly) valid. NOTE: This particular configuration is consistent and
 // contains acceptible values for fields. It won't actually run a service!
@@ -313,6 +760,52 @@ func TestInitProperlySetsGlobals(t *testing.T) {
 	assert.Equal(t, 100, Service.MaxConnections)
 	assert.Equal(t, 1, len(Endpoints))
 	assert.Equal(t, 1, len(Databases))
+	assert.Equal(t, 0, len(Formats))
+}
+
+// Tests whether config.Init normalizes the suffixes of configured file formats.
+func TestInitNormalizesFormatSuffixes(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"formats:\n  .VCF.gz: vcf\n  mzML: mzml\n"
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.Nil(t, err, fmt.Sprintf("Valid YAML input produced an error: %s", err))
+	assert.Equal(t, map[string]string{"vcf.gz": "vcf", "mzml": "mzml"}, Formats)
+}
+
+// Tests whether config.Init reads and normalizes the media types of file formats.
+func TestInitReadsMimeTypes(t *testing.T) {
+	yaml := VALID_SERVICE + VALID_ENDPOINTS + VALID_DATABASES +
+		"mimetypes:\n  vcf: text/X-VCF\n  mzml: application/vnd.hupo.psi.mzml+xml\n"
+	yaml = setTestEnvVars(yaml)
+	b := []byte(yaml)
+	err := Init(b)
+	assert.Nil(t, err, fmt.Sprintf("Valid YAML input produced an error: %s", err))
+	assert.Equal(t, map[string]string{
+		"vcf":  "text/x-vcf",
+		"mzml": "application/vnd.hupo.psi.mzml+xml",
+	}, MimeTypes)
+}
+
+// Tests whether FIPS mode restricts TLS configurations and hash algorithms.
+func TestFIPSModeRestrictsCrypto(t *testing.T) {
+	assert := assert.New(t)
+	defer func() { Service.FIPSMode = false }()
+
+	Service.FIPSMode = false
+	assert.Nil(TLSConfig())
+	assert.Nil(HttpTransport())
+	assert.True(HashAlgorithmAllowed("md5"))
+
+	Service.FIPSMode = true
+	tlsConfig := TLSConfig()
+	assert.NotNil(tlsConfig)
+	assert.Equal(uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.NotNil(HttpTransport())
+	assert.False(HashAlgorithmAllowed("md5"))
+	assert.True(HashAlgorithmAllowed("sha256"))
+	assert.True(HashAlgorithmAllowed("SHA256"))
 }
 
 // this function gets called at the begіnning of a test session
@@ -21,10 +21,25 @@
 
 package config
 
+import (
+	"time"
+)
+
 type credentialConfig struct {
+	// the provider from which the credential is obtained: "config" (the
+	// default) uses the ID and secret given here, "vault" fetches them from
+	// HashiCorp Vault, and "file" reads the secret from a file
+	Provider string `yaml:"provider,omitempty"`
+	// the path of the credential's secret within Vault's KV secrets engine
+	// (for the "vault" provider) or of the file containing it (for the "file"
+	// provider)
+	Path string `yaml:"path,omitempty"`
 	// the ID used for authentication (username or UUID)
 	Id string `yaml:"id"`
 	// the secret used for authentication (e.g. password)
 	// DO NOT STORE THIS IN A CONFIG FILE! Use an environment variable instead
 	Secret string `yaml:"secret"`
+	// the time at which the credential expires (if known), used to alert
+	// administrators before it does (e.g. a Globus client secret's expiration)
+	Expires time.Time `yaml:"expires,omitempty"`
 }
@@ -33,4 +33,34 @@ type databaseConfig struct {
 	// if set, a set of endpoints assigned functional names, available to thi
 	// database (only one of Endpoint and Endpoints may be set)
 	Endpoints map[string]string `yaml:"endpoints,omitempty"`
+	// if set, file checksums are neither submitted with transfers to or from
+	// this database nor verified against those published by the source
+	SkipChecksums bool `yaml:"skip_checksums,omitempty"`
+	// if set, the name of the credential used to authenticate with the
+	// database (instead of database-specific environment variables)
+	Credential string `yaml:"credential,omitempty"`
+	// if positive, the maximum number of staging requests each user may have
+	// outstanding at the database at once (for databases that support it) --
+	// further requests are queued until earlier ones complete
+	MaxStagingRequests int `yaml:"max_staging_requests,omitempty"`
+	// if set, the base URL at which files delivered to this database can be
+	// accessed (a file's URL is this base URL joined with its path relative to
+	// the root of the database's endpoint)
+	AccessURL string `yaml:"access_url,omitempty"`
+	// if set, a schema.org Dataset description (JSON-LD) of each transfer's
+	// payload is delivered to this database alongside its manifest
+	DatasetJSONLD bool `yaml:"dataset_jsonld,omitempty"`
+	// if set, the license (an SPDX identifier or a URL) under which files from
+	// this database may be used, recorded for those whose metadata names none
+	License string `yaml:"license,omitempty"`
+	// if set, the URL of usage terms (e.g. a data usage policy) that each user
+	// must accept (with accepted_terms) in their first transfer from this
+	// database
+	Terms string `yaml:"terms,omitempty"`
+	// if set, a DOI is minted with DataCite (see the service's doi settings)
+	// for each payload delivered to this database
+	MintDOIs bool `yaml:"mint_dois,omitempty"`
+	// if set, the database is a generic REST catalog described by this
+	// configuration instead of a database with its own implementation
+	Generic *genericDatabaseConfig `yaml:"generic,omitempty"`
 }
@@ -0,0 +1,35 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// settings for minting DOIs with DataCite for the payloads delivered to
+// databases configured with mint_dois
+type doiConfig struct {
+	// the URL of the DataCite REST API
+	// default: https://api.datacite.org
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// the DOI prefix assigned to the DTS's DataCite repository (e.g. 10.12345)
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// the name of the credential (DataCite repository ID and password) used to
+	// authenticate with DataCite
+	Credential string `json:"credential,omitempty" yaml:"credential,omitempty"`
+}
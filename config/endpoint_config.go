@@ -15,4 +15,12 @@ type endpointConfig struct {
 	Credential string `yaml:"credential"`
 	// root directory for filesystem access (optional)
 	Root string `yaml:"root,omitempty"`
+	// the host (and optional port, as "host:port") of an SFTP endpoint
+	Host string `yaml:"host,omitempty"`
+	// the name of an intermediate endpoint through which transfers from this
+	// endpoint are relayed, for destinations it can't reach directly (optional)
+	Relay string `yaml:"relay,omitempty"`
+	// if set, transfers to this endpoint must be encrypted, and are rejected if
+	// their source endpoints can't encrypt them
+	EncryptData bool `yaml:"encrypt_data,omitempty"`
 }
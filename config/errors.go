@@ -60,3 +60,30 @@ type InvalidDatabaseConfigError struct {
 func (e InvalidDatabaseConfigError) Error() string {
 	return fmt.Sprintf("Database %s is not properly configured: %s", e.Database, e.Message)
 }
+
+// indicates that an egress policy is not configured properly
+type InvalidEgressPolicyConfigError struct {
+	Policy, Message string
+}
+
+func (e InvalidEgressPolicyConfigError) Error() string {
+	return fmt.Sprintf("Egress policy %s is not properly configured: %s", e.Policy, e.Message)
+}
+
+// indicates that a file format is not configured properly
+type InvalidFormatConfigError struct {
+	Suffix, Message string
+}
+
+func (e InvalidFormatConfigError) Error() string {
+	return fmt.Sprintf("File format for suffix '%s' is not properly configured: %s", e.Suffix, e.Message)
+}
+
+// indicates that a media (MIME) type is not configured properly
+type InvalidMimeTypeConfigError struct {
+	Format, Message string
+}
+
+func (e InvalidMimeTypeConfigError) Error() string {
+	return fmt.Sprintf("Media type for format '%s' is not properly configured: %s", e.Format, e.Message)
+}
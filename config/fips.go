@@ -0,0 +1,88 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// TLS 1.2 cipher suites approved for use in FIPS mode (all TLS 1.3 suites
+// supported by Go are approved)
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// hash algorithms (as named in Frictionless descriptors) approved for use in
+// FIPS mode
+var fipsHashAlgorithms = map[string]struct{}{
+	"sha256": {},
+	"sha384": {},
+	"sha512": {},
+}
+
+// Returns a TLS configuration for outgoing connections. In FIPS mode, this
+// restricts protocol versions, cipher suites, and key exchange curves to
+// FIPS-approved choices. Otherwise, it returns nil, indicating Go's defaults.
+func TLSConfig() *tls.Config {
+	if !Service.FIPSMode {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     fipsCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+	}
+}
+
+// Returns an HTTP transport for outgoing connections that uses the TLS
+// configuration returned by TLSConfig, or nil (indicating Go's default
+// transport) if no restrictions apply.
+func HttpTransport() http.RoundTripper {
+	if !Service.FIPSMode {
+		return nil
+	}
+	return fipsTransport()
+}
+
+// a single transport shared by all connections in FIPS mode, so they can be
+// reused
+var fipsTransport = sync.OnceValue(func() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = TLSConfig()
+	return transport
+})
+
+// Returns true if the given hash algorithm (e.g. "md5", "sha256") may be used
+// to verify files. In FIPS mode, only FIPS-approved algorithms may be used.
+func HashAlgorithmAllowed(algorithm string) bool {
+	if !Service.FIPSMode {
+		return true
+	}
+	_, approved := fipsHashAlgorithms[strings.ToLower(algorithm)]
+	return approved
+}
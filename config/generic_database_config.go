@@ -0,0 +1,63 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// A generic database is described entirely by its configuration, allowing a
+// small REST catalog (e.g. a DERIVA catalog like FaceBase's) to be searched
+// without a database-specific implementation.
+type genericDatabaseConfig struct {
+	// the URL used to search the catalog, in which {query}, {offset}, and
+	// {limit} are replaced by the (URL-encoded) search query, the number of
+	// results to skip, and the maximum number of results
+	SearchURL string `yaml:"search_url"`
+	// the URL used to fetch the record for a single file, in which {id} is
+	// replaced by the file's (URL-encoded) identifier within the catalog
+	RecordURL string `yaml:"record_url"`
+	// the dot-separated path of the list of records within a search response
+	// (if empty, the response itself is the list)
+	ResultsField string `yaml:"results_field,omitempty"`
+	// the prefix of file IDs in the DTS (default: the upper-cased database
+	// name followed by a colon)
+	IdPrefix string `yaml:"id_prefix,omitempty"`
+	// the HTTP header in which the database's credential (if any) is sent
+	// (default: Authorization)
+	AuthHeader string `yaml:"auth_header,omitempty"`
+	// the scheme preceding the credential in the header (default: Bearer for
+	// the Authorization header, none for others)
+	AuthScheme string `yaml:"auth_scheme,omitempty"`
+	// a mapping of descriptor fields (id, url, name, bytes, hash, mediatype,
+	// format, description, embargo_until, recursive) to the dot-separated paths
+	// of the record fields from which they're taken (id and url are required)
+	Fields map[string]string `yaml:"fields"`
+	// rules assigning files to the database's endpoints by their URLs
+	EndpointRules []genericEndpointRule `yaml:"endpoint_rules,omitempty"`
+}
+
+// A rule that assigns files whose URLs begin with a prefix to an endpoint,
+// relative to whose root their paths are given by the rest of their URLs.
+type genericEndpointRule struct {
+	// the URL prefix identifying files on the endpoint
+	URLPrefix string `yaml:"url_prefix"`
+	// the functional name of the endpoint (for databases with several
+	// endpoints)
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// limits on the transfers requested by a user or through a DTS client
+type quotaConfig struct {
+	// maximum number of transfers that may be in progress at once
+	// default: 0 (no limit)
+	MaxConcurrentTransfers int `json:"max_concurrent_transfers,omitempty" yaml:"max_concurrent_transfers,omitempty"`
+	// maximum total size of the payloads requested in any 24-hour period
+	// (gigabytes)
+	// default: 0 (no limit)
+	MaxDailyPayloadSize float64 `json:"max_daily_payload_size,omitempty" yaml:"max_daily_payload_size,omitempty"`
+}
+
+// quotas on the transfers requested by users and DTS clients
+type quotasConfig struct {
+	// quota applied to each user without a quota of their own
+	Default quotaConfig `json:"default" yaml:"default,omitempty"`
+	// quotas for specific users, by ORCID
+	Users map[string]quotaConfig `json:"users,omitempty" yaml:"users,omitempty"`
+	// quotas shared by all transfers requested through specific DTS clients,
+	// by KBase username
+	Clients map[string]quotaConfig `json:"clients,omitempty" yaml:"clients,omitempty"`
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// policy for retrying the submission of transfers and manifests that fail
+// because of transient errors (e.g. Globus being briefly unavailable)
+type retryConfig struct {
+	// maximum number of attempts made to submit a transfer or manifest before
+	// its task fails
+	// default: 1 (no retries)
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// delay before the first retry, which doubles with each further retry
+	// (seconds)
+	// default: 30
+	InitialBackoff int `json:"initial_backoff" yaml:"initial_backoff"`
+	// maximum delay between attempts (seconds)
+	// default: 600
+	MaxBackoff int `json:"max_backoff" yaml:"max_backoff"`
+	// fraction of each delay by which it is randomly lengthened or shortened,
+	// so that submissions failing together aren't retried together
+	// default: 0.1
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// settings for simulation mode, in which the DTS replaces all of its database
+// connectors and endpoints with deterministic fakes so that it can be
+// integration-tested without real credentials
+type simulationConfig struct {
+	// flag indicating whether simulation mode is enabled
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// time added to every simulated database or endpoint operation (milliseconds)
+	Latency int `json:"latency,omitempty" yaml:"latency,omitempty"`
+	// time taken by simulated staging operations (milliseconds)
+	StagingDuration int `json:"staging_duration,omitempty" yaml:"staging_duration,omitempty"`
+	// time taken by simulated file transfers (milliseconds)
+	TransferDuration int `json:"transfer_duration,omitempty" yaml:"transfer_duration,omitempty"`
+	// fraction (0-1) of staging operations that fail
+	StagingFailureRate float64 `json:"staging_failure_rate,omitempty" yaml:"staging_failure_rate,omitempty"`
+	// fraction (0-1) of file transfers that fail
+	TransferFailureRate float64 `json:"transfer_failure_rate,omitempty" yaml:"transfer_failure_rate,omitempty"`
+	// seed that determines which operations fail (operations on the same files
+	// fail consistently for a given seed)
+	Seed int64 `json:"seed,omitempty" yaml:"seed,omitempty"`
+}
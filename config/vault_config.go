@@ -0,0 +1,39 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// settings for fetching credentials from a HashiCorp Vault server, for
+// credentials configured with the "vault" provider
+type vaultConfig struct {
+	// the URL of the Vault server (e.g. https://vault.example.org:8200)
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	// the token used to authenticate with Vault
+	// DO NOT STORE THIS IN A CONFIG FILE! Use an environment variable instead
+	Token string `json:"-" yaml:"token,omitempty"`
+	// the mount path of the KV (version 2) secrets engine holding credentials
+	// default: "secret"
+	Mount string `json:"mount,omitempty" yaml:"mount,omitempty"`
+	// interval after which a credential fetched from Vault is fetched again, so
+	// that rotated secrets are picked up (seconds)
+	// default: 5 minutes
+	RefreshInterval int `json:"refresh_interval,omitempty" yaml:"refresh_interval,omitempty"`
+}
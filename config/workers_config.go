@@ -0,0 +1,39 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+// numbers of workers that update transfer tasks concurrently in each stage of
+// the transfer pipeline (each task is updated by at most one worker at a time)
+type workersConfig struct {
+	// workers creating new tasks (resolving files and preparing subtasks)
+	// default: 1
+	Create int `json:"create" yaml:"create"`
+	// workers checking on the staging of files at their sources
+	// default: 1
+	Staging int `json:"staging" yaml:"staging"`
+	// workers submitting and monitoring file transfers
+	// default: 1
+	Transfer int `json:"transfer" yaml:"transfer"`
+	// workers generating and delivering manifests
+	// default: 1
+	Finalize int `json:"finalize" yaml:"finalize"`
+}
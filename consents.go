@@ -0,0 +1,73 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/globus"
+)
+
+// checks the consents required by each configured Globus endpoint, printing
+// the consent URLs for any data_access scopes and verifying access to each
+// endpoint's collection, and returns an exit status (0 if all endpoints are
+// accessible, 1 if not)
+func checkConsents(w io.Writer) int {
+	err := endpoints.RegisterEndpointProvider("globus", globus.NewEndpointFromConfig)
+	if err != nil {
+		fmt.Fprintf(w, "Couldn't register the Globus endpoint provider: %s\n", err.Error())
+		return 1
+	}
+
+	status := 0
+	for _, name := range slices.Sorted(maps.Keys(config.Endpoints)) {
+		if config.Endpoints[name].Provider != "globus" {
+			continue
+		}
+		fmt.Fprintf(w, "%s (%s):\n", name, config.Endpoints[name].Id.String())
+		endpoint, err := endpoints.NewEndpoint(name)
+		if err != nil {
+			fmt.Fprintf(w, "  FAILED: couldn't access endpoint: %s\n", err.Error())
+			status = 1
+			continue
+		}
+		consent := endpoint.(*globus.Endpoint).CheckConsent()
+		if consent.Scope != "" {
+			fmt.Fprintf(w, "  requires consent for scope %s\n", consent.Scope)
+			fmt.Fprintf(w, "  grant consent at: %s\n", consent.ConsentURL)
+		} else {
+			fmt.Fprintf(w, "  requires no data_access consent\n")
+		}
+		if consent.Verified {
+			fmt.Fprintf(w, "  OK: collection is accessible\n")
+		} else {
+			fmt.Fprintf(w, "  FAILED: couldn't list collection: %s\n", consent.Message)
+			status = 1
+		}
+	}
+	return status
+}
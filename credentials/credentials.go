@@ -0,0 +1,262 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/config"
+)
+
+// This package provides the credentials (IDs and secrets) the DTS uses to
+// authenticate with endpoints and databases. Each credential is obtained from
+// the provider named in its configuration: the DTS configuration itself, a
+// HashiCorp Vault server, or a file (e.g. a mounted Kubernetes secret). Secrets
+// from Vault and from files are fetched at runtime and fetched again
+// periodically so that rotated secrets are picked up.
+
+// a credential used to authenticate with an endpoint or database
+type Credential struct {
+	// the ID used for authentication (username, client UUID, etc)
+	Id string
+	// the secret used for authentication (password, client secret, etc)
+	Secret string
+}
+
+// This type represents a source of credentials.
+type Provider interface {
+	// Fetches the credential with the given name, returning it along with the
+	// time after which it should be fetched again (zero if it needn't be).
+	Fetch(name string) (Credential, time.Time, error)
+}
+
+// Returns the credential with the given name, fetching it from its provider if
+// it hasn't been fetched or is due to be fetched again. If a credential can't be
+// fetched again, the one fetched previously is returned (and the failure is
+// logged) until it's invalidated.
+func Get(name string) (Credential, error) {
+	mutex_.Lock()
+	cached, found := cache_[name]
+	mutex_.Unlock()
+	if found && (cached.RefreshTime.IsZero() || time.Now().Before(cached.RefreshTime)) {
+		return cached.Credential, nil
+	}
+
+	credentialConfig, ok := config.Credentials[name]
+	if !ok {
+		return Credential{}, &NotFoundError{Name: name}
+	}
+	var provider Provider
+	switch credentialConfig.Provider {
+	case "", "config":
+		provider = configProvider{}
+	case "vault":
+		provider = vaultProvider{}
+	case "file":
+		provider = fileProvider{}
+	default:
+		return Credential{}, &InvalidProviderError{
+			Name:     name,
+			Provider: credentialConfig.Provider,
+		}
+	}
+
+	// fetch without holding the lock, since providers may be slow to respond
+	credential, refreshTime, err := provider.Fetch(name)
+	if err != nil {
+		if found { // keep using what we have
+			slog.Warn(fmt.Sprintf("Couldn't refresh credential '%s' (using the previous one): %s",
+				name, err.Error()))
+			return cached.Credential, nil
+		}
+		return Credential{}, err
+	}
+	mutex_.Lock()
+	cache_[name] = cachedCredential{
+		Credential:  credential,
+		RefreshTime: refreshTime,
+	}
+	mutex_.Unlock()
+	return credential, nil
+}
+
+// Discards all fetched credentials, so that each is fetched again from its
+// provider when next requested.
+func Reset() {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	clear(cache_)
+}
+
+// Discards the fetched credential with the given name, so that it's fetched
+// again from its provider when next requested. This is useful when a secret
+// has been rejected, since it may have been rotated in the meantime.
+func Invalidate(name string) {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	delete(cache_, name)
+}
+
+//-----------
+// Internals
+//-----------
+
+type cachedCredential struct {
+	Credential  Credential
+	RefreshTime time.Time
+}
+
+// fetched credentials, by name, and a mutex that guards them
+var cache_ = make(map[string]cachedCredential)
+var mutex_ sync.Mutex
+
+// provides credentials given in the DTS configuration
+type configProvider struct{}
+
+func (p configProvider) Fetch(name string) (Credential, time.Time, error) {
+	credentialConfig := config.Credentials[name]
+	return Credential{
+		Id:     credentialConfig.Id,
+		Secret: credentialConfig.Secret,
+	}, time.Time{}, nil
+}
+
+// provides credentials stored in the KV (version 2) secrets engine of a
+// HashiCorp Vault server, as secrets with "id" and "secret" keys (the ID in the
+// DTS configuration is used if a secret has no "id" key)
+type vaultProvider struct{}
+
+func (p vaultProvider) Fetch(name string) (Credential, time.Time, error) {
+	credentialConfig := config.Credentials[name]
+	vault := config.Service.Vault
+
+	// https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#read-secret-version
+	resource, err := url.JoinPath(vault.Address, "v1", vault.Mount, "data",
+		strings.TrimPrefix(credentialConfig.Path, "/"))
+	if err != nil {
+		return Credential{}, time.Time{}, err
+	}
+	request, err := http.NewRequest(http.MethodGet, resource, http.NoBody)
+	if err != nil {
+		return Credential{}, time.Time{}, err
+	}
+	request.Header.Set("X-Vault-Token", vault.Token)
+	client := http.Client{
+		Transport: config.HttpTransport(),
+		Timeout:   10 * time.Second,
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return Credential{}, time.Time{}, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Credential{}, time.Time{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(body, &errorResponse)
+		return Credential{}, time.Time{}, &VaultError{
+			Credential: name,
+			Status:     response.StatusCode,
+			Message:    strings.Join(errorResponse.Errors, "; "),
+		}
+	}
+
+	var secretResponse struct {
+		LeaseDuration int `json:"lease_duration"`
+		Data          struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &secretResponse)
+	if err != nil {
+		return Credential{}, time.Time{}, err
+	}
+	secret, found := secretResponse.Data.Data["secret"]
+	if !found {
+		return Credential{}, time.Time{}, &VaultError{
+			Credential: name,
+			Status:     response.StatusCode,
+			Message:    fmt.Sprintf("secret at %s has no 'secret' key", credentialConfig.Path),
+		}
+	}
+	credential := Credential{
+		Id:     credentialConfig.Id,
+		Secret: secret,
+	}
+	if id, found := secretResponse.Data.Data["id"]; found {
+		credential.Id = id
+	}
+
+	// fetch the credential again when its lease expires (if it has one) or
+	// after the configured refresh interval, whichever comes first
+	refreshInterval := time.Duration(vault.RefreshInterval) * time.Second
+	if secretResponse.LeaseDuration > 0 {
+		refreshInterval = min(refreshInterval, time.Duration(secretResponse.LeaseDuration)*time.Second)
+	}
+	return credential, time.Now().Add(refreshInterval), nil
+}
+
+// the interval at which a credential stored in a file is read again
+const fileRefreshInterval = time.Minute
+
+// provides credentials whose secrets are stored in files (e.g. mounted
+// Kubernetes secrets or tokens written by a renewal job), which are read again
+// periodically so that renewed secrets are picked up without a restart
+type fileProvider struct{}
+
+func (p fileProvider) Fetch(name string) (Credential, time.Time, error) {
+	credentialConfig := config.Credentials[name]
+	data, err := os.ReadFile(credentialConfig.Path)
+	if err != nil {
+		return Credential{}, time.Time{}, &FileError{
+			Credential: name,
+			Path:       credentialConfig.Path,
+			Err:        err,
+		}
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return Credential{}, time.Time{}, &FileError{
+			Credential: name,
+			Path:       credentialConfig.Path,
+			Err:        fmt.Errorf("the file is empty"),
+		}
+	}
+	return Credential{
+		Id:     credentialConfig.Id,
+		Secret: secret,
+	}, time.Now().Add(fileRefreshInterval), nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credentials
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+)
+
+// a configuration with credentials from the config file and from Vault
+const credentialsConfig string = `
+service:
+  vault:
+    address: VAULT_ADDRESS
+    token: test-token
+    refresh_interval: 60
+credentials:
+  static:
+    id: static-id
+    secret: static-secret
+  rotated:
+    provider: vault
+    path: dts/globus
+    id: configured-id
+  missing:
+    provider: vault
+    path: dts/missing
+  renewed:
+    provider: file
+    path: SECRET_FILE
+    id: file-id
+`
+
+// the current secret stored in the test Vault server, and the number of
+// secrets it has served
+var vaultSecret_ atomic.Value
+var numVaultReads_ atomic.Int32
+
+// the file containing the secret for the "renewed" credential
+var secretFile_ string
+
+func TestGetConfiguredCredential(t *testing.T) {
+	assert := assert.New(t)
+
+	credential, err := Get("static")
+	assert.Nil(err)
+	assert.Equal(Credential{Id: "static-id", Secret: "static-secret"}, credential)
+
+	_, err = Get("nonexistent")
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestGetVaultCredential(t *testing.T) {
+	assert := assert.New(t)
+	Reset()
+
+	vaultSecret_.Store("first-secret")
+	numReads := numVaultReads_.Load()
+	credential, err := Get("rotated")
+	assert.Nil(err)
+	assert.Equal(Credential{Id: "configured-id", Secret: "first-secret"}, credential)
+
+	// the credential is cached until it's due to be fetched again
+	vaultSecret_.Store("second-secret")
+	credential, err = Get("rotated")
+	assert.Nil(err)
+	assert.Equal("first-secret", credential.Secret)
+	assert.Equal(numReads+1, numVaultReads_.Load())
+
+	Reset()
+	credential, err = Get("rotated")
+	assert.Nil(err)
+	assert.Equal("second-secret", credential.Secret)
+
+	_, err = Get("missing")
+	assert.IsType(&VaultError{}, err)
+}
+
+func TestGetFileCredential(t *testing.T) {
+	assert := assert.New(t)
+	Reset()
+
+	err := os.WriteFile(secretFile_, []byte("first-token\n"), 0600)
+	assert.Nil(err)
+	credential, err := Get("renewed")
+	assert.Nil(err)
+	assert.Equal(Credential{Id: "file-id", Secret: "first-token"}, credential)
+
+	// a renewed secret is picked up once the credential is invalidated
+	err = os.WriteFile(secretFile_, []byte("second-token\n"), 0600)
+	assert.Nil(err)
+	credential, err = Get("renewed")
+	assert.Nil(err)
+	assert.Equal("first-token", credential.Secret)
+	Invalidate("renewed")
+	credential, err = Get("renewed")
+	assert.Nil(err)
+	assert.Equal("second-token", credential.Secret)
+
+	// a secret that can't be fetched again is replaced by the previous one
+	os.Remove(secretFile_)
+	mutex_.Lock()
+	cached := cache_["renewed"]
+	cached.RefreshTime = time.Now().Add(-time.Second)
+	cache_["renewed"] = cached
+	mutex_.Unlock()
+	credential, err = Get("renewed")
+	assert.Nil(err)
+	assert.Equal("second-token", credential.Secret)
+
+	// a missing file is reported
+	Invalidate("renewed")
+	_, err = Get("renewed")
+	assert.IsType(&FileError{}, err)
+}
+
+// serves secrets like the KV (version 2) secrets engine of a Vault server
+func serveVault(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+	if r.URL.Path != "/v1/secret/data/dts/globus" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+		return
+	}
+	numVaultReads_.Add(1)
+	json.NewEncoder(w).Encode(map[string]any{
+		"lease_duration": 0,
+		"data": map[string]any{
+			"data": map[string]any{
+				"secret": vaultSecret_.Load(),
+			},
+		},
+	})
+}
+
+func TestMain(m *testing.M) {
+	vault := httptest.NewServer(http.HandlerFunc(serveVault))
+	dir, err := os.MkdirTemp(os.TempDir(), "credentials-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create temporary directory: %s", err)
+	}
+	secretFile_ = filepath.Join(dir, "secret")
+	myConfig := strings.ReplaceAll(credentialsConfig, "VAULT_ADDRESS", vault.URL)
+	myConfig = strings.ReplaceAll(myConfig, "SECRET_FILE", secretFile_)
+	err = config.InitSelected([]byte(myConfig), true, true, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	status := m.Run()
+	vault.Close()
+	os.RemoveAll(dir)
+	os.Exit(status)
+}
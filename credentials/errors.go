@@ -0,0 +1,74 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credentials
+
+import (
+	"fmt"
+)
+
+// indicates that a credential isn't configured
+type NotFoundError struct {
+	Name string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("The credential '%s' was not found in the DTS configuration.", e.Name)
+}
+
+// indicates that a credential is configured with an invalid provider
+type InvalidProviderError struct {
+	Name, Provider string
+}
+
+func (e InvalidProviderError) Error() string {
+	return fmt.Sprintf("The credential '%s' has an invalid provider: %s", e.Name, e.Provider)
+}
+
+// indicates that a credential couldn't be fetched from Vault
+type VaultError struct {
+	Credential string
+	Status     int
+	Message    string
+}
+
+func (e VaultError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Couldn't fetch credential '%s' from Vault (%d): %s", e.Credential,
+			e.Status, e.Message)
+	}
+	return fmt.Sprintf("Couldn't fetch credential '%s' from Vault (%d)", e.Credential, e.Status)
+}
+
+// indicates that a credential couldn't be read from its file
+type FileError struct {
+	Credential string
+	Path       string
+	Err        error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("Couldn't read credential '%s' from %s: %s", e.Credential, e.Path, e.Err)
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credit
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matches IETF BCP 47 language tags (language, then optional script, region,
+// and variant subtags, e.g. "en", "pt-BR", "zh-Hant-TW"), ignoring case
+var languageTagPattern = regexp.MustCompile(`^(?i)[a-z]{2,3}(-[a-z]{4})?(-([a-z]{2}|[0-9]{3}))?(-([a-z0-9]{5,8}|[0-9][a-z0-9]{3}))*$`)
+
+// Returns the given IETF BCP 47 language tag in its canonical form (a
+// lower-case language, a title-case script, and an upper-case region, e.g.
+// "zh-Hant-TW"), accepting underscores between subtags, and true, or "" and
+// false if the tag isn't well formed.
+func NormalizeLanguageTag(tag string) (string, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if !languageTagPattern.MatchString(tag) {
+		return "", false
+	}
+	subtags := strings.Split(strings.ToLower(tag), "-")
+	for i, subtag := range subtags {
+		if i == 0 {
+			continue
+		}
+		switch {
+		case len(subtag) == 4 && i == 1 && subtag[0] >= 'a': // script
+			subtags[i] = strings.ToUpper(subtag[:1]) + subtag[1:]
+		case len(subtag) == 2: // region
+			subtags[i] = strings.ToUpper(subtag)
+		}
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+// Normalizes the language tags of the titles and descriptions in the given
+// credit metadata, clearing any that aren't well formed.
+func (metadata *CreditMetadata) NormalizeLanguages() {
+	for i := range metadata.Titles {
+		metadata.Titles[i].Language, _ = NormalizeLanguageTag(metadata.Titles[i].Language)
+	}
+	for i := range metadata.Descriptions {
+		metadata.Descriptions[i].Language, _ = NormalizeLanguageTag(metadata.Descriptions[i].Language)
+	}
+}
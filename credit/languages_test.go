@@ -0,0 +1,71 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLanguageTag(t *testing.T) {
+	assert := assert.New(t)
+
+	for tag, normalized := range map[string]string{
+		"en":         "en",
+		"EN":         "en",
+		"pt-br":      "pt-BR",
+		"pt_BR":      "pt-BR",
+		"zh-hant-tw": "zh-Hant-TW",
+		"es-419":     "es-419",
+		"de-CH-1996": "de-CH-1996",
+		" fr ":       "fr",
+	} {
+		result, ok := NormalizeLanguageTag(tag)
+		assert.True(ok, tag)
+		assert.Equal(normalized, result)
+	}
+
+	for _, tag := range []string{"", "english", "e", "en-", "en--US", "en US"} {
+		result, ok := NormalizeLanguageTag(tag)
+		assert.False(ok, tag)
+		assert.Empty(result)
+	}
+}
+
+func TestNormalizeLanguages(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := CreditMetadata{
+		Titles: []Title{{Title: "Soil cores", Language: "EN"}},
+		Descriptions: []Description{
+			{DescriptionText: "Soil cores from the field", Language: "en_us"},
+			{DescriptionText: "Núcleos de solo do campo", Language: "pt-br"},
+			{DescriptionText: "???", Language: "klingon!"},
+		},
+	}
+	metadata.NormalizeLanguages()
+	assert.Equal("en", metadata.Titles[0].Language)
+	assert.Equal("en-US", metadata.Descriptions[0].Language)
+	assert.Equal("pt-BR", metadata.Descriptions[1].Language)
+	assert.Equal("", metadata.Descriptions[2].Language)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credit
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// A standardized contributor role: its name in the CRediT taxonomy (or, for
+// roles CRediT doesn't cover, in DataCite's contributorType vocabulary) and the
+// DataCite contributorType that best corresponds to it.
+type Role struct {
+	Name            string
+	ContributorType string
+}
+
+// standardized roles, keyed by their names and common variants, folded by
+// foldRole
+var roles = map[string]Role{}
+
+// DataCite contributorType for roles with no better correspondence
+const otherContributorType = "Other"
+
+func init() {
+	// CRediT roles (https://credit.niso.org)
+	for _, entry := range []struct {
+		Role     Role
+		Variants []string
+	}{
+		{Role{"Conceptualization", "Researcher"}, nil},
+		{Role{"Data curation", "DataCurator"}, nil},
+		{Role{"Formal analysis", "Researcher"}, []string{"analysis"}},
+		{Role{"Funding acquisition", "Sponsor"}, []string{"funding"}},
+		{Role{"Investigation", "Researcher"}, nil},
+		{Role{"Methodology", "Researcher"}, nil},
+		{Role{"Project administration", "ProjectManager"}, nil},
+		{Role{"Resources", otherContributorType}, nil},
+		{Role{"Software", otherContributorType}, nil},
+		{Role{"Supervision", "Supervisor"}, nil},
+		{Role{"Validation", "Researcher"}, nil},
+		{Role{"Visualization", "Researcher"}, []string{"visualisation"}},
+		{Role{"Writing – original draft", otherContributorType},
+			[]string{"writing original draft", "original draft"}},
+		{Role{"Writing – review & editing", "Editor"},
+			[]string{"writing review and editing", "review and editing"}},
+	} {
+		addRole(entry.Role, entry.Variants...)
+	}
+
+	// DataCite contributor types (https://support.datacite.org/docs/datacite-metadata-schema-v44-recommended-and-optional-properties#7a-contributortype)
+	for _, contributorType := range []string{
+		"ContactPerson", "DataCollector", "DataCurator", "DataManager",
+		"Distributor", "Editor", "HostingInstitution", "Producer",
+		"ProjectLeader", "ProjectManager", "ProjectMember",
+		"RegistrationAgency", "RegistrationAuthority", "RelatedPerson",
+		"Researcher", "ResearchGroup", "RightsHolder", "Sponsor", "Supervisor",
+		"WorkPackageLeader", "Other",
+	} {
+		addRole(Role{contributorType, contributorType})
+	}
+
+	// other roles used by DTS databases
+	addRole(roles[foldRole("ProjectLeader")], "Principal Investigator", "PI")
+	addRole(roles[foldRole("ContactPerson")], "Submitter")
+}
+
+// adds the given role to the table under its name and the given variants
+func addRole(role Role, variants ...string) {
+	roles[foldRole(role.Name)] = role
+	for _, variant := range variants {
+		roles[foldRole(variant)] = role
+	}
+}
+
+// folds the given role name for lookup, ignoring case, whitespace, and
+// punctuation ("Data Curation", "data_curation" -> "datacuration")
+func foldRole(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// Returns the standardized role for the given free-text role name and true, or
+// a Role with the given (trimmed) name and the "Other" DataCite contributor
+// type and false if the name isn't recognized.
+func LookupRole(name string) (Role, bool) {
+	if role, found := roles[foldRole(name)]; found {
+		return role, true
+	}
+	return Role{
+		Name:            strings.TrimSpace(name),
+		ContributorType: otherContributorType,
+	}, false
+}
+
+// Returns the standardized name for the given free-text role name, or the
+// (trimmed) name itself if it isn't recognized.
+func NormalizeRole(name string) string {
+	role, _ := LookupRole(name)
+	return role.Name
+}
+
+// Standardizes the given free-text role names, dropping empty names and
+// duplicates, and joins them into a comma-separated list suitable for a
+// Contributor's ContributorRoles field.
+func NormalizeRoles(names []string) string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		if role := NormalizeRole(name); role != "" && !slices.Contains(normalized, role) {
+			normalized = append(normalized, role)
+		}
+	}
+	return strings.Join(normalized, ",")
+}
+
+// Returns the standardized roles listed in the given contributor's
+// ContributorRoles field.
+func (contributor Contributor) Roles() []Role {
+	var contributorRoles []Role
+	for _, name := range strings.Split(contributor.ContributorRoles, ",") {
+		if strings.TrimSpace(name) != "" {
+			role, _ := LookupRole(name)
+			contributorRoles = append(contributorRoles, role)
+		}
+	}
+	return contributorRoles
+}
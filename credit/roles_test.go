@@ -0,0 +1,67 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package credit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupRole(t *testing.T) {
+	assert := assert.New(t)
+
+	role, found := LookupRole("data curation")
+	assert.True(found)
+	assert.Equal(Role{"Data curation", "DataCurator"}, role)
+
+	role, found = LookupRole("Writing review and editing")
+	assert.True(found)
+	assert.Equal("Writing – review & editing", role.Name)
+	assert.Equal("Editor", role.ContributorType)
+
+	role, found = LookupRole("PI")
+	assert.True(found)
+	assert.Equal(Role{"ProjectLeader", "ProjectLeader"}, role)
+
+	// DataCite contributor types are their own standard names
+	role, found = LookupRole("HostingInstitution")
+	assert.True(found)
+	assert.Equal(Role{"HostingInstitution", "HostingInstitution"}, role)
+
+	// unknown roles pass through
+	role, found = LookupRole(" Sample wrangling ")
+	assert.False(found)
+	assert.Equal(Role{"Sample wrangling", "Other"}, role)
+}
+
+func TestNormalizeRoles(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", NormalizeRoles(nil))
+	assert.Equal("Conceptualization,Data curation,ProjectLeader,Sample wrangling",
+		NormalizeRoles([]string{"Conceptualization", "Data Curation",
+			"Principal Investigator", "", "data_curation", "Sample wrangling"}))
+
+	contributor := Contributor{ContributorRoles: "Data curation, Submitter"}
+	assert.Equal([]Role{{"Data curation", "DataCurator"}, {"ContactPerson", "ContactPerson"}},
+		contributor.Roles())
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package contains a conformance suite for implementations of the
+// databases.Database interface. Any database connector (built-in or external)
+// can run it from its own tests to verify that it behaves as the DTS expects:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, mydb.NewDatabase, conformance.Parameters{
+//			Orcid: orcid,
+//			Query: "soil",
+//		})
+//	}
+package conformance
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/databases"
+)
+
+// parameters for running the conformance suite against a database
+type Parameters struct {
+	// ORCID of the user on whose behalf the database is accessed
+	Orcid string
+	// a search query (and database-specific search parameters) yielding at
+	// least 2*PageSize results
+	Query    string
+	Specific map[string]any
+	// number of search results per page in pagination checks (default: 5)
+	PageSize int
+	// IDs of (at least 2) files visible to the user (default: the IDs of the
+	// first results of the search query)
+	FileIds []string
+	// if set, StageFiles is not called (e.g. for databases whose staging
+	// operations are expensive)
+	SkipStaging bool
+}
+
+// Runs the conformance suite against database instances created by the given
+// function, which must return a new instance each time it's called. Each check
+// is run as a subtest.
+func Run(t *testing.T, newDatabase func() (databases.Database, error), params Parameters) {
+	if params.PageSize <= 0 {
+		params.PageSize = 5
+	}
+	db, err := newDatabase()
+	if !assert.Nil(t, err, "couldn't create database") {
+		return
+	}
+	if len(params.FileIds) == 0 {
+		params.FileIds = searchIds(t, db, params, 0, 2)
+		if len(params.FileIds) < 2 {
+			t.Fatalf("search query '%s' didn't yield at least 2 files", params.Query)
+		}
+	}
+
+	t.Run("SearchPagination", func(t *testing.T) {
+		checkSearchPagination(t, db, params)
+	})
+	t.Run("DescriptorsOrdering", func(t *testing.T) {
+		checkDescriptorsOrdering(t, db, params)
+	})
+	if !params.SkipStaging {
+		t.Run("StageFilesIdempotency", func(t *testing.T) {
+			checkStageFilesIdempotency(t, db, params)
+		})
+	}
+	t.Run("SaveLoadRoundTrip", func(t *testing.T) {
+		checkSaveLoadRoundTrip(t, db, newDatabase, params)
+	})
+}
+
+//-----------
+// Internals
+//-----------
+
+// returns the IDs of the (file) search results in the page with the given
+// offset and size
+func searchIds(t *testing.T, db databases.Database, params Parameters, offset, maxNum int) []string {
+	results, err := db.Search(params.Orcid, databases.SearchParameters{
+		Query:    params.Query,
+		Specific: params.Specific,
+		Pagination: databases.SearchPaginationParameters{
+			Offset: offset,
+			MaxNum: maxNum,
+		},
+	})
+	assert.Nil(t, err, "search failed")
+	ids := make([]string, 0, len(results.Descriptors))
+	for _, descriptor := range results.Descriptors {
+		if id, ok := descriptor["id"].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// consecutive pages of search results must respect the page size, must not
+// overlap, and must match the corresponding range of a single larger page
+func checkSearchPagination(t *testing.T, db databases.Database, params Parameters) {
+	assert := assert.New(t)
+	pageSize := params.PageSize
+	firstPage := searchIds(t, db, params, 0, pageSize)
+	secondPage := searchIds(t, db, params, pageSize, pageSize)
+	bothPages := searchIds(t, db, params, 0, 2*pageSize)
+
+	assert.Len(firstPage, pageSize, "first page has the wrong number of results")
+	assert.LessOrEqual(len(secondPage), pageSize, "second page exceeds the page size")
+	for _, id := range secondPage {
+		assert.NotContains(firstPage, id, "result %s appears on two pages", id)
+	}
+	assert.Equal(bothPages, append(slices.Clone(firstPage), secondPage...),
+		"consecutive pages don't match a single larger page")
+
+	// repeating a search yields the same results
+	assert.Equal(firstPage, searchIds(t, db, params, 0, pageSize), "search results are unstable")
+}
+
+// file descriptors must be returned in the order in which their IDs are
+// requested (data descriptors may be interspersed)
+func checkDescriptorsOrdering(t *testing.T, db databases.Database, params Parameters) {
+	assert := assert.New(t)
+	for _, ids := range [][]string{params.FileIds, reversed(params.FileIds)} {
+		descriptors, err := db.Descriptors(params.Orcid, ids)
+		if !assert.Nil(err, "Descriptors failed") {
+			return
+		}
+		fileIds := make([]string, 0, len(ids))
+		for _, descriptor := range descriptors {
+			if _, isFile := descriptor["path"]; isFile {
+				id, _ := descriptor["id"].(string)
+				fileIds = append(fileIds, id)
+			}
+		}
+		assert.Equal(ids, fileIds, "file descriptors aren't returned in the requested order")
+	}
+}
+
+// staging the same files twice must succeed both times, producing distinct
+// staging operations whose statuses are known and not failed
+func checkStageFilesIdempotency(t *testing.T, db databases.Database, params Parameters) {
+	assert := assert.New(t)
+	first, err := db.StageFiles(params.Orcid, params.FileIds)
+	assert.Nil(err, "first staging request failed")
+	second, err := db.StageFiles(params.Orcid, params.FileIds)
+	assert.Nil(err, "repeated staging request failed")
+	assert.NotEqual(uuid.Nil, first)
+	assert.NotEqual(first, second, "staging requests share an ID")
+	for _, id := range []uuid.UUID{first, second} {
+		status, err := db.StagingStatus(id)
+		assert.Nil(err, "couldn't get staging status")
+		assert.NotEqual(databases.StagingStatusUnknown, status, "staging status unknown")
+		assert.NotEqual(databases.StagingStatusFailed, status, "staging failed")
+	}
+}
+
+// a database loaded from another's saved state must report the same staging
+// statuses
+func checkSaveLoadRoundTrip(t *testing.T, db databases.Database,
+	newDatabase func() (databases.Database, error), params Parameters) {
+	assert := assert.New(t)
+	var stagingId uuid.UUID
+	if !params.SkipStaging {
+		var err error
+		stagingId, err = db.StageFiles(params.Orcid, params.FileIds)
+		assert.Nil(err, "staging request failed")
+	}
+	state, err := db.Save()
+	if !assert.Nil(err, "Save failed") {
+		return
+	}
+
+	restored, err := newDatabase()
+	if !assert.Nil(err, "couldn't create database") {
+		return
+	}
+	err = restored.Load(state)
+	if !assert.Nil(err, "Load failed") {
+		return
+	}
+	if !params.SkipStaging {
+		status, err := db.StagingStatus(stagingId)
+		assert.Nil(err)
+		restoredStatus, err := restored.StagingStatus(stagingId)
+		assert.Nil(err)
+		assert.Equal(status, restoredStatus, "staging status not preserved by Save/Load")
+	}
+
+	// saving the restored database reproduces the state
+	restoredState, err := restored.Save()
+	assert.Nil(err, "Save failed after Load")
+	assert.Equal(state.Name, restoredState.Name, "save state name not preserved")
+}
+
+func reversed(ids []string) []string {
+	r := slices.Clone(ids)
+	slices.Reverse(r)
+	return r
+}
@@ -24,6 +24,8 @@ package databases
 import (
 	"encoding/gob"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -69,6 +71,89 @@ type Database interface {
 	Load(state DatabaseSaveState) error
 }
 
+// TapeRecallEstimator is implemented by databases whose files can reside on
+// tape, allowing the cost of recalling them to be estimated before staging
+type TapeRecallEstimator interface {
+	Database
+	// returns an estimate of the tape recalls required to stage the files with
+	// the given IDs visible to the user with the given ORCID
+	EstimateTapeRecall(orcid string, fileIds []string) (TapeRecallEstimate, error)
+}
+
+// QueueingDatabase is implemented by databases that queue staging requests
+// (e.g. to respect a limit on concurrent requests at the source), allowing the
+// position of a queued request to be reported
+type QueueingDatabase interface {
+	Database
+	// returns the (1-based) position of the staging request with the given ID
+	// in the database's queue, or 0 if the request isn't queued
+	StagingQueuePosition(id uuid.UUID) int
+}
+
+// DatasetExpander is implemented by databases that group files into datasets
+// (e.g. projects), allowing a dataset ID to stand in for the IDs of all of its
+// files in a transfer request
+type DatasetExpander interface {
+	Database
+	// replaces any dataset IDs among the given file IDs with the IDs of the
+	// files they contain (visible to the user with the given ORCID), returning
+	// the expanded file IDs and a mapping of each expanded dataset ID to the
+	// IDs of its files. A database may narrow the files selected using the
+	// given transfer instructions.
+	ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error)
+}
+
+// EmbargoingDatabase is implemented by databases whose descriptors can carry
+// "embargo_until" fields giving the times at which embargoes on their files
+// lift, allowing transfers of embargoed files to be refused when requested
+type EmbargoingDatabase interface {
+	Database
+	// returns true if the database's descriptors report embargoes
+	ReportsEmbargoes() bool
+}
+
+// PathDecoder is implemented by databases whose descriptors hold encoded file
+// paths (e.g. to keep them from being mistaken for URLs in manifests), allowing
+// them to be translated to paths on the database's endpoints at transfer time
+type PathDecoder interface {
+	Database
+	// returns the path (relative to its endpoint's root) of the file with the
+	// given (encoded) descriptor path
+	DecodePath(path string) (string, error)
+}
+
+// EndpointRelocator is implemented by databases with several endpoints on
+// which their files are laid out differently (or which hold different files),
+// allowing a file to be transferred from an endpoint other than the one given
+// in its descriptor. The files of other databases have the same paths on all
+// of their endpoints.
+type EndpointRelocator interface {
+	Database
+	// returns the descriptor path at the second of the given endpoints of the
+	// file with the given descriptor path at the first, or an error if the file
+	// isn't available at the second
+	RelocatePath(path, fromEndpoint, toEndpoint string) (string, error)
+}
+
+// returns the path (relative to its endpoint's root) of the file with the
+// given descriptor path in the given database, decoding it if needed
+func EndpointPath(db Database, path string) (string, error) {
+	if decoder, ok := db.(PathDecoder); ok {
+		return decoder.DecodePath(path)
+	}
+	return path, nil
+}
+
+// an estimate of the tape recalls needed to stage a set of files
+type TapeRecallEstimate struct {
+	// number of files that must be recalled from tape
+	NumFiles int
+	// total size of the files that must be recalled from tape (bytes)
+	Bytes int64
+	// expected time needed to recall the files
+	Duration time.Duration
+}
+
 // represents a saved database state (for service restarts)
 type DatabaseSaveState struct {
 	// database name
@@ -91,6 +176,8 @@ type SearchParameters struct {
 	Status SearchFileStatus
 	// pagination support
 	Pagination SearchPaginationParameters
+	// sort order, if requested (see SortedSearch)
+	Sort SearchSortParameters
 	// database-specific search parameters with names matched to provided values
 	// (validated by database)
 	Specific map[string]any
@@ -109,6 +196,14 @@ type SearchPaginationParameters struct {
 	MaxNum int
 }
 
+type SearchSortParameters struct {
+	// field by which results are sorted (one of SearchSortFields), or "" for
+	// the database's native order
+	Field string
+	// set to sort in descending order
+	Descending bool
+}
+
 // allows searching for files that are staged, not yet staged, etc
 type SearchFileStatus int
 
@@ -166,6 +261,9 @@ func HaveDatabase(dbName string) bool {
 func NewDatabase(dbName string) (Database, error) {
 	var err error
 
+	allDatabasesMutex_.Lock()
+	defer allDatabasesMutex_.Unlock()
+
 	// do we have one of these already?
 	db, found := allDatabases_[dbName]
 	if !found {
@@ -188,6 +286,8 @@ func Save() (DatabaseSaveStates, error) {
 	states := DatabaseSaveStates{
 		Data: make(map[string]DatabaseSaveState),
 	}
+	allDatabasesMutex_.Lock()
+	defer allDatabasesMutex_.Unlock()
 	for key, db := range allDatabases_ {
 		saveState, err := db.Save()
 		if err != nil {
@@ -224,8 +324,10 @@ func Load(states DatabaseSaveStates) error {
 // set to false after the first database is registered
 var firstTime = true
 
-// we maintain a table of database instances, identified by their names
+// we maintain a table of database instances, identified by their names, and a
+// mutex that guards it
 var allDatabases_ = make(map[string]Database)
+var allDatabasesMutex_ sync.Mutex
 
 // a table of database creation functions
 var createDatabaseFuncs_ = make(map[string]func() (Database, error))
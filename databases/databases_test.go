@@ -1,14 +1,218 @@
 package databases
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/kbase/dts/frictionless"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestSortDescriptors(t *testing.T) {
+	assert := assert.New(t)
+	descriptors := []map[string]any{
+		{"id": "1", "name": "beta", "bytes": 300, "format": "fasta"},
+		{"id": "2", "name": "alpha", "bytes": 100},
+		{"id": "3", "bytes": 200, "format": "csv"},
+	}
+	ids := func() []string {
+		result := make([]string, len(descriptors))
+		for i, descriptor := range descriptors {
+			result[i] = descriptor["id"].(string)
+		}
+		return result
+	}
+
+	SortDescriptors(descriptors, SearchSortParameters{Field: "name"})
+	assert.Equal([]string{"2", "1", "3"}, ids())
+	SortDescriptors(descriptors, SearchSortParameters{Field: "name", Descending: true})
+	assert.Equal([]string{"1", "2", "3"}, ids()) // missing names still sort last
+	SortDescriptors(descriptors, SearchSortParameters{Field: "bytes"})
+	assert.Equal([]string{"2", "3", "1"}, ids())
+	SortDescriptors(descriptors, SearchSortParameters{Field: "format"})
+	assert.Equal([]string{"3", "1", "2"}, ids())
+}
+
+func TestDiffDescriptors(t *testing.T) {
+	assert := assert.New(t)
+	previous := []map[string]any{
+		{"id": "1", "hash": "abc", "bytes": 100.0, "path": "a/1.dat"}, // decoded from JSON
+		{"id": "2", "hash": "def", "bytes": 200.0, "path": "a/2.dat"},
+		{"id": "3", "hash": "ghi", "bytes": 300.0, "path": "a/3.dat"},
+	}
+	current := []map[string]any{
+		{"id": "2", "hash": "xyz", "bytes": 250, "path": "a/2.dat"},
+		{"id": "1", "hash": "abc", "bytes": 100, "path": "a/1.dat"},
+	}
+	diffs := DiffDescriptors(previous, current)
+	assert.Equal([]DescriptorDiff{
+		{Id: "1", Status: "unchanged"},
+		{
+			Id:     "2",
+			Status: "changed",
+			Changes: []DescriptorChange{
+				{Field: "hash", Previous: "def", Current: "xyz"},
+				{Field: "bytes", Previous: 200.0, Current: 250},
+			},
+		},
+		{Id: "3", Status: "missing"},
+	}, diffs)
+}
+
+func TestDescriptorFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	descriptor := map[string]any{"id": "1", "hash": "abc", "bytes": 100, "path": "a/1.dat"}
+	decoded := map[string]any{"id": "1", "hash": "abc", "bytes": 100.0, "path": "a/1.dat", "name": "1"}
+	replaced := map[string]any{"id": "1", "hash": "def", "bytes": 100, "path": "a/1.dat"}
+	assert.Equal(DescriptorFingerprint(descriptor), DescriptorFingerprint(decoded))
+	assert.NotEqual(DescriptorFingerprint(descriptor), DescriptorFingerprint(replaced))
+}
+
 func TestInvalidDatabase(t *testing.T) {
 	assert := assert.New(t)
 	bbDb, err := NewDatabase("booga booga")
 	assert.Nil(bbDb, "Invalid database should not be created")
 	assert.NotNil(err, "Invalid database creation did not report an error")
 }
+
+func TestParseQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	query, err := ParseQuery(`prochlorococcus`)
+	assert.Nil(err)
+	assert.Equal(QueryNode{Value: "prochlorococcus"}, query)
+
+	query, err = ParseQuery(`ecosystem:"soil core" AND type:metagenome`)
+	assert.Nil(err)
+	assert.Equal(QueryNode{
+		Operator: QueryAnd,
+		Operands: []QueryNode{
+			{Field: "ecosystem", Value: "soil core", Phrase: true},
+			{Field: "type", Value: "metagenome"},
+		},
+	}, query)
+
+	// AND binds more tightly than OR, and adjacent terms are implicitly ANDed
+	query, err = ParseQuery(`a b OR c`)
+	assert.Nil(err)
+	assert.Equal(QueryNode{
+		Operator: QueryOr,
+		Operands: []QueryNode{
+			{Operator: QueryAnd, Operands: []QueryNode{{Value: "a"}, {Value: "b"}}},
+			{Value: "c"},
+		},
+	}, query)
+
+	query, err = ParseQuery(`a AND (b OR c)`)
+	assert.Nil(err)
+	assert.Equal(QueryNode{
+		Operator: QueryAnd,
+		Operands: []QueryNode{
+			{Value: "a"},
+			{Operator: QueryOr, Operands: []QueryNode{{Value: "b"}, {Value: "c"}}},
+		},
+	}, query)
+
+	for _, badQuery := range []string{
+		``,
+		`"unterminated`,
+		`:value`,
+		`field:`,
+		`field:(a OR b)`,
+		`a OR`,
+		`AND a`,
+		`(a OR b`,
+		`a)`,
+	} {
+		_, err = ParseQuery(badQuery)
+		assert.IsType(&InvalidSearchParameter{}, err, badQuery)
+	}
+}
+
+func TestRequestTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	var trace RequestTrace
+	client := http.Client{Transport: trace.Transport(nil)}
+	resp, err := client.Get(server.URL + "/search?q=soil&api_key=abc123")
+	assert.Nil(err)
+	resp.Body.Close()
+	resp, err = client.PostForm(server.URL+"/token", url.Values{
+		"username": {"joe"},
+		"password": {"hunter2"},
+	})
+	assert.Nil(err)
+	resp.Body.Close()
+
+	requests := trace.Requests()
+	assert.Len(requests, 2)
+	assert.Equal(http.MethodGet, requests[0].Method)
+	assert.Equal(server.URL+"/search?api_key=REDACTED&q=soil", requests[0].URL)
+	assert.Equal(http.StatusOK, requests[0].Status)
+	assert.GreaterOrEqual(requests[0].Latency, 0.0)
+	assert.Equal(http.MethodPost, requests[1].Method)
+	assert.Equal("password=REDACTED&username=joe", requests[1].Body)
+	assert.False(strings.Contains(requests[1].Body, "hunter2"))
+	assert.Equal(http.StatusUnauthorized, requests[1].Status)
+
+	_, _, err = ExplainedSearch("nonexistent", "1234-5678-9012-3456", SearchParameters{})
+	assert.IsType(&NotFoundError{}, err)
+}
+
+// a database that resolves GOLD project IDs to its own project IDs
+type xrefDatabase struct {
+	Database // (other methods aren't called)
+}
+
+func (db xrefDatabase) ResolveCrossReferences(orcid string, xrefs []string) (map[string][]string, error) {
+	resolved := make(map[string][]string)
+	for _, xref := range xrefs {
+		if strings.HasPrefix(xref, "gold:Gp") {
+			resolved[xref] = []string{"XREF-PROJECT:" + strings.TrimPrefix(xref, "gold:")}
+		}
+	}
+	return resolved, nil
+}
+
+// tests the linking of descriptors to related resources in other databases
+func TestLinkRelatedIds(t *testing.T) {
+	assert := assert.New(t)
+
+	err := RegisterDatabase("xrefs", func() (Database, error) { return xrefDatabase{}, nil })
+	assert.Nil(err)
+
+	descriptors := []map[string]any{
+		{"id": "file1", "xrefs": []any{"gold:Gp0115663", "emsl:0a5b9e2c"}},
+		{"id": "file2", "xrefs": []any{"gold:Gp0115663", "gold:Gp0115664"}},
+		{"id": "file3"},
+	}
+	LinkRelatedIds("source", "1234-5678-9012-3456", descriptors)
+	assert.Equal(map[string][]string{"xrefs": {"XREF-PROJECT:Gp0115663"}},
+		frictionless.RelatedIds(descriptors[0]))
+	assert.Equal(map[string][]string{"xrefs": {"XREF-PROJECT:Gp0115663", "XREF-PROJECT:Gp0115664"}},
+		frictionless.RelatedIds(descriptors[1]))
+	assert.NotContains(descriptors[2], "related_ids")
+
+	// a database doesn't link descriptors to itself
+	descriptors = []map[string]any{{"id": "file1", "xrefs": []any{"gold:Gp0115663"}}}
+	LinkRelatedIds("xrefs", "1234-5678-9012-3456", descriptors)
+	assert.NotContains(descriptors[0], "related_ids")
+
+	// only a limited number of cross-references are resolved
+	defer func(max int) { maxCrossReferences = max }(maxCrossReferences)
+	maxCrossReferences = 1
+	descriptors = []map[string]any{{"id": "file1", "xrefs": []any{"gold:Gp0115663", "gold:Gp0115664"}}}
+	LinkRelatedIds("source", "1234-5678-9012-3456", descriptors)
+	assert.Equal(map[string][]string{"xrefs": {"XREF-PROJECT:Gp0115663"}},
+		frictionless.RelatedIds(descriptors[0]))
+}
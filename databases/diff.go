@@ -0,0 +1,131 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// descriptor fields compared by DiffDescriptors
+var DiffFields = []string{"hash", "bytes", "path", "url"}
+
+// a change to a field in a descriptor between two metadata fetches
+type DescriptorChange struct {
+	// name of the changed field
+	Field string `json:"field"`
+	// values of the field in the previous and current descriptors (nil if absent)
+	Previous any `json:"previous"`
+	Current  any `json:"current"`
+}
+
+// the result of comparing two descriptors for the same resource
+type DescriptorDiff struct {
+	// resource ID
+	Id string `json:"id"`
+	// "unchanged", "changed", or "missing" (not found in the current fetch)
+	Status string `json:"status"`
+	// changes to compared fields (if any)
+	Changes []DescriptorChange `json:"changes,omitempty"`
+}
+
+// Compares previously fetched descriptors with current descriptors for the
+// same resources (matched by ID), reporting changes to the fields in
+// DiffFields, so that upstream updates can be detected. Results are returned
+// in the order of the previous descriptors.
+func DiffDescriptors(previous, current []map[string]any) []DescriptorDiff {
+	currentById := make(map[string]map[string]any)
+	for _, descriptor := range current {
+		if id, ok := descriptor["id"].(string); ok {
+			currentById[id] = descriptor
+		}
+	}
+
+	diffs := make([]DescriptorDiff, 0, len(previous))
+	for _, prev := range previous {
+		id, _ := prev["id"].(string)
+		curr, found := currentById[id]
+		if !found {
+			diffs = append(diffs, DescriptorDiff{Id: id, Status: "missing"})
+			continue
+		}
+		diff := DescriptorDiff{Id: id, Status: "unchanged"}
+		for _, field := range DiffFields {
+			if !sameValue(prev[field], curr[field]) {
+				diff.Changes = append(diff.Changes, DescriptorChange{
+					Field:    field,
+					Previous: prev[field],
+					Current:  curr[field],
+				})
+			}
+		}
+		if len(diff.Changes) > 0 {
+			diff.Status = "changed"
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// Computes a fingerprint for the given descriptor from the values of the
+// fields in DiffFields. Descriptors for the same resource have identical
+// fingerprints unless one of these fields has changed.
+func DescriptorFingerprint(descriptor map[string]any) string {
+	hasher := sha256.New()
+	for _, field := range DiffFields {
+		value := descriptor[field]
+		if number, ok := asFloat(value); ok {
+			fmt.Fprintf(hasher, "%s=%s;", field, strconv.FormatFloat(number, 'g', -1, 64))
+		} else {
+			fmt.Fprintf(hasher, "%s=%v;", field, value)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// returns true if the given descriptor field values are equal, treating
+// numbers of different types (e.g. decoded from JSON) as equal if their values
+// are
+func sameValue(a, b any) bool {
+	aNumber, aIsNumber := asFloat(a)
+	bNumber, bIsNumber := asFloat(b)
+	if aIsNumber && bIsNumber {
+		return aNumber == bNumber
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func asFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
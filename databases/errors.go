@@ -77,6 +77,16 @@ func (e InvalidSearchParameter) Error() string {
 	return fmt.Sprintf("Invalid search parameter for database '%s': %s", e.Database, e.Message)
 }
 
+// This error type is returned when transfer instructions given to a database
+// are invalid
+type InvalidInstructionsError struct {
+	Database, Message string
+}
+
+func (e InvalidInstructionsError) Error() string {
+	return fmt.Sprintf("Invalid transfer instructions for database '%s': %s", e.Database, e.Message)
+}
+
 // this error type is returned when a database's endpoint configuration is invalid
 type InvalidEndpointsError struct {
 	Database, Message string
@@ -128,6 +138,17 @@ func (e ResourcesNotFoundError) Error() string {
 	return fmt.Sprintf("The following resources in database '%s' were not found: %s", e.Database, strings.Join(e.ResourceIds, ","))
 }
 
+// this error type is returned when a resource is requested from an endpoint
+// other than its own that doesn't hold a copy of it
+type ResourceNotMirroredError struct {
+	Database, Path, Endpoint string
+}
+
+func (e ResourceNotMirroredError) Error() string {
+	return fmt.Sprintf("The resource with path '%s' in database '%s' is not available at endpoint '%s'",
+		e.Path, e.Database, e.Endpoint)
+}
+
 // this error type is returned when an endpoint cannot be found for a file ID
 type ResourceEndpointNotFoundError struct {
 	Database, ResourceId string
@@ -0,0 +1,654 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package essdive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
+)
+
+// file database appropriate for handling searches and transfers
+// (implements the databases.Database interface)
+type Database struct {
+	// HTTP client that caches queries
+	Client http.Client
+	// root URL of the HTTPS endpoint serving ESS-DIVE files, relative to which
+	// file paths are given
+	DataURL string
+}
+
+func NewDatabase() (databases.Database, error) {
+	// ESS-DIVE files are downloaded over HTTPS, relative to the endpoint's root
+	endpointName := config.Databases["essdive"].Endpoint
+	epConfig, found := config.Endpoints[endpointName]
+	if !found || epConfig.Provider != "https" || epConfig.Root == "" {
+		return nil, &databases.InvalidEndpointsError{
+			Database: "essdive",
+			Message:  "ESS-DIVE requires an 'https' endpoint whose root is the URL from which its files are downloaded",
+		}
+	}
+
+	// make sure we can get an access token (if one is given)
+	if _, err := accessToken(); err != nil {
+		return nil, err
+	}
+
+	// NOTE: we prevent redirects from HTTPS -> HTTP!
+	return &Database{
+		Client:  databases.SecureHttpClient(time.Second * 20),
+		DataURL: strings.TrimSuffix(epConfig.Root, "/") + "/",
+	}, nil
+}
+
+func (db Database) SpecificSearchParameters() map[string]any {
+	// for details about ESS-DIVE-specific search parameters, see
+	// https://api.ess-dive.lbl.gov/#/Dataset/get-packages
+	return map[string]any{
+		"creator":      "",
+		"providerName": "",
+		"keywords":     "",
+		"begin_date":   "",
+		"end_date":     "",
+		"bbox":         "",
+		"site":         "",
+		"isPublic":     false,
+	}
+}
+
+func (db *Database) Search(orcid string, params databases.SearchParameters) (databases.SearchResults, error) {
+	p := url.Values{}
+	if params.Query != "" {
+		p.Add("text", params.Query)
+	}
+	if params.Specific != nil {
+		err := db.addSpecificSearchParameters(params.Specific, &p)
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+	}
+
+	// ESS-DIVE pages through packages, so we gather the files in successive
+	// pages of packages until we have the ones we need
+	var descriptors []map[string]any
+	numWanted := params.Pagination.Offset + params.Pagination.MaxNum
+	if params.Pagination.MaxNum <= 0 {
+		numWanted = params.Pagination.Offset + defaultMaxResults
+	}
+	for rowStart := 1; len(descriptors) < numWanted; rowStart += packagePageSize {
+		p.Set("rowStart", strconv.Itoa(rowStart))
+		p.Set("pageSize", strconv.Itoa(packagePageSize))
+		body, err := db.get("packages", p)
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+		var results searchResults
+		if err := json.Unmarshal(body, &results); err != nil {
+			return databases.SearchResults{}, err
+		}
+		for _, result := range results.Result {
+			pkg, err := db.packageWithId(result.Id)
+			if err != nil {
+				return databases.SearchResults{}, err
+			}
+			descriptors = append(descriptors, db.fileDescriptors(pkg)...)
+		}
+		if len(results.Result) < packagePageSize || rowStart+packagePageSize > results.Total {
+			break
+		}
+	}
+
+	// pick out the requested page of files
+	start := min(params.Pagination.Offset, len(descriptors))
+	end := min(numWanted, len(descriptors))
+	return databases.SearchResults{
+		Descriptors: descriptors[start:end],
+	}, nil
+}
+
+// routes the database's requests to ESS-DIVE through the given trace
+func (db *Database) Trace(trace *databases.RequestTrace) {
+	db.Client.Transport = trace.Transport(db.Client.Transport)
+}
+
+func (db Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
+	// fetch each package holding the requested files once
+	packages := make(map[string]Package)
+	var packageIds []string // (in order of appearance)
+	var missing []string
+	descriptors := make([]map[string]any, 0, len(fileIds))
+	for _, fileId := range fileIds {
+		packageId, fileName, valid := parseFileId(fileId)
+		if !valid {
+			missing = append(missing, fileId)
+			continue
+		}
+		pkg, found := packages[packageId]
+		if !found {
+			var err error
+			pkg, err = db.packageWithId(packageId)
+			if _, notFound := err.(*databases.ResourcesNotFoundError); notFound {
+				missing = append(missing, fileId)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			packages[packageId] = pkg
+			packageIds = append(packageIds, packageId)
+		}
+		var descriptor map[string]any
+		for _, dataObject := range pkg.Dataset.Distribution {
+			if dataObject.Name == fileName {
+				descriptor = db.fileDescriptor(pkg, dataObject)
+				break
+			}
+		}
+		if descriptor == nil {
+			missing = append(missing, fileId)
+			continue
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	if len(missing) > 0 {
+		return nil, &databases.ResourcesNotFoundError{
+			Database:    "ESS-DIVE",
+			ResourceIds: missing,
+		}
+	}
+
+	// append the JSON-LD metadata for each package
+	for _, packageId := range packageIds {
+		descriptors = append(descriptors, metadataDescriptor(packages[packageId]))
+	}
+	return descriptors, nil
+}
+
+// replaces any package IDs ("ESS-DIVE:<package-id>") among the given file IDs
+// with the IDs of the package's files (implements databases.DatasetExpander)
+func (db Database) ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error) {
+	expandedFileIds := make([]string, 0, len(fileIds))
+	datasets := make(map[string][]string)
+	encountered := make(map[string]struct{})
+	for _, fileId := range fileIds {
+		id, _ := strings.CutPrefix(fileId, idPrefix)
+		if strings.Contains(id, "/") { // ordinary file ID
+			if _, found := encountered[fileId]; !found {
+				expandedFileIds = append(expandedFileIds, fileId)
+				encountered[fileId] = struct{}{}
+			}
+			continue
+		}
+
+		pkg, err := db.packageWithId(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		var datasetFileIds []string
+		for _, dataObject := range pkg.Dataset.Distribution {
+			datasetFileIds = append(datasetFileIds, fileIdFor(pkg.Id, dataObject.Name))
+		}
+		if len(datasetFileIds) == 0 {
+			return nil, nil, &databases.ResourcesNotFoundError{
+				Database:    "ESS-DIVE",
+				ResourceIds: []string{fileId},
+			}
+		}
+		datasets[fileId] = datasetFileIds
+		for _, datasetFileId := range datasetFileIds {
+			if _, found := encountered[datasetFileId]; !found {
+				expandedFileIds = append(expandedFileIds, datasetFileId)
+				encountered[datasetFileId] = struct{}{}
+			}
+		}
+	}
+	return expandedFileIds, datasets, nil
+}
+
+func (db Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
+	// ESS-DIVE serves all of its files over HTTPS, so they're always staged.
+	// We simply generate a new UUID that can be handed to db.StagingStatus,
+	// which returns databases.StagingStatusSucceeded.
+	return uuid.New(), nil
+}
+
+func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	// all files are hot!
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db *Database) Finalize(orcid string, id uuid.UUID) error {
+	return nil
+}
+
+func (db Database) LocalUser(orcid string) (string, error) {
+	// no current mechanism for this
+	return "localuser", nil
+}
+
+func (db Database) Save() (databases.DatabaseSaveState, error) {
+	// so far, this database has no internal state
+	return databases.DatabaseSaveState{
+		Name: "essdive",
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
+
+//====================
+// Internal machinery
+//====================
+
+// the base URL of the ESS-DIVE Dataset API
+// (see https://docs.ess-dive.lbl.gov/programmatic-tools/ess-dive-dataset-api)
+var baseApiURL = "https://api.ess-dive.lbl.gov/"
+
+// the prefix of ESS-DIVE file IDs, which have the form
+// "ESS-DIVE:<package-id>/<file-name>"
+const idPrefix = "ESS-DIVE:"
+
+// the number of packages requested from ESS-DIVE in each page of a search
+const packagePageSize = 25
+
+// the maximum number of files returned by a search that doesn't specify one
+const defaultMaxResults = 100
+
+//--------------------------------
+// Access to ESS-DIVE API endpoints
+//--------------------------------
+
+// returns the ESS-DIVE access token obtained from the credential configured
+// for the database or (if none is configured) from an environment variable,
+// or an empty string if neither is given (only public data are accessible
+// without a token)
+func accessToken() (string, error) {
+	if credentialName := config.Databases["essdive"].Credential; credentialName != "" {
+		credential, err := credentials.Get(credentialName)
+		if err != nil {
+			return "", err
+		}
+		return credential.Secret, nil
+	}
+	return os.Getenv("DTS_ESSDIVE_TOKEN"), nil
+}
+
+// performs a GET request on the given resource, returning the resulting
+// response body and/or error
+func (db Database) get(resource string, values url.Values) ([]byte, error) {
+	body, err := db.tryGet(resource, values)
+	if _, rejected := err.(*databases.UnauthorizedError); rejected {
+		// the token may have expired and been renewed since we fetched it, so
+		// we fetch it again and retry once
+		if credentialName := config.Databases["essdive"].Credential; credentialName != "" {
+			slog.Info("ESS-DIVE rejected its access token; fetching it again")
+			credentials.Invalidate(credentialName)
+			body, err = db.tryGet(resource, values)
+		}
+	}
+	return body, err
+}
+
+// performs a single GET request on the given resource with the current access
+// token
+func (db Database) tryGet(resource string, values url.Values) ([]byte, error) {
+	res, err := url.Parse(baseApiURL)
+	if err != nil {
+		return nil, err
+	}
+	res = res.JoinPath(resource)
+	res.RawQuery = values.Encode()
+	slog.Debug(fmt.Sprintf("GET: %s", res.String()))
+	req, err := http.NewRequest(http.MethodGet, res.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	token, err := accessToken() // (picks up rotated tokens)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	resp, err := db.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return io.ReadAll(resp.Body)
+	case 401, 403:
+		return nil, &databases.UnauthorizedError{
+			Database: "essdive",
+			Message:  "ESS-DIVE rejected the access token",
+		}
+	case 404:
+		return nil, &databases.ResourcesNotFoundError{
+			Database: "ESS-DIVE",
+		}
+	case 503:
+		return nil, &databases.UnavailableError{
+			Database: "essdive",
+		}
+	default:
+		return nil, fmt.Errorf("an error occurred with the ESS-DIVE database (%d)",
+			resp.StatusCode)
+	}
+}
+
+// fetches the package with the given ID
+func (db Database) packageWithId(packageId string) (Package, error) {
+	var pkg Package
+	body, err := db.get("packages/"+url.PathEscape(packageId), url.Values{})
+	if err != nil {
+		if notFound, ok := err.(*databases.ResourcesNotFoundError); ok {
+			notFound.ResourceIds = []string{idPrefix + packageId}
+		}
+		return pkg, err
+	}
+	if err := json.Unmarshal(body, &pkg); err != nil {
+		return pkg, err
+	}
+	if err := json.Unmarshal(body, &struct {
+		Dataset *map[string]any `json:"dataset"`
+	}{Dataset: &pkg.Metadata}); err != nil {
+		return pkg, err
+	}
+	return pkg, nil
+}
+
+//----------------
+// Metadata types
+//----------------
+
+// a value that ESS-DIVE's JSON-LD gives either as a single item or an array
+type oneOrMany[T any] []T
+
+func (v *oneOrMany[T]) UnmarshalJSON(data []byte) error {
+	var many []T
+	if err := json.Unmarshal(data, &many); err == nil {
+		*v = many
+		return nil
+	}
+	var one T
+	if err := json.Unmarshal(data, &one); err != nil {
+		return err
+	}
+	*v = []T{one}
+	return nil
+}
+
+// results of a package search
+type searchResults struct {
+	Total  int `json:"total"`
+	Result []struct {
+		Id string `json:"id"`
+	} `json:"result"`
+}
+
+// a package (dataset) and its metadata
+type Package struct {
+	Id      string  `json:"id"`
+	ViewUrl string  `json:"viewUrl"`
+	Dataset Dataset `json:"dataset"`
+	// the package's JSON-LD metadata, as given
+	Metadata map[string]any `json:"-"`
+}
+
+// the (partial) JSON-LD description of a dataset
+// (see https://docs.ess-dive.lbl.gov/contributing-data/package-level-metadata)
+type Dataset struct {
+	Doi           string                  `json:"@id"`
+	Name          string                  `json:"name"`
+	Description   oneOrMany[string]       `json:"description"`
+	Creator       oneOrMany[Person]       `json:"creator"`
+	DatePublished string                  `json:"datePublished"`
+	License       string                  `json:"license"`
+	Funder        oneOrMany[Organization] `json:"funder"`
+	Distribution  []DataObject            `json:"distribution"`
+}
+
+// a person credited for a dataset
+type Person struct {
+	Id          string                  `json:"@id"` // ORCID (if given)
+	GivenName   string                  `json:"givenName"`
+	FamilyName  string                  `json:"familyName"`
+	Affiliation oneOrMany[Organization] `json:"affiliation"`
+}
+
+// an organization (affiliation or funder)
+type Organization struct {
+	Id   string `json:"@id"`
+	Name string `json:"name"`
+}
+
+// UnmarshalJSON accepts organizations given by name only
+func (o *Organization) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*o = Organization{Name: name}
+		return nil
+	}
+	type organization Organization // (avoids recursion)
+	return json.Unmarshal(data, (*organization)(o))
+}
+
+// a file within a dataset
+type DataObject struct {
+	Name           string  `json:"name"`
+	ContentUrl     string  `json:"contentUrl"`
+	EncodingFormat string  `json:"encodingFormat"`
+	ContentSize    float64 `json:"contentSize"` // kilobytes
+	Identifier     string  `json:"identifier"`
+}
+
+//-------------
+// Descriptors
+//-------------
+
+// returns the ID of the file with the given name in the package with the
+// given ID
+func fileIdFor(packageId, fileName string) string {
+	return idPrefix + packageId + "/" + fileName
+}
+
+// returns the package ID and file name for the given file ID, and whether the
+// ID is well-formed
+func parseFileId(fileId string) (string, string, bool) {
+	id, hasPrefix := strings.CutPrefix(fileId, idPrefix)
+	packageId, fileName, found := strings.Cut(id, "/")
+	return packageId, fileName, hasPrefix && found && packageId != "" && fileName != ""
+}
+
+// returns descriptors for all files in the given package
+func (db Database) fileDescriptors(pkg Package) []map[string]any {
+	descriptors := make([]map[string]any, len(pkg.Dataset.Distribution))
+	for i, dataObject := range pkg.Dataset.Distribution {
+		descriptors[i] = db.fileDescriptor(pkg, dataObject)
+	}
+	return descriptors
+}
+
+// returns a descriptor for the given file within the given package
+func (db Database) fileDescriptor(pkg Package, dataObject DataObject) map[string]any {
+	id := fileIdFor(pkg.Id, dataObject.Name)
+	format := formats.FormatFromFileName(dataObject.Name)
+	mediatype := dataObject.EncodingFormat
+	if mediatype == "" {
+		mediatype = formats.MimeTypeForFile(dataObject.Name)
+	}
+
+	// file paths are relative to the root of the HTTPS endpoint
+	path, underRoot := strings.CutPrefix(dataObject.ContentUrl, db.DataURL)
+	if !underRoot {
+		slog.Warn(fmt.Sprintf("ESS-DIVE file %s is not served from %s", id, db.DataURL))
+	}
+
+	fileCredit := creditMetadataForPackage(pkg)
+	fileCredit.Identifier = id
+	descriptor := map[string]any{
+		"bytes":       int(dataObject.ContentSize * 1024),
+		"credit":      fileCredit,
+		"description": fmt.Sprintf("%s (from ESS-DIVE dataset '%s')", dataObject.Name, pkg.Dataset.Name),
+		"format":      format,
+		"id":          id,
+		"mediatype":   mediatype,
+		"name":        frictionless.ResourceName(dataObject.Name),
+		"path":        path,
+	}
+	frictionless.SetBrowseURL(descriptor, dataObject.ContentUrl)
+	if license := frictionless.ParseLicense(pkg.Dataset.License); license != (frictionless.License{}) {
+		frictionless.SetLicenses(descriptor, license)
+	}
+	return descriptor
+}
+
+// returns a data descriptor holding the JSON-LD metadata for the given package
+func metadataDescriptor(pkg Package) map[string]any {
+	return map[string]any{
+		"name":  frictionless.ResourceName("ess-dive-metadata-for-" + pkg.Id + ".jsonld"),
+		"title": fmt.Sprintf("ESS-DIVE metadata for package %s", pkg.Id),
+		"data":  pkg.Metadata,
+	}
+}
+
+// extracts credit metadata from the given package
+func creditMetadataForPackage(pkg Package) credit.CreditMetadata {
+	dataset := pkg.Dataset
+	license := frictionless.ParseLicense(dataset.License)
+	contributors := make([]credit.Contributor, len(dataset.Creator))
+	for i, person := range dataset.Creator {
+		contributors[i] = credit.Contributor{
+			ContributorType: "Person",
+			ContributorId:   person.Id,
+			Name:            strings.TrimSpace(person.GivenName + " " + person.FamilyName),
+			GivenName:       person.GivenName,
+			FamilyName:      person.FamilyName,
+		}
+		for _, affiliation := range person.Affiliation {
+			contributors[i].Affiliations = append(contributors[i].Affiliations,
+				credit.Organization{
+					OrganizationId:   affiliation.Id,
+					OrganizationName: affiliation.Name,
+				})
+		}
+	}
+
+	var descriptions []credit.Description
+	for _, text := range dataset.Description {
+		descriptions = append(descriptions, credit.Description{
+			DescriptionText: text,
+			Language:        "en",
+		})
+	}
+
+	var dates []credit.EventDate
+	if dataset.DatePublished != "" {
+		dates = append(dates, credit.EventDate{
+			Date:  dataset.DatePublished,
+			Event: "Issued",
+		})
+	}
+
+	var funding []credit.FundingReference
+	for _, funder := range dataset.Funder {
+		funding = append(funding, credit.FundingReference{
+			Funder: credit.Organization{
+				OrganizationId:   funder.Id,
+				OrganizationName: funder.Name,
+			},
+		})
+	}
+
+	var relatedIdentifiers []credit.PermanentID
+	if dataset.Doi != "" {
+		relatedIdentifiers = append(relatedIdentifiers, credit.PermanentID{
+			Id:               dataset.Doi,
+			Description:      "Dataset DOI",
+			RelationshipType: "IsPartOf",
+		})
+	}
+
+	var titles []credit.Title
+	if dataset.Name != "" {
+		titles = append(titles, credit.Title{Title: dataset.Name})
+	}
+
+	return credit.CreditMetadata{
+		Contributors: contributors,
+		Dates:        dates,
+		Descriptions: descriptions,
+		Funding:      funding,
+		License:      credit.License{Id: license.Name, Url: license.Path},
+		Publisher: credit.Organization{
+			OrganizationName: "ESS-DIVE",
+		},
+		RelatedIdentifiers: relatedIdentifiers,
+		ResourceType:       "dataset",
+		Titles:             titles,
+		Url:                pkg.ViewUrl,
+	}
+}
+
+// checks ESS-DIVE-specific search parameters
+func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Values) error {
+	paramSpec := db.SpecificSearchParameters()
+	for name, jsonValue := range params {
+		if _, found := paramSpec[name]; !found {
+			return &databases.InvalidSearchParameter{
+				Database: "ESS-DIVE",
+				Message:  fmt.Sprintf("Unrecognized ESS-DIVE-specific search parameter: %s", name),
+			}
+		}
+		switch value := jsonValue.(type) {
+		case string:
+			p.Add(name, value)
+		case bool:
+			p.Add(name, strconv.FormatBool(value))
+		default:
+			return &databases.InvalidSearchParameter{
+				Database: "ESS-DIVE",
+				Message:  fmt.Sprintf("Invalid value for ESS-DIVE search parameter %s: %v", name, jsonValue),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package essdive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/conformance"
+	"github.com/kbase/dts/frictionless"
+)
+
+// we test our ESS-DIVE database against a stand-in for the ESS-DIVE Dataset
+// API that serves a few packages from memory
+
+const essdiveConfig string = `
+databases:
+  essdive:
+    name: ESS-DIVE
+    organization: Environmental System Science Data Infrastructure for a Virtual Ecosystem
+    endpoint: essdive-https
+    credential: essdive
+credentials:
+  essdive:
+    provider: file
+    path: TOKEN_FILE
+endpoints:
+  essdive-https:
+    name: ESS-DIVE downloads
+    id: 3f2a9c1e-6b7d-4e8f-9a0b-1c2d3e4f5a6b
+    provider: https
+    root: https://data.ess-dive.lbl.gov/catalog/d1/mn/v2/object
+`
+
+// the URL from which the test packages' files are downloaded
+const dataURL = "https://data.ess-dive.lbl.gov/catalog/d1/mn/v2/object/"
+
+var server *httptest.Server
+
+// the file containing the ESS-DIVE access token, and the token the test server
+// currently accepts
+var tokenFile string
+var acceptedToken atomic.Value
+
+// returns the JSON-LD metadata for a test package with the given ID and
+// number of files
+func testPackage(id string, numFiles int) map[string]any {
+	distribution := make([]any, numFiles)
+	for i := range distribution {
+		distribution[i] = map[string]any{
+			"name":           fmt.Sprintf("data_%d.csv", i+1),
+			"contentUrl":     fmt.Sprintf("%s%s-file-%d", dataURL, id, i+1),
+			"encodingFormat": "text/csv",
+			"contentSize":    2.0,
+			"identifier":     fmt.Sprintf("%s-file-%d", id, i+1),
+		}
+	}
+	return map[string]any{
+		"id":      id,
+		"viewUrl": "https://data.ess-dive.lbl.gov/view/" + id,
+		"dataset": map[string]any{
+			"@context":    "http://schema.org/",
+			"@type":       "Dataset",
+			"@id":         "doi:10.15485/" + id,
+			"name":        "Soil moisture at site " + id,
+			"description": "Hourly soil moisture measurements",
+			"creator": []any{
+				map[string]any{
+					"@id":         "https://orcid.org/0000-0002-1825-0097",
+					"givenName":   "Josiah",
+					"familyName":  "Carberry",
+					"affiliation": "Brown University",
+				},
+			},
+			"datePublished": "2023",
+			"license":       "http://creativecommons.org/licenses/by/4.0/",
+			"funder": map[string]any{
+				"@id":  "http://dx.doi.org/10.13039/100006206",
+				"name": "U.S. DOE > Office of Science > Biological and Environmental Research (BER)",
+			},
+			"distribution": distribution,
+		},
+	}
+}
+
+// packages served by the test server, in search order
+var testPackages = []map[string]any{
+	testPackage("ess-dive-aaa-20230101t000000000", 4),
+	testPackage("ess-dive-bbb-20230102t000000000", 3),
+	testPackage("ess-dive-ccc-20230103t000000000", 5),
+}
+
+// handles requests to the test server
+func serveApi(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+acceptedToken.Load().(string) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/packages" {
+		rowStart, _ := strconv.Atoi(r.URL.Query().Get("rowStart"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		var result []any
+		for i := rowStart - 1; i >= 0 && i < len(testPackages) && len(result) < pageSize; i++ {
+			result = append(result, map[string]any{"id": testPackages[i]["id"]})
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"total":  len(testPackages),
+			"result": result,
+		})
+		return
+	}
+	if packageId, found := strings.CutPrefix(r.URL.Path, "/packages/"); found {
+		for _, pkg := range testPackages {
+			if pkg["id"] == packageId {
+				json.NewEncoder(w).Encode(pkg)
+				return
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	server = httptest.NewServer(http.HandlerFunc(serveApi))
+	baseApiURL = server.URL + "/"
+	dir, err := os.MkdirTemp(os.TempDir(), "essdive-tests-")
+	if err != nil {
+		panic(err)
+	}
+	tokenFile = filepath.Join(dir, "token")
+	acceptedToken.Store("first-token")
+	err = os.WriteFile(tokenFile, []byte("first-token\n"), 0600)
+	if err != nil {
+		panic(err)
+	}
+	myConfig := strings.ReplaceAll(essdiveConfig, "TOKEN_FILE", tokenFile)
+	err = config.InitSelected([]byte(myConfig), false, true, true, true)
+	if err != nil {
+		panic(err)
+	}
+	databases.RegisterDatabase("essdive", NewDatabase)
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	server.Close()
+	os.RemoveAll(filepath.Dir(tokenFile))
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+	db, err := NewDatabase()
+	assert.NotNil(db, "ESS-DIVE database not created")
+	assert.Nil(err, "ESS-DIVE database creation encountered an error")
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+
+	results, err := db.Search("", databases.SearchParameters{Query: "soil"})
+	assert.Nil(err)
+	assert.Len(results.Descriptors, 12)
+
+	descriptor := results.Descriptors[0]
+	assert.Equal("ESS-DIVE:ess-dive-aaa-20230101t000000000/data_1.csv", descriptor["id"])
+	assert.Equal("ess-dive-aaa-20230101t000000000-file-1", descriptor["path"])
+	assert.Equal("data_1", descriptor["name"])
+	assert.Equal("text/csv", descriptor["mediatype"])
+	assert.Equal(2048, descriptor["bytes"])
+	resourceCredit := descriptor["credit"].(credit.CreditMetadata)
+	assert.Equal("Soil moisture at site ess-dive-aaa-20230101t000000000", resourceCredit.Titles[0].Title)
+	assert.Equal("https://orcid.org/0000-0002-1825-0097", resourceCredit.Contributors[0].ContributorId)
+	assert.Equal("Brown University", resourceCredit.Contributors[0].Affiliations[0].OrganizationName)
+	assert.Equal("doi:10.15485/ess-dive-aaa-20230101t000000000", resourceCredit.RelatedIdentifiers[0].Id)
+	assert.Len(resourceCredit.Funding, 1)
+	assert.Equal("CC-BY-4.0", resourceCredit.License.Id)
+	assert.Equal([]frictionless.License{frictionless.ParseLicense("CC-BY-4.0")},
+		frictionless.Licenses(descriptor))
+
+	_, err = db.Search("", databases.SearchParameters{
+		Specific: map[string]any{"color": "blue"},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+func TestDescriptors(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+
+	descriptors, err := db.Descriptors("", []string{
+		"ESS-DIVE:ess-dive-bbb-20230102t000000000/data_2.csv",
+		"ESS-DIVE:ess-dive-aaa-20230101t000000000/data_4.csv",
+	})
+	assert.Nil(err)
+	assert.Len(descriptors, 4) // 2 files + 2 packages' metadata
+	assert.Equal("ESS-DIVE:ess-dive-bbb-20230102t000000000/data_2.csv", descriptors[0]["id"])
+	assert.Equal("ESS-DIVE:ess-dive-aaa-20230101t000000000/data_4.csv", descriptors[1]["id"])
+	metadata := descriptors[2]["data"].(map[string]any)
+	assert.Equal("doi:10.15485/ess-dive-bbb-20230102t000000000", metadata["@id"])
+
+	_, err = db.Descriptors("", []string{
+		"ESS-DIVE:ess-dive-aaa-20230101t000000000/data_1.csv",
+		"ESS-DIVE:ess-dive-aaa-20230101t000000000/data_9.csv",
+		"ESS-DIVE:ess-dive-zzz-20230101t000000000/data_1.csv",
+		"not-an-essdive-id",
+	})
+	assert.IsType(&databases.ResourcesNotFoundError{}, err)
+	assert.Len(err.(*databases.ResourcesNotFoundError).ResourceIds, 3)
+}
+
+func TestExpandFileIds(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+
+	fileIds, datasets, err := db.(databases.DatasetExpander).ExpandFileIds("", []string{
+		"ESS-DIVE:ess-dive-bbb-20230102t000000000/data_1.csv",
+		"ESS-DIVE:ess-dive-bbb-20230102t000000000",
+	}, nil)
+	assert.Nil(err)
+	assert.Len(fileIds, 3)
+	assert.Len(datasets["ESS-DIVE:ess-dive-bbb-20230102t000000000"], 3)
+
+	_, _, err = db.(databases.DatasetExpander).ExpandFileIds("", []string{
+		"ESS-DIVE:ess-dive-zzz-20230101t000000000",
+	}, nil)
+	assert.IsType(&databases.ResourcesNotFoundError{}, err)
+}
+
+// runs the database conformance suite against ESS-DIVE
+func TestRenewedAccessToken(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+	params := databases.SearchParameters{
+		Query:      "soil",
+		Pagination: databases.SearchPaginationParameters{MaxNum: 1},
+	}
+	_, err := db.Search("", params)
+	assert.Nil(err)
+
+	// renew the token: the database picks up the new one when ESS-DIVE rejects
+	// the old one
+	acceptedToken.Store("second-token")
+	err = os.WriteFile(tokenFile, []byte("second-token\n"), 0600)
+	assert.Nil(err)
+	results, err := db.Search("", params)
+	assert.Nil(err)
+	assert.Equal(1, len(results.Descriptors))
+
+	// an expired token that hasn't been renewed is still rejected
+	acceptedToken.Store("third-token")
+	_, err = db.Search("", params)
+	assert.IsType(&databases.UnauthorizedError{}, err)
+	acceptedToken.Store("second-token")
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, NewDatabase, conformance.Parameters{
+		Query:    "soil",
+		PageSize: 5,
+	})
+}
+
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
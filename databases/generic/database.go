@@ -0,0 +1,489 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
+)
+
+// This package implements a "generic" database whose searches and descriptors
+// are described entirely by its configuration (see the generic field of a
+// database's configuration). It allows operators to onboard small REST
+// catalogs (e.g. DERIVA catalogs like FaceBase's) whose files are all served
+// from endpoints without writing a database implementation. Its files are
+// always staged.
+
+// file database appropriate for handling searches and transfers
+// (implements the databases.Database interface)
+type Database struct {
+	// HTTP client used for requests to the catalog
+	Client http.Client
+	// the name of the database in the DTS configuration
+	Name string
+}
+
+// returns a function that creates the generic database with the given name,
+// suitable for use with databases.RegisterDatabase
+func NewDatabaseFunc(dbName string) func() (databases.Database, error) {
+	return func() (databases.Database, error) {
+		return NewDatabase(dbName)
+	}
+}
+
+// creates the generic database with the given name
+func NewDatabase(dbName string) (databases.Database, error) {
+	dbConfig, found := config.Databases[dbName]
+	if !found || dbConfig.Generic == nil {
+		return nil, &databases.NotFoundError{Database: dbName}
+	}
+
+	// make sure we can get a credential (if one is given)
+	if dbConfig.Credential != "" {
+		if _, err := credentials.Get(dbConfig.Credential); err != nil {
+			return nil, err
+		}
+	}
+
+	// NOTE: we prevent redirects from HTTPS -> HTTP!
+	return &Database{
+		Client: databases.SecureHttpClient(time.Second * 20),
+		Name:   dbName,
+	}, nil
+}
+
+func (db Database) SpecificSearchParameters() map[string]any {
+	// generic databases have no specific search parameters
+	return nil
+}
+
+// returns true if the database's records have an embargo field (implements
+// databases.EmbargoingDatabase)
+func (db Database) ReportsEmbargoes() bool {
+	return config.Databases[db.Name].Generic.Fields["embargo_until"] != ""
+}
+
+func (db *Database) Search(orcid string, params databases.SearchParameters) (databases.SearchResults, error) {
+	if len(params.Specific) > 0 {
+		return databases.SearchResults{}, &databases.InvalidSearchParameter{
+			Database: db.Name,
+			Message:  "This database accepts no database-specific search parameters",
+		}
+	}
+	generic := config.Databases[db.Name].Generic
+	limit := params.Pagination.MaxNum
+	if limit <= 0 {
+		limit = defaultMaxResults
+	}
+	paginated := strings.Contains(generic.SearchURL, "{offset}") ||
+		strings.Contains(generic.SearchURL, "{limit}")
+	resource := strings.NewReplacer(
+		"{query}", escape(params.Query),
+		"{offset}", strconv.Itoa(params.Pagination.Offset),
+		"{limit}", strconv.Itoa(limit),
+	).Replace(generic.SearchURL)
+	body, err := db.get(resource)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+	var response any
+	if err := json.Unmarshal(body, &response); err != nil {
+		return databases.SearchResults{}, err
+	}
+	records, ok := lookup(response, generic.ResultsField).([]any)
+	if !ok {
+		return databases.SearchResults{}, fmt.Errorf("the %s search response has no list of results at '%s'",
+			db.Name, generic.ResultsField)
+	}
+
+	// if the catalog doesn't paginate its results, we pick out the requested page
+	if !paginated {
+		start := min(params.Pagination.Offset, len(records))
+		end := min(params.Pagination.Offset+limit, len(records))
+		records = records[start:end]
+	}
+	descriptors := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		descriptor, err := db.fileDescriptor(record)
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return databases.SearchResults{
+		Descriptors: descriptors,
+	}, nil
+}
+
+// routes the database's requests to its catalog through the given trace
+func (db *Database) Trace(trace *databases.RequestTrace) {
+	db.Client.Transport = trace.Transport(db.Client.Transport)
+}
+
+func (db Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
+	generic := config.Databases[db.Name].Generic
+	descriptors := make([]map[string]any, 0, len(fileIds))
+	var missing []string
+	for _, fileId := range fileIds {
+		id, hasPrefix := strings.CutPrefix(fileId, db.idPrefix())
+		if !hasPrefix || id == "" {
+			missing = append(missing, fileId)
+			continue
+		}
+		body, err := db.get(strings.ReplaceAll(generic.RecordURL, "{id}", escape(id)))
+		if _, notFound := err.(*databases.ResourcesNotFoundError); notFound {
+			missing = append(missing, fileId)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var record any
+		if err := json.Unmarshal(body, &record); err != nil {
+			return nil, err
+		}
+		if records, isList := record.([]any); isList { // (e.g. DERIVA entities)
+			if len(records) == 0 {
+				missing = append(missing, fileId)
+				continue
+			}
+			record = records[0]
+		}
+		descriptor, err := db.fileDescriptor(record)
+		if err != nil {
+			return nil, err
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	if len(missing) > 0 {
+		return nil, &databases.ResourcesNotFoundError{
+			Database:    db.Name,
+			ResourceIds: missing,
+		}
+	}
+	return descriptors, nil
+}
+
+func (db Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
+	// generic databases serve their files from their endpoints, so they're
+	// always staged
+	return uuid.New(), nil
+}
+
+func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db *Database) Finalize(orcid string, id uuid.UUID) error {
+	return nil
+}
+
+func (db Database) LocalUser(orcid string) (string, error) {
+	// no current mechanism for this
+	return "localuser", nil
+}
+
+func (db Database) Save() (databases.DatabaseSaveState, error) {
+	// this database has no internal state
+	return databases.DatabaseSaveState{
+		Name: db.Name,
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
+
+//====================
+// Internal machinery
+//====================
+
+// the maximum number of files returned by a search that doesn't specify one
+const defaultMaxResults = 100
+
+// returns the prefix of the database's file IDs
+func (db Database) idPrefix() string {
+	if prefix := config.Databases[db.Name].Generic.IdPrefix; prefix != "" {
+		return prefix
+	}
+	return strings.ToUpper(db.Name) + ":"
+}
+
+// URL-encodes the given value for substitution into a URL template, in either
+// its path or its query
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// returns the value at the given dot-separated path within the given JSON
+// value, or nil if there is none (an empty path yields the value itself)
+func lookup(value any, fieldPath string) any {
+	if fieldPath == "" {
+		return value
+	}
+	for _, name := range strings.Split(fieldPath, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// performs a GET request on the given URL, returning the resulting response
+// body and/or error
+func (db Database) get(resource string) ([]byte, error) {
+	slog.Debug(fmt.Sprintf("GET: %s", resource))
+	req, err := http.NewRequest(http.MethodGet, resource, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if credentialName := config.Databases[db.Name].Credential; credentialName != "" {
+		credential, err := credentials.Get(credentialName) // (picks up rotated secrets)
+		if err != nil {
+			return nil, err
+		}
+		header, value := db.authHeader(credential.Secret)
+		req.Header.Add(header, value)
+	}
+	resp, err := db.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return io.ReadAll(resp.Body)
+	case 401, 403:
+		return nil, &databases.UnauthorizedError{
+			Database: db.Name,
+			Message:  fmt.Sprintf("%s rejected the DTS's credential", db.Name),
+		}
+	case 404:
+		return nil, &databases.ResourcesNotFoundError{
+			Database: db.Name,
+		}
+	case 503:
+		return nil, &databases.UnavailableError{
+			Database: db.Name,
+		}
+	default:
+		return nil, fmt.Errorf("an error occurred with the %s database (%d)",
+			db.Name, resp.StatusCode)
+	}
+}
+
+// returns the name and value of the HTTP header that sends the given secret
+func (db Database) authHeader(secret string) (string, string) {
+	generic := config.Databases[db.Name].Generic
+	header, scheme := generic.AuthHeader, generic.AuthScheme
+	if header == "" {
+		header = "Authorization"
+	}
+	if scheme == "" && strings.EqualFold(header, "Authorization") {
+		scheme = "Bearer"
+	}
+	if scheme != "" {
+		return header, scheme + " " + secret
+	}
+	return header, secret
+}
+
+//-------------
+// Descriptors
+//-------------
+
+// returns a descriptor for the file with the given catalog record
+func (db Database) fileDescriptor(record any) (map[string]any, error) {
+	generic := config.Databases[db.Name].Generic
+	field := func(name string) any {
+		if fieldPath := generic.Fields[name]; fieldPath != "" {
+			return lookup(record, fieldPath)
+		}
+		return nil
+	}
+	stringField := func(name string) string {
+		switch value := field(name).(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return ""
+		}
+	}
+
+	catalogId := stringField("id")
+	if catalogId == "" {
+		return nil, &InvalidRecordError{
+			Database: db.Name,
+			Message:  fmt.Sprintf("A record has no '%s' (id) field", generic.Fields["id"]),
+		}
+	}
+	id := db.idPrefix() + catalogId
+	fileURL := stringField("url")
+	if fileURL == "" {
+		return nil, &InvalidRecordError{
+			Database: db.Name,
+			Message:  fmt.Sprintf("The record for %s has no '%s' (url) field", id, generic.Fields["url"]),
+		}
+	}
+	filePath, endpoint, err := db.endpointPath(id, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	if escapesRoot(filePath) {
+		return nil, &InvalidRecordError{
+			Database: db.Name,
+			Message:  fmt.Sprintf("The URL for %s has a path with '..' segments: %s", id, fileURL),
+		}
+	}
+
+	fileName := stringField("name")
+	if fileName == "" {
+		fileName = path.Base(filePath)
+	}
+	format := stringField("format")
+	if format == "" {
+		format = formats.FormatFromFileName(fileName)
+	}
+	mediatype := stringField("mediatype")
+	if mediatype == "" {
+		mediatype = formats.MimeTypeForFile(fileName)
+	}
+	description := stringField("description")
+	if description == "" {
+		description = fmt.Sprintf("%s (from %s)", fileName, config.Databases[db.Name].Name)
+	}
+
+	descriptor := map[string]any{
+		"credit": credit.CreditMetadata{
+			Identifier: id,
+			Publisher: credit.Organization{
+				OrganizationName: config.Databases[db.Name].Organization,
+			},
+			ResourceType: "dataset",
+			Url:          fileURL,
+		},
+		"description": description,
+		"format":      format,
+		"id":          id,
+		"mediatype":   mediatype,
+		"name":        frictionless.ResourceName(fileName),
+		"path":        filePath,
+	}
+	if recursive, _ := field("recursive").(bool); recursive {
+		descriptor["recursive"] = true // a directory, transferred with its contents
+	} else if bytes, ok := field("bytes").(float64); ok {
+		descriptor["bytes"] = int(bytes)
+	}
+	if hash := stringField("hash"); hash != "" {
+		descriptor["hash"] = hash
+	}
+	if embargoUntil := stringField("embargo_until"); embargoUntil != "" {
+		descriptor["embargo_until"] = embargoUntil
+	}
+	if endpoint != "" {
+		descriptor["endpoint"] = endpoint
+	}
+	frictionless.SetBrowseURL(descriptor, fileURL)
+	return descriptor, nil
+}
+
+// returns the path of the file with the given ID and URL relative to the root
+// of the endpoint on which it resides, and the name of that endpoint (or an
+// empty string for a database with a single endpoint)
+func (db Database) endpointPath(id, fileURL string) (string, string, error) {
+	dbConfig := config.Databases[db.Name]
+	for _, rule := range dbConfig.Generic.EndpointRules {
+		if filePath, found := strings.CutPrefix(fileURL, rule.URLPrefix); found {
+			if len(dbConfig.Endpoints) > 0 {
+				endpoint, found := dbConfig.Endpoints[rule.Endpoint]
+				if !found {
+					return "", "", &databases.InvalidResourceEndpointError{
+						Database:   db.Name,
+						ResourceId: id,
+						Endpoint:   rule.Endpoint,
+					}
+				}
+				return filePath, endpoint, nil
+			}
+			return filePath, "", nil
+		}
+	}
+	if len(dbConfig.Endpoints) > 0 {
+		return "", "", &databases.ResourceEndpointNotFoundError{
+			Database:   db.Name,
+			ResourceId: id,
+		}
+	}
+	// without a rule, the path is relative to the root of the (HTTPS) endpoint
+	// when possible, and the URL's path otherwise (except for HTTPS endpoints,
+	// which would fetch a different file from their own host)
+	epConfig := config.Endpoints[dbConfig.Endpoint]
+	if epConfig.Root != "" {
+		if filePath, found := strings.CutPrefix(fileURL, strings.TrimSuffix(epConfig.Root, "/")+"/"); found {
+			return filePath, "", nil
+		}
+	}
+	if epConfig.Provider == "https" {
+		return "", "", &InvalidRecordError{
+			Database: db.Name,
+			Message: fmt.Sprintf("The URL for %s lies outside the root of endpoint %s: %s",
+				id, dbConfig.Endpoint, fileURL),
+		}
+	}
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimPrefix(u.Path, "/"), "", nil
+}
+
+// returns true if the given (possibly escaped) path has '..' segments that
+// could lead outside of an endpoint's root
+func escapesRoot(filePath string) bool {
+	if unescaped, err := url.PathUnescape(filePath); err == nil {
+		filePath = unescaped
+	}
+	return slices.Contains(strings.Split(filePath, "/"), "..")
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/conformance"
+	"github.com/kbase/dts/frictionless"
+)
+
+// we test generic databases against a stand-in for a DERIVA-style catalog
+// that serves a few file records from memory
+
+const genericConfig string = `
+credentials:
+  facebase:
+    id: dts
+    secret: catalog-token
+databases:
+  facebase:
+    name: FaceBase
+    organization: FaceBase Consortium
+    endpoint: facebase-https
+    credential: facebase
+    generic:
+      search_url: CATALOG_URL/search?q={query}&offset={offset}&limit={limit}
+      record_url: CATALOG_URL/entity/file/RID={id}
+      results_field: data.records
+      id_prefix: "FACEBASE:"
+      fields:
+        id: RID
+        url: url
+        name: filename
+        bytes: byte_count
+        hash: md5
+        description: caption
+        embargo_until: release_date
+        recursive: is_directory
+  mirrored:
+    name: Mirrored catalog
+    organization: Somebody
+    endpoints:
+      primary: facebase-https
+      mirror: mirror-https
+    generic:
+      search_url: CATALOG_URL/entity/file/*::ciregexp::{query}
+      record_url: CATALOG_URL/entity/file/RID={id}
+      fields:
+        id: RID
+        url: mirror_url
+      endpoint_rules:
+        - url_prefix: https://www.facebase.org/hatrac/
+          endpoint: primary
+        - url_prefix: https://mirror.example.org/files/
+          endpoint: mirror
+endpoints:
+  facebase-https:
+    name: FaceBase downloads
+    id: 8c7d6e5f-4a3b-2c1d-0e9f-8a7b6c5d4e3f
+    provider: https
+    root: https://www.facebase.org/hatrac/
+  mirror-https:
+    name: Mirror downloads
+    id: 1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d
+    provider: https
+    root: https://mirror.example.org/files/
+`
+
+var server *httptest.Server
+
+// file records served by the test catalog
+var testRecords []map[string]any
+
+func init() {
+	for i := range 12 {
+		rid := fmt.Sprintf("1-%04d", i+1)
+		releaseDate := ""
+		if i == 2 { // the third file is embargoed
+			releaseDate = "2099-01-01"
+		}
+		mirrorURL := fmt.Sprintf("https://www.facebase.org/hatrac/facebase/data/skull_%d.nii.gz", i+1)
+		if i%2 == 1 {
+			mirrorURL = fmt.Sprintf("https://mirror.example.org/files/skull_%d.nii.gz", i+1)
+		}
+		testRecords = append(testRecords, map[string]any{
+			"RID":          rid,
+			"url":          fmt.Sprintf("https://www.facebase.org/hatrac/facebase/data/skull_%d.nii.gz", i+1),
+			"mirror_url":   mirrorURL,
+			"filename":     fmt.Sprintf("skull_%d.nii.gz", i+1),
+			"byte_count":   1024.0 * float64(i+1),
+			"md5":          fmt.Sprintf("%032x", i+1),
+			"caption":      fmt.Sprintf("Micro-CT scan of skull %d", i+1),
+			"release_date": releaseDate,
+		})
+	}
+}
+
+// handles requests to the test catalog
+func serveCatalog(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/search") &&
+		r.Header.Get("Authorization") != "Bearer catalog-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/search" {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var records []map[string]any
+		for _, record := range testRecords {
+			if strings.Contains(record["caption"].(string), r.URL.Query().Get("q")) {
+				records = append(records, record)
+			}
+		}
+		records = records[min(offset, len(records)):min(offset+limit, len(records))]
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"records": records},
+		})
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/entity/file/*::ciregexp::") {
+		json.NewEncoder(w).Encode(testRecords)
+		return
+	}
+	if rid, found := strings.CutPrefix(r.URL.Path, "/entity/file/RID="); found {
+		records := []map[string]any{}
+		for _, record := range testRecords {
+			if record["RID"] == rid {
+				records = append(records, record)
+			}
+		}
+		json.NewEncoder(w).Encode(records)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	server = httptest.NewServer(http.HandlerFunc(serveCatalog))
+	myConfig := strings.ReplaceAll(genericConfig, "CATALOG_URL", server.URL)
+	err := config.InitSelected([]byte(myConfig), false, true, true, true)
+	if err != nil {
+		panic(err)
+	}
+	databases.RegisterDatabase("facebase", NewDatabaseFunc("facebase"))
+	databases.RegisterDatabase("mirrored", NewDatabaseFunc("mirrored"))
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	server.Close()
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+	db, err := NewDatabase("facebase")
+	assert.NotNil(db, "generic database not created")
+	assert.Nil(err, "generic database creation encountered an error")
+
+	_, err = NewDatabase("nonexistent")
+	assert.IsType(&databases.NotFoundError{}, err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("facebase")
+	results, err := db.Search("", databases.SearchParameters{
+		Query:      "skull 1",
+		Pagination: databases.SearchPaginationParameters{MaxNum: 2},
+	})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Descriptors))
+	descriptor := results.Descriptors[0]
+	assert.Equal("FACEBASE:1-0001", descriptor["id"])
+	assert.Equal("skull_1.nii", descriptor["name"])
+	assert.Equal("facebase/data/skull_1.nii.gz", descriptor["path"])
+	assert.Equal(1024, descriptor["bytes"])
+	assert.Equal(fmt.Sprintf("%032x", 1), descriptor["hash"])
+	assert.Equal("Micro-CT scan of skull 1", descriptor["description"])
+	fileCredit := descriptor["credit"].(credit.CreditMetadata)
+	assert.Equal("FACEBASE:1-0001", fileCredit.Identifier)
+	assert.Equal("FaceBase Consortium", fileCredit.Publisher.OrganizationName)
+
+	_, err = db.Search("", databases.SearchParameters{
+		Query:    "skull",
+		Specific: map[string]any{"species": "mouse"},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+func TestDescriptors(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("facebase")
+	descriptors, err := db.Descriptors("", []string{"FACEBASE:1-0003", "FACEBASE:1-0001"})
+	assert.Nil(err)
+	assert.Equal(2, len(descriptors))
+	assert.Equal("FACEBASE:1-0003", descriptors[0]["id"])
+	assert.Equal("FACEBASE:1-0001", descriptors[1]["id"])
+
+	// embargoes are reported by databases with embargo fields
+	assert.Equal("2099-01-01", descriptors[0]["embargo_until"])
+	assert.NotContains(descriptors[1], "embargo_until")
+	assert.True(db.(databases.EmbargoingDatabase).ReportsEmbargoes())
+	mirrored, _ := NewDatabase("mirrored")
+	assert.False(mirrored.(databases.EmbargoingDatabase).ReportsEmbargoes())
+
+	_, err = db.Descriptors("", []string{"FACEBASE:1-0001", "FACEBASE:9-9999", "JDP:1-0001"})
+	assert.IsType(&databases.ResourcesNotFoundError{}, err)
+	assert.Equal([]string{"FACEBASE:9-9999", "JDP:1-0001"},
+		err.(*databases.ResourcesNotFoundError).ResourceIds)
+}
+
+func TestEndpointRules(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase("mirrored")
+	results, err := db.Search("", databases.SearchParameters{
+		Query:      "skull",
+		Pagination: databases.SearchPaginationParameters{Offset: 1, MaxNum: 2},
+	})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Descriptors))
+
+	// without an id_prefix, IDs are prefixed with the database name
+	assert.Equal("MIRRORED:1-0002", results.Descriptors[0]["id"])
+	assert.Equal("skull_2.nii.gz", results.Descriptors[0]["path"])
+	assert.Equal("mirror-https", results.Descriptors[0]["endpoint"])
+	assert.Equal("MIRRORED:1-0003", results.Descriptors[1]["id"])
+	assert.Equal("facebase/data/skull_3.nii.gz", results.Descriptors[1]["path"])
+	assert.Equal("facebase-https", results.Descriptors[1]["endpoint"])
+}
+
+func TestParentPathSegments(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{Name: "facebase"}
+	for _, fileURL := range []string{
+		"https://www.facebase.org/hatrac/../../etc/passwd",
+		"https://www.facebase.org/hatrac/%2e%2e/secrets.txt",
+	} {
+		_, err := db.fileDescriptor(map[string]any{"RID": "1-0001", "url": fileURL})
+		assert.IsType(&InvalidRecordError{}, err, fileURL)
+	}
+	descriptor, err := db.fileDescriptor(map[string]any{"RID": "1-0001",
+		"url": "https://www.facebase.org/hatrac/facebase/data/skull..1.nii.gz"})
+	assert.Nil(err)
+	assert.Equal("facebase/data/skull..1.nii.gz", descriptor["path"])
+}
+
+func TestURLsOutsideEndpointRoot(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{Name: "facebase"}
+	_, err := db.fileDescriptor(map[string]any{"RID": "1-0001",
+		"url": "https://elsewhere.example.org/hatrac/facebase/data/skull_1.nii.gz"})
+	assert.IsType(&InvalidRecordError{}, err)
+}
+
+func TestDirectories(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{Name: "facebase"}
+	descriptor, err := db.fileDescriptor(map[string]any{"RID": "1-0100",
+		"url": "https://www.facebase.org/hatrac/facebase/data/scans", "is_directory": true})
+	assert.Nil(err)
+	assert.Equal(true, descriptor["recursive"])
+	assert.NotContains(descriptor, "bytes")
+	assert.Nil(frictionless.ValidateFileDescriptor(descriptor))
+
+	descriptor, err = db.fileDescriptor(testRecords[0])
+	assert.Nil(err)
+	assert.NotContains(descriptor, "recursive")
+	assert.Equal(1024, descriptor["bytes"])
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, NewDatabaseFunc("facebase"), conformance.Parameters{
+		Query:    "skull",
+		PageSize: 5,
+	})
+}
+
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
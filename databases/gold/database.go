@@ -0,0 +1,570 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package gold
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/frictionless"
+)
+
+// The Genomes OnLine Database (GOLD) catalogs the studies, biosamples, and
+// sequencing projects behind the JGI's data, but holds no files itself. This
+// database searches GOLD for the sequencing projects identified by GOLD IDs
+// and serves the files the JGI Data Portal (JDP) holds for them, so users can
+// start from the GOLD identifiers they already know. Its files are JDP files
+// ("JDP:<id>"), and it delegates their staging to the JDP database, so it must
+// be configured alongside the JDP and share its endpoint(s).
+
+// file database appropriate for handling searches and transfers
+// (implements the databases.Database interface)
+type Database struct {
+	// HTTP client for GOLD API requests
+	Client http.Client
+}
+
+func NewDatabase() (databases.Database, error) {
+	// GOLD's files are JDP files, served from the JDP's endpoints
+	jdpConfig, found := config.Databases["jdp"]
+	if !found {
+		return nil, &databases.InvalidEndpointsError{
+			Database: "gold",
+			Message:  "GOLD serves JDP files, so the JDP database must also be configured",
+		}
+	}
+	goldConfig := config.Databases["gold"]
+	if goldConfig.Endpoint != jdpConfig.Endpoint || !maps.Equal(goldConfig.Endpoints, jdpConfig.Endpoints) {
+		return nil, &databases.InvalidEndpointsError{
+			Database: "gold",
+			Message:  "GOLD serves JDP files, so it must be configured with the JDP's endpoint(s)",
+		}
+	}
+
+	// make sure we have a GOLD API credential
+	if _, err := userCredential(); err != nil {
+		return nil, err
+	}
+
+	return &Database{
+		Client: databases.SecureHttpClient(time.Second * 30),
+	}, nil
+}
+
+func (db Database) SpecificSearchParameters() map[string]any {
+	return nil
+}
+
+// searches for the JDP files of the sequencing projects identified by the GOLD
+// IDs in the given query (a list of study, biosample, and/or project IDs
+// separated by commas or spaces)
+func (db *Database) Search(orcid string, params databases.SearchParameters) (databases.SearchResults, error) {
+	if len(params.Specific) > 0 {
+		return databases.SearchResults{}, &databases.InvalidSearchParameter{
+			Database: "GOLD",
+			Message:  "GOLD has no database-specific search parameters",
+		}
+	}
+	goldIds, err := parseGoldIds(params.Query)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+	fileIds, projectForFile, err := db.searchFileIds(orcid, goldIds)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+
+	// fetch descriptors for the requested page of files
+	start := min(params.Pagination.Offset, len(fileIds))
+	end := len(fileIds)
+	if params.Pagination.MaxNum > 0 {
+		end = min(start+params.Pagination.MaxNum, len(fileIds))
+	}
+	if start == end {
+		return databases.SearchResults{Descriptors: make([]map[string]any, 0)}, nil
+	}
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+	descriptors, err := jdp.Descriptors(orcid, fileIds[start:end])
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+
+	// cross-reference each file's GOLD study, biosample, and project
+	for _, descriptor := range descriptors {
+		if id, ok := descriptor["id"].(string); ok {
+			if project, found := projectForFile[id]; found {
+				frictionless.AddCrossReferences(descriptor, project.crossReferences()...)
+			}
+		}
+	}
+	return databases.SearchResults{
+		Descriptors: descriptors,
+	}, nil
+}
+
+// routes the database's requests to GOLD through the given trace
+func (db *Database) Trace(trace *databases.RequestTrace) {
+	db.Client.Transport = trace.Transport(db.Client.Transport)
+}
+
+func (db Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return jdp.Descriptors(orcid, fileIds)
+}
+
+// replaces any GOLD IDs ("GOLD:<id>", for a study, biosample, or project)
+// among the given file IDs with the IDs of the JDP files of the related
+// sequencing projects, passing other IDs (e.g. "JDP-PROJECT:<id>") to the JDP
+// to expand (implements databases.DatasetExpander)
+func (db Database) ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error) {
+	var jdpIds []string
+	expandedFileIds := make([]string, 0, len(fileIds))
+	datasets := make(map[string][]string)
+	for _, fileId := range fileIds {
+		goldId, isGoldId := strings.CutPrefix(fileId, idPrefix)
+		if !isGoldId {
+			jdpIds = append(jdpIds, fileId)
+			continue
+		}
+		goldIds, err := parseGoldIds(goldId)
+		if err != nil || len(goldIds) > 1 {
+			return nil, nil, &databases.ResourcesNotFoundError{
+				Database:    "GOLD",
+				ResourceIds: []string{fileId},
+			}
+		}
+		projects, err := db.projectsWithIds(goldIds)
+		if err != nil {
+			return nil, nil, err
+		}
+		datasetFileIds, _, err := jdpFileIds(orcid, projects)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(datasetFileIds) == 0 {
+			return nil, nil, &databases.ResourcesNotFoundError{
+				Database:    "GOLD",
+				ResourceIds: []string{fileId},
+			}
+		}
+		datasets[fileId] = datasetFileIds
+		expandedFileIds = append(expandedFileIds, datasetFileIds...)
+	}
+
+	// let the JDP expand its own dataset IDs
+	if len(jdpIds) > 0 {
+		jdp, err := jdpDatabase()
+		if err != nil {
+			return nil, nil, err
+		}
+		if expander, ok := jdp.(databases.DatasetExpander); ok {
+			var jdpDatasets map[string][]string
+			jdpIds, jdpDatasets, err = expander.ExpandFileIds(orcid, jdpIds, instructions)
+			if err != nil {
+				return nil, nil, err
+			}
+			maps.Copy(datasets, jdpDatasets)
+		}
+		expandedFileIds = append(expandedFileIds, jdpIds...)
+	}
+
+	// remove duplicates, preserving order
+	encountered := make(map[string]struct{})
+	expandedFileIds = slices.DeleteFunc(expandedFileIds, func(fileId string) bool {
+		_, found := encountered[fileId]
+		encountered[fileId] = struct{}{}
+		return found
+	})
+	return expandedFileIds, datasets, nil
+}
+
+// estimates the tape recalls needed to stage the given JDP files
+// (implements databases.TapeRecallEstimator)
+func (db Database) EstimateTapeRecall(orcid string, fileIds []string) (databases.TapeRecallEstimate, error) {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return databases.TapeRecallEstimate{}, err
+	}
+	if estimator, ok := jdp.(databases.TapeRecallEstimator); ok {
+		return estimator.EstimateTapeRecall(orcid, fileIds)
+	}
+	return databases.TapeRecallEstimate{}, nil
+}
+
+func (db Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
+	// the JDP stages its own files
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return jdp.StageFiles(orcid, fileIds)
+}
+
+func (db Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return databases.StagingStatusUnknown, err
+	}
+	return jdp.StagingStatus(id)
+}
+
+// returns the position of the given staging request in the JDP's queue
+// (implements databases.QueueingDatabase)
+func (db Database) StagingQueuePosition(id uuid.UUID) int {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return 0
+	}
+	if queue, ok := jdp.(databases.QueueingDatabase); ok {
+		return queue.StagingQueuePosition(id)
+	}
+	return 0
+}
+
+func (db *Database) Finalize(orcid string, id uuid.UUID) error {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return err
+	}
+	return jdp.Finalize(orcid, id)
+}
+
+func (db Database) LocalUser(orcid string) (string, error) {
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return "", err
+	}
+	return jdp.LocalUser(orcid)
+}
+
+func (db Database) Save() (databases.DatabaseSaveState, error) {
+	// the JDP saves the state of staging requests
+	return databases.DatabaseSaveState{
+		Name: "gold",
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	// no internal state -> nothing to do
+	return nil
+}
+
+//====================
+// Internal machinery
+//====================
+
+// the base URL of the GOLD API
+// (see https://gold-ws.jgi.doe.gov/swagger-ui/index.html)
+var baseApiURL = "https://gold-ws.jgi.doe.gov/api/v1/"
+
+// the prefix of GOLD IDs standing in for the files of related projects in
+// transfer requests
+const idPrefix = "GOLD:"
+
+// returns the JDP database, which holds GOLD's files
+func jdpDatabase() (databases.Database, error) {
+	return databases.NewDatabase("jdp")
+}
+
+// returns the GOLD IDs (e.g. "Gs0110115", "Gb0115663", "Gp0115663") in the
+// given list, separated by commas or spaces
+func parseGoldIds(query string) ([]string, error) {
+	var goldIds []string
+	for _, goldId := range strings.FieldsFunc(query, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\t' || c == '\n'
+	}) {
+		goldId = strings.TrimPrefix(goldId, "gold:") // accept CURIEs
+		if len(goldId) < 3 || !strings.ContainsRune("sbp", rune(goldId[1])) ||
+			!strings.HasPrefix(goldId, "G") {
+			return nil, &databases.InvalidSearchParameter{
+				Database: "GOLD",
+				Message: fmt.Sprintf("'%s' is not a GOLD study, biosample, or project ID (Gs..., Gb..., Gp...)",
+					goldId),
+			}
+		}
+		if !slices.Contains(goldIds, goldId) {
+			goldIds = append(goldIds, goldId)
+		}
+	}
+	if len(goldIds) == 0 {
+		return nil, &databases.InvalidSearchParameter{
+			Database: "GOLD",
+			Message:  "A GOLD search requires one or more GOLD study, biosample, or project IDs",
+		}
+	}
+	return goldIds, nil
+}
+
+// the JDP files found by a search, cached so that paging through its results
+// doesn't repeat the GOLD and JDP searches that found them
+type searchFiles struct {
+	FileIds        []string
+	ProjectForFile map[string]Project
+	Time           time.Time // time at which the files were found
+}
+
+// the time for which the files found by a search are cached
+var searchFilesLifetime = 10 * time.Minute
+
+// files found by searches, keyed by ORCID and GOLD IDs, and a mutex that
+// guards them
+var searchFiles_ = make(map[string]searchFiles)
+var searchFilesMutex_ sync.Mutex
+
+// returns the IDs of the JDP files (visible to the user with the given ORCID)
+// of the sequencing projects identified by the given GOLD IDs, in order, along
+// with a mapping of each file ID to its project, reusing those found by the
+// same search within the last searchFilesLifetime
+func (db *Database) searchFileIds(orcid string, goldIds []string) ([]string, map[string]Project, error) {
+	key := orcid + " " + strings.Join(goldIds, ",")
+	searchFilesMutex_.Lock()
+	cached, found := searchFiles_[key]
+	searchFilesMutex_.Unlock()
+	if found && time.Since(cached.Time) < searchFilesLifetime {
+		return cached.FileIds, cached.ProjectForFile, nil
+	}
+
+	projects, err := db.projectsWithIds(goldIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileIds, projectForFile, err := jdpFileIds(orcid, projects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	searchFilesMutex_.Lock()
+	defer searchFilesMutex_.Unlock()
+	maps.DeleteFunc(searchFiles_, func(_ string, files searchFiles) bool {
+		return time.Since(files.Time) >= searchFilesLifetime
+	})
+	searchFiles_[key] = searchFiles{
+		FileIds:        fileIds,
+		ProjectForFile: projectForFile,
+		Time:           time.Now(),
+	}
+	return fileIds, projectForFile, nil
+}
+
+// returns the IDs of the JDP files (visible to the user with the given ORCID)
+// of the given sequencing projects, in order, along with a mapping of each
+// file ID to its project
+func jdpFileIds(orcid string, projects []Project) ([]string, map[string]Project, error) {
+	projectForFile := make(map[string]Project)
+	if len(projects) == 0 {
+		return []string{}, projectForFile, nil
+	}
+	jdp, err := jdpDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+	resolver, isResolver := jdp.(databases.CrossReferenceResolver)
+	expander, isExpander := jdp.(databases.DatasetExpander)
+	if !isResolver || !isExpander {
+		return nil, nil, fmt.Errorf("the JDP database can't find the files of GOLD projects")
+	}
+
+	// find the JDP projects for the sequencing projects
+	xrefs := make([]string, len(projects))
+	for i, project := range projects {
+		xrefs[i] = frictionless.CURIE("gold", project.ProjectGoldId)
+	}
+	jdpProjectIds, err := resolver.ResolveCrossReferences(orcid, xrefs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// gather their files
+	var fileIds []string
+	for i, project := range projects {
+		for _, jdpProjectId := range jdpProjectIds[xrefs[i]] {
+			_, datasets, err := expander.ExpandFileIds(orcid, []string{jdpProjectId}, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, fileId := range datasets[jdpProjectId] {
+				if _, found := projectForFile[fileId]; !found {
+					fileIds = append(fileIds, fileId)
+					projectForFile[fileId] = project
+				}
+			}
+		}
+	}
+	return fileIds, projectForFile, nil
+}
+
+//-----------------------------
+// Access to GOLD API endpoints
+//-----------------------------
+
+type credential struct {
+	User, Password string
+}
+
+// returns the GOLD API user credential, obtained from the credential configured
+// for the database or (if none is configured) from environment variables
+func userCredential() (credential, error) {
+	if credentialName := config.Databases["gold"].Credential; credentialName != "" {
+		apiCredential, err := credentials.Get(credentialName)
+		if err != nil {
+			return credential{}, err
+		}
+		return credential{User: apiCredential.Id, Password: apiCredential.Secret}, nil
+	}
+	goldUser, haveGoldUser := os.LookupEnv("DTS_GOLD_USER")
+	if !haveGoldUser {
+		return credential{}, &databases.UnauthorizedError{
+			Database: "gold",
+			Message:  "No GOLD API user (DTS_GOLD_USER) was provided for authentication",
+		}
+	}
+	goldPassword, haveGoldPassword := os.LookupEnv("DTS_GOLD_PASSWORD")
+	if !haveGoldPassword {
+		return credential{}, &databases.UnauthorizedError{
+			Database: "gold",
+			Message:  "No GOLD API password (DTS_GOLD_PASSWORD) was provided for authentication",
+		}
+	}
+	return credential{User: goldUser, Password: goldPassword}, nil
+}
+
+// performs a GET request on the given resource, returning the resulting
+// response body and/or error
+func (db Database) get(resource string, values url.Values) ([]byte, error) {
+	res, err := url.Parse(baseApiURL)
+	if err != nil {
+		return nil, err
+	}
+	res = res.JoinPath(resource)
+	res.RawQuery = values.Encode()
+	slog.Debug(fmt.Sprintf("GET: %s", res.String()))
+	req, err := http.NewRequest(http.MethodGet, res.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	apiCredential, err := userCredential() // (picks up rotated credentials)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(apiCredential.User, apiCredential.Password)
+	resp, err := db.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return io.ReadAll(resp.Body)
+	case 401, 403:
+		return nil, &databases.UnauthorizedError{
+			Database: "gold",
+			Message:  "GOLD rejected the API credential",
+		}
+	case 404: // no matching records
+		return []byte("[]"), nil
+	case 503:
+		return nil, &databases.UnavailableError{
+			Database: "gold",
+		}
+	default:
+		return nil, fmt.Errorf("an error occurred with the GOLD database (%d)",
+			resp.StatusCode)
+	}
+}
+
+// fetches the sequencing projects identified by the given GOLD IDs: the
+// projects themselves, those of biosamples, and those of studies
+func (db Database) projectsWithIds(goldIds []string) ([]Project, error) {
+	var projects []Project
+	for _, goldId := range goldIds {
+		var field string
+		switch goldId[1] {
+		case 's':
+			field = "studyGoldId"
+		case 'b':
+			field = "biosampleGoldId"
+		default:
+			field = "projectGoldId"
+		}
+		body, err := db.get("projects", url.Values{field: {goldId}})
+		if err != nil {
+			return nil, err
+		}
+		var idProjects []Project
+		if err := json.Unmarshal(body, &idProjects); err != nil {
+			return nil, err
+		}
+		for _, project := range idProjects {
+			if !slices.ContainsFunc(projects, func(p Project) bool {
+				return p.ProjectGoldId == project.ProjectGoldId
+			}) {
+				projects = append(projects, project)
+			}
+		}
+	}
+	return projects, nil
+}
+
+//----------------
+// Metadata types
+//----------------
+
+// a (partial) GOLD sequencing project record
+type Project struct {
+	ProjectGoldId   string `json:"projectGoldId"`
+	ProjectName     string `json:"projectName"`
+	StudyGoldId     string `json:"studyGoldId"`
+	BiosampleGoldId string `json:"biosampleGoldId"`
+}
+
+// returns cross-references (CURIEs) to the project and its study and biosample
+func (project Project) crossReferences() []string {
+	var xrefs []string
+	for _, id := range []string{project.ProjectGoldId, project.StudyGoldId, project.BiosampleGoldId} {
+		if id != "" {
+			xrefs = append(xrefs, frictionless.CURIE("gold", id))
+		}
+	}
+	return xrefs
+}
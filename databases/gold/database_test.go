@@ -0,0 +1,308 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package gold
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/conformance"
+	"github.com/kbase/dts/frictionless"
+)
+
+// we test our GOLD database against a stand-in for the GOLD API that serves a
+// study with three sequencing projects, and a stand-in for the JDP that holds
+// files for two of them
+
+const goldConfig string = `
+databases:
+  jdp:
+    name: JGI Data Portal
+    organization: Joint Genome Institute
+    endpoint: jdp-https
+  gold:
+    name: Genomes OnLine Database
+    organization: Joint Genome Institute
+    endpoint: jdp-https
+    credential: gold
+credentials:
+  gold:
+    id: gold-user
+    secret: gold-password
+endpoints:
+  jdp-https:
+    name: JDP downloads
+    id: 6f1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d
+    provider: https
+    root: https://data.jgi.doe.gov/
+`
+
+var server *httptest.Server
+
+// sequencing projects served by the test server
+var testProjects = []Project{
+	{ProjectGoldId: "Gp0000001", ProjectName: "Soil metagenome 1", StudyGoldId: "Gs0000100", BiosampleGoldId: "Gb0000001"},
+	{ProjectGoldId: "Gp0000002", ProjectName: "Soil metagenome 2", StudyGoldId: "Gs0000100", BiosampleGoldId: "Gb0000002"},
+	{ProjectGoldId: "Gp0000003", ProjectName: "Soil metagenome 3", StudyGoldId: "Gs0000100", BiosampleGoldId: "Gb0000002"},
+}
+
+// the number of requests made to the test server
+var numRequests atomic.Int32
+
+// handles requests to the test server
+func serveApi(w http.ResponseWriter, r *http.Request) {
+	numRequests.Add(1)
+	if user, password, ok := r.BasicAuth(); !ok || user != "gold-user" || password != "gold-password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/projects" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	var projects []Project
+	for _, project := range testProjects {
+		if project.StudyGoldId == query.Get("studyGoldId") ||
+			project.BiosampleGoldId == query.Get("biosampleGoldId") ||
+			project.ProjectGoldId == query.Get("projectGoldId") {
+			projects = append(projects, project)
+		}
+	}
+	if len(projects) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(projects)
+}
+
+// a stand-in for the JDP database, holding files for the first two projects
+type jdpStandIn struct{}
+
+// JDP projects (and their numbers of files) for GOLD projects
+var jdpProjects = map[string]struct {
+	Id       string
+	NumFiles int
+}{
+	"gold:Gp0000001": {"1001", 6},
+	"gold:Gp0000002": {"1002", 5},
+}
+
+func (db jdpStandIn) SpecificSearchParameters() map[string]any { return nil }
+
+func (db jdpStandIn) Search(orcid string, params databases.SearchParameters) (databases.SearchResults, error) {
+	return databases.SearchResults{}, nil
+}
+
+func (db jdpStandIn) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
+	descriptors := make([]map[string]any, len(fileIds))
+	for i, fileId := range fileIds {
+		descriptors[i] = map[string]any{
+			"id":   fileId,
+			"name": strings.ToLower(strings.ReplaceAll(fileId, ":", "-")),
+			"path": strings.TrimPrefix(fileId, "JDP:") + ".fastq.gz",
+		}
+	}
+	return descriptors, nil
+}
+
+func (db jdpStandIn) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (db jdpStandIn) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db jdpStandIn) Finalize(orcid string, id uuid.UUID) error { return nil }
+
+func (db jdpStandIn) LocalUser(orcid string) (string, error) { return "localuser", nil }
+
+func (db jdpStandIn) Save() (databases.DatabaseSaveState, error) {
+	return databases.DatabaseSaveState{Name: "jdp"}, nil
+}
+
+func (db jdpStandIn) Load(state databases.DatabaseSaveState) error { return nil }
+
+func (db jdpStandIn) ResolveCrossReferences(orcid string, xrefs []string) (map[string][]string, error) {
+	resolved := make(map[string][]string)
+	for _, xref := range xrefs {
+		if project, found := jdpProjects[xref]; found {
+			resolved[xref] = []string{"JDP-PROJECT:" + project.Id}
+		}
+	}
+	return resolved, nil
+}
+
+func (db jdpStandIn) ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error) {
+	var expandedFileIds []string
+	datasets := make(map[string][]string)
+	for _, fileId := range fileIds {
+		projectId, isProject := strings.CutPrefix(fileId, "JDP-PROJECT:")
+		if !isProject {
+			expandedFileIds = append(expandedFileIds, fileId)
+			continue
+		}
+		for _, project := range jdpProjects {
+			if project.Id == projectId {
+				for i := range project.NumFiles {
+					datasets[fileId] = append(datasets[fileId], fmt.Sprintf("JDP:%s-%d", projectId, i+1))
+				}
+			}
+		}
+		expandedFileIds = append(expandedFileIds, datasets[fileId]...)
+	}
+	return expandedFileIds, datasets, nil
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	server = httptest.NewServer(http.HandlerFunc(serveApi))
+	baseApiURL = server.URL + "/"
+	err := config.InitSelected([]byte(goldConfig), false, true, true, true)
+	if err != nil {
+		panic(err)
+	}
+	databases.RegisterDatabase("jdp", func() (databases.Database, error) { return jdpStandIn{}, nil })
+	databases.RegisterDatabase("gold", NewDatabase)
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	server.Close()
+}
+
+func TestNewDatabase(t *testing.T) {
+	assert := assert.New(t)
+	db, err := NewDatabase()
+	assert.NotNil(db, "GOLD database not created")
+	assert.Nil(err, "GOLD database creation encountered an error")
+}
+
+// tests searches for the JDP files of GOLD studies, biosamples, and projects
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+
+	// a study's projects' files are found in the JDP
+	results, err := db.Search("", databases.SearchParameters{Query: "Gs0000100"})
+	assert.Nil(err)
+	assert.Len(results.Descriptors, 11)
+	assert.Equal("JDP:1001-1", results.Descriptors[0]["id"])
+	assert.Equal([]string{"gold:Gp0000001", "gold:Gs0000100", "gold:Gb0000001"},
+		frictionless.CrossReferences(results.Descriptors[0]))
+	assert.Equal("JDP:1002-1", results.Descriptors[6]["id"])
+
+	// so are those of biosamples and projects (given as IDs or CURIEs)
+	results, err = db.Search("", databases.SearchParameters{Query: "Gb0000002, gold:Gp0000001"})
+	assert.Nil(err)
+	assert.Len(results.Descriptors, 11)
+	assert.Equal("JDP:1002-1", results.Descriptors[0]["id"])
+
+	// pages of a search's results reuse the files it found
+	numRequests.Store(0)
+	results, err = db.Search("", databases.SearchParameters{
+		Query:      "Gs0000100",
+		Pagination: databases.SearchPaginationParameters{Offset: 6, MaxNum: 3},
+	})
+	assert.Nil(err)
+	assert.Len(results.Descriptors, 3)
+	assert.Equal("JDP:1002-1", results.Descriptors[0]["id"])
+	assert.Equal(int32(0), numRequests.Load())
+
+	// projects without JDP files yield nothing
+	results, err = db.Search("", databases.SearchParameters{Query: "Gp0000003 Gp0000004"})
+	assert.Nil(err)
+	assert.Empty(results.Descriptors)
+
+	// queries must consist of GOLD IDs
+	_, err = db.Search("", databases.SearchParameters{Query: "soil"})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+	_, err = db.Search("", databases.SearchParameters{})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+	_, err = db.Search("", databases.SearchParameters{
+		Query:    "Gs0000100",
+		Specific: map[string]any{"color": "blue"},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+func TestExpandFileIds(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := NewDatabase()
+
+	fileIds, datasets, err := db.(databases.DatasetExpander).ExpandFileIds("", []string{
+		"GOLD:Gb0000002",
+		"JDP:1002-1",
+		"JDP-PROJECT:1001",
+	}, nil)
+	assert.Nil(err)
+	assert.Len(fileIds, 11)
+	assert.Equal("JDP:1002-1", fileIds[0])
+	assert.True(slices.Contains(fileIds, "JDP:1001-6"))
+	assert.Len(datasets["GOLD:Gb0000002"], 5)
+	assert.Len(datasets["JDP-PROJECT:1001"], 6)
+
+	for _, fileId := range []string{"GOLD:Gp0000003", "GOLD:soil"} {
+		_, _, err = db.(databases.DatasetExpander).ExpandFileIds("", []string{fileId}, nil)
+		assert.IsType(&databases.ResourcesNotFoundError{}, err)
+	}
+}
+
+func TestParseGoldIds(t *testing.T) {
+	assert := assert.New(t)
+	ids, err := parseGoldIds("Gs0110115,Gb0115663 gold:Gp0115663, Gs0110115")
+	assert.Nil(err)
+	assert.Equal([]string{"Gs0110115", "Gb0115663", "Gp0115663"}, ids)
+
+	for _, query := range []string{"", " , ", "Ga0115663", "JDP:1234", "G"} {
+		_, err = parseGoldIds(query)
+		assert.IsType(&databases.InvalidSearchParameter{}, err, query)
+	}
+}
+
+// runs the database conformance suite against GOLD
+func TestConformance(t *testing.T) {
+	conformance.Run(t, NewDatabase, conformance.Parameters{
+		Query:    "Gs0000100",
+		PageSize: 5,
+	})
+}
+
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
@@ -27,13 +27,19 @@ import (
 	"time"
 
 	"github.com/StalkR/hsts"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/tracing"
 )
 
 // Here's a secure HTTP client that can be used to connect to databases. It
-// sets a reasonable timeout and enables HTTP Strict Transport Security (HSTS).
+// sets a reasonable timeout and enables HTTP Strict Transport Security (HSTS),
+// restricting TLS configurations to FIPS-approved choices in FIPS mode, and
+// traces its requests when tracing is enabled.
 func SecureHttpClient(timeout time.Duration) http.Client {
 	client := http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: tracing.Transport(config.HttpTransport()),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if req.URL.Scheme == "http" {
 				return &DowngradedRedirectError{
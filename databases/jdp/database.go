@@ -28,7 +28,6 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -36,14 +35,17 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-	"unicode"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
 	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
 )
 
 // file database appropriate for handling JDP searches and transfers
@@ -53,22 +55,43 @@ type Database struct {
 	Client http.Client
 	// shared secret used for authentication
 	Secret string
-	// mapping from staging UUIDs to JDP restoration request ID
+	// mapping from staging UUIDs to JDP restoration request ID, and a mutex
+	// that guards it
 	StagingRequests map[uuid.UUID]StagingRequest
+	stagingMutex    sync.Mutex
 }
 
 type StagingRequest struct {
-	// JDP staging request ID
+	// JDP staging request ID (0 if the request is queued)
 	Id int
-	// time of staging request (for purging)
+	// time of staging request (for purging and queueing)
 	Time time.Time
+	// ORCID of the user on whose behalf files are staged
+	Orcid string
+	// IDs of the files to be staged (retained only while queued)
+	FileIds []string
+	// set once the JDP has restored the requested files
+	Ready bool
+	// set if a queued request couldn't be submitted
+	Failed bool
 }
 
 func NewDatabase() (databases.Database, error) {
-	// make sure we have a shared secret or an SSO token
-	secret, haveSecret := os.LookupEnv("DTS_JDP_SECRET")
-	if !haveSecret { // check for SSO token
-		return nil, fmt.Errorf("no shared secret was found for JDP authentication")
+	// make sure we have a shared secret or an SSO token (from a configured
+	// credential, if given)
+	var secret string
+	if credentialName := config.Databases["jdp"].Credential; credentialName != "" {
+		credential, err := credentials.Get(credentialName)
+		if err != nil {
+			return nil, err
+		}
+		secret = credential.Secret
+	} else {
+		var haveSecret bool
+		secret, haveSecret = os.LookupEnv("DTS_JDP_SECRET")
+		if !haveSecret { // check for SSO token
+			return nil, fmt.Errorf("no shared secret was found for JDP authentication")
+		}
 	}
 
 	// make sure we are using only a single endpoint
@@ -89,7 +112,7 @@ func NewDatabase() (databases.Database, error) {
 	}, nil
 }
 
-func (db Database) SpecificSearchParameters() map[string]any {
+func (db *Database) SpecificSearchParameters() map[string]any {
 	return map[string]any{
 		// see https://files.jgi.doe.gov/apidoc/#/GET/search_list
 		"d": []string{"asc", "desc"}, // sort direction (ascending/descending)
@@ -97,7 +120,8 @@ func (db Database) SpecificSearchParameters() map[string]any {
 			"img_taxon_oid"},
 		"include_private_data": []int{0, 1},                                             // flag to include private data
 		"s":                    []string{"name", "id", "title", "kingdom", "score.avg"}, // sort order
-		"extra":                []string{"img_taxon_oid", "project_id"},                 // list of requested extra fields
+		"extra": []string{"img_taxon_oid", "project_id", // list of requested extra fields
+			"sequencing_strategy", "its_proposal_id", "analysis_project_id", "genome_type"},
 	}
 }
 
@@ -142,6 +166,38 @@ func (db *Database) Search(orcid string, params databases.SearchParameters) (dat
 	}, err
 }
 
+// routes the database's requests to the JDP through the given trace
+func (db *Database) Trace(trace *databases.RequestTrace) {
+	db.Client.Transport = trace.Transport(db.Client.Transport)
+}
+
+// translates a DTS query into the ElasticSearch query string syntax accepted
+// by the JDP's "q" search parameter
+func (db *Database) TranslateQuery(query databases.QueryNode) (string, error) {
+	if query.Operator != "" {
+		operands := make([]string, len(query.Operands))
+		for i, operand := range query.Operands {
+			translated, err := db.TranslateQuery(operand)
+			if err != nil {
+				return "", err
+			}
+			if operand.Operator != "" {
+				translated = "(" + translated + ")"
+			}
+			operands[i] = translated
+		}
+		return strings.Join(operands, " "+query.Operator+" "), nil
+	}
+	value := query.Value
+	if query.Phrase || strings.ContainsAny(value, `+-=&|><!(){}[]^~*?:\/`) {
+		value = strconv.Quote(value)
+	}
+	if query.Field != "" {
+		return query.Field + ":" + value, nil
+	}
+	return value, nil
+}
+
 func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
 	// strip the "JDP:" prefix from our files and create a mapping from IDs to
 	// their original order so we can hand back metadata accordingly
@@ -152,21 +208,7 @@ func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]an
 		indexForId[strippedFileIds[i]] = i
 	}
 
-	type MetadataRequest struct {
-		Ids                []string `json:"ids"`
-		Aggregations       bool     `json:"aggregations"`
-		IncludePrivateData int      `json:"include_private_data"`
-	}
-	data, err := json.Marshal(MetadataRequest{
-		Ids:                strippedFileIds,
-		Aggregations:       true,
-		IncludePrivateData: 1,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := db.post("search/by_file_ids/", orcid, bytes.NewReader(data))
+	body, err := db.fetchFileMetadata(orcid, strippedFileIds)
 	if err != nil {
 		return nil, err
 	}
@@ -207,9 +249,102 @@ func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]an
 	return descriptors, nil
 }
 
+// estimates the tape recalls needed to stage the files with the given IDs,
+// based on their JDP file status (implements databases.TapeRecallEstimator)
+func (db *Database) EstimateTapeRecall(orcid string, fileIds []string) (databases.TapeRecallEstimate, error) {
+	strippedFileIds := make([]string, len(fileIds))
+	for i, fileId := range fileIds {
+		strippedFileIds[i] = strings.TrimPrefix(fileId, "JDP:")
+	}
+	body, err := db.fetchFileMetadata(orcid, strippedFileIds)
+	if err != nil {
+		return databases.TapeRecallEstimate{}, err
+	}
+
+	type JDPResults struct {
+		Organisms []Organism `json:"organisms"`
+	}
+	var jdpResults JDPResults
+	err = json.Unmarshal(body, &jdpResults)
+	if err != nil {
+		return databases.TapeRecallEstimate{}, err
+	}
+	files := make([]File, 0)
+	for _, org := range jdpResults.Organisms {
+		files = append(files, org.Files...)
+	}
+	return tapeRecallEstimate(files), nil
+}
+
+// replaces any JDP project IDs ("JDP-PROJECT:<id>") among the given file IDs
+// with the IDs of the files in those projects (implements
+// databases.DatasetExpander)
+func (db *Database) ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error) {
+	expandedFileIds := make([]string, 0, len(fileIds))
+	projects := make(map[string][]string)
+	encountered := make(map[string]struct{})
+	for _, fileId := range fileIds {
+		projectId, isProject := strings.CutPrefix(fileId, projectIdPrefix)
+		if !isProject {
+			if _, found := encountered[fileId]; !found {
+				expandedFileIds = append(expandedFileIds, fileId)
+				encountered[fileId] = struct{}{}
+			}
+			continue
+		}
+		projectFileIds, err := db.projectFileIds(orcid, projectId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(projectFileIds) == 0 {
+			return nil, nil, &databases.ResourcesNotFoundError{
+				Database:    "JDP",
+				ResourceIds: []string{fileId},
+			}
+		}
+		projects[fileId] = projectFileIds
+		for _, projectFileId := range projectFileIds {
+			if _, found := encountered[projectFileId]; !found {
+				expandedFileIds = append(expandedFileIds, projectFileId)
+				encountered[projectFileId] = struct{}{}
+			}
+		}
+	}
+	return expandedFileIds, projects, nil
+}
+
 func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
-	var xferId uuid.UUID
+	db.stagingMutex.Lock()
+	defer db.stagingMutex.Unlock()
+	db.pruneStagingRequests()
 
+	// if the user has as many restoration requests underway as allowed, queue
+	// this one until one of them completes
+	request := StagingRequest{
+		Time:  time.Now(),
+		Orcid: orcid,
+	}
+	xferId := uuid.New()
+	if limit := config.Databases["jdp"].MaxStagingRequests; limit > 0 && db.outstandingRequests(orcid) >= limit {
+		request.FileIds = fileIds
+		db.StagingRequests[xferId] = request
+		slog.Debug(fmt.Sprintf("Queued request for %d archived files from JDP (%d restoration requests underway)",
+			len(fileIds), limit))
+		return xferId, nil
+	}
+
+	var err error
+	request.Id, err = db.requestRestoration(orcid, fileIds)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	db.StagingRequests[xferId] = request
+	return xferId, nil
+}
+
+// requests the restoration of the archived files with the given IDs on behalf
+// of the user with the given ORCID, returning the JDP's ID for the request
+func (db *Database) requestRestoration(orcid string, fileIds []string) (int, error) {
 	// construct a POST request to restore archived files with the given IDs
 	type RestoreRequest struct {
 		Ids                []string `json:"ids"`
@@ -233,7 +368,7 @@ func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error
 		IncludePrivateData: 1, // we need this just in case!
 	})
 	if err != nil {
-		return xferId, err
+		return 0, err
 	}
 
 	// NOTE: The slash in the resource is all-important for POST requests to
@@ -244,7 +379,7 @@ func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error
 		case *databases.ResourcesNotFoundError:
 			e.ResourceIds = fileIds
 		}
-		return xferId, err
+		return 0, err
 	}
 
 	type RestoreResponse struct {
@@ -254,21 +389,25 @@ func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error
 	var jdpResp RestoreResponse
 	err = json.Unmarshal(body, &jdpResp)
 	if err != nil {
-		return xferId, err
+		return 0, err
 	}
 	slog.Debug(fmt.Sprintf("Requested %d archived files from JDP (request ID: %d)",
 		len(fileIds), jdpResp.RequestId))
-	xferId = uuid.New()
-	db.StagingRequests[xferId] = StagingRequest{
-		Id:   jdpResp.RequestId,
-		Time: time.Now(),
-	}
-	return xferId, err
+	return jdpResp.RequestId, nil
 }
 
 func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	db.stagingMutex.Lock()
+	defer db.stagingMutex.Unlock()
 	db.pruneStagingRequests()
+	db.submitQueuedRequests()
 	if request, found := db.StagingRequests[id]; found {
+		if request.Failed {
+			return databases.StagingStatusFailed, nil
+		}
+		if request.Id == 0 { // still queued
+			return databases.StagingStatusActive, nil
+		}
 		resource := fmt.Sprintf("request_archived_files/requests/%d", request.Id)
 		body, err := db.get(resource, url.Values{})
 		if err != nil {
@@ -288,6 +427,11 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 			"ready":   databases.StagingStatusSucceeded,
 		}
 		if status, ok := statusForString[jdpResult.Status]; ok {
+			if status == databases.StagingStatusSucceeded && !request.Ready { // free up a slot
+				request.Ready = true
+				db.StagingRequests[id] = request
+				db.submitQueuedRequests()
+			}
 			return status, nil
 		}
 		return databases.StagingStatusUnknown, fmt.Errorf("unrecognized staging status string: %s", jdpResult.Status)
@@ -296,6 +440,22 @@ func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error)
 	}
 }
 
+func (db *Database) StagingQueuePosition(id uuid.UUID) int {
+	db.stagingMutex.Lock()
+	defer db.stagingMutex.Unlock()
+	request, found := db.StagingRequests[id]
+	if !found || request.Id != 0 || request.Failed {
+		return 0
+	}
+	position := 1
+	for _, other := range db.StagingRequests {
+		if other.Orcid == request.Orcid && other.Id == 0 && !other.Failed && other.Time.Before(request.Time) {
+			position++
+		}
+	}
+	return position
+}
+
 func (db *Database) Finalize(orcid string, id uuid.UUID) error {
 	return nil
 }
@@ -305,7 +465,9 @@ func (db *Database) LocalUser(orcid string) (string, error) {
 	return "localuser", nil
 }
 
-func (db Database) Save() (databases.DatabaseSaveState, error) {
+func (db *Database) Save() (databases.DatabaseSaveState, error) {
+	db.stagingMutex.Lock()
+	defer db.stagingMutex.Unlock()
 	var buffer bytes.Buffer
 	enc := gob.NewEncoder(&buffer)
 	err := enc.Encode(db.StagingRequests)
@@ -319,6 +481,8 @@ func (db Database) Save() (databases.DatabaseSaveState, error) {
 }
 
 func (db *Database) Load(state databases.DatabaseSaveState) error {
+	db.stagingMutex.Lock()
+	defer db.stagingMutex.Unlock()
 	enc := gob.NewDecoder(bytes.NewReader(state.Data))
 	return enc.Decode(&db.StagingRequests)
 }
@@ -332,59 +496,89 @@ const (
 	filePathPrefix = "/global/dna/dm_archive/" // directory containing JDP files
 )
 
-// a mapping from file suffixes to format labels
-var suffixToFormat = map[string]string{
-	"bam":      "bam",
-	"bam.bai":  "bai",
-	"blasttab": "blast",
-	"bz":       "bzip",
-	"bz2":      "bzip2",
-	"csv":      "csv",
-	"faa":      "fasta",
-	"fasta":    "fasta",
-	"fasta.gz": "fasta",
-	"fastq":    "fastq",
-	"fastq.gz": "fastq",
-	"fna":      "fasta",
-	"gff":      "gff",
-	"gff3":     "gff3",
-	"gz":       "gz",
-	"html":     "html",
-	"info":     "texinfo",
-	"out":      "text",
-	"pdf":      "pdf",
-	"tar":      "tar",
-	"tar.gz":   "tar",
-	"tar.bz":   "tar",
-	"tar.bz2":  "tar",
-	"tsv":      "tsv",
-	"txt":      "text",
-}
-
-// this gets populated automatically with the keys in suffixToFormat
-var supportedSuffixes []string
-
-// extracts the file format from the name and type of the file
-func formatFromFileName(fileName string) string {
-	// make a list of the supported suffixes if we haven't yet
-	if supportedSuffixes == nil {
-		supportedSuffixes = make([]string, 0)
-		for suffix := range suffixToFormat {
-			supportedSuffixes = append(supportedSuffixes, suffix)
-		}
-	}
-
-	// determine whether the file matches any of the supported suffixes,
-	// selecting the longest matching suffix
-	format := "unknown"
-	longestSuffix := 0
-	for _, suffix := range supportedSuffixes {
-		if strings.HasSuffix(fileName, suffix) && len(suffix) > longestSuffix {
-			format = suffixToFormat[suffix]
-			longestSuffix = len(suffix)
-		}
-	}
-	return format
+// rough hints for the time needed to recall files from JGI's HPSS tape archive:
+// each recall request pays a fixed latency for mounting and positioning tapes,
+// after which files stream at a modest aggregate rate
+const (
+	tapeRecallLatency    = 10 * time.Minute
+	tapeRecallThroughput = 100 * 1024 * 1024 // bytes per second
+)
+
+// estimates the tape recalls needed to stage the given files, which reside on
+// tape if the JDP has purged them from disk
+func tapeRecallEstimate(files []File) databases.TapeRecallEstimate {
+	var estimate databases.TapeRecallEstimate
+	for _, file := range files {
+		if file.Status == "PURGED" {
+			estimate.NumFiles++
+			estimate.Bytes += int64(file.Size)
+		}
+	}
+	if estimate.NumFiles > 0 {
+		estimate.Duration = tapeRecallLatency +
+			time.Duration(estimate.Bytes/tapeRecallThroughput)*time.Second
+	}
+	return estimate
+}
+
+// prefix identifying a JDP project ID among file IDs
+const projectIdPrefix = "JDP-PROJECT:"
+
+// returns the (prefixed) IDs of all files in the JDP project with the given ID
+// that are visible to the user with the given ORCID
+func (db *Database) projectFileIds(orcid, projectId string) ([]string, error) {
+	const pageSize = 100
+	fileIds := make([]string, 0)
+	encountered := make(map[string]struct{})
+	for pageNumber := 1; ; pageNumber++ {
+		p := url.Values{}
+		p.Add("q", projectId)
+		p.Add("f", "project_id")
+		p.Add("include_private_data", "1")
+		p.Add("p", strconv.Itoa(pageNumber))
+		p.Add("x", strconv.Itoa(pageSize))
+		p.Add("orcid", orcid)
+		body, err := db.get("search", p)
+		if err != nil {
+			return nil, err
+		}
+		descriptors, err := descriptorsFromResponseBody(body, nil)
+		if err != nil {
+			return nil, err
+		}
+		numNew := 0
+		for _, descriptor := range descriptors {
+			fileId := descriptor["id"].(string)
+			if _, found := encountered[fileId]; !found {
+				fileIds = append(fileIds, fileId)
+				encountered[fileId] = struct{}{}
+				numNew++
+			}
+		}
+		if len(descriptors) < pageSize || numNew == 0 { // last page
+			break
+		}
+	}
+	return fileIds, nil
+}
+
+// fetches JDP metadata for files with the given (unprefixed) IDs, returning
+// the body of the response
+func (db *Database) fetchFileMetadata(orcid string, fileIds []string) ([]byte, error) {
+	type MetadataRequest struct {
+		Ids                []string `json:"ids"`
+		Aggregations       bool     `json:"aggregations"`
+		IncludePrivateData int      `json:"include_private_data"`
+	}
+	data, err := json.Marshal(MetadataRequest{
+		Ids:                fileIds,
+		Aggregations:       true,
+		IncludePrivateData: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.post("search/by_file_ids/", orcid, bytes.NewReader(data))
 }
 
 // extracts source information from the given metadata
@@ -422,48 +616,17 @@ func sourcesFromMetadata(md Metadata) []any {
 	return sources
 }
 
-// creates a Frictionless DataResource-savvy name for a file:
-// * the name consists of lower case characters plus '.', '-', and '_'
-// * all forbidden characters encountered in the filename are removed
-// * a number suffix is added if needed to make the name unique
-func dataResourceName(filename string) string {
-	name := strings.ToLower(filename)
-
-	// remove any file suffix
-	lastDot := strings.LastIndex(name, ".")
-	if lastDot != -1 {
-		name = name[:lastDot]
-	}
-
-	// replace sequences of invalid characters with '_'
-	for {
-		isInvalid := func(c rune) bool {
-			return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' && c != '.'
-		}
-		start := strings.IndexFunc(name, isInvalid)
-		if start >= 0 {
-			nameRunes := []rune(name)
-			end := start + 1
-			for end < len(name) && isInvalid(nameRunes[end]) {
-				end++
-			}
-			if end < len(name) {
-				name = name[:start] + string('_') + name[end+1:]
-			} else {
-				name = name[:start] + string('_')
-			}
-		} else {
-			break
-		}
-	}
-
-	return name
+// the JGI's data usage policy, which governs the use of all JDP files
+var dataUsagePolicy = frictionless.License{
+	Name:  "JGI-Data-Policy",
+	Path:  "https://jgi.doe.gov/user-programs/pmo-overview/policies/",
+	Title: "JGI Data Usage Policy",
 }
 
 // creates a Frictionless descriptor from a File
 func descriptorFromOrganismAndFile(organism Organism, file File) map[string]any {
 	id := "JDP:" + file.Id
-	format := formatFromFileName(file.Name)
+	format := formats.FormatFromFileName(file.Name)
 	sources := sourcesFromMetadata(file.Metadata)
 
 	// we use relative file paths in accordance with the Frictionless
@@ -473,10 +636,10 @@ func descriptorFromOrganismAndFile(organism Organism, file File) map[string]any
 	pi := file.Metadata.Proposal.PI
 	descriptor := map[string]any{
 		"id":        id,
-		"name":      dataResourceName(file.Name),
+		"name":      frictionless.ResourceName(file.Name),
 		"path":      filePath,
 		"format":    format,
-		"mediatype": mimetypeForFile(file.Name),
+		"mediatype": formats.MimeTypeForFile(file.Name),
 		"bytes":     int(file.Size),
 		"hash":      file.MD5Sum,
 		"credit": credit.CreditMetadata{
@@ -532,18 +695,165 @@ func descriptorFromOrganismAndFile(organism Organism, file File) map[string]any
 					ContributorRoles: "PI",
 				},
 			},
+			License: credit.License{Url: dataUsagePolicy.Path},
 			Version: file.Date,
 		},
 	}
+	frictionless.SetLicenses(descriptor, dataUsagePolicy)
+	frictionless.SetTaxonomy(descriptor, taxonomyFromMetadata(file.Metadata))
+	frictionless.AddCrossReferences(descriptor, crossReferencesFromMetadata(file.Metadata)...)
 	if len(sources) > 0 {
 		descriptor["sources"] = sources
 	}
 	return descriptor
 }
 
+// extracts normalized taxonomic information from the given metadata
+func taxonomyFromMetadata(md Metadata) frictionless.Taxonomy {
+	taxonomy := frictionless.Taxonomy{
+		NCBITaxId:      md.NCBITaxonId,
+		ScientificName: md.NCBITaxon.Species,
+	}
+	if taxonomy.ScientificName == "" {
+		taxonomy.ScientificName = md.IMG.TaxonDisplayName
+	}
+	for _, taxon := range []frictionless.Taxon{
+		{Rank: "domain", Name: md.IMG.Domain},
+		{Rank: "kingdom", Name: md.Portal.JdpKingdom},
+		{Rank: "order", Name: md.NCBITaxon.Order},
+		{Rank: "family", Name: md.NCBITaxon.Family},
+		{Rank: "genus", Name: md.NCBITaxon.Genus},
+		{Rank: "species", Name: md.NCBITaxon.Species},
+	} {
+		if taxon.Name != "" {
+			taxonomy.Lineage = append(taxonomy.Lineage, taxon)
+		}
+	}
+	return taxonomy
+}
+
+// returns cross-references (CURIEs) to the GOLD project and IMG taxon
+// identified in the given metadata
+func crossReferencesFromMetadata(md Metadata) []string {
+	var xrefs []string
+	if md.GoldData.StampId != "" {
+		xrefs = append(xrefs, frictionless.CURIE("gold", md.GoldData.StampId))
+	}
+	switch taxonOID := md.IMG.TaxonOID.(type) { // can be a number or a string
+	case float64:
+		xrefs = append(xrefs, frictionless.CURIE("img.taxon", strconv.FormatFloat(taxonOID, 'f', -1, 64)))
+	case string:
+		if taxonOID != "" {
+			xrefs = append(xrefs, frictionless.CURIE("img.taxon", taxonOID))
+		}
+	}
+	return xrefs
+}
+
+// the maximum number of cross-references resolved concurrently (each costs a
+// JDP search)
+const maxConcurrentXrefSearches = 8
+
+// returns the IDs of the JDP projects ("JDP-PROJECT:<id>") visible to the user
+// with the given ORCID that are related to the given cross-references, keyed
+// by cross-reference (implements databases.CrossReferenceResolver). GOLD
+// project IDs ("gold:Gp...") and IMG taxon OIDs ("img.taxon:...") are
+// recognized. Cross-references are resolved concurrently, a few at a time.
+func (db *Database) ResolveCrossReferences(orcid string, xrefs []string) (map[string][]string, error) {
+	projectIds := make([][]string, len(xrefs))
+	errs := make([]error, len(xrefs))
+	searchSlots := make(chan struct{}, maxConcurrentXrefSearches)
+	var wg sync.WaitGroup
+	for i, xref := range xrefs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searchSlots <- struct{}{}
+			defer func() { <-searchSlots }()
+			projectIds[i], errs[i] = db.resolveCrossReference(orcid, xref)
+		}()
+	}
+	wg.Wait()
+
+	resolved := make(map[string][]string)
+	for i, xref := range xrefs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if len(projectIds[i]) > 0 {
+			resolved[xref] = projectIds[i]
+		}
+	}
+	return resolved, nil
+}
+
+// returns the IDs of the JDP projects visible to the user with the given ORCID
+// that are related to the given cross-reference (nil if it isn't recognized)
+func (db *Database) resolveCrossReference(orcid, xref string) ([]string, error) {
+	var organisms []Organism
+	var err error
+	switch namespace, id := frictionless.ParseCURIE(xref); namespace {
+	case "gold":
+		if !strings.HasPrefix(id, "Gp") { // only projects are sequenced
+			return nil, nil
+		}
+		organisms, err = db.searchOrganisms(orcid, id, "")
+		// the search isn't restricted to GOLD IDs, so check for matches
+		organisms = slices.DeleteFunc(organisms, func(org Organism) bool {
+			return !slices.ContainsFunc(org.Files, func(file File) bool {
+				return file.Metadata.GoldData.StampId == id
+			})
+		})
+	case "img.taxon":
+		organisms, err = db.searchOrganisms(orcid, id, "img_taxon_oid")
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var projectIds []string
+	for _, org := range organisms {
+		projectId := projectIdPrefix + org.Id
+		if !slices.Contains(projectIds, projectId) {
+			projectIds = append(projectIds, projectId)
+		}
+	}
+	return projectIds, nil
+}
+
+// returns the organisms (projects) with files matching the given query (in
+// the given field, if any) that are visible to the user with the given ORCID
+func (db *Database) searchOrganisms(orcid, query, field string) ([]Organism, error) {
+	p := url.Values{}
+	p.Add("q", query)
+	if field != "" {
+		p.Add("f", field)
+	}
+	p.Add("include_private_data", "1")
+	p.Add("x", "100")
+	p.Add("orcid", orcid)
+	body, err := db.get("search", p)
+	if err != nil {
+		return nil, err
+	}
+	var results struct {
+		Organisms []Organism `json:"organisms"`
+	}
+	err = json.Unmarshal(body, &results)
+	return results.Organisms, err
+}
+
 // adds an appropriate authorization header to given HTTP request
-func (db Database) addAuthHeader(orcid string, request *http.Request) {
-	request.Header.Add("Authorization", fmt.Sprintf("Token %s_%s", orcid, db.Secret))
+func (db *Database) addAuthHeader(orcid string, request *http.Request) {
+	secret := db.Secret
+	if credentialName := config.Databases["jdp"].Credential; credentialName != "" {
+		// pick up the secret if it has been rotated
+		if credential, err := credentials.Get(credentialName); err == nil {
+			secret = credential.Secret
+		}
+	}
+	request.Header.Add("Authorization", fmt.Sprintf("Token %s_%s", orcid, secret))
 }
 
 // performs a GET request on the given resource, returning the resulting
@@ -648,6 +958,19 @@ func descriptorsFromResponseBody(body []byte, extraFields []string) ([]map[strin
 						extras["project_id"] = org.Id
 					case "img_taxon_oid":
 						extras["img_taxon_oid"] = file.Metadata.IMG.TaxonOID
+					case "sequencing_strategy":
+						extras["sequencing_strategy"] = file.Metadata.SequencingProject.SequencingStrategy
+					case "its_proposal_id":
+						extras["its_proposal_id"] = file.Metadata.ProposalId
+					case "analysis_project_id":
+						// fall back on IMG's analysis project ID if needed
+						if file.Metadata.AnalysisProjectId != nil {
+							extras["analysis_project_id"] = file.Metadata.AnalysisProjectId
+						} else {
+							extras["analysis_project_id"] = file.Metadata.IMG.AnalysisProjectId
+						}
+					case "genome_type":
+						extras["genome_type"] = file.Metadata.SequencingProject.GenomeType
 					}
 				}
 				descriptor["extra"] = extras
@@ -681,7 +1004,7 @@ func pageNumberAndSize(offset, maxNum int) (int, int) {
 }
 
 // checks JDP-specific search parameters and adds them to the given URL values
-func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Values) error {
+func (db *Database) addSpecificSearchParameters(params map[string]any, p *url.Values) error {
 	paramSpec := db.SpecificSearchParameters()
 	for name, jsonValue := range params {
 		var ok bool
@@ -755,14 +1078,15 @@ func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Val
 				}
 			}
 			acceptedValues := paramSpec["extra"].([]string)
-			if slices.Contains(acceptedValues, value) {
-				p.Add(name, value)
-			} else {
-				return &databases.InvalidSearchParameter{
-					Database: "JDP",
-					Message:  fmt.Sprintf("Invalid requested extra field: %s", value),
+			for _, field := range strings.Split(value, ",") {
+				if !slices.Contains(acceptedValues, field) {
+					return &databases.InvalidSearchParameter{
+						Database: "JDP",
+						Message:  fmt.Sprintf("Invalid requested extra field: %s", field),
+					}
 				}
 			}
+			p.Add(name, value)
 		default:
 			return &databases.InvalidSearchParameter{
 				Database: "JDP",
@@ -773,6 +1097,52 @@ func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Val
 	return nil
 }
 
+// returns the number of restoration requests submitted on behalf of the user
+// with the given ORCID that the JDP hasn't completed
+func (db *Database) outstandingRequests(orcid string) int {
+	n := 0
+	for _, request := range db.StagingRequests {
+		if request.Orcid == orcid && request.Id != 0 && !request.Ready {
+			n++
+		}
+	}
+	return n
+}
+
+// submits queued restoration requests, in the order in which they were made,
+// as long as their users have fewer outstanding requests than allowed
+func (db *Database) submitQueuedRequests() {
+	queued := make([]uuid.UUID, 0)
+	for id, request := range db.StagingRequests {
+		if request.Id == 0 && !request.Failed {
+			queued = append(queued, id)
+		}
+	}
+	slices.SortFunc(queued, func(a, b uuid.UUID) int {
+		return db.StagingRequests[a].Time.Compare(db.StagingRequests[b].Time)
+	})
+	limit := config.Databases["jdp"].MaxStagingRequests
+	for _, id := range queued {
+		request := db.StagingRequests[id]
+		if limit > 0 && db.outstandingRequests(request.Orcid) >= limit {
+			continue
+		}
+		requestId, err := db.requestRestoration(request.Orcid, request.FileIds)
+		if err != nil {
+			if _, unavailable := err.(*databases.UnavailableError); unavailable {
+				return // try again later
+			}
+			slog.Error(fmt.Sprintf("Couldn't submit queued JDP restoration request: %s", err.Error()))
+			request.Failed = true
+		} else {
+			request.Id = requestId
+			request.Time = time.Now()
+			request.FileIds = nil
+		}
+		db.StagingRequests[id] = request
+	}
+}
+
 func (db *Database) pruneStagingRequests() {
 	deleteAfter := time.Duration(config.Service.DeleteAfter) * time.Second
 	for uuid, request := range db.StagingRequests {
@@ -782,11 +1152,3 @@ func (db *Database) pruneStagingRequests() {
 		}
 	}
 }
-
-func mimetypeForFile(filename string) string {
-	mimetype := mime.TypeByExtension(filepath.Ext(filename))
-	if mimetype == "" {
-		mimetype = "application/octet-stream"
-	}
-	return mimetype
-}
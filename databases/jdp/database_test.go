@@ -1,17 +1,24 @@
 package jdp
 
 import (
+	"encoding/json"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/conformance"
 	"github.com/kbase/dts/dtstest"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/frictionless"
 )
 
 const jdpConfig string = `
@@ -99,6 +106,90 @@ func TestSearchByIMGTaxonOID(t *testing.T) {
 	assert.Nil(err, "JDP search query encountered an error")
 }
 
+func TestExtraFields(t *testing.T) {
+	assert := assert.New(t)
+	db := &Database{}
+
+	// several extra fields can be requested at once, but only supported ones
+	p := url.Values{}
+	err := db.addSpecificSearchParameters(map[string]any{
+		"extra": "sequencing_strategy,its_proposal_id,analysis_project_id,genome_type",
+	}, &p)
+	assert.Nil(err)
+	err = db.addSpecificSearchParameters(map[string]any{"extra": "genome_type,color"}, &url.Values{})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+
+	body := []byte(`{"organisms": [{"id": "1234", "name": "prochlorococcus", "files": [{
+		"_id": "5a1b2c3d4e5f", "file_name": "reads.fastq.gz", "file_path": "/global/dna/reads",
+		"file_size": 1024, "file_type": "fastq", "md5sum": "abc",
+		"metadata": {
+			"proposal_id": 503125,
+			"analysis_project_id": [1045963],
+			"img": {"taxon_oid": 2582580701},
+			"sequencing_project": {
+				"sequencing_strategy": "Whole Genome Sequencing",
+				"genome_type": "isolate"
+			}
+		}
+	}]}]}`)
+	descriptors, err := descriptorsFromResponseBody(body, strings.Split(p.Get("extra"), ","))
+	assert.Nil(err)
+	assert.Equal(1, len(descriptors))
+	assert.Equal(map[string]any{
+		"sequencing_strategy": "Whole Genome Sequencing",
+		"its_proposal_id":     503125,
+		"analysis_project_id": []any{1045963.0},
+		"genome_type":         "isolate",
+	}, descriptors[0]["extra"])
+}
+
+func TestTaxonomyFromMetadata(t *testing.T) {
+	assert := assert.New(t)
+	var md Metadata
+	err := json.Unmarshal([]byte(`{
+		"ncbi_taxon_id": 1219,
+		"ncbi_taxon": {
+			"ncbi_taxon_order": "Synechococcales",
+			"ncbi_taxon_family": "Prochlorococcaceae",
+			"ncbi_taxon_genus": "Prochlorococcus",
+			"ncbi_taxon_species": "Prochlorococcus marinus"
+		},
+		"portal": {"jdp_kingdom": "Bacteria"}
+	}`), &md)
+	assert.Nil(err)
+	assert.Equal(frictionless.Taxonomy{
+		NCBITaxId:      1219,
+		ScientificName: "Prochlorococcus marinus",
+		Lineage: []frictionless.Taxon{
+			{Rank: "kingdom", Name: "Bacteria"},
+			{Rank: "order", Name: "Synechococcales"},
+			{Rank: "family", Name: "Prochlorococcaceae"},
+			{Rank: "genus", Name: "Prochlorococcus"},
+			{Rank: "species", Name: "Prochlorococcus marinus"},
+		},
+	}, taxonomyFromMetadata(md))
+
+	// files without taxonomic metadata have no taxonomy
+	assert.True(taxonomyFromMetadata(Metadata{}).IsEmpty())
+}
+
+func TestCrossReferencesFromMetadata(t *testing.T) {
+	assert := assert.New(t)
+	var md Metadata
+	err := json.Unmarshal([]byte(`{
+		"gold_data": {"gold_stamp_id": "Gp0115663"},
+		"img": {"taxon_oid": 2582580701}
+	}`), &md)
+	assert.Nil(err)
+	assert.Equal([]string{"gold:Gp0115663", "img.taxon:2582580701"}, crossReferencesFromMetadata(md))
+
+	md.IMG.TaxonOID = "3300002156"
+	assert.Equal([]string{"gold:Gp0115663", "img.taxon:3300002156"}, crossReferencesFromMetadata(md))
+
+	// files without identifiers have no cross-references
+	assert.Nil(crossReferencesFromMetadata(Metadata{}))
+}
+
 func TestDescriptors(t *testing.T) {
 	assert := assert.New(t)
 	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
@@ -128,6 +219,80 @@ func TestDescriptors(t *testing.T) {
 	}
 }
 
+func TestTranslateQuery(t *testing.T) {
+	assert := assert.New(t)
+	db := &Database{}
+
+	query, err := databases.ParseQuery(`prochlorococcus AND (project_id:1234 OR "marine isolate") img_taxon_oid:2-3`)
+	assert.Nil(err)
+	translated, err := db.TranslateQuery(query)
+	assert.Nil(err)
+	assert.Equal(`prochlorococcus AND (project_id:1234 OR "marine isolate") AND img_taxon_oid:"2-3"`, translated)
+}
+
+func TestTapeRecallEstimate(t *testing.T) {
+	assert := assert.New(t)
+	files := []File{
+		{Id: "1", Status: "RESTORED", Size: 1024},
+		{Id: "2", Status: "PURGED", Size: 200 * 1024 * 1024},
+		{Id: "3", Status: "PURGED", Size: 100 * 1024 * 1024},
+	}
+	estimate := tapeRecallEstimate(files)
+	assert.Equal(2, estimate.NumFiles)
+	assert.Equal(int64(300*1024*1024), estimate.Bytes)
+	assert.Equal(tapeRecallLatency+3*time.Second, estimate.Duration)
+
+	// no recall is needed for files on disk
+	estimate = tapeRecallEstimate(files[:1])
+	assert.Equal(0, estimate.NumFiles)
+	assert.Equal(time.Duration(0), estimate.Duration)
+}
+
+// runs the database conformance suite against the JDP (without staging, which
+// recalls files from tape)
+func TestStagingQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	dbConfig := config.Databases["jdp"]
+	dbConfig.MaxStagingRequests = 1
+	config.Databases["jdp"] = dbConfig
+	defer func() {
+		dbConfig.MaxStagingRequests = 0
+		config.Databases["jdp"] = dbConfig
+	}()
+
+	// with a restoration request underway, further requests are queued
+	orcid := "1234-5678-9012-3456"
+	db := Database{StagingRequests: make(map[uuid.UUID]StagingRequest)}
+	underway := uuid.New()
+	db.StagingRequests[underway] = StagingRequest{Id: 1, Time: time.Now(), Orcid: orcid}
+	first, err := db.StageFiles(orcid, []string{"JDP:1"})
+	assert.Nil(err)
+	second, err := db.StageFiles(orcid, []string{"JDP:2"})
+	assert.Nil(err)
+	assert.Equal(0, db.StagingQueuePosition(underway))
+	assert.Equal(1, db.StagingQueuePosition(first))
+	assert.Equal(2, db.StagingQueuePosition(second))
+	assert.Equal([]string{"JDP:2"}, db.StagingRequests[second].FileIds)
+
+	// other users' requests don't count against the limit
+	assert.Equal(1, db.outstandingRequests(orcid))
+	assert.Equal(0, db.outstandingRequests("0000-0000-0000-0000"))
+
+	// queued requests are reported as active
+	status, err := db.StagingStatus(first)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusActive, status)
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, NewDatabase, conformance.Parameters{
+		Orcid:       os.Getenv("DTS_KBASE_TEST_ORCID"),
+		Query:       "prochlorococcus",
+		SkipStaging: true,
+	})
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	setup()
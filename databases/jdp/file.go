@@ -148,6 +148,10 @@ type Metadata struct {
 	SequencingProject struct {
 		// name of scientific program to which project belongs
 		ScientificProgramName string `json:"scientific_program_name"`
+		// sequencing strategy (e.g. "Whole Genome Sequencing", "Metagenome")
+		SequencingStrategy string `json:"sequencing_strategy"`
+		// type of genome sequenced (e.g. "isolate", "metagenome")
+		GenomeType string `json:"genome_type"`
 	} `json:"sequencing_project"`
 	// sequencing project ID, sometimes used as ITS project ID
 	SequencingProjectId any `json:"sequencing_project_id"`
@@ -27,22 +27,23 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"mime"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
 	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
 )
 
 // file database appropriate for handling searches and transfers
@@ -57,19 +58,9 @@ type Database struct {
 }
 
 func NewDatabase() (databases.Database, error) {
-	nmdcUser, haveNmdcUser := os.LookupEnv("DTS_NMDC_USER")
-	if !haveNmdcUser {
-		return nil, &databases.UnauthorizedError{
-			Database: "nmdc",
-			Message:  "No NMDC user (DTS_NMDC_USER) was provided for authentication",
-		}
-	}
-	nmdcPassword, haveNmdcPassword := os.LookupEnv("DTS_NMDC_PASSWORD")
-	if !haveNmdcPassword {
-		return nil, &databases.UnauthorizedError{
-			Database: "nmdc",
-			Message:  "No NMDC password (DTS_NMDC_PASSWORD) was provided for authentication",
-		}
+	apiCredential, err := userCredential()
+	if err != nil {
+		return nil, err
 	}
 
 	if config.Databases["nmdc"].Endpoint != "" {
@@ -104,7 +95,7 @@ func NewDatabase() (databases.Database, error) {
 	}
 
 	// get an API access token
-	auth, err := db.getAccessToken(credential{User: nmdcUser, Password: nmdcPassword})
+	auth, err := db.getAccessToken(apiCredential)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +115,11 @@ func (db Database) SpecificSearchParameters() map[string]any {
 		"sort":           "",
 		"sample_id":      "",
 		"study_id":       "",
-		"extra":          "",
+		// list of requested extra fields
+		"extra": []string{"biosample_id", "ecosystem", "instrument", "lat_lon", "omics_type", "xrefs"},
+		// selects the outputs of a workflow execution (and optionally its inputs)
+		"workflow_execution_id":   "",
+		"include_workflow_inputs": false,
 	}
 }
 
@@ -154,23 +149,83 @@ func (db *Database) Search(orcid string, params databases.SearchParameters) (dat
 		p.Add("filter", params.Query)
 	}
 
+	// extract any requested "extra" metadata fields (and scrub them from params)
+	var extraFields []string
+	if p.Has("extra") {
+		extraFields = strings.Split(p.Get("extra"), ",")
+		p.Del("extra")
+	}
+
 	var descriptors []map[string]any
+	var dataObjects []DataObject
+	var biosampleForWorkflow map[string]map[string]any
 	var err error
 	if p.Has("study_id") { // fetch data objects associated with this study
-		descriptors, err = db.createDataObjectDescriptorsForStudy(p.Get("study_id"))
+		descriptors, dataObjects, err = db.createDataObjectDescriptorsForStudy(p.Get("study_id"))
+	} else if p.Has("workflow_execution_id") { // fetch a workflow execution's data objects
+		var dataObjectIds []string
+		dataObjectIds, err = db.workflowDataObjectIds(p.Get("workflow_execution_id"),
+			p.Get("include_workflow_inputs") == "true")
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+		dataObjects, err = db.dataObjectsWithIds(dataObjectIds)
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+		descriptors, _, biosampleForWorkflow, err = db.createDataObjectAndBiosampleDescriptors(dataObjects)
 	} else {
-		var dataObjects []DataObject
 		dataObjects, err = db.dataObjects(p)
 		if err != nil {
 			return databases.SearchResults{}, err
 		}
-		descriptors, _, err = db.createDataObjectAndBiosampleDescriptors(dataObjects)
+		descriptors, _, biosampleForWorkflow, err = db.createDataObjectAndBiosampleDescriptors(dataObjects)
+	}
+	if err == nil && extraFields != nil {
+		err = db.addExtraFields(descriptors, dataObjects, biosampleForWorkflow, extraFields)
 	}
 	return databases.SearchResults{
 		Descriptors: descriptors,
 	}, err
 }
 
+// routes the database's requests to the NMDC through the given trace
+func (db *Database) Trace(trace *databases.RequestTrace) {
+	db.Client.Transport = trace.Transport(db.Client.Transport)
+}
+
+// translates a DTS query into the NMDC's filter syntax, a comma-separated
+// list of field:value conditions that must all be satisfied
+func (db Database) TranslateQuery(query databases.QueryNode) (string, error) {
+	terms := []databases.QueryNode{query}
+	if query.Operator == databases.QueryAnd {
+		terms = query.Operands
+	}
+	conditions := make([]string, len(terms))
+	for i, term := range terms {
+		if term.Operator != "" {
+			return "", &databases.InvalidSearchParameter{
+				Database: "NMDC",
+				Message:  "NMDC queries can only combine terms with AND",
+			}
+		}
+		if term.Field == "" {
+			return "", &databases.InvalidSearchParameter{
+				Database: "NMDC",
+				Message:  fmt.Sprintf("NMDC query term '%s' must specify a field", term.Value),
+			}
+		}
+		if strings.Contains(term.Value, ",") {
+			return "", &databases.InvalidSearchParameter{
+				Database: "NMDC",
+				Message:  fmt.Sprintf("NMDC query value '%s' cannot contain a comma", term.Value),
+			}
+		}
+		conditions[i] = term.Field + ":" + term.Value
+	}
+	return strings.Join(conditions, ","), nil
+}
+
 func (db Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
 	if err := db.renewAccessTokenIfExpired(); err != nil {
 		return nil, err
@@ -178,26 +233,89 @@ func (db Database) Descriptors(orcid string, fileIds []string) ([]map[string]any
 
 	// construct data resource descriptors from the IDs and make lists of
 	// workflow executions and data generations (for metadata)
-	dataObjects := make([]DataObject, len(fileIds))
-	for i, fileId := range fileIds {
-		body, err := db.get(fmt.Sprintf("data_objects/%s", fileId), url.Values{})
-		if err != nil {
-			return nil, err
-		}
-		err = json.Unmarshal(body, &dataObjects[i])
-		if err != nil {
-			return nil, err
-		}
+	dataObjects, err := db.dataObjectsWithIds(fileIds)
+	if err != nil {
+		return nil, err
 	}
 
 	// fetch metadata for data objects and biosamples and turn them into descriptors
-	dataObjectDescriptors, biosampleDescriptors, err := db.createDataObjectAndBiosampleDescriptors(dataObjects)
+	dataObjectDescriptors, biosampleDescriptors, _, err := db.createDataObjectAndBiosampleDescriptors(dataObjects)
 	if err != nil {
 		return nil, err
 	}
 	return slices.Concat(dataObjectDescriptors, biosampleDescriptors), nil
 }
 
+// replaces any dataset IDs among the given file IDs with the IDs of their
+// data objects (implements databases.DatasetExpander):
+//   - "NMDC-STUDY:<id>" expands to a study's data objects, restricted to any
+//     types listed in the "data_object_types" field of the given instructions
+//   - "NMDC-WORKFLOW:<id>" expands to the outputs of a workflow execution
+//   - "NMDC-WORKFLOW-WITH-INPUTS:<id>" expands to the inputs and outputs of a
+//     workflow execution
+func (db Database) ExpandFileIds(orcid string, fileIds []string, instructions map[string]any) ([]string, map[string][]string, error) {
+	types, err := dataObjectTypesFromInstructions(instructions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expandedFileIds := make([]string, 0, len(fileIds))
+	datasets := make(map[string][]string)
+	encountered := make(map[string]struct{})
+	for _, fileId := range fileIds {
+		var datasetFileIds []string
+		if studyId, isStudy := strings.CutPrefix(fileId, studyIdPrefix); isStudy {
+			if err := db.renewAccessTokenIfExpired(); err != nil {
+				return nil, nil, err
+			}
+			dataObjects, err := db.studyDataObjects(studyId)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, dataObject := range dataObjects {
+				if len(types) == 0 || slices.Contains(types, dataObject.DataObjectType) {
+					datasetFileIds = append(datasetFileIds, dataObject.Id)
+				}
+			}
+		} else if workflowId, isWorkflow := strings.CutPrefix(fileId, workflowWithInputsIdPrefix); isWorkflow {
+			if err := db.renewAccessTokenIfExpired(); err != nil {
+				return nil, nil, err
+			}
+			if datasetFileIds, err = db.workflowDataObjectIds(workflowId, true); err != nil {
+				return nil, nil, err
+			}
+		} else if workflowId, isWorkflow := strings.CutPrefix(fileId, workflowIdPrefix); isWorkflow {
+			if err := db.renewAccessTokenIfExpired(); err != nil {
+				return nil, nil, err
+			}
+			if datasetFileIds, err = db.workflowDataObjectIds(workflowId, false); err != nil {
+				return nil, nil, err
+			}
+		} else { // ordinary file ID
+			if _, found := encountered[fileId]; !found {
+				expandedFileIds = append(expandedFileIds, fileId)
+				encountered[fileId] = struct{}{}
+			}
+			continue
+		}
+
+		if len(datasetFileIds) == 0 {
+			return nil, nil, &databases.ResourcesNotFoundError{
+				Database:    "NMDC",
+				ResourceIds: []string{fileId},
+			}
+		}
+		datasets[fileId] = datasetFileIds
+		for _, datasetFileId := range datasetFileIds {
+			if _, found := encountered[datasetFileId]; !found {
+				expandedFileIds = append(expandedFileIds, datasetFileId)
+				encountered[datasetFileId] = struct{}{}
+			}
+		}
+	}
+	return expandedFileIds, datasets, nil
+}
+
 func (db Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
 	// NMDC keeps all of its NERSC data on disk, so all files are already staged.
 	// We simply generate a new UUID that can be handed to db.StagingStatus,
@@ -266,6 +384,33 @@ type credential struct {
 	User, Password string
 }
 
+// returns the NMDC API user credential, obtained from the credential configured
+// for the database or (if none is configured) from environment variables
+func userCredential() (credential, error) {
+	if credentialName := config.Databases["nmdc"].Credential; credentialName != "" {
+		apiCredential, err := credentials.Get(credentialName)
+		if err != nil {
+			return credential{}, err
+		}
+		return credential{User: apiCredential.Id, Password: apiCredential.Secret}, nil
+	}
+	nmdcUser, haveNmdcUser := os.LookupEnv("DTS_NMDC_USER")
+	if !haveNmdcUser {
+		return credential{}, &databases.UnauthorizedError{
+			Database: "nmdc",
+			Message:  "No NMDC user (DTS_NMDC_USER) was provided for authentication",
+		}
+	}
+	nmdcPassword, haveNmdcPassword := os.LookupEnv("DTS_NMDC_PASSWORD")
+	if !haveNmdcPassword {
+		return credential{}, &databases.UnauthorizedError{
+			Database: "nmdc",
+			Message:  "No NMDC password (DTS_NMDC_PASSWORD) was provided for authentication",
+		}
+	}
+	return credential{User: nmdcUser, Password: nmdcPassword}, nil
+}
+
 // fetches an access token / type from NMDC using a credential
 func (db *Database) getAccessToken(credential credential) (authorization, error) {
 	var auth authorization
@@ -350,7 +495,14 @@ func (db *Database) getAccessToken(credential credential) (authorization, error)
 func (db *Database) renewAccessTokenIfExpired() error {
 	var err error
 	if time.Now().After(db.Auth.ExpirationTime) { // token has expired
-		db.Auth, err = db.getAccessToken(db.Auth.Credential)
+		// pick up the user credential if it has been rotated
+		apiCredential := db.Auth.Credential
+		if config.Databases["nmdc"].Credential != "" {
+			if apiCredential, err = userCredential(); err != nil {
+				return err
+			}
+		}
+		db.Auth, err = db.getAccessToken(apiCredential)
 	}
 	return err
 }
@@ -460,14 +612,6 @@ type WorkflowExecution struct {
 
 // fetches metadata for data objects based on the given URL search parameters
 func (db Database) dataObjects(params url.Values) ([]DataObject, error) {
-	// extract any requested "extra" metadata fields (and scrub them from params)
-	// FIXME: no extra fields yet, so we simply remove this parameter
-	//var extraFields []string
-	if params.Has("extra") {
-		//extraFields = strings.Split(params.Get("extra"), ",")
-		params.Del("extra")
-	}
-
 	body, err := db.get("data_objects/", params)
 	type DataObjectResults struct {
 		// NOTE: we only extract the results field for now
@@ -481,24 +625,288 @@ func (db Database) dataObjects(params url.Values) ([]DataObject, error) {
 	return dataObjectResults.Results, err
 }
 
-// returns descriptors for data objects for a given study
-func (db Database) createDataObjectDescriptorsForStudy(studyId string) ([]map[string]any, error) {
+// returns descriptors for data objects for a given study, along with the data
+// objects themselves
+func (db Database) createDataObjectDescriptorsForStudy(studyId string) ([]map[string]any, []DataObject, error) {
 	// fetch the study and its metadata
 	resource := fmt.Sprintf("studies/%s", studyId)
 	body, err := db.get(resource, url.Values{})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var study Study
 	err = json.Unmarshal(body, &study)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	relatedCredit := db.creditMetadataForStudy(study)
 
 	// fetch the data objects for the study
-	resource = fmt.Sprintf("data_objects/study/%s", studyId)
-	body, err = db.get(resource, url.Values{})
+	dataObjects, err := db.studyDataObjects(studyId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// render descriptors from the data objects and credit metadata
+	descriptors := make([]map[string]any, len(dataObjects))
+	for i, dataObject := range dataObjects {
+		descriptors[i] = db.createDataObjectDescriptor(dataObject, relatedCredit)
+	}
+	return descriptors, dataObjects, nil
+}
+
+// biosample and data generation metadata from which extra fields are taken
+type extraFieldMetadata struct {
+	Biosample      map[string]any
+	DataGeneration map[string]any
+	// names of the instruments used by the data generation
+	Instruments []string
+}
+
+// attaches the requested extra metadata fields to the descriptors for the
+// given data objects (in the same order) as "extra" fields, fetching the
+// biosample and data generation related to each data object's workflow
+// execution (once per workflow execution). Biosamples already fetched for
+// workflow executions (if any) are taken from biosampleForWorkflow. The
+// "xrefs" field instead adds the identifiers of the biosample and data
+// generation to each descriptor's cross-references.
+func (db Database) addExtraFields(descriptors []map[string]any, dataObjects []DataObject,
+	biosampleForWorkflow map[string]map[string]any, extraFields []string) error {
+	wantXrefs := slices.Contains(extraFields, "xrefs")
+	wantBiosample := wantXrefs || slices.ContainsFunc(extraFields, func(field string) bool {
+		return field == "biosample_id" || field == "ecosystem" || field == "lat_lon"
+	})
+	wantInstruments := slices.Contains(extraFields, "instrument")
+	wantDataGeneration := wantXrefs || wantInstruments || slices.Contains(extraFields, "omics_type")
+	metadataForWorkflow := make(map[string]extraFieldMetadata)
+	instrumentNameForId := make(map[string]string)
+	for i, dataObject := range dataObjects {
+		workflowId := dataObject.WasGeneratedBy
+		metadata, found := metadataForWorkflow[workflowId]
+		if !found {
+			var err error
+			metadata, err = db.extraFieldMetadataForWorkflow(workflowId, biosampleForWorkflow,
+				wantBiosample, wantDataGeneration)
+			if err != nil {
+				return err
+			}
+			if wantInstruments {
+				metadata.Instruments, err = db.instrumentNames(
+					stringValues(metadata.DataGeneration["instrument_used"]), instrumentNameForId)
+				if err != nil {
+					return err
+				}
+			}
+			metadataForWorkflow[workflowId] = metadata
+		}
+		descriptors[i]["extra"] = extraFieldsFromMetadata(extraFields, metadata)
+		if wantXrefs {
+			frictionless.AddCrossReferences(descriptors[i], slices.Concat(
+				crossReferencesFromBiosample(metadata.Biosample),
+				crossReferencesFromDataGeneration(metadata.DataGeneration))...)
+		}
+	}
+	return nil
+}
+
+// fetches the biosample and/or data generation related to the workflow
+// execution (or data generation, for raw data) with the given ID, taking the
+// biosample from biosampleForWorkflow if it has already been fetched
+func (db Database) extraFieldMetadataForWorkflow(workflowExecId string,
+	biosampleForWorkflow map[string]map[string]any,
+	wantBiosample, wantDataGeneration bool) (extraFieldMetadata, error) {
+	var metadata extraFieldMetadata
+	if workflowExecId == "" {
+		return metadata, nil
+	}
+	var dataGenerationId string
+	if strings.Contains(workflowExecId, "nmdc:wf") {
+		if wantBiosample {
+			biosample, fetched := biosampleForWorkflow[workflowExecId]
+			if !fetched {
+				var err error
+				_, biosample, err = db.creditAndBiosampleForWorkflow(workflowExecId)
+				if err != nil {
+					return metadata, err
+				}
+			}
+			metadata.Biosample = biosample
+		}
+		if wantDataGeneration {
+			body, err := db.get(fmt.Sprintf("workflow_executions/%s", workflowExecId), url.Values{})
+			if err != nil {
+				return metadata, err
+			}
+			// see https://microbiomedata.github.io/nmdc-schema/WorkflowExecution/
+			var workflowExec struct {
+				WasInformedBy any `json:"was_informed_by"` // string or list
+			}
+			err = json.Unmarshal(body, &workflowExec)
+			if err != nil {
+				return metadata, err
+			}
+			if ids := stringValues(workflowExec.WasInformedBy); len(ids) > 0 {
+				dataGenerationId = ids[0]
+			}
+		}
+	} else { // raw data are generated directly by a data generation
+		dataGenerationId = workflowExecId
+	}
+
+	if dataGenerationId != "" && (wantDataGeneration || metadata.Biosample == nil) {
+		body, err := db.get(fmt.Sprintf("nmdcschema/data_generation_set/%s", dataGenerationId),
+			url.Values{})
+		if err != nil {
+			return metadata, err
+		}
+		err = json.Unmarshal(body, &metadata.DataGeneration)
+		if err != nil {
+			return metadata, err
+		}
+
+		// a data generation's inputs are its biosamples
+		if wantBiosample && metadata.Biosample == nil {
+			if ids := stringValues(metadata.DataGeneration["has_input"]); len(ids) > 0 {
+				body, err := db.get(fmt.Sprintf("biosamples/%s", ids[0]), url.Values{})
+				if err != nil {
+					return metadata, err
+				}
+				err = json.Unmarshal(body, &metadata.Biosample)
+				if err != nil {
+					return metadata, err
+				}
+			}
+		}
+	}
+	return metadata, nil
+}
+
+// returns the names of the instruments with the given IDs, fetching any not
+// already found in (and adding them to) the given cache of names by ID
+func (db Database) instrumentNames(instrumentIds []string, nameForId map[string]string) ([]string, error) {
+	var names []string
+	for _, instrumentId := range instrumentIds {
+		name, found := nameForId[instrumentId]
+		if !found {
+			body, err := db.get(fmt.Sprintf("nmdcschema/instrument_set/%s", instrumentId),
+				url.Values{})
+			if err != nil {
+				return nil, err
+			}
+			// see https://microbiomedata.github.io/nmdc-schema/Instrument/
+			var instrument struct {
+				Name string `json:"name"`
+			}
+			err = json.Unmarshal(body, &instrument)
+			if err != nil {
+				return nil, err
+			}
+			name = instrument.Name
+			if name == "" { // fall back to the ID for unnamed instruments
+				name = instrumentId
+			}
+			nameForId[instrumentId] = name
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// the biosample fields describing its ecosystem
+// (see https://microbiomedata.github.io/nmdc-schema/Biosample/)
+var ecosystemFields = []string{"ecosystem", "ecosystem_category", "ecosystem_type",
+	"ecosystem_subtype", "specific_ecosystem"}
+
+// returns the requested extra fields available in the given metadata
+func extraFieldsFromMetadata(extraFields []string, metadata extraFieldMetadata) map[string]any {
+	extras := make(map[string]any)
+	for _, field := range extraFields {
+		switch field {
+		case "biosample_id":
+			if id, found := metadata.Biosample["id"]; found {
+				extras["biosample_id"] = id
+			}
+		case "ecosystem":
+			ecosystem := make(map[string]any)
+			for _, name := range ecosystemFields {
+				if value, found := metadata.Biosample[name]; found {
+					ecosystem[name] = value
+				}
+			}
+			if len(ecosystem) > 0 {
+				extras["ecosystem"] = ecosystem
+			}
+		case "lat_lon":
+			// see https://microbiomedata.github.io/nmdc-schema/GeolocationValue/
+			if latLon, ok := metadata.Biosample["lat_lon"].(map[string]any); ok {
+				extras["lat_lon"] = map[string]any{
+					"latitude":  latLon["latitude"],
+					"longitude": latLon["longitude"],
+				}
+			}
+		case "instrument":
+			if len(metadata.Instruments) > 0 {
+				extras["instrument"] = metadata.Instruments
+			}
+		case "omics_type":
+			// see https://microbiomedata.github.io/nmdc-schema/analyte_category/
+			if category, found := metadata.DataGeneration["analyte_category"]; found {
+				extras["omics_type"] = category
+			}
+		}
+	}
+	return extras
+}
+
+// biosample fields holding identifiers (CURIEs) of the biosample in other
+// repositories (see https://microbiomedata.github.io/nmdc-schema/Biosample/)
+var biosampleIdentifierFields = []string{"gold_biosample_identifiers",
+	"emsl_biosample_identifiers", "img_identifiers", "insdc_biosample_identifiers",
+	"igsn_biosample_identifiers"}
+
+// returns cross-references (CURIEs) to the given biosample and its
+// counterparts in other repositories
+func crossReferencesFromBiosample(biosample map[string]any) []string {
+	xrefs := stringValues(biosample["id"])
+	for _, field := range biosampleIdentifierFields {
+		xrefs = append(xrefs, stringValues(biosample[field])...)
+	}
+	return xrefs
+}
+
+// returns cross-references (CURIEs) to the given data generation and the
+// sequencing projects (e.g. GOLD projects) that produced its data
+// (see https://microbiomedata.github.io/nmdc-schema/NucleotideSequencing/)
+func crossReferencesFromDataGeneration(dataGeneration map[string]any) []string {
+	return slices.Concat(stringValues(dataGeneration["id"]),
+		stringValues(dataGeneration["gold_sequencing_project_identifiers"]))
+}
+
+// returns the string(s) in the given JSON value, which can be a string or a
+// list
+func stringValues(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, vi := range v {
+			if s, ok := vi.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// returns all data objects for the study with the given ID
+// NOTE: the NMDC API returns these in a single response grouped by biosample,
+// NOTE: so there are no pages to iterate over
+func (db Database) studyDataObjects(studyId string) ([]DataObject, error) {
+	resource := fmt.Sprintf("data_objects/study/%s", studyId)
+	body, err := db.get(resource, url.Values{})
 	if err != nil {
 		return nil, err
 	}
@@ -511,40 +919,251 @@ func (db Database) createDataObjectDescriptorsForStudy(studyId string) ([]map[st
 	if err != nil {
 		return nil, err
 	}
-
-	// render descriptors from the data objects and credit metadata
-	descriptors := make([]map[string]any, 0)
+	dataObjects := make([]DataObject, 0)
 	for _, objectSet := range objectSets {
-		for _, dataObject := range objectSet.DataObjects {
-			descriptors = append(descriptors, db.createDataObjectDescriptor(dataObject, relatedCredit))
+		dataObjects = append(dataObjects, objectSet.DataObjects...)
+	}
+	return dataObjects, nil
+}
+
+// prefixes identifying NMDC study and workflow execution IDs among file IDs
+const (
+	studyIdPrefix              = "NMDC-STUDY:"
+	workflowIdPrefix           = "NMDC-WORKFLOW:"
+	workflowWithInputsIdPrefix = "NMDC-WORKFLOW-WITH-INPUTS:"
+)
+
+// returns the IDs of the data objects output by the workflow execution with
+// the given ID, preceded by those of its inputs if requested
+func (db Database) workflowDataObjectIds(workflowExecId string, includeInputs bool) ([]string, error) {
+	body, err := db.get(fmt.Sprintf("workflow_executions/%s", workflowExecId), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	// see https://microbiomedata.github.io/nmdc-schema/WorkflowExecution/
+	var workflowExec struct {
+		HasInput  []string `json:"has_input"`
+		HasOutput []string `json:"has_output"`
+	}
+	err = json.Unmarshal(body, &workflowExec)
+	if err != nil {
+		return nil, err
+	}
+	if includeInputs {
+		return slices.Concat(workflowExec.HasInput, workflowExec.HasOutput), nil
+	}
+	return workflowExec.HasOutput, nil
+}
+
+// returns the IDs of the NMDC workflow executions ("NMDC-WORKFLOW:<id>") that
+// analyzed data related to the given cross-references, keyed by cross-reference
+// (implements databases.CrossReferenceResolver). GOLD biosample and project
+// IDs ("gold:Gb...", "gold:Gp...") and the EMSL, IGSN, and INSDC IDs of
+// biosamples are recognized.
+func (db Database) ResolveCrossReferences(orcid string, xrefs []string) (map[string][]string, error) {
+	if err := db.renewAccessTokenIfExpired(); err != nil {
+		return nil, err
+	}
+
+	// sort the recognized cross-references by the fields in which they appear
+	biosampleXrefs := make(map[string][]string) // biosample field -> xrefs
+	var sequencingProjectXrefs []string
+	for _, xref := range xrefs {
+		namespace, id := frictionless.ParseCURIE(xref)
+		switch {
+		case namespace == "gold" && strings.HasPrefix(id, "Gb"):
+			biosampleXrefs["gold_biosample_identifiers"] = append(biosampleXrefs["gold_biosample_identifiers"], xref)
+		case namespace == "gold" && strings.HasPrefix(id, "Gp"):
+			sequencingProjectXrefs = append(sequencingProjectXrefs, xref)
+		case namespace == "emsl":
+			biosampleXrefs["emsl_biosample_identifiers"] = append(biosampleXrefs["emsl_biosample_identifiers"], xref)
+		case namespace == "igsn":
+			biosampleXrefs["igsn_biosample_identifiers"] = append(biosampleXrefs["igsn_biosample_identifiers"], xref)
+		case namespace == "biosample": // INSDC biosample accessions
+			biosampleXrefs["insdc_biosample_identifiers"] = append(biosampleXrefs["insdc_biosample_identifiers"], xref)
+		}
+	}
+
+	// find the biosamples with the cross-referenced identifiers
+	xrefsForBiosample := make(map[string][]string)
+	for field, fieldXrefs := range biosampleXrefs {
+		biosamples, err := db.collectionResources("biosample_set",
+			map[string]any{field: map[string]any{"$in": fieldXrefs}})
+		if err != nil {
+			return nil, err
+		}
+		for _, biosample := range biosamples {
+			id, _ := biosample["id"].(string)
+			for _, xref := range stringValues(biosample[field]) {
+				if slices.Contains(fieldXrefs, xref) {
+					xrefsForBiosample[id] = append(xrefsForBiosample[id], xref)
+				}
+			}
+		}
+	}
+
+	// find the data generations for those biosamples and sequencing projects
+	xrefsForDataGeneration := make(map[string][]string)
+	var conditions []any
+	if len(xrefsForBiosample) > 0 {
+		conditions = append(conditions, map[string]any{
+			"has_input": map[string]any{"$in": slices.Collect(maps.Keys(xrefsForBiosample))},
+		})
+	}
+	if len(sequencingProjectXrefs) > 0 {
+		conditions = append(conditions, map[string]any{
+			"gold_sequencing_project_identifiers": map[string]any{"$in": sequencingProjectXrefs},
+		})
+	}
+	if len(conditions) == 0 {
+		return map[string][]string{}, nil
+	}
+	dataGenerations, err := db.collectionResources("data_generation_set",
+		map[string]any{"$or": conditions})
+	if err != nil {
+		return nil, err
+	}
+	for _, dataGeneration := range dataGenerations {
+		id, _ := dataGeneration["id"].(string)
+		for _, biosampleId := range stringValues(dataGeneration["has_input"]) {
+			xrefsForDataGeneration[id] = append(xrefsForDataGeneration[id], xrefsForBiosample[biosampleId]...)
+		}
+		for _, xref := range stringValues(dataGeneration["gold_sequencing_project_identifiers"]) {
+			if slices.Contains(sequencingProjectXrefs, xref) {
+				xrefsForDataGeneration[id] = append(xrefsForDataGeneration[id], xref)
+			}
+		}
+	}
+	if len(xrefsForDataGeneration) == 0 {
+		return map[string][]string{}, nil
+	}
+
+	// find the workflow executions that analyzed their data
+	workflowExecs, err := db.collectionResources("workflow_execution_set",
+		map[string]any{"was_informed_by": map[string]any{
+			"$in": slices.Collect(maps.Keys(xrefsForDataGeneration)),
+		}})
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[string][]string)
+	for _, workflowExec := range workflowExecs {
+		id, _ := workflowExec["id"].(string)
+		for _, dataGenerationId := range stringValues(workflowExec["was_informed_by"]) {
+			for _, xref := range xrefsForDataGeneration[dataGenerationId] {
+				if !slices.Contains(resolved[xref], workflowIdPrefix+id) {
+					resolved[xref] = append(resolved[xref], workflowIdPrefix+id)
+				}
+			}
 		}
 	}
-	return descriptors, nil
+	return resolved, nil
+}
+
+// returns all records in the NMDC schema collection with the given name that
+// match the given (MongoDB-style) filter
+func (db Database) collectionResources(collection string, filter map[string]any) ([]map[string]any, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	var resources []map[string]any
+	pageToken := ""
+	for {
+		p := url.Values{}
+		p.Add("filter", string(filterJSON))
+		p.Add("max_page_size", "1000")
+		if pageToken != "" {
+			p.Add("page_token", pageToken)
+		}
+		body, err := db.get(fmt.Sprintf("nmdcschema/%s", collection), p)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Resources     []map[string]any `json:"resources"`
+			NextPageToken string           `json:"next_page_token"`
+		}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return resources, nil
+}
+
+// fetches metadata for the data objects with the given IDs
+func (db Database) dataObjectsWithIds(dataObjectIds []string) ([]DataObject, error) {
+	dataObjects := make([]DataObject, len(dataObjectIds))
+	for i, dataObjectId := range dataObjectIds {
+		body, err := db.get(fmt.Sprintf("data_objects/%s", dataObjectId), url.Values{})
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(body, &dataObjects[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dataObjects, nil
+}
+
+// extracts an optional list of accepted data object types from the given
+// transfer instructions ("data_object_types")
+func dataObjectTypesFromInstructions(instructions map[string]any) ([]string, error) {
+	value, found := instructions["data_object_types"]
+	if !found {
+		return nil, nil
+	}
+	invalidTypesError := &databases.InvalidInstructionsError{
+		Database: "nmdc",
+		Message:  "data_object_types must be an array of strings",
+	}
+	values, ok := value.([]any)
+	if !ok {
+		return nil, invalidTypesError
+	}
+	types := make([]string, len(values))
+	for i, v := range values {
+		if types[i], ok = v.(string); !ok {
+			return nil, invalidTypesError
+		}
+	}
+	return types, nil
 }
 
 // returns descriptors for data objects and related biosample metadata
-// using workflow execution IDs (can be expensive)
-func (db Database) createDataObjectAndBiosampleDescriptors(dataObjects []DataObject) ([]map[string]any, []map[string]any, error) {
+// using workflow execution IDs (can be expensive), along with the biosample
+// fetched for each workflow execution ID (nil if it has none)
+func (db Database) createDataObjectAndBiosampleDescriptors(dataObjects []DataObject) ([]map[string]any, []map[string]any, map[string]map[string]any, error) {
 	// create data object descriptors and fill in metadata
 	dataObjectDescriptors := make([]map[string]any, len(dataObjects))
 	creditForWorkflow := make(map[string]credit.CreditMetadata)
-	biosampleForWorkflow := make(map[string]any)
+	biosampleForWorkflow := make(map[string]map[string]any)
 	for i, dataObject := range dataObjects {
 		workflowId := dataObject.WasGeneratedBy
 		if _, found := creditForWorkflow[workflowId]; !found {
 			var err error
 			creditForWorkflow[workflowId], biosampleForWorkflow[workflowId], err = db.creditAndBiosampleForWorkflow(workflowId)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 		}
 		dataObjectDescriptors[i] = db.createDataObjectDescriptor(dataObject, creditForWorkflow[workflowId])
+		if biosample := biosampleForWorkflow[workflowId]; biosample != nil {
+			frictionless.SetTaxonomy(dataObjectDescriptors[i], taxonomyFromBiosample(biosample))
+			frictionless.AddCrossReferences(dataObjectDescriptors[i], crossReferencesFromBiosample(biosample)...)
+		}
 	}
 
 	// create biosample descriptors
 	biosampleDescriptors := make([]map[string]any, len(biosampleForWorkflow))
-	for _, b := range biosampleForWorkflow {
-		biosample := b.(map[string]any)
+	for _, biosample := range biosampleForWorkflow {
 		var studyIds []string
 		switch s := biosample["associated_studies"].(type) {
 		case string:
@@ -570,9 +1189,12 @@ func (db Database) createDataObjectAndBiosampleDescriptors(dataObjects []DataObj
 		}
 	}
 
-	return dataObjectDescriptors, biosampleDescriptors, nil
+	return dataObjectDescriptors, biosampleDescriptors, biosampleForWorkflow, nil
 }
 
+// the license under which the NMDC publishes its data
+var dataLicense = frictionless.ParseLicense("CC-BY-4.0")
+
 // returns a descriptor for the given data object, including the given credit
 // metadata (mined from the study to which the data object belongs)
 func (db Database) createDataObjectDescriptor(dataObject DataObject, studyCredit credit.CreditMetadata) map[string]any {
@@ -585,24 +1207,30 @@ func (db Database) createDataObjectDescriptor(dataObject DataObject, studyCredit
 		})
 	objectCredit.Identifier = dataObject.Id
 	objectCredit.Url = dataObject.URL
+	format := formatFromTypeAndName(dataObject.Type, dataObject.Name)
+	mediatype := formats.MimeTypeForFormat(format)
+	if mediatype == "" {
+		mediatype = formats.MimeTypeForFile(dataObject.URL)
+	}
 	descriptor := map[string]any{
 		"bytes":       dataObject.FileSizeBytes,
 		"credit":      objectCredit,
 		"description": dataObject.Description,
-		"format":      formatFromType(dataObject.Type),
+		"format":      format,
 		"hash":        dataObject.MD5Checksum,
 		"id":          dataObject.Id,
-		"mediatype":   mimetypeForFile(dataObject.URL),
-		"name":        dataResourceName(dataObject.Name),
+		"mediatype":   mediatype,
+		"name":        frictionless.ResourceName(dataObject.Name),
 		"path":        dataObject.URL,
 	}
+	frictionless.SetBrowseURL(descriptor, dataObject.URL)
+	frictionless.SetLicenses(descriptor, dataLicense)
 
 	// strip the host from the resource's path and assign it an endpoint
 	for hostURL, endpoint := range db.EndpointForHost {
 		if strings.Contains(descriptor["path"].(string), hostURL) {
 			path := strings.Replace(descriptor["path"].(string), hostURL, "", 1)
-			// URL-encode the path to prevent "nmdc:" from being interpreted as a URL protocol
-			descriptor["path"] = url.QueryEscape(path)
+			descriptor["path"] = encodePath(path)
 			descriptor["endpoint"] = endpoint
 		}
 	}
@@ -610,6 +1238,78 @@ func (db Database) createDataObjectDescriptor(dataObject DataObject, studyCredit
 	return descriptor
 }
 
+// extracts normalized taxonomic information (the sample's NCBI taxon and its
+// environment terms) from the given biosample metadata
+// (see https://microbiomedata.github.io/nmdc-schema/Biosample/)
+func taxonomyFromBiosample(biosample map[string]any) frictionless.Taxonomy {
+	var taxonomy frictionless.Taxonomy
+	if id, name, rawValue := controlledTerm(biosample["samp_taxon_id"]); id != "" || rawValue != "" {
+		taxonomy.NCBITaxId = frictionless.ParseNCBITaxId(id)
+		if taxonomy.NCBITaxId == 0 {
+			taxonomy.NCBITaxId = frictionless.ParseNCBITaxId(rawValue)
+		}
+		taxonomy.ScientificName = name
+		if name == "" { // e.g. "soil metagenome [NCBITaxon:410658]"
+			taxonomy.ScientificName, _, _ = strings.Cut(rawValue, "[")
+			taxonomy.ScientificName = strings.TrimSpace(taxonomy.ScientificName)
+		}
+	}
+	for _, scale := range []string{"broad_scale", "local_scale", "medium"} {
+		id, name, rawValue := controlledTerm(biosample["env_"+scale])
+		if id == "" && name == "" {
+			name = rawValue
+		}
+		if id != "" || name != "" {
+			taxonomy.Environment = append(taxonomy.Environment, frictionless.EnvironmentTerm{
+				Scale: scale,
+				Id:    id,
+				Name:  name,
+			})
+		}
+	}
+	return taxonomy
+}
+
+// returns the ID, name, and raw value of the given controlled (identified)
+// term value
+// (see https://microbiomedata.github.io/nmdc-schema/ControlledIdentifiedTermValue/)
+func controlledTerm(value any) (string, string, string) {
+	termValue, ok := value.(map[string]any)
+	if !ok {
+		return "", "", ""
+	}
+	rawValue, _ := termValue["has_raw_value"].(string)
+	term, _ := termValue["term"].(map[string]any)
+	id, _ := term["id"].(string)
+	name, _ := term["name"].(string)
+	return id, name, rawValue
+}
+
+// URL-encodes the given path (relative to an NMDC host) for use in a
+// descriptor, preventing "nmdc:" from being interpreted as a URL protocol
+func encodePath(path string) string {
+	return url.QueryEscape(path)
+}
+
+// decodes a path encoded by encodePath, yielding the path of the file on its
+// endpoint
+func (db *Database) DecodePath(path string) (string, error) {
+	return url.QueryUnescape(path)
+}
+
+// NMDC's endpoints hold different files under different hosts, so a file is
+// available only at its own endpoint
+func (db *Database) RelocatePath(path, fromEndpoint, toEndpoint string) (string, error) {
+	if fromEndpoint != toEndpoint {
+		return "", &databases.ResourceNotMirroredError{
+			Database: "nmdc",
+			Path:     path,
+			Endpoint: toEndpoint,
+		}
+	}
+	return path, nil
+}
+
 // fetch credit and biosample metadata related to the given workflow execution ID
 func (db *Database) creditAndBiosampleForWorkflow(workflowExecId string) (credit.CreditMetadata, map[string]any, error) {
 	var relatedCredit credit.CreditMetadata
@@ -662,7 +1362,7 @@ func (db Database) creditMetadataForStudy(study Study) credit.CreditMetadata {
 			ContributorType:  "Person",
 			ContributorId:    association.Person.Orcid,
 			Name:             association.Person.Name,
-			ContributorRoles: strings.Join(association.Roles, ","),
+			ContributorRoles: credit.NormalizeRoles(association.Roles),
 		}
 		names := strings.Split(" ", association.Person.Name)
 		contributors[i].GivenName = names[0]
@@ -718,6 +1418,7 @@ func (db Database) creditMetadataForStudy(study Study) credit.CreditMetadata {
 	return credit.CreditMetadata{
 		Contributors: contributors,
 		Funding:      fundingSources,
+		License:      credit.License{Id: dataLicense.Name, Url: dataLicense.Path},
 		Publisher: credit.Organization{
 			OrganizationId:   "ROR:05cwx3318",
 			OrganizationName: "National Microbiome Data Collaborative",
@@ -796,59 +1497,13 @@ var fileTypeToFormat = map[string]string{
 	"TRNA Annotation GFF":                                 "gff3",
 }
 
-// extracts the file format from the name and type of the file
-func formatFromType(fileType string) string {
+// extracts the file format from the type of the file, falling back to its
+// name if the type isn't recognized
+func formatFromTypeAndName(fileType, fileName string) string {
 	if format, found := fileTypeToFormat[fileType]; found {
 		return format
 	}
-	return "unknown"
-}
-
-// extracts the file format from the name and type of the file
-func mimetypeForFile(filename string) string {
-	mimetype := mime.TypeByExtension(filepath.Ext(filename))
-	if mimetype == "" {
-		mimetype = "application/octet-stream"
-	}
-	return mimetype
-}
-
-// creates a Frictionless DataResource-savvy name for a file:
-// * the name consists of lower case characters plus '.', '-', and '_'
-// * all forbidden characters encountered in the filename are removed
-// * a number suffix is added if needed to make the name unique
-func dataResourceName(filename string) string {
-	name := strings.ToLower(filename)
-
-	// remove any file suffix
-	lastDot := strings.LastIndex(name, ".")
-	if lastDot != -1 {
-		name = name[:lastDot]
-	}
-
-	// replace sequences of invalid characters with '_'
-	for {
-		isInvalid := func(c rune) bool {
-			return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' && c != '.'
-		}
-		start := strings.IndexFunc(name, isInvalid)
-		if start >= 0 {
-			nameRunes := []rune(name)
-			end := start + 1
-			for end < len(name) && isInvalid(nameRunes[end]) {
-				end++
-			}
-			if end < len(name) {
-				name = name[:start] + string('_') + name[end:]
-			} else {
-				name = name[:start] + string('_')
-			}
-		} else {
-			break
-		}
-	}
-
-	return name
+	return formats.FormatFromFileName(fileName)
 }
 
 // checks NMDC-specific search parameters
@@ -857,7 +1512,7 @@ func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Val
 	for name, jsonValue := range params {
 		var ok bool
 		switch name {
-		case "activity_id", "data_object_id", "filter", "sort", "sample_id",
+		case "activity_id", "data_object_id", "fields", "filter", "sort", "sample_id",
 			"study_id":
 			var value string
 			if value, ok = jsonValue.(string); !ok {
@@ -867,7 +1522,7 @@ func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Val
 				}
 			}
 			p.Add(name, value)
-		case "fields": // accepts comma-delimited strings
+		case "extra": // accepts comma-delimited strings
 			var value string
 			if value, ok = jsonValue.(string); !ok {
 				return &databases.InvalidSearchParameter{
@@ -876,15 +1531,33 @@ func (db Database) addSpecificSearchParameters(params map[string]any, p *url.Val
 				}
 			}
 			acceptedValues := paramSpec["extra"].([]string)
-			if slices.Contains(acceptedValues, value) {
-				p.Add(name, value)
-			} else {
+			for _, field := range strings.Split(value, ",") {
+				if !slices.Contains(acceptedValues, field) {
+					return &databases.InvalidSearchParameter{
+						Database: "nmdc",
+						Message:  fmt.Sprintf("Invalid requested extra field: %s", field),
+					}
+				}
+			}
+			p.Add(name, value)
+		case "workflow_execution_id":
+			var value string
+			if value, ok = jsonValue.(string); !ok || !strings.HasPrefix(value, "nmdc:wf") {
 				return &databases.InvalidSearchParameter{
 					Database: "nmdc",
-					Message:  fmt.Sprintf("Invalid requested extra field: %s", value),
+					Message:  "Invalid value for parameter workflow_execution_id (must be an nmdc:wf... ID)",
 				}
 			}
-		case "extra": // accepts comma-delimited strings
+			p.Add(name, value)
+		case "include_workflow_inputs":
+			var value bool
+			if value, ok = jsonValue.(bool); !ok {
+				return &databases.InvalidSearchParameter{
+					Database: "nmdc",
+					Message:  "Invalid value for parameter include_workflow_inputs (must be boolean)",
+				}
+			}
+			p.Add(name, strconv.FormatBool(value))
 		default:
 			return &databases.InvalidSearchParameter{
 				Database: "nmdc",
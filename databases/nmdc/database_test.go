@@ -1,17 +1,25 @@
 package nmdc
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/conformance"
 	"github.com/kbase/dts/dtstest"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/frictionless"
 )
 
 const nmdcConfig string = `
@@ -119,6 +127,370 @@ func TestDescriptors(t *testing.T) {
 	}
 }
 
+// tests the translation of DTS queries into NMDC filters
+func TestTranslateQuery(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{}
+
+	query, err := databases.ParseQuery(`data_object_type:"Metagenome Bins" AND file_size_bytes:1024`)
+	assert.Nil(err)
+	translated, err := db.TranslateQuery(query)
+	assert.Nil(err)
+	assert.Equal("data_object_type:Metagenome Bins,file_size_bytes:1024", translated)
+
+	for _, badQuery := range []string{`a:1 OR b:2`, `soil`, `name:"a,b"`} {
+		query, err = databases.ParseQuery(badQuery)
+		assert.Nil(err)
+		_, err = db.TranslateQuery(query)
+		assert.IsType(&databases.InvalidSearchParameter{}, err, badQuery)
+	}
+}
+
+func TestDataObjectTypesFromInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	types, err := dataObjectTypesFromInstructions(map[string]any{
+		"data_object_types": []any{"Metagenome Raw Reads", "Assembly Contigs"},
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"Metagenome Raw Reads", "Assembly Contigs"}, types)
+
+	types, err = dataObjectTypesFromInstructions(nil)
+	assert.Nil(err)
+	assert.Nil(types)
+
+	_, err = dataObjectTypesFromInstructions(map[string]any{"data_object_types": "Assembly Contigs"})
+	assert.IsType(&databases.InvalidInstructionsError{}, err)
+	_, err = dataObjectTypesFromInstructions(map[string]any{"data_object_types": []any{1}})
+	assert.IsType(&databases.InvalidInstructionsError{}, err)
+}
+
+func TestExtraFields(t *testing.T) {
+	assert := assert.New(t)
+	db := Database{}
+
+	// requested extra fields are validated
+	p := url.Values{}
+	err := db.addSpecificSearchParameters(map[string]any{"extra": "omics_type,lat_lon"}, &p)
+	assert.Nil(err)
+	assert.Equal("omics_type,lat_lon", p.Get("extra"))
+	err = db.addSpecificSearchParameters(map[string]any{"extra": "omics_type,color"}, &url.Values{})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+	err = db.addSpecificSearchParameters(map[string]any{"extra": 1}, &url.Values{})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+
+	// extra fields are taken from biosample and data generation metadata
+	metadata := extraFieldMetadata{
+		Biosample: map[string]any{
+			"id":                 "nmdc:bsm-11-abc123",
+			"ecosystem":          "Environmental",
+			"ecosystem_category": "Terrestrial",
+			"lat_lon": map[string]any{
+				"latitude":      46.37,
+				"longitude":     -119.27,
+				"has_raw_value": "46.37 -119.27",
+			},
+		},
+		DataGeneration: map[string]any{
+			"analyte_category": "metagenome",
+			"instrument_used":  []any{"nmdc:inst-14-mr4r2w09"},
+		},
+		Instruments: []string{"NovaSeq 6000"},
+	}
+	extras := extraFieldsFromMetadata([]string{"biosample_id", "ecosystem", "instrument",
+		"lat_lon", "omics_type"}, metadata)
+	assert.Equal(map[string]any{
+		"biosample_id": "nmdc:bsm-11-abc123",
+		"ecosystem": map[string]any{
+			"ecosystem":          "Environmental",
+			"ecosystem_category": "Terrestrial",
+		},
+		"instrument": []string{"NovaSeq 6000"},
+		"lat_lon": map[string]any{
+			"latitude":  46.37,
+			"longitude": -119.27,
+		},
+		"omics_type": "metagenome",
+	}, extras)
+
+	// only requested (and available) fields are included
+	extras = extraFieldsFromMetadata([]string{"omics_type", "lat_lon"},
+		extraFieldMetadata{DataGeneration: metadata.DataGeneration})
+	assert.Equal(map[string]any{"omics_type": "metagenome"}, extras)
+
+	// instrument names already fetched are taken from the given cache
+	names, err := db.instrumentNames([]string{"nmdc:inst-14-mr4r2w09"},
+		map[string]string{"nmdc:inst-14-mr4r2w09": "NovaSeq 6000"})
+	assert.Nil(err)
+	assert.Equal([]string{"NovaSeq 6000"}, names)
+}
+
+func TestTaxonomyFromBiosample(t *testing.T) {
+	assert := assert.New(t)
+	biosample := map[string]any{
+		"id": "nmdc:bsm-11-abc123",
+		"samp_taxon_id": map[string]any{
+			"has_raw_value": "soil metagenome [NCBITaxon:410658]",
+		},
+		"env_broad_scale": map[string]any{
+			"has_raw_value": "ENVO:00000446",
+			"term": map[string]any{
+				"id":   "ENVO:00000446",
+				"name": "terrestrial biome",
+			},
+		},
+		"env_medium": map[string]any{
+			"term": map[string]any{
+				"id":   "ENVO:00001998",
+				"name": "soil",
+			},
+		},
+	}
+	assert.Equal(frictionless.Taxonomy{
+		NCBITaxId:      410658,
+		ScientificName: "soil metagenome",
+		Environment: []frictionless.EnvironmentTerm{
+			{Scale: "broad_scale", Id: "ENVO:00000446", Name: "terrestrial biome"},
+			{Scale: "medium", Id: "ENVO:00001998", Name: "soil"},
+		},
+	}, taxonomyFromBiosample(biosample))
+
+	assert.True(taxonomyFromBiosample(map[string]any{}).IsEmpty())
+}
+
+func TestCrossReferences(t *testing.T) {
+	assert := assert.New(t)
+	biosample := map[string]any{
+		"id":                          "nmdc:bsm-11-abc123",
+		"gold_biosample_identifiers":  []any{"gold:Gb0115663"},
+		"emsl_biosample_identifiers":  []any{"emsl:0a5b9e2c-7e3d-4f51-a1c7-9f2e0c3b8d41"},
+		"insdc_biosample_identifiers": []any{"biosample:SAMN05163187"},
+	}
+	assert.Equal([]string{"nmdc:bsm-11-abc123", "gold:Gb0115663",
+		"emsl:0a5b9e2c-7e3d-4f51-a1c7-9f2e0c3b8d41", "biosample:SAMN05163187"},
+		crossReferencesFromBiosample(biosample))
+	dataGeneration := map[string]any{
+		"id":                                  "nmdc:omprc-11-xyz789",
+		"gold_sequencing_project_identifiers": []any{"gold:Gp0115663"},
+	}
+	assert.Equal([]string{"nmdc:omprc-11-xyz789", "gold:Gp0115663"},
+		crossReferencesFromDataGeneration(dataGeneration))
+
+	// missing metadata has no cross-references
+	assert.Empty(crossReferencesFromBiosample(nil))
+	assert.Empty(crossReferencesFromDataGeneration(nil))
+
+	// cross-references can be requested as an extra field
+	err := Database{}.addSpecificSearchParameters(map[string]any{"extra": "xrefs"}, &url.Values{})
+	assert.Nil(err)
+}
+
+// tests that data object paths on both NMDC hosts are encoded in descriptors
+// and decoded to their locations on the corresponding endpoints
+func TestDataObjectPaths(t *testing.T) {
+	assert := assert.New(t)
+	db := &Database{
+		EndpointForHost: map[string]string{
+			"https://data.microbiomedata.org/data/": "globus-nmdc-nersc",
+			"https://nmdcdemo.emsl.pnnl.gov/":       "globus-nmdc-emsl",
+		},
+	}
+	for _, test := range []struct {
+		URL, Endpoint, Path string
+	}{
+		{
+			URL:      "https://data.microbiomedata.org/data/nmdc:omprc-11-adjx8k10/nmdc:wfmgan-11-y2cmfj63.1/nmdc_wfmgan-11-y2cmfj63.1_cog.gff",
+			Endpoint: "globus-nmdc-nersc",
+			Path:     "nmdc:omprc-11-adjx8k10/nmdc:wfmgan-11-y2cmfj63.1/nmdc_wfmgan-11-y2cmfj63.1_cog.gff",
+		},
+		{
+			URL:      "https://nmdcdemo.emsl.pnnl.gov/lipidomics/blanchard_11_8ws97026/Brodie_158_MeOH_R1_23Mar19.raw",
+			Endpoint: "globus-nmdc-emsl",
+			Path:     "lipidomics/blanchard_11_8ws97026/Brodie_158_MeOH_R1_23Mar19.raw",
+		},
+	} {
+		descriptor := db.createDataObjectDescriptor(DataObject{
+			Id:   "nmdc:dobj-11-abc123",
+			Name: "file.gff",
+			URL:  test.URL,
+		}, credit.CreditMetadata{})
+		assert.Equal(test.Endpoint, descriptor["endpoint"])
+		path := descriptor["path"].(string)
+		assert.NotContains(path, "/") // encoded
+		decoded, err := databases.EndpointPath(db, path)
+		assert.Nil(err)
+		assert.Equal(test.Path, decoded)
+	}
+
+	// HTML reports and images can be viewed at their URLs
+	url := "https://data.microbiomedata.org/data/nmdc:omprc-11-adjx8k10/nmdc:wfrbt-11-abc.1/nmdc_wfrbt-11-abc.1_kronaplot.html"
+	descriptor := db.createDataObjectDescriptor(DataObject{
+		Id:   "nmdc:dobj-11-def456",
+		Name: "kronaplot.html",
+		URL:  url,
+	}, credit.CreditMetadata{})
+	assert.Equal(url, descriptor["browse_url"])
+
+	// files can't be relocated between hosts, which hold different files
+	path := descriptor["path"].(string)
+	relocated, err := db.RelocatePath(path, "globus-nmdc-nersc", "globus-nmdc-nersc")
+	assert.Nil(err)
+	assert.Equal(path, relocated)
+	_, err = db.RelocatePath(path, "globus-nmdc-nersc", "globus-nmdc-emsl")
+	assert.IsType(&databases.ResourceNotMirroredError{}, err)
+}
+
+// a stand-in for the NMDC API that serves a single workflow execution with
+// one input and two outputs
+func serveWorkflowApi(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer nmdc-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	const workflowId = "nmdc:wfmgan-11-abc123.1"
+	dataObject := func(id, generatedBy string) map[string]any {
+		return map[string]any{
+			"id":               id,
+			"name":             id + ".fna",
+			"url":              "https://data.microbiomedata.org/data/" + id + ".fna",
+			"type":             "nmdc:DataObject",
+			"file_size_bytes":  1024,
+			"was_generated_by": generatedBy,
+		}
+	}
+	var body any
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "workflow_executions/" + workflowId:
+		body = map[string]any{
+			"id":         workflowId,
+			"has_input":  []string{"nmdc:dobj-11-input1"},
+			"has_output": []string{"nmdc:dobj-11-output1", "nmdc:dobj-11-output2"},
+		}
+	case "workflow_executions/" + workflowId + "/related_resources":
+		body = map[string]any{
+			"id":         workflowId,
+			"studies":    []any{map[string]any{"id": "nmdc:sty-11-abc123", "title": "A study"}},
+			"biosamples": []any{map[string]any{"id": "nmdc:bsm-11-abc123"}},
+		}
+	case "data_objects/nmdc:dobj-11-input1":
+		body = dataObject("nmdc:dobj-11-input1", "nmdc:omprc-11-abc123")
+	case "data_objects/nmdc:dobj-11-output1":
+		body = dataObject("nmdc:dobj-11-output1", workflowId)
+	case "data_objects/nmdc:dobj-11-output2":
+		body = dataObject("nmdc:dobj-11-output2", workflowId)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// redirects requests to the NMDC API to the given test server
+type redirectingTransport struct {
+	Server *httptest.Server
+}
+
+func (t redirectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	serverURL, _ := url.Parse(t.Server.URL)
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = serverURL.Scheme, serverURL.Host
+	return t.Server.Client().Transport.RoundTrip(req)
+}
+
+// returns an NMDC database that sends its requests to the given test server
+func stubbedDatabase(server *httptest.Server) *Database {
+	return &Database{
+		Client: http.Client{Transport: redirectingTransport{Server: server}},
+		Auth: authorization{
+			Token:          "nmdc-token",
+			Type:           "Bearer",
+			Expires:        true,
+			ExpirationTime: time.Now().Add(time.Hour),
+		},
+		EndpointForHost: map[string]string{
+			"https://data.microbiomedata.org/data/": "globus-nmdc-nersc",
+		},
+	}
+}
+
+// tests the expansion of workflow executions into the IDs of their data
+// objects against a stand-in for the NMDC API
+func TestExpandWorkflowExecutions(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(serveWorkflowApi))
+	defer server.Close()
+	db := stubbedDatabase(server)
+
+	// a workflow execution expands to its outputs (without duplicates)
+	fileIds, datasets, err := db.ExpandFileIds("", []string{"nmdc:dobj-11-output1",
+		"NMDC-WORKFLOW:nmdc:wfmgan-11-abc123.1"}, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"nmdc:dobj-11-output1", "nmdc:dobj-11-output2"}, fileIds)
+	assert.Equal(map[string][]string{
+		"NMDC-WORKFLOW:nmdc:wfmgan-11-abc123.1": {"nmdc:dobj-11-output1", "nmdc:dobj-11-output2"},
+	}, datasets)
+
+	// ... and optionally to its inputs as well
+	fileIds, _, err = db.ExpandFileIds("", []string{"NMDC-WORKFLOW-WITH-INPUTS:nmdc:wfmgan-11-abc123.1"}, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"nmdc:dobj-11-input1", "nmdc:dobj-11-output1", "nmdc:dobj-11-output2"}, fileIds)
+
+	// unknown workflow executions can't be expanded
+	_, _, err = db.ExpandFileIds("", []string{"NMDC-WORKFLOW:nmdc:wfmgan-11-nope.1"}, nil)
+	assert.NotNil(err)
+}
+
+// tests searches for the data objects of a workflow execution against a
+// stand-in for the NMDC API
+func TestSearchWorkflowExecution(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(serveWorkflowApi))
+	defer server.Close()
+	db := stubbedDatabase(server)
+
+	// a workflow execution's outputs are found by default
+	results, err := db.Search("", databases.SearchParameters{
+		Specific: map[string]any{"workflow_execution_id": "nmdc:wfmgan-11-abc123.1"},
+	})
+	assert.Nil(err)
+	assert.Equal(2, len(results.Descriptors))
+	assert.Equal("nmdc:dobj-11-output1", results.Descriptors[0]["id"])
+	assert.Equal("nmdc:dobj-11-output2", results.Descriptors[1]["id"])
+	assert.Equal("globus-nmdc-nersc", results.Descriptors[0]["endpoint"])
+
+	// ... and its inputs are included on request
+	results, err = db.Search("", databases.SearchParameters{
+		Specific: map[string]any{
+			"workflow_execution_id":   "nmdc:wfmgan-11-abc123.1",
+			"include_workflow_inputs": true,
+		},
+	})
+	assert.Nil(err)
+	assert.Equal(3, len(results.Descriptors))
+	assert.Equal("nmdc:dobj-11-input1", results.Descriptors[0]["id"])
+
+	// only workflow execution IDs are accepted
+	_, err = db.Search("", databases.SearchParameters{
+		Specific: map[string]any{"workflow_execution_id": "nmdc:sty-11-abc123"},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+	_, err = db.Search("", databases.SearchParameters{
+		Specific: map[string]any{
+			"workflow_execution_id":   "nmdc:wfmgan-11-abc123.1",
+			"include_workflow_inputs": "yes",
+		},
+	})
+	assert.IsType(&databases.InvalidSearchParameter{}, err)
+}
+
+// runs the database conformance suite against NMDC
+func TestConformance(t *testing.T) {
+	conformance.Run(t, NewDatabase, conformance.Parameters{
+		Orcid:    os.Getenv("DTS_KBASE_TEST_ORCID"),
+		Specific: nmdcSearchParams,
+	})
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	setup()
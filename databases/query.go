@@ -0,0 +1,277 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The DTS query syntax allows users to search any database without learning
+// the quirks of its native query language. A query consists of terms, each of
+// which is a bare word (`prochlorococcus`), a quoted phrase (`"soil core"`),
+// or either of these qualified by a field name (`ecosystem:"Soil"`). Terms are
+// combined with AND and OR (AND binding more tightly), and grouped with
+// parentheses. Adjacent terms are implicitly combined with AND.
+
+// operators that combine terms in a DTS query
+const (
+	QueryAnd = "AND"
+	QueryOr  = "OR"
+)
+
+// A QueryNode is an element of a parsed DTS query: either a term or a set of
+// operands combined with an operator
+type QueryNode struct {
+	// QueryAnd or QueryOr for a combination of operands, or "" for a term
+	Operator string
+	// operands combined by the operator
+	Operands []QueryNode
+	// field to which a term is restricted ("" for any field)
+	Field string
+	// value matched by a term
+	Value string
+	// true if the term's value is a quoted phrase
+	Phrase bool
+}
+
+// QueryTranslatingDatabase is implemented by databases that can translate a
+// parsed DTS query into their native query syntax
+type QueryTranslatingDatabase interface {
+	Database
+	// returns the native query (as given in SearchParameters.Query) equivalent
+	// to the given DTS query, or an error if the query can't be expressed
+	TranslateQuery(query QueryNode) (string, error)
+}
+
+// Parses the given DTS query and translates it into the native query syntax of
+// the given database.
+func TranslateQuery(db Database, query string) (string, error) {
+	translator, ok := db.(QueryTranslatingDatabase)
+	if !ok {
+		return "", &InvalidSearchParameter{
+			Database: "DTS",
+			Message:  "this database does not support the DTS query syntax",
+		}
+	}
+	node, err := ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	return translator.TranslateQuery(node)
+}
+
+// Parses the given DTS query, returning its root node.
+func ParseQuery(query string) (QueryNode, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return QueryNode{}, err
+	}
+	if len(tokens) == 0 {
+		return QueryNode{}, queryError("query is empty")
+	}
+	parser := queryParser{Tokens: tokens}
+	node, err := parser.parseOr()
+	if err != nil {
+		return QueryNode{}, err
+	}
+	if parser.Position < len(tokens) {
+		return QueryNode{}, queryError(fmt.Sprintf("unexpected '%s'", tokens[parser.Position].Text))
+	}
+	return node, nil
+}
+
+func queryError(message string) error {
+	return &InvalidSearchParameter{
+		Database: "DTS",
+		Message:  "invalid query: " + message,
+	}
+}
+
+//------------
+// Tokenizing
+//------------
+
+type queryTokenKind int
+
+const (
+	queryWord queryTokenKind = iota
+	queryPhrase
+	queryField // a word followed by a colon
+	queryLeftParen
+	queryRightParen
+)
+
+type queryToken struct {
+	Kind queryTokenKind
+	Text string
+}
+
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{Kind: queryLeftParen, Text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{Kind: queryRightParen, Text: ")"})
+			i++
+		case r == '"':
+			var phrase strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				phrase.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, queryError("unterminated quoted phrase")
+			}
+			tokens = append(tokens, queryToken{Kind: queryPhrase, Text: phrase.String()})
+			i++ // closing quote
+		default:
+			start := i
+			for ; i < len(runes) && !unicode.IsSpace(runes[i]) &&
+				!strings.ContainsRune(`()":`, runes[i]); i++ {
+			}
+			if i < len(runes) && runes[i] == ':' {
+				if i == start {
+					return nil, queryError("missing field name before ':'")
+				}
+				tokens = append(tokens, queryToken{Kind: queryField, Text: string(runes[start:i])})
+				i++
+			} else {
+				tokens = append(tokens, queryToken{Kind: queryWord, Text: string(runes[start:i])})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+//---------
+// Parsing
+//---------
+
+// a recursive descent parser for the DTS query grammar:
+//
+//	or   := and ("OR" and)*
+//	and  := term ("AND"? term)*
+//	term := "(" or ")" | field? (word | phrase)
+type queryParser struct {
+	Tokens   []queryToken
+	Position int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.Position < len(p.Tokens) {
+		return p.Tokens[p.Position], true
+	}
+	return queryToken{}, false
+}
+
+// returns true if the next token is the given operator
+func (p *queryParser) atOperator(operator string) bool {
+	token, ok := p.peek()
+	return ok && token.Kind == queryWord && token.Text == operator
+}
+
+func (p *queryParser) parseOr() (QueryNode, error) {
+	return p.parseCombination(QueryOr, p.parseAnd)
+}
+
+func (p *queryParser) parseAnd() (QueryNode, error) {
+	return p.parseCombination(QueryAnd, p.parseTerm)
+}
+
+// parses operands joined by the given operator (or implicitly by AND)
+func (p *queryParser) parseCombination(operator string,
+	parseOperand func() (QueryNode, error)) (QueryNode, error) {
+	operand, err := parseOperand()
+	if err != nil {
+		return QueryNode{}, err
+	}
+	operands := []QueryNode{operand}
+	for {
+		if p.atOperator(operator) {
+			p.Position++
+		} else if token, ok := p.peek(); !ok || operator == QueryOr ||
+			token.Kind == queryRightParen || p.atOperator(QueryOr) {
+			break
+		}
+		operand, err = parseOperand()
+		if err != nil {
+			return QueryNode{}, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return QueryNode{Operator: operator, Operands: operands}, nil
+}
+
+func (p *queryParser) parseTerm() (QueryNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return QueryNode{}, queryError("query ends unexpectedly")
+	}
+	p.Position++
+	switch token.Kind {
+	case queryLeftParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return QueryNode{}, err
+		}
+		if token, ok = p.peek(); !ok || token.Kind != queryRightParen {
+			return QueryNode{}, queryError("missing ')'")
+		}
+		p.Position++
+		return node, nil
+	case queryField:
+		value, err := p.parseTerm()
+		if err != nil {
+			return QueryNode{}, err
+		}
+		if value.Operator != "" || value.Field != "" {
+			return QueryNode{}, queryError(fmt.Sprintf("field '%s' must be followed by a word or phrase",
+				token.Text))
+		}
+		value.Field = token.Text
+		return value, nil
+	case queryWord:
+		if token.Text == QueryAnd || token.Text == QueryOr {
+			return QueryNode{}, queryError(fmt.Sprintf("misplaced '%s'", token.Text))
+		}
+		return QueryNode{Value: token.Text}, nil
+	case queryPhrase:
+		return QueryNode{Value: token.Text, Phrase: true}, nil
+	default:
+		return QueryNode{}, queryError(fmt.Sprintf("unexpected '%s'", token.Text))
+	}
+}
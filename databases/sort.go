@@ -0,0 +1,142 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/frictionless"
+)
+
+// fields by which the DTS can sort search results
+var SearchSortFields = []string{"name", "bytes", "format", "date"}
+
+// the maximum number of search results the DTS sorts itself for databases that
+// can't sort them: results are sorted (and then paginated) within this many
+// leading matches
+const MaxSortedSearchResults = 1000
+
+// SortingDatabase is implemented by databases that can sort search results by
+// some of the fields in SearchSortFields themselves
+type SortingDatabase interface {
+	Database
+	// returns true if the database sorts search results by the given field
+	// when it's given in SearchParameters.Sort
+	SortsSearchResults(field string) bool
+}
+
+// Searches the given database for files visible to the user with the given
+// ORCID, sorting the results as requested in params.Sort. If the database
+// can't sort by the requested field, the DTS sorts the results before
+// paginating them.
+func SortedSearch(db Database, orcid string, params SearchParameters) (SearchResults, error) {
+	if params.Sort.Field == "" {
+		return db.Search(orcid, params)
+	}
+	if !slices.Contains(SearchSortFields, params.Sort.Field) {
+		return SearchResults{}, &InvalidSearchParameter{
+			Database: "DTS",
+			Message: fmt.Sprintf("Invalid sort field: %s (must be one of %v)",
+				params.Sort.Field, SearchSortFields),
+		}
+	}
+	if sorter, ok := db.(SortingDatabase); ok && sorter.SortsSearchResults(params.Sort.Field) {
+		return db.Search(orcid, params)
+	}
+
+	// fetch leading results, sort them, and paginate
+	pagination := params.Pagination
+	params.Pagination = SearchPaginationParameters{MaxNum: MaxSortedSearchResults}
+	results, err := db.Search(orcid, params)
+	if err != nil {
+		return results, err
+	}
+	SortDescriptors(results.Descriptors, params.Sort)
+	start := min(pagination.Offset, len(results.Descriptors))
+	end := len(results.Descriptors)
+	if pagination.MaxNum > 0 {
+		end = min(start+pagination.MaxNum, end)
+	}
+	results.Descriptors = results.Descriptors[start:end]
+	return results, nil
+}
+
+// sorts the given Frictionless descriptors according to the given parameters,
+// placing those missing the sorted field last
+func SortDescriptors(descriptors []map[string]any, sort SearchSortParameters) {
+	slices.SortStableFunc(descriptors, func(a, b map[string]any) int {
+		var result int
+		var aFound, bFound bool
+		if sort.Field == "bytes" {
+			var aBytes, bBytes int
+			aBytes, aFound = frictionless.Int(a, "bytes")
+			bBytes, bFound = frictionless.Int(b, "bytes")
+			result = cmp.Compare(aBytes, bBytes)
+		} else {
+			aValue, bValue := sortKey(a, sort.Field), sortKey(b, sort.Field)
+			aFound, bFound = aValue != "", bValue != ""
+			result = cmp.Compare(aValue, bValue)
+		}
+		if aFound != bFound { // missing values sort last
+			if aFound {
+				return -1
+			}
+			return 1
+		}
+		if sort.Descending {
+			return -result
+		}
+		return result
+	})
+}
+
+// returns the value of the given (string-valued) sort field for the given
+// descriptor, or an empty string if it's missing
+func sortKey(descriptor map[string]any, field string) string {
+	if field == "date" {
+		return descriptorDate(descriptor)
+	}
+	value, _ := descriptor[field].(string)
+	return value
+}
+
+// returns the creation date recorded in the credit metadata of the given
+// descriptor, or an empty string if none is found (dates are compared as
+// strings, which works for the ISO 8601 formats used in credit metadata)
+func descriptorDate(descriptor map[string]any) string {
+	creditMetadata, ok := descriptor["credit"].(credit.CreditMetadata)
+	if !ok {
+		return ""
+	}
+	for _, date := range creditMetadata.Dates {
+		if date.Event == "Created" {
+			return date.Date
+		}
+	}
+	if len(creditMetadata.Dates) > 0 {
+		return creditMetadata.Dates[0].Date
+	}
+	return creditMetadata.Version
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// To help diagnose searches that don't return what users expect, the DTS can
+// report the upstream requests a database issues on a user's behalf, with
+// credentials redacted.
+
+// an HTTP request issued by a database to its upstream service
+type UpstreamRequest struct {
+	// HTTP method
+	Method string `json:"method" example:"GET" doc:"the HTTP method of the request"`
+	// requested URL (with credentials redacted)
+	URL string `json:"url" doc:"the requested URL (with credentials redacted)"`
+	// request body (with credentials redacted), if any
+	Body string `json:"body,omitempty" doc:"the body of the request (with credentials redacted), if any"`
+	// HTTP status code of the response (0 if no response was received)
+	Status int `json:"status" example:"200" doc:"the HTTP status code of the response (0 if none was received)"`
+	// time elapsed between issuing the request and receiving its response
+	Latency float64 `json:"latency" example:"0.42" doc:"the time elapsed before the response was received (seconds)"`
+	// error encountered issuing the request, if any
+	Error string `json:"error,omitempty" doc:"a description of the error encountered issuing the request, if any"`
+}
+
+// TracingDatabase is implemented by databases that can report the upstream
+// HTTP requests they issue
+type TracingDatabase interface {
+	Database
+	// routes the database's upstream HTTP requests through the given trace
+	Trace(trace *RequestTrace)
+}
+
+// A RequestTrace records the HTTP requests issued through its transports.
+type RequestTrace struct {
+	mutex    sync.Mutex
+	requests []UpstreamRequest
+}
+
+// Returns a transport that records requests in the trace before passing them
+// to the given transport (or http.DefaultTransport if it's nil).
+func (trace *RequestTrace) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &tracingTransport{Trace: trace, Next: next}
+}
+
+// returns the requests recorded in the trace, in the order they were issued
+func (trace *RequestTrace) Requests() []UpstreamRequest {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	requests := make([]UpstreamRequest, len(trace.requests))
+	copy(requests, trace.requests)
+	return requests
+}
+
+// Searches the given database on behalf of the user with the given ORCID as
+// SortedSearch does, returning the upstream requests issued along with the
+// results. The search is performed by a new instance of the database so its
+// requests aren't confused with those of other users. No requests are returned
+// for a database that doesn't implement TracingDatabase.
+func ExplainedSearch(dbName, orcid string, params SearchParameters) (SearchResults, []UpstreamRequest, error) {
+	createDb, found := createDatabaseFuncs_[dbName]
+	if !found {
+		return SearchResults{}, nil, &NotFoundError{dbName}
+	}
+	db, err := createDb()
+	if err != nil {
+		return SearchResults{}, nil, err
+	}
+	var trace RequestTrace
+	if tracingDb, ok := db.(TracingDatabase); ok {
+		tracingDb.Trace(&trace)
+	}
+	results, err := SortedSearch(db, orcid, params)
+	return results, trace.Requests(), err
+}
+
+//-----------
+// Internals
+//-----------
+
+// names of URL query parameters and form fields containing these (in lower
+// case) are redacted
+var redactedNames = []string{"auth", "credential", "key", "password", "secret", "token"}
+
+const redacted = "REDACTED"
+
+type tracingTransport struct {
+	Trace *RequestTrace
+	Next  http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := UpstreamRequest{
+		Method: req.Method,
+		URL:    redactURL(req.URL),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			request.Body = redactBody(req.Header.Get("Content-Type"), string(data))
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.Next.RoundTrip(req)
+	request.Latency = time.Since(start).Seconds()
+	if err != nil {
+		request.Error = err.Error()
+	} else {
+		request.Status = resp.StatusCode
+	}
+
+	t.Trace.mutex.Lock()
+	t.Trace.requests = append(t.Trace.requests, request)
+	t.Trace.mutex.Unlock()
+	return resp, err
+}
+
+func isRedacted(name string) bool {
+	name = strings.ToLower(name)
+	for _, redactedName := range redactedNames {
+		if strings.Contains(name, redactedName) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValues(values url.Values) url.Values {
+	for name := range values {
+		if isRedacted(name) {
+			values[name] = []string{redacted}
+		}
+	}
+	return values
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	redactedURL.User = nil
+	redactedURL.RawQuery = redactValues(u.Query()).Encode()
+	return redactedURL.String()
+}
+
+func redactBody(contentType, body string) string {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(body); err == nil {
+			return redactValues(values).Encode()
+		}
+	}
+	return body
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package databases
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// CrossReferenceResolver is implemented by databases that can find their
+// resources related to identifiers from other repositories (e.g. the JDP
+// projects assigned a GOLD project ID), allowing descriptors from other
+// databases to be linked to them
+type CrossReferenceResolver interface {
+	Database
+	// returns the IDs of resources visible to the user with the given ORCID
+	// that are related to the given cross-references (CURIEs, e.g.
+	// "gold:Gp0115663"), keyed by cross-reference. The IDs can be requested
+	// for transfer, and may identify datasets (e.g. "JDP-PROJECT:<id>").
+	// Cross-references the database doesn't recognize are ignored.
+	ResolveCrossReferences(orcid string, xrefs []string) (map[string][]string, error)
+}
+
+// the maximum number of distinct cross-references resolved for one set of
+// descriptors (e.g. a page of search results), which bounds the number of
+// lookups the other databases make
+var maxCrossReferences = 50
+
+// Fills in the "related_ids" fields of the given descriptors (from the database
+// with the given name) with the IDs of resources in other databases related to
+// their cross-references (see frictionless.CrossReferences), as resolved by
+// every other registered database that can resolve them. The databases
+// resolve them concurrently, and only the first maxCrossReferences distinct
+// cross-references are resolved. A database that can't resolve the
+// cross-references is skipped, and its error logged.
+func LinkRelatedIds(dbName, orcid string, descriptors []map[string]any) {
+	// gather the distinct cross-references of the descriptors
+	var xrefs []string
+	for _, descriptor := range descriptors {
+		for _, xref := range frictionless.CrossReferences(descriptor) {
+			if !slices.Contains(xrefs, xref) {
+				xrefs = append(xrefs, xref)
+			}
+		}
+	}
+	if len(xrefs) == 0 {
+		return
+	}
+	if len(xrefs) > maxCrossReferences {
+		slog.Warn(fmt.Sprintf("Resolving only %d of %d cross-references from %s",
+			maxCrossReferences, len(xrefs), dbName))
+		xrefs = xrefs[:maxCrossReferences]
+	}
+
+	// resolve them in the other databases
+	resolvedIds := make(map[string]map[string][]string) // database -> xref -> IDs
+	var mutex sync.Mutex                                // guards resolvedIds
+	var wg sync.WaitGroup
+	for _, otherDbName := range slices.Sorted(maps.Keys(createDatabaseFuncs_)) {
+		if otherDbName == dbName {
+			continue
+		}
+		db, err := NewDatabase(otherDbName)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Couldn't resolve cross-references in %s: %s", otherDbName, err.Error()))
+			continue
+		}
+		resolver, ok := db.(CrossReferenceResolver)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resolved, err := resolver.ResolveCrossReferences(orcid, xrefs)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("Couldn't resolve cross-references in %s: %s", otherDbName, err.Error()))
+				return
+			}
+			mutex.Lock()
+			resolvedIds[otherDbName] = resolved
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// link each descriptor to the resources related to its cross-references
+	for _, descriptor := range descriptors {
+		relatedIds := frictionless.RelatedIds(descriptor)
+		for otherDbName, resolved := range resolvedIds {
+			for _, xref := range frictionless.CrossReferences(descriptor) {
+				for _, id := range resolved[xref] {
+					if !slices.Contains(relatedIds[otherDbName], id) {
+						relatedIds[otherDbName] = append(relatedIds[otherDbName], id)
+					}
+				}
+			}
+		}
+		frictionless.SetRelatedIds(descriptor, relatedIds)
+	}
+}
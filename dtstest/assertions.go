@@ -0,0 +1,126 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package dtstest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+)
+
+// Asserts that the given descriptor meets the DTS's requirements for
+// descriptors returned by a Database: it must have a non-empty string "id" and
+// "name", and either a string "path" (for a file, which must also have an
+// integer "bytes" field) or a "data" field (for in-line data). Optional "hash",
+// "format", "mediatype", and "endpoint" fields must be strings.
+func AssertValidDescriptor(t testing.TB, descriptor map[string]any) bool {
+	t.Helper()
+	assert := assert.New(t)
+	valid := true
+	for _, field := range []string{"id", "name"} {
+		value, ok := descriptor[field].(string)
+		valid = assert.True(ok && value != "",
+			"descriptor %v has no valid '%s' field", descriptor["id"], field) && valid
+	}
+	_, hasPath := descriptor["path"]
+	_, hasData := descriptor["data"]
+	valid = assert.True(hasPath != hasData,
+		"descriptor %v must have exactly one of 'path' or 'data'", descriptor["id"]) && valid
+	if hasPath {
+		_, ok := descriptor["path"].(string)
+		valid = assert.True(ok, "descriptor %v has a non-string 'path'", descriptor["id"]) && valid
+		_, ok = frictionless.Int(descriptor, "bytes")
+		valid = assert.True(ok, "descriptor %v has no integer 'bytes' field", descriptor["id"]) && valid
+	}
+	for _, field := range []string{"hash", "format", "mediatype", "endpoint"} {
+		if value, found := descriptor[field]; found {
+			_, ok := value.(string)
+			valid = assert.True(ok, "descriptor %v has a non-string '%s'", descriptor["id"], field) && valid
+		}
+	}
+	return valid
+}
+
+// Polls the given endpoint at short intervals for the status of the transfer
+// with the given ID until it succeeds or fails, returning an error if it
+// doesn't complete within the given timeout.
+func WaitForTransfer(endpoint endpoints.Endpoint, id uuid.UUID,
+	timeout time.Duration) (endpoints.TransferStatus, error) {
+	pollInterval := min(timeout/10, 100*time.Millisecond)
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := endpoint.Status(id)
+		if err != nil {
+			return status, err
+		}
+		if status.Code == endpoints.TransferStatusSucceeded ||
+			status.Code == endpoints.TransferStatusFailed {
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			return status, fmt.Errorf("transfer %s didn't complete within %s", id.String(), timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Asserts that the transfer with the given ID at the given endpoint completes
+// successfully within the given timeout.
+func AssertTransferSucceeds(t testing.TB, endpoint endpoints.Endpoint, id uuid.UUID,
+	timeout time.Duration) bool {
+	t.Helper()
+	status, err := WaitForTransfer(endpoint, id, timeout)
+	if !assert.Nil(t, err) {
+		return false
+	}
+	return assert.Equal(t, endpoints.TransferStatusSucceeded, status.Code,
+		"transfer %s failed: %s", id.String(), status.Message)
+}
+
+// Asserts that the files for the given descriptors are present beneath the
+// given folder on the local filesystem (e.g. a destination folder within the
+// root of a local endpoint), with the sizes given in their "bytes" fields.
+func AssertFilesPresent(t testing.TB, folder string, descriptors ...map[string]any) bool {
+	t.Helper()
+	present := true
+	for _, descriptor := range descriptors {
+		path, _ := descriptor["path"].(string)
+		info, err := os.Stat(filepath.Join(folder, path))
+		if !assert.Nil(t, err, "file for descriptor %v is missing", descriptor["id"]) {
+			present = false
+			continue
+		}
+		if size, ok := frictionless.Int(descriptor, "bytes"); ok {
+			present = assert.Equal(t, int64(size), info.Size(),
+				"file for descriptor %v has the wrong size", descriptor["id"]) && present
+		}
+	}
+	return present
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package dtstest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Returns a fresh set of canned Frictionless descriptors for three text files
+// (with IDs "file1", "file2", and "file3") residing on the endpoint with the
+// given name, suitable for use with NewDatabase and RegisterDatabase. The
+// descriptors can be modified freely.
+func Descriptors(endpoint string) map[string]map[string]any {
+	canned := map[string]map[string]any{
+		"file1": {
+			"id":        "file1",
+			"name":      "file1.dat",
+			"path":      "dir1/file1.dat",
+			"format":    "text",
+			"mediatype": "text/plain",
+			"bytes":     1024,
+			"hash":      "d91f97974d06563cab48d4d43a17e08a",
+		},
+		"file2": {
+			"id":        "file2",
+			"name":      "file2.dat",
+			"path":      "dir2/file2.dat",
+			"format":    "text",
+			"mediatype": "text/plain",
+			"bytes":     2048,
+			"hash":      "d91f9e974d0e563cab48d4d43a17e08a",
+		},
+		"file3": {
+			"id":        "file3",
+			"name":      "file3.dat",
+			"path":      "dir3/file3.dat",
+			"format":    "text",
+			"mediatype": "text/plain",
+			"bytes":     4096,
+			"hash":      "e91f9e974d0e563cab48d4d43a17e08e",
+		},
+	}
+	for _, descriptor := range canned {
+		descriptor["endpoint"] = endpoint
+	}
+	return canned
+}
+
+// Writes a file for each of the given descriptors (with a "path" field) at its
+// path beneath the given root directory, updating the descriptor's "bytes" and
+// "hash" (MD5) fields to match the file's generated content. Use this to
+// populate the root directory of a local endpoint with real files.
+func WriteFiles(root string, descriptors map[string]map[string]any) error {
+	for id, descriptor := range descriptors {
+		path, ok := descriptor["path"].(string)
+		if !ok {
+			continue
+		}
+		content := fmt.Appendf(nil, "This is the content of %s.\n", id)
+		absPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(absPath, content, 0644); err != nil {
+			return err
+		}
+		checksum := md5.Sum(content)
+		descriptor["bytes"] = len(content)
+		descriptor["hash"] = hex.EncodeToString(checksum[:])
+	}
+	return nil
+}
@@ -19,14 +19,31 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-// This package contains testing utilities for the Data Transfer System.
+// This package contains testing utilities for the Data Transfer System. It
+// serves as a testing kit for the DTS itself and for authors of external
+// database connectors and endpoint providers, offering
+//
+//   - an in-memory Database test fixture (NewDatabase, RegisterDatabase)
+//   - an Endpoint test fixture that simulates staging and transfers
+//     (NewEndpoint, RegisterEndpoint)
+//   - helpers for setting up local endpoints with real files (WriteFiles)
+//   - canned Frictionless descriptors (Descriptors)
+//   - assertions for descriptors and transfers (AssertValidDescriptor,
+//     AssertTransferSucceeds, AssertFilesPresent)
+//
+// See docs/developer/dtstest.md for examples.
 package dtstest
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -99,23 +116,30 @@ type Endpoint struct {
 	Database *Database
 	// endpoint testing options
 	Options EndpointOptions
-	// a table of ongoing "file transfers"
+	// a table of ongoing "file transfers" and a mutex that guards it
 	Xfers map[uuid.UUID]transferInfo
+	mutex sync.Mutex
 	// root path
 	RootPath string
 }
 
+// Creates an endpoint test fixture with the given options and root path,
+// without registering it in the configuration.
+func NewEndpoint(options EndpointOptions, root string) *Endpoint {
+	return &Endpoint{
+		Options:  options,
+		Xfers:    make(map[uuid.UUID]transferInfo),
+		RootPath: root,
+	}
+}
+
 // Registers an endpoint test fixture with the given name in the configuration,
 // assigning it options that govern its testing behavior, and assigning it the
 // given set of Frictionless descriptors as "test files."
 func RegisterEndpoint(endpointName string, options EndpointOptions) error {
 	slog.Debug(fmt.Sprintf("Registering test endpoint %s...", endpointName))
 	newEndpointFunc := func(name string) (endpoints.Endpoint, error) {
-		return &Endpoint{
-			Options:  options,
-			Xfers:    make(map[uuid.UUID]transferInfo),
-			RootPath: config.Endpoints[endpointName].Root,
-		}, nil
+		return NewEndpoint(options, config.Endpoints[endpointName].Root), nil
 	}
 	provider := config.Endpoints[endpointName].Provider
 	return endpoints.RegisterEndpointProvider(provider, newEndpointFunc)
@@ -141,6 +165,8 @@ func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
 		}
 		// the source endpoint should report true for the staged files as long
 		// as the source database has had time to stage them
+		ep.Database.mutex.Lock()
+		defer ep.Database.mutex.Unlock()
 		for _, req := range ep.Database.Staging {
 			if time.Since(req.Time) < ep.Options.StagingDuration {
 				return false, nil
@@ -151,6 +177,8 @@ func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
 }
 
 func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	xfers := make([]uuid.UUID, 0)
 	for xferId := range ep.Xfers {
 		xfers = append(xfers, xferId)
@@ -160,6 +188,8 @@ func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 
 func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
 	xferId := uuid.New()
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	ep.Xfers[xferId] = transferInfo{
 		Time: time.Now(),
 		Status: endpoints.TransferStatus{
@@ -172,6 +202,8 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 }
 
 func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	if info, found := ep.Xfers[id]; found {
 		if info.Status.Code != endpoints.TransferStatusSucceeded &&
 			time.Since(info.Time) >= ep.Options.TransferDuration { // update if needed
@@ -201,6 +233,23 @@ type Database struct {
 	Endpt       endpoints.Endpoint
 	descriptors map[string]map[string]any
 	Staging     map[uuid.UUID]stagingRequest
+	mutex       sync.Mutex // guards Staging
+}
+
+// Creates an in-memory database test fixture that holds the given descriptors
+// (by file ID) for files residing on the given endpoint, without registering it
+// in the configuration. If the endpoint is itself a test fixture, it's attached
+// to the database so that it reports files as staged consistently with it.
+func NewDatabase(descriptors map[string]map[string]any, endpoint endpoints.Endpoint) *Database {
+	db := &Database{
+		Endpt:       endpoint,
+		descriptors: descriptors,
+		Staging:     make(map[uuid.UUID]stagingRequest),
+	}
+	if testEndpoint, isTestEndpoint := db.Endpt.(*Endpoint); isTestEndpoint {
+		testEndpoint.Database = db
+	}
+	return db
 }
 
 // Registers a database test fixture with the given name in the configuration.
@@ -211,20 +260,12 @@ func RegisterDatabase(databaseName string, descriptors map[string]map[string]any
 		if err != nil {
 			return nil, err
 		}
-		db := Database{
-			Endpt:       endpoint,
-			descriptors: descriptors,
-			Staging:     make(map[uuid.UUID]stagingRequest),
-		}
-		if testEndpoint, isTestEndpoint := db.Endpt.(*Endpoint); isTestEndpoint {
-			testEndpoint.Database = &db
-		}
-		return &db, nil
+		return NewDatabase(descriptors, endpoint), nil
 	}
 	return databases.RegisterDatabase(databaseName, newDatabaseFunc)
 }
 
-func (db Database) SpecificSearchParameters() map[string]any {
+func (db *Database) SpecificSearchParameters() map[string]any {
 	return map[string]any{
 		"happy": false, // can also be true--single value indicates all values valid
 		"day":   []string{"sunday", "monday"},
@@ -236,11 +277,23 @@ func (db *Database) Search(orcid string, params databases.SearchParameters) (dat
 	results := databases.SearchResults{
 		Descriptors: make([]map[string]any, 0),
 	}
-	for fileId, descriptors := range db.descriptors {
+	fileIds := make([]string, 0)
+	for fileId := range db.descriptors {
 		if strings.Contains(params.Query, fileId) {
-			results.Descriptors = append(results.Descriptors, descriptors)
+			fileIds = append(fileIds, fileId)
 		}
 	}
+
+	// return results in a stable order, honoring pagination
+	slices.Sort(fileIds)
+	offset := min(params.Pagination.Offset, len(fileIds))
+	fileIds = fileIds[offset:]
+	if params.Pagination.MaxNum > 0 && params.Pagination.MaxNum < len(fileIds) {
+		fileIds = fileIds[:params.Pagination.MaxNum]
+	}
+	for _, fileId := range fileIds {
+		results.Descriptors = append(results.Descriptors, db.descriptors[fileId])
+	}
 	return results, nil
 }
 
@@ -248,7 +301,8 @@ func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]an
 	descriptors := make([]map[string]any, 0)
 	for _, fileId := range fileIds {
 		if descriptor, found := db.descriptors[fileId]; found {
-			descriptors = append(descriptors, descriptor)
+			// callers may modify descriptors, so we hand out copies
+			descriptors = append(descriptors, maps.Clone(descriptor))
 		}
 	}
 	return descriptors, nil
@@ -256,6 +310,8 @@ func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]an
 
 func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
 	id := uuid.New()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
 	db.Staging[id] = stagingRequest{
 		FileIds: fileIds,
 		Time:    time.Now(),
@@ -264,8 +320,13 @@ func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error
 }
 
 func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
 	if info, found := db.Staging[id]; found {
-		endpoint := db.Endpt.(*Endpoint)
+		endpoint, isTestEndpoint := db.Endpt.(*Endpoint)
+		if !isTestEndpoint { // files on other endpoints are staged immediately
+			return databases.StagingStatusSucceeded, nil
+		}
 		if time.Since(info.Time) >= endpoint.Options.StagingDuration { // FIXME: not always so!
 			return databases.StagingStatusSucceeded, nil
 		}
@@ -287,9 +348,25 @@ func (db *Database) LocalUser(orcid string) (string, error) {
 }
 
 func (db *Database) Save() (databases.DatabaseSaveState, error) {
-	return databases.DatabaseSaveState{}, nil
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	if err := enc.Encode(db.Staging); err != nil {
+		return databases.DatabaseSaveState{}, err
+	}
+	return databases.DatabaseSaveState{
+		Name: "dtstest",
+		Data: buffer.Bytes(),
+	}, nil
 }
 
 func (db *Database) Load(state databases.DatabaseSaveState) error {
-	return nil
+	if len(state.Data) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	dec := gob.NewDecoder(bytes.NewReader(state.Data))
+	return dec.Decode(&db.Staging)
 }
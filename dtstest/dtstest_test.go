@@ -0,0 +1,130 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package dtstest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+)
+
+func TestInMemoryDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := NewEndpoint(EndpointOptions{}, "")
+	db := NewDatabase(Descriptors("test-endpoint"), endpoint)
+	descriptors, err := db.Descriptors("1234-5678-9012-3456", []string{"file1", "file3"})
+	assert.Nil(err)
+	assert.Len(descriptors, 2)
+	for _, descriptor := range descriptors {
+		AssertValidDescriptor(t, descriptor)
+		assert.Equal("test-endpoint", descriptor["endpoint"])
+	}
+	assert.Equal(db, endpoint.Database)
+}
+
+func TestInvalidDescriptor(t *testing.T) {
+	assert := assert.New(t)
+
+	// run the assertion against a mock T so its failure doesn't fail this test
+	mockT := new(testing.T)
+	assert.False(AssertValidDescriptor(mockT, map[string]any{
+		"id":    "file1",
+		"path":  "dir1/file1.dat",
+		"data":  "both path and data!",
+		"bytes": "1024",
+	}))
+}
+
+func TestLocalTransferAssertions(t *testing.T) {
+	assert := assert.New(t)
+
+	// populate the source endpoint with real files
+	descriptors := Descriptors("source")
+	err := WriteFiles(sourceRoot, descriptors)
+	assert.Nil(err)
+
+	source, err := local.NewEndpoint("source")
+	assert.Nil(err)
+	destination, err := local.NewEndpoint("destination")
+	assert.Nil(err)
+
+	var files []endpoints.FileTransfer
+	for _, descriptor := range descriptors {
+		AssertValidDescriptor(t, descriptor)
+		path := descriptor["path"].(string)
+		files = append(files, endpoints.FileTransfer{
+			SourcePath:      path,
+			DestinationPath: filepath.Join("delivery", path),
+		})
+	}
+	xferId, err := source.Transfer(destination, files)
+	assert.Nil(err)
+	AssertTransferSucceeds(t, source, xferId, 5*time.Second)
+	AssertFilesPresent(t, filepath.Join(destinationRoot, "delivery"),
+		descriptors["file1"], descriptors["file2"], descriptors["file3"])
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	tempRoot, err := os.MkdirTemp(os.TempDir(), "dtstest-")
+	if err != nil {
+		panic(err)
+	}
+	sourceRoot = filepath.Join(tempRoot, "source")
+	destinationRoot = filepath.Join(tempRoot, "destination")
+	os.Mkdir(sourceRoot, 0755)
+	os.Mkdir(destinationRoot, 0755)
+	myConfig := strings.ReplaceAll(kitConfig, "SOURCE_ROOT", sourceRoot)
+	myConfig = strings.ReplaceAll(myConfig, "DESTINATION_ROOT", destinationRoot)
+	err = config.InitSelected([]byte(myConfig), false, false, false, true)
+	if err != nil {
+		panic(err)
+	}
+	status := m.Run()
+	os.RemoveAll(tempRoot)
+	os.Exit(status)
+}
+
+var sourceRoot, destinationRoot string
+
+const kitConfig string = `
+endpoints:
+  source:
+    name: Source Endpoint
+    id: 2ee69538-10d5-4d1e-a890-1127b5e42003
+    provider: local
+    root: SOURCE_ROOT
+  destination:
+    name: Destination Endpoint
+    id: b925d96e-7e39-473b-a658-714f8c243b1c
+    provider: local
+    root: DESTINATION_ROOT
+`
@@ -0,0 +1,111 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package endpoints
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the results of a bandwidth test between two endpoints
+type BandwidthReport struct {
+	// size of the synthetic test payload (bytes)
+	PayloadSize int64 `json:"payload_size"`
+	// time taken by the source endpoint to accept the transfer request
+	Latency time.Duration `json:"latency"`
+	// time between the transfer request and the detection of its completion
+	// (accurate to within the polling interval)
+	Duration time.Duration `json:"duration"`
+	// achieved throughput (bytes per second)
+	Throughput float64 `json:"throughput"`
+}
+
+// Measures the throughput between the given source and destination endpoints
+// by transferring a synthetic payload of the given size (in bytes), polling
+// for completion at the given interval and giving up after the given timeout.
+// The payload is written to a scratch folder under the source endpoint's root,
+// so the source endpoint must be able to read files the DTS writes there. The
+// payload is removed from the source (and from the destination, if the
+// destination endpoint can delete files) afterward.
+func MeasureBandwidth(source, destination Endpoint, size int64,
+	pollInterval, timeout time.Duration) (BandwidthReport, error) {
+	report := BandwidthReport{PayloadSize: size}
+
+	// write the payload (random, so it can't be compressed in transit)
+	folder := fmt.Sprintf("dts-bandwidth-test-%s", uuid.New().String())
+	payload := filepath.Join(folder, "payload.dat")
+	scratchFolder := filepath.Join(source.Root(), folder)
+	if err := os.MkdirAll(scratchFolder, 0755); err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(scratchFolder)
+	file, err := os.Create(filepath.Join(source.Root(), payload))
+	if err != nil {
+		return report, err
+	}
+	_, err = io.CopyN(file, rand.Reader, size)
+	file.Close()
+	if err != nil {
+		return report, err
+	}
+
+	// transfer it and wait for the transfer to complete
+	start := time.Now()
+	xferId, err := source.Transfer(destination, []FileTransfer{
+		{
+			SourcePath:      payload,
+			DestinationPath: payload,
+		},
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Latency = time.Since(start)
+	if deleter, ok := destination.(DeletingEndpoint); ok {
+		defer deleter.Delete(folder)
+	}
+	for {
+		status, err := source.Status(xferId)
+		if err != nil {
+			return report, err
+		}
+		if status.Code == TransferStatusSucceeded {
+			break
+		} else if status.Code == TransferStatusFailed {
+			return report, fmt.Errorf("bandwidth test transfer failed: %s", status.Message)
+		}
+		if time.Since(start) > timeout {
+			source.Cancel(xferId)
+			return report, fmt.Errorf("bandwidth test transfer timed out after %s", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+	report.Duration = time.Since(start)
+	report.Throughput = float64(size) / report.Duration.Seconds()
+	return report, nil
+}
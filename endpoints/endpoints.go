@@ -22,6 +22,11 @@
 package endpoints
 
 import (
+	"context"
+	"io"
+	"path/filepath"
+	"sync"
+
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
@@ -34,6 +39,39 @@ type FileTransfer struct {
 	SourcePath, DestinationPath string
 	// Hash and hash algorithm used to validate the file
 	Hash, HashAlgorithm string
+	// if set, the source path is a directory whose contents are transferred
+	// recursively, subject to any filter rules
+	Recursive bool
+	// rules selecting the contents of a recursively-transferred directory
+	FilterRules []FilterRule
+}
+
+// this type holds a rule that includes or excludes items within a directory
+// being transferred recursively. Rules are evaluated in order for each item,
+// and the first rule matching the item decides whether it's transferred. Items
+// matching no rule are transferred.
+type FilterRule struct {
+	// "include" or "exclude"
+	Method string
+	// "file" or "dir" to apply the rule only to files or directories (the rule
+	// applies to both if this is empty)
+	Type string
+	// a glob pattern (e.g. "*.fastq.gz") matched against item names
+	Name string
+}
+
+// returns true if an item with the given name (of the given type) within a
+// recursively-transferred directory is selected by the given filter rules
+func FilterRulesInclude(rules []FilterRule, name string, isDir bool) bool {
+	for _, rule := range rules {
+		if (rule.Type == "file" && isDir) || (rule.Type == "dir" && !isDir) {
+			continue
+		}
+		if matched, _ := filepath.Match(rule.Name, name); matched {
+			return rule.Method == "include"
+		}
+	}
+	return true
 }
 
 // this "enum" type encodes the status of a file transfer between endpoints
@@ -47,6 +85,7 @@ const (
 	TransferStatusFinalizing                    // transfer manifest being generated
 	TransferStatusSucceeded                     // transfer completed successfully
 	TransferStatusFailed                        // transfer failed or was canceled
+	TransferStatusWaiting                       // task waiting for other tasks to succeed
 )
 
 // this type conveys various information about a file transfer's status
@@ -61,6 +100,12 @@ type TransferStatus struct {
 	NumFilesTransferred int
 	// number of files that are skipped for whatever reason
 	NumFilesSkipped int
+	// number of bytes that have been transferred
+	NumBytesTransferred int64
+	// total number of bytes being transferred (0 if unknown)
+	NumBytes int64
+	// effective transfer rate in bytes per second (0 if unknown)
+	BytesPerSecond float64
 }
 
 // This type represents an endpoint for transferring files.
@@ -85,8 +130,129 @@ type Endpoint interface {
 	Cancel(id uuid.UUID) error
 }
 
-// we maintain a table of endpoint instances, identified by their names
+// This type represents an endpoint whose provider can suspend and resume
+// transfers in progress. Transfers from endpoints that don't implement this
+// interface can't be paused once they've begun.
+type PausableEndpoint interface {
+	Endpoint
+	// Suspends the transfer task with the given UUID.
+	Pause(id uuid.UUID) error
+	// Resumes the suspended transfer task with the given UUID.
+	Resume(id uuid.UUID) error
+}
+
+// This type represents an endpoint with direct access to its files, which can
+// compute checksums for files whose sources don't publish them.
+type ChecksummingEndpoint interface {
+	Endpoint
+	// Returns the checksum (in hexadecimal) of the file with the given path
+	// (relative to the endpoint's root), computed with the given hash algorithm
+	// ("md5" or "sha256").
+	Checksum(path, algorithm string) (string, error)
+}
+
+// This type represents an endpoint that can delete files it holds, allowing
+// the DTS to clean up intermediate copies of files relayed through it.
+type DeletingEndpoint interface {
+	Endpoint
+	// Deletes the file or directory (recursively) with the given path (relative
+	// to the endpoint's root). Deletion may complete asynchronously.
+	Delete(path string) error
+}
+
+// This type represents an endpoint that can move files it holds, allowing the
+// DTS to set aside delivered files that fail checksum verification and to put
+// final manifests in place of provisional ones.
+type MovingEndpoint interface {
+	Endpoint
+	// Moves the file with the given source path to the given destination path
+	// (both relative to the endpoint's root), creating any missing folders.
+	Move(source, destination string) error
+}
+
+// This type represents an endpoint that can serve individual files directly
+// (e.g. over HTTPS) without a transfer task, which is practical only for small
+// files.
+type DownloadingEndpoint interface {
+	Endpoint
+	// Returns true if the endpoint can currently serve files directly.
+	CanDownload() bool
+	// Writes the contents of the file with the given path (relative to the
+	// endpoint's root) to the given writer, failing if the file is larger than
+	// the given number of bytes.
+	Download(path string, w io.Writer, maxBytes int64) error
+}
+
+// This type represents an endpoint to which files can be uploaded directly
+// (e.g. over SFTP), allowing endpoints with access to a local file system to
+// transfer files to it.
+type UploadingEndpoint interface {
+	Endpoint
+	// Uploads the local file with the given path to the given path (relative
+	// to the endpoint's root), creating any missing folders.
+	Upload(localPath, path string) error
+}
+
+// This type represents an endpoint that can encrypt the data it transfers.
+// Destination endpoints configured to require encryption accept transfers only
+// from endpoints that implement this interface and report that they encrypt.
+type EncryptingEndpoint interface {
+	Endpoint
+	// Returns true if the endpoint encrypts the data it transfers.
+	EncryptsTransfers() bool
+}
+
+// This type represents an endpoint that can skip files whose sources produce
+// errors instead of failing a transfer, reporting the skipped files afterward.
+type SkippingEndpoint interface {
+	Endpoint
+	// Begins a transfer task like Transfer, but skips files whose sources
+	// produce errors (e.g. missing or unreadable files) instead of failing.
+	TransferSkippingErrors(dst Endpoint, files []FileTransfer) (uuid.UUID, error)
+	// Returns the files skipped because of errors by the transfer task with
+	// the given UUID.
+	SkippedFiles(id uuid.UUID) ([]SkippedFile, error)
+}
+
+// This type represents an endpoint that sends the requests initiating a
+// transfer with a given context, propagating the trace context it carries to
+// the endpoint's provider so the provider's work appears in the same trace.
+type TracingEndpoint interface {
+	Endpoint
+	// Begins a transfer task like Transfer, sending its requests with the
+	// given context. If skipErrors is set, the task skips files whose sources
+	// produce errors, like TransferSkippingErrors.
+	TransferWithContext(ctx context.Context, dst Endpoint, files []FileTransfer,
+		skipErrors bool) (uuid.UUID, error)
+}
+
+// This type represents an endpoint whose provider accepts a transfer submitted
+// under a given submission ID at most once, so that a submission failing
+// ambiguously (e.g. timing out after its request was sent) can be retried
+// without beginning a second transfer.
+type IdempotentEndpoint interface {
+	Endpoint
+	// Returns a new ID under which a transfer can be submitted.
+	SubmissionId(ctx context.Context) (uuid.UUID, error)
+	// Begins a transfer task like TransferWithContext, submitting it under the
+	// given submission ID. If a transfer has already been submitted under that
+	// ID, returns its UUID instead of beginning another.
+	SubmitTransfer(ctx context.Context, submissionId uuid.UUID, dst Endpoint,
+		files []FileTransfer, skipErrors bool) (uuid.UUID, error)
+}
+
+// this type identifies a file skipped by a transfer because of an error
+type SkippedFile struct {
+	// source path of the file, as given in its FileTransfer
+	SourcePath string
+	// description of the error that caused the file to be skipped
+	Error string
+}
+
+// we maintain a table of endpoint instances, identified by their names, and a
+// mutex that guards it
 var allEndpoints map[string]Endpoint = make(map[string]Endpoint)
+var allEndpointsMutex sync.Mutex
 
 // here's a table of endpoint creation functions
 var createEndpointFuncs = make(map[string]func(name string) (Endpoint, error))
@@ -107,6 +273,9 @@ func RegisterEndpointProvider(provider string, createEp func(name string) (Endpo
 func NewEndpoint(endpointName string) (Endpoint, error) {
 	var err error
 
+	allEndpointsMutex.Lock()
+	defer allEndpointsMutex.Unlock()
+
 	// do we have one of these already?
 	endpoint, found := allEndpoints[endpointName]
 	if !found {
@@ -49,6 +49,21 @@ func TestNonexistentEndpoint(t *testing.T) {
 	assert.NotNil(err, "Nonexistent endpoint creation returned no error")
 }
 
+func TestFilterRulesInclude(t *testing.T) {
+	assert := assert.New(t)
+	rules := []FilterRule{
+		{Method: "include", Type: "dir", Name: "*"},
+		{Method: "include", Name: "*.fastq.gz"},
+		{Method: "exclude", Name: "*"},
+	}
+	assert.True(FilterRulesInclude(rules, "reads.fastq.gz", false))
+	assert.True(FilterRulesInclude(rules, "subdir", true))
+	assert.False(FilterRulesInclude(rules, "assembly.fna", false))
+
+	// items matching no rules are included
+	assert.True(FilterRulesInclude(nil, "assembly.fna", false))
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	var status int
@@ -79,3 +79,14 @@ func (e IncompatibleDestinationError) Error() string {
 	return fmt.Sprintf("The source endpoint '%s' (%s) cannot transfer files to the destination endpoint '%s' (%s)",
 		e.Source, e.SourceProvider, e.Destination, e.DestinationProvider)
 }
+
+// indicates that a file is too large to be downloaded directly from an endpoint
+type FileTooLargeError struct {
+	Endpoint, Path string
+	Size, MaxSize  int64
+}
+
+func (e FileTooLargeError) Error() string {
+	return fmt.Sprintf("The file '%s' on endpoint '%s' (%d bytes) exceeds the maximum size for direct downloads (%d bytes)",
+		e.Path, e.Endpoint, e.Size, e.MaxSize)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package globus
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Globus Connect Server (v5) mapped collections require a data_access consent
+// for each collection before files can be accessed through the Transfer API
+// (see https://docs.globus.org/api/transfer/gcsv5_scopes/). A missing consent
+// surfaces as a ConsentRequired error on the first file operation, so
+// administrators can check consents for all configured collections when a DTS
+// deployment is bootstrapped.
+
+// this type describes the consent status of a Globus collection
+type ConsentStatus struct {
+	// the data_access scope requiring consent (empty if none is required)
+	Scope string
+	// a URL at which consent for the scope can be granted (empty if none is
+	// required)
+	ConsentURL string
+	// true if the collection's root directory could be listed, indicating
+	// that any required consent has been granted
+	Verified bool
+	// a description of the failure to list the collection's root directory
+	// (if any)
+	Message string
+}
+
+// Determines whether the endpoint's collection requires a data_access consent,
+// and verifies access to the collection by listing its root directory.
+func (ep *Endpoint) CheckConsent() ConsentStatus {
+	var status ConsentStatus
+	if ep.Info.EntityType == "GCSv5_mapped_collection" {
+		status.Scope = dataAccessScope(ep.Id.String())
+		status.ConsentURL = consentURL(ep.ClientId.String(), status.Scope)
+	}
+
+	// list the root directory, requesting the data_access scope as a dependent
+	// scope of the transfer scope if needed
+	if status.Scope != "" {
+		err := ep.authenticate([]string{fmt.Sprintf("%s[*%s]", defaultScopes_[0], status.Scope)})
+		if err != nil {
+			status.Message = err.Error()
+			return status
+		}
+	}
+	values := url.Values{}
+	values.Add("path", ep.RootDir)
+	values.Add("limit", "1")
+	resource := fmt.Sprintf("operation/endpoint/%s/ls", ep.Id.String())
+	_, err := ep.get(resource, values)
+	if err != nil {
+		status.Message = err.Error()
+	} else {
+		status.Verified = true
+	}
+	return status
+}
+
+// returns the data_access scope for the collection with the given ID
+func dataAccessScope(collectionId string) string {
+	return fmt.Sprintf("https://auth.globus.org/scopes/%s/data_access", collectionId)
+}
+
+// returns a URL at which a user can grant consent for the given scope to the
+// client with the given ID
+func consentURL(clientId, scope string) string {
+	values := url.Values{}
+	values.Add("client_id", clientId)
+	values.Add("scope", strings.Join([]string{defaultScopes_[0], scope}, " "))
+	values.Add("response_type", "code")
+	values.Add("redirect_uri", "https://auth.globus.org/v2/web/auth-code")
+	values.Add("prompt", "login")
+	return "https://auth.globus.org/v2/oauth2/authorize?" + values.Encode()
+}
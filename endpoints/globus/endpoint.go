@@ -23,6 +23,7 @@ package globus
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -31,11 +32,16 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
 	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/tracing"
 )
 
 // This file implements a Globus endpoint. It uses the Globus Transfer API
@@ -59,6 +65,14 @@ func (e GlobusError) Error() string {
 	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
 }
 
+// returns true if the error may be transient (e.g. Globus or an endpoint is
+// briefly unavailable), false if it results from a problem with the request
+// itself or its permissions, which retrying won't fix
+func (e GlobusError) Transient() bool {
+	return !strings.HasPrefix(e.Code, "ClientError") && e.Code != "PermissionDenied" &&
+		e.Code != "ConsentRequired"
+}
+
 // this type satisfies the endpoints.Endpoint interface for Globus endpoints
 type Endpoint struct {
 	// descriptive endpoint name (obtained from config)
@@ -73,9 +87,24 @@ type Endpoint struct {
 	// authentication stuff
 	ClientId     uuid.UUID
 	ClientSecret string
+	// name of the credential providing the client secret (if any), from
+	// which a rotated secret is fetched when the endpoint reauthenticates
+	Credential string
 
 	// endpoint configuration
 	Info EndpointInfo
+	// set if transfers to this endpoint must be encrypted (obtained from config)
+	EncryptData bool
+
+	// access token for the collection's HTTPS server and its expiration time
+	httpsToken        string
+	httpsTokenExpires time.Time
+
+	// a mutex that guards the access token and client secret, which are
+	// renewed while other requests are in flight, and another that guards the
+	// HTTPS token (held during its renewal)
+	tokenMutex      sync.Mutex
+	httpsTokenMutex sync.Mutex
 }
 
 // creates a new Globus endpoint using the given information
@@ -123,15 +152,20 @@ func NewEndpointFromConfig(endpointName string) (endpoints.Endpoint, error) {
 	if epConfig.Provider != "globus" {
 		return nil, fmt.Errorf("'%s' is not a Globus endpoint", endpointName)
 	}
-	credential, found := config.Credentials[epConfig.Credential]
-	if !found {
-		return nil, fmt.Errorf("invalid credential for endpoint '%s': %s", endpointName, epConfig.Credential)
+	credential, err := credentials.Get(epConfig.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential for endpoint '%s': %s", endpointName, err.Error())
 	}
 	clientId, err := uuid.Parse(credential.Id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid Globus client ID for credential '%s': %s (must be UUID)", epConfig.Credential, credential.Id)
 	}
-	return NewEndpoint(epConfig.Name, epConfig.Id, epConfig.Root, clientId, credential.Secret)
+	endpoint, err := NewEndpoint(epConfig.Name, epConfig.Id, epConfig.Root, clientId, credential.Secret)
+	if err == nil {
+		endpoint.(*Endpoint).EncryptData = epConfig.EncryptData
+		endpoint.(*Endpoint).Credential = epConfig.Credential
+	}
+	return endpoint, err
 }
 
 func (ep *Endpoint) Provider() string {
@@ -142,12 +176,17 @@ func (ep *Endpoint) Root() string {
 	return ep.RootDir
 }
 
+// Globus encrypts transfer data on request
+func (ep *Endpoint) EncryptsTransfers() bool {
+	return true
+}
+
 func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
 	// find all the directories in which these files reside
 	filesInDir := make(map[string][]string)
 	for _, resource := range files {
 		descriptor := resource.(map[string]any)
-		dir, file := filepath.Split(descriptor["path"].(string))
+		dir, file := filepath.Split(frictionless.String(descriptor, "path"))
 		dir = filepath.Join(ep.RootDir, dir)
 		if _, found := filesInDir[dir]; !found {
 			filesInDir[dir] = make([]string, 0)
@@ -236,18 +275,91 @@ func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 }
 
 func (ep *Endpoint) Transfer(destination endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	return ep.TransferWithContext(context.Background(), destination, files, false)
+}
+
+// begins a transfer task in which Globus skips files whose sources produce
+// errors instead of failing the task
+func (ep *Endpoint) TransferSkippingErrors(destination endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	return ep.TransferWithContext(context.Background(), destination, files, true)
+}
+
+// begins a transfer task, sending the requests that submit it with the given
+// context (and thus any trace context it carries)
+func (ep *Endpoint) TransferWithContext(ctx context.Context, destination endpoints.Endpoint,
+	files []endpoints.FileTransfer, skipErrors bool) (uuid.UUID, error) {
 	// NOTE: We don't check whether files are staged here, because the endpoint itself doesn't always
 	// have a reliable staging check (e.g. JDP's private data is invisible to Globus directory
 	// listings). Consequently, we assume that files are staged by the time this function is called.
 
 	// obtain a submission ID
-	submissionId, err := ep.getSubmissionId()
+	submissionId, err := ep.SubmissionId(ctx)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
 	// now, submit the transfer task itself
-	return ep.submitTransfer(destination, submissionId, files)
+	return ep.SubmitTransfer(ctx, submissionId, destination, files, skipErrors)
+}
+
+// begins a transfer task submitted under the given submission ID, returning
+// the UUID of the task already submitted under that ID if there is one
+func (ep *Endpoint) SubmitTransfer(ctx context.Context, submissionId uuid.UUID,
+	destination endpoints.Endpoint, files []endpoints.FileTransfer, skipErrors bool) (uuid.UUID, error) {
+	return ep.submitTransfer(ctx, destination, submissionId, files, skipErrors)
+}
+
+// returns the files skipped because of errors by the transfer task with the
+// given UUID
+func (ep *Endpoint) SkippedFiles(id uuid.UUID) ([]endpoints.SkippedFile, error) {
+	// https://docs.globus.org/api/transfer/task/#get_task_skipped_errors
+	type SkippedError struct {
+		SourcePath   string `json:"source_path"`
+		ErrorCode    string `json:"error_code"`
+		ErrorDetails string `json:"error_details"`
+	}
+	type SkippedErrorsResponse struct {
+		NextMarker string         `json:"next_marker"`
+		Data       []SkippedError `json:"DATA"`
+	}
+	var skippedFiles []endpoints.SkippedFile
+	resource := fmt.Sprintf("task/%s/skipped_errors", id.String())
+	values := url.Values{}
+	for {
+		body, err := ep.get(resource, values)
+		if err != nil {
+			return nil, err
+		}
+		if responseIsError(body) {
+			var globusErr GlobusError
+			err = json.Unmarshal(body, &globusErr)
+			if err == nil {
+				err = &globusErr
+			}
+			return nil, err
+		}
+		var response SkippedErrorsResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, err
+		}
+		for _, skipped := range response.Data {
+			// report paths relative to the endpoint's root, as they were given
+			sourcePath, err := filepath.Rel(ep.RootDir, skipped.SourcePath)
+			if err != nil {
+				sourcePath = skipped.SourcePath
+			}
+			skippedFiles = append(skippedFiles, endpoints.SkippedFile{
+				SourcePath: sourcePath,
+				Error:      fmt.Sprintf("%s: %s", skipped.ErrorCode, skipped.ErrorDetails),
+			})
+		}
+		if response.NextMarker == "" {
+			break
+		}
+		values.Set("marker", response.NextMarker)
+	}
+	return skippedFiles, nil
 }
 
 // mapping of Globus status code strings to DTS status codes
@@ -273,6 +385,8 @@ func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 		return endpoints.TransferStatus{}, err
 	}
 	type TaskResponse struct {
+		BytesTransferred           int64  `json:"bytes_transferred"`
+		EffectiveBytesPerSecond    int64  `json:"effective_bytes_per_second"`
 		Files                      int    `json:"files"`
 		FilesSkipped               int    `json:"files_skipped"`
 		FilesTransferred           int    `json:"files_transferred"`
@@ -332,11 +446,17 @@ func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 			return endpoints.TransferStatus{}, fmt.Errorf(response.NiceStatusShortDescription)
 		}
 	}
+	code := statusCodesForStrings[response.Status]
+	if response.IsPaused && code == endpoints.TransferStatusActive {
+		code = endpoints.TransferStatusInactive
+	}
 	return endpoints.TransferStatus{
-		Code:                statusCodesForStrings[response.Status],
+		Code:                code,
 		NumFiles:            response.Files,
 		NumFilesSkipped:     response.FilesSkipped,
 		NumFilesTransferred: response.FilesTransferred,
+		NumBytesTransferred: response.BytesTransferred,
+		BytesPerSecond:      float64(response.EffectiveBytesPerSecond),
 	}, nil
 }
 
@@ -370,10 +490,103 @@ func (ep *Endpoint) Cancel(id uuid.UUID) error {
 	return err
 }
 
+// Pausing and resuming tasks requires the activity manager role on the
+// endpoint (https://docs.globus.org/api/transfer/advanced_endpoint_management/#admin_pause)
+func (ep *Endpoint) Pause(id uuid.UUID) error {
+	type AdminPauseRequest struct {
+		Message    string   `json:"message"`
+		TaskIdList []string `json:"task_id_list"`
+	}
+	return ep.adminTaskRequest("endpoint_manager/admin_pause", AdminPauseRequest{
+		Message:    "Transfer paused by the Data Transfer Service",
+		TaskIdList: []string{id.String()},
+	})
+}
+
+// https://docs.globus.org/api/transfer/advanced_endpoint_management/#admin_resume
+func (ep *Endpoint) Resume(id uuid.UUID) error {
+	type AdminResumeRequest struct {
+		TaskIdList []string `json:"task_id_list"`
+	}
+	return ep.adminTaskRequest("endpoint_manager/admin_resume", AdminResumeRequest{
+		TaskIdList: []string{id.String()},
+	})
+}
+
+// https://docs.globus.org/api/transfer/task_submit/#submit_delete_task
+func (ep *Endpoint) Delete(path string) error {
+	submissionId, err := ep.SubmissionId(context.Background())
+	if err != nil {
+		return err
+	}
+	type DeleteItem struct {
+		DataType string `json:"DATA_TYPE"` // "delete_item"
+		Path     string `json:"path"`
+	}
+	type DeleteRequest struct {
+		DataType  string       `json:"DATA_TYPE"` // "delete"
+		Id        string       `json:"submission_id"`
+		Label     string       `json:"label"` // "DTS"
+		Endpoint  string       `json:"endpoint"`
+		Recursive bool         `json:"recursive"`
+		Data      []DeleteItem `json:"DATA"`
+	}
+	data, err := json.Marshal(DeleteRequest{
+		DataType:  "delete",
+		Id:        submissionId.String(),
+		Label:     "DTS",
+		Endpoint:  ep.Id.String(),
+		Recursive: true,
+		Data: []DeleteItem{
+			{
+				DataType: "delete_item",
+				Path:     filepath.Join(ep.RootDir, path),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	body, err := ep.post("delete", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if responseIsError(body) {
+		var globusErr GlobusError
+		err = json.Unmarshal(body, &globusErr)
+		if err == nil {
+			err = &globusErr
+		}
+		return err
+	}
+	return nil
+}
+
 //-----------
 // Internals
 //-----------
 
+// submits an endpoint management request for one or more tasks
+func (ep *Endpoint) adminTaskRequest(resource string, request any) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	body, err := ep.post(resource, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if responseIsError(body) {
+		var globusErr GlobusError
+		err := json.Unmarshal(body, &globusErr)
+		if err == nil {
+			err = &globusErr
+		}
+		return err
+	}
+	return nil
+}
+
 // default client credentials grant scopes
 var defaultScopes_ = []string{"urn:globus:auth:scope:transfer.api.globus.org:all"}
 
@@ -389,22 +602,57 @@ func responseIsError(body []byte) bool {
 // access token with consents for its relevant list of scopes
 // (https://docs.globus.org/api/auth/reference/#client_credentials_grant)
 func (ep *Endpoint) authenticate(scopes []string) error {
+	accessToken, _, err := ep.requestToken(scopes)
+	if err != nil {
+		return err
+	}
+
+	// stash the access token
+	ep.tokenMutex.Lock()
+	ep.AccessToken = accessToken
+	ep.tokenMutex.Unlock()
+
+	return nil
+}
+
+// returns the endpoint's current access token
+func (ep *Endpoint) accessToken() string {
+	ep.tokenMutex.Lock()
+	defer ep.tokenMutex.Unlock()
+	return ep.AccessToken
+}
+
+// requests an access token with consents for the given scopes using the
+// endpoint's client ID and secret, returning the token and its lifetime
+func (ep *Endpoint) requestToken(scopes []string) (string, time.Duration, error) {
+	ep.tokenMutex.Lock()
+	if ep.Credential != "" { // pick up the client secret if it has been rotated
+		credential, err := credentials.Get(ep.Credential)
+		if err != nil {
+			ep.tokenMutex.Unlock()
+			return "", 0, err
+		}
+		ep.ClientSecret = credential.Secret
+	}
+	clientSecret := ep.ClientSecret
+	ep.tokenMutex.Unlock()
+
 	authUrl := "https://auth.globus.org/v2/oauth2/token"
 	data := url.Values{}
 	data.Set("scope", strings.Join(scopes, " "))
 	data.Set("grant_type", "client_credentials")
 	req, err := http.NewRequest(http.MethodPost, authUrl, strings.NewReader(data.Encode()))
 	if err != nil {
-		return err
+		return "", 0, err
 	}
-	req.SetBasicAuth(ep.ClientId.String(), ep.ClientSecret)
+	req.SetBasicAuth(ep.ClientId.String(), clientSecret)
 	req.Header.Add("Content-Type", "application-x-www-form-urlencoded")
 
 	// send the request using a fresh HTTP client
-	var client http.Client
+	client := http.Client{Transport: config.HttpTransport()}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 	if resp.StatusCode != 200 {
 		// fish specifics out of the response
@@ -415,26 +663,26 @@ func (ep *Endpoint) authenticate(scopes []string) error {
 		}
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return "", 0, err
 		}
 		var authError AuthError
 		err = json.Unmarshal(body, &authError)
 		if err != nil {
 			// report the authentication error without details
-			return fmt.Errorf("couldn't authenticate via Globus Auth API (%d)", resp.StatusCode)
+			return "", 0, fmt.Errorf("couldn't authenticate via Globus Auth API (%d)", resp.StatusCode)
 		}
 		if len(authError.Description) > 0 {
-			return fmt.Errorf("couldn't authenticate via Globus Auth API: %s; %s (%d)",
+			return "", 0, fmt.Errorf("couldn't authenticate via Globus Auth API: %s; %s (%d)",
 				authError.Error, authError.Description, resp.StatusCode)
 		}
-		return fmt.Errorf("couldn't authenticate via Globus Auth API: %s (%d)",
+		return "", 0, fmt.Errorf("couldn't authenticate via Globus Auth API: %s (%d)",
 			authError.Error, resp.StatusCode)
 	}
 
 	// read and unmarshal the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 	type AuthResponse struct {
 		AccessToken    string `json:"access_token"`
@@ -446,15 +694,12 @@ func (ep *Endpoint) authenticate(scopes []string) error {
 	var authResponse AuthResponse
 	err = json.Unmarshal(body, &authResponse)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 
 	// FIXME: check the scopes to see if they match our requested ones?
 
-	// stash the access token
-	ep.AccessToken = authResponse.AccessToken
-
-	return nil
+	return authResponse.AccessToken, time.Duration(authResponse.ExpiresIn) * time.Second, nil
 }
 
 // This helper sends the given HTTP request, parsing the response for
@@ -464,7 +709,7 @@ func (ep *Endpoint) authenticate(scopes []string) error {
 // error indicating failure.
 func (ep *Endpoint) sendRequest(request *http.Request) ([]byte, error) {
 	// send the initial request with a fresh HTTP client
-	var client http.Client
+	client := http.Client{Transport: tracing.Transport(config.HttpTransport())}
 	resp, err := client.Do(request)
 	if err != nil {
 		return nil, err
@@ -493,7 +738,13 @@ func (ep *Endpoint) sendRequest(request *http.Request) ([]byte, error) {
 			if err != nil {
 				return nil, err
 			}
-			// try the request again
+			// try the request again with the new token
+			request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
+			if request.GetBody != nil {
+				if request.Body, err = request.GetBody(); err != nil {
+					return nil, err
+				}
+			}
 			resp, err = client.Do(request)
 			if err != nil {
 				return nil, err
@@ -515,6 +766,11 @@ func (ep *Endpoint) sendRequest(request *http.Request) ([]byte, error) {
 // retrying the operation. See https://docs.globus.org/api/flows/working-with-consents/
 // for details on Globus scopes and consents.
 func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
+	return ep.getWithContext(context.Background(), resource, values)
+}
+
+// Performs a GET request like get, sending it with the given context.
+func (ep *Endpoint) getWithContext(ctx context.Context, resource string, values url.Values) ([]byte, error) {
 	u, err := url.ParseRequestURI(globusTransferBaseURL)
 	if err != nil {
 		return nil, err
@@ -523,11 +779,11 @@ func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
 	u.RawQuery = values.Encode()
 	res := fmt.Sprintf("%v", u)
 	slog.Debug(fmt.Sprintf("GET: %s", res))
-	req, err := http.NewRequest(http.MethodGet, res, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.AccessToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
 
 	return ep.sendRequest(req)
 }
@@ -539,6 +795,11 @@ func (ep *Endpoint) get(resource string, values url.Values) ([]byte, error) {
 // retrying the operation. See https://docs.globus.org/api/flows/working-with-consents/
 // for details on Globus scopes and consents.
 func (ep *Endpoint) post(resource string, body io.Reader) ([]byte, error) {
+	return ep.postWithContext(context.Background(), resource, body)
+}
+
+// Performs a POST request like post, sending it with the given context.
+func (ep *Endpoint) postWithContext(ctx context.Context, resource string, body io.Reader) ([]byte, error) {
 	u, err := url.ParseRequestURI(globusTransferBaseURL)
 	if err != nil {
 		return nil, err
@@ -546,20 +807,21 @@ func (ep *Endpoint) post(resource string, body io.Reader) ([]byte, error) {
 	u.Path = fmt.Sprintf("%s/%s", globusTransferApiVersion, resource)
 	res := fmt.Sprintf("%v", u)
 	slog.Debug(fmt.Sprintf("POST: %s", res))
-	req, err := http.NewRequest(http.MethodPost, res, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, res, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.AccessToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", ep.accessToken()))
 	req.Header.Set("Content-Type", "application/json")
 
 	return ep.sendRequest(req)
 }
 
+// returns a new ID under which a task can be submitted
 // https://docs.globus.org/api/transfer/task_submit/#get_submission_id
-func (ep *Endpoint) getSubmissionId() (uuid.UUID, error) {
+func (ep *Endpoint) SubmissionId(ctx context.Context) (uuid.UUID, error) {
 	var id uuid.UUID
-	body, err := ep.get("submission_id", url.Values{})
+	body, err := ep.getWithContext(ctx, "submission_id", url.Values{})
 	if err != nil {
 		return id, err
 	}
@@ -574,8 +836,8 @@ func (ep *Endpoint) getSubmissionId() (uuid.UUID, error) {
 // https://docs.globus.org/api/transfer/endpoints_and_collections/#get_endpoint_or_collection_by_id
 // https://docs.globus.org/api/transfer/task_submit/#submit_transfer_task
 // https://docs.globus.org/api/transfer/task_submit/#transfer_item_fields
-func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
-	submissionId uuid.UUID, files []endpoints.FileTransfer) (uuid.UUID, error) {
+func (ep *Endpoint) submitTransfer(ctx context.Context, destination endpoints.Endpoint,
+	submissionId uuid.UUID, files []endpoints.FileTransfer, skipSourceErrors bool) (uuid.UUID, error) {
 	var xferId uuid.UUID
 
 	// are the source and destination endpoints configured in a conflicting way?
@@ -604,13 +866,43 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		DestinationPath   string `json:"destination_path"`
 		ExternalChecksum  string `json:"external_checksum,omitempty"`
 		ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+		Recursive         bool   `json:"recursive,omitempty"`
 	}
+
+	// Globus applies filter rules to all recursive items in a task, so we
+	// gather the distinct rules for recursive items
+	type FilterRule struct {
+		DataType string `json:"DATA_TYPE"` // "filter_rule"
+		Method   string `json:"method"`
+		Type     string `json:"type,omitempty"`
+		Name     string `json:"name"`
+	}
+	var filterRules []FilterRule
+	filterRulesSeen := make(map[endpoints.FilterRule]struct{})
+
 	xferItems := make([]TransferItem, len(files))
 	for i, file := range files {
+		if file.Recursive {
+			for _, rule := range file.FilterRules {
+				if _, seen := filterRulesSeen[rule]; !seen {
+					filterRulesSeen[rule] = struct{}{}
+					filterRules = append(filterRules, FilterRule{
+						DataType: "filter_rule",
+						Method:   rule.Method,
+						Type:     rule.Type,
+						Name:     rule.Name,
+					})
+				}
+			}
+		}
 		var checksum, checksumAlgorithm string
-		if verifyChecksum {
+		if verifyChecksum && !file.Recursive { // directories have no checksums
 			checksum = file.Hash
-			checksumAlgorithm = file.HashAlgorithm
+			checksumAlgorithm = globusChecksumAlgorithm(file.HashAlgorithm)
+			if !config.HashAlgorithmAllowed(file.HashAlgorithm) {
+				// never let Globus fall back to MD5 in FIPS mode
+				checksum, checksumAlgorithm = "", "SHA256"
+			}
 		}
 		xferItems[i] = TransferItem{
 			DataType:          "transfer_item",
@@ -618,6 +910,7 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 			DestinationPath:   file.DestinationPath,
 			ExternalChecksum:  checksum,
 			ChecksumAlgorithm: checksumAlgorithm,
+			Recursive:         file.Recursive,
 		}
 	}
 
@@ -638,6 +931,9 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		SyncLevel           int            `json:"sync_level"`
 		VerifyChecksum      bool           `json:"verify_checksum"`
 		FailOnQuotaErrors   bool           `json:"fail_on_quota_errors"`
+		SkipSourceErrors    bool           `json:"skip_source_errors"`
+		FilterRules         []FilterRule   `json:"filter_rules,omitempty"`
+		EncryptData         bool           `json:"encrypt_data"`
 	}
 	data, err := json.Marshal(SubmissionRequest{
 		DataType:            "transfer",
@@ -649,14 +945,30 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		SyncLevel:           syncLevel,
 		VerifyChecksum:      verifyChecksum,
 		FailOnQuotaErrors:   true,
+		SkipSourceErrors:    skipSourceErrors,
+		FilterRules:         filterRules,
+		EncryptData: ep.EncryptData || gDestination.EncryptData ||
+			ep.Info.ForceEncryption || gDestination.Info.ForceEncryption,
 	})
 	if err != nil {
 		return xferId, err
 	}
-	body, err := ep.post("transfer", bytes.NewReader(data))
+	body, err := ep.postWithContext(ctx, "transfer", bytes.NewReader(data))
 	if err != nil {
 		return xferId, err
 	}
+	type SubmissionResponse struct {
+		Code   string    `json:"code"`
+		TaskId uuid.UUID `json:"task_id"`
+	}
+	var gResp SubmissionResponse
+	if err = json.Unmarshal(body, &gResp); err == nil && gResp.Code == "Duplicate" {
+		// a task was already submitted under this ID (e.g. by an earlier
+		// attempt whose response was lost), so we report that one
+		slog.Debug(fmt.Sprintf("Globus transfer task %s was already submitted as %s",
+			gResp.TaskId.String(), submissionId.String()))
+		return gResp.TaskId, nil
+	}
 	if responseIsError(body) {
 		var globusErr GlobusError
 		err = json.Unmarshal(body, &globusErr)
@@ -665,12 +977,6 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 		}
 		return xferId, err
 	}
-	type SubmissionResponse struct {
-		TaskId uuid.UUID `json:"task_id"`
-	}
-
-	var gResp SubmissionResponse
-	err = json.Unmarshal(body, &gResp)
 	if err != nil {
 		return xferId, err
 	}
@@ -681,8 +987,14 @@ func (ep *Endpoint) submitTransfer(destination endpoints.Endpoint,
 }
 
 type EndpointInfo struct {
-	DisableVerify bool `json:"disable_verify"` // true if checksums are not available
-	ForceVerify   bool `json:"force_verify"`   // true if checksums must be available
+	// type of endpoint or collection (e.g. "GCSv5_mapped_collection")
+	EntityType    string `json:"entity_type"`
+	DisableVerify bool   `json:"disable_verify"` // true if checksums are not available
+	ForceVerify   bool   `json:"force_verify"`   // true if checksums must be available
+	// true if all transfers involving the endpoint must be encrypted
+	ForceEncryption bool `json:"force_encryption"`
+	// base URL of the collection's HTTPS server (empty if HTTPS access is disabled)
+	HttpsServer string `json:"https_server"`
 }
 
 func (ep *Endpoint) getEndpointInfo(id uuid.UUID) (EndpointInfo, error) {
@@ -703,3 +1015,9 @@ func (ep *Endpoint) getEndpointInfo(id uuid.UUID) (EndpointInfo, error) {
 	err = json.Unmarshal(body, &endpointInfo)
 	return endpointInfo, err
 }
+
+// converts a hash algorithm name as used in Frictionless descriptors ("md5",
+// "sha256") to its Globus counterpart ("MD5", "SHA256")
+func globusChecksumAlgorithm(algorithm string) string {
+	return strings.ToUpper(strings.ReplaceAll(algorithm, "-", ""))
+}
@@ -108,6 +108,16 @@ func TestBadGlobusConstructor(t *testing.T) {
 	assert.NotNil(err)
 }
 
+func TestGlobusConsent(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, _ := NewEndpointFromConfig("source")
+	consent := endpoint.(*Endpoint).CheckConsent()
+	assert.True(consent.Verified, consent.Message)
+	if consent.Scope != "" {
+		assert.Contains(consent.ConsentURL, "data_access")
+	}
+}
+
 func TestGlobusTransfers(t *testing.T) {
 	assert := assert.New(t)
 	endpoint, _ := NewEndpointFromConfig("source")
@@ -0,0 +1,120 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package globus
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+)
+
+// Globus Connect Server (v5) collections with HTTPS access enabled serve
+// individual files from an HTTPS server, given an access token with the
+// collection's https scope (see https://docs.globus.org/globus-connect-server/v5/https-access-collections/).
+// This is much quicker than a transfer task for small files.
+
+// returns the https scope for the collection with the given ID
+func httpsScope(collectionId string) string {
+	return fmt.Sprintf("https://auth.globus.org/scopes/%s/https", collectionId)
+}
+
+// returns true if the endpoint's collection has HTTPS access enabled
+func (ep *Endpoint) CanDownload() bool {
+	return ep.Info.HttpsServer != ""
+}
+
+// writes the contents of the file with the given path (relative to the
+// endpoint's root) to the given writer, fetching it from the collection's
+// HTTPS server and failing if the file is larger than the given number of bytes
+func (ep *Endpoint) Download(path string, w io.Writer, maxBytes int64) error {
+	if !ep.CanDownload() {
+		return fmt.Errorf("HTTPS access is not enabled for Globus collection '%s'", ep.Name)
+	}
+	httpsToken, err := ep.renewHttpsToken()
+	if err != nil {
+		return err
+	}
+
+	fileUrl, err := url.JoinPath(ep.Info.HttpsServer, strings.Split(filepath.Join(ep.RootDir, path), "/")...)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, fileUrl, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", httpsToken))
+	client := http.Client{Transport: config.HttpTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("couldn't download %s from Globus collection '%s' (%d)",
+			path, ep.Name, resp.StatusCode)
+	}
+	tooLarge := endpoints.FileTooLargeError{
+		Endpoint: ep.Name,
+		Path:     path,
+		Size:     resp.ContentLength,
+		MaxSize:  maxBytes,
+	}
+	if resp.ContentLength > maxBytes {
+		return tooLarge
+	}
+
+	// guard against servers that don't report content lengths
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxBytes {
+		tooLarge.Size = n
+		return tooLarge
+	}
+	return nil
+}
+
+// returns an access token for the collection's HTTPS server, obtaining one if
+// the endpoint doesn't have one or its token is about to expire
+func (ep *Endpoint) renewHttpsToken() (string, error) {
+	ep.httpsTokenMutex.Lock()
+	defer ep.httpsTokenMutex.Unlock()
+	if ep.httpsToken != "" && time.Until(ep.httpsTokenExpires) > time.Minute {
+		return ep.httpsToken, nil
+	}
+	token, lifetime, err := ep.requestToken([]string{httpsScope(ep.Id.String())})
+	if err != nil {
+		return "", err
+	}
+	ep.httpsToken = token
+	ep.httpsTokenExpires = time.Now().Add(lifetime)
+	return token, nil
+}
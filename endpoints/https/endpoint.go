@@ -0,0 +1,532 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package https
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+)
+
+// the number of times the download of a file is attempted (resuming from where
+// the previous attempt stopped) before its transfer fails
+var maxAttempts = 3
+
+// the time waited between attempts to download a file
+var retryInterval = 5 * time.Second
+
+// returns the transport used for requests (replaced in testing to trust the
+// test server's certificate)
+var httpTransport = config.HttpTransport
+
+// the suffix of partially-downloaded files
+const partialSuffix = ".part"
+
+type xferRecord struct {
+	Status   endpoints.TransferStatus
+	Files    []endpoints.FileTransfer
+	Canceled bool
+	cancel   context.CancelFunc
+}
+
+// This type implements an endpoint that fetches files from HTTPS download URLs,
+// for databases (e.g. ESS-DIVE, Zenodo) that offer no other access to their
+// files. A file's path is either an absolute URL or a path relative to the
+// endpoint's root URL. Files are streamed to local endpoints, or to endpoints
+// that accept uploads (via a temporary local file). Interrupted downloads are
+// resumed with ranged requests, and files are verified against their checksums.
+type Endpoint struct {
+	// descriptive endpoint name (obtained from config)
+	Name string
+	// endpoint UUID (obtained from config)
+	Id uuid.UUID
+	// base URL against which relative file paths are resolved (if any)
+	RootURL string
+	// name of the credential holding a bearer token for the server (if any)
+	Credential string
+
+	// transfers in progress and a mutex that guards them
+	xfers map[uuid.UUID]xferRecord
+	mutex sync.Mutex
+}
+
+// creates a new HTTPS endpoint using the information supplied in the DTS
+// configuration file under the given endpoint name
+func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
+	epConfig, found := config.Endpoints[endpointName]
+	if !found {
+		return nil, fmt.Errorf("'%s' is not an endpoint", endpointName)
+	}
+	if epConfig.Provider != "https" {
+		return nil, fmt.Errorf("'%s' is not an HTTPS endpoint", endpointName)
+	}
+	if epConfig.Credential != "" {
+		if _, err := credentials.Get(epConfig.Credential); err != nil {
+			return nil, fmt.Errorf("invalid credential for endpoint '%s': %s", endpointName, err.Error())
+		}
+	}
+	if epConfig.Root != "" {
+		if rootUrl, err := url.Parse(epConfig.Root); err != nil || rootUrl.Scheme != "https" {
+			return nil, fmt.Errorf("root of HTTPS endpoint '%s' is not an https URL: %s", endpointName, epConfig.Root)
+		}
+	}
+	return &Endpoint{
+		Name:       epConfig.Name,
+		Id:         epConfig.Id,
+		RootURL:    epConfig.Root,
+		Credential: epConfig.Credential,
+		xfers:      make(map[uuid.UUID]xferRecord),
+	}, nil
+}
+
+func (ep *Endpoint) Provider() string {
+	return "https"
+}
+
+func (ep *Endpoint) Root() string {
+	return ep.RootURL
+}
+
+// files served over HTTPS are always available, so we just make sure they
+// exist
+func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
+	for _, resource := range files {
+		descriptor, ok := resource.(map[string]any)
+		if !ok {
+			return false, fmt.Errorf("invalid file descriptor: %v", resource)
+		}
+		resp, err := ep.request(context.Background(), http.MethodHead,
+			frictionless.String(descriptor, "path"), 0)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		} else if resp.StatusCode != http.StatusOK {
+			return false, &httpError{Endpoint: ep.Name, Url: resp.Request.URL.String(), Status: resp.Status}
+		}
+	}
+	return true, nil
+}
+
+func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfers := make([]uuid.UUID, 0)
+	for xferId, xfer := range ep.xfers {
+		switch xfer.Status.Code {
+		case endpoints.TransferStatusSucceeded, endpoints.TransferStatusFailed:
+		default:
+			xfers = append(xfers, xferId)
+		}
+	}
+	return xfers, nil
+}
+
+// Begins downloading the given files to the given destination endpoint, which
+// must either have direct access to its files (i.e. a local endpoint) or
+// accept uploads. Directories can't be transferred.
+func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	_, uploading := dst.(endpoints.UploadingEndpoint)
+	if dst.Provider() != "local" && !uploading {
+		return uuid.UUID{}, &endpoints.IncompatibleDestinationError{
+			Source:              ep.Name,
+			SourceProvider:      ep.Provider(),
+			Destination:         dst.Root(),
+			DestinationProvider: dst.Provider(),
+		}
+	}
+	for _, file := range files {
+		if file.Recursive {
+			return uuid.UUID{}, fmt.Errorf("HTTPS endpoint '%s' can't transfer directories", ep.Name)
+		}
+	}
+
+	xferId := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ep.mutex.Lock()
+	ep.xfers[xferId] = xferRecord{
+		Status: endpoints.TransferStatus{
+			Code:     endpoints.TransferStatusActive,
+			NumFiles: len(files),
+		},
+		Files:  files,
+		cancel: cancel,
+	}
+	ep.mutex.Unlock()
+	go ep.transfer(ctx, xferId, dst)
+	return xferId, nil
+}
+
+func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.xfers[id]; found {
+		return xfer.Status, nil
+	}
+	return endpoints.TransferStatus{
+		Code: endpoints.TransferStatusUnknown,
+	}, fmt.Errorf("transfer %s not found", id.String())
+}
+
+func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.xfers[id]; found {
+		xfer.Canceled = true
+		ep.xfers[id] = xfer
+		xfer.cancel() // interrupts any download in progress
+		return nil
+	}
+	return fmt.Errorf("transfer %s not found", id.String())
+}
+
+// HTTPS endpoints can always serve files directly
+func (ep *Endpoint) CanDownload() bool {
+	return true
+}
+
+// writes the contents of the file with the given path (or URL) to the given
+// writer, failing if the file is larger than the given number of bytes
+func (ep *Endpoint) Download(path string, w io.Writer, maxBytes int64) error {
+	resp, err := ep.request(context.Background(), http.MethodGet, path, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &httpError{Endpoint: ep.Name, Url: resp.Request.URL.String(), Status: resp.Status}
+	}
+	tooLarge := endpoints.FileTooLargeError{
+		Endpoint: ep.Name,
+		Path:     path,
+		Size:     resp.ContentLength,
+		MaxSize:  maxBytes,
+	}
+	if resp.ContentLength > maxBytes {
+		return tooLarge
+	}
+
+	// guard against servers that don't report content lengths
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxBytes {
+		tooLarge.Size = n
+		return tooLarge
+	}
+	return nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// transfers the files for the transfer with the given ID to the given
+// destination endpoint, one at a time
+func (ep *Endpoint) transfer(ctx context.Context, xferId uuid.UUID, dst endpoints.Endpoint) {
+	ep.mutex.Lock()
+	xfer := ep.xfers[xferId]
+	ep.mutex.Unlock()
+
+	// files for endpoints that accept uploads are downloaded to a temporary
+	// folder (in the DTS's data directory, where the janitor removes any left
+	// behind) and uploaded from there
+	var uploader endpoints.UploadingEndpoint
+	var tempDir string
+	var err error
+	if dst.Provider() != "local" {
+		uploader = dst.(endpoints.UploadingEndpoint)
+		tempDir, err = os.MkdirTemp(config.Service.DataDirectory, "dts-https-")
+		if err == nil {
+			defer os.RemoveAll(tempDir)
+		}
+	}
+	for _, file := range xfer.Files {
+		if err != nil || ctx.Err() != nil { // failed or canceled
+			break
+		}
+		var localPath string
+		if uploader == nil {
+			localPath = filepath.Join(dst.Root(), file.DestinationPath)
+			if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+				break
+			}
+		} else {
+			localPath = filepath.Join(tempDir, filepath.Base(file.DestinationPath))
+		}
+		var size int64
+		if size, err = ep.download(ctx, file, localPath); err != nil {
+			break
+		}
+		if uploader != nil {
+			err = uploader.Upload(localPath, file.DestinationPath)
+			os.Remove(localPath)
+			if err != nil {
+				break
+			}
+		}
+		xfer.Status.NumFilesTransferred++
+		xfer.Status.NumBytesTransferred += size
+		ep.mutex.Lock()
+		xfer.Canceled = ep.xfers[xferId].Canceled
+		ep.xfers[xferId] = xfer
+		ep.mutex.Unlock()
+	}
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfer.Canceled = ep.xfers[xferId].Canceled
+	if err != nil || xfer.Canceled {
+		xfer.Status.Code = endpoints.TransferStatusFailed
+		if err != nil && !xfer.Canceled {
+			xfer.Status.Message = err.Error()
+		}
+	} else {
+		xfer.Status.Code = endpoints.TransferStatusSucceeded
+	}
+	ep.xfers[xferId] = xfer
+}
+
+// downloads the given file to the given local path, resuming interrupted
+// downloads and verifying the file's checksum (if given), and returning the
+// size of the file
+func (ep *Endpoint) download(ctx context.Context, file endpoints.FileTransfer,
+	localPath string) (int64, error) {
+	partialPath := localPath + partialSuffix
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(retryInterval):
+			}
+		}
+		var retry bool
+		retry, err = ep.resumeDownload(ctx, file.SourcePath, partialPath)
+		if err == nil || !retry {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyChecksum(partialPath, file.Hash, file.HashAlgorithm); err != nil {
+		os.Remove(partialPath) // start from scratch next time
+		return 0, fmt.Errorf("%s: %s", file.SourcePath, err.Error())
+	}
+	info, err := os.Stat(partialPath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(partialPath, localPath)
+}
+
+// appends the remainder of the file with the given path (or URL) to the
+// partially-downloaded file with the given local path, requesting only the
+// missing bytes, and returning an error (and whether the download should be
+// retried) if it's interrupted
+func (ep *Endpoint) resumeDownload(ctx context.Context, path, partialPath string) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(partialPath); err == nil {
+		offset = info.Size()
+	}
+	resp, err := ep.request(ctx, http.MethodGet, path, offset)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent: // the server honored our range
+		flags |= os.O_APPEND
+	case http.StatusOK: // the server sent the whole file
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable: // we already have it all
+		return false, nil
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, &httpError{Endpoint: ep.Name, Url: resp.Request.URL.String(), Status: resp.Status}
+	}
+	f, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return ctx.Err() == nil, err
+	}
+	return false, nil
+}
+
+// sends an HTTP request with the given method for the file with the given path
+// (or URL), asking for its contents starting at the given byte offset. The
+// endpoint's bearer token (if any) is sent only to the host of its root URL, so
+// files hosted elsewhere (e.g. at absolute URLs supplied by a database) never
+// see it.
+func (ep *Endpoint) request(ctx context.Context, method, path string, offset int64) (*http.Response, error) {
+	fileUrl, err := ep.fileURL(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fileUrl.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if ep.Credential != "" && ep.isRootHost(fileUrl) {
+		credential, err := credentials.Get(ep.Credential)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", credential.Secret))
+	}
+	client := http.Client{
+		Transport: httpTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("HTTPS endpoint '%s' won't follow a redirect to non-https URL %s", ep.Name, req.URL)
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("HTTPS endpoint '%s' stopped after 10 redirects", ep.Name)
+			}
+			return nil
+		},
+	}
+	return client.Do(req)
+}
+
+// returns the URL for the file with the given path, which is either an
+// absolute https URL or a path relative to the endpoint's root URL
+func (ep *Endpoint) fileURL(path string) (*url.URL, error) {
+	var fileUrl string
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		fileUrl = path
+	} else {
+		if ep.RootURL == "" {
+			return nil, fmt.Errorf("HTTPS endpoint '%s' has no root URL for relative path '%s'", ep.Name, path)
+		}
+		var err error
+		fileUrl, err = url.JoinPath(ep.RootURL, strings.Split(strings.TrimPrefix(path, "/"), "/")...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	parsedUrl, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsedUrl.Scheme != "https" {
+		return nil, fmt.Errorf("HTTPS endpoint '%s' can't fetch non-https URL %s", ep.Name, fileUrl)
+	}
+	return parsedUrl, nil
+}
+
+// returns true if the given URL refers to the host of the endpoint's root URL
+func (ep *Endpoint) isRootHost(fileUrl *url.URL) bool {
+	if ep.RootURL == "" {
+		return false
+	}
+	rootUrl, err := url.Parse(ep.RootURL)
+	return err == nil && strings.EqualFold(rootUrl.Host, fileUrl.Host)
+}
+
+// returns a hash for the given algorithm, or nil if it's not supported
+func newHash(algorithm string) hash.Hash {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// verifies that the file with the given local path has the given checksum
+// (computed with the given algorithm), skipping verification if no checksum is
+// given or its algorithm is unsupported or disallowed
+func verifyChecksum(localPath, checksum, algorithm string) error {
+	h := newHash(algorithm)
+	if checksum == "" || h == nil || !config.HashAlgorithmAllowed(algorithm) {
+		return nil
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if computed := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(computed, checksum) {
+		return &checksumError{Algorithm: algorithm, Expected: checksum, Computed: computed}
+	}
+	return nil
+}
+
+// indicates an unexpected response from an HTTPS server
+type httpError struct {
+	Endpoint, Url, Status string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("HTTPS endpoint '%s' couldn't fetch %s (%s)", e.Endpoint, e.Url, e.Status)
+}
+
+// indicates that a downloaded file's checksum doesn't match the expected one
+type checksumError struct {
+	Algorithm, Expected, Computed string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch (expected %s, got %s)", e.Algorithm, e.Expected, e.Computed)
+}
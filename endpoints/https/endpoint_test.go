@@ -0,0 +1,315 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package https
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+)
+
+// we test our HTTPS endpoint against a test server that serves files from
+// memory (honoring range requests) and interrupts the first download of a
+// "flaky" file halfway through
+
+var tempRoot string
+var localRoot string
+var server *httptest.Server
+
+// a second server (on another host) that serves files without authorization,
+// and the Authorization headers it has received
+var otherServer *httptest.Server
+var otherAuthorizations []string
+
+// files served by the test server, by path
+var serverFiles = map[string]string{
+	"/files/file1.txt": "file 1",
+	"/files/flaky.txt": "a file whose first download is interrupted",
+}
+
+// Range headers of GET requests received by the test server, by path
+var serverRanges = make(map[string][]string)
+var serverMutex sync.Mutex
+
+const httpsConfig string = `
+credentials:
+  https-token:
+    id: dts
+    secret: opensesame
+endpoints:
+  https:
+    name: HTTPS Endpoint
+    id: 7b0c4d2e-1f3a-4c5b-8d6e-9f0a1b2c3d4e
+    provider: https
+    credential: https-token
+    root: ROOT_URL/files
+  local:
+    name: Local Endpoint
+    id: 2ee69538-10d5-4d1e-a890-1127b5e42003
+    provider: local
+    root: LOCAL_ROOT
+`
+
+// handles requests to the test server
+func serveFile(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer opensesame" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	content, found := serverFiles[r.URL.Path]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		serverMutex.Lock()
+		serverRanges[r.URL.Path] = append(serverRanges[r.URL.Path], r.Header.Get("Range"))
+		numRequests := len(serverRanges[r.URL.Path])
+		serverMutex.Unlock()
+		if strings.HasSuffix(r.URL.Path, "flaky.txt") && numRequests == 1 {
+			// promise the whole file, send half of it, and hang up
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(content[:len(content)/2]))
+			return
+		}
+	}
+	http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader([]byte(content)))
+}
+
+// handles requests to the other test server
+func serveOtherFile(w http.ResponseWriter, r *http.Request) {
+	serverMutex.Lock()
+	otherAuthorizations = append(otherAuthorizations, r.Header.Get("Authorization"))
+	serverMutex.Unlock()
+	http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader([]byte("other file")))
+}
+
+// this function gets called at the begіnning of a test session
+func setup() {
+	var err error
+	tempRoot, err = os.MkdirTemp(os.TempDir(), "dts-https-endpoints")
+	if err != nil {
+		panic(err)
+	}
+	localRoot = filepath.Join(tempRoot, "local")
+	if err = os.Mkdir(localRoot, 0700); err != nil {
+		panic(err)
+	}
+	server = httptest.NewTLSServer(http.HandlerFunc(serveFile))
+	otherServer = httptest.NewTLSServer(http.HandlerFunc(serveOtherFile))
+	retryInterval = 0
+	// trust both test servers' certificates
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.RootCAs.AddCert(otherServer.Certificate())
+	httpTransport = func() http.RoundTripper { return transport }
+
+	myConfig := strings.ReplaceAll(httpsConfig, "ROOT_URL", server.URL)
+	myConfig = strings.ReplaceAll(myConfig, "LOCAL_ROOT", localRoot)
+	err = config.InitSelected([]byte(myConfig), false, true, false, true)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	server.Close()
+	otherServer.Close()
+	os.RemoveAll(tempRoot)
+}
+
+// returns the MD5 checksum of the given content
+func md5sum(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// waits for the transfer with the given ID at the given endpoint to complete
+func waitForTransfer(endpoint endpoints.Endpoint, xferId uuid.UUID) endpoints.TransferStatus {
+	for {
+		status, _ := endpoint.Status(xferId)
+		if status.Code == endpoints.TransferStatusSucceeded || status.Code == endpoints.TransferStatusFailed {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, err := NewEndpoint("https")
+	assert.Nil(err)
+	assert.Equal("https", endpoint.Provider())
+	assert.Equal(server.URL+"/files", endpoint.Root())
+
+	_, err = NewEndpoint("local")
+	assert.NotNil(err)
+}
+
+func TestFilesStaged(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, err := NewEndpoint("https")
+	assert.Nil(err)
+
+	staged, err := endpoint.FilesStaged([]any{
+		map[string]any{"path": "file1.txt"},
+		map[string]any{"path": server.URL + "/files/flaky.txt"}, // absolute URL
+	})
+	assert.Nil(err)
+	assert.True(staged)
+	staged, err = endpoint.FilesStaged([]any{map[string]any{"path": "missing.txt"}})
+	assert.Nil(err)
+	assert.False(staged)
+}
+
+func TestTransfer(t *testing.T) {
+	assert := assert.New(t)
+	httpsEndpoint, err := NewEndpoint("https")
+	assert.Nil(err)
+	localEndpoint, err := local.NewEndpoint("local")
+	assert.Nil(err)
+
+	serverMutex.Lock()
+	clear(serverRanges)
+	serverMutex.Unlock()
+
+	// interrupted downloads are resumed, and checksums are verified
+	xferId, err := httpsEndpoint.Transfer(localEndpoint, []endpoints.FileTransfer{
+		{
+			SourcePath:      "file1.txt",
+			DestinationPath: "dir/file1.txt",
+			Hash:            md5sum(serverFiles["/files/file1.txt"]),
+			HashAlgorithm:   "md5",
+		},
+		{
+			SourcePath:      "flaky.txt",
+			DestinationPath: "dir/flaky.txt",
+			Hash:            md5sum(serverFiles["/files/flaky.txt"]),
+			HashAlgorithm:   "md5",
+		},
+	})
+	assert.Nil(err)
+	status := waitForTransfer(httpsEndpoint, xferId)
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code, status.Message)
+	assert.Equal(2, status.NumFilesTransferred)
+	for _, name := range []string{"file1.txt", "flaky.txt"} {
+		data, err := os.ReadFile(filepath.Join(localRoot, "dir", name))
+		assert.Nil(err)
+		assert.Equal(serverFiles["/files/"+name], string(data))
+	}
+	serverMutex.Lock()
+	flakyRanges := serverRanges["/files/flaky.txt"]
+	serverMutex.Unlock()
+	assert.Equal([]string{"", "bytes=21-"}, flakyRanges)
+
+	// a checksum mismatch fails the transfer
+	xferId, err = httpsEndpoint.Transfer(localEndpoint, []endpoints.FileTransfer{
+		{
+			SourcePath:      "file1.txt",
+			DestinationPath: "dir/bad.txt",
+			Hash:            md5sum("something else"),
+			HashAlgorithm:   "md5",
+		},
+	})
+	assert.Nil(err)
+	status = waitForTransfer(httpsEndpoint, xferId)
+	assert.Equal(endpoints.TransferStatusFailed, status.Code)
+	assert.Contains(status.Message, "checksum mismatch")
+	_, err = os.Stat(filepath.Join(localRoot, "dir", "bad.txt"))
+	assert.True(os.IsNotExist(err))
+
+	// so does a missing file
+	xferId, err = httpsEndpoint.Transfer(localEndpoint, []endpoints.FileTransfer{
+		{SourcePath: "missing.txt", DestinationPath: "dir/missing.txt"},
+	})
+	assert.Nil(err)
+	status = waitForTransfer(httpsEndpoint, xferId)
+	assert.Equal(endpoints.TransferStatusFailed, status.Code)
+	assert.Contains(status.Message, "404")
+
+	// HTTPS endpoints can't send files to endpoints that don't accept uploads
+	_, err = httpsEndpoint.Transfer(httpsEndpoint, []endpoints.FileTransfer{
+		{SourcePath: "file1.txt", DestinationPath: "file1.txt"},
+	})
+	assert.IsType(&endpoints.IncompatibleDestinationError{}, err)
+}
+
+func TestDownload(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, err := NewEndpoint("https")
+	assert.Nil(err)
+	downloader := endpoint.(endpoints.DownloadingEndpoint)
+	assert.True(downloader.CanDownload())
+
+	var buffer bytes.Buffer
+	err = downloader.Download("file1.txt", &buffer, 100)
+	assert.Nil(err)
+	assert.Equal("file 1", buffer.String())
+	err = downloader.Download("file1.txt", &buffer, 2)
+	assert.IsType(endpoints.FileTooLargeError{}, err)
+}
+
+// This tests that the endpoint's bearer token is sent only to the host of its
+// root URL, and that only https URLs are fetched.
+func TestCredentialScope(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, err := NewEndpoint("https")
+	assert.Nil(err)
+	downloader := endpoint.(endpoints.DownloadingEndpoint)
+
+	var buffer bytes.Buffer
+	err = downloader.Download(otherServer.URL+"/file.txt", &buffer, 100)
+	assert.Nil(err)
+	assert.Equal("other file", buffer.String())
+	serverMutex.Lock()
+	assert.Equal([]string{""}, otherAuthorizations)
+	serverMutex.Unlock()
+
+	buffer.Reset()
+	err = downloader.Download(strings.Replace(otherServer.URL, "https://", "http://", 1)+"/file.txt", &buffer, 100)
+	assert.NotNil(err)
+}
+
+// This runs setup, runs all tests, and does breakdown.
+func TestMain(m *testing.M) {
+	setup()
+	status := m.Run()
+	breakdown()
+	os.Exit(status)
+}
@@ -22,16 +22,24 @@
 package local
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
 )
 
 type xferRecord struct {
@@ -49,8 +57,9 @@ type Endpoint struct {
 	Id uuid.UUID
 	// root directory for endpoint (default: current working directory)
 	root string
-	// transfers in progress
+	// transfers in progress and a mutex that guards them
 	Xfers map[uuid.UUID]xferRecord
+	mutex sync.Mutex
 }
 
 // creates a new local endpoint using the information supplied in the
@@ -96,7 +105,7 @@ func (ep *Endpoint) Root() string {
 func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
 	for _, resource := range files {
 		descriptor := resource.(map[string]any)
-		absPath := filepath.Join(ep.root, descriptor["path"].(string))
+		absPath := filepath.Join(ep.root, frictionless.String(descriptor, "path"))
 		_, err := os.Stat(absPath)
 		if err != nil {
 			return false, nil
@@ -106,6 +115,8 @@ func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
 }
 
 func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	xfers := make([]uuid.UUID, 0)
 	for xferId, xfer := range ep.Xfers {
 		switch xfer.Status.Code {
@@ -120,7 +131,9 @@ func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
 // implements asynchronous local file transfers and validation
 func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 	var err error
+	ep.mutex.Lock()
 	xfer := ep.Xfers[xferId]
+	ep.mutex.Unlock()
 	for _, file := range xfer.Files {
 		// has the transfer been canceled?
 		if xfer.Canceled {
@@ -129,43 +142,24 @@ func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 
 		sourcePath := filepath.Join(ep.Root(), file.SourcePath)
 		destPath := filepath.Join(dest.Root(), file.DestinationPath)
-
-		// check for the source directory
-		sourceDir := filepath.Dir(sourcePath)
-		var sourceDirInfo os.FileInfo
-		sourceDirInfo, err = os.Stat(sourceDir)
-		if err != nil {
-			break
+		copyTo := copyFile
+		if uploader, ok := dest.(endpoints.UploadingEndpoint); ok { // e.g. SFTP
+			destPath = file.DestinationPath
+			copyTo = uploader.Upload
 		}
-
-		// create the destination directory if needed
-		destDir := filepath.Dir(destPath)
-		_, err = os.Stat(destDir)
-		if err != nil {
-			if errors.Is(err, fs.ErrNotExist) { // destination dir doesn't exist
-				os.MkdirAll(destDir, sourceDirInfo.Mode())
-			} else { // something else happened
-				break
-			}
+		if file.Recursive {
+			err = copyDirectory(sourcePath, destPath, file.FilterRules, copyTo)
+		} else {
+			err = copyTo(sourcePath, destPath)
 		}
-
-		// copy the file into place
-		var data []byte
-		var sourceFileInfo os.FileInfo
-		sourceFileInfo, err = os.Stat(sourcePath)
-		if err != nil {
-			break
-		}
-		data, err = os.ReadFile(sourcePath)
-		if err != nil {
-			break
-		}
-		err = os.WriteFile(destPath, data, sourceFileInfo.Mode())
 		if err != nil {
+			xfer.Status.Message = err.Error()
 			break
 		}
 		xfer.Status.NumFilesTransferred++
-		continue
+		if info, statErr := os.Stat(sourcePath); statErr == nil && !info.IsDir() {
+			xfer.Status.NumBytesTransferred += info.Size()
+		}
 	}
 	if err != nil { // trouble!
 		xfer.Status.Code = endpoints.TransferStatusFailed
@@ -174,14 +168,79 @@ func (ep *Endpoint) transferFiles(xferId uuid.UUID, dest endpoints.Endpoint) {
 	} else { // all's well
 		xfer.Status.Code = endpoints.TransferStatusSucceeded
 	}
+	ep.mutex.Lock()
 	ep.Xfers[xferId] = xfer
+	ep.mutex.Unlock()
+}
+
+// copies the file at the given source path to the given destination path,
+// creating the destination directory if needed
+func copyFile(sourcePath, destPath string) error {
+	// check for the source directory
+	sourceDir := filepath.Dir(sourcePath)
+	sourceDirInfo, err := os.Stat(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	// create the destination directory if needed
+	destDir := filepath.Dir(destPath)
+	_, err = os.Stat(destDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) { // destination dir doesn't exist
+			os.MkdirAll(destDir, sourceDirInfo.Mode())
+		} else { // something else happened
+			return err
+		}
+	}
+
+	// copy the file into place
+	sourceFileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(destPath, data, sourceFileInfo.Mode())
+}
+
+// copies the contents of the directory at the given source path selected by
+// the given filter rules to the given destination path, copying each file
+// with the given function
+func copyDirectory(sourcePath, destPath string, rules []endpoints.FilterRule,
+	copyTo func(sourcePath, destPath string) error) error {
+	return filepath.WalkDir(sourcePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sourcePath {
+			return nil
+		}
+		if !endpoints.FilterRulesInclude(rules, entry.Name(), entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+		return copyTo(path, filepath.Join(destPath, relPath))
+	})
 }
 
 func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
 	var xferId uuid.UUID
 
-	_, ok := dst.(*Endpoint)
-	if !ok {
+	_, isLocal := dst.(*Endpoint)
+	_, isUploader := dst.(endpoints.UploadingEndpoint)
+	if !isLocal && !isUploader {
 		return xferId, &endpoints.IncompatibleDestinationError{
 			Source:              ep.Name,
 			SourceProvider:      "local",
@@ -204,6 +263,7 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 	if staged {
 		// assign a UUID to the transfer and set it going
 		xferId := uuid.New()
+		ep.mutex.Lock()
 		ep.Xfers[xferId] = xferRecord{
 			Status: endpoints.TransferStatus{
 				Code:                endpoints.TransferStatusActive,
@@ -212,6 +272,7 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 			},
 			Files: files,
 		}
+		ep.mutex.Unlock()
 		go ep.transferFiles(xferId, dst)
 		return xferId, nil
 	}
@@ -219,6 +280,8 @@ func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTrans
 }
 
 func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	if xfer, found := ep.Xfers[id]; found {
 		return xfer.Status, nil
 	}
@@ -228,6 +291,8 @@ func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
 }
 
 func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
 	if xfer, found := ep.Xfers[id]; found {
 		xfer.Canceled = true
 		return nil
@@ -235,8 +300,129 @@ func (ep *Endpoint) Cancel(id uuid.UUID) error {
 	return fmt.Errorf("transfer %s not found", id.String())
 }
 
+// deletes the file or directory with the given path (relative to the
+// endpoint's root)
+func (ep *Endpoint) Delete(path string) error {
+	return os.RemoveAll(filepath.Join(ep.root, path))
+}
+
+// moves the file with the given source path to the given destination path
+// (both relative to the endpoint's root)
+func (ep *Endpoint) Move(source, destination string) error {
+	destination = filepath.Join(ep.root, destination)
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(ep.root, source), destination)
+}
+
+// this method is specific to local endpoints and fetches the file with the
+// given source path directly from the given endpoint, writing it to the given
+// destination path (relative to the endpoint's root), provided the file is no
+// larger than the given number of bytes
+func (ep *Endpoint) Fetch(source endpoints.DownloadingEndpoint, sourcePath, destinationPath string, maxBytes int64) error {
+	destinationPath = filepath.Join(ep.root, destinationPath)
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(destinationPath)
+	if err != nil {
+		return err
+	}
+	err = source.Download(sourcePath, file, maxBytes)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destinationPath) // don't leave a partial file behind
+	}
+	return err
+}
+
+// this method is specific to local endpoints and begins fetching the files
+// identified by the given FileTransfer structs directly from the given source
+// endpoint, provided no file is larger than the given number of bytes. It
+// returns a UUID that can be used to check the status of the fetch like that of
+// any other transfer involving this endpoint.
+func (ep *Endpoint) FetchFiles(source endpoints.DownloadingEndpoint, files []endpoints.FileTransfer, maxBytes int64) (uuid.UUID, error) {
+	if !source.CanDownload() {
+		return uuid.UUID{}, fmt.Errorf("source endpoint (%s) can't serve files directly", source.Provider())
+	}
+	xferId := uuid.New()
+	ep.mutex.Lock()
+	ep.Xfers[xferId] = xferRecord{
+		Status: endpoints.TransferStatus{
+			Code:     endpoints.TransferStatusActive,
+			NumFiles: len(files),
+		},
+		Files: files,
+	}
+	ep.mutex.Unlock()
+	go ep.fetchFiles(xferId, source, maxBytes)
+	return xferId, nil
+}
+
+// implements asynchronous fetches of files from downloading endpoints
+func (ep *Endpoint) fetchFiles(xferId uuid.UUID, source endpoints.DownloadingEndpoint, maxBytes int64) {
+	var err error
+	ep.mutex.Lock()
+	xfer := ep.Xfers[xferId]
+	ep.mutex.Unlock()
+	for _, file := range xfer.Files {
+		if xfer.Canceled {
+			break
+		}
+		err = ep.Fetch(source, file.SourcePath, file.DestinationPath, maxBytes)
+		if err != nil {
+			xfer.Status.Message = err.Error()
+			break
+		}
+		xfer.Status.NumFilesTransferred++
+		if info, statErr := os.Stat(filepath.Join(ep.root, file.DestinationPath)); statErr == nil {
+			xfer.Status.NumBytesTransferred += info.Size()
+		}
+	}
+	if err != nil || xfer.Canceled {
+		xfer.Status.Code = endpoints.TransferStatusFailed
+	} else {
+		xfer.Status.Code = endpoints.TransferStatusSucceeded
+	}
+	ep.mutex.Lock()
+	ep.Xfers[xferId] = xfer
+	ep.mutex.Unlock()
+}
+
 // this method is specific to local endpoints and gives access to the
 // local filesystem
 func (ep *Endpoint) FS() (fs.FS, error) {
 	return os.DirFS(filepath.Join("/", ep.root)), nil
 }
+
+// this method is specific to local endpoints and identifies the format of the
+// file with the given path (relative to the endpoint's root) by its content
+func (ep *Endpoint) FileFormat(path string) (string, error) {
+	return formats.FormatFromFile(filepath.Join(ep.root, path))
+}
+
+// computes the checksum of the file with the given path (relative to the
+// endpoint's root) using the given hash algorithm ("md5" or "sha256")
+func (ep *Endpoint) Checksum(path, algorithm string) (string, error) {
+	var hasher hash.Hash
+	switch algorithm {
+	case "md5":
+		hasher = md5.New()
+	case "sha256":
+		hasher = sha256.New()
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+	}
+	file, err := os.Open(filepath.Join(ep.root, path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
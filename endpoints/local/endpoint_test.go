@@ -22,11 +22,16 @@
 package local
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -210,6 +215,46 @@ func TestBadLocalTransfer(t *testing.T) {
 	assert.NotNil(err)
 }
 
+func TestLocalRecursiveTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	source, _ := NewEndpoint("source")
+	destination, _ := NewEndpoint("destination")
+
+	// create a directory with files we want and files we don't
+	for _, name := range []string{"reads.fastq.gz", "subdir/more.fastq.gz", "assembly.fna"} {
+		path := filepath.Join(sourceRoot, "project", name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		err := os.WriteFile(path, []byte(name), 0600)
+		assert.Nil(err)
+	}
+
+	id, err := source.Transfer(destination, []endpoints.FileTransfer{
+		{
+			SourcePath:      "project",
+			DestinationPath: "filtered-project",
+			Recursive:       true,
+			FilterRules: []endpoints.FilterRule{
+				{Method: "exclude", Type: "file", Name: "*.fna"},
+			},
+		},
+	})
+	assert.Nil(err)
+	for {
+		status, err := source.Status(id)
+		assert.Nil(err)
+		if status.Code == endpoints.TransferStatusSucceeded ||
+			status.Code == endpoints.TransferStatusFailed {
+			assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.FileExists(filepath.Join(destinationRoot, "filtered-project", "reads.fastq.gz"))
+	assert.FileExists(filepath.Join(destinationRoot, "filtered-project", "subdir", "more.fastq.gz"))
+	assert.NoFileExists(filepath.Join(destinationRoot, "filtered-project", "assembly.fna"))
+}
+
 func TestUnknownLocalStatus(t *testing.T) {
 	assert := assert.New(t)
 	endpoint, _ := NewEndpoint("source")
@@ -242,6 +287,178 @@ func TestLocalTransferCancellation(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestLocalFileFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("source")
+	source := endpoint.(*Endpoint)
+
+	// write a FASTA file with an uninformative suffix and identify it
+	err := os.WriteFile(filepath.Join(sourceRoot, "sequence.dat"),
+		[]byte(">seq1\nACGT\n"), 0600)
+	assert.Nil(err)
+	format, err := source.FileFormat("sequence.dat")
+	assert.Nil(err)
+	assert.Equal("fasta", format)
+
+	// nonexistent files can't be identified
+	_, err = source.FileFormat("nonexistent.dat")
+	assert.NotNil(err)
+}
+
+func TestLocalChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("source")
+	source := endpoint.(endpoints.ChecksummingEndpoint)
+
+	content := []byte("This is the content of file 1.")
+	checksum, err := source.Checksum(sourceFilesById["1"], "md5")
+	assert.Nil(err)
+	expected := md5.Sum(content)
+	assert.Equal(hex.EncodeToString(expected[:]), checksum)
+
+	checksum, err = source.Checksum(sourceFilesById["1"], "sha256")
+	assert.Nil(err)
+	expectedSha256 := sha256.Sum256(content)
+	assert.Equal(hex.EncodeToString(expectedSha256[:]), checksum)
+
+	// unsupported algorithms can't be used
+	_, err = source.Checksum(sourceFilesById["1"], "crc32")
+	assert.NotNil(err)
+
+	// nonexistent files have no checksums
+	_, err = source.Checksum("nonexistent.dat", "md5")
+	assert.NotNil(err)
+}
+
+func TestLocalMove(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("destination")
+	destination := endpoint.(endpoints.MovingEndpoint)
+
+	err := os.WriteFile(filepath.Join(destinationRoot, "moved.txt"), []byte("moved"), 0600)
+	assert.Nil(err)
+	err = destination.Move("moved.txt", filepath.Join("quarantine", "moved.txt"))
+	assert.Nil(err)
+	_, err = os.Stat(filepath.Join(destinationRoot, "moved.txt"))
+	assert.True(os.IsNotExist(err))
+	content, err := os.ReadFile(filepath.Join(destinationRoot, "quarantine", "moved.txt"))
+	assert.Nil(err)
+	assert.Equal("moved", string(content))
+
+	// nonexistent files can't be moved
+	err = destination.Move("nonexistent.txt", "elsewhere.txt")
+	assert.NotNil(err)
+}
+
+// a source endpoint that serves files from memory
+type downloadingEndpoint struct {
+	endpoints.Endpoint
+	Files map[string]string
+}
+
+func (ep downloadingEndpoint) CanDownload() bool {
+	return true
+}
+
+func (ep downloadingEndpoint) Download(path string, w io.Writer, maxBytes int64) error {
+	content, found := ep.Files[path]
+	if !found {
+		return fmt.Errorf("no such file: %s", path)
+	}
+	if int64(len(content)) > maxBytes {
+		return endpoints.FileTooLargeError{Path: path, Size: int64(len(content)), MaxSize: maxBytes}
+	}
+	_, err := io.WriteString(w, content)
+	return err
+}
+
+func TestLocalFetch(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("destination")
+	destination := endpoint.(*Endpoint)
+	source := downloadingEndpoint{
+		Files: map[string]string{"remote/small.txt": "small"},
+	}
+
+	err := destination.Fetch(source, "remote/small.txt", "fetched/small.txt", 1024)
+	assert.Nil(err)
+	content, err := os.ReadFile(filepath.Join(destinationRoot, "fetched", "small.txt"))
+	assert.Nil(err)
+	assert.Equal("small", string(content))
+
+	// files that are too large aren't fetched, and nothing is left behind
+	err = destination.Fetch(source, "remote/small.txt", "fetched/tiny.txt", 2)
+	assert.IsType(endpoints.FileTooLargeError{}, err)
+	_, err = os.Stat(filepath.Join(destinationRoot, "fetched", "tiny.txt"))
+	assert.True(os.IsNotExist(err))
+
+	// nonexistent files can't be fetched
+	err = destination.Fetch(source, "remote/nonexistent.txt", "fetched/nonexistent.txt", 1024)
+	assert.NotNil(err)
+}
+
+func TestLocalFetchFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint, _ := NewEndpoint("destination")
+	destination := endpoint.(*Endpoint)
+	source := downloadingEndpoint{
+		Files: map[string]string{
+			"remote/a.txt": "aaa",
+			"remote/b.txt": "bb",
+		},
+	}
+
+	id, err := destination.FetchFiles(source, []endpoints.FileTransfer{
+		{SourcePath: "remote/a.txt", DestinationPath: "fetched-all/a.txt"},
+		{SourcePath: "remote/b.txt", DestinationPath: "fetched-all/b.txt"},
+	}, 1024)
+	assert.Nil(err)
+	assert.Eventually(func() bool {
+		status, err := destination.Status(id)
+		return err == nil && status.Code == endpoints.TransferStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+	status, _ := destination.Status(id)
+	assert.Equal(2, status.NumFilesTransferred)
+	assert.Equal(int64(5), status.NumBytesTransferred)
+
+	// a missing file fails the fetch
+	id, err = destination.FetchFiles(source, []endpoints.FileTransfer{
+		{SourcePath: "remote/missing.txt", DestinationPath: "fetched-all/missing.txt"},
+	}, 1024)
+	assert.Nil(err)
+	assert.Eventually(func() bool {
+		status, err := destination.Status(id)
+		return err == nil && status.Code == endpoints.TransferStatusFailed
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestLocalBandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	source, _ := NewEndpoint("source")
+	destination, _ := NewEndpoint("destination")
+
+	report, err := endpoints.MeasureBandwidth(source, destination, 1024*1024,
+		10*time.Millisecond, 10*time.Second)
+	assert.Nil(err)
+	assert.Equal(int64(1024*1024), report.PayloadSize)
+	assert.Greater(report.Throughput, 0.0)
+	assert.GreaterOrEqual(report.Duration, report.Latency)
+
+	// the test payload is cleaned up afterward
+	entries, err := filepath.Glob(filepath.Join(sourceRoot, "dts-bandwidth-test-*"))
+	assert.Nil(err)
+	assert.Empty(entries)
+	entries, err = filepath.Glob(filepath.Join(destinationRoot, "dts-bandwidth-test-*"))
+	assert.Nil(err)
+	assert.Empty(entries)
+}
+
 // this runs setup, runs all tests, and does breakdown
 func TestMain(m *testing.M) {
 	var status int
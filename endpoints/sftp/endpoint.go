@@ -0,0 +1,391 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+)
+
+// the SFTP client run (in batch mode) to access SFTP endpoints
+var sftpCommand = "sftp"
+
+type xferRecord struct {
+	Status   endpoints.TransferStatus
+	Files    []endpoints.FileTransfer
+	Canceled bool
+	cancel   context.CancelFunc
+}
+
+// This type implements an endpoint that accesses files on an SFTP server using
+// key-based authentication, allowing sites without Globus subscriptions to act
+// as transfer destinations. The DTS runs the OpenSSH sftp client to access the
+// server, which must appear in the known_hosts file of the user running the
+// DTS. Files are transferred between an SFTP endpoint and local endpoints:
+// local endpoints upload files to it, and it downloads files to them.
+type Endpoint struct {
+	// descriptive endpoint name (obtained from config)
+	Name string
+	// endpoint UUID (obtained from config)
+	Id uuid.UUID
+	// SFTP server host and port
+	Host string
+	Port string
+	// name of the credential holding the SSH user and private key
+	Credential string
+	// root directory for endpoint on the SFTP server
+	RootDir string
+
+	// transfers in progress and a mutex that guards them
+	xfers map[uuid.UUID]xferRecord
+	mutex sync.Mutex
+}
+
+// creates a new SFTP endpoint using the information supplied in the DTS
+// configuration file under the given endpoint name
+func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
+	epConfig, found := config.Endpoints[endpointName]
+	if !found {
+		return nil, fmt.Errorf("'%s' is not an endpoint", endpointName)
+	}
+	if epConfig.Provider != "sftp" {
+		return nil, fmt.Errorf("'%s' is not an SFTP endpoint", endpointName)
+	}
+	host, port, err := net.SplitHostPort(epConfig.Host)
+	if err != nil { // no port given
+		host, port = epConfig.Host, "22"
+	}
+	if _, err := credentials.Get(epConfig.Credential); err != nil {
+		return nil, fmt.Errorf("invalid credential for endpoint '%s': %s", endpointName, err.Error())
+	}
+	root := epConfig.Root
+	if root == "" {
+		root = "."
+	}
+	return &Endpoint{
+		Name:       epConfig.Name,
+		Id:         epConfig.Id,
+		Host:       host,
+		Port:       port,
+		Credential: epConfig.Credential,
+		RootDir:    root,
+		xfers:      make(map[uuid.UUID]xferRecord),
+	}, nil
+}
+
+func (ep *Endpoint) Provider() string {
+	return "sftp"
+}
+
+func (ep *Endpoint) Root() string {
+	return ep.RootDir
+}
+
+// SSH encrypts everything it transfers
+func (ep *Endpoint) EncryptsTransfers() bool {
+	return true
+}
+
+func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
+	commands := make([]string, 0, len(files))
+	for _, resource := range files {
+		descriptor, ok := resource.(map[string]any)
+		if !ok {
+			return false, fmt.Errorf("invalid file descriptor: %v", resource)
+		}
+		filePath := frictionless.String(descriptor, "path")
+		if err := ep.validatePath(filePath); err != nil {
+			return false, err
+		}
+		commands = append(commands, "ls -d "+quote(ep.remotePath(filePath)))
+	}
+	if err := ep.run(context.Background(), commands); err != nil {
+		// sftp exits with status 1 if a command fails (here, if a file is
+		// missing) and 255 if it can't connect
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfers := make([]uuid.UUID, 0)
+	for xferId, xfer := range ep.xfers {
+		switch xfer.Status.Code {
+		case endpoints.TransferStatusSucceeded, endpoints.TransferStatusFailed:
+		default:
+			xfers = append(xfers, xferId)
+		}
+	}
+	return xfers, nil
+}
+
+// Begins downloading the given files from the SFTP server to the given
+// destination endpoint, which must have direct access to its files (i.e. a
+// local endpoint). Files are transferred to SFTP endpoints by uploading them
+// from local endpoints.
+func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	if dst.Provider() != "local" {
+		return uuid.UUID{}, &endpoints.IncompatibleDestinationError{
+			Source:              ep.Name,
+			SourceProvider:      ep.Provider(),
+			Destination:         dst.Root(),
+			DestinationProvider: dst.Provider(),
+		}
+	}
+	for _, file := range files {
+		if len(file.FilterRules) > 0 {
+			return uuid.UUID{}, fmt.Errorf("SFTP endpoint '%s' can't filter the contents of directories", ep.Name)
+		}
+		for _, p := range []string{file.SourcePath, file.DestinationPath} {
+			if err := ep.validatePath(p); err != nil {
+				return uuid.UUID{}, err
+			}
+		}
+	}
+
+	xferId := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ep.mutex.Lock()
+	ep.xfers[xferId] = xferRecord{
+		Status: endpoints.TransferStatus{
+			Code:     endpoints.TransferStatusActive,
+			NumFiles: len(files),
+		},
+		Files:  files,
+		cancel: cancel,
+	}
+	ep.mutex.Unlock()
+	go ep.download(ctx, xferId, dst.Root())
+	return xferId, nil
+}
+
+func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.xfers[id]; found {
+		return xfer.Status, nil
+	}
+	return endpoints.TransferStatus{
+		Code: endpoints.TransferStatusUnknown,
+	}, fmt.Errorf("transfer %s not found", id.String())
+}
+
+func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.xfers[id]; found {
+		xfer.Canceled = true
+		ep.xfers[id] = xfer
+		xfer.cancel() // stops the sftp client
+		return nil
+	}
+	return fmt.Errorf("transfer %s not found", id.String())
+}
+
+// uploads the local file with the given path to the given path (relative to
+// the endpoint's root), creating any missing folders
+func (ep *Endpoint) Upload(localPath, destPath string) error {
+	for _, p := range []string{localPath, destPath} {
+		if err := ep.validatePath(p); err != nil {
+			return err
+		}
+	}
+	remotePath := ep.remotePath(destPath)
+	var commands []string
+	for _, folder := range parentFolders(remotePath) {
+		commands = append(commands, "-mkdir "+quote(folder)) // (may already exist)
+	}
+	commands = append(commands, fmt.Sprintf("put %s %s", quote(localPath), quote(remotePath)))
+	return ep.run(context.Background(), commands)
+}
+
+//-----------
+// Internals
+//-----------
+
+// downloads the files for the transfer with the given ID to the given local
+// directory, one at a time
+func (ep *Endpoint) download(ctx context.Context, xferId uuid.UUID, localRoot string) {
+	ep.mutex.Lock()
+	xfer := ep.xfers[xferId]
+	ep.mutex.Unlock()
+
+	var err error
+	for _, file := range xfer.Files {
+		if ctx.Err() != nil { // canceled
+			break
+		}
+		localPath := filepath.Join(localRoot, file.DestinationPath)
+		if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			break
+		}
+		get := "get "
+		if file.Recursive {
+			get = "get -r "
+		}
+		err = ep.run(ctx, []string{get + quote(ep.remotePath(file.SourcePath)) + " " + quote(localPath)})
+		if err != nil {
+			break
+		}
+		xfer.Status.NumFilesTransferred++
+		if info, statErr := os.Stat(localPath); statErr == nil && !info.IsDir() {
+			xfer.Status.NumBytesTransferred += info.Size()
+		}
+	}
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfer.Canceled = ep.xfers[xferId].Canceled
+	if err != nil || xfer.Canceled {
+		xfer.Status.Code = endpoints.TransferStatusFailed
+		if err != nil && !xfer.Canceled {
+			xfer.Status.Message = err.Error()
+		}
+	} else {
+		xfer.Status.Code = endpoints.TransferStatusSucceeded
+	}
+	ep.xfers[xferId] = xfer
+}
+
+// returns the path on the SFTP server of the file with the given path
+// (relative to the endpoint's root)
+func (ep *Endpoint) remotePath(p string) string {
+	return path.Join(ep.RootDir, p)
+}
+
+// returns the folders containing the given (remote) path, outermost first
+func parentFolders(p string) []string {
+	var folders []string
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		folders = append([]string{dir}, folders...)
+	}
+	return folders
+}
+
+// returns an error if the given path holds control characters: a newline
+// would end an sftp batch command, allowing the rest of the path to be run as
+// another (e.g. a local shell command prefixed with "!")
+func (ep *Endpoint) validatePath(p string) error {
+	if strings.ContainsFunc(p, unicode.IsControl) {
+		return &InvalidPathError{Endpoint: ep.Name, Path: p}
+	}
+	return nil
+}
+
+// quotes the given path (free of control characters) for use in an sftp batch
+// command
+func quote(p string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+// runs the given commands in a single sftp session, returning an error if the
+// session can't be established or any command (not prefixed by "-") fails
+func (ep *Endpoint) run(ctx context.Context, commands []string) error {
+	for _, command := range commands {
+		if strings.ContainsFunc(command, unicode.IsControl) { // (paths are validated)
+			return &InvalidPathError{Endpoint: ep.Name, Path: command}
+		}
+	}
+	credential, err := credentials.Get(ep.Credential)
+	if err != nil {
+		return err
+	}
+	keyFile, err := writePrivateKeyFile(credential.Secret)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Dir(keyFile))
+	cmd := exec.CommandContext(ctx, sftpCommand,
+		"-b", "-", // read commands from stdin
+		"-i", keyFile,
+		"-P", ep.Port,
+		"-o", "BatchMode=yes",
+		"-o", "IdentitiesOnly=yes",
+		fmt.Sprintf("%s@%s", credential.Id, ep.Host))
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && stderr.Len() > 0 {
+			exitErr.Stderr = stderr.Bytes()
+			return &sftpError{ExitError: exitErr}
+		}
+		return err
+	}
+	return nil
+}
+
+// an error reported by the sftp client (including its diagnostics)
+type sftpError struct {
+	*exec.ExitError
+}
+
+func (e *sftpError) Error() string {
+	return fmt.Sprintf("sftp: %s", strings.TrimSpace(string(e.Stderr)))
+}
+
+func (e *sftpError) Unwrap() error {
+	return e.ExitError
+}
+
+// writes the given private key (from the endpoint's credential) to a file
+// (readable only by the DTS) in a new temporary directory (in the DTS's data
+// directory) for the duration of an sftp session, returning its name
+func writePrivateKeyFile(key string) (string, error) {
+	dir, err := os.MkdirTemp(config.Service.DataDirectory, "dts-sftp-")
+	if err != nil {
+		return "", err
+	}
+	keyFile := filepath.Join(dir, "id")
+	content := key
+	if !strings.HasSuffix(content, "\n") { // OpenSSH insists on a trailing newline
+		content += "\n"
+	}
+	if err := os.WriteFile(keyFile, []byte(content), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return keyFile, nil
+}
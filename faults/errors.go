@@ -0,0 +1,63 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package faults
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// indicates that an injected fault has been triggered
+type InjectedFaultError struct {
+	TaskId uuid.UUID
+	Fault  Fault
+}
+
+func (e InjectedFaultError) Error() string {
+	var description string
+	switch e.Fault {
+	case StagingTimeout:
+		description = "staging timed out"
+	case SubmissionFailure:
+		description = "transfer submission failed"
+	case TokenExpiry:
+		description = "access token expired"
+	}
+	return fmt.Sprintf("Injected fault in transfer %s: %s", e.TaskId.String(), description)
+}
+
+// indicates that an invalid fault was requested
+type InvalidFaultError struct {
+	Fault Fault
+}
+
+func (e InvalidFaultError) Error() string {
+	return fmt.Sprintf("Invalid fault: '%s'", e.Fault)
+}
+
+// indicates that fault injection is not enabled
+type NotEnabledError struct{}
+
+func (e NotEnabledError) Error() string {
+	return "Fault injection is not enabled."
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package implements fault injection for chaos testing. When enabled by
+// the fault_injection service configuration parameter, faults can be injected
+// into specific transfers to exercise the DTS's retry and error-surfacing
+// paths end to end.
+package faults
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+)
+
+// a kind of fault that can be injected into a transfer
+type Fault string
+
+const (
+	// staging of the transfer's files times out
+	StagingTimeout Fault = "staging_timeout"
+	// the submission of the transfer's files to its source endpoint fails
+	SubmissionFailure Fault = "submission_failure"
+	// the access token used to check on the transfer's progress expires
+	TokenExpiry Fault = "token_expiry"
+)
+
+// all supported faults
+var Faults = []Fault{StagingTimeout, SubmissionFailure, TokenExpiry}
+
+// returns true if fault injection is enabled in the configuration
+func Enabled() bool {
+	return config.Service.FaultInjection
+}
+
+// Injects the given fault into the transfer with the given ID, to be triggered
+// the given number of times (or every time, if count is 0).
+func Inject(taskId uuid.UUID, fault Fault, count int) error {
+	if !Enabled() {
+		return &NotEnabledError{}
+	}
+	if !slices.Contains(Faults, fault) {
+		return &InvalidFaultError{Fault: fault}
+	}
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if _, found := injected_[taskId]; !found {
+		injected_[taskId] = make(map[Fault]int)
+	}
+	if count <= 0 {
+		count = -1 // unlimited
+	}
+	injected_[taskId][fault] = count
+	return nil
+}
+
+// Removes all faults injected into the transfer with the given ID.
+func Clear(taskId uuid.UUID) {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	delete(injected_, taskId)
+}
+
+// Returns the faults injected into the transfer with the given ID.
+func Injected(taskId uuid.UUID) []Fault {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return slices.Sorted(maps.Keys(injected_[taskId]))
+}
+
+// Returns true if the given fault has been injected into the transfer with the
+// given ID, consuming one of its occurrences. Call this at the point where the
+// fault would naturally occur.
+func Triggered(taskId uuid.UUID, fault Fault) bool {
+	if !Enabled() {
+		return false
+	}
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	count, found := injected_[taskId][fault]
+	if !found {
+		return false
+	}
+	if count > 0 {
+		count--
+		if count == 0 {
+			delete(injected_[taskId], fault)
+		} else {
+			injected_[taskId][fault] = count
+		}
+	}
+	return true
+}
+
+//-----------
+// Internals
+//-----------
+
+// numbers of remaining occurrences (-1 for unlimited) of faults, by task ID
+var injected_ = make(map[uuid.UUID]map[Fault]int)
+var mutex_ sync.Mutex
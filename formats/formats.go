@@ -0,0 +1,226 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package provides a central registry that identifies the formats of
+// files by their names and (optionally) by their content.
+package formats
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kbase/dts/config"
+)
+
+// the format label for a file whose format can't be determined
+const Unknown = "unknown"
+
+// returns the format label for a file with the given name, selecting the
+// format associated with its longest matching suffix (suffixes in the
+// "formats" section of the configuration take precedence over built-in ones)
+func FormatFromFileName(fileName string) string {
+	fileName = strings.ToLower(fileName)
+	format := Unknown
+	longestSuffix := 0
+	for suffix, suffixFormat := range suffixToFormat() {
+		if len(suffix) > longestSuffix && strings.HasSuffix(fileName, "."+suffix) {
+			format = suffixFormat
+			longestSuffix = len(suffix)
+		}
+	}
+	return format
+}
+
+// returns the format label for the given file content, identified by "magic
+// bytes" at its beginning, or Unknown if the format can't be identified
+func FormatFromContent(data []byte) string {
+	for _, magic := range magicBytes {
+		if bytes.HasPrefix(data, magic.Prefix) {
+			if magic.Contains == nil || bytes.Contains(data, magic.Contains) {
+				return magic.Format
+			}
+		}
+	}
+	return Unknown
+}
+
+// reads the beginning of the file with the given path and returns the format
+// label for its content, or Unknown if the format can't be identified
+func FormatFromFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Unknown, err
+	}
+	defer file.Close()
+	data := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Unknown, err
+	}
+	return FormatFromContent(data[:n]), nil
+}
+
+// returns the media (MIME) type associated with the given format label
+// (types in the "mimetypes" section of the configuration take precedence over
+// built-in ones), or an empty string if no type is associated with the format
+func MimeTypeForFormat(format string) string {
+	if mimetype, found := config.MimeTypes[format]; found {
+		return mimetype
+	}
+	return builtinFormatToMimeType[format]
+}
+
+// returns the media (MIME) type for a file with the given name, determined
+// from its format if possible and otherwise from its extension. Compressed
+// files (e.g. reads.fastq.gz) have the media type of their compression.
+func MimeTypeForFile(fileName string) string {
+	if mimetype, found := compressionMimeTypes[strings.ToLower(filepath.Ext(fileName))]; found {
+		return mimetype
+	}
+	mimetype := MimeTypeForFormat(FormatFromFileName(fileName))
+	if mimetype == "" {
+		mimetype = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+	return mimetype
+}
+
+//-----------
+// Internals
+//-----------
+
+// the number of bytes read from the beginning of a file to identify its format
+const sniffLength = 512
+
+// a mapping from (lower case) file suffixes to built-in format labels
+var builtinSuffixToFormat = map[string]string{
+	"bam":      "bam",
+	"bam.bai":  "bai",
+	"bed":      "bed",
+	"biom":     "biom",
+	"blasttab": "blast",
+	"bz":       "bzip",
+	"bz2":      "bzip2",
+	"cram":     "cram",
+	"csv":      "csv",
+	"faa":      "fasta",
+	"fasta":    "fasta",
+	"fasta.gz": "fasta",
+	"fastq":    "fastq",
+	"fastq.gz": "fastq",
+	"fna":      "fasta",
+	"gbk":      "genbank",
+	"gff":      "gff",
+	"gff3":     "gff3",
+	"gz":       "gz",
+	"h5":       "hdf5",
+	"hdf5":     "hdf5",
+	"html":     "html",
+	"info":     "texinfo",
+	"json":     "json",
+	"mzml":     "mzml",
+	"mzxml":    "mzxml",
+	"out":      "text",
+	"parquet":  "parquet",
+	"pdf":      "pdf",
+	"sam":      "sam",
+	"tar":      "tar",
+	"tar.gz":   "tar",
+	"tar.bz":   "tar",
+	"tar.bz2":  "tar",
+	"tsv":      "tsv",
+	"txt":      "text",
+	"vcf":      "vcf",
+	"vcf.gz":   "vcf",
+	"xml":      "xml",
+	"zip":      "zip",
+}
+
+// a mapping from the extensions of compressed files to their media types
+var compressionMimeTypes = map[string]string{
+	".bz":  "application/x-bzip",
+	".bz2": "application/x-bzip2",
+	".gz":  "application/gzip",
+	".xz":  "application/x-xz",
+	".zip": "application/zip",
+}
+
+// a mapping from format labels to built-in media types for formats not
+// (reliably) covered by the standard library's mime package
+var builtinFormatToMimeType = map[string]string{
+	"bam":     "application/x-bam",
+	"cram":    "application/x-cram",
+	"fasta":   "text/x-fasta",
+	"fastq":   "text/x-fastq",
+	"gff":     "text/x-gff",
+	"gff3":    "text/x-gff3",
+	"hdf5":    "application/x-hdf5",
+	"mzml":    "application/xml",
+	"mzxml":   "application/xml",
+	"parquet": "application/vnd.apache.parquet",
+	"sam":     "text/x-sam",
+	"vcf":     "text/x-vcf",
+}
+
+// returns a mapping from file suffixes to format labels that merges the
+// built-in table with any configured formats
+func suffixToFormat() map[string]string {
+	if len(config.Formats) == 0 {
+		return builtinSuffixToFormat
+	}
+	table := maps.Clone(builtinSuffixToFormat)
+	maps.Copy(table, config.Formats)
+	return table
+}
+
+// this type associates a format with a sequence of bytes at the beginning of
+// a file (and, optionally, a sequence of bytes appearing somewhere within the
+// sniffed content)
+type magicEntry struct {
+	Prefix, Contains []byte
+	Format           string
+}
+
+// magic byte sequences, checked in order (more specific entries first)
+var magicBytes = []magicEntry{
+	{Prefix: []byte("\x89HDF\r\n\x1a\n"), Format: "hdf5"},
+	{Prefix: []byte("PAR1"), Format: "parquet"},
+	{Prefix: []byte("%PDF-"), Format: "pdf"},
+	{Prefix: []byte("CRAM"), Format: "cram"},
+	{Prefix: []byte("\x1f\x8b"), Format: "gz"},
+	{Prefix: []byte("BZh"), Format: "bzip2"},
+	{Prefix: []byte("PK\x03\x04"), Format: "zip"},
+	{Prefix: []byte("##fileformat=VCF"), Format: "vcf"},
+	{Prefix: []byte("##gff-version 3"), Format: "gff3"},
+	{Prefix: []byte("<?xml"), Contains: []byte("<mzML"), Format: "mzml"},
+	{Prefix: []byte("<?xml"), Contains: []byte("<indexedmzML"), Format: "mzml"},
+	{Prefix: []byte("<?xml"), Contains: []byte("<mzXML"), Format: "mzxml"},
+	{Prefix: []byte("@HD\t"), Format: "sam"},
+	{Prefix: []byte(">"), Format: "fasta"},
+	{Prefix: []byte("@"), Format: "fastq"},
+}
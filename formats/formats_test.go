@@ -0,0 +1,120 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+)
+
+func TestFormatFromFileName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("fasta", FormatFromFileName("contigs.fna"))
+	assert.Equal("fastq", FormatFromFileName("reads.fastq.gz"))
+	assert.Equal("vcf", FormatFromFileName("variants.vcf.gz"))
+	assert.Equal("bed", FormatFromFileName("regions.bed"))
+	assert.Equal("hdf5", FormatFromFileName("matrix.h5"))
+	assert.Equal("parquet", FormatFromFileName("table.parquet"))
+	assert.Equal("mzml", FormatFromFileName("spectra.mzML"))
+	assert.Equal("tar", FormatFromFileName("archive.tar.gz"))
+	assert.Equal("gz", FormatFromFileName("something.gz"))
+	assert.Equal(Unknown, FormatFromFileName("mystery.xyz"))
+	assert.Equal(Unknown, FormatFromFileName("fasta"))
+}
+
+func TestConfiguredFormatFromFileName(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Formats = map[string]string{
+		"xyz": "xyzzy",
+		"gz":  "gzip",
+	}
+	defer func() { config.Formats = nil }()
+
+	assert.Equal("xyzzy", FormatFromFileName("mystery.xyz"))
+	assert.Equal("gzip", FormatFromFileName("something.gz"))
+	assert.Equal("fastq", FormatFromFileName("reads.fastq.gz"))
+}
+
+func TestFormatFromContent(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("hdf5", FormatFromContent([]byte("\x89HDF\r\n\x1a\n\x00\x00")))
+	assert.Equal("parquet", FormatFromContent([]byte("PAR1\x15\x04")))
+	assert.Equal("pdf", FormatFromContent([]byte("%PDF-1.7\n")))
+	assert.Equal("gz", FormatFromContent([]byte("\x1f\x8b\x08\x00")))
+	assert.Equal("vcf", FormatFromContent([]byte("##fileformat=VCFv4.2\n")))
+	assert.Equal("gff3", FormatFromContent([]byte("##gff-version 3\n")))
+	assert.Equal("mzml", FormatFromContent([]byte(`<?xml version="1.0"?><mzML>`)))
+	assert.Equal("fasta", FormatFromContent([]byte(">seq1\nACGT\n")))
+	assert.Equal("fastq", FormatFromContent([]byte("@read1\nACGT\n+\nIIII\n")))
+	assert.Equal(Unknown, FormatFromContent([]byte(`<?xml version="1.0"?><html>`)))
+	assert.Equal(Unknown, FormatFromContent([]byte{}))
+}
+
+func TestFormatFromFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "variants.dat")
+	err := os.WriteFile(path, []byte("##fileformat=VCFv4.2\n"), 0600)
+	assert.Nil(err)
+
+	format, err := FormatFromFile(path)
+	assert.Nil(err)
+	assert.Equal("vcf", format)
+
+	_, err = FormatFromFile(filepath.Join(dir, "nonexistent.dat"))
+	assert.NotNil(err)
+}
+
+func TestMimeTypeForFile(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("application/pdf", MimeTypeForFile("paper.pdf"))
+	assert.Equal("text/x-vcf", MimeTypeForFile("variants.vcf"))
+	assert.Equal("application/gzip", MimeTypeForFile("variants.vcf.gz"))
+	assert.Equal("application/gzip", MimeTypeForFile("reads.FASTQ.GZ"))
+	assert.Equal("application/octet-stream", MimeTypeForFile("mystery.xyz"))
+}
+
+func TestConfiguredMimeTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	config.MimeTypes = map[string]string{
+		"vcf":   "text/vcf",
+		"xyzzy": "application/x-xyzzy",
+	}
+	defer func() { config.MimeTypes = nil }()
+
+	assert.Equal("text/vcf", MimeTypeForFormat("vcf"))
+	assert.Equal("application/x-xyzzy", MimeTypeForFormat("xyzzy"))
+	assert.Equal("text/x-fasta", MimeTypeForFormat("fasta"))
+	assert.Equal("", MimeTypeForFormat("nothing"))
+	assert.Equal("text/vcf", MimeTypeForFile("variants.vcf"))
+}
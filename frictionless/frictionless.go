@@ -0,0 +1,282 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package frictionless provides a typed representation of the Frictionless
+// data resource descriptors the DTS passes between databases, endpoints, and
+// tasks, together with helpers for safely reading fields from descriptors held
+// as generic maps. Descriptors decoded from JSON store numbers as float64, so
+// unchecked type assertions like descriptor["bytes"].(int) can panic; use Int,
+// String, and Bool instead.
+package frictionless
+
+import (
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// A Frictionless data resource descriptor with typed fields for those the DTS
+// uses. Fields not represented here are preserved in Extra.
+type Descriptor struct {
+	Id        string
+	Name      string
+	Path      string // path of a file resource (relative to its endpoint root)
+	Format    string
+	MediaType string
+	Bytes     int
+	Hash      string
+	Endpoint  string // name of the endpoint hosting the resource
+	Recursive bool   // set if the resource is a directory
+	Data      any    // inline data for a data-only resource
+	Extra     map[string]any
+}
+
+// fields with typed representations in Descriptor
+var typedFields = []string{
+	"id", "name", "path", "format", "mediatype", "bytes", "hash", "endpoint",
+	"recursive", "data",
+}
+
+// Converts a descriptor held in a map to a typed Descriptor, returning an
+// InvalidFieldError if any typed field has an incompatible value.
+func DescriptorFromMap(m map[string]any) (Descriptor, error) {
+	var d Descriptor
+	var err error
+	if d.Id, err = stringField(m, "id"); err != nil {
+		return d, err
+	}
+	if d.Name, err = stringField(m, "name"); err != nil {
+		return d, err
+	}
+	if d.Path, err = stringField(m, "path"); err != nil {
+		return d, err
+	}
+	if d.Format, err = stringField(m, "format"); err != nil {
+		return d, err
+	}
+	if d.MediaType, err = stringField(m, "mediatype"); err != nil {
+		return d, err
+	}
+	if d.Hash, err = stringField(m, "hash"); err != nil {
+		return d, err
+	}
+	if d.Endpoint, err = stringField(m, "endpoint"); err != nil {
+		return d, err
+	}
+	if value, found := m["bytes"]; found {
+		var ok bool
+		if d.Bytes, ok = toInt(value); !ok {
+			return d, InvalidFieldError{Field: "bytes", Value: value, Expected: "integer"}
+		}
+	}
+	if value, found := m["recursive"]; found {
+		var ok bool
+		if d.Recursive, ok = value.(bool); !ok {
+			return d, InvalidFieldError{Field: "recursive", Value: value, Expected: "boolean"}
+		}
+	}
+	d.Data = m["data"]
+
+	for key, value := range m {
+		if !slices.Contains(typedFields, key) {
+			if d.Extra == nil {
+				d.Extra = make(map[string]any)
+			}
+			d.Extra[key] = value
+		}
+	}
+	return d, nil
+}
+
+// Converts the descriptor to a map, omitting empty typed fields (except bytes
+// for file resources).
+func (d Descriptor) Map() map[string]any {
+	m := make(map[string]any)
+	maps.Copy(m, d.Extra)
+	setString(m, "id", d.Id)
+	setString(m, "name", d.Name)
+	setString(m, "path", d.Path)
+	setString(m, "format", d.Format)
+	setString(m, "mediatype", d.MediaType)
+	setString(m, "hash", d.Hash)
+	setString(m, "endpoint", d.Endpoint)
+	if d.Path != "" || d.Bytes != 0 {
+		m["bytes"] = d.Bytes
+	}
+	if d.Recursive {
+		m["recursive"] = true
+	}
+	if d.Data != nil {
+		m["data"] = d.Data
+	}
+	return m
+}
+
+// Checks that the given descriptor describes a file resource the DTS can
+// transfer: it must have string "id" and "path" fields and (unless it's a
+// directory) a non-negative integer "bytes" field, and any "hash" must be a
+// string. Returns a MissingFieldError or InvalidFieldError if it doesn't.
+func ValidateFileDescriptor(descriptor map[string]any) error {
+	d, err := DescriptorFromMap(descriptor)
+	if err != nil {
+		return err
+	}
+	if d.Id == "" {
+		return MissingFieldError{Field: "id"}
+	}
+	if d.Path == "" {
+		return MissingFieldError{Field: "path"}
+	}
+	if !d.Recursive {
+		if _, found := descriptor["bytes"]; !found {
+			return MissingFieldError{Field: "bytes"}
+		}
+		if d.Bytes < 0 {
+			return InvalidFieldError{Field: "bytes", Value: d.Bytes, Expected: "non-negative integer"}
+		}
+	}
+	return nil
+}
+
+// returns true if the descriptor describes a file (or directory) resource
+func (d Descriptor) IsFile() bool {
+	return d.Path != ""
+}
+
+// Returns the string value of the given field in the descriptor, or "" if the
+// field is missing or isn't a string.
+func String(descriptor map[string]any, field string) string {
+	s, _ := descriptor[field].(string)
+	return s
+}
+
+// Returns the integer value of the given field in the descriptor and true, or
+// 0 and false if the field is missing or isn't an integer. Integral floating
+// point values (as decoded from JSON) are accepted.
+func Int(descriptor map[string]any, field string) (int, bool) {
+	return toInt(descriptor[field])
+}
+
+// Returns the boolean value of the given field in the descriptor, or false if
+// the field is missing or isn't a boolean.
+func Bool(descriptor map[string]any, field string) bool {
+	b, _ := descriptor[field].(bool)
+	return b
+}
+
+// Returns true if resources with the given media type can be viewed directly
+// in a web browser: HTML reports (e.g. Krona plots) and images.
+func Browsable(mediatype string) bool {
+	mediatype, _, _ = strings.Cut(mediatype, ";") // ignore parameters
+	mediatype = strings.TrimSpace(mediatype)
+	return mediatype == "text/html" || strings.HasPrefix(mediatype, "image/")
+}
+
+// Sets the "browse_url" field of the given descriptor to the given URL if the
+// descriptor's resource is browsable and the URL uses HTTP(S), so users can
+// view the resource without transferring it. Databases call this for
+// resources their sources expose at public URLs.
+func SetBrowseURL(descriptor map[string]any, url string) {
+	if Browsable(String(descriptor, "mediatype")) &&
+		(strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")) {
+		descriptor["browse_url"] = url
+	}
+}
+
+// Creates a Frictionless DataResource-savvy name for a file:
+//   - the name consists of lower case characters plus '.', '-', and '_'
+//   - any file suffix is removed
+//   - each sequence of forbidden characters in the filename is replaced by '_'
+func ResourceName(filename string) string {
+	name := strings.ToLower(filename)
+
+	// remove any file suffix
+	if lastDot := strings.LastIndex(name, "."); lastDot != -1 {
+		name = name[:lastDot]
+	}
+
+	// replace sequences of invalid characters with '_'
+	var b strings.Builder
+	replacing := false
+	for _, c := range name {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-' || c == '.' {
+			b.WriteRune(c)
+			replacing = false
+		} else if !replacing {
+			b.WriteRune('_')
+			replacing = true
+		}
+	}
+	return b.String()
+}
+
+//-----------
+// Internals
+//-----------
+
+func stringField(m map[string]any, field string) (string, error) {
+	value, found := m[field]
+	if !found || value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", InvalidFieldError{Field: field, Value: value, Expected: "string"}
+	}
+	return s, nil
+}
+
+func setString(m map[string]any, field, value string) {
+	if value != "" {
+		m[field] = value
+	}
+}
+
+func toInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		if v > math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
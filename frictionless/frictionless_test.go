@@ -0,0 +1,227 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	m := map[string]any{
+		"id":        "file1",
+		"name":      "file1",
+		"path":      "dir1/file1.txt",
+		"format":    "text",
+		"mediatype": "text/plain",
+		"bytes":     1024,
+		"hash":      "md5:d41d8cd98f00b204e9800998ecf8427e",
+		"credit":    map[string]any{"identifier": "doi:10.1234/5678"},
+	}
+	d, err := DescriptorFromMap(m)
+	assert.Nil(err)
+	assert.Equal("file1", d.Id)
+	assert.Equal(1024, d.Bytes)
+	assert.True(d.IsFile())
+	assert.Equal(m["credit"], d.Extra["credit"])
+	assert.Equal(m, d.Map())
+}
+
+func TestDescriptorFromJSON(t *testing.T) {
+	assert := assert.New(t)
+	var m map[string]any
+	err := json.Unmarshal([]byte(`{"id": "file1", "path": "file1.txt", "bytes": 1024}`), &m)
+	assert.Nil(err)
+	assert.IsType(float64(0), m["bytes"])
+
+	d, err := DescriptorFromMap(m)
+	assert.Nil(err)
+	assert.Equal(1024, d.Bytes)
+	size, ok := Int(m, "bytes")
+	assert.True(ok)
+	assert.Equal(1024, size)
+}
+
+func TestInvalidDescriptorFields(t *testing.T) {
+	assert := assert.New(t)
+	_, err := DescriptorFromMap(map[string]any{"id": 12})
+	assert.Equal(InvalidFieldError{Field: "id", Value: 12, Expected: "string"}, err)
+	_, err = DescriptorFromMap(map[string]any{"id": "file1", "bytes": 1.5})
+	assert.IsType(InvalidFieldError{}, err)
+	_, err = DescriptorFromMap(map[string]any{"id": "file1", "bytes": "big"})
+	assert.IsType(InvalidFieldError{}, err)
+}
+
+func TestAccessors(t *testing.T) {
+	assert := assert.New(t)
+	m := map[string]any{"id": "file1", "bytes": int64(12), "recursive": true}
+	assert.Equal("file1", String(m, "id"))
+	assert.Equal("", String(m, "bytes"))
+	assert.Equal("", String(m, "path"))
+	size, ok := Int(m, "bytes")
+	assert.True(ok)
+	assert.Equal(12, size)
+	_, ok = Int(m, "id")
+	assert.False(ok)
+	assert.True(Bool(m, "recursive"))
+	assert.False(Bool(m, "id"))
+}
+
+func TestSetBrowseURL(t *testing.T) {
+	assert := assert.New(t)
+	report := map[string]any{"id": "krona", "mediatype": "text/html; charset=utf-8"}
+	SetBrowseURL(report, "https://example.org/krona.html")
+	assert.Equal("https://example.org/krona.html", report["browse_url"])
+
+	plot := map[string]any{"id": "plot", "mediatype": "image/png"}
+	SetBrowseURL(plot, "ftp://example.org/plot.png")
+	assert.NotContains(plot, "browse_url")
+
+	reads := map[string]any{"id": "reads", "mediatype": "text/x-fastq"}
+	SetBrowseURL(reads, "https://example.org/reads.fastq")
+	assert.NotContains(reads, "browse_url")
+}
+
+func TestResourceName(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("skull_1.nii", ResourceName("Skull_1.nii.gz"))
+	assert.Equal("3300042_bins_low-quality_", ResourceName("3300042 bins (low-quality).tar"))
+	assert.Equal("ümlaut_name", ResourceName("Ümlaut+*name.txt"))
+}
+
+func TestValidateFileDescriptor(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(ValidateFileDescriptor(map[string]any{"id": "file1", "path": "file1.txt", "bytes": 0}))
+	assert.Nil(ValidateFileDescriptor(map[string]any{"id": "dir1", "path": "dir1", "recursive": true}))
+	assert.Equal(MissingFieldError{Field: "id"},
+		ValidateFileDescriptor(map[string]any{"path": "file1.txt", "bytes": 1}))
+	assert.Equal(MissingFieldError{Field: "path"},
+		ValidateFileDescriptor(map[string]any{"id": "file1", "bytes": 1}))
+	assert.Equal(MissingFieldError{Field: "bytes"},
+		ValidateFileDescriptor(map[string]any{"id": "file1", "path": "file1.txt"}))
+	assert.IsType(InvalidFieldError{},
+		ValidateFileDescriptor(map[string]any{"id": "file1", "path": "file1.txt", "bytes": -1}))
+	assert.IsType(InvalidFieldError{},
+		ValidateFileDescriptor(map[string]any{"id": "file1", "path": "file1.txt", "bytes": 1, "hash": 7}))
+}
+
+func TestLicenses(t *testing.T) {
+	assert := assert.New(t)
+
+	// well-known licenses are recognized by SPDX identifier or URL
+	ccBy := ParseLicense("cc-by-4.0")
+	assert.Equal("CC-BY-4.0", ccBy.Name)
+	assert.Equal("https://creativecommons.org/licenses/by/4.0/", ccBy.Path)
+	assert.Equal(ccBy, ParseLicense("http://creativecommons.org/licenses/by/4.0"))
+
+	// others are left as given
+	assert.Equal(License{Name: "MIT", Path: "https://spdx.org/licenses/MIT.html"}, ParseLicense("MIT"))
+	assert.Equal(License{Path: "https://example.org/terms"}, ParseLicense("https://example.org/terms"))
+	assert.Equal(License{}, ParseLicense(" "))
+
+	// licenses survive a trip through JSON
+	descriptor := map[string]any{"id": "file1"}
+	SetLicenses(descriptor, ccBy, ParseLicense("https://example.org/terms"))
+	data, err := json.Marshal(descriptor)
+	assert.Nil(err)
+	var decoded map[string]any
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal([]License{ccBy, {Path: "https://example.org/terms"}}, Licenses(decoded))
+
+	SetLicenses(descriptor)
+	assert.NotContains(descriptor, "licenses")
+	assert.Empty(Licenses(descriptor))
+}
+
+func TestTaxonomy(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(410658, ParseNCBITaxId("soil metagenome [NCBITaxon:410658]"))
+	assert.Equal(1219, ParseNCBITaxId("NCBITaxon:1219"))
+	assert.Equal(0, ParseNCBITaxId("ENVO:00000446"))
+
+	taxonomy := Taxonomy{
+		NCBITaxId:      1219,
+		ScientificName: "Prochlorococcus marinus",
+		Lineage: []Taxon{
+			{Rank: "kingdom", Name: "Bacteria"},
+			{Rank: "genus", Name: "Prochlorococcus"},
+		},
+		Environment: []EnvironmentTerm{
+			{Scale: "medium", Id: "ENVO:00002149", Name: "sea water"},
+		},
+	}
+	descriptor := map[string]any{"id": "file1"}
+	SetTaxonomy(descriptor, taxonomy)
+	assert.Equal(taxonomy, TaxonomyOf(descriptor))
+
+	// taxonomies survive a JSON round trip
+	data, err := json.Marshal(descriptor)
+	assert.Nil(err)
+	var decoded map[string]any
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal(taxonomy, TaxonomyOf(decoded))
+
+	SetTaxonomy(descriptor, Taxonomy{})
+	assert.NotContains(descriptor, "taxonomy")
+	assert.True(TaxonomyOf(descriptor).IsEmpty())
+}
+
+func TestCrossReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("gold:Gp0115663", CURIE("GOLD", "Gp0115663"))
+	assert.Equal("", CURIE("gold", ""))
+	namespace, id := ParseCURIE("EMSL:0a5b9e2c")
+	assert.Equal("emsl", namespace)
+	assert.Equal("0a5b9e2c", id)
+	namespace, id = ParseCURIE("Gp0115663")
+	assert.Equal("", namespace)
+	assert.Equal("", id)
+
+	// cross-references are normalized and deduplicated
+	descriptor := map[string]any{"name": "contigs"}
+	AddCrossReferences(descriptor, "GOLD:Gp0115663", "emsl:0a5b9e2c", "bogus", "gold:Gp0115663")
+	assert.Equal([]string{"gold:Gp0115663", "emsl:0a5b9e2c"}, CrossReferences(descriptor))
+	AddCrossReferences(descriptor, "gold:Gb0115663")
+	assert.Equal([]string{"gold:Gp0115663", "emsl:0a5b9e2c", "gold:Gb0115663"}, CrossReferences(descriptor))
+
+	// nothing is added if no cross-references are given
+	empty := map[string]any{}
+	AddCrossReferences(empty)
+	assert.NotContains(empty, "xrefs")
+
+	// related IDs survive a JSON round trip
+	SetRelatedIds(descriptor, map[string][]string{"jdp": {"JDP-PROJECT:1234"}, "kbase": nil})
+	data, err := json.Marshal(descriptor)
+	assert.Nil(err)
+	var decoded map[string]any
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal(map[string][]string{"jdp": {"JDP-PROJECT:1234"}}, RelatedIds(decoded))
+	assert.Equal(CrossReferences(descriptor), CrossReferences(decoded))
+
+	SetRelatedIds(descriptor, nil)
+	assert.NotContains(descriptor, "related_ids")
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"strings"
+)
+
+// A license under which a resource may be used, as listed in the "licenses"
+// field of a Frictionless descriptor. At least one of Name and Path is set.
+type License struct {
+	Name  string // SPDX identifier or other short name for the license
+	Path  string // URL for the text of the license
+	Title string // human-readable title of the license
+}
+
+// well-known licenses, by SPDX identifier
+var knownLicenses = map[string]License{
+	"CC0-1.0": {
+		Name:  "CC0-1.0",
+		Path:  "https://creativecommons.org/publicdomain/zero/1.0/",
+		Title: "Creative Commons Zero v1.0 Universal",
+	},
+	"CC-BY-4.0": {
+		Name:  "CC-BY-4.0",
+		Path:  "https://creativecommons.org/licenses/by/4.0/",
+		Title: "Creative Commons Attribution 4.0 International",
+	},
+	"CC-BY-SA-4.0": {
+		Name:  "CC-BY-SA-4.0",
+		Path:  "https://creativecommons.org/licenses/by-sa/4.0/",
+		Title: "Creative Commons Attribution Share Alike 4.0 International",
+	},
+	"CC-BY-NC-4.0": {
+		Name:  "CC-BY-NC-4.0",
+		Path:  "https://creativecommons.org/licenses/by-nc/4.0/",
+		Title: "Creative Commons Attribution Non Commercial 4.0 International",
+	},
+}
+
+// Returns a License for the given SPDX identifier or license URL, filling in
+// the other fields for well-known licenses. Returns an empty License if the
+// given string is empty.
+func ParseLicense(s string) License {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return License{}
+	}
+	if strings.Contains(s, "://") {
+		for _, license := range knownLicenses {
+			if licenseURLsMatch(license.Path, s) {
+				return license
+			}
+		}
+		return License{Path: s}
+	}
+	for id, license := range knownLicenses {
+		if strings.EqualFold(id, s) {
+			return license
+		}
+	}
+	return License{
+		Name: s,
+		Path: "https://spdx.org/licenses/" + s + ".html",
+	}
+}
+
+// Converts the license to a map suitable for a descriptor's "licenses" field.
+func (l License) Map() map[string]any {
+	m := make(map[string]any)
+	setString(m, "name", l.Name)
+	setString(m, "path", l.Path)
+	setString(m, "title", l.Title)
+	return m
+}
+
+// Returns the licenses listed in the "licenses" field of the given descriptor,
+// skipping malformed entries.
+func Licenses(descriptor map[string]any) []License {
+	entries := mapEntries(descriptor["licenses"])
+	licenses := make([]License, 0, len(entries))
+	for _, entry := range entries {
+		license := License{
+			Name:  String(entry, "name"),
+			Path:  String(entry, "path"),
+			Title: String(entry, "title"),
+		}
+		if license.Name != "" || license.Path != "" {
+			licenses = append(licenses, license)
+		}
+	}
+	return licenses
+}
+
+// Sets the "licenses" field of the given descriptor to the given licenses,
+// removing it if none are given.
+func SetLicenses(descriptor map[string]any, licenses ...License) {
+	if len(licenses) == 0 {
+		delete(descriptor, "licenses")
+		return
+	}
+	entries := make([]any, len(licenses))
+	for i, license := range licenses {
+		entries[i] = license.Map()
+	}
+	descriptor["licenses"] = entries
+}
+
+//-----------
+// Internals
+//-----------
+
+// returns true if the given license URLs refer to the same license, ignoring
+// their schemes and any trailing slashes
+func licenseURLsMatch(a, b string) bool {
+	normalize := func(u string) string {
+		if _, rest, found := strings.Cut(u, "://"); found {
+			u = rest
+		}
+		return strings.ToLower(strings.TrimSuffix(u, "/"))
+	}
+	return normalize(a) == normalize(b)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Normalized taxonomic information about the organism (or community) from
+// which a resource's data derive, as given in the "taxonomy" field of a
+// Frictionless descriptor. Databases populate it where they can, so that
+// destinations can group resources without querying their sources again.
+type Taxonomy struct {
+	NCBITaxId      int               // NCBI Taxonomy ID (0 if unknown)
+	ScientificName string            // scientific name of the taxon
+	Lineage        []Taxon           // lineage of the taxon, from broadest to narrowest rank
+	Environment    []EnvironmentTerm // terms describing the environment of a sample
+}
+
+// A taxon within a lineage
+type Taxon struct {
+	Rank string // e.g. "domain", "kingdom", "order", "family", "genus", "species"
+	Name string
+}
+
+// An ontology term (e.g. from ENVO) describing a sample's environment
+type EnvironmentTerm struct {
+	Scale string // e.g. "broad_scale", "local_scale", "medium"
+	Id    string // CURIE, e.g. "ENVO:00000446"
+	Name  string
+}
+
+// Returns the NCBI Taxonomy ID in the given CURIE or raw value (e.g.
+// "NCBITaxon:410658" or "soil metagenome [NCBITaxon:410658]"), or 0 if it has
+// none.
+func ParseNCBITaxId(s string) int {
+	_, id, found := strings.Cut(s, "NCBITaxon:")
+	if !found {
+		return 0
+	}
+	end := strings.IndexFunc(id, func(c rune) bool { return c < '0' || c > '9' })
+	if end != -1 {
+		id = id[:end]
+	}
+	taxId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0
+	}
+	return taxId
+}
+
+// Returns true if the taxonomy holds no information.
+func (t Taxonomy) IsEmpty() bool {
+	return t.NCBITaxId == 0 && t.ScientificName == "" && len(t.Lineage) == 0 &&
+		len(t.Environment) == 0
+}
+
+// Converts the taxonomy to a map suitable for a descriptor's "taxonomy" field.
+func (t Taxonomy) Map() map[string]any {
+	m := make(map[string]any)
+	if t.NCBITaxId != 0 {
+		m["ncbi_taxid"] = t.NCBITaxId
+	}
+	setString(m, "scientific_name", t.ScientificName)
+	if len(t.Lineage) > 0 {
+		lineage := make([]any, len(t.Lineage))
+		for i, taxon := range t.Lineage {
+			lineage[i] = map[string]any{"rank": taxon.Rank, "name": taxon.Name}
+		}
+		m["lineage"] = lineage
+	}
+	if len(t.Environment) > 0 {
+		environment := make([]any, len(t.Environment))
+		for i, term := range t.Environment {
+			entry := make(map[string]any)
+			setString(entry, "scale", term.Scale)
+			setString(entry, "id", term.Id)
+			setString(entry, "name", term.Name)
+			environment[i] = entry
+		}
+		m["environment"] = environment
+	}
+	return m
+}
+
+// Returns the taxonomy in the "taxonomy" field of the given descriptor, or an
+// empty Taxonomy if it has none, skipping malformed entries.
+func TaxonomyOf(descriptor map[string]any) Taxonomy {
+	var t Taxonomy
+	m, ok := descriptor["taxonomy"].(map[string]any)
+	if !ok {
+		return t
+	}
+	t.NCBITaxId, _ = Int(m, "ncbi_taxid")
+	t.ScientificName = String(m, "scientific_name")
+	for _, entry := range mapEntries(m["lineage"]) {
+		if taxon := (Taxon{Rank: String(entry, "rank"), Name: String(entry, "name")}); taxon.Name != "" {
+			t.Lineage = append(t.Lineage, taxon)
+		}
+	}
+	for _, entry := range mapEntries(m["environment"]) {
+		term := EnvironmentTerm{
+			Scale: String(entry, "scale"),
+			Id:    String(entry, "id"),
+			Name:  String(entry, "name"),
+		}
+		if term.Id != "" || term.Name != "" {
+			t.Environment = append(t.Environment, term)
+		}
+	}
+	return t
+}
+
+// Sets the "taxonomy" field of the given descriptor to the given taxonomy,
+// removing it if the taxonomy is empty.
+func SetTaxonomy(descriptor map[string]any, taxonomy Taxonomy) {
+	if taxonomy.IsEmpty() {
+		delete(descriptor, "taxonomy")
+		return
+	}
+	descriptor["taxonomy"] = taxonomy.Map()
+}
+
+//-----------
+// Internals
+//-----------
+
+// returns the maps in the given list of entries
+func mapEntries(value any) []map[string]any {
+	var entries []map[string]any
+	switch value := value.(type) {
+	case []any:
+		for _, entry := range value {
+			if m, ok := entry.(map[string]any); ok {
+				entries = append(entries, m)
+			}
+		}
+	case []map[string]any:
+		entries = value
+	}
+	return entries
+}
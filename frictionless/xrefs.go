@@ -0,0 +1,142 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frictionless
+
+import (
+	"slices"
+	"strings"
+)
+
+// Resources held by different databases are often related: an NMDC biosample
+// may have been sequenced by the JGI (and assigned a GOLD project ID) and
+// analyzed by EMSL, for example. A descriptor records the identifiers of
+// related resources in other repositories as compact URIs (CURIEs, e.g.
+// "gold:Gp0115663") in its "xrefs" field. The DTS can resolve these into the
+// IDs of related resources in other databases it serves, which it places in
+// the descriptor's "related_ids" field, keyed by database name, so users can
+// assemble payloads spanning several databases.
+
+// Returns the CURIE formed from the given namespace and local identifier, with
+// the namespace in lower case (e.g. "gold:Gp0115663"), or "" if either is
+// missing.
+func CURIE(namespace, id string) string {
+	namespace, id = strings.TrimSpace(namespace), strings.TrimSpace(id)
+	if namespace == "" || id == "" {
+		return ""
+	}
+	return strings.ToLower(namespace) + ":" + id
+}
+
+// Returns the namespace (in lower case) and local identifier of the given
+// CURIE, or empty strings if it isn't a CURIE.
+func ParseCURIE(curie string) (string, string) {
+	namespace, id, found := strings.Cut(strings.TrimSpace(curie), ":")
+	if !found || namespace == "" || id == "" {
+		return "", ""
+	}
+	return strings.ToLower(namespace), id
+}
+
+// Returns the cross-references (CURIEs) in the "xrefs" field of the given
+// descriptor, skipping malformed entries.
+func CrossReferences(descriptor map[string]any) []string {
+	return stringEntries(descriptor["xrefs"])
+}
+
+// Adds the given cross-references (CURIEs) to the "xrefs" field of the given
+// descriptor, normalizing their namespaces and skipping malformed entries and
+// those already present.
+func AddCrossReferences(descriptor map[string]any, xrefs ...string) {
+	existing := CrossReferences(descriptor)
+	for _, xref := range xrefs {
+		xref = CURIE(ParseCURIE(xref))
+		if xref != "" && !slices.Contains(existing, xref) {
+			existing = append(existing, xref)
+		}
+	}
+	if len(existing) == 0 {
+		return
+	}
+	entries := make([]any, len(existing))
+	for i, xref := range existing {
+		entries[i] = xref
+	}
+	descriptor["xrefs"] = entries
+}
+
+// Returns the IDs of related resources in other databases in the
+// "related_ids" field of the given descriptor, keyed by database name.
+func RelatedIds(descriptor map[string]any) map[string][]string {
+	relatedIds := make(map[string][]string)
+	if m, ok := descriptor["related_ids"].(map[string]any); ok {
+		for dbName, value := range m {
+			if ids := stringEntries(value); len(ids) > 0 {
+				relatedIds[dbName] = ids
+			}
+		}
+	}
+	return relatedIds
+}
+
+// Sets the "related_ids" field of the given descriptor to the given IDs of
+// related resources, keyed by database name, removing it if none are given.
+func SetRelatedIds(descriptor map[string]any, relatedIds map[string][]string) {
+	m := make(map[string]any)
+	for dbName, ids := range relatedIds {
+		if len(ids) > 0 {
+			entries := make([]any, len(ids))
+			for i, id := range ids {
+				entries[i] = id
+			}
+			m[dbName] = entries
+		}
+	}
+	if len(m) == 0 {
+		delete(descriptor, "related_ids")
+		return
+	}
+	descriptor["related_ids"] = m
+}
+
+//-----------
+// Internals
+//-----------
+
+// returns the non-empty strings in the given list of entries
+func stringEntries(value any) []string {
+	var entries []string
+	switch value := value.(type) {
+	case []any:
+		for _, entry := range value {
+			if s, ok := entry.(string); ok && s != "" {
+				entries = append(entries, s)
+			}
+		}
+	case []string:
+		for _, s := range value {
+			if s != "" {
+				entries = append(entries, s)
+			}
+		}
+	}
+	return entries
+}
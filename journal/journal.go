@@ -26,6 +26,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/frictionlessdata/datapackage-go/datapackage"
@@ -33,7 +35,9 @@ import (
 	"github.com/google/uuid"
 	bolt "go.etcd.io/bbolt"
 
+	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/manifests"
 )
 
 // This is the DTS transfer journal, which logs all transfer activity. The journal is a table of
@@ -48,15 +52,26 @@ type Record struct {
 	Destination string `json:"destination"`
 	// the ORCID associated with the transfer
 	Orcid string `json:"orcid"`
+	// the allocation or project to which the transfer is attributed (if any)
+	Allocation string `json:"allocation,omitempty"`
 	// times at which the transfer was requested and at which it completed
 	StartTime time.Time `json:"start_time"`
 	StopTime  time.Time `json:"stop_time"`
-	// status of the transfer ("succeeded", "failed", or "canceled")
+	// status of the transfer ("succeeded", "failed", "canceled", or "expired")
 	Status string `json:"status"`
 	// size of the transfer's payload in bytes
 	PayloadSize int64 `json:"payload_size"`
 	// number of files in the transfer's payload
 	NumFiles int `json:"num_files"`
+	// free-text note attached to the transfer by its owner
+	Note string `json:"note,omitempty"`
+	// user-defined labels associated with the transfer
+	Tags []string `json:"tags,omitempty"`
+	// providers that moved the transfer's files (e.g. "globus", or "https" for
+	// direct downloads)
+	Movers []string `json:"movers,omitempty"`
+	// DOI minted for the transfer's delivered payload (if any)
+	DOI string `json:"doi,omitempty"`
 	// manifest containing metadata for the transfer's payload (stored separate from record)
 	Manifest *datapackage.Package `json:"-"`
 }
@@ -98,7 +113,7 @@ func IsOpen() bool {
 // record: the record containing all transfer information
 func RecordTransfer(record Record) error {
 	switch record.Status {
-	case "succeeded", "failed", "canceled":
+	case "succeeded", "failed", "canceled", "expired":
 		// pass-through (see below)
 	default:
 		return &NewRecordError{
@@ -134,6 +149,82 @@ func Records(start, stop time.Time) ([]Record, error) {
 	}
 }
 
+// retrieves records for transfers that started and finished within the time range with the given
+// (inclusive) bounds and that bear all of the given tags
+// start: the beginning of the time period of interest
+// stop: the end of the time period of interest
+// tags: the tags borne by each retrieved record
+func RecordsWithTags(start, stop time.Time, tags []string) ([]Record, error) {
+	records, err := Records(start, stop)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(records, func(record Record) bool {
+		for _, tag := range tags {
+			if !slices.Contains(record.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// anonymizes the records of all transfers requested by the user with the given
+// ORCID, replacing the ORCID and scrubbing personal information from any
+// stored manifests, and returns the number of records anonymized
+func AnonymizeUser(orcid string) (int, error) {
+	return anonymizeRecords(anonymization{Orcid: orcid})
+}
+
+// anonymizes the records of all transfers that finished before the given time,
+// returning the number of records anonymized
+func AnonymizeBefore(t time.Time) (int, error) {
+	return anonymizeRecords(anonymization{Before: t})
+}
+
+// a summary of the data movement attributed to an allocation or project
+type AllocationUsage struct {
+	// the allocation or project ("" for unattributed transfers)
+	Allocation string `json:"allocation"`
+	// the number of transfers attributed to the allocation
+	NumTransfers int `json:"num_transfers"`
+	// the number of files moved by those transfers
+	NumFiles int `json:"num_files"`
+	// the total size of those transfers' payloads in bytes
+	PayloadSize int64 `json:"payload_size"`
+}
+
+// aggregates records for transfers that started and finished within the time
+// range with the given (inclusive) bounds by allocation, returning usage
+// sorted by allocation
+// start: the beginning of the time period of interest
+// stop: the end of the time period of interest
+func UsageByAllocation(start, stop time.Time) ([]AllocationUsage, error) {
+	records, err := Records(start, stop)
+	if err != nil {
+		return nil, err
+	}
+	usageByAllocation := make(map[string]*AllocationUsage)
+	for _, record := range records {
+		usage, found := usageByAllocation[record.Allocation]
+		if !found {
+			usage = &AllocationUsage{Allocation: record.Allocation}
+			usageByAllocation[record.Allocation] = usage
+		}
+		usage.NumTransfers++
+		usage.NumFiles += record.NumFiles
+		usage.PayloadSize += record.PayloadSize
+	}
+	usages := make([]AllocationUsage, 0, len(usageByAllocation))
+	for _, usage := range usageByAllocation {
+		usages = append(usages, *usage)
+	}
+	slices.SortFunc(usages, func(a, b AllocationUsage) int {
+		return strings.Compare(a.Allocation, b.Allocation)
+	})
+	return usages, nil
+}
+
 //-----------
 // Internals
 //-----------
@@ -146,19 +237,40 @@ type TimeRange struct {
 	Start, Stop time.Time
 }
 
+// criteria selecting records to be anonymized (empty fields select all records)
+type anonymization struct {
+	Orcid  string    // ORCID of the user whose records are anonymized
+	Before time.Time // time before which anonymized transfers finished
+}
+
+func anonymizeRecords(request anonymization) (int, error) {
+	if !IsOpen() {
+		return 0, &NotOpenError{}
+	}
+	channels_.Input.Anonymize <- request
+	select {
+	case numAnonymized := <-channels_.Output.NumAnonymized:
+		return numAnonymized, nil
+	case err := <-channels_.Output.Error:
+		return 0, err
+	}
+}
+
 var channels_ struct {
 	Open  bool // true if channels are open, false if not
 	Input struct {
-		CreateRecord chan Record    // for creating new records
-		CheckIfOpen  chan struct{}  // for checking to see whether the database is open
-		FetchRecords chan TimeRange // for fetching records within a time range
-		Shutdown     chan struct{}  // for shutting down the database
+		CreateRecord chan Record        // for creating new records
+		CheckIfOpen  chan struct{}      // for checking to see whether the database is open
+		FetchRecords chan TimeRange     // for fetching records within a time range
+		Anonymize    chan anonymization // for anonymizing selected records
+		Shutdown     chan struct{}      // for shutting down the database
 	}
 
 	Output struct {
-		Records chan []Record // for returning records
-		Error   chan error    // for returning errors
-		IsOpen  chan bool     // for answering queries about whether the database is open
+		Records       chan []Record // for returning records
+		NumAnonymized chan int      // for returning the number of anonymized records
+		Error         chan error    // for returning errors
+		IsOpen        chan bool     // for answering queries about whether the database is open
 	}
 }
 
@@ -205,6 +317,14 @@ func transferJournalProcess() {
 				channels_.Output.Records <- records
 			}
 
+		case request := <-channels_.Input.Anonymize:
+			numAnonymized, err := anonymize(db, request)
+			if err != nil {
+				channels_.Output.Error <- err
+			} else {
+				channels_.Output.NumAnonymized <- numAnonymized
+			}
+
 		case <-channels_.Input.Shutdown:
 			err := db.Close()
 			if err != nil {
@@ -222,8 +342,10 @@ func openChannels() {
 	channels_.Input.CreateRecord = make(chan Record)
 	channels_.Input.CheckIfOpen = make(chan struct{})
 	channels_.Input.FetchRecords = make(chan TimeRange)
+	channels_.Input.Anonymize = make(chan anonymization)
 	channels_.Input.Shutdown = make(chan struct{})
 	channels_.Output.Records = make(chan []Record)
+	channels_.Output.NumAnonymized = make(chan int)
 	channels_.Output.Error = make(chan error)
 	channels_.Output.IsOpen = make(chan bool)
 }
@@ -233,8 +355,10 @@ func closeChannels() {
 	close(channels_.Input.CreateRecord)
 	close(channels_.Input.CheckIfOpen)
 	close(channels_.Input.FetchRecords)
+	close(channels_.Input.Anonymize)
 	close(channels_.Input.Shutdown)
 	close(channels_.Output.Records)
+	close(channels_.Output.NumAnonymized)
 	close(channels_.Output.Error)
 	close(channels_.Output.IsOpen)
 }
@@ -315,3 +439,52 @@ func fetchRecords(db *bolt.DB, start, stop time.Time) ([]Record, error) {
 
 	return records, err
 }
+
+func anonymize(db *bolt.DB, request anonymization) (int, error) {
+	numAnonymized := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		transfers := tx.Bucket([]byte("transfers"))
+		manifestBucket := tx.Bucket([]byte("manifests"))
+		updated := make(map[string][]byte)
+		err := transfers.ForEach(func(k, v []byte) error {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.Orcid == auth.AnonymousOrcid ||
+				(request.Orcid != "" && record.Orcid != request.Orcid) ||
+				(!request.Before.IsZero() && !record.StopTime.Before(request.Before)) {
+				return nil
+			}
+			record.Orcid = auth.AnonymousOrcid
+			jsonBytes, err := json.Marshal(&record)
+			if err != nil {
+				return err
+			}
+			updated[string(k)] = jsonBytes
+
+			// scrub the transfer's manifest, if we have it
+			if m := manifestBucket.Get([]byte(record.Id.String())); m != nil {
+				scrubbed, err := manifests.ScrubManifest(m)
+				if err != nil {
+					return err
+				}
+				if err := manifestBucket.Put([]byte(record.Id.String()), scrubbed); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updated {
+			if err := transfers.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		numAnonymized = len(updated)
+		return nil
+	})
+	return numAnonymized, err
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/dtstest"
 )
@@ -46,6 +47,9 @@ func TestRunner(t *testing.T) {
 	tester.TestInitAndFinalize()
 	tester.TestRecordSuccessfulTransfer()
 	tester.TestRecordFailedTransfer()
+	tester.TestRecordsWithTags()
+	tester.TestUsageByAllocation()
+	tester.TestAnonymizeUser()
 }
 
 // This runs setup, runs all tests, and does breakdown.
@@ -194,6 +198,130 @@ func (t *SerialTests) TestRecordFailedTransfer() {
 	assert.Nil(err)
 }
 
+func (t *SerialTests) TestRecordsWithTags() {
+	assert := assert.New(t.Test)
+
+	err := Init()
+	assert.Nil(err)
+
+	// record a couple of tagged transfers in a distinct time range
+	stopTime := time.Now().Add(-24 * time.Hour)
+	for i, tags := range [][]string{{"fy25-soil-campaign"}, {"fy25-soil-campaign", "pilot"}} {
+		err = RecordTransfer(Record{
+			Id:          uuid.New(),
+			Source:      "source",
+			Destination: "destination",
+			Orcid:       "1234-5678-9012-3456",
+			Status:      "failed",
+			StartTime:   stopTime.Add(-time.Duration(i+1) * time.Hour),
+			StopTime:    stopTime,
+			PayloadSize: int64(12853294),
+			NumFiles:    12,
+			Tags:        tags,
+		})
+		assert.Nil(err)
+	}
+
+	startTime := stopTime.Add(-3 * time.Hour)
+	records, err := RecordsWithTags(startTime, stopTime, []string{"fy25-soil-campaign"})
+	assert.Nil(err)
+	assert.Equal(2, len(records))
+
+	records, err = RecordsWithTags(startTime, stopTime, []string{"fy25-soil-campaign", "pilot"})
+	assert.Nil(err)
+	assert.Equal(1, len(records))
+	assert.Equal([]string{"fy25-soil-campaign", "pilot"}, records[0].Tags)
+
+	records, err = RecordsWithTags(startTime, stopTime, []string{"nonexistent"})
+	assert.Nil(err)
+	assert.Equal(0, len(records))
+
+	err = Finalize()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestUsageByAllocation() {
+	assert := assert.New(t.Test)
+
+	err := Init()
+	assert.Nil(err)
+
+	// record a few transfers in a distinct time range, two of them attributed
+	// to the same allocation
+	stopTime := time.Now().Add(-48 * time.Hour)
+	for i, allocation := range []string{"m1234", "", "m1234"} {
+		err = RecordTransfer(Record{
+			Id:          uuid.New(),
+			Source:      "source",
+			Destination: "destination",
+			Orcid:       "1234-5678-9012-3456",
+			Allocation:  allocation,
+			Status:      "failed",
+			StartTime:   stopTime.Add(-time.Duration(i+1) * time.Hour),
+			StopTime:    stopTime,
+			PayloadSize: int64(1000 * (i + 1)),
+			NumFiles:    i + 1,
+		})
+		assert.Nil(err)
+	}
+
+	usage, err := UsageByAllocation(stopTime.Add(-4*time.Hour), stopTime)
+	assert.Nil(err)
+	assert.Equal([]AllocationUsage{
+		{Allocation: "", NumTransfers: 1, NumFiles: 2, PayloadSize: 2000},
+		{Allocation: "m1234", NumTransfers: 2, NumFiles: 4, PayloadSize: 4000},
+	}, usage)
+
+	err = Finalize()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestAnonymizeUser() {
+	assert := assert.New(t.Test)
+
+	err := Init()
+	assert.Nil(err)
+
+	// record transfers for two users in a distinct time range
+	stopTime := time.Now().Add(-72 * time.Hour)
+	for i, orcid := range []string{"1111-2222-3333-4444", "5555-6666-7777-8888"} {
+		err = RecordTransfer(Record{
+			Id:          uuid.New(),
+			Source:      "source",
+			Destination: "destination",
+			Orcid:       orcid,
+			Status:      "failed",
+			StartTime:   stopTime.Add(-time.Duration(i+1) * time.Hour),
+			StopTime:    stopTime,
+			PayloadSize: int64(1000),
+			NumFiles:    1,
+		})
+		assert.Nil(err)
+	}
+
+	numAnonymized, err := AnonymizeUser("1111-2222-3333-4444")
+	assert.Nil(err)
+	assert.Equal(1, numAnonymized)
+
+	records, err := Records(stopTime.Add(-3*time.Hour), stopTime)
+	assert.Nil(err)
+	orcids := []string{records[0].Orcid, records[1].Orcid}
+	assert.ElementsMatch([]string{auth.AnonymousOrcid, "5555-6666-7777-8888"}, orcids)
+
+	// records of transfers that finished before a cutoff can be anonymized
+	numAnonymized, err = AnonymizeBefore(stopTime.Add(time.Second))
+	assert.Nil(err)
+	assert.GreaterOrEqual(numAnonymized, 1)
+	records, err = Records(stopTime.Add(-3*time.Hour), stopTime)
+	assert.Nil(err)
+	for _, record := range records {
+		assert.Equal(auth.AnonymousOrcid, record.Orcid)
+	}
+
+	err = Finalize()
+	assert.Nil(err)
+}
+
 // temporary testing directory
 var TESTING_DIR string
 
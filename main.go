@@ -49,6 +49,8 @@ import (
 func usage() {
 	fmt.Fprintf(os.Stderr, "%s: usage:\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "%s <config_file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "%s consents <config_file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  (checks the Globus consents needed by configured endpoints)\n")
 	fmt.Fprintf(os.Stderr, "See README.md for details on config files.\n")
 	os.Exit(1)
 }
@@ -66,15 +68,8 @@ func enableLogging() {
 	slog.Debug("Debug logging enabled.")
 }
 
-func main() {
-
-	// the only argument is the configuration filename
-	if len(os.Args) < 2 {
-		usage()
-	}
-	configFile := os.Args[1]
-
-	// read the configuration file and initialize the config package
+// reads the given configuration file and initializes the config package
+func readConfig(configFile string) {
 	log.Printf("Reading configuration from '%s'...\n", configFile)
 	file, err := os.Open(configFile)
 	if err != nil {
@@ -89,6 +84,24 @@ func main() {
 	if err != nil {
 		log.Panicf("Couldn't initialize the configuration: %s\n", err.Error())
 	}
+}
+
+func main() {
+
+	// the only argument is the configuration filename, unless a command is
+	// given
+	if len(os.Args) < 2 {
+		usage()
+	}
+	if os.Args[1] == "consents" {
+		if len(os.Args) < 3 {
+			usage()
+		}
+		readConfig(os.Args[2])
+		enableLogging()
+		os.Exit(checkConsents(os.Stdout))
+	}
+	readConfig(os.Args[1])
 
 	enableLogging()
 
@@ -0,0 +1,361 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package manifests
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+// This package maintains a long-term archive of the manifests generated for
+// completed transfers, indexed by transfer ID, user ORCID, and file ID, so we
+// can determine where (and to whom) a given file has been delivered long after
+// the transfer itself has been forgotten by the task manager.
+
+// an archived manifest and information about the transfer that produced it
+type Entry struct {
+	// UUID identifying the transfer
+	Id uuid.UUID `json:"id"`
+	// the ORCID of the user that requested the transfer
+	Orcid string `json:"orcid"`
+	// the names of the source and destination databases
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// identifiers for the transferred files
+	FileIds []string `json:"file_ids"`
+	// the time at which the manifest was archived
+	CreationTime time.Time `json:"creation_time"`
+	// the content of the manifest (a Frictionless DataPackage)
+	Manifest json.RawMessage `json:"manifest"`
+}
+
+// criteria for selecting archived manifests (empty fields match all entries)
+type Query struct {
+	// the ID of a file that must appear in the transfer
+	FileId string
+	// the ORCID of the user that requested the transfer
+	Orcid string
+}
+
+// returns true if the entry satisfies the query
+func (q Query) Matches(entry Entry) bool {
+	return (q.Orcid == "" || entry.Orcid == q.Orcid) &&
+		(q.FileId == "" || slices.Contains(entry.FileIds, q.FileId))
+}
+
+// opens the manifest archive (if it's not already open)
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "manifests.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{entriesBucket, fileIdsBucket, orcidsBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+	return nil
+}
+
+// closes the manifest archive (if it's been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the manifest archive is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// archives the given manifest entry, replacing any existing entry for the same
+// transfer
+func Archive(entry Entry) error {
+	if entry.CreationTime.IsZero() {
+		entry.CreationTime = time.Now()
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return update(func(tx *bolt.Tx) error {
+		id := []byte(entry.Id.String())
+		if previous := tx.Bucket([]byte(entriesBucket)).Get(id); previous != nil {
+			var old Entry
+			if err := json.Unmarshal(previous, &old); err != nil {
+				return err
+			}
+			if err := unindex(tx, old); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket([]byte(entriesBucket)).Put(id, value); err != nil {
+			return err
+		}
+		return index(tx, entry)
+	})
+}
+
+// retrieves the archived manifest entry for the transfer with the given UUID
+func Fetch(id uuid.UUID) (Entry, error) {
+	var entry Entry
+	err := view(func(tx *bolt.Tx) error {
+		value := tx.Bucket([]byte(entriesBucket)).Get([]byte(id.String()))
+		if value == nil {
+			return &NotFoundError{Id: id}
+		}
+		return json.Unmarshal(value, &entry)
+	})
+	return entry, err
+}
+
+// retrieves all archived manifest entries matching the given query, in order
+// of creation
+func Search(query Query) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	err := view(func(tx *bolt.Tx) error {
+		entryBucket := tx.Bucket([]byte(entriesBucket))
+		addIfMatching := func(value []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			if query.Matches(entry) {
+				entries = append(entries, entry)
+			}
+			return nil
+		}
+
+		// use an index if we can, and scan everything if we can't
+		var bucketName, key string
+		if query.FileId != "" {
+			bucketName, key = fileIdsBucket, query.FileId
+		} else if query.Orcid != "" {
+			bucketName, key = orcidsBucket, query.Orcid
+		} else {
+			return entryBucket.ForEach(func(_, value []byte) error {
+				return addIfMatching(value)
+			})
+		}
+		prefix := indexPrefix(key)
+		cursor := tx.Bucket([]byte(bucketName)).Cursor()
+		for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+			value := entryBucket.Get(k[len(prefix):])
+			if value == nil { // stale index entry
+				continue
+			}
+			if err := addIfMatching(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return a.CreationTime.Compare(b.CreationTime)
+	})
+	return entries, err
+}
+
+// anonymizes all archived manifest entries for transfers requested by the user
+// with the given ORCID, returning the number of entries anonymized
+func AnonymizeUser(orcid string) (int, error) {
+	return anonymize(func(entry Entry) bool {
+		return entry.Orcid == orcid
+	})
+}
+
+// anonymizes all archived manifest entries created before the given time,
+// returning the number of entries anonymized
+func AnonymizeBefore(t time.Time) (int, error) {
+	return anonymize(func(entry Entry) bool {
+		return entry.CreationTime.Before(t)
+	})
+}
+
+// removes personal information (names, email addresses, organizations, and
+// usernames of requesting users) from the given manifest content
+func ScrubManifest(content []byte) ([]byte, error) {
+	var manifest map[string]any
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+	if contributors, ok := manifest["contributors"].([]any); ok {
+		for _, c := range contributors {
+			if contributor, ok := c.(map[string]any); ok {
+				delete(contributor, "email")
+				delete(contributor, "organization")
+				contributor["title"] = auth.AnonymousOrcid
+			}
+		}
+	}
+	if _, found := manifest["username"]; found {
+		manifest["username"] = auth.AnonymousOrcid
+	}
+	return json.Marshal(manifest)
+}
+
+//-----------
+// Internals
+//-----------
+
+const (
+	entriesBucket = "manifests"
+	fileIdsBucket = "file_ids"
+	orcidsBucket  = "orcids"
+)
+
+var db_ *bolt.DB
+var mutex_ sync.Mutex
+
+// runs the given function in a read-only transaction on the archive
+func view(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(f)
+}
+
+// runs the given function in a read-write transaction on the archive
+func update(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(f)
+}
+
+// index keys have the form "<key>\x00<transfer ID>", so all the entries for a
+// given key can be found with a prefix scan
+func indexPrefix(key string) []byte {
+	return append([]byte(key), 0)
+}
+
+func indexKey(key string, id uuid.UUID) []byte {
+	return append(indexPrefix(key), []byte(id.String())...)
+}
+
+// adds index records for the given entry
+func index(tx *bolt.Tx, entry Entry) error {
+	fileIds := tx.Bucket([]byte(fileIdsBucket))
+	for _, fileId := range entry.FileIds {
+		if err := fileIds.Put(indexKey(fileId, entry.Id), nil); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket([]byte(orcidsBucket)).Put(indexKey(entry.Orcid, entry.Id), nil)
+}
+
+// removes index records for the given entry
+func unindex(tx *bolt.Tx, entry Entry) error {
+	fileIds := tx.Bucket([]byte(fileIdsBucket))
+	for _, fileId := range entry.FileIds {
+		if err := fileIds.Delete(indexKey(fileId, entry.Id)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket([]byte(orcidsBucket)).Delete(indexKey(entry.Orcid, entry.Id))
+}
+
+// anonymizes all entries selected by the given function, reindexing them and
+// scrubbing their manifests, and returns the number of entries anonymized
+func anonymize(selected func(entry Entry) bool) (int, error) {
+	numAnonymized := 0
+	err := update(func(tx *bolt.Tx) error {
+		entryBucket := tx.Bucket([]byte(entriesBucket))
+		var entries []Entry
+		err := entryBucket.ForEach(func(_, value []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			if entry.Orcid != auth.AnonymousOrcid && selected(entry) {
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := unindex(tx, entry); err != nil {
+				return err
+			}
+			entry.Orcid = auth.AnonymousOrcid
+			if len(entry.Manifest) > 0 {
+				content, err := ScrubManifest(entry.Manifest)
+				if err != nil {
+					return err
+				}
+				entry.Manifest = content
+			}
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := entryBucket.Put([]byte(entry.Id.String()), value); err != nil {
+				return err
+			}
+			if err := index(tx, entry); err != nil {
+				return err
+			}
+		}
+		numAnonymized = len(entries)
+		return nil
+	})
+	return numAnonymized, err
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package manifests
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+)
+
+const alice = "1234-5678-9012-3456"
+const bob = "2345-6789-0123-4567"
+const carol = "3456-7890-1234-5678"
+
+func TestArchiveAndFetch(t *testing.T) {
+	assert := assert.New(t)
+
+	id := uuid.New()
+	err := Archive(Entry{
+		Id:          id,
+		Orcid:       alice,
+		Source:      "jdp",
+		Destination: "kbase",
+		FileIds:     []string{"file1", "file2"},
+		Manifest:    json.RawMessage(`{"name":"manifest"}`),
+	})
+	assert.Nil(err)
+
+	entry, err := Fetch(id)
+	assert.Nil(err)
+	assert.Equal(alice, entry.Orcid)
+	assert.Equal([]string{"file1", "file2"}, entry.FileIds)
+	assert.JSONEq(`{"name":"manifest"}`, string(entry.Manifest))
+	assert.False(entry.CreationTime.IsZero())
+
+	_, err = Fetch(uuid.New())
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	first, second := uuid.New(), uuid.New()
+	err := Archive(Entry{Id: first, Orcid: alice, FileIds: []string{"file3", "file30"}})
+	assert.Nil(err)
+	err = Archive(Entry{Id: second, Orcid: bob, FileIds: []string{"file3"}})
+	assert.Nil(err)
+
+	// where has file3 been delivered?
+	entries, err := Search(Query{FileId: "file3"})
+	assert.Nil(err)
+	assert.Len(entries, 2)
+	assert.Equal(first, entries[0].Id)
+	assert.Equal(second, entries[1].Id)
+
+	entries, err = Search(Query{FileId: "file3", Orcid: bob})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+	assert.Equal(second, entries[0].Id)
+
+	entries, err = Search(Query{FileId: "file30"})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	entries, err = Search(Query{Orcid: bob})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	// re-archiving an entry replaces its index records
+	err = Archive(Entry{Id: second, Orcid: bob, FileIds: []string{"file4"}})
+	assert.Nil(err)
+	entries, err = Search(Query{FileId: "file3"})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+	entries, err = Search(Query{FileId: "file4"})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	assert := assert.New(t)
+
+	id := uuid.New()
+	err := Archive(Entry{
+		Id:      id,
+		Orcid:   carol,
+		FileIds: []string{"file5"},
+		Manifest: json.RawMessage(`{"name":"manifest","username":"carol",` +
+			`"contributors":[{"title":"Carol","email":"carol@example.com","role":"author"}]}`),
+	})
+	assert.Nil(err)
+
+	numAnonymized, err := AnonymizeUser(carol)
+	assert.Nil(err)
+	assert.Equal(1, numAnonymized)
+
+	entry, err := Fetch(id)
+	assert.Nil(err)
+	assert.Equal(auth.AnonymousOrcid, entry.Orcid)
+	assert.JSONEq(`{"name":"manifest","username":"anonymous",`+
+		`"contributors":[{"title":"anonymous","role":"author"}]}`, string(entry.Manifest))
+
+	// the entry is no longer indexed under the user's ORCID
+	entries, err := Search(Query{Orcid: carol})
+	assert.Nil(err)
+	assert.Len(entries, 0)
+	entries, err = Search(Query{FileId: "file5"})
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	// anonymized entries aren't anonymized again
+	numAnonymized, err = AnonymizeUser(carol)
+	assert.Nil(err)
+	assert.Equal(0, numAnonymized)
+}
+
+func TestAnonymizeBefore(t *testing.T) {
+	assert := assert.New(t)
+
+	cutoff := time.Now()
+	id := uuid.New()
+	err := Archive(Entry{Id: id, Orcid: carol, FileIds: []string{"file6"}})
+	assert.Nil(err)
+
+	_, err = AnonymizeBefore(cutoff)
+	assert.Nil(err)
+	entry, err := Fetch(id)
+	assert.Nil(err)
+	assert.Equal(carol, entry.Orcid)
+
+	_, err = AnonymizeBefore(time.Now())
+	assert.Nil(err)
+	entry, err = Fetch(id)
+	assert.Nil(err)
+	assert.Equal(auth.AnonymousOrcid, entry.Orcid)
+}
+
+// This runs setup, runs all tests, and does breakdown.
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+
+	myConfig := strings.ReplaceAll(manifestsConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.InitSelected([]byte(myConfig), true, false, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open manifest archive: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// configuration
+const manifestsConfig string = `
+service:
+  name: test
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+`
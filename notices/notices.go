@@ -0,0 +1,211 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package notices
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/config"
+)
+
+// This package maintains announcements set by DTS administrators (e.g.
+// "NERSC maintenance Saturday; transfers will queue") so client UIs can warn
+// users of planned disruptions. Notices are persisted in the data directory
+// and are visible only within their (optional) time windows.
+
+// severities of notices
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// an announcement for users of the DTS
+type Notice struct {
+	// UUID identifying the notice
+	Id uuid.UUID `json:"id"`
+	// the text of the announcement
+	Message string `json:"message"`
+	// the severity of the notice ("info", "warning", or "critical")
+	Severity string `json:"severity"`
+	// the times at which the notice becomes visible and expires (zero times
+	// place no limit on visibility)
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// the ORCID of the administrator who posted the notice
+	Author string `json:"author"`
+	// the time at which the notice was posted
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// returns true if the notice is visible at the given time
+func (n Notice) ActiveAt(t time.Time) bool {
+	return (n.Start.IsZero() || !t.Before(n.Start)) && (n.End.IsZero() || t.Before(n.End))
+}
+
+// opens the notice store (if it's not already open)
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "notices.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(noticesBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+	return nil
+}
+
+// closes the notice store (if it's been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the notice store is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// posts the given notice, assigning it a new ID and creation time, and returns
+// it
+func Post(notice Notice) (Notice, error) {
+	notice.Message = strings.TrimSpace(notice.Message)
+	if notice.Message == "" {
+		return notice, &InvalidNoticeError{Message: "a notice must have a message"}
+	}
+	if notice.Severity == "" {
+		notice.Severity = SeverityInfo
+	}
+	if !slices.Contains([]string{SeverityInfo, SeverityWarning, SeverityCritical}, notice.Severity) {
+		return notice, &InvalidNoticeError{Message: "invalid severity: " + notice.Severity}
+	}
+	if !notice.Start.IsZero() && !notice.End.IsZero() && !notice.End.After(notice.Start) {
+		return notice, &InvalidNoticeError{Message: "a notice must end after it starts"}
+	}
+	notice.Id = uuid.New()
+	notice.CreationTime = time.Now().UTC()
+	value, err := json.Marshal(notice)
+	if err != nil {
+		return notice, err
+	}
+	err = update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(noticesBucket)).Put([]byte(notice.Id.String()), value)
+	})
+	return notice, err
+}
+
+// removes the notice with the given ID
+func Remove(id uuid.UUID) error {
+	return update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(noticesBucket))
+		key := []byte(id.String())
+		if bucket.Get(key) == nil {
+			return &NotFoundError{Id: id}
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// returns all notices visible at the given time, most recently posted first
+func Active(t time.Time) ([]Notice, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(all, func(n Notice) bool { return !n.ActiveAt(t) }), nil
+}
+
+// returns all notices (including those not yet visible or expired), most
+// recently posted first
+func All() ([]Notice, error) {
+	notices := make([]Notice, 0)
+	err := view(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(noticesBucket)).ForEach(func(_, value []byte) error {
+			var notice Notice
+			if err := json.Unmarshal(value, &notice); err != nil {
+				return err
+			}
+			notices = append(notices, notice)
+			return nil
+		})
+	})
+	slices.SortFunc(notices, func(a, b Notice) int {
+		return b.CreationTime.Compare(a.CreationTime)
+	})
+	return notices, err
+}
+
+//-----------
+// Internals
+//-----------
+
+const noticesBucket = "notices"
+
+var db_ *bolt.DB
+var mutex_ sync.Mutex
+
+func update(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(f)
+}
+
+func view(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(f)
+}
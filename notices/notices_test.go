@@ -0,0 +1,145 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package notices
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+)
+
+func TestPostAndRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	notice, err := Post(Notice{
+		Message:  "NERSC maintenance Saturday; transfers will queue",
+		Severity: SeverityWarning,
+		Author:   "1234-5678-9012-3456",
+	})
+	assert.Nil(err)
+	assert.NotEqual(uuid.Nil, notice.Id)
+	assert.False(notice.CreationTime.IsZero())
+
+	all, err := All()
+	assert.Nil(err)
+	assert.Contains(all, notice)
+
+	err = Remove(notice.Id)
+	assert.Nil(err)
+	err = Remove(notice.Id)
+	assert.IsType(&NotFoundError{}, err)
+}
+
+func TestActiveNotices(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	current, err := Post(Notice{Message: "current", End: now.Add(time.Hour)})
+	assert.Nil(err)
+	assert.Equal(SeverityInfo, current.Severity)
+	future, err := Post(Notice{Message: "future", Start: now.Add(time.Hour)})
+	assert.Nil(err)
+	expired, err := Post(Notice{Message: "expired", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)})
+	assert.Nil(err)
+
+	active, err := Active(now)
+	assert.Nil(err)
+	assert.Len(active, 1)
+	assert.Equal(current.Id, active[0].Id)
+
+	for _, notice := range []Notice{current, future, expired} {
+		assert.Nil(Remove(notice.Id))
+	}
+}
+
+func TestInvalidNotices(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Post(Notice{Message: "  "})
+	assert.IsType(&InvalidNoticeError{}, err)
+	_, err = Post(Notice{Message: "hi", Severity: "dire"})
+	assert.IsType(&InvalidNoticeError{}, err)
+	now := time.Now()
+	_, err = Post(Notice{Message: "hi", Start: now, End: now.Add(-time.Minute)})
+	assert.IsType(&InvalidNoticeError{}, err)
+}
+
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+
+	myConfig := strings.ReplaceAll(noticesConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.InitSelected([]byte(myConfig), true, false, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open notice store: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// configuration
+const noticesConfig string = `
+service:
+  name: test
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+`
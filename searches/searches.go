@@ -0,0 +1,471 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package searches
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/webhooks"
+)
+
+// This package stores saved searches: database queries saved by users, which
+// the DTS runs periodically to detect newly matching files. A user can poll a
+// saved search for the files that appeared when it was last run, or register a
+// webhook that is notified of them with a signed request (see the webhooks
+// package).
+
+// a database query saved by a user
+type Search struct {
+	// UUID identifying the search
+	Id uuid.UUID `json:"id"`
+	// a name for the search chosen by its owner
+	Name string `json:"name"`
+	// the name of the database searched
+	Database string `json:"database"`
+	// the search query
+	Query string `json:"query"`
+	// the syntax of the query: "dts" for the DTS query syntax, or "" for the
+	// database's native syntax
+	Syntax string `json:"syntax,omitempty"`
+	// database-specific search parameters
+	Specific map[string]any `json:"specific,omitempty"`
+	// the ORCID of the user that owns the search
+	Owner string `json:"owner"`
+	// an optional URL to which newly matching files are POSTed
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// the time at which the search was created
+	CreationTime time.Time `json:"creation_time"`
+	// the time at which the search was last run
+	LastRunTime time.Time `json:"last_run_time"`
+	// a description of the error encountered when the search was last run or
+	// its webhook notified (if any)
+	LastError string `json:"last_error,omitempty"`
+	// IDs of files matched by the search when it was last run
+	FileIds []string `json:"file_ids,omitempty"`
+	// IDs of files first matched by the search when it was last run
+	NewFileIds []string `json:"new_file_ids,omitempty"`
+}
+
+// the body of a request POSTed to a saved search's webhook
+type Notification struct {
+	// UUID identifying the search
+	Id uuid.UUID `json:"id"`
+	// the name of the search
+	Name string `json:"name"`
+	// the name of the database searched
+	Database string `json:"database"`
+	// IDs of files that newly match the search
+	NewFileIds []string `json:"new_file_ids"`
+	// the time at which the search was run
+	Time time.Time `json:"time"`
+}
+
+// the number of matching files requested from a database at a time when a
+// saved search is run
+var pageSize = 1000
+
+// opens the saved search store (if it's not already open) and begins running
+// saved searches at the configured interval
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "searches.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+
+	if config.Service.SavedSearchInterval > 0 {
+		stop_ = make(chan struct{})
+		go runPeriodically(time.Duration(config.Service.SavedSearchInterval)*time.Second, stop_)
+	}
+	return nil
+}
+
+// stops running saved searches and closes the saved search store (if it's
+// been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	if stop_ != nil {
+		close(stop_)
+		stop_ = nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the saved search store is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// stores a new saved search, returning its UUID
+func Create(search Search) (uuid.UUID, error) {
+	search.Id = uuid.New()
+	search.CreationTime = time.Now()
+	return search.Id, update(func(bucket *bolt.Bucket) error {
+		return put(bucket, search)
+	})
+}
+
+// retrieves the saved search with the given UUID on behalf of the user with the
+// given ORCID, who must own it
+func Fetch(id uuid.UUID, orcid string) (Search, error) {
+	var search Search
+	err := view(func(bucket *bolt.Bucket) error {
+		var err error
+		search, err = owned(bucket, id, orcid)
+		return err
+	})
+	return search, err
+}
+
+// retrieves all saved searches owned by the user with the given ORCID, in
+// order of creation
+func List(orcid string) ([]Search, error) {
+	searches, err := all()
+	searches = slices.DeleteFunc(searches, func(search Search) bool {
+		return search.Owner != orcid
+	})
+	return searches, err
+}
+
+// deletes the saved search with the given UUID on behalf of the user with the
+// given ORCID, who must own it
+func Delete(id uuid.UUID, orcid string) error {
+	return update(func(bucket *bolt.Bucket) error {
+		if _, err := owned(bucket, id, orcid); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(id.String()))
+	})
+}
+
+// deletes all saved searches owned by the user with the given ORCID, which are
+// of no use to anyone else once the user is anonymized, returning the number
+// of searches deleted
+func AnonymizeUser(orcid string) (int, error) {
+	numDeleted := 0
+	err := update(func(bucket *bolt.Bucket) error {
+		var owned [][]byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			var search Search
+			if err := json.Unmarshal(value, &search); err != nil {
+				return err
+			}
+			if search.Owner == orcid {
+				owned = append(owned, slices.Clone(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range owned {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		numDeleted = len(owned)
+		return nil
+	})
+	return numDeleted, err
+}
+
+// Runs the saved search with the given UUID, recording the files it matches
+// and those that newly match it, and notifying its webhook (if any) of the
+// latter. The first run of a search establishes the files it matches without
+// reporting any as new. Errors encountered running the search or notifying its
+// webhook are recorded in the search's LastError field, and files whose
+// notification fails are reported again on the next run.
+func Run(id uuid.UUID) (Search, error) {
+	var search Search
+	err := view(func(bucket *bolt.Bucket) error {
+		var err error
+		search, err = get(bucket, id)
+		return err
+	})
+	if err != nil {
+		return search, err
+	}
+
+	runTime := time.Now()
+	fileIds, err := matchingFileIds(search)
+	search.LastError = ""
+	if err != nil {
+		search.LastError = err.Error()
+	} else {
+		search.NewFileIds = nil
+		if !search.LastRunTime.IsZero() {
+			for _, fileId := range fileIds {
+				if !slices.Contains(search.FileIds, fileId) {
+					search.NewFileIds = append(search.NewFileIds, fileId)
+				}
+			}
+		}
+		if len(search.NewFileIds) > 0 && search.WebhookURL != "" {
+			err = notify(search, runTime)
+		}
+		if err != nil { // retry the notification on the next run
+			search.LastError = err.Error()
+		} else {
+			search.FileIds = fileIds
+		}
+	}
+	search.LastRunTime = runTime
+
+	// store the results unless the search was deleted in the meantime
+	err = update(func(bucket *bolt.Bucket) error {
+		if _, err := get(bucket, id); err != nil {
+			return err
+		}
+		return put(bucket, search)
+	})
+	return search, err
+}
+
+//-----------
+// Internals
+//-----------
+
+const bucketName = "searches"
+
+var db_ *bolt.DB
+var stop_ chan struct{}
+var mutex_ sync.Mutex
+
+// runs the given function on the searches bucket in a read-only transaction
+func view(f func(bucket *bolt.Bucket) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(func(tx *bolt.Tx) error {
+		return f(tx.Bucket([]byte(bucketName)))
+	})
+}
+
+// runs the given function on the searches bucket in a read-write transaction
+func update(f func(bucket *bolt.Bucket) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(func(tx *bolt.Tx) error {
+		return f(tx.Bucket([]byte(bucketName)))
+	})
+}
+
+func get(bucket *bolt.Bucket, id uuid.UUID) (Search, error) {
+	var search Search
+	value := bucket.Get([]byte(id.String()))
+	if value == nil {
+		return search, &NotFoundError{Id: id}
+	}
+	err := json.Unmarshal(value, &search)
+	return search, err
+}
+
+// retrieves the saved search with the given UUID, provided that it's owned by
+// the user with the given ORCID
+func owned(bucket *bolt.Bucket, id uuid.UUID, orcid string) (Search, error) {
+	search, err := get(bucket, id)
+	if err == nil && search.Owner != orcid {
+		return Search{}, &NotFoundError{Id: id} // don't reveal others' searches
+	}
+	return search, err
+}
+
+func put(bucket *bolt.Bucket, search Search) error {
+	value, err := json.Marshal(search)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(search.Id.String()), value)
+}
+
+// retrieves all saved searches in order of creation
+func all() ([]Search, error) {
+	searches := make([]Search, 0)
+	err := view(func(bucket *bolt.Bucket) error {
+		return bucket.ForEach(func(_, value []byte) error {
+			var search Search
+			if err := json.Unmarshal(value, &search); err != nil {
+				return err
+			}
+			searches = append(searches, search)
+			return nil
+		})
+	})
+	slices.SortFunc(searches, func(a, b Search) int {
+		return a.CreationTime.Compare(b.CreationTime)
+	})
+	return searches, err
+}
+
+// runs all saved searches at the given interval until the given channel is
+// closed
+func runPeriodically(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			searches, err := all()
+			if err != nil {
+				slog.Error(fmt.Sprintf("Couldn't retrieve saved searches: %s", err.Error()))
+				continue
+			}
+			for _, search := range searches {
+				search, err = Run(search.Id)
+				if err != nil {
+					if _, deleted := err.(*NotFoundError); !deleted {
+						slog.Error(fmt.Sprintf("Couldn't run saved search %s: %s", search.Id, err.Error()))
+					}
+				} else if search.LastError != "" {
+					slog.Warn(fmt.Sprintf("Saved search %s: %s", search.Id, search.LastError))
+				} else if len(search.NewFileIds) > 0 {
+					slog.Info(fmt.Sprintf("Saved search %s matched %d new file(s)", search.Id,
+						len(search.NewFileIds)))
+				}
+			}
+		}
+	}
+}
+
+// runs the given saved search on behalf of its owner, returning the IDs of
+// the matching files
+func matchingFileIds(search Search) ([]string, error) {
+	db, err := databases.NewDatabase(search.Database)
+	if err != nil {
+		return nil, err
+	}
+	query := search.Query
+	if search.Syntax == "dts" && query != "" {
+		query, err = databases.TranslateQuery(db, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// page through all matching files
+	fileIds := make([]string, 0)
+	seen := make(map[string]struct{})
+	for offset := 0; ; offset += pageSize {
+		results, err := db.Search(search.Owner, databases.SearchParameters{
+			Query: query,
+			Pagination: databases.SearchPaginationParameters{
+				Offset: offset,
+				MaxNum: pageSize,
+			},
+			Specific: specificParameters(search.Specific),
+		})
+		if err != nil {
+			return nil, err
+		}
+		numNew := 0
+		for _, descriptor := range results.Descriptors {
+			if id, ok := descriptor["id"].(string); ok {
+				if _, found := seen[id]; !found {
+					seen[id] = struct{}{}
+					fileIds = append(fileIds, id)
+					numNew++
+				}
+			}
+		}
+		// stop at the last page (or if the database ignores the offset)
+		if len(results.Descriptors) < pageSize || numNew == 0 {
+			break
+		}
+	}
+	return fileIds, nil
+}
+
+// restores integer-valued database-specific search parameters, which are
+// decoded from the store as floating point numbers
+func specificParameters(specific map[string]any) map[string]any {
+	if specific == nil {
+		return nil
+	}
+	params := make(map[string]any)
+	for key, value := range specific {
+		if v, ok := value.(float64); ok && v == math.Floor(v) {
+			params[key] = int(v)
+		} else {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// POSTs the files newly matching the given saved search to its webhook
+func notify(search Search, runTime time.Time) error {
+	body, err := json.Marshal(Notification{
+		Id:         search.Id,
+		Name:       search.Name,
+		Database:   search.Database,
+		NewFileIds: search.NewFileIds,
+		Time:       runTime,
+	})
+	if err != nil {
+		return err
+	}
+	if err = webhooks.Post(search.WebhookURL, body, nil); err != nil {
+		return fmt.Errorf("couldn't notify webhook: %s", err.Error())
+	}
+	return nil
+}
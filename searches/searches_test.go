@@ -0,0 +1,297 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package searches
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/dtstest"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/webhooks"
+)
+
+const owner = "1234-5678-9012-3456"
+const stranger = "3456-7890-1234-5678"
+
+// tests that saved searches are visible only to their owners
+func TestCreateFetchListAndDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := Create(Search{
+		Name:     "soil metagenomes",
+		Database: "test-source",
+		Query:    "file1",
+		Owner:    owner,
+	})
+	assert.Nil(err)
+
+	search, err := Fetch(id, owner)
+	assert.Nil(err)
+	assert.Equal("soil metagenomes", search.Name)
+	assert.Equal("file1", search.Query)
+
+	// others can't see the search
+	_, err = Fetch(id, stranger)
+	assert.IsType(&NotFoundError{}, err)
+	searches, err := List(stranger)
+	assert.Nil(err)
+	assert.Empty(searches)
+	err = Delete(id, stranger)
+	assert.IsType(&NotFoundError{}, err)
+
+	searches, err = List(owner)
+	assert.Nil(err)
+	assert.Len(searches, 1)
+
+	err = Delete(id, owner)
+	assert.Nil(err)
+	_, err = Fetch(id, owner)
+	assert.IsType(&NotFoundError{}, err)
+	_, err = Run(uuid.New())
+	assert.IsType(&NotFoundError{}, err)
+}
+
+// tests the deletion of a user's saved searches when the user is anonymized
+func TestAnonymizeUser(t *testing.T) {
+	assert := assert.New(t)
+
+	const departing = "4567-8901-2345-6789"
+	id, err := Create(Search{
+		Name:     "marine metagenomes",
+		Database: "test-source",
+		Query:    "file2",
+		Owner:    departing,
+	})
+	assert.Nil(err)
+	othersId, err := Create(Search{
+		Name:     "lake metagenomes",
+		Database: "test-source",
+		Query:    "file3",
+		Owner:    stranger,
+	})
+	assert.Nil(err)
+
+	numDeleted, err := AnonymizeUser(departing)
+	assert.Nil(err)
+	assert.Equal(1, numDeleted)
+	_, err = Fetch(id, departing)
+	assert.IsType(&NotFoundError{}, err)
+	_, err = Fetch(othersId, stranger)
+	assert.Nil(err)
+
+	err = Delete(othersId, stranger)
+	assert.Nil(err)
+}
+
+// tests the detection of newly matching files and the notification of a saved
+// search's webhook
+func TestRunAndNotify(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a webhook that records notifications
+	var notifications []Notification
+	webhookStatus := http.StatusOK
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(err)
+		assert.Equal(webhooks.Signature(body, "callback-secret"), r.Header.Get("X-DTS-Signature"))
+		var notification Notification
+		err = json.Unmarshal(body, &notification)
+		assert.Nil(err)
+		notifications = append(notifications, notification)
+		w.WriteHeader(webhookStatus)
+	}))
+	defer webhook.Close()
+
+	id, err := Create(Search{
+		Name:       "new files",
+		Database:   "test-source",
+		Query:      "file1 file2 file3",
+		Owner:      owner,
+		WebhookURL: webhook.URL,
+	})
+	assert.Nil(err)
+	defer Delete(id, owner)
+
+	// the first run establishes the matching files without reporting new ones
+	search, err := Run(id)
+	assert.Nil(err)
+	assert.Empty(search.LastError)
+	assert.Equal([]string{"file1", "file2"}, search.FileIds)
+	assert.Empty(search.NewFileIds)
+	assert.Empty(notifications)
+
+	// a new file appears upstream, but the webhook is down
+	testDescriptors["file3"] = map[string]any{"id": "file3", "name": "file3.txt", "path": "file3.txt"}
+	defer delete(testDescriptors, "file3")
+	webhookStatus = http.StatusServiceUnavailable
+	search, err = Run(id)
+	assert.Nil(err)
+	assert.NotEmpty(search.LastError)
+	assert.Equal([]string{"file3"}, search.NewFileIds)
+	assert.Len(notifications, 1)
+
+	// the notification is retried on the next run
+	webhookStatus = http.StatusOK
+	search, err = Run(id)
+	assert.Nil(err)
+	assert.Empty(search.LastError)
+	assert.Equal([]string{"file1", "file2", "file3"}, search.FileIds)
+	assert.Equal([]string{"file3"}, search.NewFileIds)
+	assert.Len(notifications, 2)
+	assert.Equal(id, notifications[1].Id)
+	assert.Equal([]string{"file3"}, notifications[1].NewFileIds)
+
+	// nothing new appears thereafter
+	search, err = Run(id)
+	assert.Nil(err)
+	assert.Empty(search.NewFileIds)
+	assert.Len(notifications, 2)
+
+	// all matching files are found, however many pages of results they span
+	pageSize = 1
+	defer func() { pageSize = 1000 }()
+	search, err = Run(id)
+	assert.Nil(err)
+	assert.Equal([]string{"file1", "file2", "file3"}, search.FileIds)
+	assert.Empty(search.NewFileIds)
+}
+
+// tests that webhooks at private addresses aren't notified unless the service
+// allows them
+func TestPrivateWebhook(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Service.AllowPrivateWebhooks = false
+	defer func() { config.Service.AllowPrivateWebhooks = true }()
+	id, err := Create(Search{
+		Name:       "private",
+		Database:   "test-source",
+		Query:      "file1",
+		Owner:      owner,
+		WebhookURL: "http://127.0.0.1:9/hook",
+	})
+	assert.Nil(err)
+	defer Delete(id, owner)
+	search, err := Run(id)
+	assert.Nil(err)
+	search.NewFileIds = []string{"file1"}
+	err = notify(search, time.Now())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "private addresses are not allowed")
+}
+
+// This runs setup, runs all tests, and does breakdown.
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+	myConfig := strings.ReplaceAll(searchesConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.Init([]byte(myConfig))
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = endpoints.RegisterEndpointProvider("local", local.NewEndpoint)
+	if err != nil {
+		log.Panicf("Couldn't register endpoint provider: %s", err)
+	}
+	testDescriptors = map[string]map[string]any{
+		"file1": {"id": "file1", "name": "file1.txt", "path": "file1.txt"},
+		"file2": {"id": "file2", "name": "file2.txt", "path": "file2.txt"},
+	}
+	err = dtstest.RegisterDatabase("test-source", testDescriptors)
+	if err != nil {
+		log.Panicf("Couldn't register test database: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open saved search store: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// descriptors for files in the test database
+var testDescriptors map[string]map[string]any
+
+// configuration
+const searchesConfig string = `
+service:
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+  endpoint: local-endpoint
+  saved_search_interval: 0
+  callback_secret: callback-secret
+  allow_private_webhooks: true
+databases:
+  test-source:
+    name: Source Test Database
+    organization: The Source Company
+    endpoint: local-endpoint
+endpoints:
+  local-endpoint:
+    name: Local endpoint
+    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6
+    provider: local
+    root: TESTING_DIR
+`
@@ -1,19 +1,26 @@
 package services
 
 import (
+	"bytes"
 	"cmp"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"math"
 	"net"
 	"net/http"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humamux"
@@ -22,11 +29,23 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/net/netutil"
 
+	"github.com/kbase/dts/audit"
 	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/collections"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/faults"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/journal"
+	"github.com/kbase/dts/manifests"
+	"github.com/kbase/dts/notices"
+	"github.com/kbase/dts/searches"
 	"github.com/kbase/dts/tasks"
+	"github.com/kbase/dts/tracing"
+	"github.com/kbase/dts/units"
+	"github.com/kbase/dts/webhooks"
 )
 
 // This type implements the TransferService interface, allowing file transfers
@@ -46,6 +65,24 @@ type prototype struct {
 	API huma.API
 	// HTTP server.
 	Server *http.Server
+	// staging requests not associated with transfers, by ID
+	StagingRequests map[uuid.UUID]stagingRequest
+	// mutex guarding staging requests
+	StagingMutex sync.Mutex
+}
+
+// a record of a staging request not associated with a transfer
+type stagingRequest struct {
+	// name of the database staging the files
+	Database string
+	// ORCID of the user for whom the files are staged
+	Orcid string
+	// number of files being staged
+	NumFiles int
+	// estimate of tape recalls needed for staging, if available
+	TapeRecall databases.TapeRecallEstimate
+	// time at which staging was requested
+	Time time.Time
 }
 
 // constructs a prototype file transfer service given our configuration
@@ -55,26 +92,129 @@ func NewDTSPrototype() (TransferService, error) {
 	service.Name = "DTS prototype"
 	service.Version = version
 	service.Port = -1
+	service.StagingRequests = make(map[uuid.UUID]stagingRequest)
 
 	// set up routing
 	service.Router = mux.NewRouter()
 	api := humamux.New(service.Router, huma.DefaultConfig(service.Name, service.Version))
+	api.UseMiddleware(traceRequests)
+	api.UseMiddleware(deprecationHeaders)
 	huma.Get(api, "/", service.getRoot)
 
 	// API v1
+	huma.Get(api, "/api/v1/capabilities", service.getCapabilities)
+	huma.Get(api, "/api/v1/credentials", service.getCredentialStatuses)
 	huma.Get(api, "/api/v1/databases", service.getDatabases)
 	huma.Get(api, "/api/v1/databases/{db}", service.getDatabase)
 	huma.Get(api, "/api/v1/databases/{db}/search-parameters", service.getDatabaseSearchParameters)
+	huma.Post(api, "/api/v1/endpoints/bandwidth-test", service.testBandwidth)
 	huma.Get(api, "/api/v1/files", service.searchDatabase)
 	huma.Post(api, "/api/v1/files", service.searchDatabaseWithSpecificParams)
 	huma.Get(api, "/api/v1/files/by-id", service.fetchFileMetadata)
+	huma.Get(api, "/api/v1/files/estimate", service.estimateFiles)
+	huma.Get(api, "/api/v1/files/preview", service.previewFile)
+	huma.Post(api, "/api/v1/files/diff", service.diffFileMetadata)
+	huma.Post(api, "/api/v1/files/stage", service.stageFiles)
+	huma.Get(api, "/api/v1/files/stage/{id}", service.getStagingStatus)
+	huma.Get(api, "/api/v1/transfers", service.listTransfers, supersededBy("/api/v2/transfers"))
 	huma.Post(api, "/api/v1/transfers", service.createTransfer)
-	huma.Get(api, "/api/v1/transfers/{id}", service.getTransferStatus)
+	huma.Get(api, "/api/v1/transfers/{id}", service.getTransferStatus, supersededBy("/api/v2/transfers/{id}"))
+	huma.Patch(api, "/api/v1/transfers/{id}", service.annotateTransfer)
+	huma.Post(api, "/api/v1/transfers/{id}/pause", service.pauseTransfer)
+	huma.Post(api, "/api/v1/transfers/{id}/resume", service.resumeTransfer)
+	huma.Post(api, "/api/v1/transfers/{id}/verify", service.verifyTransfer)
 	huma.Delete(api, "/api/v1/transfers/{id}", service.deleteTransfer)
+	huma.Get(api, "/api/v1/batches/{id}", service.getBatchStatus)
+	huma.Get(api, "/api/v1/collections", service.listCollections)
+	huma.Post(api, "/api/v1/collections", service.createCollection)
+	huma.Get(api, "/api/v1/collections/{id}", service.getCollection)
+	huma.Patch(api, "/api/v1/collections/{id}", service.shareCollection)
+	huma.Delete(api, "/api/v1/collections/{id}", service.deleteCollection)
+	huma.Get(api, "/api/v1/searches", service.listSavedSearches)
+	huma.Post(api, "/api/v1/searches", service.createSavedSearch)
+	huma.Get(api, "/api/v1/searches/{id}", service.getSavedSearch)
+	huma.Delete(api, "/api/v1/searches/{id}", service.deleteSavedSearch)
+	huma.Get(api, "/api/v1/manifests", service.searchManifests)
+	huma.Get(api, "/api/v1/manifests/{id}", service.getManifest)
+	huma.Get(api, "/api/v1/notices", service.getNotices)
+	huma.Post(api, "/api/v1/notices", service.postNotice)
+	huma.Delete(api, "/api/v1/notices/{id}", service.deleteNotice)
+	huma.Get(api, "/api/v1/statistics/allocations", service.getAllocationUsage)
+	huma.Get(api, "/api/v1/statistics/stages", service.getStageTimings)
+	huma.Get(api, "/api/v1/statistics/janitor", service.getJanitorStatistics)
+	huma.Post(api, "/api/v1/users/{orcid}/anonymize", service.anonymizeUser)
+
+	// API v2
+	huma.Get(api, "/api/v2/transfers", service.listTransfersV2)
+	huma.Get(api, "/api/v2/transfers/{id}", service.getTransferStatusV2)
+
+	// chaos testing
+	if config.Service.FaultInjection {
+		huma.Post(api, "/api/v1/transfers/{id}/faults", service.injectFault)
+		huma.Delete(api, "/api/v1/transfers/{id}/faults", service.clearFaults)
+	}
 
 	return service, nil
 }
 
+// The /api/v1 endpoints remain stable for as long as they are offered. When an
+// endpoint is superseded by a later version, it is marked deprecated in the
+// OpenAPI spec and its responses carry a Deprecation header and a Link header
+// identifying its successor.
+
+// returns an operation handler that marks an operation as deprecated in favor
+// of the endpoint with the given path (which may refer to the operation's
+// path parameters)
+func supersededBy(successor string) func(o *huma.Operation) {
+	return func(o *huma.Operation) {
+		o.Deprecated = true
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]any)
+		}
+		o.Metadata["successor"] = successor
+	}
+}
+
+// middleware that adds Deprecation and Link headers to responses from
+// deprecated operations
+func deprecationHeaders(ctx huma.Context, next func(huma.Context)) {
+	op := ctx.Operation()
+	if op.Deprecated {
+		ctx.SetHeader("Deprecation", "true")
+		if successor, ok := op.Metadata["successor"].(string); ok {
+			for _, param := range op.Parameters {
+				if param.In == "path" {
+					successor = strings.ReplaceAll(successor, "{"+param.Name+"}", ctx.Param(param.Name))
+				}
+			}
+			ctx.SetHeader("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		}
+	}
+	next(ctx)
+}
+
+// middleware that traces the handling of each request in a server span,
+// continuing the trace identified by the request's traceparent header (if
+// any) and identifying the span in the response's traceresponse header
+func traceRequests(ctx huma.Context, next func(huma.Context)) {
+	if !tracing.Enabled() {
+		next(ctx)
+		return
+	}
+	parent, _ := tracing.ParseTraceParent(ctx.Header("traceparent")) // (a new trace if invalid)
+	spanCtx, span := tracing.Start(tracing.ContextWithSpanContext(ctx.Context(), parent),
+		fmt.Sprintf("%s %s", ctx.Method(), ctx.Operation().Path), tracing.SpanKindServer)
+	span.SetAttribute("http.request.method", ctx.Method())
+	span.SetAttribute("http.route", ctx.Operation().Path)
+	ctx.SetHeader("traceresponse", span.Context.TraceParent())
+	next(huma.WithContext(ctx, spanCtx))
+	span.SetAttribute("http.response.status_code", ctx.Status())
+	if ctx.Status() >= 500 {
+		span.Error = http.StatusText(ctx.Status())
+	}
+	span.End()
+}
+
 // starts the prototype data transfer service
 func (service *prototype) Start(port int) error {
 	slog.Info(fmt.Sprintf("Starting %s v%s on port %d...", service.Name, version, port))
@@ -91,7 +231,32 @@ func (service *prototype) Start(port int) error {
 	defer listener.Close()
 	listener = netutil.LimitListener(listener, config.Service.MaxConnections)
 
-	// start tasks processing
+	// start exporting traces, open the collection store, saved search store,
+	// manifest archive, and notice store, and start tasks processing
+	err = tracing.Init()
+	if err != nil {
+		return err
+	}
+	err = collections.Init()
+	if err != nil {
+		return err
+	}
+	err = searches.Init()
+	if err != nil {
+		return err
+	}
+	err = manifests.Init()
+	if err != nil {
+		return err
+	}
+	err = notices.Init()
+	if err != nil {
+		return err
+	}
+	err = audit.Init()
+	if err != nil {
+		return err
+	}
 	err = tasks.Start()
 	if err != nil {
 		return err
@@ -112,6 +277,12 @@ func (service *prototype) Start(port int) error {
 // gracefully shuts down the service without interrupting active connections
 func (service *prototype) Shutdown(ctx context.Context) error {
 	tasks.Stop()
+	collections.Finalize()
+	searches.Finalize()
+	manifests.Finalize()
+	notices.Finalize()
+	audit.Finalize()
+	tracing.Finalize()
 	if service.Server != nil {
 		return service.Server.Shutdown(ctx)
 	}
@@ -121,6 +292,12 @@ func (service *prototype) Shutdown(ctx context.Context) error {
 // closes down the service abruptly, freeing all resources
 func (service *prototype) Close() {
 	tasks.Stop()
+	collections.Finalize()
+	searches.Finalize()
+	manifests.Finalize()
+	notices.Finalize()
+	audit.Finalize()
+	tracing.Finalize()
 	if service.Server != nil {
 		service.Server.Close()
 	}
@@ -201,6 +378,34 @@ func (service *prototype) getRoot(ctx context.Context,
 			Version:       service.Version,
 			Uptime:        int(service.uptime()),
 			Documentation: "/docs",
+			Notices:       activeNotices(),
+		},
+	}, nil
+}
+
+type CapabilitiesOutput struct {
+	Body CapabilitiesResponse `doc:"the optional features enabled in this deployment"`
+}
+
+// handler method for querying the service's capabilities (no authorization
+// needed for this one either)
+func (service *prototype) getCapabilities(ctx context.Context,
+	input *struct{}) (*CapabilitiesOutput, error) {
+
+	pathSanitization := config.Service.PathSanitization
+	if pathSanitization == "none" {
+		pathSanitization = ""
+	}
+	return &CapabilitiesOutput{
+		Body: CapabilitiesResponse{
+			APIVersions:             []string{"v1", "v2"},
+			CustomTransfers:         true,
+			ManifestFormats:         []string{"frictionless-data-package"},
+			FederatedSearch:         true,
+			Webhooks:                config.Service.CallbackSecret != "",
+			MaxPayloadBytes:         units.GigabytesToBytes(config.Service.MaxPayloadSize),
+			PathSanitization:        pathSanitization,
+			ComputeMissingChecksums: config.Service.ComputeMissingChecksums,
 		},
 	}, nil
 }
@@ -234,6 +439,7 @@ func (service *prototype) getDatabases(ctx context.Context,
 				Id:           dbName,
 				Name:         db.Name,
 				Organization: db.Organization,
+				Terms:        db.Terms,
 			})
 		}
 	}
@@ -265,6 +471,7 @@ func (service *prototype) getDatabase(ctx context.Context,
 			Id:           input.Id,
 			Name:         db.Name,
 			Organization: db.Organization,
+			Terms:        db.Terms,
 		},
 	}, nil
 }
@@ -383,12 +590,17 @@ type SearchResultsOutput struct {
 }
 
 type SearchDatabaseInputWithoutHeader struct {
-	Database string `json:"database" query:"database" example:"jdp" doc:"The ID of the database to search"`
+	Database string `json:"database" query:"database" example:"jdp" doc:"The ID of the database to search, a comma-separated list of database IDs, or \"all\" to search every database"`
 	Orcid    string `json:"orcid" query:"orcid" example:"1234-5678-9101=112X" doc:"The ORCID of the user searching for files"`
 	Query    string `json:"query" query:"query" example:"prochlorococcus" doc:"A query used to search the database for matching files"`
+	Syntax   string `json:"syntax,omitempty" query:"syntax" example:"dts" enum:"native,dts" doc:"(Optional) The syntax of the query: the database's native syntax (default) or the DTS query syntax (field:value terms, quoted phrases, AND/OR)"`
 	Status   string `json:"status" query:"status" example:"\"staged\"" doc:"(Optional) The staged or unstaged status of the desired files"`
 	Offset   int    `json:"offset" query:"offset" example:"100" doc:"Search results begin at the given offset"`
 	Limit    int    `json:"limit" query:"limit" example:"50" doc:"Limits the number of search results returned"`
+	Sort     string `json:"sort,omitempty" query:"sort" example:"bytes" enum:"name,bytes,format,date" doc:"(Optional) The field by which search results are sorted before pagination"`
+	Order    string `json:"order,omitempty" query:"order" example:"desc" enum:"asc,desc" doc:"(Optional) The order in which search results are sorted (default: asc)"`
+	Explain  bool   `json:"explain,omitempty" query:"explain" doc:"(Optional) If true, the response includes the upstream requests issued by the database and their latency"`
+	Related  bool   `json:"related,omitempty" query:"related" doc:"(Optional) If true, each result lists the IDs of related resources in other databases in its related_ids field"`
 }
 
 type SearchDatabaseInput struct {
@@ -417,7 +629,7 @@ func databaseError(err error) error {
 }
 
 // implements database search for both GET and POST requests
-func searchDatabase(_ context.Context,
+func searchDatabase(ctx context.Context,
 	input *SearchDatabaseInput,
 	specific map[string]json.RawMessage) (*SearchResultsOutput, error) {
 
@@ -426,10 +638,16 @@ func searchDatabase(_ context.Context,
 		return nil, err
 	}
 
-	// is the database valid?
-	_, ok := config.Databases[input.Database]
-	if !ok {
-		return nil, databaseError(&databases.NotFoundError{Database: input.Database})
+	// are the databases valid?
+	dbNames := searchedDatabases(input.Database)
+	for _, dbName := range dbNames {
+		if _, ok := config.Databases[dbName]; !ok {
+			return nil, databaseError(&databases.NotFoundError{Database: dbName})
+		}
+	}
+	federated := input.Database == "all" || len(dbNames) > 1
+	if federated && input.Explain {
+		return nil, huma.Error400BadRequest("explain is not supported for searches of several databases")
 	}
 
 	// check the requested file status
@@ -483,41 +701,195 @@ func searchDatabase(_ context.Context,
 		}
 	}
 
-	slog.Info(fmt.Sprintf("Searching database %s for files...", input.Database))
-	db, err := databases.NewDatabase(input.Database)
-	if err != nil {
-		return nil, databaseError(err)
+	if input.Syntax != "" && input.Syntax != "native" && input.Syntax != "dts" {
+		return nil, fmt.Errorf("invalid syntax parameter: %s", input.Syntax)
 	}
-
-	results, err := db.Search(orcid, databases.SearchParameters{
+	params := databases.SearchParameters{
 		Query:  input.Query,
 		Status: fileStatus,
 		Pagination: databases.SearchPaginationParameters{
 			Offset: input.Offset,
 			MaxNum: input.Limit,
 		},
+		Sort: databases.SearchSortParameters{
+			Field:      input.Sort,
+			Descending: input.Order == "desc",
+		},
 		Specific: dbSpecific,
-	})
+	}
+	if federated {
+		return federatedSearch(ctx, input, dbNames, orcid, params)
+	}
+
+	slog.Info(fmt.Sprintf("Searching database %s for files...", input.Database))
+	var results databases.SearchResults
+	var explanation *SearchExplanation
+	if input.Explain {
+		params.Query, err = nativeQuery(input.Database, input.Syntax, input.Query)
+		if err != nil {
+			return nil, databaseError(err)
+		}
+		var requests []databases.UpstreamRequest
+		results, requests, err = databases.ExplainedSearch(input.Database, orcid, params)
+		explanation = &SearchExplanation{
+			NativeQuery: params.Query,
+			Requests:    requests,
+		}
+		for _, request := range requests {
+			explanation.Latency += request.Latency
+		}
+	} else {
+		results, err = searchOneDatabase(ctx, input.Database, input.Syntax, orcid, params)
+	}
 	if err != nil {
 		return nil, databaseError(err)
 	}
+	if input.Related {
+		databases.LinkRelatedIds(input.Database, orcid, results.Descriptors)
+	}
 	// validate the descriptors and send them along
-	for _, descriptor := range results.Descriptors {
-		err = validator.Validate(descriptor, "data-resource", validator.MustInMemoryRegistry())
-		if err != nil {
-			slog.Error(err.Error())
-			return nil, err
-		}
+	if err := validateDescriptors(results.Descriptors); err != nil {
+		return nil, err
 	}
 	return &SearchResultsOutput{
 		Body: SearchResultsResponse{
 			Database:    input.Database,
 			Query:       input.Query,
 			Descriptors: results.Descriptors,
+			Explanation: explanation,
 		},
 	}, nil
 }
 
+// returns the names of the databases named in the given database search
+// parameter, which holds a database name, a comma-separated list of names, or
+// "all" for every registered database
+func searchedDatabases(database string) []string {
+	if database == "all" {
+		var dbNames []string
+		for dbName := range config.Databases {
+			if databases.HaveDatabase(dbName) {
+				dbNames = append(dbNames, dbName)
+			}
+		}
+		slices.Sort(dbNames)
+		return dbNames
+	}
+	var dbNames []string
+	for _, dbName := range strings.Split(database, ",") {
+		dbName = strings.TrimSpace(dbName)
+		if !slices.Contains(dbNames, dbName) {
+			dbNames = append(dbNames, dbName)
+		}
+	}
+	return dbNames
+}
+
+// translates the given query into the native syntax of the database with the
+// given name if it's given in the DTS query syntax
+func nativeQuery(dbName, syntax, query string) (string, error) {
+	if syntax != "dts" || query == "" {
+		return query, nil
+	}
+	db, err := databases.NewDatabase(dbName)
+	if err != nil {
+		return "", err
+	}
+	return databases.TranslateQuery(db, query)
+}
+
+// searches the database with the given name for files visible to the user
+// with the given ORCID, translating a query given in the given syntax, and
+// tracing the search within the trace carried by the given context
+func searchOneDatabase(ctx context.Context, dbName, syntax, orcid string,
+	params databases.SearchParameters) (results databases.SearchResults, err error) {
+	_, span := tracing.Start(ctx, "search "+dbName, tracing.SpanKindInternal)
+	span.SetAttribute("dts.database", dbName)
+	defer func() {
+		span.SetAttribute("dts.num_results", len(results.Descriptors))
+		span.RecordError(err)
+		span.End()
+	}()
+
+	db, err := databases.NewDatabase(dbName)
+	if err != nil {
+		return databases.SearchResults{}, err
+	}
+	if syntax == "dts" && params.Query != "" {
+		params.Query, err = databases.TranslateQuery(db, params.Query)
+		if err != nil {
+			return databases.SearchResults{}, err
+		}
+	}
+	return databases.SortedSearch(db, orcid, params)
+}
+
+// searches the databases with the given names concurrently, merging their
+// results (each marked with the database in which it was found) and reporting
+// the errors of databases that couldn't be searched instead of failing the
+// search. Pagination parameters apply to each database.
+func federatedSearch(ctx context.Context, input *SearchDatabaseInput, dbNames []string, orcid string,
+	params databases.SearchParameters) (*SearchResultsOutput, error) {
+	slog.Info(fmt.Sprintf("Searching databases %s for files...", strings.Join(dbNames, ", ")))
+	results := make([]databases.SearchResults, len(dbNames))
+	errs := make([]error, len(dbNames))
+	var wg sync.WaitGroup
+	for i, dbName := range dbNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = searchOneDatabase(ctx, dbName, input.Syntax, orcid, params)
+		}()
+	}
+	wg.Wait()
+
+	response := SearchResultsResponse{
+		Database:    input.Database,
+		Query:       input.Query,
+		Descriptors: make([]map[string]any, 0),
+	}
+	for i, dbName := range dbNames {
+		err := errs[i]
+		if err == nil {
+			if input.Related {
+				databases.LinkRelatedIds(dbName, orcid, results[i].Descriptors)
+			}
+			// a database returning invalid descriptors fails only its own part
+			// of the search
+			err = validateDescriptors(results[i].Descriptors)
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("Searching database %s: %s", dbName, err.Error()))
+			if response.Errors == nil {
+				response.Errors = make(map[string]string)
+			}
+			response.Errors[dbName] = err.Error()
+			continue
+		}
+		for _, descriptor := range results[i].Descriptors {
+			descriptor = maps.Clone(descriptor)
+			descriptor["database"] = dbName
+			response.Descriptors = append(response.Descriptors, descriptor)
+		}
+	}
+	if params.Sort.Field != "" {
+		databases.SortDescriptors(response.Descriptors, params.Sort)
+	}
+	return &SearchResultsOutput{Body: response}, nil
+}
+
+// validates the given descriptors as Frictionless data resources
+func validateDescriptors(descriptors []map[string]any) error {
+	for _, descriptor := range descriptors {
+		err := validator.Validate(descriptor, "data-resource", validator.MustInMemoryRegistry())
+		if err != nil {
+			slog.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
 // handle search queries for files of interest (GET, no DB-specific parameters)
 func (service *prototype) searchDatabase(ctx context.Context,
 	input *SearchDatabaseInput) (*SearchResultsOutput, error) {
@@ -547,9 +919,14 @@ func (service *prototype) searchDatabaseWithSpecificParams(ctx context.Context,
 			Database: body.Database,
 			Orcid:    body.Orcid,
 			Query:    body.Query,
+			Syntax:   body.Syntax,
 			Status:   body.Status,
 			Offset:   body.Offset,
 			Limit:    body.Limit,
+			Sort:     body.Sort,
+			Order:    body.Order,
+			Explain:  body.Explain,
+			Related:  body.Related,
 		},
 	}
 	return searchDatabase(ctx, &searchInput, body.Specific)
@@ -568,6 +945,7 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 		Ids           string `json:"ids" query:"ids" example:"JDP:6101cc0f2b1f2eeea564c978" doc:"A comma-separated list of file IDs"`
 		Offset        int    `json:"offset" query:"offset" example:"100" doc:"Metadata records begin at the given offset"`
 		Limit         int    `json:"limit" query:"limit" example:"50" doc:"Limits the number of metadata records returned"`
+		Related       bool   `json:"related,omitempty" query:"related" doc:"(Optional) If true, each record lists the IDs of related resources in other databases in its related_ids field"`
 	}) (*FileMetadataOutput, error) {
 
 	userOrClient, err := authorize(input.Authorization)
@@ -587,6 +965,27 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 	}
 	ids := strings.Split(input.Ids, ",")
 
+	// select the requested page of IDs
+	if input.Offset < 0 || input.Limit < 0 {
+		return nil, huma.Error400BadRequest("Offset and limit must be non-negative!")
+	}
+	total := len(ids)
+	start := min(input.Offset, total)
+	end := total
+	if input.Limit > 0 {
+		end = min(start+input.Limit, total)
+	}
+	ids = ids[start:end]
+	if len(ids) == 0 { // nothing left to fetch
+		return &FileMetadataOutput{
+			Body: FileMetadataResponse{
+				Database:    input.Database,
+				Total:       total,
+				Descriptors: make([]map[string]any, 0),
+			},
+		}, nil
+	}
+
 	slog.Info(fmt.Sprintf("Fetching file metadata for %d files in database %s...",
 		len(ids), input.Database))
 	db, err := databases.NewDatabase(input.Database)
@@ -595,21 +994,16 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 	}
 
 	// FIXME: for now, if a user ORCID is not specified, use the client's ORCID
-	orcid := input.Orcid
-	if orcid == "" {
-		switch u := userOrClient.(type) {
-		case auth.User:
-			orcid = u.Orcid
-		case auth.Client:
-			orcid = u.Orcid
-		}
-	}
+	orcid := requestingOrcid(userOrClient, input.Orcid)
 
 	descriptors, err := db.Descriptors(orcid, ids)
 	if err != nil {
 		slog.Error(err.Error())
 		return nil, err
 	}
+	if input.Related {
+		databases.LinkRelatedIds(input.Database, orcid, descriptors)
+	}
 
 	// validate the descriptors and send them along
 	for _, descriptor := range descriptors {
@@ -622,192 +1016,2224 @@ func (service *prototype) fetchFileMetadata(ctx context.Context,
 	return &FileMetadataOutput{
 		Body: FileMetadataResponse{
 			Database:    input.Database,
+			Total:       total,
 			Descriptors: descriptors,
 		},
 	}, nil
 }
 
-type TransferOutput struct {
-	Body   TransferResponse `doc:"A UUID for the requested transfer"`
-	Status int
-}
+// the default and maximum sizes of file previews (bytes)
+const defaultPreviewBytes = 16 * 1024
+const maxPreviewBytes = 64 * 1024
 
-// returns a string slice containing file IDs in the TransferRequest that have duplicates, or nil
-// if no duplicates are found
-func DuplicateFileIds(transferRequest TransferRequest) []string {
-	fileIdsEncountered := make(map[string]struct{})
-	var duplicates []string
-	for _, fileId := range transferRequest.FileIds {
-		if _, found := fileIdsEncountered[fileId]; found {
-			duplicates = append(duplicates, fileId)
-		} else {
-			fileIdsEncountered[fileId] = struct{}{}
-		}
-	}
-	return duplicates
+type FilePreviewOutput struct {
+	ContentType string `header:"Content-Type"`
+	Truncated   string `header:"X-DTS-Preview-Truncated" doc:"true if the file is longer than its preview, false if not"`
+	Body        []byte
 }
 
-// handler method for initiating a file transfer operation
-func (service *prototype) createTransfer(ctx context.Context,
+// fetches the beginning of a text file so a user can confirm its content
+// before transferring it
+func (service *prototype) previewFile(ctx context.Context,
 	input *struct {
-		Authorization string          `header:"Authorization" doc:"Authorization header with encoded access token"`
-		Body          TransferRequest `doc:"The body of a POST request for a file transfer"`
-		ContentType   string          `header:"Content-Type" doc:"Content-Type header (must be application/json)"`
-	}) (*TransferOutput, error) {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Database      string `query:"database" example:"nmdc" doc:"The ID of the database containing the file"`
+		Orcid         string `query:"orcid" example:"1234-5678-9101-112X" doc:"(Optional) The ORCID of the user requesting the preview (defaults to that of the authorized user)"`
+		Id            string `query:"id" example:"nmdc:dobj-11-3e4hz961" doc:"The ID of the file to preview"`
+		Bytes         int    `query:"bytes" example:"4096" minimum:"0" maximum:"65536" doc:"(Optional) The maximum number of bytes in the preview (default: 16384)"`
+	}) (*FilePreviewOutput, error) {
 
 	userOrClient, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
 
-	// fetch information about the requesting user
-	user, isUser := userOrClient.(auth.User)
-	if !isUser {
-		client := userOrClient.(auth.Client)
-		user = auth.User{
-			Name:         client.Name,
-			Email:        client.Email,
-			Orcid:        client.Orcid,
-			Organization: client.Organization,
-		}
+	if !databases.HaveDatabase(input.Database) {
+		return nil, huma.Error404NotFound(fmt.Sprintf("Database %s not found", input.Database))
+	}
+	if strings.TrimSpace(input.Id) == "" {
+		return nil, huma.Error400BadRequest("No file ID was provided!")
+	}
+	numBytes := input.Bytes
+	if numBytes == 0 {
+		numBytes = defaultPreviewBytes
+	}
+	if numBytes < 0 || numBytes > maxPreviewBytes {
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Previews must be between 1 and %d bytes long",
+			maxPreviewBytes))
 	}
 
-	if input.Body.Orcid == "" {
-		return nil, huma.Error401Unauthorized("No user ORCID was provided")
+	db, err := databases.NewDatabase(input.Database)
+	if err != nil {
+		return nil, databaseError(err)
 	}
-	if !isUser {
-		// override the client's ORCID
-		user.Orcid = input.Body.Orcid
-	} else {
-		// a user is requesting a transfer on behalf of another user
-		// FIXME: for now, we only extract the ORCID and keep everything else the same, but at length
-		// FIXME: we should fill in the other fields with the ORCID public record
-		user.Orcid = input.Body.Orcid
+	descriptors, err := db.Descriptors(requestingOrcid(userOrClient, input.Orcid), []string{input.Id})
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	if len(descriptors) == 0 || descriptors[0] == nil {
+		return nil, huma.Error404NotFound(fmt.Sprintf("File %s not found in database %s",
+			input.Id, input.Database))
+	}
+	descriptor := descriptors[0]
+	if !previewable(descriptor) {
+		return nil, huma.Error415UnsupportedMediaType(fmt.Sprintf("File %s is not a text file (%s)",
+			input.Id, frictionless.String(descriptor, "mediatype")))
 	}
 
-	// inspect the list of files, making sure there are no duplicates
-	duplicates := DuplicateFileIds(input.Body)
-	if duplicates != nil {
-		return nil, huma.Error400BadRequest(fmt.Sprintf("The following requested file IDs have duplicates, which are forbidden: %s",
-			strings.Join(duplicates, ", ")))
+	slog.Info(fmt.Sprintf("Previewing file %s in database %s...", input.Id, input.Database))
+	preview, truncated, err := fetchPreview(descriptor, numBytes)
+	if err != nil {
+		return nil, huma.Error502BadGateway(fmt.Sprintf("Couldn't fetch preview of file %s: %s",
+			input.Id, err.Error()))
+	}
+	if preview == nil {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("File %s is not accessible over HTTPS",
+			input.Id))
+	}
+	if bytes.IndexByte(preview, 0) != -1 {
+		return nil, huma.Error415UnsupportedMediaType(fmt.Sprintf("File %s contains binary data", input.Id))
 	}
+	return &FilePreviewOutput{
+		ContentType: "text/plain; charset=utf-8", // never rendered as HTML by browsers
+		Truncated:   strconv.FormatBool(truncated),
+		Body:        preview,
+	}, nil
+}
 
-	// validate the destination
-	if !databases.HaveDatabase(input.Body.Destination) {
-		// is this a "custom transfer", available only to Special People?
-		if strings.Contains(input.Body.Destination, ":") {
-			_, err := endpoints.ParseCustomSpec(input.Body.Destination)
-			if err != nil {
-				return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid destination: %s", input.Body.Destination))
-			}
-			if !user.IsSuper { // not allowed to do custom transfers
-				return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid destination: %s", input.Body.Destination))
-			}
-		} else { // nope, we just didn't find it
-			return nil, huma.Error404NotFound(fmt.Sprintf("Destination database not found: %s", input.Body.Destination))
+// returns true if the file with the given descriptor holds uncompressed text
+func previewable(descriptor map[string]any) bool {
+	mediatype := frictionless.String(descriptor, "mediatype")
+	if !strings.HasPrefix(mediatype, "text/") && mediatype != "application/json" &&
+		mediatype != "application/xml" {
+		return false
+	}
+	for _, name := range []string{frictionless.String(descriptor, "name"), frictionless.String(descriptor, "path")} {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".gz", ".bz", ".bz2", ".zip", ".zst", ".xz":
+			return false
 		}
 	}
+	return true
+}
 
-	taskId, err := tasks.Create(tasks.Specification{
-		User:         user,
-		Source:       input.Body.Source,
-		Destination:  input.Body.Destination,
-		FileIds:      input.Body.FileIds,
-		Description:  input.Body.Description,
-		Instructions: input.Body.Instructions,
-	})
-	if err != nil {
-		slog.Error(err.Error())
-		switch err.(type) {
-		case *tasks.NoFilesRequestedError:
-			return nil, huma.Error400BadRequest(err.Error())
-		case *databases.NotFoundError:
-			return nil, huma.Error404NotFound(err.Error())
-		default:
-			return nil, huma.Error500InternalServerError(err.Error())
+// Fetches up to the given number of bytes from the beginning of the file with
+// the given descriptor, from its source endpoint if that serves files directly
+// or otherwise over HTTPS from the URL in its credit metadata (if that serves
+// the file itself rather than a landing page). Returns the fetched bytes (nil
+// if the file isn't accessible) and true if the file is longer.
+func fetchPreview(descriptor map[string]any, numBytes int) ([]byte, bool, error) {
+	if endpointName := frictionless.String(descriptor, "endpoint"); endpointName != "" {
+		endpoint, err := endpoints.NewEndpoint(endpointName)
+		if err != nil {
+			return nil, false, err
+		}
+		if downloader, ok := endpoint.(endpoints.DownloadingEndpoint); ok && downloader.CanDownload() {
+			return downloadPreview(downloader, descriptor, numBytes)
 		}
 	}
-	return &TransferOutput{
-		Body: TransferResponse{
-			Id: taskId,
-		},
-		Status: http.StatusCreated,
-	}, nil
+
+	var fileUrl string
+	switch c := descriptor["credit"].(type) {
+	case credit.CreditMetadata:
+		fileUrl = c.Url
+	case map[string]any:
+		fileUrl = frictionless.String(c, "url")
+	}
+	if strings.HasPrefix(fileUrl, "https://") {
+		req, err := http.NewRequest(http.MethodGet, fileUrl, http.NoBody)
+		if err != nil {
+			return nil, false, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", numBytes)) // one extra byte
+		client := databases.SecureHttpClient(30 * time.Second)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			return nil, false, fmt.Errorf("%s responded with status %d", fileUrl, resp.StatusCode)
+		}
+		if isLandingPage(resp, descriptor) {
+			return nil, false, nil
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, int64(numBytes)+1))
+		if err != nil {
+			return nil, false, err
+		}
+		return previewOf(data, numBytes)
+	}
+	return nil, false, nil
 }
 
-// convert a transfer status code to a nice human-friendly string
-func statusAsString(statusCode endpoints.TransferStatusCode) string {
-	switch statusCode {
-	case endpoints.TransferStatusStaging:
-		return "staging"
-	case endpoints.TransferStatusActive:
-		return "active"
-	case endpoints.TransferStatusInactive:
-		return "inactive"
-	case endpoints.TransferStatusFinalizing:
-		return "finalizing"
-	case endpoints.TransferStatusSucceeded:
-		return "succeeded"
-	case endpoints.TransferStatusFailed:
-		return "failed"
+// downloads a preview of up to the given number of bytes of the file with the
+// given descriptor from the given endpoint
+func downloadPreview(downloader endpoints.DownloadingEndpoint, descriptor map[string]any,
+	numBytes int) ([]byte, bool, error) {
+	// the buffer stops the download once it holds one byte beyond the preview,
+	// so the endpoint need only allow files of the size recorded for this one
+	// (or of any size if none is recorded, leaving room for the extra byte
+	// endpoints read to detect files that are too large)
+	maxBytes := int64(math.MaxInt64 - 1)
+	if size, found := frictionless.Int(descriptor, "bytes"); found {
+		maxBytes = int64(max(size, numBytes))
 	}
-	return "unknown"
+	buffer := previewBuffer{Limit: numBytes + 1}
+	err := downloader.Download(frictionless.String(descriptor, "path"), &buffer, maxBytes)
+	if err != nil && err != errPreviewFull {
+		return nil, false, err
+	}
+	return previewOf(buffer.Data, numBytes)
 }
 
-type TransferStatusOutput struct {
-	Body TransferStatusResponse `doc:"A status message for the transfer task with the given ID"`
+// returns true if the given response holds an HTML page (e.g. a landing page
+// for a dataset) rather than the file with the given descriptor
+func isLandingPage(resp *http.Response, descriptor map[string]any) bool {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	return strings.HasPrefix(contentType, "text/html") &&
+		frictionless.String(descriptor, "mediatype") != "text/html"
 }
 
-// handler method for getting the status of a transfer
-func (service *prototype) getTransferStatus(ctx context.Context,
-	input *struct {
-		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+// returns the preview for the given data, fetched with one byte beyond the
+// given preview size to determine whether the file is longer
+func previewOf(data []byte, numBytes int) ([]byte, bool, error) {
+	if data == nil {
+		data = []byte{}
+	}
+	if len(data) > numBytes {
+		return data[:numBytes], true, nil
+	}
+	return data, false, nil
+}
+
+var errPreviewFull = errors.New("preview is full")
+
+// a writer that accumulates data up to a limit, then refuses more
+type previewBuffer struct {
+	Limit int
+	Data  []byte
+}
+
+func (b *previewBuffer) Write(p []byte) (int, error) {
+	n := min(len(p), b.Limit-len(b.Data))
+	b.Data = append(b.Data, p[:n]...)
+	if n < len(p) {
+		return n, errPreviewFull
+	}
+	return n, nil
+}
+
+type FileDiffOutput struct {
+	Body FileDiffResponse `doc:"Comparisons of previously fetched and current file metadata"`
+}
+
+// compares previously fetched file metadata with current metadata for the
+// same files, so clients mirroring files can detect upstream changes
+func (service *prototype) diffFileMetadata(ctx context.Context,
+	input *struct {
+		Authorization string          `header:"authorization" doc:"Authorization header with encoded access token"`
+		Body          FileDiffRequest `doc:"The body of a POST request comparing file metadata"`
+	}) (*FileDiffOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	if !databases.HaveDatabase(input.Body.Database) {
+		return nil, databaseError(&databases.NotFoundError{Database: input.Body.Database})
+	}
+	ids := make([]string, len(input.Body.Descriptors))
+	for i, descriptor := range input.Body.Descriptors {
+		id, ok := descriptor["id"].(string)
+		if !ok || id == "" {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Resource %d has no ID", i))
+		}
+		ids[i] = id
+	}
+	if len(ids) == 0 {
+		return nil, huma.Error400BadRequest("No resources were provided!")
+	}
+
+	db, err := databases.NewDatabase(input.Body.Database)
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	orcid := requestingOrcid(userOrClient, input.Body.Orcid)
+
+	// fetch current descriptors, omitting any resources that no longer exist
+	descriptors, err := db.Descriptors(orcid, ids)
+	var missingIds []string
+	switch e := err.(type) {
+	case databases.ResourcesNotFoundError:
+		missingIds = e.ResourceIds
+	case *databases.ResourcesNotFoundError:
+		missingIds = e.ResourceIds
+	}
+	if missingIds != nil {
+		ids = slices.DeleteFunc(ids, func(id string) bool {
+			return slices.Contains(missingIds, id)
+		})
+		descriptors, err = nil, nil
+		if len(ids) > 0 {
+			descriptors, err = db.Descriptors(orcid, ids)
+		}
+	}
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	return &FileDiffOutput{
+		Body: FileDiffResponse{
+			Database: input.Body.Database,
+			Diffs:    databases.DiffDescriptors(input.Body.Descriptors, descriptors),
+		},
+	}, nil
+}
+
+type FileEstimateOutput struct {
+	Body FileEstimateResponse `doc:"An estimate of the cost of transferring files with the given IDs"`
+}
+
+// estimates the size of a transfer of files with the given identifiers and,
+// for databases with tape tiers, the cost of recalling them from tape
+func (service *prototype) estimateFiles(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Database      string `json:"database" query:"database" example:"jdp" doc:"The ID of the database containing the files"`
+		Orcid         string `json:"orcid" query:"orcid" example:"1234-5678-9101-112X" doc:"The ORCID of the user requesting the estimate"`
+		Ids           string `json:"ids" query:"ids" example:"JDP:6101cc0f2b1f2eeea564c978" doc:"A comma-separated list of file IDs"`
+	}) (*FileEstimateOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(input.Ids) == "" {
+		return nil, huma.Error400BadRequest("No file IDs were provided!")
+	}
+	ids := strings.Split(input.Ids, ",")
+
+	db, err := databases.NewDatabase(input.Database)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+
+	orcid := requestingOrcid(userOrClient, input.Orcid)
+
+	descriptors, err := db.Descriptors(orcid, ids)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	var size uint64
+	for _, descriptor := range descriptors {
+		if numBytes, ok := frictionless.Int(descriptor, "bytes"); ok {
+			size += uint64(numBytes)
+		}
+	}
+	estimate := FileEstimateResponse{
+		Database:     input.Database,
+		NumFiles:     len(descriptors),
+		PayloadSize:  float64(size) / units.BytesPerGigabyte,
+		PayloadBytes: int64(size),
+	}
+
+	if estimator, ok := db.(databases.TapeRecallEstimator); ok {
+		recall, err := estimator.EstimateTapeRecall(orcid, ids)
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, err
+		}
+		estimate.NumFilesToRecall = recall.NumFiles
+		estimate.RecallTime = recall.Duration.Seconds()
+	}
+
+	return &FileEstimateOutput{
+		Body: estimate,
+	}, nil
+}
+
+type StagingOutput struct {
+	Body   StagingResponse `doc:"A UUID identifying the staging request"`
+	Status int
+}
+
+// handler method for staging files ahead of a transfer
+func (service *prototype) stageFiles(ctx context.Context,
+	input *struct {
+		Authorization string         `header:"Authorization" doc:"Authorization header with encoded access token"`
+		Body          StagingRequest `doc:"The body of a POST request for staging files"`
+		ContentType   string         `header:"Content-Type" doc:"Content-Type header (must be application/json)"`
+	}) (*StagingOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.Body.FileIds) == 0 {
+		return nil, huma.Error400BadRequest("No file IDs were provided!")
+	}
+
+	db, err := databases.NewDatabase(input.Body.Database)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+
+	orcid := requestingOrcid(userOrClient, input.Body.Orcid)
+
+	// estimate any tape recalls before staging begins (failing to do so
+	// doesn't prevent staging)
+	var recall databases.TapeRecallEstimate
+	if estimator, ok := db.(databases.TapeRecallEstimator); ok {
+		recall, err = estimator.EstimateTapeRecall(orcid, input.Body.FileIds)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Couldn't estimate tape recalls for staging: %s", err.Error()))
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Staging %d files in database %s...", len(input.Body.FileIds),
+		input.Body.Database))
+	id, err := db.StageFiles(orcid, input.Body.FileIds)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	// record the request, purging any that are old enough to be forgotten
+	deleteAfter := time.Duration(config.Service.DeleteAfter) * time.Second
+	service.StagingMutex.Lock()
+	for requestId, request := range service.StagingRequests {
+		if time.Since(request.Time) > deleteAfter {
+			delete(service.StagingRequests, requestId)
+		}
+	}
+	service.StagingRequests[id] = stagingRequest{
+		Database:   input.Body.Database,
+		Orcid:      orcid,
+		NumFiles:   len(input.Body.FileIds),
+		TapeRecall: recall,
+		Time:       time.Now(),
+	}
+	service.StagingMutex.Unlock()
+
+	return &StagingOutput{
+		Body: StagingResponse{
+			Id: id,
+		},
+		Status: http.StatusAccepted,
+	}, nil
+}
+
+// convert a staging status to a nice human-friendly string
+func stagingStatusAsString(status databases.StagingStatus) string {
+	switch status {
+	case databases.StagingStatusActive:
+		return "active"
+	case databases.StagingStatusSucceeded:
+		return "succeeded"
+	case databases.StagingStatusFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+type StagingStatusOutput struct {
+	Body StagingStatusResponse `doc:"A status message for the staging request with the given ID"`
+}
+
+// handler method for getting the status of a staging request
+func (service *prototype) getStagingStatus(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the staging request"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user for whom files are staged (defaults to that of the authorized user)"`
+	}) (*StagingStatusOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	service.StagingMutex.Lock()
+	request, found := service.StagingRequests[input.Id]
+	service.StagingMutex.Unlock()
+	if found && request.Orcid != requestingOrcid(userOrClient, input.Orcid) {
+		found = false // don't reveal others' staging requests
+	}
+	if !found {
+		return nil, huma.Error404NotFound(fmt.Sprintf("Staging request %s not found", input.Id.String()))
+	}
+
+	db, err := databases.NewDatabase(request.Database)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	status, err := db.StagingStatus(input.Id)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	response := StagingStatusResponse{
+		Id:               input.Id.String(),
+		Database:         request.Database,
+		Status:           stagingStatusAsString(status),
+		NumFiles:         request.NumFiles,
+		NumFilesToRecall: request.TapeRecall.NumFiles,
+		RecallTime:       request.TapeRecall.Duration.Seconds(),
+	}
+	if queue, ok := db.(databases.QueueingDatabase); ok {
+		response.QueuePosition = queue.StagingQueuePosition(input.Id)
+	}
+	return &StagingStatusOutput{
+		Body: response,
+	}, nil
+}
+
+type TransferOutput struct {
+	Body   TransferResponse `doc:"A UUID for the requested transfer"`
+	Status int
+}
+
+// returns a string slice containing file IDs in the TransferRequest that have duplicates, or nil
+// if no duplicates are found
+func DuplicateFileIds(transferRequest TransferRequest) []string {
+	fileIdsEncountered := make(map[string]struct{})
+	var duplicates []string
+	for _, fileId := range transferRequest.FileIds {
+		if _, found := fileIdsEncountered[fileId]; found {
+			duplicates = append(duplicates, fileId)
+		} else {
+			fileIdsEncountered[fileId] = struct{}{}
+		}
+	}
+	return duplicates
+}
+
+// the maximum length of a user-defined transfer tag
+const maxTagLength = 64
+
+// checks the given user-defined transfer tags, returning an error describing
+// the first invalid tag encountered, or nil if all tags are valid
+func validateTags(tags []string) error {
+	tagsEncountered := make(map[string]struct{})
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return huma.Error400BadRequest("Transfer tags must not be empty")
+		}
+		if len(tag) > maxTagLength {
+			return huma.Error400BadRequest(fmt.Sprintf("Transfer tag '%s' exceeds %d characters", tag, maxTagLength))
+		}
+		if strings.Contains(tag, ",") {
+			return huma.Error400BadRequest(fmt.Sprintf("Transfer tag '%s' must not contain commas", tag))
+		}
+		if _, found := tagsEncountered[tag]; found {
+			return huma.Error400BadRequest(fmt.Sprintf("Transfer tag '%s' is duplicated", tag))
+		}
+		tagsEncountered[tag] = struct{}{}
+	}
+	return nil
+}
+
+// the maximum length of the name of an allocation or project
+const maxAllocationLength = 64
+
+// checks the name of the allocation or project to which a transfer is
+// attributed, returning an error if it's invalid
+func validateAllocation(allocation string) error {
+	if len(allocation) > maxAllocationLength {
+		return huma.Error400BadRequest(fmt.Sprintf("Transfer allocation '%s' exceeds %d characters",
+			allocation, maxAllocationLength))
+	}
+	if strings.ContainsFunc(allocation, unicode.IsSpace) {
+		return huma.Error400BadRequest(fmt.Sprintf("Transfer allocation '%s' must not contain whitespace",
+			allocation))
+	}
+	return nil
+}
+
+// handler method for initiating a file transfer operation
+func (service *prototype) createTransfer(ctx context.Context,
+	input *struct {
+		Authorization string          `header:"Authorization" doc:"Authorization header with encoded access token"`
+		Body          TransferRequest `doc:"The body of a POST request for a file transfer"`
+		ContentType   string          `header:"Content-Type" doc:"Content-Type header (must be application/json)"`
+	}) (*TransferOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch information about the requesting user
+	var clientUsername string
+	user, isUser := userOrClient.(auth.User)
+	if !isUser {
+		client := userOrClient.(auth.Client)
+		clientUsername = client.Username
+		user = auth.User{
+			Name:         client.Name,
+			Email:        client.Email,
+			Orcid:        client.Orcid,
+			Organization: client.Organization,
+		}
+	}
+
+	if input.Body.Orcid == "" {
+		return nil, huma.Error401Unauthorized("No user ORCID was provided")
+	}
+	if !isUser {
+		// override the client's ORCID
+		user.Orcid = input.Body.Orcid
+	} else {
+		// a user is requesting a transfer on behalf of another user
+		// FIXME: for now, we only extract the ORCID and keep everything else the same, but at length
+		// FIXME: we should fill in the other fields with the ORCID public record
+		user.Orcid = input.Body.Orcid
+	}
+
+	var batch uuid.UUID
+	if input.Body.Batch != "" {
+		batch, err = uuid.Parse(input.Body.Batch)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid batch ID: %s", input.Body.Batch))
+		}
+	}
+
+	dependsOn := make([]uuid.UUID, len(input.Body.DependsOn))
+	for i, dependency := range input.Body.DependsOn {
+		dependsOn[i], err = uuid.Parse(dependency)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid dependency ID: %s", dependency))
+		}
+	}
+
+	// add the files in any requested collection
+	if input.Body.Collection != "" {
+		collectionId, err := uuid.Parse(input.Body.Collection)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid collection ID: %s", input.Body.Collection))
+		}
+		collection, err := collections.Fetch(collectionId, user.Orcid)
+		if err != nil {
+			return nil, collectionError(err)
+		}
+		if collection.Database != input.Body.Source {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Collection %s contains files from %s, not %s",
+				input.Body.Collection, collection.Database, input.Body.Source))
+		}
+		input.Body.FileIds = append(slices.Clone(collection.FileIds), input.Body.FileIds...)
+	}
+
+	// inspect the list of files, making sure there are no duplicates
+	duplicates := DuplicateFileIds(input.Body)
+	if duplicates != nil {
+		return nil, huma.Error400BadRequest(fmt.Sprintf("The following requested file IDs have duplicates, which are forbidden: %s",
+			strings.Join(duplicates, ", ")))
+	}
+
+	// validate any tags and allocation
+	if err := validateTags(input.Body.Tags); err != nil {
+		return nil, err
+	}
+	if err := validateAllocation(input.Body.Allocation); err != nil {
+		return nil, err
+	}
+
+	// validate any callback URL
+	if input.Body.CallbackURL != "" {
+		if config.Service.CallbackSecret == "" {
+			return nil, huma.Error400BadRequest("Transfer callbacks are not enabled")
+		}
+		if err := webhooks.ValidateURL(input.Body.CallbackURL); err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+	}
+
+	// validate the destination
+	if !databases.HaveDatabase(input.Body.Destination) {
+		// is this a "custom transfer", available only to Special People?
+		if strings.Contains(input.Body.Destination, ":") {
+			_, err := endpoints.ParseCustomSpec(input.Body.Destination)
+			if err != nil {
+				return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid destination: %s", input.Body.Destination))
+			}
+			if !user.IsSuper { // not allowed to do custom transfers
+				return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid destination: %s", input.Body.Destination))
+			}
+		} else { // nope, we just didn't find it
+			return nil, huma.Error404NotFound(fmt.Sprintf("Destination database not found: %s", input.Body.Destination))
+		}
+	}
+
+	// the user must accept the source's usage terms (if any) the first time
+	if err := checkTermsAcceptance(user.Orcid, input.Body.Source, input.Body.AcceptedTerms); err != nil {
+		return nil, err
+	}
+
+	taskId, err := tasks.Create(tasks.Specification{
+		User:                user,
+		Client:              clientUsername,
+		Source:              input.Body.Source,
+		Destination:         input.Body.Destination,
+		FileIds:             input.Body.FileIds,
+		Exclude:             input.Body.Exclude,
+		Description:         input.Body.Description,
+		DescriptionLanguage: input.Body.DescriptionLanguage,
+		Translations:        descriptionTranslations(input.Body.DescriptionTranslations),
+		Instructions:        input.Body.Instructions,
+		Tags:                input.Body.Tags,
+		Batch:               batch,
+		DependsOn:           dependsOn,
+		Allocation:          input.Body.Allocation,
+		CallbackURL:         input.Body.CallbackURL,
+		SkipChecksums:       input.Body.SkipChecksums,
+		SkipSourceErrors:    input.Body.SkipSourceErrors,
+		MetadataOnly:        input.Body.MetadataOnly,
+		WaitForEmbargo:      input.Body.WaitForEmbargo,
+		Deadline:            input.Body.Deadline,
+		Trace:               tracing.SpanContextFromContext(ctx),
+	})
+	if err != nil {
+		slog.Error(err.Error())
+		switch err.(type) {
+		case *tasks.NoFilesRequestedError, *tasks.InvalidFilterRulesError, *tasks.InvalidSourceEndpointError,
+			*tasks.EncryptionRequiredError, *tasks.InvalidDependencyError,
+			*tasks.InvalidExclusionError, *tasks.InvalidDeadlineError, *tasks.InvalidLanguageError,
+			*tasks.EmbargoedFilesError, *databases.InvalidInstructionsError:
+			return nil, huma.Error400BadRequest(err.Error())
+		case *databases.NotFoundError, *databases.ResourcesNotFoundError:
+			return nil, huma.Error404NotFound(err.Error())
+		case *tasks.InsufficientDiskSpaceError:
+			return nil, huma.Error503ServiceUnavailable(err.Error())
+		case *tasks.QuotaExceededError:
+			return nil, huma.Error429TooManyRequests(err.Error())
+		case *tasks.EgressPolicyViolationError:
+			auditErr := audit.Record(audit.Event{
+				Orcid:   user.Orcid,
+				Action:  "create_transfer",
+				Outcome: audit.OutcomeDenied,
+				Policy:  err.(*tasks.EgressPolicyViolationError).Policy,
+				Message: err.Error(),
+			})
+			if auditErr != nil {
+				slog.Error(auditErr.Error())
+			}
+			return nil, huma.Error403Forbidden(err.Error())
+		default:
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+	}
+	return &TransferOutput{
+		Body: TransferResponse{
+			Id: taskId,
+		},
+		Status: http.StatusCreated,
+	}, nil
+}
+
+// converts the given description translations from a transfer request to
+// their task counterparts
+func descriptionTranslations(translations []DescriptionTranslation) []tasks.Translation {
+	var converted []tasks.Translation
+	for _, translation := range translations {
+		converted = append(converted, tasks.Translation{
+			Language: translation.Language,
+			Text:     translation.Description,
+		})
+	}
+	return converted
+}
+
+// checks that the user with the given ORCID has accepted the usage terms of
+// the given source database (if it has any), recording their acceptance if
+// they're accepting them now
+func checkTermsAcceptance(orcid, source string, acceptedTerms bool) error {
+	terms := config.Databases[source].Terms
+	if terms == "" {
+		return nil
+	}
+	accepted, err := audit.HasAcceptedTerms(orcid, source, terms)
+	if err != nil {
+		slog.Error(err.Error())
+		return huma.Error500InternalServerError("Couldn't verify acceptance of usage terms")
+	}
+	if accepted {
+		return nil
+	}
+	if !acceptedTerms {
+		return huma.Error403Forbidden(fmt.Sprintf("Transfers from %s require acceptance of its usage terms (%s): "+
+			"please review them and resubmit the request with accepted_terms set", source, terms))
+	}
+	if err := audit.RecordTermsAcceptance(orcid, source, terms); err != nil {
+		slog.Error(err.Error())
+		return huma.Error500InternalServerError("Couldn't record acceptance of usage terms")
+	}
+	slog.Info(fmt.Sprintf("User %s accepted the usage terms of %s", orcid, source))
+	return nil
+}
+
+// convert a transfer status code to a nice human-friendly string
+func statusAsString(statusCode endpoints.TransferStatusCode) string {
+	switch statusCode {
+	case endpoints.TransferStatusStaging:
+		return "staging"
+	case endpoints.TransferStatusActive:
+		return "active"
+	case endpoints.TransferStatusInactive:
+		return "inactive"
+	case endpoints.TransferStatusFinalizing:
+		return "finalizing"
+	case endpoints.TransferStatusSucceeded:
+		return "succeeded"
+	case endpoints.TransferStatusFailed:
+		return "failed"
+	case endpoints.TransferStatusWaiting:
+		return "waiting"
+	}
+	return "unknown"
+}
+
+type TransferStatusOutput struct {
+	Body TransferStatusResponse `doc:"A status message for the transfer task with the given ID"`
+}
+
+// handler method for getting the status of a transfer
+func (service *prototype) getTransferStatus(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
 		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
 	}) (*TransferStatusOutput, error) {
 
-	_, err := authorize(input.Authorization)
+	_, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the status for the job using the appropriate task data
+	summary, err := tasks.Summarize(input.Id)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	return &TransferStatusOutput{
+		Body: transferStatusResponse(summary),
+	}, nil
+}
+
+// returns the total number of bytes to be moved by the summarized transfer,
+// falling back to its payload size before its endpoints have reported one
+func transferBytesTotal(summary tasks.Summary) int64 {
+	if summary.Status.NumBytes > 0 {
+		return summary.Status.NumBytes
+	}
+	return units.GigabytesToBytes(summary.PayloadSize)
+}
+
+// creates a transfer status response from a task summary
+func transferStatusResponse(summary tasks.Summary) TransferStatusResponse {
+	response := TransferStatusResponse{
+		Id:                  summary.Id.String(),
+		Status:              statusAsString(summary.Status.Code),
+		Message:             summary.Status.Message,
+		NumFiles:            summary.Status.NumFiles,
+		NumFilesTransferred: summary.Status.NumFilesTransferred,
+		Note:                summary.Note,
+		Tags:                summary.Tags,
+		Allocation:          summary.Allocation,
+		PayloadBytes:        units.GigabytesToBytes(summary.PayloadSize),
+		BytesTransferred:    summary.Status.NumBytesTransferred,
+		BytesTotal:          transferBytesTotal(summary),
+		MBPerSecond:         units.BytesToMegabytes(summary.Status.BytesPerSecond),
+		Warnings:            summary.Warnings,
+		CreatedAt:           summary.StartTime,
+		Expired:             summary.Expired,
+	}
+	if summary.Batch != uuid.Nil {
+		response.Batch = summary.Batch.String()
+	}
+	for _, dependency := range summary.DependsOn {
+		response.DependsOn = append(response.DependsOn, dependency.String())
+	}
+	if !summary.ProcessingTime.IsZero() {
+		response.StartedAt = &summary.ProcessingTime
+	}
+	if !summary.CompletionTime.IsZero() {
+		response.CompletedAt = &summary.CompletionTime
+	}
+	if !summary.Deadline.IsZero() {
+		response.Deadline = &summary.Deadline
+	}
+	if !summary.EstimatedEnd.IsZero() {
+		response.ETA = &summary.EstimatedEnd
+	}
+	return response
+}
+
+// the maximum length of a free-text transfer note
+const maxNoteLength = 4096
+
+// handler method for updating the note and/or tags of a transfer
+func (service *prototype) annotateTransfer(ctx context.Context,
+	input *struct {
+		Authorization string               `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID            `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer to be updated"`
+		Body          TransferPatchRequest `doc:"The body of a PATCH request for a file transfer"`
+	}) (*TransferStatusOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the owner of a transfer (or a client acting for them) may annotate it
+	orcid := requestingOrcid(userOrClient, input.Body.Orcid)
+
+	if input.Body.Note != nil && len(*input.Body.Note) > maxNoteLength {
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Transfer note exceeds %d characters", maxNoteLength))
+	}
+	if err := validateTags(input.Body.Tags); err != nil {
+		return nil, err
+	}
+
+	err = tasks.Annotate(input.Id, orcid, tasks.Annotation{
+		Note: input.Body.Note,
+		Tags: input.Body.Tags,
+	})
+	if err != nil {
+		switch err.(type) {
+		case *tasks.NotFoundError:
+			return nil, huma.Error404NotFound(err.Error())
+		case *tasks.NotOwnerError:
+			return nil, huma.Error403Forbidden(err.Error())
+		default:
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+	}
+
+	summary, err := tasks.Summarize(input.Id)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	return &TransferStatusOutput{
+		Body: transferStatusResponse(summary),
+	}, nil
+}
+
+type TransferListOutput struct {
+	Body TransferListResponse `doc:"Status messages for transfer tasks matching the request"`
+}
+
+// handler method for listing transfers, optionally filtered by tags
+func (service *prototype) listTransfers(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Tags          string `query:"tags" example:"fy25-soil-campaign" doc:"(Optional) A comma-separated list of tags borne by all listed transfers"`
+	}) (*TransferListOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	if input.Tags != "" {
+		tags = strings.Split(input.Tags, ",")
+	}
+	summaries, err := tasks.List(tags)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	summaries = visibleSummaries(userOrClient, summaries)
+	transfers := make([]TransferStatusResponse, len(summaries))
+	for i, summary := range summaries {
+		transfers[i] = transferStatusResponse(summary)
+	}
+	return &TransferListOutput{
+		Body: TransferListResponse{
+			Transfers: transfers,
+		},
+	}, nil
+}
+
+// returns the given task summaries that the given authorized user or client
+// may see: those of its own transfers, or all of them for a superuser
+func visibleSummaries(userOrClient any, summaries []tasks.Summary) []tasks.Summary {
+	if user, ok := userOrClient.(auth.User); ok && user.IsSuper {
+		return summaries
+	}
+	orcid := requestingOrcid(userOrClient, "")
+	return slices.DeleteFunc(summaries, func(summary tasks.Summary) bool {
+		return summary.Orcid != orcid
+	})
+}
+
+type BatchOutput struct {
+	Body BatchResponse `doc:"The aggregate status of the batch of transfers with the given ID"`
+}
+
+// handler method for getting the aggregate status of a batch of transfers,
+// which includes only the requester's own transfers (unless they're a
+// superuser)
+func (service *prototype) getBatchStatus(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"5c3e9a4e-2b1f-4f0e-9d8a-7a6b5c4d3e2f" doc:"the UUID for the requested batch"`
+	}) (*BatchOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := tasks.ListBatch(input.Id)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	summaries = visibleSummaries(userOrClient, summaries)
+	if len(summaries) == 0 { // (including batches of others' transfers)
+		return nil, huma.Error404NotFound(fmt.Sprintf("Batch %s not found", input.Id))
+	}
+	return &BatchOutput{
+		Body: batchResponse(input.Id, summaries),
+	}, nil
+}
+
+// creates a response aggregating the statuses of the transfers in a batch
+func batchResponse(batch uuid.UUID, summaries []tasks.Summary) BatchResponse {
+	response := BatchResponse{
+		Id:           batch.String(),
+		Status:       "succeeded",
+		NumTransfers: len(summaries),
+		StatusCounts: make(map[string]int),
+		Transfers:    make([]TransferStatusResponse, len(summaries)),
+	}
+	inProgress := false
+	for i, summary := range summaries {
+		transfer := transferStatusResponse(summary)
+		response.Transfers[i] = transfer
+		response.StatusCounts[transfer.Status]++
+		response.NumFiles += transfer.NumFiles
+		response.NumFilesTransferred += transfer.NumFilesTransferred
+		response.PayloadBytes += transfer.PayloadBytes
+		response.BytesTransferred += transfer.BytesTransferred
+		response.BytesTotal += transfer.BytesTotal
+		response.MBPerSecond += transfer.MBPerSecond
+		switch summary.Status.Code {
+		case tasks.TransferStatusSucceeded:
+		case tasks.TransferStatusFailed:
+			response.Status = "failed"
+		default:
+			inProgress = true
+		}
+	}
+	if inProgress {
+		response.Status = "active"
+	}
+	return response
+}
+
+type TransferStatusOutputV2 struct {
+	Body TransferStatusResponseV2 `doc:"A detailed status message for the transfer task with the given ID"`
+}
+
+// handler method for getting the detailed status of a transfer (API v2)
+func (service *prototype) getTransferStatusV2(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
+	}) (*TransferStatusOutputV2, error) {
+
+	_, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := tasks.Summarize(input.Id)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	return &TransferStatusOutputV2{
+		Body: transferStatusResponseV2(summary),
+	}, nil
+}
+
+type TransferListOutputV2 struct {
+	Body TransferListResponseV2 `doc:"Detailed status messages for transfer tasks matching the request"`
+}
+
+// handler method for listing transfers, optionally filtered by tags (API v2)
+func (service *prototype) listTransfersV2(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Tags          string `query:"tags" example:"fy25-soil-campaign" doc:"(Optional) A comma-separated list of tags borne by all listed transfers"`
+	}) (*TransferListOutputV2, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	if input.Tags != "" {
+		tags = strings.Split(input.Tags, ",")
+	}
+	summaries, err := tasks.List(tags)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	summaries = visibleSummaries(userOrClient, summaries)
+	transfers := make([]TransferStatusResponseV2, len(summaries))
+	for i, summary := range summaries {
+		transfers[i] = transferStatusResponseV2(summary)
+	}
+	return &TransferListOutputV2{
+		Body: TransferListResponseV2{
+			Transfers: transfers,
+		},
+	}, nil
+}
+
+// creates a detailed (API v2) transfer status response from a task summary
+func transferStatusResponseV2(summary tasks.Summary) TransferStatusResponseV2 {
+	response := TransferStatusResponseV2{
+		Id:                  summary.Id.String(),
+		Status:              statusAsString(summary.Status.Code),
+		Message:             summary.Status.Message,
+		NumFiles:            summary.Status.NumFiles,
+		NumFilesTransferred: summary.Status.NumFilesTransferred,
+		Note:                summary.Note,
+		Tags:                summary.Tags,
+		Allocation:          summary.Allocation,
+		PayloadBytes:        units.GigabytesToBytes(summary.PayloadSize),
+		BytesTransferred:    summary.Status.NumBytesTransferred,
+		BytesTotal:          transferBytesTotal(summary),
+		MBPerSecond:         units.BytesToMegabytes(summary.Status.BytesPerSecond),
+		Warnings:            summary.Warnings,
+		Expired:             summary.Expired,
+		StartTime:           summary.StartTime,
+		Stages:              make([]TransferStageResponse, len(summary.Stages)),
+		Files:               make([]TransferFileResponse, 0, summary.Status.NumFiles),
+		Timeline:            make([]TimelineEntryResponse, len(summary.Timeline)),
+	}
+	if !summary.CompletionTime.IsZero() {
+		response.CompletionTime = &summary.CompletionTime
+	}
+	if summary.Batch != uuid.Nil {
+		response.Batch = summary.Batch.String()
+	}
+	for _, dependency := range summary.DependsOn {
+		response.DependsOn = append(response.DependsOn, dependency.String())
+	}
+	if !summary.Deadline.IsZero() {
+		response.Deadline = &summary.Deadline
+	}
+	if !summary.EstimatedEnd.IsZero() {
+		response.ETA = &summary.EstimatedEnd
+	}
+	for i, entry := range summary.Timeline {
+		response.Timeline[i] = TimelineEntryResponse{
+			Stage:   entry.Stage,
+			Start:   entry.Start,
+			Seconds: entry.Duration().Seconds(),
+		}
+		if !entry.End.IsZero() {
+			response.Timeline[i].End = &entry.End
+		}
+	}
+	for i, stage := range summary.Stages {
+		status := statusAsString(stage.Status.Code)
+		response.Stages[i] = TransferStageResponse{
+			Source:         stage.Source,
+			SourceEndpoint: stage.SourceEndpoint,
+			RelayEndpoint:  stage.RelayEndpoint,
+			Mover:          stage.Mover,
+			StagingStatus:  stagingStatusAsString(stage.StagingStatus),
+			Status:         status,
+			NumFiles:       len(stage.Files),
+		}
+		for _, file := range stage.Files {
+			fileResponse := TransferFileResponse{
+				Id:     file.Id,
+				Path:   file.Path,
+				Stage:  i,
+				Status: status,
+			}
+			if file.SkipReason != "" {
+				fileResponse.Status = "skipped"
+				fileResponse.Error = file.SkipReason
+			} else if file.QuarantinePath != "" {
+				fileResponse.Status = "corrupt"
+				fileResponse.Error = fmt.Sprintf("checksum mismatch (quarantined at %s)", file.QuarantinePath)
+			}
+			response.Files = append(response.Files, fileResponse)
+		}
+	}
+	return response
+}
+
+// handler method for pausing a transfer
+func (service *prototype) pauseTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer to be paused"`
+	}) (*TransferStatusOutput, error) {
+	return service.pauseOrResumeTransfer(input.Authorization, input.Id, tasks.Pause)
+}
+
+// handler method for resuming a paused transfer
+func (service *prototype) resumeTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer to be resumed"`
+	}) (*TransferStatusOutput, error) {
+	return service.pauseOrResumeTransfer(input.Authorization, input.Id, tasks.Resume)
+}
+
+// pauses or resumes the transfer with the given ID using the given function,
+// provided that the authorized user owns the transfer or is a super user
+func (service *prototype) pauseOrResumeTransfer(authorization string, taskId uuid.UUID,
+	pauseOrResume func(uuid.UUID) error) (*TransferStatusOutput, error) {
+
+	userOrClient, err := authorize(authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := tasks.Summarize(taskId)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	switch identity := userOrClient.(type) {
+	case auth.User:
+		if !identity.IsSuper && identity.Orcid != summary.Orcid {
+			return nil, huma.Error403Forbidden(fmt.Sprintf("Not authorized to pause or resume transfer %s", taskId.String()))
+		}
+	case auth.Client:
+		if identity.Orcid != summary.Orcid {
+			return nil, huma.Error403Forbidden(fmt.Sprintf("Not authorized to pause or resume transfer %s", taskId.String()))
+		}
+	}
+
+	err = pauseOrResume(taskId)
+	if err != nil {
+		switch err.(type) {
+		case *tasks.NotFoundError:
+			return nil, huma.Error404NotFound(err.Error())
+		case *tasks.CannotPauseError:
+			return nil, huma.Error409Conflict(err.Error())
+		default:
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+	}
+
+	summary, err = tasks.Summarize(taskId)
+	if err != nil {
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	return &TransferStatusOutput{
+		Body: transferStatusResponse(summary),
+	}, nil
+}
+
+type TaskDeletionOutput struct {
+	Status int
+}
+
+// handler method for deleting (canceling) an existing transfer
+func (service *prototype) deleteTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
+	}) (*TaskDeletionOutput, error) {
+
+	// request that the task be canceled
+	err := tasks.Cancel(input.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskDeletionOutput{
+		Status: http.StatusAccepted,
+	}, nil
+}
+
+// returns the ORCID of the user on whose behalf a request is made: that of the
+// authorized user, or for a client (which acts on behalf of its users) the
+// given ORCID if specified and the client's otherwise
+func requestingOrcid(userOrClient any, orcid string) string {
+	switch identity := userOrClient.(type) {
+	case auth.User:
+		return identity.Orcid
+	case auth.Client:
+		if orcid == "" {
+			return identity.Orcid
+		}
+	}
+	return orcid
+}
+
+// converts an error from the collection store to an appropriate HTTP error
+func collectionError(err error) error {
+	switch err.(type) {
+	case *collections.NotFoundError:
+		return huma.Error404NotFound(err.Error())
+	case *collections.NotOwnerError:
+		return huma.Error403Forbidden(err.Error())
+	default:
+		return huma.Error500InternalServerError(err.Error())
+	}
+}
+
+func collectionResponse(collection collections.Collection) CollectionResponse {
+	return CollectionResponse{
+		Id:         collection.Id.String(),
+		Name:       collection.Name,
+		Database:   collection.Database,
+		FileIds:    collection.FileIds,
+		Owner:      collection.Owner,
+		SharedWith: collection.SharedWith,
+	}
+}
+
+type CollectionOutput struct {
+	Body   CollectionResponse `doc:"A collection of file IDs"`
+	Status int
+}
+
+// handler method for saving a new collection of file IDs
+func (service *prototype) createCollection(ctx context.Context,
+	input *struct {
+		Authorization string            `header:"authorization" doc:"Authorization header with encoded access token"`
+		Body          CollectionRequest `doc:"The body of a POST request for a collection"`
+	}) (*CollectionOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	orcid := requestingOrcid(userOrClient, input.Body.Orcid)
+
+	if strings.TrimSpace(input.Body.Name) == "" {
+		return nil, huma.Error400BadRequest("Collections must have names")
+	}
+	if !databases.HaveDatabase(input.Body.Database) {
+		return nil, huma.Error404NotFound(fmt.Sprintf("Database %s not found", input.Body.Database))
+	}
+	if len(input.Body.FileIds) == 0 {
+		return nil, huma.Error400BadRequest("Collections must include at least one file ID")
+	}
+
+	collectionId, err := collections.Create(collections.Collection{
+		Name:       input.Body.Name,
+		Database:   input.Body.Database,
+		FileIds:    input.Body.FileIds,
+		Owner:      orcid,
+		SharedWith: input.Body.SharedWith,
+	})
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	collection, err := collections.Fetch(collectionId, orcid)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	return &CollectionOutput{
+		Body:   collectionResponse(collection),
+		Status: http.StatusCreated,
+	}, nil
+}
+
+// handler method for fetching a collection owned by or shared with the user
+func (service *prototype) getCollection(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the collection"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user accessing the collection (defaults to that of the authorized user)"`
+	}) (*CollectionOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := collections.Fetch(input.Id, requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	return &CollectionOutput{
+		Body:   collectionResponse(collection),
+		Status: http.StatusOK,
+	}, nil
+}
+
+type CollectionListOutput struct {
+	Body CollectionListResponse `doc:"Collections owned by or shared with the user"`
+}
+
+// handler method for listing collections owned by or shared with the user
+func (service *prototype) listCollections(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Orcid         string `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user whose collections are listed (defaults to that of the authorized user)"`
+	}) (*CollectionListOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	userCollections, err := collections.List(requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	responses := make([]CollectionResponse, len(userCollections))
+	for i, collection := range userCollections {
+		responses[i] = collectionResponse(collection)
+	}
+	return &CollectionListOutput{
+		Body: CollectionListResponse{
+			Collections: responses,
+		},
+	}, nil
+}
+
+// handler method for updating the users with whom a collection is shared
+func (service *prototype) shareCollection(ctx context.Context,
+	input *struct {
+		Authorization string                 `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID              `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the collection to be shared"`
+		Body          CollectionPatchRequest `doc:"The body of a PATCH request for a collection"`
+	}) (*CollectionOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := collections.Share(input.Id, requestingOrcid(userOrClient, input.Body.Orcid),
+		input.Body.SharedWith)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	return &CollectionOutput{
+		Body:   collectionResponse(collection),
+		Status: http.StatusOK,
+	}, nil
+}
+
+type CollectionDeletionOutput struct {
+	Status int
+}
+
+// handler method for deleting a collection owned by the user
+func (service *prototype) deleteCollection(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the collection to be deleted"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user who owns the collection (defaults to that of the authorized user)"`
+	}) (*CollectionDeletionOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	err = collections.Delete(input.Id, requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	return &CollectionDeletionOutput{
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+// converts an error from the saved search store to an appropriate HTTP error
+func savedSearchError(err error) error {
+	switch err.(type) {
+	case *searches.NotFoundError:
+		return huma.Error404NotFound(err.Error())
+	default:
+		return huma.Error500InternalServerError(err.Error())
+	}
+}
+
+func savedSearchResponse(search searches.Search) SavedSearchResponse {
+	response := SavedSearchResponse{
+		Id:         search.Id.String(),
+		Name:       search.Name,
+		Database:   search.Database,
+		Query:      search.Query,
+		Syntax:     search.Syntax,
+		Specific:   search.Specific,
+		Owner:      search.Owner,
+		WebhookURL: search.WebhookURL,
+		LastError:  search.LastError,
+		NumFiles:   len(search.FileIds),
+		NewFileIds: search.NewFileIds,
+	}
+	if !search.LastRunTime.IsZero() {
+		response.LastRunTime = search.LastRunTime.Format(time.RFC3339)
+	}
+	return response
+}
+
+type SavedSearchOutput struct {
+	Body   SavedSearchResponse `doc:"A saved search"`
+	Status int
+}
+
+// handler method for saving a search, which is run immediately to establish
+// the files it matches
+func (service *prototype) createSavedSearch(ctx context.Context,
+	input *struct {
+		Authorization string             `header:"authorization" doc:"Authorization header with encoded access token"`
+		Body          SavedSearchRequest `doc:"The body of a POST request for a saved search"`
+	}) (*SavedSearchOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	orcid := requestingOrcid(userOrClient, input.Body.Orcid)
+
+	if strings.TrimSpace(input.Body.Name) == "" {
+		return nil, huma.Error400BadRequest("Saved searches must have names")
+	}
+	if !databases.HaveDatabase(input.Body.Database) {
+		return nil, huma.Error404NotFound(fmt.Sprintf("Database %s not found", input.Body.Database))
+	}
+	syntax := input.Body.Syntax
+	switch syntax {
+	case "", "native":
+		syntax = ""
+	case "dts":
+		if _, err := databases.ParseQuery(input.Body.Query); err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+	default:
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid query syntax: %s", syntax))
+	}
+	if input.Body.WebhookURL != "" {
+		if config.Service.CallbackSecret == "" {
+			return nil, huma.Error400BadRequest("Saved search webhooks are not enabled")
+		}
+		if err := webhooks.ValidateURL(input.Body.WebhookURL); err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+	}
+
+	searchId, err := searches.Create(searches.Search{
+		Name:       input.Body.Name,
+		Database:   input.Body.Database,
+		Query:      input.Body.Query,
+		Syntax:     syntax,
+		Specific:   input.Body.Specific,
+		Owner:      orcid,
+		WebhookURL: input.Body.WebhookURL,
+	})
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	search, err := searches.Run(searchId)
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	return &SavedSearchOutput{
+		Body:   savedSearchResponse(search),
+		Status: http.StatusCreated,
+	}, nil
+}
+
+// handler method for fetching a search saved by the user, including any files
+// that newly matched it when it was last run
+func (service *prototype) getSavedSearch(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the saved search"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user who owns the search (defaults to that of the authorized user)"`
+	}) (*SavedSearchOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	search, err := searches.Fetch(input.Id, requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	return &SavedSearchOutput{
+		Body:   savedSearchResponse(search),
+		Status: http.StatusOK,
+	}, nil
+}
+
+type SavedSearchListOutput struct {
+	Body SavedSearchListResponse `doc:"Searches saved by the user"`
+}
+
+// handler method for listing searches saved by the user
+func (service *prototype) listSavedSearches(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Orcid         string `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user whose searches are listed (defaults to that of the authorized user)"`
+	}) (*SavedSearchListOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	userSearches, err := searches.List(requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	responses := make([]SavedSearchResponse, len(userSearches))
+	for i, search := range userSearches {
+		responses[i] = savedSearchResponse(search)
+	}
+	return &SavedSearchListOutput{
+		Body: SavedSearchListResponse{
+			Searches: responses,
+		},
+	}, nil
+}
+
+type SavedSearchDeletionOutput struct {
+	Status int
+}
+
+// handler method for deleting a search saved by the user
+func (service *prototype) deleteSavedSearch(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the saved search to be deleted"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user who owns the search (defaults to that of the authorized user)"`
+	}) (*SavedSearchDeletionOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	err = searches.Delete(input.Id, requestingOrcid(userOrClient, input.Orcid))
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	return &SavedSearchDeletionOutput{
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func manifestSummary(entry manifests.Entry) ManifestSummary {
+	return ManifestSummary{
+		Id:           entry.Id.String(),
+		Orcid:        entry.Orcid,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		FileIds:      entry.FileIds,
+		CreationTime: entry.CreationTime.Format(time.RFC3339),
+	}
+}
+
+type ManifestListOutput struct {
+	Body ManifestListResponse `doc:"Archived manifests for the user's transfers"`
+}
+
+// handler method for searching the manifest archive for the user's transfers
+func (service *prototype) searchManifests(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		FileId        string `query:"file_id" example:"JDP:57f9e03f7ded5e3135bc069e" doc:"(Optional) the ID of a file that appears in matching transfers"`
+		Orcid         string `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user whose transfers are searched (defaults to that of the authorized user)"`
+	}) (*ManifestListOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
 
-	// fetch the status for the job using the appropriate task data
-	status, err := tasks.Status(input.Id)
+	entries, err := manifests.Search(manifests.Query{
+		FileId: input.FileId,
+		Orcid:  requestingOrcid(userOrClient, input.Orcid),
+	})
 	if err != nil {
-		return nil, huma.Error404NotFound(err.Error())
+		return nil, huma.Error500InternalServerError(err.Error())
 	}
-	return &TransferStatusOutput{
-		Body: TransferStatusResponse{
-			Id:                  input.Id.String(),
-			Status:              statusAsString(status.Code),
-			Message:             status.Message,
-			NumFiles:            status.NumFiles,
-			NumFilesTransferred: status.NumFilesTransferred,
+	summaries := make([]ManifestSummary, len(entries))
+	for i, entry := range entries {
+		summaries[i] = manifestSummary(entry)
+	}
+	return &ManifestListOutput{
+		Body: ManifestListResponse{
+			Manifests: summaries,
 		},
 	}, nil
 }
 
-type TaskDeletionOutput struct {
-	Status int
+type ManifestOutput struct {
+	Body ManifestResponse `doc:"An archived transfer manifest"`
 }
 
-// handler method for deleting (canceling) an existing transfer
-func (service *prototype) deleteTransfer(ctx context.Context,
+// handler method for fetching the archived manifest for one of the user's transfers
+func (service *prototype) getManifest(ctx context.Context,
 	input *struct {
 		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
-		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the requested transfer"`
-	}) (*TaskDeletionOutput, error) {
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user who requested the transfer (defaults to that of the authorized user)"`
+	}) (*ManifestOutput, error) {
 
-	// request that the task be canceled
-	err := tasks.Cancel(input.Id)
+	userOrClient, err := authorize(input.Authorization)
 	if err != nil {
 		return nil, err
 	}
-	return &TaskDeletionOutput{
-		Status: http.StatusAccepted,
+
+	entry, err := manifests.Fetch(input.Id)
+	if err == nil && entry.Orcid != requestingOrcid(userOrClient, input.Orcid) {
+		err = &manifests.NotFoundError{Id: input.Id} // don't reveal others' transfers
+	}
+	if err != nil {
+		if _, notFound := err.(*manifests.NotFoundError); notFound {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	var manifest map[string]any
+	if err := json.Unmarshal(entry.Manifest, &manifest); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &ManifestOutput{
+		Body: ManifestResponse{
+			ManifestSummary: manifestSummary(entry),
+			Manifest:        manifest,
+		},
+	}, nil
+}
+
+type VerificationOutput struct {
+	Body VerificationResponse `doc:"A report on the state of the files delivered by a transfer"`
+}
+
+// handler method for re-verifying the files delivered by a completed transfer
+// (by the user who requested it or a superuser)
+func (service *prototype) verifyTransfer(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the completed transfer"`
+		Orcid         string    `query:"orcid" example:"0000-0002-9227-8514" doc:"(Optional) ORCID for the user who requested the transfer (defaults to that of the authorized user)"`
+	}) (*VerificationOutput, error) {
+
+	userOrClient, err := authorize(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the requesting user or a superuser can verify a transfer
+	entry, err := manifests.Fetch(input.Id)
+	if user, ok := userOrClient.(auth.User); err == nil && !(ok && user.IsSuper) &&
+		entry.Orcid != requestingOrcid(userOrClient, input.Orcid) {
+		err = &manifests.NotFoundError{Id: input.Id}
+	}
+	if err != nil {
+		if _, notFound := err.(*manifests.NotFoundError); notFound {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	report, err := tasks.Verify(input.Id)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	files := make([]FileVerificationResponse, len(report.Files))
+	for i, file := range report.Files {
+		files[i] = FileVerificationResponse(file)
+	}
+	return &VerificationOutput{
+		Body: VerificationResponse{
+			Id:                report.Id.String(),
+			Destination:       report.Destination,
+			DestinationFolder: report.DestinationFolder,
+			Time:              report.Time.Format(time.RFC3339),
+			Passed:            report.Passed(),
+			NumFiles:          report.NumFiles,
+			NumMissing:        report.NumMissing,
+			NumMismatched:     report.NumMismatched,
+			NumUnverified:     report.NumUnverified,
+			Files:             files,
+		},
+	}, nil
+}
+
+type BandwidthTestOutput struct {
+	Body BandwidthTestResponse `doc:"The results of a bandwidth test between two endpoints"`
+}
+
+// handler method for measuring the throughput between two configured endpoints
+// (superusers only)
+func (service *prototype) testBandwidth(ctx context.Context,
+	input *struct {
+		Authorization string               `header:"authorization" doc:"Authorization header with encoded access token"`
+		Body          BandwidthTestRequest `doc:"The body of a bandwidth test request"`
+	}) (*BandwidthTestOutput, error) {
+
+	_, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceName := input.Body.Source
+	if sourceName == "" {
+		sourceName = config.Service.Endpoint
+	}
+	size := int64(input.Body.Size) * 1024 * 1024
+	if size == 0 {
+		size = 100 * 1024 * 1024
+	}
+	if float64(size)/float64(1024*1024*1024) > config.Service.MaxPayloadSize {
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Test payload size exceeds the maximum payload size (%s)",
+			units.FormatGigabytes(config.Service.MaxPayloadSize)))
+	}
+	timeout := time.Duration(input.Body.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+
+	endpointsByName := make(map[string]endpoints.Endpoint)
+	for _, name := range []string{sourceName, input.Body.Destination} {
+		endpoint, err := endpoints.NewEndpoint(name)
+		if err != nil {
+			if _, notFound := err.(endpoints.NotFoundError); notFound {
+				return nil, huma.Error404NotFound(err.Error())
+			}
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		endpointsByName[name] = endpoint
+	}
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+	report, err := endpoints.MeasureBandwidth(endpointsByName[sourceName],
+		endpointsByName[input.Body.Destination], size, pollInterval, timeout)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &BandwidthTestOutput{
+		Body: BandwidthTestResponse{
+			Source:      sourceName,
+			Destination: input.Body.Destination,
+			PayloadSize: report.PayloadSize,
+			Latency:     report.Latency.Seconds(),
+			Duration:    report.Duration.Seconds(),
+			Throughput:  report.Throughput,
+		},
 	}, nil
 }
 
+type FaultsOutput struct {
+	Body FaultsResponse `doc:"Faults injected into a transfer"`
+}
+
+func faultsOutput(taskId uuid.UUID) *FaultsOutput {
+	injected := faults.Injected(taskId)
+	names := make([]string, len(injected))
+	for i, fault := range injected {
+		names[i] = string(fault)
+	}
+	return &FaultsOutput{
+		Body: FaultsResponse{
+			Id:     taskId.String(),
+			Faults: names,
+		},
+	}
+}
+
+// handler method for injecting a fault into a transfer (chaos testing only,
+// superusers only)
+func (service *prototype) injectFault(ctx context.Context,
+	input *struct {
+		Authorization string                `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID             `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer"`
+		Body          FaultInjectionRequest `doc:"The body of a fault injection request"`
+	}) (*FaultsOutput, error) {
+
+	_, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	err = faults.Inject(input.Id, faults.Fault(input.Body.Fault), input.Body.Count)
+	if err != nil {
+		switch err.(type) {
+		case *faults.InvalidFaultError:
+			return nil, huma.Error400BadRequest(err.Error())
+		case *faults.NotEnabledError:
+			return nil, huma.Error403Forbidden(err.Error())
+		default:
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+	}
+	return faultsOutput(input.Id), nil
+}
+
+// handler method for clearing faults injected into a transfer (chaos testing
+// only, superusers only)
+func (service *prototype) clearFaults(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the transfer"`
+	}) (*FaultsOutput, error) {
+
+	_, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	faults.Clear(input.Id)
+	return faultsOutput(input.Id), nil
+}
+
 // returns the uptime for the service in seconds
 func (service *prototype) uptime() float64 {
 	return time.Since(service.StartTime).Seconds()
 }
+
+// returns responses for the notices currently in effect (none if the notice
+// store can't be read)
+func activeNotices() []NoticeResponse {
+	active, err := notices.Active(time.Now())
+	if err != nil {
+		if notices.IsOpen() {
+			slog.Error(err.Error())
+		}
+		return nil
+	}
+	responses := make([]NoticeResponse, len(active))
+	for i, notice := range active {
+		responses[i] = noticeResponse(notice)
+	}
+	return responses
+}
+
+func noticeResponse(notice notices.Notice) NoticeResponse {
+	response := NoticeResponse{
+		Id:       notice.Id.String(),
+		Message:  notice.Message,
+		Severity: notice.Severity,
+	}
+	if !notice.Start.IsZero() {
+		response.Start = &notice.Start
+	}
+	if !notice.End.IsZero() {
+		response.End = &notice.End
+	}
+	return response
+}
+
+// authorizes the administrator (superuser) with the given authorization
+// header, returning their ORCID
+func authorizeAdmin(authorizationHeader string) (string, error) {
+	userOrClient, err := authorize(authorizationHeader)
+	if err != nil {
+		return "", err
+	}
+	if user, ok := userOrClient.(auth.User); !ok || !user.IsSuper {
+		return "", huma.Error403Forbidden("This operation is restricted to DTS administrators")
+	}
+	return userOrClient.(auth.User).Orcid, nil
+}
+
+type NoticeOutput struct {
+	Body NoticeResponse `doc:"a service-wide notice"`
+}
+
+type NoticeListOutput struct {
+	Body NoticeListResponse `doc:"service-wide notices currently in effect"`
+}
+
+// handler method for listing the notices currently in effect
+func (service *prototype) getNotices(ctx context.Context,
+	input *struct{}) (*NoticeListOutput, error) {
+
+	responses := activeNotices()
+	if responses == nil {
+		responses = make([]NoticeResponse, 0)
+	}
+	return &NoticeListOutput{
+		Body: NoticeListResponse{
+			Notices: responses,
+		},
+	}, nil
+}
+
+// handler method for posting a notice
+func (service *prototype) postNotice(ctx context.Context,
+	input *struct {
+		Authorization string        `header:"authorization" doc:"Authorization header with encoded access token"`
+		Body          NoticeRequest `doc:"The body of a POST request for a notice"`
+	}) (*NoticeOutput, error) {
+
+	orcid, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	notice, err := notices.Post(notices.Notice{
+		Message:  input.Body.Message,
+		Severity: input.Body.Severity,
+		Start:    input.Body.Start,
+		End:      input.Body.End,
+		Author:   orcid,
+	})
+	if err != nil {
+		if _, invalid := err.(*notices.InvalidNoticeError); invalid {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	slog.Info(fmt.Sprintf("Posted notice %s: %s", notice.Id.String(), notice.Message))
+	return &NoticeOutput{
+		Body: noticeResponse(notice),
+	}, nil
+}
+
+type NoticeDeletionOutput struct {
+	Status int
+}
+
+// handler method for removing a notice
+func (service *prototype) deleteNotice(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Id            uuid.UUID `path:"id" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"the UUID for the notice"`
+	}) (*NoticeDeletionOutput, error) {
+
+	_, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	err = notices.Remove(input.Id)
+	if err != nil {
+		if _, notFound := err.(*notices.NotFoundError); notFound {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &NoticeDeletionOutput{
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+type AllocationUsageOutput struct {
+	Body AllocationUsageListResponse `doc:"data movement attributed to allocations over a period"`
+}
+
+// handler method for summarizing data movement by allocation (administrators
+// only)
+func (service *prototype) getAllocationUsage(ctx context.Context,
+	input *struct {
+		Authorization string    `header:"authorization" doc:"Authorization header with encoded access token"`
+		Start         time.Time `query:"start" doc:"(Optional) the beginning of the period of interest (default: 30 days before its end)"`
+		Stop          time.Time `query:"stop" doc:"(Optional) the end of the period of interest (default: now)"`
+	}) (*AllocationUsageOutput, error) {
+
+	if _, err := authorizeAdmin(input.Authorization); err != nil {
+		return nil, err
+	}
+
+	stop := input.Stop
+	if stop.IsZero() {
+		stop = time.Now()
+	}
+	start := input.Start
+	if start.IsZero() {
+		start = stop.Add(-30 * 24 * time.Hour)
+	}
+	if stop.Before(start) {
+		return nil, huma.Error400BadRequest("The end of the period precedes its beginning")
+	}
+
+	usages, err := journal.UsageByAllocation(start, stop)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	allocations := make([]AllocationUsageResponse, len(usages))
+	for i, usage := range usages {
+		allocations[i] = AllocationUsageResponse{
+			Allocation:   usage.Allocation,
+			NumTransfers: usage.NumTransfers,
+			NumFiles:     usage.NumFiles,
+			PayloadBytes: usage.PayloadSize,
+		}
+	}
+	return &AllocationUsageOutput{
+		Body: AllocationUsageListResponse{
+			Start:       start,
+			Stop:        stop,
+			Allocations: allocations,
+		},
+	}, nil
+}
+
+type StageTimingsOutput struct {
+	Body StageTimingListResponse `doc:"time spent by successful transfers in each pipeline stage"`
+}
+
+// handler method for reporting the time spent by successful transfers in each
+// pipeline stage (administrators only)
+func (service *prototype) getStageTimings(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+	}) (*StageTimingsOutput, error) {
+
+	if _, err := authorizeAdmin(input.Authorization); err != nil {
+		return nil, err
+	}
+
+	timings := tasks.StageTimings()
+	stages := make([]StageTimingResponse, len(timings))
+	for i, timing := range timings {
+		stages[i] = StageTimingResponse{
+			Stage:        timing.Stage,
+			NumTransfers: timing.NumTasks,
+			TotalSeconds: timing.Total.Seconds(),
+			MeanSeconds:  timing.Mean().Seconds(),
+			MaxSeconds:   timing.Max.Seconds(),
+		}
+	}
+	return &StageTimingsOutput{
+		Body: StageTimingListResponse{
+			Stages: stages,
+		},
+	}, nil
+}
+
+type JanitorStatisticsOutput struct {
+	Body JanitorStatisticsResponse `doc:"metrics describing the removal of orphaned scratch files"`
+}
+
+// handler method for reporting the janitor's removal of orphaned scratch files
+// (administrators only)
+func (service *prototype) getJanitorStatistics(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+	}) (*JanitorStatisticsOutput, error) {
+
+	if _, err := authorizeAdmin(input.Authorization); err != nil {
+		return nil, err
+	}
+
+	metrics := tasks.Janitor()
+	response := JanitorStatisticsResponse{
+		NumSweeps:       metrics.NumSweeps,
+		NumFilesRemoved: metrics.NumFilesRemoved,
+		BytesReclaimed:  metrics.BytesReclaimed,
+	}
+	if !metrics.LastSweepTime.IsZero() {
+		response.LastSweepTime = &metrics.LastSweepTime
+	}
+	return &JanitorStatisticsOutput{
+		Body: response,
+	}, nil
+}
+
+type CredentialStatusesOutput struct {
+	Body CredentialStatusListResponse `doc:"expiration statuses of upstream credentials"`
+}
+
+// handler method for reporting the expiration statuses of upstream credentials
+// (administrators only)
+func (service *prototype) getCredentialStatuses(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+	}) (*CredentialStatusesOutput, error) {
+
+	if _, err := authorizeAdmin(input.Authorization); err != nil {
+		return nil, err
+	}
+
+	statuses := tasks.Credentials()
+	credentials := make([]CredentialStatusResponse, len(statuses))
+	for i, status := range statuses {
+		credentials[i] = CredentialStatusResponse{
+			Name:   status.Name,
+			Status: "ok",
+		}
+		if status.Expires.IsZero() {
+			credentials[i].Status = "unknown"
+			continue
+		}
+		expires := status.Expires
+		secondsRemaining := int64(time.Until(expires).Seconds())
+		credentials[i].Expires = &expires
+		credentials[i].SecondsRemaining = &secondsRemaining
+		if status.Expired {
+			credentials[i].Status = "expired"
+		} else if status.Expiring {
+			credentials[i].Status = "expiring"
+		}
+	}
+	return &CredentialStatusesOutput{
+		Body: CredentialStatusListResponse{
+			Credentials: credentials,
+		},
+	}, nil
+}
+
+type AnonymizationOutput struct {
+	Body AnonymizationResponse `doc:"the numbers of anonymized records"`
+}
+
+// handler method for anonymizing the historical records of a user on request
+// (administrators only)
+func (service *prototype) anonymizeUser(ctx context.Context,
+	input *struct {
+		Authorization string `header:"authorization" doc:"Authorization header with encoded access token"`
+		Orcid         string `path:"orcid" example:"0000-0002-9227-8514" doc:"the ORCID of the user whose records are anonymized"`
+	}) (*AnonymizationOutput, error) {
+
+	admin, err := authorizeAdmin(input.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	if input.Orcid == auth.AnonymousOrcid {
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid ORCID: %s", input.Orcid))
+	}
+
+	var response AnonymizationResponse
+	if response.NumJournalRecords, err = journal.AnonymizeUser(input.Orcid); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	if response.NumManifests, err = manifests.AnonymizeUser(input.Orcid); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	if response.NumAuditEvents, err = audit.AnonymizeUser(input.Orcid); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	response.NumTransfers, response.NumActiveTransfers, err = tasks.AnonymizeUser(input.Orcid)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	if response.NumCollections, err = collections.AnonymizeUser(input.Orcid); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	if response.NumSavedSearches, err = searches.AnonymizeUser(input.Orcid); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	// record the anonymization itself (without the user's ORCID, of course)
+	err = audit.Record(audit.Event{
+		Orcid:   admin,
+		Action:  "anonymize_user",
+		Outcome: audit.OutcomeAllowed,
+		Message: fmt.Sprintf("anonymized %d journal record(s), %d manifest(s), %d audit event(s), %d transfer(s), and %d collection(s) of a user, and deleted %d saved search(es)",
+			response.NumJournalRecords, response.NumManifests, response.NumAuditEvents,
+			response.NumTransfers, response.NumCollections, response.NumSavedSearches),
+	})
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	return &AnonymizationOutput{
+		Body: response,
+	}, nil
+}
@@ -20,12 +20,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/dtstest"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/notices"
 )
 
 // working directory from which the tests were invoked
@@ -251,6 +253,19 @@ func post(resource string, body io.Reader) (*http.Response, error) {
 	return http.DefaultClient.Do(req)
 }
 
+// sends a PATCH query with well-formed headers and a payload
+func patch(resource string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPatch, resource, body)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := os.Getenv("DTS_KBASE_DEV_TOKEN")
+	b64Token := base64.StdEncoding.EncodeToString([]byte(accessToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", b64Token))
+	req.Header.Add("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
+}
+
 // sends a DELETE query with well-formed headers
 func delete_(resource string) (*http.Response, error) {
 	req, err := http.NewRequest(http.MethodDelete, resource, http.NoBody)
@@ -282,6 +297,60 @@ func TestQueryRoot(t *testing.T) {
 	assert.Equal(version, root.Version)
 }
 
+// checks that active notices are returned by the root and notices endpoints
+func TestQueryNotices(t *testing.T) {
+	assert := assert.New(t)
+
+	notice, err := notices.Post(notices.Notice{
+		Message:  "NERSC maintenance Saturday; transfers will queue",
+		Severity: notices.SeverityWarning,
+	})
+	assert.Nil(err)
+	defer notices.Remove(notice.Id)
+
+	resp, err := get(baseUrl)
+	assert.Nil(err)
+	respBody, err := io.ReadAll(resp.Body)
+	assert.Nil(err)
+	resp.Body.Close()
+	var root ServiceInfoResponse
+	err = json.Unmarshal(respBody, &root)
+	assert.Nil(err)
+	assert.Len(root.Notices, 1)
+	assert.Equal(notice.Message, root.Notices[0].Message)
+
+	resp, err = get(baseUrl + apiPrefix + "notices")
+	assert.Nil(err)
+	respBody, err = io.ReadAll(resp.Body)
+	assert.Nil(err)
+	resp.Body.Close()
+	var list NoticeListResponse
+	err = json.Unmarshal(respBody, &list)
+	assert.Nil(err)
+	assert.Len(list.Notices, 1)
+	assert.Equal(notice.Id.String(), list.Notices[0].Id)
+	assert.Equal("warning", list.Notices[0].Severity)
+	assert.Nil(list.Notices[0].End)
+}
+
+// queries the service's capabilities endpoint
+func TestQueryCapabilities(t *testing.T) {
+	assert := assert.New(t)
+	resp, err := http.Get(baseUrl + apiPrefix + "capabilities")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	assert.Nil(err)
+	var capabilities CapabilitiesResponse
+	err = json.Unmarshal(respBody, &capabilities)
+	assert.Nil(err)
+	assert.Equal([]string{"v1", "v2"}, capabilities.APIVersions)
+	assert.True(capabilities.CustomTransfers)
+	assert.Contains(capabilities.ManifestFormats, "frictionless-data-package")
+	assert.Equal(int64(config.Service.MaxPayloadSize*1024*1024*1024), capabilities.MaxPayloadBytes)
+}
+
 // queries the service's databases endpoint
 func TestQueryDatabases(t *testing.T) {
 	assert := assert.New(t)
@@ -430,6 +499,51 @@ func TestSearchDatabase(t *testing.T) {
 	assert.Equal("file1", results.Descriptors[0]["name"])
 }
 
+// searches several databases at once, reporting those that can't be searched
+func TestFederatedSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	resp, err := get(baseUrl + apiPrefix + "files?database=source,destination1&query=1")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	var results SearchResultsResponse
+	err = json.Unmarshal(respBody, &results)
+	assert.Nil(err)
+	assert.Equal("source,destination1", results.Database)
+	assert.Equal(1, len(results.Descriptors))
+	assert.Equal("file1", results.Descriptors[0]["name"])
+	assert.Equal("source", results.Descriptors[0]["database"])
+	assert.Empty(results.Errors)
+
+	// databases that can't be searched (e.g. the JDP, without credentials)
+	// report errors alongside the results from the others
+	resp, err = get(baseUrl + apiPrefix + "files?database=all&query=1")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	respBody, err = io.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	results = SearchResultsResponse{}
+	err = json.Unmarshal(respBody, &results)
+	assert.Nil(err)
+	assert.True(slices.ContainsFunc(results.Descriptors, func(d map[string]any) bool {
+		return d["database"] == "source" && d["name"] == "file1"
+	}))
+	for dbName := range results.Errors {
+		assert.Contains(config.Databases, dbName)
+	}
+
+	// unknown databases are rejected
+	resp, err = get(baseUrl + apiPrefix + "files?database=source,nope&query=1")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
 // searches a specific database with some database-specific parameters
 func TestSearchJdpDatabaseWithSpecificParams(t *testing.T) {
 	assert := assert.New(t)
@@ -489,6 +603,149 @@ func TestFetchJdpMetadata(t *testing.T) {
 	assert.Equal("JDP:61412246cc4ff44f36c8913d", results.Descriptors[2]["id"])
 }
 
+// fetches pages of file metadata from the source test database
+func TestFetchMetadataWithOffsetAndLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	resp, err := get(baseUrl + apiPrefix + "files/by-id?database=source&ids=1,2,3&offset=1&limit=1")
+	assert.Nil(err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	var results FileMetadataResponse
+	err = json.Unmarshal(respBody, &results)
+	assert.Nil(err)
+	assert.Equal("source", results.Database)
+	assert.Equal(3, results.Total)
+	assert.Equal(1, len(results.Descriptors))
+	assert.Equal("2", results.Descriptors[0]["id"])
+
+	// an offset past the end of the list yields no descriptors
+	resp, err = get(baseUrl + apiPrefix + "files/by-id?database=source&ids=1,2,3&offset=3")
+	assert.Nil(err)
+	respBody, err = io.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	err = json.Unmarshal(respBody, &results)
+	assert.Nil(err)
+	assert.Equal(3, results.Total)
+	assert.Equal(0, len(results.Descriptors))
+}
+
+// stages files without transferring them and checks the staging status
+func TestStageFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	payload, err := json.Marshal(StagingRequest{
+		Database: "source",
+		FileIds:  []string{"1", "2", "3"},
+	})
+	assert.Nil(err)
+	resp, err := post(baseUrl+apiPrefix+"files/stage", bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var stagingResp StagingResponse
+	err = json.Unmarshal(body, &stagingResp)
+	assert.Nil(err)
+
+	resp, err = get(baseUrl + apiPrefix + fmt.Sprintf("files/stage/%s", stagingResp.Id.String()))
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var statusResp StagingStatusResponse
+	err = json.Unmarshal(body, &statusResp)
+	assert.Nil(err)
+	assert.Equal("source", statusResp.Database)
+	assert.Equal(3, statusResp.NumFiles)
+	assert.True(statusResp.Status == "active" || statusResp.Status == "succeeded")
+
+	// unknown staging requests aren't found
+	resp, err = get(baseUrl + apiPrefix + "files/stage/3f0f9563-e1f8-4b9c-9308-36988e25df0b")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	// staging requests for other users aren't found
+	resp, err = get(baseUrl + apiPrefix + fmt.Sprintf("files/stage/%s?orcid=9999-9999-9999-9999",
+		stagingResp.Id.String()))
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	// nor are nonexistent databases
+	payload, err = json.Marshal(StagingRequest{
+		Database: "nonexistent",
+		FileIds:  []string{"1"},
+	})
+	assert.Nil(err)
+	resp, err = post(baseUrl+apiPrefix+"files/stage", bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
+
+// estimates the cost of transferring files
+func TestEstimateFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	resp, err := get(baseUrl + apiPrefix + "files/estimate?database=source&ids=1,2,3")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var estimate FileEstimateResponse
+	err = json.Unmarshal(body, &estimate)
+	assert.Nil(err)
+	assert.Equal("source", estimate.Database)
+	assert.Equal(3, estimate.NumFiles)
+	assert.Equal(0, estimate.NumFilesToRecall) // no tape tier
+
+	// no IDs given
+	resp, err = get(baseUrl + apiPrefix + "files/estimate?database=source")
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	// nonexistent database
+	resp, err = get(baseUrl + apiPrefix + "files/estimate?database=nonexistent&ids=1")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
+
+// tests that users must accept a source database's usage terms before their
+// first transfer from it
+func TestTermsAcceptance(t *testing.T) {
+	assert := assert.New(t)
+
+	source := config.Databases["source"]
+	defer func() { config.Databases["source"] = source }()
+	withTerms := source
+	withTerms.Terms = "https://example.org/source-policy"
+	config.Databases["source"] = withTerms
+
+	orcid := "9999-8888-7777-6666"
+	err := checkTermsAcceptance(orcid, "source", false)
+	assert.NotNil(err)
+	assert.Equal(http.StatusForbidden, err.(huma.StatusError).GetStatus())
+	assert.Nil(checkTermsAcceptance(orcid, "source", true))
+	assert.Nil(checkTermsAcceptance(orcid, "source", false)) // (already accepted)
+
+	// other users and databases are unaffected
+	assert.NotNil(checkTermsAcceptance("1111-1111-1111-1111", "source", false))
+	assert.Nil(checkTermsAcceptance(orcid, "destination1", false))
+}
+
 // creates a transfer from source -> destination1
 func TestCreateTransfer(t *testing.T) {
 	assert := assert.New(t)
@@ -531,6 +788,7 @@ func TestCreateTransfer(t *testing.T) {
 	status, err := queryTransfer()
 	assert.Nil(err)
 	assert.NotEqual("failed", status.Status)
+	assert.False(status.CreatedAt.IsZero())
 
 	// wait a bit for the task to finish (shouldn't take long)
 	time.Sleep(600 * time.Millisecond)
@@ -539,6 +797,10 @@ func TestCreateTransfer(t *testing.T) {
 	status, err = queryTransfer()
 	assert.Nil(err)
 	assert.Equal("succeeded", status.Status)
+	if assert.NotNil(status.StartedAt) && assert.NotNil(status.CompletedAt) {
+		assert.False(status.StartedAt.Before(status.CreatedAt))
+		assert.False(status.CompletedAt.Before(*status.StartedAt))
+	}
 
 	// check for the files in the payload
 	username := testUser
@@ -549,6 +811,74 @@ func TestCreateTransfer(t *testing.T) {
 	}
 }
 
+// creates a transfer and checks its detailed status via API v2, and the
+// deprecation headers on the superseded v1 endpoint
+func TestTransferStatusV2(t *testing.T) {
+	assert := assert.New(t)
+	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
+
+	payload, err := json.Marshal(TransferRequest{
+		Orcid:       orcid,
+		Source:      "source",
+		FileIds:     []string{"1", "2", "3"},
+		Destination: "destination1",
+	})
+	assert.Nil(err)
+	resp, err := post(baseUrl+apiPrefix+"transfers", bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var xferResp TransferResponse
+	err = json.Unmarshal(body, &xferResp)
+	assert.Nil(err)
+	xferId := xferResp.Id
+
+	// the v1 endpoint points to its v2 successor
+	resp, err = get(baseUrl + apiPrefix + fmt.Sprintf("transfers/%s", xferId.String()))
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("true", resp.Header.Get("Deprecation"))
+	assert.Equal(fmt.Sprintf("</api/v2/transfers/%s>; rel=\"successor-version\"", xferId.String()),
+		resp.Header.Get("Link"))
+
+	queryTransfer := func() (TransferStatusResponseV2, error) {
+		resp, err := get(baseUrl + fmt.Sprintf("api/v2/transfers/%s", xferId.String()))
+		assert.Nil(err)
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		assert.Empty(resp.Header.Get("Deprecation"))
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(err)
+		var statusResp TransferStatusResponseV2
+		err = json.Unmarshal(body, &statusResp)
+		return statusResp, err
+	}
+
+	status, err := queryTransfer()
+	assert.Nil(err)
+	assert.False(status.StartTime.IsZero())
+
+	// wait for the task to finish
+	time.Sleep(600 * time.Millisecond)
+
+	status, err = queryTransfer()
+	assert.Nil(err)
+	assert.Equal("succeeded", status.Status)
+	assert.NotNil(status.CompletionTime)
+	if assert.Len(status.Stages, 1) {
+		assert.Equal("source", status.Stages[0].Source)
+		assert.Equal(3, status.Stages[0].NumFiles)
+	}
+	assert.Len(status.Files, 3)
+	for _, file := range status.Files {
+		assert.Equal(0, file.Stage)
+		assert.Equal("succeeded", file.Status)
+	}
+}
+
 // creates a transfer from source -> destination2 and then cancels it
 func TestCreateAndCancelTransfer(t *testing.T) {
 	assert := assert.New(t)
@@ -622,6 +952,266 @@ func TestCreateAndCancelTransfer(t *testing.T) {
 	}
 }
 
+// creates tagged transfers and lists them by tag
+func TestListTransfersByTag(t *testing.T) {
+	assert := assert.New(t)
+	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
+
+	createTransfer := func(tags []string) (*http.Response, error) {
+		payload, err := json.Marshal(TransferRequest{
+			Orcid:       orcid,
+			Source:      "source",
+			FileIds:     []string{"1", "2", "3"},
+			Destination: "destination1",
+			Tags:        tags,
+		})
+		assert.Nil(err)
+		return post(baseUrl+apiPrefix+"transfers", bytes.NewReader(payload))
+	}
+	listTransfers := func(tags string) (TransferListResponse, error) {
+		var listResp TransferListResponse
+		resp, err := get(baseUrl + apiPrefix + "transfers?tags=" + tags)
+		if err != nil {
+			return listResp, err
+		}
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return listResp, err
+		}
+		err = json.Unmarshal(body, &listResp)
+		return listResp, err
+	}
+
+	// create a couple of tagged transfers
+	for _, tags := range [][]string{{"fy25-soil-campaign"}, {"fy25-soil-campaign", "pilot"}} {
+		resp, err := createTransfer(tags)
+		assert.Nil(err)
+		assert.Equal(http.StatusCreated, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// invalid tags are rejected
+	resp, err := createTransfer([]string{"has,comma"})
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	listResp, err := listTransfers("fy25-soil-campaign")
+	assert.Nil(err)
+	assert.Equal(2, len(listResp.Transfers))
+
+	listResp, err = listTransfers("fy25-soil-campaign,pilot")
+	assert.Nil(err)
+	assert.Equal(1, len(listResp.Transfers))
+	assert.Equal([]string{"fy25-soil-campaign", "pilot"}, listResp.Transfers[0].Tags)
+
+	listResp, err = listTransfers("nonexistent")
+	assert.Nil(err)
+	assert.Equal(0, len(listResp.Transfers))
+}
+
+// creates a transfer and updates its note and tags
+func TestAnnotateTransfer(t *testing.T) {
+	assert := assert.New(t)
+	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
+
+	payload, err := json.Marshal(TransferRequest{
+		Orcid:       orcid,
+		Source:      "source",
+		FileIds:     []string{"1", "2", "3"},
+		Destination: "destination1",
+		Tags:        []string{"original"},
+	})
+	assert.Nil(err)
+	resp, err := post(baseUrl+apiPrefix+"transfers", bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var xferResp TransferResponse
+	err = json.Unmarshal(body, &xferResp)
+	assert.Nil(err)
+	resource := baseUrl + apiPrefix + fmt.Sprintf("transfers/%s", xferResp.Id.String())
+
+	// attach a note, leaving the tags alone
+	note := "resubmitted after staging outage"
+	payload, err = json.Marshal(TransferPatchRequest{Note: &note})
+	assert.Nil(err)
+	resp, err = patch(resource, bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	// replace the tags, leaving the note alone
+	payload, err = json.Marshal(TransferPatchRequest{Tags: []string{"fy25-soil-campaign"}})
+	assert.Nil(err)
+	resp, err = patch(resource, bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	// check the status
+	resp, err = get(resource)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var statusResp TransferStatusResponse
+	err = json.Unmarshal(body, &statusResp)
+	assert.Nil(err)
+	assert.Equal(note, statusResp.Note)
+	assert.Equal([]string{"fy25-soil-campaign"}, statusResp.Tags)
+
+	// only the owner may annotate the transfer
+	payload, err = json.Marshal(TransferPatchRequest{Orcid: "9999-9999-9999-9999", Note: &note})
+	assert.Nil(err)
+	resp, err = patch(resource, bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	// nonexistent transfers can't be annotated
+	resp, err = patch(baseUrl+apiPrefix+"transfers/3f0f9563-e1f8-4b9c-9308-36988e25df0b",
+		bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
+
+// creates a transfer, pauses it, and resumes it
+func TestPauseAndResumeTransfer(t *testing.T) {
+	assert := assert.New(t)
+	orcid := os.Getenv("DTS_KBASE_TEST_ORCID")
+
+	payload, err := json.Marshal(TransferRequest{
+		Orcid:       orcid,
+		Source:      "source",
+		FileIds:     []string{"1", "2", "3"},
+		Destination: "destination1",
+	})
+	assert.Nil(err)
+	resp, err := post(baseUrl+apiPrefix+"transfers", bytes.NewReader(payload))
+	assert.Nil(err)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	var xferResp TransferResponse
+	err = json.Unmarshal(body, &xferResp)
+	assert.Nil(err)
+	resource := baseUrl + apiPrefix + fmt.Sprintf("transfers/%s", xferResp.Id.String())
+
+	pauseOrResume := func(action string) (TransferStatusResponse, int) {
+		var statusResp TransferStatusResponse
+		resp, err := post(resource+"/"+action, http.NoBody)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(err)
+		json.Unmarshal(body, &statusResp)
+		return statusResp, resp.StatusCode
+	}
+
+	// pause the transfer and make sure it stays paused
+	status, code := pauseOrResume("pause")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("inactive", status.Status)
+	_, code = pauseOrResume("pause")
+	assert.Equal(http.StatusConflict, code)
+	time.Sleep(600 * time.Millisecond)
+	resp, err = get(resource)
+	assert.Nil(err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	err = json.Unmarshal(body, &status)
+	assert.Nil(err)
+	assert.Equal("inactive", status.Status)
+
+	// resume the transfer and wait for it to complete
+	status, code = pauseOrResume("resume")
+	assert.Equal(http.StatusOK, code)
+	assert.NotEqual("inactive", status.Status)
+	_, code = pauseOrResume("resume")
+	assert.Equal(http.StatusConflict, code)
+	time.Sleep(600 * time.Millisecond)
+	resp, err = get(resource)
+	assert.Nil(err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(err)
+	err = json.Unmarshal(body, &status)
+	assert.Nil(err)
+	assert.Equal("succeeded", status.Status)
+}
+
+// a downloading endpoint that serves one file from memory, checking its size
+// against the limit it's given before copying it like the HTTPS endpoints do
+type fakeDownloader struct {
+	endpoints.Endpoint
+	Content string
+}
+
+func (d *fakeDownloader) CanDownload() bool {
+	return true
+}
+
+func (d *fakeDownloader) Download(path string, w io.Writer, maxBytes int64) error {
+	if int64(len(d.Content)) > maxBytes {
+		return endpoints.FileTooLargeError{Path: path, Size: int64(len(d.Content)), MaxSize: maxBytes}
+	}
+	_, err := io.Copy(w, io.LimitReader(strings.NewReader(d.Content), maxBytes+1))
+	return err
+}
+
+// tests the selection and truncation of file previews
+func TestPreviewFile(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(previewable(map[string]any{"name": "genes", "path": "genes.gff", "mediatype": "text/x-gff"}))
+	assert.False(previewable(map[string]any{"name": "reads", "path": "reads.fastq.gz", "mediatype": "text/x-fastq"}))
+	assert.False(previewable(map[string]any{"name": "report", "path": "report.pdf", "mediatype": "application/pdf"}))
+
+	buffer := previewBuffer{Limit: 5}
+	n, err := buffer.Write([]byte("abc"))
+	assert.Equal(3, n)
+	assert.Nil(err)
+	_, err = buffer.Write([]byte("defg"))
+	assert.Equal(errPreviewFull, err)
+	preview, truncated, err := previewOf(buffer.Data, 4)
+	assert.Nil(err)
+	assert.Equal("abcd", string(preview))
+	assert.True(truncated)
+
+	// previews are downloaded from endpoints that serve files directly
+	downloader := &fakeDownloader{Content: "the quick brown fox"}
+	preview, truncated, err = downloadPreview(downloader, map[string]any{"path": "fox.txt"}, 9)
+	assert.Nil(err)
+	assert.Equal("the quick", string(preview))
+	assert.True(truncated)
+	preview, truncated, err = downloadPreview(downloader, map[string]any{"path": "fox.txt", "bytes": 19}, 64)
+	assert.Nil(err)
+	assert.Equal("the quick brown fox", string(preview))
+	assert.False(truncated)
+
+	// landing pages aren't previews
+	page := &http.Response{Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}}
+	assert.True(isLandingPage(page, map[string]any{"mediatype": "text/plain"}))
+	assert.False(isLandingPage(page, map[string]any{"mediatype": "text/html"}))
+
+	// the test source endpoint doesn't serve files over HTTPS
+	resp, err := get(baseUrl + apiPrefix + "files/preview?database=source&id=1")
+	assert.Nil(err)
+	assert.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	resp, err = get(baseUrl + apiPrefix + "files/preview?database=source&id=42")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
 // attempts to fetch the status of a nonexistent transfer
 func TestFetchInvalidTransferStatus(t *testing.T) {
 	assert := assert.New(t)
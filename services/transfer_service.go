@@ -2,8 +2,11 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/kbase/dts/databases"
 )
 
 // this type encodes a JSON object for responding to root queries
@@ -12,6 +15,28 @@ type ServiceInfoResponse struct {
 	Version       string `json:"version" example:"1.0.0" doc:"The version string (major.minor.patch)"`
 	Uptime        int    `json:"uptime" example:"345600" doc:"The time the service has been up (seconds)"`
 	Documentation string `json:"documentation" example:"/docs" doc:"The OpenAPI documentation endpoint"`
+	// active service-wide notices
+	Notices []NoticeResponse `json:"notices,omitempty" doc:"Announcements of planned disruptions, etc"`
+}
+
+// a response describing the optional features enabled in this deployment (GET)
+type CapabilitiesResponse struct {
+	// versions of the API offered by the service
+	APIVersions []string `json:"api_versions" example:"[\"v1\",\"v2\"]" doc:"versions of the API offered by the service"`
+	// whether transfers to custom (non-database) destinations are supported
+	CustomTransfers bool `json:"custom_transfers" doc:"whether privileged users may transfer files to custom Globus endpoints"`
+	// formats in which transfer manifests are written
+	ManifestFormats []string `json:"manifest_formats" example:"[\"frictionless-data-package\"]" doc:"formats in which transfer manifests are written"`
+	// whether searches may span several databases
+	FederatedSearch bool `json:"federated_search" doc:"whether a single search may span several databases"`
+	// whether clients may register webhooks for transfer events
+	Webhooks bool `json:"webhooks" doc:"whether clients may register webhooks notified of transfer events"`
+	// the maximum size of a transfer payload
+	MaxPayloadBytes int64 `json:"max_payload_bytes" example:"107374182400" doc:"the largest transfer payload accepted (bytes)"`
+	// the method used to sanitize destination paths (if any)
+	PathSanitization string `json:"path_sanitization,omitempty" example:"replace" enum:"replace,encode" doc:"the method used to sanitize special characters in destination paths (omitted if none)"`
+	// whether checksums missing from source metadata are computed
+	ComputeMissingChecksums bool `json:"compute_missing_checksums" doc:"whether checksums missing from source metadata are computed before transfer"`
 }
 
 // a response for a database-related query (GET)
@@ -20,6 +45,7 @@ type DatabaseResponse struct {
 	Name         string `json:"name" example:"JGI Data portal"`
 	Organization string `json:"organization" example:"Joint Genome Institute"`
 	URL          string `json:"url" example:"https://data.jgi.doe.gov"`
+	Terms        string `json:"terms,omitempty" example:"https://jgi.doe.gov/user-programs/pmo-overview/policies/" doc:"the URL of usage terms users must accept (with accepted_terms) in their first transfer from the database"`
 }
 
 // a response for a file search query (GET)
@@ -30,16 +56,100 @@ type SearchResultsResponse struct {
 	Query string `json:"query" example:"prochlorococcus" doc:"the given query string"`
 	// resources matching the query
 	Descriptors []map[string]any `json:"resources" doc:"an array of validated Frictionless descriptors"`
+	// diagnostic information, if requested
+	Explanation *SearchExplanation `json:"explanation,omitempty" doc:"diagnostic information about the search (if requested)"`
+	// errors encountered searching some of several databases, by database
+	Errors map[string]string `json:"errors,omitempty" doc:"errors that prevented some of the searched databases from returning results, by database ID (searches of several databases only)"`
+}
+
+// diagnostic information about a file search
+type SearchExplanation struct {
+	// query given to the database in its native syntax
+	NativeQuery string `json:"native_query" example:"prochlorococcus" doc:"the query given to the database in its native syntax"`
+	// upstream requests issued by the database
+	Requests []databases.UpstreamRequest `json:"requests" doc:"the requests issued by the database to its upstream service (with credentials redacted)"`
+	// total upstream latency
+	Latency float64 `json:"latency" example:"0.42" doc:"the total time spent waiting for upstream responses (seconds)"`
 }
 
 // a response for a file metadata query (GET)
 type FileMetadataResponse struct {
 	// name of organization database
 	Database string `json:"database" example:"jdp" doc:"the database searched"`
+	// total number of file IDs requested (irrespective of offset/limit)
+	Total int `json:"total" example:"1000" doc:"the total number of requested file IDs"`
 	// resources corresponding to given file IDs
 	Descriptors []map[string]any `json:"resources" doc:"an array of validated Frictionless descriptors"`
 }
 
+// a request to compare previously fetched file metadata with current metadata (POST)
+type FileDiffRequest struct {
+	// name of organization database
+	Database string `json:"database" example:"jdp" doc:"the database containing the files"`
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for the user requesting metadata (defaults to that of the authorized user)"`
+	// previously fetched resources
+	Descriptors []map[string]any `json:"resources" doc:"an array of previously fetched Frictionless descriptors"`
+}
+
+// a response for a file metadata comparison (POST)
+type FileDiffResponse struct {
+	// name of organization database
+	Database string `json:"database" example:"jdp" doc:"the database containing the files"`
+	// comparisons of previous and current resources
+	Diffs []databases.DescriptorDiff `json:"diffs" doc:"an array of comparisons of previous and current descriptors (in the order of the given resources), with status \"unchanged\", \"changed\", or \"missing\""`
+}
+
+// a response for a file transfer estimate query (GET)
+type FileEstimateResponse struct {
+	// name of organization database
+	Database string `json:"database" example:"jdp" doc:"the database containing the files"`
+	// number of requested files found in the database
+	NumFiles int `json:"num_files" doc:"the number of requested files found in the database"`
+	// size of the requested files (gigabytes)
+	PayloadSize float64 `json:"payload_size" doc:"the total size of the requested files (GB)"`
+	// size of the requested files (bytes)
+	PayloadBytes int64 `json:"payload_bytes" doc:"the total size of the requested files in bytes"`
+	// number of files requiring recall from tape
+	NumFilesToRecall int `json:"num_files_to_recall,omitempty" doc:"the number of requested files that must be recalled from tape"`
+	// expected time needed for tape recalls (seconds)
+	RecallTime float64 `json:"recall_time,omitempty" doc:"the expected time needed to recall files from tape (seconds)"`
+}
+
+// a request to stage files ahead of a transfer (POST)
+type StagingRequest struct {
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for user requesting staging (defaults to that of the authorized user)"`
+	// name of database containing the files
+	Database string `json:"database" example:"jdp" doc:"identifier for the database containing the files"`
+	// identifiers for files to be staged
+	FileIds []string `json:"file_ids" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files to be staged"`
+}
+
+// a response for a staging request (POST)
+type StagingResponse struct {
+	// staging request ID
+	Id uuid.UUID `json:"id" doc:"a UUID for the staging request"`
+}
+
+// a response for a staging status request (GET)
+type StagingStatusResponse struct {
+	// staging request ID
+	Id string `json:"id" doc:"the UUID for the staging request"`
+	// name of database containing the files
+	Database string `json:"database" example:"jdp" doc:"identifier for the database containing the files"`
+	// staging status
+	Status string `json:"status" example:"active" doc:"the status of the staging request (active, succeeded, failed, or unknown)"`
+	// number of files being staged
+	NumFiles int `json:"num_files" doc:"the number of files being staged"`
+	// number of files requiring recall from tape
+	NumFilesToRecall int `json:"num_files_to_recall,omitempty" doc:"the number of staged files that must be recalled from tape"`
+	// expected time needed for tape recalls (seconds)
+	RecallTime float64 `json:"recall_time,omitempty" doc:"the expected time needed to recall files from tape (seconds)"`
+	// position of the request in the database's staging queue (if queued)
+	QueuePosition int `json:"queue_position,omitempty" doc:"the (1-based) position of the request in the database's staging queue, if it's waiting for earlier requests to complete"`
+}
+
 // a request for a file transfer (POST)
 type TransferRequest struct {
 	// user ORCID
@@ -47,13 +157,51 @@ type TransferRequest struct {
 	// name of source database
 	Source string `json:"source" example:"jdp" doc:"source database identifier"`
 	// identifiers for files to be transferred
-	FileIds []string `json:"file_ids" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files to be transferred"`
+	FileIds []string `json:"file_ids,omitempty" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files to be transferred"`
+	// ID of a saved collection of files to be transferred
+	Collection string `json:"collection,omitempty" example:"de9a2d6a-f5c9-4322-b8a7-8121d83fdfc2" doc:"UUID of a collection (owned by or shared with the user) whose files are transferred along with any in file_ids"`
+	// identifiers or name patterns for files excluded from the transfer
+	Exclude []string `json:"exclude,omitempty" example:"[\"*.fastq.gz\"]" doc:"source-specific identifiers or glob patterns over file names for files to exclude from those requested"`
 	// name of destination database
 	Destination string `json:"destination" example:"kbase" doc:"destination database identifier"`
 	// a Markdown description of the transfer request
 	Description string `json:"description,omitempty" example:"# title\n* type: assembly\n" doc:"Markdown task description"`
+	// the language of the description
+	DescriptionLanguage string `json:"description_language,omitempty" example:"en" doc:"IETF BCP 47 language tag of the task description"`
+	// translations of the description into other languages
+	DescriptionTranslations []DescriptionTranslation `json:"description_translations,omitempty" doc:"translations of the task description into other languages, recorded with it in the transfer's manifest"`
 	// machine-readable instructions for processing a payload at the destination site
 	Instructions map[string]any `json:"instructions,omitempty" doc:"JSON object containing machine-readable instructions for processing payload at destination"`
+	// user-defined labels for grouping related transfers
+	Tags []string `json:"tags,omitempty" example:"[\"fy25-soil-campaign\"]" doc:"user-defined labels for grouping related transfers"`
+	// ID of a batch of transfers created together
+	Batch string `json:"batch,omitempty" example:"5c3e9a4e-2b1f-4f0e-9d8a-7a6b5c4d3e2f" doc:"UUID (chosen by the client) of a batch of related transfers created together, whose statuses can be tracked as a unit"`
+	// IDs of transfers that must succeed before this one starts
+	DependsOn []string `json:"depends_on,omitempty" example:"[\"5c3e9a4e-2b1f-4f0e-9d8a-7a6b5c4d3e2f\"]" doc:"UUIDs of existing transfers (requested by the same user) that must succeed before this transfer starts; the transfer waits until they do, and fails if any of them fails"`
+	// the allocation or project to which the transfer is attributed
+	Allocation string `json:"allocation,omitempty" example:"m3408" doc:"the DOE allocation or project to which the transfer's data movement is attributed"`
+	// if set, file checksums are neither submitted nor verified
+	SkipChecksums bool `json:"skip_checksums,omitempty" doc:"set to skip the submission and verification of file checksums"`
+	// if set, files whose sources produce errors are skipped instead of failing the transfer
+	SkipSourceErrors bool `json:"skip_source_errors,omitempty" doc:"set to skip files whose sources produce errors (e.g. missing or unreadable files) instead of failing the transfer (Globus source endpoints only)"`
+	// if set, only metadata describing the files is delivered
+	MetadataOnly bool `json:"metadata_only,omitempty" doc:"set to deliver only the manifest and small stub files describing where the requested files can be accessed (with URLs and checksums), without transferring the files themselves"`
+	// if set, a transfer of embargoed files waits for their embargoes to lift
+	WaitForEmbargo bool `json:"wait_for_embargo,omitempty" doc:"set to start the transfer automatically once embargoes on requested files lift, instead of failing"`
+	// URL to which events are POSTed as the transfer's status changes
+	CallbackURL string `json:"callback_url,omitempty" example:"https://example.com/dts-events" doc:"a URL to which signed JSON events are POSTed as the transfer's status changes (staging, active, finalizing, succeeded, failed, canceled, expired), if the service has callbacks enabled"`
+	// the time by which the transfer must complete
+	Deadline time.Time `json:"deadline,omitempty" example:"2025-06-30T17:00:00Z" doc:"the time by which staging and transfer must complete, after which the transfer is canceled and marked as expired, and an expired event is POSTed to its callback_url (if any)"`
+	// set if the user accepts the source database's usage terms
+	AcceptedTerms bool `json:"accepted_terms,omitempty" doc:"set to accept the usage terms of the source database (required in a user's first transfer from a database with terms)"`
+}
+
+// a translation of a transfer's description into another language
+type DescriptionTranslation struct {
+	// the language of the translation
+	Language string `json:"language" example:"fr" doc:"IETF BCP 47 language tag of the translation"`
+	// the translated description
+	Description string `json:"description" example:"# titre\n* type : assemblage\n" doc:"Markdown task description in the given language"`
 }
 
 // a response for a file transfer request (POST)
@@ -67,13 +215,501 @@ type TransferStatusResponse struct {
 	// transfer job ID
 	Id string `json:"id"`
 	// transfer job status
-	Status string `json:"status"`
+	Status string `json:"status" doc:"the status of the transfer (waiting, staging, active, inactive, finalizing, succeeded, failed, or unknown)"`
 	// message (if any) related to status
 	Message string `json:"message,omitempty"`
 	// number of files being transferred
 	NumFiles int `json:"num_files"`
 	// number of files that have been completely transferred
 	NumFilesTransferred int `json:"num_files_transferred"`
+	// total size of the files being transferred (bytes)
+	PayloadBytes int64 `json:"payload_bytes"`
+	// number of bytes that have been transferred so far
+	BytesTransferred int64 `json:"bytes_transferred" doc:"the number of bytes that have been moved to the destination so far"`
+	// total number of bytes to be transferred
+	BytesTotal int64 `json:"bytes_total" doc:"the total number of bytes to be moved to the destination, as reported by the transfer endpoints where known"`
+	// effective transfer rate (MiB/s)
+	MBPerSecond float64 `json:"mb_per_second" doc:"the combined effective rate at which files are being moved (MiB/s, or 0 if no files are moving)"`
+	// free-text note attached to the transfer by its owner
+	Note string `json:"note,omitempty"`
+	// user-defined labels associated with the transfer
+	Tags []string `json:"tags,omitempty"`
+	// batch to which the transfer belongs (if any)
+	Batch string `json:"batch,omitempty"`
+	// IDs of transfers that must succeed before this one starts
+	DependsOn []string `json:"depends_on,omitempty"`
+	// allocation or project to which the transfer is attributed
+	Allocation string `json:"allocation,omitempty"`
+	// non-fatal issues with the transfer's payload
+	Warnings []string `json:"warnings,omitempty"`
+	// time at which the transfer was requested
+	CreatedAt time.Time `json:"created_at" doc:"the time at which the transfer was requested"`
+	// time at which work on the transfer began (if it has)
+	StartedAt *time.Time `json:"started_at,omitempty" doc:"the time at which staging or transfer of files began (omitted if the transfer is still queued)"`
+	// time at which the transfer completed (if it has)
+	CompletedAt *time.Time `json:"completed_at,omitempty" doc:"the time at which the transfer succeeded or failed (omitted if it hasn't)"`
+	// time by which the transfer must complete (if any)
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// set if the transfer was canceled because its deadline passed
+	Expired bool `json:"expired,omitempty"`
+	// estimated time at which the transfer will complete (if known)
+	ETA *time.Time `json:"eta,omitempty" doc:"the estimated time at which staging and transfer will complete, based on observed transfer rates and staging history (omitted if unknown)"`
+}
+
+// a response aggregating the statuses of a batch of transfers (GET)
+type BatchResponse struct {
+	// batch ID
+	Id string `json:"id" doc:"the UUID for the batch"`
+	// aggregate status of the batch
+	Status string `json:"status" enum:"active,succeeded,failed" doc:"active if any transfer in the batch is still in progress, otherwise failed if any transfer failed, otherwise succeeded"`
+	// number of transfers in the batch
+	NumTransfers int `json:"num_transfers" doc:"the number of transfers in the batch"`
+	// numbers of transfers with each status
+	StatusCounts map[string]int `json:"status_counts" example:"{\"active\": 2, \"succeeded\": 5}" doc:"the number of transfers in the batch with each status"`
+	// number of files being transferred
+	NumFiles int `json:"num_files" doc:"the number of files transferred by the batch"`
+	// number of files that have been completely transferred
+	NumFilesTransferred int `json:"num_files_transferred" doc:"the number of files that have been completely transferred"`
+	// total size of the files being transferred (bytes)
+	PayloadBytes int64 `json:"payload_bytes" doc:"the total size of the files transferred by the batch (bytes)"`
+	// number of bytes that have been transferred so far
+	BytesTransferred int64 `json:"bytes_transferred" doc:"the number of bytes that have been moved to their destinations so far"`
+	// total number of bytes to be transferred
+	BytesTotal int64 `json:"bytes_total" doc:"the total number of bytes to be moved by the batch"`
+	// effective transfer rate (MiB/s)
+	MBPerSecond float64 `json:"mb_per_second" doc:"the combined effective rate at which the batch's files are being moved (MiB/s)"`
+	// statuses of the transfers in the batch
+	Transfers []TransferStatusResponse `json:"transfers" doc:"an array of statuses for the transfers in the batch, in order of creation"`
+}
+
+// a request to update the annotations of an existing file transfer (PATCH)
+type TransferPatchRequest struct {
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for the user who requested the transfer (defaults to that of the authorized user; only clients may supply it)"`
+	// a free-text note for the transfer
+	Note *string `json:"note,omitempty" example:"resubmitted after staging outage" doc:"free-text note attached to the transfer (omit to leave unchanged)"`
+	// user-defined labels for grouping related transfers
+	Tags []string `json:"tags,omitempty" example:"[\"fy25-soil-campaign\"]" doc:"user-defined labels replacing those of the transfer (omit to leave unchanged)"`
+}
+
+// a response for a transfer listing request (GET)
+type TransferListResponse struct {
+	// statuses of transfers matching the request
+	Transfers []TransferStatusResponse `json:"transfers" doc:"an array of statuses for matching transfers"`
+}
+
+// a response for a transfer status request in version 2 of the API (GET),
+// which includes the transfer's stages, per-file statuses, and timestamps
+type TransferStatusResponseV2 struct {
+	// transfer job ID
+	Id string `json:"id"`
+	// transfer job status
+	Status string `json:"status" doc:"the status of the transfer (waiting, staging, active, inactive, finalizing, succeeded, failed, or unknown)"`
+	// message (if any) related to status
+	Message string `json:"message,omitempty"`
+	// number of files being transferred
+	NumFiles int `json:"num_files"`
+	// number of files that have been completely transferred
+	NumFilesTransferred int `json:"num_files_transferred"`
+	// total size of the files being transferred (bytes)
+	PayloadBytes int64 `json:"payload_bytes"`
+	// number of bytes that have been transferred so far
+	BytesTransferred int64 `json:"bytes_transferred" doc:"the number of bytes that have been moved to the destination so far"`
+	// total number of bytes to be transferred
+	BytesTotal int64 `json:"bytes_total" doc:"the total number of bytes to be moved to the destination, as reported by the transfer endpoints where known"`
+	// effective transfer rate (MiB/s)
+	MBPerSecond float64 `json:"mb_per_second" doc:"the combined effective rate at which files are being moved (MiB/s, or 0 if no files are moving)"`
+	// free-text note attached to the transfer by its owner
+	Note string `json:"note,omitempty"`
+	// user-defined labels associated with the transfer
+	Tags []string `json:"tags,omitempty"`
+	// batch to which the transfer belongs (if any)
+	Batch string `json:"batch,omitempty"`
+	// IDs of transfers that must succeed before this one starts
+	DependsOn []string `json:"depends_on,omitempty"`
+	// allocation or project to which the transfer is attributed
+	Allocation string `json:"allocation,omitempty"`
+	// non-fatal issues with the transfer's payload
+	Warnings []string `json:"warnings,omitempty"`
+	// time by which the transfer must complete (if any)
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// set if the transfer was canceled because its deadline passed
+	Expired bool `json:"expired,omitempty"`
+	// estimated time at which the transfer will complete (if known)
+	ETA *time.Time `json:"eta,omitempty" doc:"the estimated time at which staging and transfer will complete, based on observed transfer rates and staging history (omitted if unknown)"`
+	// time at which the transfer was requested
+	StartTime time.Time `json:"start_time" doc:"the time at which the transfer was requested"`
+	// time at which the transfer completed (if it has)
+	CompletionTime *time.Time `json:"completion_time,omitempty" doc:"the time at which the transfer succeeded or failed (omitted if it hasn't)"`
+	// stages of the transfer, each moving files from one source endpoint
+	Stages []TransferStageResponse `json:"stages" doc:"stages of the transfer, each moving files from one source endpoint"`
+	// statuses of the individual files in the transfer
+	Files []TransferFileResponse `json:"files" doc:"statuses of the individual files in the transfer"`
+	// time spent by the transfer in each pipeline stage it has entered
+	Timeline []TimelineEntryResponse `json:"timeline" doc:"the time spent by the transfer in each pipeline stage it has entered (create, staging, transfer, finalize), in order"`
+}
+
+// the time spent by a transfer in a pipeline stage
+type TimelineEntryResponse struct {
+	// name of the stage
+	Stage string `json:"stage" enum:"create,staging,transfer,finalize" doc:"the name of the pipeline stage"`
+	// time at which the transfer entered the stage
+	Start time.Time `json:"start" doc:"the time at which the transfer entered the stage"`
+	// time at which the transfer left the stage (if it has)
+	End *time.Time `json:"end,omitempty" doc:"the time at which the transfer left the stage (omitted if it hasn't)"`
+	// time spent in the stage (seconds)
+	Seconds float64 `json:"seconds" doc:"the time spent by the transfer in the stage, or so far if it hasn't left it (seconds)"`
+}
+
+// the status of a stage of a transfer
+type TransferStageResponse struct {
+	// name of the database providing the stage's files
+	Source string `json:"source"`
+	// name of the endpoint from which the stage's files are transferred
+	SourceEndpoint string `json:"source_endpoint"`
+	// name of the endpoint relaying the stage's files (if any)
+	RelayEndpoint string `json:"relay_endpoint,omitempty"`
+	// provider moving the stage's files
+	Mover string `json:"mover,omitempty" example:"globus" doc:"the provider moving the stage's files (\"https\" for direct downloads), once their transfer has begun"`
+	// staging status of the stage's files at the source
+	StagingStatus string `json:"staging_status" enum:"unknown,active,succeeded,failed" doc:"status of the staging of the stage's files at the source"`
+	// transfer status of the stage's files
+	Status string `json:"status"`
+	// number of files moved by the stage
+	NumFiles int `json:"num_files"`
+}
+
+// the status of an individual file in a transfer
+type TransferFileResponse struct {
+	// file ID
+	Id string `json:"id"`
+	// file path, as given in the file's descriptor
+	Path string `json:"path"`
+	// index of the stage moving the file
+	Stage int `json:"stage" doc:"index of the transfer stage moving the file"`
+	// file status
+	Status string `json:"status" doc:"the status of the file's stage, \"skipped\" if the file was skipped because of a source error, or \"corrupt\" if it failed checksum verification"`
+	// error that caused the file to be skipped or quarantined (if any)
+	Error string `json:"error,omitempty" doc:"a description of the source error that caused the file to be skipped, or of the checksum mismatch that caused it to be quarantined (if either happened)"`
+}
+
+// a response for a transfer listing request in version 2 of the API (GET)
+type TransferListResponseV2 struct {
+	// statuses of transfers matching the request
+	Transfers []TransferStatusResponseV2 `json:"transfers" doc:"an array of statuses for matching transfers"`
+}
+
+// a summary of the data movement attributed to an allocation or project
+type AllocationUsageResponse struct {
+	// allocation or project ("" for unattributed transfers)
+	Allocation string `json:"allocation" example:"m3408" doc:"the allocation or project (empty for unattributed transfers)"`
+	// number of transfers attributed to the allocation
+	NumTransfers int `json:"num_transfers" doc:"the number of completed transfers attributed to the allocation"`
+	// number of files moved by those transfers
+	NumFiles int `json:"num_files" doc:"the number of files moved by those transfers"`
+	// total size of those transfers' payloads (bytes)
+	PayloadBytes int64 `json:"payload_bytes" doc:"the total size of those transfers' payloads (bytes)"`
+}
+
+// a response for a request for data movement statistics by allocation (GET)
+type AllocationUsageListResponse struct {
+	// start and end of the period covered by the statistics
+	Start time.Time `json:"start" doc:"the beginning of the period covered"`
+	Stop  time.Time `json:"stop" doc:"the end of the period covered"`
+	// usage by allocation
+	Allocations []AllocationUsageResponse `json:"allocations" doc:"data movement for each allocation with completed transfers in the period"`
+}
+
+// aggregated time spent by successful transfers in a pipeline stage
+type StageTimingResponse struct {
+	// name of the stage
+	Stage string `json:"stage" enum:"create,staging,transfer,finalize" doc:"the name of the pipeline stage"`
+	// number of transfers that passed through the stage
+	NumTransfers int `json:"num_transfers" doc:"the number of successful transfers that passed through the stage"`
+	// total time spent in the stage (seconds)
+	TotalSeconds float64 `json:"total_seconds" doc:"the total time spent in the stage by those transfers (seconds)"`
+	// average time spent in the stage (seconds)
+	MeanSeconds float64 `json:"mean_seconds" doc:"the average time spent in the stage by a transfer (seconds)"`
+	// longest time spent in the stage (seconds)
+	MaxSeconds float64 `json:"max_seconds" doc:"the longest time spent in the stage by a single transfer (seconds)"`
+}
+
+// a response for a request for pipeline stage timings (GET)
+type StageTimingListResponse struct {
+	// timings for each stage
+	Stages []StageTimingResponse `json:"stages" doc:"aggregated timings for each pipeline stage, in order, for transfers that have succeeded since the service started"`
+}
+
+// a response for a request for janitor statistics (GET)
+type JanitorStatisticsResponse struct {
+	// number of sweeps performed by the janitor
+	NumSweeps int `json:"num_sweeps" doc:"the number of sweeps for orphaned scratch files since the service started"`
+	// number of orphaned files removed
+	NumFilesRemoved int `json:"num_files_removed" doc:"the number of orphaned scratch files (and directories) removed"`
+	// disk space reclaimed by removing orphaned files (bytes)
+	BytesReclaimed int64 `json:"bytes_reclaimed" doc:"the disk space reclaimed by removing orphaned scratch files (bytes)"`
+	// time of the most recent sweep
+	LastSweepTime *time.Time `json:"last_sweep_time,omitempty" doc:"the time of the most recent sweep (omitted if none has been performed)"`
+}
+
+// a response for a request to anonymize a user's historical records (POST)
+type AnonymizationResponse struct {
+	// numbers of anonymized records in each store
+	NumJournalRecords int `json:"num_journal_records" doc:"the number of anonymized transfer journal records"`
+	NumManifests      int `json:"num_manifests" doc:"the number of anonymized archived manifests"`
+	NumAuditEvents    int `json:"num_audit_events" doc:"the number of anonymized audit log events"`
+	NumTransfers      int `json:"num_transfers" doc:"the number of anonymized (completed) transfer task records"`
+	NumCollections    int `json:"num_collections" doc:"the number of anonymized collections (owned by or shared with the user)"`
+	NumSavedSearches  int `json:"num_saved_searches" doc:"the number of deleted saved searches"`
+	// transfers that couldn't be anonymized yet
+	NumActiveTransfers int `json:"num_active_transfers" doc:"the number of the user's transfers still in progress, which can be anonymized once they complete"`
+}
+
+// the expiration status of an upstream credential
+type CredentialStatusResponse struct {
+	// name of the credential
+	Name string `json:"name" example:"globus" doc:"the name of the credential in the DTS configuration"`
+	// expiration time (if known)
+	Expires *time.Time `json:"expires,omitempty" doc:"the time at which the credential expires (omitted if unknown)"`
+	// seconds remaining until expiration (if known)
+	SecondsRemaining *int64 `json:"seconds_remaining,omitempty" doc:"the number of seconds until the credential expires (negative if it has expired, omitted if unknown)"`
+	// expiration status
+	Status string `json:"status" enum:"ok,expiring,expired,unknown" doc:"\"expiring\" if the credential expires within the configured warning period, \"unknown\" if its expiration time isn't configured"`
+}
+
+// a response for a request for the expiration statuses of credentials (GET)
+type CredentialStatusListResponse struct {
+	// statuses of all configured credentials
+	Credentials []CredentialStatusResponse `json:"credentials" doc:"expiration statuses of all configured credentials"`
+}
+
+// a request to save a named collection of file IDs (POST)
+type CollectionRequest struct {
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for the user who owns the collection (defaults to that of the authorized user)"`
+	// name of the collection
+	Name string `json:"name" example:"soil metagenomes" doc:"a name for the collection"`
+	// name of database containing the files
+	Database string `json:"database" example:"jdp" doc:"identifier for the database containing the files"`
+	// identifiers for files in the collection
+	FileIds []string `json:"file_ids" example:"[\"fileid1\", \"fileid2\"]" doc:"source-specific identifiers for files in the collection"`
+	// ORCIDs of users with read-only access to the collection
+	SharedWith []string `json:"shared_with,omitempty" example:"[\"0000-0002-1825-0097\"]" doc:"ORCIDs for users with read-only access to the collection"`
+}
+
+// a request to update the sharing of an existing collection (PATCH)
+type CollectionPatchRequest struct {
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for the user who owns the collection (defaults to that of the authorized user)"`
+	// ORCIDs of users with read-only access to the collection
+	SharedWith []string `json:"shared_with" example:"[\"0000-0002-1825-0097\"]" doc:"ORCIDs for users with read-only access to the collection (replacing any existing ones)"`
+}
+
+// a response for a collection request (GET, POST, PATCH)
+type CollectionResponse struct {
+	// collection ID
+	Id string `json:"id" doc:"the UUID for the collection"`
+	// name of the collection
+	Name string `json:"name" doc:"the name of the collection"`
+	// name of database containing the files
+	Database string `json:"database" doc:"identifier for the database containing the files"`
+	// identifiers for files in the collection
+	FileIds []string `json:"file_ids" doc:"source-specific identifiers for files in the collection"`
+	// ORCID of the collection's owner
+	Owner string `json:"owner" doc:"ORCID for the user who owns the collection"`
+	// ORCIDs of users with read-only access to the collection
+	SharedWith []string `json:"shared_with,omitempty" doc:"ORCIDs for users with read-only access to the collection"`
+}
+
+// a response for a collection listing request (GET)
+type CollectionListResponse struct {
+	// collections owned by or shared with the user
+	Collections []CollectionResponse `json:"collections" doc:"an array of collections owned by or shared with the user"`
+}
+
+// a request to save a search for files (POST)
+type SavedSearchRequest struct {
+	// user ORCID
+	Orcid string `json:"orcid,omitempty" example:"0000-0002-9227-8514" doc:"ORCID for the user who owns the search (defaults to that of the authorized user)"`
+	// name of the search
+	Name string `json:"name" example:"new soil metagenomes" doc:"a name for the search"`
+	// name of the database searched
+	Database string `json:"database" example:"jdp" doc:"identifier for the database searched"`
+	// search query
+	Query string `json:"query" example:"prochlorococcus" doc:"a query used to search the database for matching files"`
+	// syntax of the search query
+	Syntax string `json:"syntax,omitempty" example:"dts" enum:"native,dts" doc:"the syntax of the query: the database's native syntax (default) or the DTS query syntax"`
+	// database-specific search parameters
+	Specific map[string]any `json:"specific,omitempty" doc:"database-specific search parameters in a JSON object"`
+	// URL notified of newly matching files
+	WebhookURL string `json:"webhook_url,omitempty" example:"https://example.com/dts-hook" doc:"a public URL to which the IDs of newly matching files are POSTed, signed like transfer callbacks"`
+}
+
+// a response for a saved search request (GET, POST)
+type SavedSearchResponse struct {
+	// search ID
+	Id string `json:"id" doc:"the UUID for the saved search"`
+	// name of the search
+	Name string `json:"name" doc:"the name of the search"`
+	// name of the database searched
+	Database string `json:"database" doc:"identifier for the database searched"`
+	// search query
+	Query string `json:"query" doc:"the query used to search the database"`
+	// syntax of the search query
+	Syntax string `json:"syntax,omitempty" doc:"the syntax of the query (omitted for the database's native syntax)"`
+	// database-specific search parameters
+	Specific map[string]any `json:"specific,omitempty" doc:"database-specific search parameters"`
+	// ORCID of the search's owner
+	Owner string `json:"owner" doc:"ORCID for the user who owns the search"`
+	// URL notified of newly matching files
+	WebhookURL string `json:"webhook_url,omitempty" doc:"the URL to which the IDs of newly matching files are POSTed"`
+	// time at which the search was last run
+	LastRunTime string `json:"last_run_time,omitempty" doc:"the time at which the search was last run (RFC3339)"`
+	// error encountered when the search was last run
+	LastError string `json:"last_error,omitempty" doc:"a description of the error encountered when the search was last run (if any)"`
+	// number of files matched by the search
+	NumFiles int `json:"num_files" doc:"the number of files matched by the search when it was last run"`
+	// IDs of files newly matched by the search
+	NewFileIds []string `json:"new_file_ids,omitempty" doc:"identifiers for files first matched by the search when it was last run"`
+}
+
+// a response for a saved search listing request (GET)
+type SavedSearchListResponse struct {
+	// searches owned by the user
+	Searches []SavedSearchResponse `json:"searches" doc:"an array of searches saved by the user"`
+}
+
+// a summary of an archived transfer manifest
+type ManifestSummary struct {
+	// transfer ID
+	Id string `json:"id" doc:"the UUID for the transfer"`
+	// ORCID of the requesting user
+	Orcid string `json:"orcid" doc:"ORCID for the user who requested the transfer"`
+	// names of source and destination databases
+	Source      string `json:"source" doc:"the database from which files were transferred"`
+	Destination string `json:"destination" doc:"the database to which files were transferred"`
+	// identifiers for transferred files
+	FileIds []string `json:"file_ids" doc:"source-specific identifiers for the transferred files"`
+	// time at which the manifest was archived
+	CreationTime string `json:"creation_time" format:"date-time" doc:"the time at which the manifest was archived"`
+}
+
+// a response for an archived manifest request (GET)
+type ManifestResponse struct {
+	ManifestSummary
+	// the manifest itself
+	Manifest map[string]any `json:"manifest" doc:"the transfer manifest (a Frictionless DataPackage)"`
+}
+
+// a response for an archived manifest search (GET)
+type ManifestListResponse struct {
+	// summaries of matching manifests
+	Manifests []ManifestSummary `json:"manifests" doc:"an array of archived manifests matching the search criteria"`
+}
+
+// the verification status of a file delivered by a transfer
+type FileVerificationResponse struct {
+	// file ID in the source database
+	Id string `json:"id" doc:"the source-specific identifier for the file"`
+	// path relative to the destination endpoint's root
+	Path string `json:"path" doc:"the path of the file at the destination endpoint"`
+	// presence at the destination
+	Present bool `json:"present" doc:"true if the file is present at the destination"`
+	// outcome of checksum verification
+	Checksum string `json:"checksum" enum:"verified,mismatch,unverified" doc:"the outcome of checksum verification for the file"`
+}
+
+// a response for a delivery verification request (POST)
+type VerificationResponse struct {
+	// transfer ID
+	Id string `json:"id" doc:"the UUID for the transfer"`
+	// destination database and folder
+	Destination       string `json:"destination" doc:"the database to which files were transferred"`
+	DestinationFolder string `json:"destination_folder" doc:"the folder holding the delivered files at the destination endpoint"`
+	// time of verification
+	Time string `json:"time" format:"date-time" doc:"the time at which the verification was performed"`
+	// true if all files are present and no checksums are mismatched
+	Passed bool `json:"passed" doc:"true if all delivered files are present and no checksums are mismatched"`
+	// statistics
+	NumFiles      int `json:"num_files" doc:"the number of delivered files"`
+	NumMissing    int `json:"num_missing" doc:"the number of files missing from the destination"`
+	NumMismatched int `json:"num_mismatched" doc:"the number of files whose checksums don't match the manifest"`
+	NumUnverified int `json:"num_unverified" doc:"the number of files whose checksums couldn't be verified"`
+	// per-file statuses
+	Files []FileVerificationResponse `json:"files" doc:"verification statuses for delivered files"`
+}
+
+// a request for a bandwidth test between two endpoints (POST)
+type BandwidthTestRequest struct {
+	// name of the source endpoint
+	Source string `json:"source,omitempty" example:"globus-jdp" doc:"the name of the source endpoint (defaults to the DTS's own endpoint)"`
+	// name of the destination endpoint
+	Destination string `json:"destination" example:"globus-kbase" doc:"the name of the destination endpoint"`
+	// size of the synthetic payload
+	Size int `json:"size,omitempty" minimum:"1" example:"100" doc:"the size of the synthetic test payload in megabytes (default: 100)"`
+	// time after which the test is abandoned
+	Timeout int `json:"timeout,omitempty" minimum:"1" example:"600" doc:"the time in seconds after which the test is abandoned (default: 600)"`
+}
+
+// a response for a bandwidth test request (POST)
+type BandwidthTestResponse struct {
+	// names of the source and destination endpoints
+	Source      string `json:"source" doc:"the name of the source endpoint"`
+	Destination string `json:"destination" doc:"the name of the destination endpoint"`
+	// size of the synthetic payload (bytes)
+	PayloadSize int64 `json:"payload_size" doc:"the size of the synthetic test payload in bytes"`
+	// time taken to accept the transfer request (seconds)
+	Latency float64 `json:"latency" doc:"the time in seconds taken by the source endpoint to accept the transfer"`
+	// time taken to complete the transfer (seconds)
+	Duration float64 `json:"duration" doc:"the time in seconds taken to complete the transfer (accurate to within the polling interval)"`
+	// achieved throughput (bytes per second)
+	Throughput float64 `json:"throughput" doc:"the achieved throughput in bytes per second"`
+}
+
+// a request to inject a fault into a transfer for chaos testing (POST)
+type FaultInjectionRequest struct {
+	// the kind of fault to inject
+	Fault string `json:"fault" enum:"staging_timeout,submission_failure,token_expiry" doc:"the kind of fault to inject"`
+	// number of times the fault is triggered
+	Count int `json:"count,omitempty" minimum:"0" doc:"the number of times the fault is triggered (default: 0, every time until cleared)"`
+}
+
+// a response for a fault injection request (POST, DELETE)
+type FaultsResponse struct {
+	// transfer ID
+	Id string `json:"id" doc:"the UUID for the transfer"`
+	// faults pending for the transfer
+	Faults []string `json:"faults" doc:"the faults currently injected into the transfer"`
+}
+
+// a request to post a service-wide notice (POST)
+type NoticeRequest struct {
+	// text of the announcement
+	Message string `json:"message" example:"NERSC maintenance Saturday; transfers will queue" doc:"the text of the announcement"`
+	// severity of the notice
+	Severity string `json:"severity,omitempty" enum:"info,warning,critical" example:"warning" doc:"the severity of the notice (default: info)"`
+	// times bounding the notice's visibility
+	Start time.Time `json:"start,omitempty" doc:"the time at which the notice becomes visible (default: immediately)"`
+	End   time.Time `json:"end,omitempty" doc:"the time at which the notice expires (default: never)"`
+}
+
+// a service-wide notice (GET, POST)
+type NoticeResponse struct {
+	// notice ID
+	Id string `json:"id" doc:"the UUID for the notice"`
+	// text of the announcement
+	Message string `json:"message" doc:"the text of the announcement"`
+	// severity of the notice
+	Severity string `json:"severity" doc:"the severity of the notice (info, warning, or critical)"`
+	// times bounding the notice's visibility
+	Start *time.Time `json:"start,omitempty" doc:"the time at which the notice becomes visible (if any)"`
+	End   *time.Time `json:"end,omitempty" doc:"the time at which the notice expires (if any)"`
+}
+
+// a response for a notice listing request (GET)
+type NoticeListResponse struct {
+	// active notices
+	Notices []NoticeResponse `json:"notices" doc:"an array of notices currently in effect, most recent first"`
 }
 
 // TransferService defines the interface for our data transfer service.
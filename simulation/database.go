@@ -0,0 +1,205 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package simulation
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+// number of results returned by any simulated search
+const numSearchResults = 25
+
+// a simulated database whose files are synthesized from their IDs: every ID
+// refers to a file except those containing the word "missing"
+type Database struct {
+	// name of the database in the configuration
+	Name string
+	// simulated staging requests
+	StagingRequests map[uuid.UUID]StagingRequest
+	mutex           sync.Mutex
+}
+
+// a record of a simulated staging request
+type StagingRequest struct {
+	FileIds []string
+	Time    time.Time
+}
+
+// creates a simulated database with the given name in the configuration
+func NewDatabase(dbName string) (databases.Database, error) {
+	if _, found := config.Databases[dbName]; !found {
+		return nil, &databases.NotFoundError{Database: dbName}
+	}
+	return &Database{
+		Name:            dbName,
+		StagingRequests: make(map[uuid.UUID]StagingRequest),
+	}, nil
+}
+
+func (db *Database) SpecificSearchParameters() map[string]any {
+	return nil
+}
+
+func (db *Database) Search(orcid string, params databases.SearchParameters) (databases.SearchResults, error) {
+	delay()
+	ids := make([]string, numSearchResults)
+	queryHash := hashIndex(params.Query, 1<<32)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s:sim-%08x-%d", db.Name, queryHash, i+1)
+	}
+	offset := min(params.Pagination.Offset, len(ids))
+	ids = ids[offset:]
+	if params.Pagination.MaxNum > 0 && params.Pagination.MaxNum < len(ids) {
+		ids = ids[:params.Pagination.MaxNum]
+	}
+	results := databases.SearchResults{
+		Descriptors: make([]map[string]any, len(ids)),
+	}
+	for i, id := range ids {
+		results.Descriptors[i] = db.descriptor(id)
+	}
+	return results, nil
+}
+
+func (db *Database) Descriptors(orcid string, fileIds []string) ([]map[string]any, error) {
+	delay()
+	descriptors := make([]map[string]any, 0, len(fileIds))
+	var missing []string
+	for _, id := range fileIds {
+		if strings.Contains(id, "missing") {
+			missing = append(missing, id)
+		} else {
+			descriptors = append(descriptors, db.descriptor(id))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &databases.ResourcesNotFoundError{
+			Database:    db.Name,
+			ResourceIds: missing,
+		}
+	}
+	return descriptors, nil
+}
+
+func (db *Database) StageFiles(orcid string, fileIds []string) (uuid.UUID, error) {
+	delay()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	id := uuid.New()
+	db.StagingRequests[id] = StagingRequest{
+		FileIds: fileIds,
+		Time:    time.Now(),
+	}
+	return id, nil
+}
+
+func (db *Database) StagingStatus(id uuid.UUID) (databases.StagingStatus, error) {
+	delay()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	request, found := db.StagingRequests[id]
+	if !found {
+		return databases.StagingStatusUnknown, nil
+	}
+	simulation := config.Service.Simulation
+	if time.Since(request.Time) < time.Duration(simulation.StagingDuration)*time.Millisecond {
+		return databases.StagingStatusActive, nil
+	}
+	if fails(simulation.StagingFailureRate, request.FileIds) {
+		return databases.StagingStatusFailed, nil
+	}
+	return databases.StagingStatusSucceeded, nil
+}
+
+func (db *Database) Finalize(orcid string, id uuid.UUID) error {
+	delay()
+	return nil
+}
+
+func (db *Database) LocalUser(orcid string) (string, error) {
+	return "sim-" + orcid, nil
+}
+
+func (db *Database) Save() (databases.DatabaseSaveState, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(db.StagingRequests)
+	if err != nil {
+		return databases.DatabaseSaveState{}, err
+	}
+	return databases.DatabaseSaveState{
+		Name: db.Name,
+		Data: buffer.Bytes(),
+	}, nil
+}
+
+func (db *Database) Load(state databases.DatabaseSaveState) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	enc := gob.NewDecoder(bytes.NewReader(state.Data))
+	return enc.Decode(&db.StagingRequests)
+}
+
+// synthesizes a descriptor for the file with the given ID
+func (db *Database) descriptor(id string) map[string]any {
+	checksum := md5.Sum([]byte(id))
+	name := sanitize(id) + ".dat"
+	return map[string]any{
+		"id":        id,
+		"name":      name,
+		"path":      filepath.Join("simulated", db.Name, name),
+		"format":    "text",
+		"mediatype": "text/plain",
+		"bytes":     int(1024 + hashIndex(id, 1024*1024)),
+		"hash":      hex.EncodeToString(checksum[:]),
+		"endpoint":  db.endpoint(),
+	}
+}
+
+// returns the name of the (first) endpoint configured for the database
+func (db *Database) endpoint() string {
+	dbConfig := config.Databases[db.Name]
+	if dbConfig.Endpoint != "" {
+		return dbConfig.Endpoint
+	}
+	for _, key := range slices.Sorted(maps.Keys(dbConfig.Endpoints)) {
+		return dbConfig.Endpoints[key]
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package simulation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+)
+
+// a simulated endpoint, which has all files and transfers them in the
+// configured simulated transfer duration
+type Endpoint struct {
+	// name of the endpoint in the configuration
+	Name string
+	// simulated transfers
+	Xfers map[uuid.UUID]transfer
+	mutex sync.Mutex
+}
+
+type transfer struct {
+	Time     time.Time
+	NumFiles int
+	Fails    bool
+	Canceled bool
+}
+
+// creates a simulated endpoint with the given name in the configuration
+func NewEndpoint(endpointName string) (endpoints.Endpoint, error) {
+	if _, found := config.Endpoints[endpointName]; !found {
+		return nil, endpoints.NotFoundError{Name: endpointName}
+	}
+	return &Endpoint{
+		Name:  endpointName,
+		Xfers: make(map[uuid.UUID]transfer),
+	}, nil
+}
+
+func (ep *Endpoint) Provider() string {
+	return "simulation"
+}
+
+func (ep *Endpoint) Root() string {
+	return config.Endpoints[ep.Name].Root
+}
+
+func (ep *Endpoint) FilesStaged(files []any) (bool, error) {
+	delay()
+	return true, nil
+}
+
+func (ep *Endpoint) Transfers() ([]uuid.UUID, error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfers := make([]uuid.UUID, 0, len(ep.Xfers))
+	for id := range ep.Xfers {
+		xfers = append(xfers, id)
+	}
+	return xfers, nil
+}
+
+func (ep *Endpoint) Transfer(dst endpoints.Endpoint, files []endpoints.FileTransfer) (uuid.UUID, error) {
+	delay()
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.SourcePath
+	}
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	id := uuid.New()
+	ep.Xfers[id] = transfer{
+		Time:     time.Now(),
+		NumFiles: len(files),
+		Fails:    fails(config.Service.Simulation.TransferFailureRate, paths),
+	}
+	return id, nil
+}
+
+func (ep *Endpoint) Status(id uuid.UUID) (endpoints.TransferStatus, error) {
+	delay()
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	xfer, found := ep.Xfers[id]
+	if !found {
+		return endpoints.TransferStatus{}, fmt.Errorf("invalid transfer ID: %s", id.String())
+	}
+	status := endpoints.TransferStatus{
+		Code:     endpoints.TransferStatusActive,
+		NumFiles: xfer.NumFiles,
+	}
+	duration := time.Duration(config.Service.Simulation.TransferDuration) * time.Millisecond
+	elapsed := time.Since(xfer.Time)
+	if xfer.Canceled {
+		status.Code = endpoints.TransferStatusFailed
+		status.Message = "simulated transfer canceled"
+	} else if elapsed < duration {
+		status.NumFilesTransferred = int(float64(xfer.NumFiles) * elapsed.Seconds() / duration.Seconds())
+	} else if xfer.Fails {
+		status.Code = endpoints.TransferStatusFailed
+		status.Message = "simulated transfer failure"
+	} else {
+		status.Code = endpoints.TransferStatusSucceeded
+		status.NumFilesTransferred = xfer.NumFiles
+	}
+	return status, nil
+}
+
+func (ep *Endpoint) Cancel(id uuid.UUID) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if xfer, found := ep.Xfers[id]; found {
+		xfer.Canceled = true
+		ep.Xfers[id] = xfer
+	}
+	return nil
+}
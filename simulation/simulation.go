@@ -0,0 +1,111 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// This package implements simulation mode, in which the DTS replaces all of its
+// database connectors and endpoints with deterministic fakes, so that
+// downstream teams can integration-test against a DTS instance without real
+// Globus or database credentials. Simulated operations take configurable
+// amounts of time, and fail at configurable rates. Whether a given operation
+// fails is determined by the files involved and the simulation seed, so
+// repeating a request produces the same outcome.
+package simulation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+)
+
+// Registers simulated databases for all configured databases and simulated
+// endpoint providers for all configured endpoints. This must be called before
+// any real database or endpoint providers are registered.
+func Register() error {
+	slog.Info("Simulation mode enabled: databases and endpoints are simulated.")
+	providers := make(map[string]bool)
+	for _, endpointConfig := range config.Endpoints {
+		providers[endpointConfig.Provider] = true
+	}
+	for _, provider := range slices.Sorted(maps.Keys(providers)) {
+		err := endpoints.RegisterEndpointProvider(provider, NewEndpoint)
+		if err != nil {
+			return err
+		}
+	}
+	for _, dbName := range slices.Sorted(maps.Keys(config.Databases)) {
+		err := databases.RegisterDatabase(dbName, func() (databases.Database, error) {
+			return NewDatabase(dbName)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// pauses for the configured simulated latency
+func delay() {
+	time.Sleep(time.Duration(config.Service.Simulation.Latency) * time.Millisecond)
+}
+
+// returns true if a simulated operation identified by the given keys fails,
+// given a failure rate, deterministically for the configured seed
+func fails(rate float64, keys []string) bool {
+	if rate <= 0 {
+		return false
+	}
+	hasher := fnv.New64a()
+	fmt.Fprintf(hasher, "%d", config.Service.Simulation.Seed)
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "\x00%s", key)
+	}
+	return float64(hasher.Sum64()%1000000)/1000000.0 < rate
+}
+
+// returns a deterministic number in [0, n) derived from the given string
+func hashIndex(s string, n uint64) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(s))
+	return hasher.Sum64() % n
+}
+
+// returns the given ID with characters that are awkward in paths replaced
+func sanitize(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '/', '\\', ' ':
+			return '_'
+		default:
+			return r
+		}
+	}, id)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package simulation
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+)
+
+const orcid = "1234-5678-9012-3456"
+
+func TestSimulatedDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := databases.NewDatabase("source")
+	assert.Nil(err)
+
+	results, err := db.Search(orcid, databases.SearchParameters{
+		Query:      "soil",
+		Pagination: databases.SearchPaginationParameters{Offset: 5, MaxNum: 10},
+	})
+	assert.Nil(err)
+	assert.Len(results.Descriptors, 10)
+
+	// descriptors are synthesized deterministically from file IDs
+	ids := []string{results.Descriptors[0]["id"].(string), "source:file2"}
+	descriptors, err := db.Descriptors(orcid, ids)
+	assert.Nil(err)
+	assert.Equal(results.Descriptors[0], descriptors[0])
+	again, err := db.Descriptors(orcid, ids)
+	assert.Nil(err)
+	assert.Equal(descriptors, again)
+	assert.Equal("source-endpoint", descriptors[1]["endpoint"])
+
+	_, err = db.Descriptors(orcid, []string{"source:missing-file"})
+	assert.IsType(&databases.ResourcesNotFoundError{}, err)
+
+	// staging takes the configured time
+	stagingId, err := db.StageFiles(orcid, ids)
+	assert.Nil(err)
+	status, err := db.StagingStatus(stagingId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusActive, status)
+	time.Sleep(time.Duration(config.Service.Simulation.StagingDuration) * time.Millisecond)
+	status, err = db.StagingStatus(stagingId)
+	assert.Nil(err)
+	assert.Equal(databases.StagingStatusSucceeded, status)
+}
+
+func TestSimulatedEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	source, err := endpoints.NewEndpoint("source-endpoint")
+	assert.Nil(err)
+	destination, err := endpoints.NewEndpoint("destination-endpoint")
+	assert.Nil(err)
+
+	files := []endpoints.FileTransfer{
+		{SourcePath: "a.dat", DestinationPath: "a.dat"},
+		{SourcePath: "b.dat", DestinationPath: "b.dat"},
+	}
+	xferId, err := source.Transfer(destination, files)
+	assert.Nil(err)
+	status, err := source.Status(xferId)
+	assert.Nil(err)
+	assert.Equal(endpoints.TransferStatusActive, status.Code)
+	assert.Equal(2, status.NumFiles)
+
+	time.Sleep(time.Duration(config.Service.Simulation.TransferDuration) * time.Millisecond)
+	status, err = source.Status(xferId)
+	assert.Nil(err)
+	assert.Equal(endpoints.TransferStatusSucceeded, status.Code)
+	assert.Equal(2, status.NumFilesTransferred)
+}
+
+func TestFailureInjection(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := []string{"a.dat", "b.dat"}
+	assert.False(fails(0, keys))
+	assert.True(fails(1, keys))
+
+	// outcomes are deterministic for a given seed
+	for range 10 {
+		assert.Equal(fails(0.5, keys), fails(0.5, keys))
+	}
+
+	// and roughly match the failure rate
+	numFailures := 0
+	for i := range 1000 {
+		if fails(0.25, []string{string(rune('a' + i%26)), string(rune(i))}) {
+			numFailures++
+		}
+	}
+	assert.InDelta(250, numFailures, 60)
+}
+
+// this runs setup, runs all tests, and does breakdown
+func TestMain(m *testing.M) {
+	err := config.Init([]byte(simulationConfig))
+	if err != nil {
+		panic(err)
+	}
+	err = Register()
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+const simulationConfig string = `
+service:
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  endpoint: local-endpoint
+  simulation:
+    enabled: true
+    staging_duration: 50   # milliseconds
+    transfer_duration: 100 # milliseconds
+    seed: 42
+databases:
+  source:
+    name: Simulated Source Database
+    organization: The Source Company
+    endpoint: source-endpoint
+  destination:
+    name: Simulated Destination Database
+    organization: Fabulous Destinations, Inc.
+    endpoint: destination-endpoint
+endpoints:
+  local-endpoint:
+    name: Local endpoint
+    id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6
+    provider: local
+  source-endpoint:
+    name: Endpoint 1
+    id: 26d61236-39f6-4742-a374-8ec709347f2f
+    provider: globus
+  destination-endpoint:
+    name: Endpoint 2
+    id: f1865b86-2c64-4b8b-99f3-5aaa945ec3d9
+    provider: globus
+`
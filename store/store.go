@@ -0,0 +1,278 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+// This package persists the state of the task manager (transfers, their
+// subtasks, and the save states of databases) in an embedded key-value store
+// so that pending transfers survive a restart. Transfers and subtasks are
+// stored as individual JSON documents, so a record that can't be decoded
+// costs only that record and not the entire state, and the layout of the
+// store itself is versioned and migrated when the service starts.
+
+// a persisted transfer and its subtasks, each stored as an opaque JSON
+// document encoded (and decoded) by the task manager
+type Transfer struct {
+	// UUID identifying the transfer
+	Id uuid.UUID
+	// the transfer, excluding its subtasks
+	Data json.RawMessage
+	// the transfer's subtasks, in order
+	Subtasks []json.RawMessage
+}
+
+// the state of the task manager at a given moment
+type State struct {
+	// all pending and recently finished transfers
+	Transfers []Transfer
+	// save states for databases with transfer-related state
+	Databases databases.DatabaseSaveStates
+	// keys of records that couldn't be read (and were skipped) when the state
+	// was loaded
+	Skipped []string
+}
+
+// opens the store (if it's not already open), migrating its contents from
+// earlier schema versions as needed
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ != nil {
+		return nil
+	}
+
+	dbPath := filepath.Join(config.Service.DataDirectory, "dts.db")
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &CantOpenError{Message: err.Error()}
+	}
+	if err = db.Update(migrate); err != nil {
+		db.Close()
+		if _, unsupported := err.(*UnsupportedVersionError); unsupported {
+			return err
+		}
+		return &CantOpenError{Message: err.Error()}
+	}
+	db_ = db
+	return nil
+}
+
+// closes the store (if it's been opened)
+func Finalize() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return nil
+	}
+	err := db_.Close()
+	db_ = nil
+	return err
+}
+
+// returns true if the store is open, false if not
+func IsOpen() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return db_ != nil
+}
+
+// replaces the contents of the store with the given state in a single
+// transaction, so a failure leaves the previously saved state intact
+func Save(state State) error {
+	return update(func(tx *bolt.Tx) error {
+		for _, name := range []string{transfersBucket, subtasksBucket, databasesBucket} {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		transfers := tx.Bucket([]byte(transfersBucket))
+		subtasks := tx.Bucket([]byte(subtasksBucket))
+		for _, transfer := range state.Transfers {
+			if err := transfers.Put([]byte(transfer.Id.String()), transfer.Data); err != nil {
+				return err
+			}
+			for i, subtask := range transfer.Subtasks {
+				if err := subtasks.Put(subtaskKey(transfer.Id, i), subtask); err != nil {
+					return err
+				}
+			}
+		}
+		databaseStates := tx.Bucket([]byte(databasesBucket))
+		for key, databaseState := range state.Databases.Data {
+			value, err := json.Marshal(databaseState)
+			if err != nil {
+				return err
+			}
+			if err := databaseStates.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// retrieves the state saved in the store (which is empty if nothing has been
+// saved). A record that can't be read is skipped (and its key listed in the
+// state) without affecting the others.
+func Load() (State, error) {
+	state := State{
+		Databases: databases.DatabaseSaveStates{
+			Data: make(map[string]databases.DatabaseSaveState),
+		},
+	}
+	err := view(func(tx *bolt.Tx) error {
+		subtasks := tx.Bucket([]byte(subtasksBucket)).Cursor()
+		err := tx.Bucket([]byte(transfersBucket)).ForEach(func(key, value []byte) error {
+			id, err := uuid.ParseBytes(key)
+			if err != nil {
+				state.Skipped = append(state.Skipped, transfersBucket+"/"+string(key))
+				return nil
+			}
+			transfer := Transfer{
+				Id:       id,
+				Data:     bytes.Clone(value),
+				Subtasks: make([]json.RawMessage, 0),
+			}
+			prefix := subtaskPrefix(id)
+			for k, v := subtasks.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = subtasks.Next() {
+				transfer.Subtasks = append(transfer.Subtasks, bytes.Clone(v))
+			}
+			state.Transfers = append(state.Transfers, transfer)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(databasesBucket)).ForEach(func(key, value []byte) error {
+			var databaseState databases.DatabaseSaveState
+			if err := json.Unmarshal(value, &databaseState); err != nil {
+				state.Skipped = append(state.Skipped, databasesBucket+"/"+string(key))
+				return nil
+			}
+			state.Databases.Data[string(key)] = databaseState
+			return nil
+		})
+	})
+	return state, err
+}
+
+//-----------
+// Internals
+//-----------
+
+const (
+	metaBucket      = "meta"
+	transfersBucket = "transfers"
+	subtasksBucket  = "subtasks"
+	databasesBucket = "databases"
+)
+
+// the key under which the schema version is stored in the meta bucket
+const schemaVersionKey = "schema_version"
+
+// migrations[v] migrates the store from schema version v to v+1, so the
+// current schema version is len(migrations). When the layout of the store
+// changes, append a migration--never modify an existing one.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: buckets for transfers, subtasks, and database save states
+	func(tx *bolt.Tx) error {
+		for _, name := range []string{transfersBucket, subtasksBucket, databasesBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var db_ *bolt.DB
+var mutex_ sync.Mutex
+
+// brings the store up to the current schema version within the given
+// transaction
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+	version := 0
+	if value := meta.Get([]byte(schemaVersionKey)); len(value) == 4 {
+		version = int(binary.BigEndian.Uint32(value))
+	}
+	if version > len(migrations) {
+		return &UnsupportedVersionError{Version: version, Current: len(migrations)}
+	}
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](tx); err != nil {
+			return err
+		}
+	}
+	return meta.Put([]byte(schemaVersionKey), binary.BigEndian.AppendUint32(nil, uint32(version)))
+}
+
+// runs the given function in a read-only transaction on the store
+func view(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.View(f)
+}
+
+// runs the given function in a read-write transaction on the store
+func update(f func(tx *bolt.Tx) error) error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if db_ == nil {
+		return &NotOpenError{}
+	}
+	return db_.Update(f)
+}
+
+// subtask keys have the form "<transfer ID>\x00<index>", with the index
+// encoded big-endian so that a prefix scan yields a transfer's subtasks in
+// order
+func subtaskPrefix(id uuid.UUID) []byte {
+	return append([]byte(id.String()), 0)
+}
+
+func subtaskKey(id uuid.UUID, index int) []byte {
+	return binary.BigEndian.AppendUint32(subtaskPrefix(id), uint32(index))
+}
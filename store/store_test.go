@@ -0,0 +1,201 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	first, second := uuid.New(), uuid.New()
+	subtasks := make([]json.RawMessage, 12) // enough to check ordering
+	for i := range subtasks {
+		subtasks[i] = json.RawMessage(`{"index":` + strings.Repeat("1", i+1) + `}`)
+	}
+	err := Save(State{
+		Transfers: []Transfer{
+			{Id: first, Data: json.RawMessage(`{"name":"first"}`), Subtasks: subtasks},
+			{Id: second, Data: json.RawMessage(`{"name":"second"}`)},
+		},
+		Databases: databases.DatabaseSaveStates{
+			Data: map[string]databases.DatabaseSaveState{
+				"jdp": {Name: "jdp", Data: []byte{1, 2, 3}},
+			},
+		},
+	})
+	assert.Nil(err)
+
+	state, err := Load()
+	assert.Nil(err)
+	assert.Len(state.Transfers, 2)
+	for _, transfer := range state.Transfers {
+		if transfer.Id == first {
+			assert.JSONEq(`{"name":"first"}`, string(transfer.Data))
+			assert.Equal(subtasks, transfer.Subtasks)
+		} else {
+			assert.Equal(second, transfer.Id)
+			assert.Empty(transfer.Subtasks)
+		}
+	}
+	assert.Equal([]byte{1, 2, 3}, state.Databases.Data["jdp"].Data)
+
+	// saving replaces everything that was saved before
+	err = Save(State{Transfers: []Transfer{{Id: second, Data: json.RawMessage(`{}`)}}})
+	assert.Nil(err)
+	state, err = Load()
+	assert.Nil(err)
+	assert.Len(state.Transfers, 1)
+	assert.Equal(second, state.Transfers[0].Id)
+	assert.Empty(state.Databases.Data)
+
+	// unreadable records are skipped
+	err = update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(transfersBucket)).Put([]byte("not-a-uuid"), []byte(`{}`)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(databasesBucket)).Put([]byte("jdp"), []byte("garbage"))
+	})
+	assert.Nil(err)
+	state, err = Load()
+	assert.Nil(err)
+	assert.Len(state.Transfers, 1)
+	assert.Empty(state.Databases.Data)
+	assert.ElementsMatch([]string{"transfers/not-a-uuid", "databases/jdp"}, state.Skipped)
+}
+
+func TestSchemaVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	// the store is migrated to the current schema version
+	var version uint32
+	err := view(func(tx *bolt.Tx) error {
+		version = binary.BigEndian.Uint32(tx.Bucket([]byte(metaBucket)).Get([]byte(schemaVersionKey)))
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(uint32(len(migrations)), version)
+
+	// a store written by a newer version of the service is rejected
+	err = update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(metaBucket)).Put([]byte(schemaVersionKey),
+			binary.BigEndian.AppendUint32(nil, version+1))
+	})
+	assert.Nil(err)
+	Finalize()
+	err = Init()
+	assert.IsType(&UnsupportedVersionError{}, err)
+	assert.False(IsOpen())
+
+	// restore the current version so the store can be reopened
+	db, err := bolt.Open(config.Service.DataDirectory+"/dts.db", 0600, &bolt.Options{Timeout: time.Second})
+	assert.Nil(err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(metaBucket)).Put([]byte(schemaVersionKey),
+			binary.BigEndian.AppendUint32(nil, version))
+	})
+	assert.Nil(err)
+	db.Close()
+	err = Init()
+	assert.Nil(err)
+}
+
+func TestNotOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	Finalize()
+	_, err := Load()
+	assert.IsType(&NotOpenError{}, err)
+	err = Save(State{})
+	assert.IsType(&NotOpenError{}, err)
+	err = Init()
+	assert.Nil(err)
+}
+
+// This runs setup, runs all tests, and does breakdown.
+func TestMain(m *testing.M) {
+	var status int
+	setup()
+	status = m.Run()
+	breakdown()
+	os.Exit(status)
+}
+
+// this function gets called at the beginning of a test session
+func setup() {
+	var err error
+	TESTING_DIR, err = os.MkdirTemp(os.TempDir(), "data-transfer-service-tests-")
+	if err != nil {
+		log.Panicf("Couldn't create testing directory: %s", err)
+	}
+
+	myConfig := strings.ReplaceAll(storeConfig, "TESTING_DIR", TESTING_DIR)
+	err = config.InitSelected([]byte(myConfig), true, false, false, false)
+	if err != nil {
+		log.Panicf("Couldn't initialize configuration: %s", err)
+	}
+	err = os.Mkdir(config.Service.DataDirectory, 0755)
+	if err != nil {
+		log.Panicf("Couldn't create data directory: %s", err)
+	}
+	err = Init()
+	if err != nil {
+		log.Panicf("Couldn't open task store: %s", err)
+	}
+}
+
+// this function gets called after all tests have been run
+func breakdown() {
+	Finalize()
+	if TESTING_DIR != "" {
+		os.RemoveAll(TESTING_DIR)
+	}
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// configuration
+const storeConfig string = `
+service:
+  name: test
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+`
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Work that can take a while (requests to slow services, checksums of large
+// files) is done in the background so it doesn't hold up a worker: the task
+// that needs it checks on it each time it's updated, and picks up its result
+// once it's finished.
+
+// the result of a background operation
+type backgroundResult[T any] struct {
+	Value T
+	Error error
+}
+
+// background operations in progress (channels of backgroundResults), by task
+// ID and operation
+var backgroundOperations = make(map[string]any)
+var backgroundMutex sync.Mutex
+
+// starts the given operation for the task with the given ID in the background
+// if it's not already in progress, returning its result and true if it has
+// finished, or false if it's still in progress
+func inBackground[T any](taskId uuid.UUID, operation string,
+	work func() (T, error)) (backgroundResult[T], bool) {
+	key := taskId.String() + "/" + operation
+	backgroundMutex.Lock()
+	defer backgroundMutex.Unlock()
+	results, found := backgroundOperations[key].(chan backgroundResult[T])
+	if !found {
+		results = make(chan backgroundResult[T], 1)
+		backgroundOperations[key] = results
+		go func() {
+			value, err := work()
+			results <- backgroundResult[T]{Value: value, Error: err}
+		}()
+	}
+	select {
+	case result := <-results:
+		delete(backgroundOperations, key)
+		return result, true
+	default:
+		return backgroundResult[T]{}, false
+	}
+}
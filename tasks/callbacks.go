@@ -0,0 +1,203 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/webhooks"
+)
+
+// A task created with a callback URL has an event POSTed to that URL each time
+// its status changes, so clients needn't poll for it. Each event is a JSON
+// object signed with the service's callback secret (see the webhooks package).
+// Each callback URL has its own goroutine delivering its events in order, so a
+// slow or unresponsive URL delays only its own events, and an event whose
+// delivery fails is retried a few times before it's dropped.
+
+// this type describes a change in the status of a transfer task, as POSTed
+// to the task's callback URL
+type CallbackEvent struct {
+	// the task's identifier
+	Id uuid.UUID `json:"id"`
+	// the event: staging, active, finalizing, succeeded, failed, canceled, or
+	// expired
+	Event string `json:"event"`
+	// a message describing the task's status (if any)
+	Message string `json:"message,omitempty"`
+	// the number of files being transferred
+	NumFiles int `json:"num_files"`
+	// the number of files that have been completely transferred
+	NumFilesTransferred int `json:"num_files_transferred"`
+	// the DOI minted for the delivered payload (if any)
+	DOI string `json:"doi,omitempty"`
+	// the time at which the event occurred
+	Time time.Time `json:"time"`
+}
+
+//-----------
+// Internals
+//-----------
+
+// a callback event and the URL to which it's delivered
+type callbackDelivery struct {
+	URL   string
+	Event CallbackEvent
+}
+
+// the number of times delivery of a callback event is attempted, and the time
+// between attempts
+const maxCallbackAttempts = 3
+
+var callbackRetryInterval = 5 * time.Second
+
+// the number of events awaiting delivery to a single callback URL beyond which
+// further events for that URL are dropped
+const maxQueuedCallbacks = 64
+
+// returns the callback event corresponding to the task's status, or "" if
+// its status doesn't correspond to an event
+func (task transferTask) callbackEvent() string {
+	switch task.Status.Code {
+	case TransferStatusStaging:
+		return "staging"
+	case TransferStatusActive:
+		return "active"
+	case TransferStatusFinalizing:
+		return "finalizing"
+	case TransferStatusSucceeded:
+		return "succeeded"
+	case TransferStatusFailed:
+		if task.Expired {
+			return "expired"
+		}
+		if task.Canceled {
+			return "canceled"
+		}
+		return "failed"
+	default:
+		return ""
+	}
+}
+
+// queues the callback event corresponding to the task's status for delivery
+// to its callback URL (if it has one), without waiting for it to be delivered
+func (task transferTask) queueCallback(callbacks chan<- callbackDelivery) {
+	event := task.callbackEvent()
+	if task.CallbackURL == "" || event == "" {
+		return
+	}
+	delivery := callbackDelivery{
+		URL: task.CallbackURL,
+		Event: CallbackEvent{
+			Id:                  task.Id,
+			Event:               event,
+			Message:             task.Status.Message,
+			NumFiles:            task.Status.NumFiles,
+			NumFilesTransferred: task.Status.NumFilesTransferred,
+			DOI:                 task.DOI,
+			Time:                time.Now(),
+		},
+	}
+	select {
+	case callbacks <- delivery:
+	default:
+		slog.Warn(fmt.Sprintf("Task %s: callback queue full; dropped %s event",
+			task.Id.String(), event))
+	}
+}
+
+// this function runs in its own goroutine, dispatching callback events to
+// per-URL delivery goroutines until its channel is closed
+func deliverCallbacks(callbacks <-chan callbackDelivery) {
+	var mutex sync.Mutex // guards queues
+	queues := make(map[string]chan callbackDelivery)
+	var delivering sync.WaitGroup
+	for delivery := range callbacks {
+		mutex.Lock()
+		queue, found := queues[delivery.URL]
+		if !found {
+			queue = make(chan callbackDelivery, maxQueuedCallbacks)
+			queues[delivery.URL] = queue
+			delivering.Add(1)
+			go func(url string) { // delivers events until its queue is empty
+				defer delivering.Done()
+				for {
+					mutex.Lock()
+					if len(queue) == 0 {
+						delete(queues, url)
+						mutex.Unlock()
+						return
+					}
+					mutex.Unlock()
+					deliverCallback(<-queue)
+				}
+			}(delivery.URL)
+		}
+		select {
+		case queue <- delivery:
+		default:
+			slog.Warn(fmt.Sprintf("Task %s: too many undelivered events for %s; dropped %s event",
+				delivery.Event.Id.String(), delivery.URL, delivery.Event.Event))
+		}
+		mutex.Unlock()
+	}
+	delivering.Wait()
+}
+
+// delivers a callback event, retrying failed attempts unless the URL itself
+// is refused
+func deliverCallback(delivery callbackDelivery) {
+	var err error
+	for attempt := 1; attempt <= maxCallbackAttempts; attempt++ {
+		if err = postCallback(delivery); err == nil {
+			return
+		}
+		var invalidURL *webhooks.InvalidURLError
+		if errors.As(err, &invalidURL) {
+			break
+		}
+		if attempt < maxCallbackAttempts {
+			time.Sleep(callbackRetryInterval)
+		}
+	}
+	slog.Warn(fmt.Sprintf("Task %s: couldn't deliver %s event to %s: %s",
+		delivery.Event.Id.String(), delivery.Event.Event, delivery.URL, err.Error()))
+}
+
+// POSTs a signed callback event to its URL
+func postCallback(delivery callbackDelivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	return webhooks.Post(delivery.URL, body, map[string]string{
+		"X-DTS-Event": delivery.Event.Event,
+	})
+}
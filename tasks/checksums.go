@@ -0,0 +1,142 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+)
+
+// Alongside its manifest, a task delivers a file listing the checksums of the
+// files in its payload for each hash algorithm used (e.g. "md5sums.txt",
+// "sha256sums.txt"), in the format read by md5sum -c, sha256sum -c, etc, so
+// that receiving sites can validate the payload with standard tools.
+
+// returns the name of the file (in the destination folder) that lists the
+// checksums computed with the given hash algorithm
+func checksumFileName(algorithm string) string {
+	return algorithm + "sums.txt"
+}
+
+// returns a line for a checksum file listing the given checksum for the file
+// with the given path, escaped as GNU coreutils does if needed
+func checksumLine(checksum, path string) string {
+	if strings.ContainsAny(path, "\\\n\r") {
+		path = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(path)
+		return fmt.Sprintf("\\%s  %s", checksum, path)
+	}
+	return fmt.Sprintf("%s  %s", checksum, path)
+}
+
+// returns the lines of the checksum files for a task's delivered payload,
+// mapped to their hash algorithms -- files whose checksums weren't verified
+// (including those of tasks that skip checksums) aren't listed
+func (task *transferTask) checksumLines() map[string][]string {
+	lines := make(map[string][]string)
+	if task.SkipChecksums || task.MetadataOnly {
+		return lines
+	}
+	for i := range task.Subtasks {
+		subtask := &task.Subtasks[i]
+		for _, d := range subtask.Descriptors {
+			descriptor, err := fileDescriptor(d)
+			if err != nil || descriptor.Recursive {
+				continue
+			}
+			if _, skipped := subtask.SkippedFiles[descriptor.Id]; skipped {
+				continue
+			}
+			if _, corrupt := subtask.CorruptFiles[descriptor.Id]; corrupt {
+				continue
+			}
+			value, algorithm := parseHash(descriptor.Hash)
+			if value == "" || !config.HashAlgorithmAllowed(algorithm) {
+				continue
+			}
+			lines[algorithm] = append(lines[algorithm],
+				checksumLine(value, subtask.destinationPath(descriptor.Path)))
+		}
+	}
+	return lines
+}
+
+// writes the checksum files for a task's payload to the manifest directory,
+// recording their names in the task and returning transfers that deliver them
+// alongside the manifest
+func (task *transferTask) writeChecksumFiles() ([]FileTransfer, error) {
+	task.removeChecksumFiles()
+	var fileXfers []FileTransfer
+	lines := task.checksumLines()
+	for _, algorithm := range slices.Sorted(maps.Keys(lines)) {
+		slices.Sort(lines[algorithm])
+		checksumFile := filepath.Join(config.Service.ManifestDirectory,
+			fmt.Sprintf("checksums-%s-%s.txt", task.Id.String(), algorithm))
+		content := strings.Join(lines[algorithm], "\n") + "\n"
+		if err := os.WriteFile(checksumFile, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("creating checksum file: %s", err.Error())
+		}
+		if task.ChecksumFiles == nil {
+			task.ChecksumFiles = make(map[string]string)
+		}
+		task.ChecksumFiles[algorithm] = checksumFile
+		fileXfers = append(fileXfers, FileTransfer{
+			SourcePath:      checksumFile,
+			DestinationPath: filepath.Join(task.DestinationFolder, checksumFileName(algorithm)),
+		})
+	}
+	return fileXfers, nil
+}
+
+// removes the checksum files written for a task
+func (task *transferTask) removeChecksumFiles() {
+	for _, checksumFile := range task.ChecksumFiles {
+		os.Remove(checksumFile)
+	}
+	task.ChecksumFiles = nil
+}
+
+// removes the checksum files delivered to the given destination endpoint with
+// a retracted manifest (before removing the local copies)
+func (task *transferTask) retractChecksumFiles(destination endpoints.Endpoint) {
+	for _, algorithm := range slices.Sorted(maps.Keys(task.ChecksumFiles)) {
+		path := filepath.Join(task.DestinationFolder, checksumFileName(algorithm))
+		var err error
+		if deleter, ok := destination.(endpoints.DeletingEndpoint); ok {
+			err = deleter.Delete(path)
+		} else {
+			err = fmt.Errorf("destination endpoint can't delete files")
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Task %s: couldn't remove checksum file %s: %s", task.Id.String(),
+				path, err.Error()))
+		}
+	}
+	task.removeChecksumFiles()
+}
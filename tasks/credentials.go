@@ -0,0 +1,115 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/units"
+)
+
+// this type describes the expiration of an upstream credential
+type CredentialStatus struct {
+	// the name of the credential (in the DTS config file)
+	Name string
+	// the time at which the credential expires (zero if unknown)
+	Expires time.Time
+	// true if the credential expires within the configured warning period
+	Expiring bool
+	// true if the credential has expired
+	Expired bool
+}
+
+// Returns the expiration statuses of all configured credentials, sorted by
+// name.
+func Credentials() []CredentialStatus {
+	expirations := make(map[string]time.Time)
+	for name, credential := range config.Credentials {
+		expirations[name] = credential.Expires
+	}
+	warning := time.Duration(config.Service.CredentialExpiryWarning) * time.Second
+	return credentialStatuses(expirations, warning)
+}
+
+//-----------
+// Internals
+//-----------
+
+// interval at which credential expirations are checked
+const credentialCheckInterval = 12 * time.Hour
+
+// this function runs in its own goroutine, alerting administrators (via the
+// log) to credentials that have expired or will expire soon, until tasks are
+// no longer being processed
+func monitorCredentials(interval time.Duration) {
+	for {
+		checkCredentials(Credentials())
+		time.Sleep(interval)
+		if !running {
+			break
+		}
+	}
+}
+
+// returns the statuses of credentials with the given expiration times (by
+// name), given the period before expiration in which they are considered to be
+// expiring
+func credentialStatuses(expirations map[string]time.Time, warning time.Duration) []CredentialStatus {
+	now := time.Now()
+	statuses := make([]CredentialStatus, 0, len(expirations))
+	for _, name := range slices.Sorted(maps.Keys(expirations)) {
+		status := CredentialStatus{
+			Name:    name,
+			Expires: expirations[name],
+		}
+		if !status.Expires.IsZero() {
+			status.Expired = !now.Before(status.Expires)
+			status.Expiring = !status.Expired && now.Add(warning).After(status.Expires)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// logs alerts for the given credentials that have expired or will expire soon,
+// returning the number of such credentials
+func checkCredentials(statuses []CredentialStatus) int {
+	numAlerts := 0
+	for _, status := range statuses {
+		if status.Expired {
+			slog.Error(fmt.Sprintf("Credential %s expired at %s and must be renewed",
+				status.Name, status.Expires.Format(time.RFC3339)))
+			numAlerts++
+		} else if status.Expiring {
+			slog.Warn(fmt.Sprintf("Credential %s expires at %s (in %s) and should be renewed",
+				status.Name, status.Expires.Format(time.RFC3339),
+				units.FormatDuration(time.Until(status.Expires))))
+			numAlerts++
+		}
+	}
+	return numAlerts
+}
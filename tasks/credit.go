@@ -0,0 +1,168 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/frictionless"
+)
+
+// distinct credit metadata gathered from the file descriptors in a payload,
+// in order of appearance
+type payloadCredit struct {
+	Contributors []credit.Contributor
+	Licenses     []credit.License
+	Funding      []credit.FundingReference
+	Sources      []string // URLs (or identifiers) of the files' source resources
+}
+
+// returns the credit metadata in the given file descriptor (which may have
+// been decoded from JSON), and true if it has any
+func descriptorCredit(descriptor map[string]any) (credit.CreditMetadata, bool) {
+	switch c := descriptor["credit"].(type) {
+	case credit.CreditMetadata:
+		return c, true
+	case map[string]any: // (decoded from JSON)
+		var metadata credit.CreditMetadata
+		data, err := json.Marshal(c)
+		if err == nil {
+			err = json.Unmarshal(data, &metadata)
+		}
+		return metadata, err == nil
+	default:
+		return credit.CreditMetadata{}, false
+	}
+}
+
+// gathers the distinct contributors, licenses, funders, and sources named in
+// the credit metadata of the given file descriptors
+func gatherCredit(descriptors []map[string]any) payloadCredit {
+	var gathered payloadCredit
+	seen := make(map[string]bool)
+	firstSighting := func(kind, key string) bool {
+		if key == "" || seen[kind+"\x00"+key] {
+			return false
+		}
+		seen[kind+"\x00"+key] = true
+		return true
+	}
+	for _, descriptor := range descriptors {
+		for _, license := range descriptorLicenses(descriptor) {
+			if firstSighting("license", licenseURL(license)) {
+				gathered.Licenses = append(gathered.Licenses, license)
+			}
+		}
+		metadata, found := descriptorCredit(descriptor)
+		if !found {
+			continue
+		}
+		for _, contributor := range metadata.Contributors {
+			key := contributor.ContributorId
+			if key == "" {
+				key = contributor.Name + "\x00" + contributor.GivenName + "\x00" + contributor.FamilyName
+			}
+			if firstSighting("contributor", key) {
+				gathered.Contributors = append(gathered.Contributors, contributor)
+			}
+		}
+		for _, funding := range metadata.Funding {
+			if firstSighting("funder", funding.Funder.OrganizationName+"\x00"+funding.GrantId) {
+				gathered.Funding = append(gathered.Funding, funding)
+			}
+		}
+		source := metadata.Url
+		if source == "" {
+			source = metadata.Identifier
+		}
+		if firstSighting("source", source) {
+			gathered.Sources = append(gathered.Sources, source)
+		}
+	}
+	return gathered
+}
+
+// returns the URL of the given license (at spdx.org for a license given only
+// by its SPDX identifier), or "" if the license is empty
+func licenseURL(license credit.License) string {
+	if license.Url != "" {
+		return license.Url
+	}
+	if license.Id != "" {
+		return "https://spdx.org/licenses/" + license.Id + ".html"
+	}
+	return ""
+}
+
+// returns the licenses of the file with the given descriptor: the one named in
+// its credit metadata, or otherwise those listed in its "licenses" field
+func descriptorLicenses(descriptor map[string]any) []credit.License {
+	if metadata, found := descriptorCredit(descriptor); found && licenseURL(metadata.License) != "" {
+		return []credit.License{metadata.License}
+	}
+	var licenses []credit.License
+	for _, license := range frictionless.Licenses(descriptor) {
+		licenses = append(licenses, credit.License{Id: license.Name, Url: license.Path})
+	}
+	return licenses
+}
+
+// lists licenses in the "licenses" fields of the given file descriptors that
+// have none, using those in their credit metadata or else the given default
+// license (if any)
+func fillLicenses(descriptors []map[string]any, defaultLicense string) {
+	for _, descriptor := range descriptors {
+		if len(frictionless.Licenses(descriptor)) > 0 {
+			continue
+		}
+		license := frictionless.ParseLicense(defaultLicense)
+		if metadata, found := descriptorCredit(descriptor); found {
+			if metadata.License.Url != "" {
+				license = frictionless.ParseLicense(metadata.License.Url)
+			} else if metadata.License.Id != "" {
+				license = frictionless.ParseLicense(metadata.License.Id)
+			}
+		}
+		if license != (frictionless.License{}) {
+			frictionless.SetLicenses(descriptor, license)
+		}
+	}
+}
+
+// returns the distinct licenses listed in the given manifest resources, in
+// order of appearance, for the manifest's own "licenses" field
+func manifestLicenses(resources []any) []any {
+	var licenses []any
+	seen := make(map[frictionless.License]bool)
+	for _, resource := range resources {
+		if descriptor, ok := resource.(map[string]any); ok {
+			for _, license := range frictionless.Licenses(descriptor) {
+				if !seen[license] {
+					seen[license] = true
+					licenses = append(licenses, license.Map())
+				}
+			}
+		}
+	}
+	return licenses
+}
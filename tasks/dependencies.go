@@ -0,0 +1,84 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// A task can depend on other tasks belonging to the same user (e.g. a transfer
+// of reads can depend on a transfer of the reference data needed to interpret
+// them). A task with dependencies waits until all of them have succeeded, and
+// fails if any of them fails.
+
+// makes sure that the dependencies of a new task refer to existing tasks
+// belonging to the same user
+func validateDependencies(task transferTask, tasks map[uuid.UUID]transferTask) error {
+	for _, id := range task.DependsOn {
+		dependency, found := tasks[id]
+		if !found {
+			return &InvalidDependencyError{Id: id, Message: "task not found"}
+		}
+		if dependency.User.Orcid != task.User.Orcid {
+			return &InvalidDependencyError{Id: id, Message: "task belongs to another user"}
+		}
+	}
+	return nil
+}
+
+// checks the dependencies of a waiting task, returning true if the task must
+// wait at least until the next poll before starting, or an error if it can
+// never start because a dependency failed (or the task was canceled). A task
+// whose dependencies have all succeeded starts on the next poll, which places
+// it in the queue for bulk transfers if needed.
+func (task *transferTask) checkDependencies(tasks map[uuid.UUID]transferTask) (bool, error) {
+	if task.Status.Code != TransferStatusWaiting {
+		return false, nil
+	}
+	if task.Canceled {
+		return false, fmt.Errorf("canceled while waiting for dependencies")
+	}
+	numPending := 0
+	for _, id := range task.DependsOn {
+		dependency, found := tasks[id]
+		if !found {
+			return false, fmt.Errorf("dependency %s no longer exists", id.String())
+		}
+		switch dependency.Status.Code {
+		case TransferStatusSucceeded:
+		case TransferStatusFailed:
+			return false, fmt.Errorf("dependency %s failed", id.String())
+		default:
+			numPending++
+		}
+	}
+	if numPending > 0 {
+		task.Status.Message = fmt.Sprintf("waiting for %d of %d dependencies to succeed",
+			numPending, len(task.DependsOn))
+	} else {
+		task.Status.Code = TransferStatusUnknown
+		task.Status.Message = ""
+	}
+	return true, nil
+}
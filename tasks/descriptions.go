@@ -0,0 +1,102 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"slices"
+
+	"github.com/kbase/dts/credit"
+)
+
+// a translation of a task's description into another language
+type Translation struct {
+	Language string // IETF BCP 47 language tag (e.g. "fr", "pt-BR")
+	Text     string // Markdown description in the given language
+}
+
+// returns the given description language and translations with their
+// language tags normalized, or an error if any tag isn't well formed or
+// translations are missing tags or repeat languages
+func normalizeDescriptionLanguages(language string,
+	translations []Translation) (string, []Translation, error) {
+	var languages []string
+	if language != "" {
+		tag, ok := credit.NormalizeLanguageTag(language)
+		if !ok {
+			return "", nil, &InvalidLanguageError{Language: language, Message: "not a valid IETF BCP 47 language tag"}
+		}
+		language = tag
+		languages = append(languages, tag)
+	}
+	normalized := make([]Translation, len(translations))
+	for i, translation := range translations {
+		tag, ok := credit.NormalizeLanguageTag(translation.Language)
+		if !ok {
+			return "", nil, &InvalidLanguageError{Language: translation.Language,
+				Message: "translations need valid IETF BCP 47 language tags"}
+		}
+		if slices.Contains(languages, tag) {
+			return "", nil, &InvalidLanguageError{Language: translation.Language,
+				Message: "the description has more than one version in this language"}
+		}
+		languages = append(languages, tag)
+		normalized[i] = Translation{Language: tag, Text: translation.Text}
+	}
+	return language, normalized, nil
+}
+
+// returns the task's description followed by its translations, each with its
+// language (if known), for the "descriptions" field of its manifest, or nil
+// if the task's description has no language or translations
+func (task transferTask) manifestDescriptions() []any {
+	if task.DescriptionLanguage == "" && len(task.Translations) == 0 {
+		return nil
+	}
+	descriptions := make([]any, 0, len(task.Translations)+1)
+	if task.Description != "" {
+		description := map[string]any{"description": task.Description}
+		if task.DescriptionLanguage != "" {
+			description["language"] = task.DescriptionLanguage
+		}
+		descriptions = append(descriptions, description)
+	}
+	for _, translation := range task.Translations {
+		descriptions = append(descriptions, map[string]any{
+			"description": translation.Text,
+			"language":    translation.Language,
+		})
+	}
+	return descriptions
+}
+
+// normalizes the language tags of the titles and descriptions in the credit
+// metadata of the given (manifest) file descriptor, if it has any
+func normalizeCreditLanguages(descriptor map[string]any) {
+	metadata, found := descriptorCredit(descriptor)
+	if !found || (len(metadata.Titles) == 0 && len(metadata.Descriptions) == 0) {
+		return
+	}
+	metadata.Titles = slices.Clone(metadata.Titles)
+	metadata.Descriptions = slices.Clone(metadata.Descriptions)
+	metadata.NormalizeLanguages()
+	descriptor["credit"] = metadata
+}
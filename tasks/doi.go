@@ -0,0 +1,417 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/frictionless"
+)
+
+// Just before it writes its final manifest, a task whose destination is
+// configured to mint DOIs registers a draft DOI with DataCite for its delivered
+// payload, describing the payload with the credit metadata of its files. The
+// DOI is recorded in the manifest, the transfer journal, and the task's
+// "succeeded" callback event. Only once the manifest has been delivered is the
+// DOI published, resolving to the task's destination folder (if the
+// destination has an access URL; otherwise it remains a draft), so a DOI never
+// resolves to an incomplete payload. Requests to DataCite are made in the
+// background, so a slow response doesn't hold up a worker: the task checks on
+// its request each time it's updated.
+
+// the HTTP client used to communicate with DataCite
+var doiClient = &http.Client{Timeout: 30 * time.Second}
+
+// mints a draft DOI for the task's delivered payload if its destination mints
+// them and the task doesn't already have one, returning true once this is done
+// (or unnecessary) and false while the DataCite request is in progress. A
+// failure is recorded as a warning, since it doesn't affect the delivered
+// payload.
+func (task *transferTask) mintDOI() bool {
+	if task.DOI != "" || !config.Databases[task.Destination].MintDOIs {
+		return true
+	}
+	attributes := task.dataciteAttributes()
+	result, done := dataciteRequest(task.Id, "mint", func() (string, error) {
+		return registerDOI(attributes)
+	})
+	if !done {
+		return false
+	}
+	if result.Error != nil {
+		task.doiWarning("couldn't mint DOI", result.Error)
+		return true
+	}
+	task.DOI = result.DOI
+	slog.Info(fmt.Sprintf("Task %s: minted draft DOI %s", task.Id.String(), task.DOI))
+	return true
+}
+
+// publishes the task's (draft) DOI, making it resolve to the delivered payload,
+// returning true once this is done (or unnecessary because the task has no DOI
+// or its destination has no access URL) and false while the DataCite request
+// is in progress. A failure is recorded as a warning.
+func (task *transferTask) publishDOI() bool {
+	url := task.accessURL(task.DestinationFolder)
+	if task.DOI == "" || url == "" {
+		return true
+	}
+	doi := task.DOI
+	result, done := dataciteRequest(task.Id, "publish", func() (string, error) {
+		return doi, updateDOI(doi, map[string]any{"url": url, "event": "publish"})
+	})
+	if !done {
+		return false
+	}
+	if result.Error != nil {
+		task.doiWarning("couldn't publish DOI", result.Error)
+		return true
+	}
+	slog.Info(fmt.Sprintf("Task %s: published DOI %s", task.Id.String(), doi))
+	return true
+}
+
+// records a warning for the given failed DataCite operation
+func (task *transferTask) doiWarning(operation string, err error) {
+	slog.Warn(fmt.Sprintf("Task %s: %s: %s", task.Id.String(), operation, err.Error()))
+	warning := fmt.Sprintf("%s: %s", operation, err.Error())
+	if !slices.Contains(task.MetadataWarnings, warning) {
+		task.MetadataWarnings = append(task.MetadataWarnings, warning)
+	}
+}
+
+// the result of a DataCite request
+type dataciteResult struct {
+	DOI   string
+	Error error
+}
+
+// starts the given DataCite operation for the task with the given ID in the
+// background if it's not already in progress, returning its result and true
+// if it has finished, or false if it's still in progress
+func dataciteRequest(taskId uuid.UUID, operation string,
+	request func() (string, error)) (dataciteResult, bool) {
+	result, done := inBackground(taskId, "datacite-"+operation, request)
+	return dataciteResult{DOI: result.Value, Error: result.Error}, done
+}
+
+// returns the file descriptors of the task's delivered payload
+func (task transferTask) deliveredDescriptors() []map[string]any {
+	var descriptors []map[string]any
+	for _, subtask := range task.Subtasks {
+		for _, d := range subtask.Descriptors {
+			descriptor, ok := d.(map[string]any)
+			if !ok {
+				continue
+			}
+			id := frictionless.String(descriptor, "id")
+			if _, skipped := subtask.SkippedFiles[id]; skipped {
+				continue
+			}
+			if _, corrupt := subtask.CorruptFiles[id]; corrupt {
+				continue
+			}
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+	return descriptors
+}
+
+// returns the DataCite metadata attributes describing the task's delivered
+// payload
+func (task transferTask) dataciteAttributes() map[string]any {
+	payloadCredit := gatherCredit(task.deliveredDescriptors())
+
+	// credit the contributors to the payload's files, or the requesting user
+	// if they name no contributors
+	creators := make([]any, 0, len(payloadCredit.Contributors))
+	for _, contributor := range payloadCredit.Contributors {
+		creators = append(creators, dataciteCreator(contributor))
+	}
+	if len(creators) == 0 {
+		creators = append(creators, dataciteCreator(credit.Contributor{
+			ContributorType: "Person",
+			ContributorId:   "ORCID:" + task.User.Orcid,
+			Name:            task.User.Name,
+		}))
+	}
+
+	// list the contributors once for each DataCite contributor type
+	// corresponding to their roles
+	var contributors []any
+	for _, contributor := range payloadCredit.Contributors {
+		var contributorTypes []string
+		for _, role := range contributor.Roles() {
+			if !slices.Contains(contributorTypes, role.ContributorType) {
+				contributorTypes = append(contributorTypes, role.ContributorType)
+				entry := dataciteCreator(contributor)
+				entry["contributorType"] = role.ContributorType
+				contributors = append(contributors, entry)
+			}
+		}
+	}
+
+	attributes := map[string]any{
+		"prefix":   config.Service.DOI.Prefix,
+		"creators": creators,
+		"titles": []any{
+			map[string]any{"title": task.datasetTitle()},
+		},
+		"descriptions":    task.dataciteDescriptions(),
+		"publisher":       config.Databases[task.Destination].Organization,
+		"publicationYear": time.Now().Year(),
+		"types":           map[string]any{"resourceTypeGeneral": "Dataset"},
+	}
+
+	if len(contributors) > 0 {
+		attributes["contributors"] = contributors
+	}
+
+	if len(payloadCredit.Licenses) > 0 {
+		rights := make([]any, len(payloadCredit.Licenses))
+		for i, license := range payloadCredit.Licenses {
+			entry := map[string]any{
+				"rights":    license.Id,
+				"rightsUri": licenseURL(license),
+			}
+			if license.Id != "" {
+				entry["rightsIdentifier"] = license.Id
+				entry["rightsIdentifierScheme"] = "SPDX"
+			} else {
+				entry["rights"] = license.Url
+			}
+			rights[i] = entry
+		}
+		attributes["rightsList"] = rights
+	}
+	if len(payloadCredit.Funding) > 0 {
+		funding := make([]any, len(payloadCredit.Funding))
+		for i, reference := range payloadCredit.Funding {
+			entry := map[string]any{
+				"funderName": reference.Funder.OrganizationName,
+			}
+			if rorId, found := strings.CutPrefix(reference.Funder.OrganizationId, "ROR:"); found {
+				entry["funderIdentifier"] = "https://ror.org/" + rorId
+				entry["funderIdentifierType"] = "ROR"
+			}
+			if reference.GrantId != "" {
+				entry["awardNumber"] = reference.GrantId
+			}
+			if reference.GrantTitle != "" {
+				entry["awardTitle"] = reference.GrantTitle
+			}
+			if reference.GrantUrl != "" {
+				entry["awardURI"] = reference.GrantUrl
+			}
+			funding[i] = entry
+		}
+		attributes["fundingReferences"] = funding
+	}
+
+	// relate the DOI to the resources from which the payload was derived
+	// (DataCite accepts only certain identifier types)
+	var related []any
+	for _, source := range payloadCredit.Sources {
+		identifier, identifierType := source, "URL"
+		if doi, found := cutDOIPrefix(source); found {
+			identifier, identifierType = doi, "DOI"
+		} else if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+			continue
+		}
+		related = append(related, map[string]any{
+			"relatedIdentifier":     identifier,
+			"relatedIdentifierType": identifierType,
+			"relationType":          "IsDerivedFrom",
+		})
+	}
+	if len(related) > 0 {
+		attributes["relatedIdentifiers"] = related
+	}
+	return attributes
+}
+
+// returns the DataCite descriptions of the payload delivered by the task: its
+// description and any translations, each tagged with its language (if known;
+// the generic description used in place of a missing one is untagged)
+func (task transferTask) dataciteDescriptions() []any {
+	description := map[string]any{"description": task.datasetDescription(), "descriptionType": "Abstract"}
+	if task.DescriptionLanguage != "" && task.Description != "" {
+		description["lang"] = task.DescriptionLanguage
+	}
+	descriptions := []any{description}
+	for _, translation := range task.Translations {
+		descriptions = append(descriptions, map[string]any{
+			"description":     translation.Text,
+			"descriptionType": "Abstract",
+			"lang":            translation.Language,
+		})
+	}
+	return descriptions
+}
+
+// returns a DataCite creator for the given contributor
+func dataciteCreator(contributor credit.Contributor) map[string]any {
+	creator := map[string]any{
+		"name":     contributor.Name,
+		"nameType": "Personal",
+	}
+	if contributor.ContributorType == "Organization" {
+		creator["nameType"] = "Organizational"
+	} else {
+		if contributor.GivenName != "" {
+			creator["givenName"] = contributor.GivenName
+		}
+		if contributor.FamilyName != "" {
+			creator["familyName"] = contributor.FamilyName
+			if contributor.Name == "" {
+				creator["name"] = fmt.Sprintf("%s, %s", contributor.FamilyName, contributor.GivenName)
+			}
+		}
+	}
+	if orcid, found := strings.CutPrefix(contributor.ContributorId, "ORCID:"); found && orcid != "" {
+		creator["nameIdentifiers"] = []any{
+			map[string]any{
+				"nameIdentifier":       "https://orcid.org/" + orcid,
+				"nameIdentifierScheme": "ORCID",
+				"schemeUri":            "https://orcid.org",
+			},
+		}
+	}
+	if len(contributor.Affiliations) > 0 {
+		affiliations := make([]any, len(contributor.Affiliations))
+		for i, affiliation := range contributor.Affiliations {
+			affiliations[i] = map[string]any{"name": affiliation.OrganizationName}
+		}
+		creator["affiliation"] = affiliations
+	}
+	return creator
+}
+
+// returns the DOI in the given identifier and true if it's a DOI (e.g.
+// "doi:10.12345/abc", "DOI:10.12345/abc", or "https://doi.org/10.12345/abc")
+func cutDOIPrefix(identifier string) (string, bool) {
+	for _, prefix := range []string{"doi:", "DOI:", "https://doi.org/", "http://doi.org/", "http://dx.doi.org/"} {
+		if doi, found := strings.CutPrefix(identifier, prefix); found && strings.HasPrefix(doi, "10.") {
+			return doi, true
+		}
+	}
+	return "", false
+}
+
+// registers a DOI with the given metadata attributes with DataCite, returning
+// the new DOI
+func registerDOI(attributes map[string]any) (string, error) {
+	credential, err := credentials.Get(config.Service.DOI.Credential)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]any{
+		"data": map[string]any{
+			"type":       "dois",
+			"attributes": attributes,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequest(http.MethodPost,
+		strings.TrimSuffix(config.Service.DOI.Endpoint, "/")+"/dois", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/vnd.api+json")
+	request.SetBasicAuth(credential.Id, credential.Secret)
+	response, err := doiClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("DataCite responded with status %d: %s", response.StatusCode,
+			strings.TrimSpace(string(responseBody)))
+	}
+	var created struct {
+		Data struct {
+			Id string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(responseBody, &created); err != nil {
+		return "", err
+	}
+	if created.Data.Id == "" {
+		return "", fmt.Errorf("DataCite response has no DOI")
+	}
+	return created.Data.Id, nil
+}
+
+// updates the metadata attributes of the given DOI with DataCite (e.g. to
+// publish it)
+func updateDOI(doi string, attributes map[string]any) error {
+	credential, err := credentials.Get(config.Service.DOI.Credential)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{
+		"data": map[string]any{
+			"type":       "dois",
+			"attributes": attributes,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPut,
+		strings.TrimSuffix(config.Service.DOI.Endpoint, "/")+"/dois/"+doi, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/vnd.api+json")
+	request.SetBasicAuth(credential.Id, credential.Secret)
+	response, err := doiClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("DataCite responded with status %d: %s", response.StatusCode,
+			strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
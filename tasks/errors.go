@@ -23,10 +23,15 @@ package tasks
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/units"
 )
 
 // indicates that a task is sought but not found
@@ -38,6 +43,26 @@ func (t NotFoundError) Error() string {
 	return fmt.Sprintf("The task %s was not found.", t.Id.String())
 }
 
+// indicates that a user has attempted to modify a task belonging to another user
+type NotOwnerError struct {
+	Id    uuid.UUID
+	Orcid string
+}
+
+func (t NotOwnerError) Error() string {
+	return fmt.Sprintf("The task %s does not belong to the user with ORCID %s.", t.Id.String(), t.Orcid)
+}
+
+// indicates that a task cannot be paused or resumed in its present state
+type CannotPauseError struct {
+	Id      uuid.UUID
+	Message string
+}
+
+func (t CannotPauseError) Error() string {
+	return fmt.Sprintf("The task %s cannot be paused or resumed: %s", t.Id.String(), t.Message)
+}
+
 // indicates that Start() has been called when tasks are being processed
 type AlreadyRunningError struct{}
 
@@ -65,6 +90,167 @@ type PayloadTooLargeError struct {
 }
 
 func (e PayloadTooLargeError) Error() string {
-	return fmt.Sprintf("Requested payload is too large: %g GB (limit is %g GB).",
-		e.Size, config.Service.MaxPayloadSize)
+	return fmt.Sprintf("Requested payload is too large: %s (limit is %s).",
+		units.FormatGigabytes(e.Size), units.FormatGigabytes(config.Service.MaxPayloadSize))
+}
+
+// indicates that a transfer request exceeds the quota of the requesting user
+// or of the DTS client through which it was made
+type QuotaExceededError struct {
+	Subject        string  // "user <ORCID>" or "client <KBase username>"
+	Transfers      int     // number of transfers in progress
+	MaxTransfers   int     // concurrent transfer limit (0 if not exceeded)
+	PayloadSize    float64 // size of payloads requested in the past day (gigabytes)
+	MaxPayloadSize float64 // daily payload limit (gigabytes; 0 if not exceeded)
+}
+
+func (e QuotaExceededError) Error() string {
+	if e.MaxTransfers > 0 {
+		return fmt.Sprintf("Transfer quota exceeded for %s: %d transfer(s) in progress (limit is %d).",
+			e.Subject, e.Transfers, e.MaxTransfers)
+	}
+	return fmt.Sprintf("Transfer quota exceeded for %s: %s requested in the past day (limit is %s).",
+		e.Subject, units.FormatGigabytes(e.PayloadSize), units.FormatGigabytes(e.MaxPayloadSize))
+}
+
+// indicates that a filesystem used by the DTS is running out of space
+type InsufficientDiskSpaceError struct {
+	Directory string  // directory on the filesystem in question
+	Available float64 // free space on the filesystem (gigabytes)
+}
+
+func (e InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("Insufficient disk space for %s: %s available (at least %s required).",
+		e.Directory, units.FormatGigabytes(e.Available), units.FormatGigabytes(config.Service.MinFreeDiskSpace))
+}
+
+// indicates that the filter rules given in a transfer's instructions are invalid
+type InvalidFilterRulesError struct {
+	Message string
+}
+
+func (e InvalidFilterRulesError) Error() string {
+	return fmt.Sprintf("Invalid filter rules in transfer instructions: %s", e.Message)
+}
+
+// indicates that the source endpoints selected in a transfer's instructions are
+// invalid
+type InvalidSourceEndpointError struct {
+	Message string
+}
+
+func (e InvalidSourceEndpointError) Error() string {
+	return fmt.Sprintf("Invalid source endpoint selection in transfer instructions: %s", e.Message)
+}
+
+// indicates that an entry in a transfer's exclusion list is malformed
+type InvalidExclusionError struct {
+	Exclusion string
+}
+
+func (e InvalidExclusionError) Error() string {
+	return fmt.Sprintf("Invalid file exclusion pattern: %s", e.Exclusion)
+}
+
+// indicates that a requested deadline for a transfer has already passed
+type InvalidDeadlineError struct {
+	Deadline time.Time
+}
+
+func (e InvalidDeadlineError) Error() string {
+	return fmt.Sprintf("Invalid transfer deadline: %s (must be in the future)", e.Deadline.Format(time.RFC3339))
+}
+
+// indicates that a task depends on a task that doesn't exist or can't be used
+// as a dependency
+type InvalidDependencyError struct {
+	Id      uuid.UUID // ID of the dependency
+	Message string    // reason the dependency is invalid
+}
+
+func (e InvalidDependencyError) Error() string {
+	return fmt.Sprintf("Invalid transfer dependency %s: %s", e.Id.String(), e.Message)
+}
+
+// indicates that a destination endpoint requires encrypted transfers that a
+// source endpoint can't provide
+type EncryptionRequiredError struct {
+	Source, Destination string // names of source and destination endpoints
+}
+
+func (e EncryptionRequiredError) Error() string {
+	return fmt.Sprintf("The endpoint '%s' requires encrypted transfers, which the endpoint '%s' can't provide.",
+		e.Destination, e.Source)
+}
+
+// indicates that a requested transfer violates an egress policy
+type EgressPolicyViolationError struct {
+	Policy      string   // name of the violated policy
+	Description string   // statement of the policy (if any)
+	Source      string   // name of the source database
+	Destination string   // name of the destination database (or custom spec)
+	FileIds     []string // IDs of governed files (empty if all files are governed)
+}
+
+func (e EgressPolicyViolationError) Error() string {
+	msg := fmt.Sprintf("Files from '%s' may not be transferred to '%s' (egress policy '%s')",
+		e.Source, e.Destination, e.Policy)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	if len(e.FileIds) > 0 {
+		msg += fmt.Sprintf(" [governed files: %s]", strings.Join(e.FileIds, ", "))
+	}
+	return msg
+}
+
+// indicates that a source endpoint failed an availability probe
+type SourceUnavailableError struct {
+	Endpoint string // name of the source endpoint
+	Message  string // description of the failure
+}
+
+func (e SourceUnavailableError) Error() string {
+	return fmt.Sprintf("The source endpoint '%s' is unavailable: %s", e.Endpoint, e.Message)
+}
+
+// indicates that some requested files are embargoed
+type EmbargoedFilesError struct {
+	Embargoes map[string]time.Time // file IDs mapped to times their embargoes lift
+}
+
+func (e EmbargoedFilesError) Error() string {
+	ids := slices.Sorted(maps.Keys(e.Embargoes))
+	files := make([]string, len(ids))
+	for i, id := range ids {
+		files[i] = fmt.Sprintf("%s (until %s)", id, e.Embargoes[id].Format(time.RFC3339))
+	}
+	return fmt.Sprintf("The following requested files are embargoed: %s",
+		strings.Join(files, ", "))
+}
+
+// indicates that the submission of a transfer or manifest failed on every
+// attempt allowed by the service's retry policy
+type SubmissionRetriesExhaustedError struct {
+	What     string // "transfer" or "manifest"
+	Attempts int    // number of failed attempts
+	Err      error  // error from the last attempt
+}
+
+func (e SubmissionRetriesExhaustedError) Error() string {
+	return fmt.Sprintf("The %s submission failed after %d attempts: %s", e.What, e.Attempts, e.Err.Error())
+}
+
+func (e SubmissionRetriesExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// indicates that a language given for a transfer's description is invalid
+type InvalidLanguageError struct {
+	Language string // the invalid language tag
+	Message  string // reason the language is invalid
+}
+
+func (e InvalidLanguageError) Error() string {
+	return fmt.Sprintf("Invalid description language '%s': %s", e.Language, e.Message)
 }
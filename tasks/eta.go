@@ -0,0 +1,156 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/frictionless"
+)
+
+// Estimates of the time remaining for a transfer task combine the progress
+// observed for each of its subtasks with a history of how long staging and
+// transfers from each source database have taken in the past. The history is
+// kept in memory and rebuilt as the service runs.
+
+// weight given to the most recent observation in smoothed history values
+const historyWeight = 0.25
+
+var (
+	historyMutex_     sync.Mutex
+	stagingDurations_ = make(map[string]time.Duration) // by source database
+	transferRates_    = make(map[string]float64)       // bytes/second, by source database
+)
+
+// records the time taken to stage files from the given source database
+func recordStagingDuration(source string, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	historyMutex_.Lock()
+	defer historyMutex_.Unlock()
+	if previous, found := stagingDurations_[source]; found {
+		duration = time.Duration(historyWeight*float64(duration) + (1-historyWeight)*float64(previous))
+	}
+	stagingDurations_[source] = duration
+}
+
+// records the rate at which the given number of bytes were transferred from
+// the given source database over the given duration
+func recordTransferRate(source string, numBytes int64, duration time.Duration) {
+	if numBytes <= 0 || duration <= 0 {
+		return
+	}
+	rate := float64(numBytes) / duration.Seconds()
+	historyMutex_.Lock()
+	defer historyMutex_.Unlock()
+	if previous, found := transferRates_[source]; found {
+		rate = historyWeight*rate + (1-historyWeight)*previous
+	}
+	transferRates_[source] = rate
+}
+
+// returns the typical staging duration for the given source database, or
+// false if none has been observed
+func typicalStagingDuration(source string) (time.Duration, bool) {
+	historyMutex_.Lock()
+	defer historyMutex_.Unlock()
+	duration, found := stagingDurations_[source]
+	return duration, found
+}
+
+// returns the typical transfer rate (bytes/second) for the given source
+// database, or false if none has been observed
+func typicalTransferRate(source string) (float64, bool) {
+	historyMutex_.Lock()
+	defer historyMutex_.Unlock()
+	rate, found := transferRates_[source]
+	return rate, found
+}
+
+// returns the estimated time of completion for the task's staging and file
+// transfers, or a zero time if no estimate can be made
+func (task transferTask) estimateCompletion() time.Time {
+	var remaining time.Duration
+	for _, subtask := range task.Subtasks {
+		subtaskRemaining, ok := subtask.timeRemaining()
+		if !ok {
+			return time.Time{}
+		}
+		remaining = max(remaining, subtaskRemaining) // subtasks proceed in parallel
+	}
+	return time.Now().Add(remaining)
+}
+
+// returns the estimated time remaining for the subtask's staging and file
+// transfers, and true, or false if no estimate can be made
+func (subtask transferSubtask) timeRemaining() (time.Duration, bool) {
+	if !subtask.Staging.Valid && !subtask.Transfer.Valid { // not underway
+		return 0, subtask.TransferStatus.Code == TransferStatusSucceeded
+	}
+
+	// relayed files are transferred twice
+	numBytes := subtask.payloadBytes()
+	numLegs := int64(1)
+	if subtask.RelayEndpoint != "" && !subtask.Relayed {
+		numLegs = 2
+	}
+
+	var remaining time.Duration
+	bytesLeft := numLegs * numBytes
+	rate, haveRate := typicalTransferRate(subtask.Source)
+	if subtask.Staging.Valid {
+		duration, ok := typicalStagingDuration(subtask.Source)
+		if !ok {
+			return 0, false
+		}
+		remaining = max(duration-time.Since(subtask.StagingStartTime), 0)
+	} else { // transferring: use the observed rate if there's progress
+		transferred := subtask.TransferStatus.NumBytesTransferred
+		elapsed := time.Since(subtask.TransferStartTime)
+		if transferred > 0 && elapsed > 0 {
+			rate, haveRate = float64(transferred)/elapsed.Seconds(), true
+		}
+		bytesLeft = max(numBytes-transferred, 0) + (numLegs-1)*numBytes
+	}
+	if bytesLeft > 0 {
+		if !haveRate {
+			return 0, false
+		}
+		remaining += time.Duration(float64(bytesLeft) / rate * float64(time.Second))
+	}
+	return remaining, true
+}
+
+// returns the total size of the subtask's files in bytes
+func (subtask transferSubtask) payloadBytes() int64 {
+	var numBytes int64
+	for _, d := range subtask.Descriptors {
+		if descriptor, ok := d.(map[string]any); ok {
+			if size, ok := frictionless.Int(descriptor, "bytes"); ok {
+				numBytes += int64(size)
+			}
+		}
+	}
+	return numBytes
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/audit"
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/journal"
+	"github.com/kbase/dts/manifests"
+	"github.com/kbase/dts/units"
+)
+
+// this type holds metrics describing the removal of orphaned scratch files
+type JanitorMetrics struct {
+	// number of sweeps performed by the janitor
+	NumSweeps int
+	// number of orphaned files removed
+	NumFilesRemoved int
+	// disk space reclaimed by removing orphaned files (bytes)
+	BytesReclaimed int64
+	// time of the most recent sweep
+	LastSweepTime time.Time
+}
+
+// Returns metrics describing the janitor's removal of orphaned scratch files
+// (manifests for failed transfers, etc).
+func Janitor() JanitorMetrics {
+	janitorMutex.Lock()
+	defer janitorMutex.Unlock()
+	return janitorMetrics
+}
+
+//-----------
+// Internals
+//-----------
+
+// a directory in which the DTS writes scratch files, and patterns matching
+// their names
+type scratchDirectory struct {
+	Dir      string
+	Patterns []string
+}
+
+// patterns matching scratch files written by the DTS in its manifest directory
+var manifestScratchPatterns = []string{"manifest-*.json", "stub-*.json", "checksums-*.txt", "dataset-*.jsonld"}
+
+// patterns matching scratch directories written by endpoints in the DTS's
+// data directory (files staged for upload and temporary keys), which are left
+// behind if the DTS stops during a transfer
+var dataScratchPatterns = []string{"dts-https-*", "dts-sftp-*"}
+
+// returns the directories in which the DTS writes scratch files
+func scratchDirectories() []scratchDirectory {
+	return []scratchDirectory{
+		{Dir: config.Service.ManifestDirectory, Patterns: manifestScratchPatterns},
+		{Dir: config.Service.DataDirectory, Patterns: dataScratchPatterns},
+	}
+}
+
+// janitor metrics and a mutex that guards them
+var janitorMetrics JanitorMetrics
+var janitorMutex sync.Mutex
+
+// this function runs in its own goroutine, removing orphaned scratch files
+// (and anonymizing expired personal information) each time it receives the set
+// of files referenced by live tasks, until its channel is closed
+func janitor(liveFilesChan <-chan map[string]struct{}) {
+	retention := time.Duration(config.Service.ScratchRetention) * time.Second
+	userDataRetention := time.Duration(config.Service.UserDataRetention) * time.Second
+	for liveFiles := range liveFilesChan {
+		sweepScratchFiles(scratchDirectories(), liveFiles, retention)
+		if userDataRetention > 0 {
+			anonymizeUserData(time.Now().Add(-userDataRetention))
+		}
+	}
+}
+
+// anonymizes personal information in the records of transfers completed before
+// the given time in the transfer journal, manifest archive, and audit log
+func anonymizeUserData(cutoff time.Time) {
+	numAnonymized := 0
+	anonymizers := make(map[string]func(time.Time) (int, error))
+	if journal.IsOpen() {
+		anonymizers["journal"] = journal.AnonymizeBefore
+	}
+	if manifests.IsOpen() {
+		anonymizers["manifest archive"] = manifests.AnonymizeBefore
+	}
+	if audit.IsOpen() {
+		anonymizers["audit log"] = audit.AnonymizeBefore
+	}
+	for store, anonymizeBefore := range anonymizers {
+		n, err := anonymizeBefore(cutoff)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Janitor: anonymizing %s: %s", store, err.Error()))
+			continue
+		}
+		numAnonymized += n
+	}
+	if numAnonymized > 0 {
+		slog.Info(fmt.Sprintf("Janitor: anonymized %d expired record(s)", numAnonymized))
+	}
+}
+
+// removes scratch files (and directories) in the given directories that
+// aren't among the given live files and haven't been modified within the given
+// retention period
+func sweepScratchFiles(dirs []scratchDirectory, liveFiles map[string]struct{}, retention time.Duration) {
+	numFilesRemoved := 0
+	var bytesReclaimed int64
+	for _, dir := range dirs {
+		if dir.Dir == "" {
+			continue
+		}
+		for _, pattern := range dir.Patterns {
+			files, err := filepath.Glob(filepath.Join(dir.Dir, pattern))
+			if err != nil {
+				slog.Error(fmt.Sprintf("Janitor: %s", err.Error()))
+				continue
+			}
+			for _, file := range files {
+				if _, live := liveFiles[file]; live {
+					continue
+				}
+				size, modTime, err := scratchFileUsage(file)
+				if err != nil || time.Since(modTime) < retention {
+					continue
+				}
+				err = os.RemoveAll(file)
+				if err != nil {
+					slog.Error(fmt.Sprintf("Janitor: removing %s: %s", file, err.Error()))
+					continue
+				}
+				numFilesRemoved++
+				bytesReclaimed += size
+			}
+		}
+	}
+	if numFilesRemoved > 0 {
+		slog.Info(fmt.Sprintf("Janitor: removed %d orphaned scratch file(s) (%s)",
+			numFilesRemoved, units.FormatBytes(bytesReclaimed)))
+	}
+
+	janitorMutex.Lock()
+	janitorMetrics.NumSweeps++
+	janitorMetrics.NumFilesRemoved += numFilesRemoved
+	janitorMetrics.BytesReclaimed += bytesReclaimed
+	janitorMetrics.LastSweepTime = time.Now()
+	janitorMutex.Unlock()
+}
+
+// returns the size of the scratch file (or directory) with the given path and
+// the time at which it (or anything within it) was last modified
+func scratchFileUsage(path string) (int64, time.Time, error) {
+	var size int64
+	var modTime time.Time
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	return size, modTime, err
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/frictionlessdata/datapackage-go/datapackage"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/frictionless"
+)
+
+// Alongside its manifest, a task can deliver a schema.org Dataset description
+// of its payload in JSON-LD ("dataset.jsonld") to destinations configured to
+// receive one. The description maps the credit metadata of the payload's files
+// to the creators, licenses, funders, and sources of the dataset and lists the
+// files as downloads, so landing pages and dataset search engines can index the
+// delivered data at destinations that serve static files.
+
+// the name of the JSON-LD file in a task's destination folder
+const jsonLDFileName = "dataset.jsonld"
+
+// returns true if the task's destination receives a JSON-LD dataset
+// description with each manifest
+func (task transferTask) wantsJSONLD() bool {
+	return config.Databases[task.Destination].DatasetJSONLD
+}
+
+// returns a schema.org Person or Organization for the given contributor
+func jsonLDContributor(contributor credit.Contributor) map[string]any {
+	entity := map[string]any{
+		"@type": "Person",
+		"name":  contributor.Name,
+	}
+	if contributor.ContributorType == "Organization" {
+		entity["@type"] = "Organization"
+	} else {
+		if contributor.GivenName != "" {
+			entity["givenName"] = contributor.GivenName
+		}
+		if contributor.FamilyName != "" {
+			entity["familyName"] = contributor.FamilyName
+		}
+		if contributor.Name == "" {
+			entity["name"] = fmt.Sprintf("%s %s", contributor.GivenName, contributor.FamilyName)
+		}
+	}
+	if contributor.ContributorId != "" {
+		entity["identifier"] = contributor.ContributorId
+	}
+	if len(contributor.Affiliations) > 0 {
+		affiliations := make([]any, len(contributor.Affiliations))
+		for i, affiliation := range contributor.Affiliations {
+			affiliations[i] = jsonLDOrganization(affiliation)
+		}
+		entity["affiliation"] = affiliations
+	}
+	return entity
+}
+
+// returns a schema.org Organization for the given organization
+func jsonLDOrganization(organization credit.Organization) map[string]any {
+	entity := map[string]any{
+		"@type": "Organization",
+		"name":  organization.OrganizationName,
+	}
+	if organization.OrganizationId != "" {
+		entity["identifier"] = organization.OrganizationId
+	}
+	return entity
+}
+
+// returns a title for the dataset delivered by the task
+func (task transferTask) datasetTitle() string {
+	return fmt.Sprintf("Data from %s (transfer %s)", config.Databases[task.Source].Name,
+		task.Id.String())
+}
+
+// returns a description of the dataset delivered by the task: the task's own
+// description, if it has one
+func (task transferTask) datasetDescription() string {
+	if task.Description != "" {
+		return task.Description
+	}
+	return fmt.Sprintf("Files from %s transferred by the Data Transfer Service.",
+		config.Databases[task.Source].Name)
+}
+
+// returns the description of the dataset delivered by the task for its JSON-LD
+// representation: a plain string, or, if the description's language is known
+// or it has translations, a list of language-tagged values
+func (task transferTask) datasetJSONLDDescription() any {
+	if task.DescriptionLanguage == "" && len(task.Translations) == 0 {
+		return task.datasetDescription()
+	}
+	var descriptions []any
+	if task.DescriptionLanguage != "" && task.Description != "" {
+		descriptions = append(descriptions, map[string]any{
+			"@value":    task.datasetDescription(),
+			"@language": task.DescriptionLanguage,
+		})
+	} else {
+		descriptions = append(descriptions, task.datasetDescription())
+	}
+	for _, translation := range task.Translations {
+		descriptions = append(descriptions, map[string]any{
+			"@value":    translation.Text,
+			"@language": translation.Language,
+		})
+	}
+	return descriptions
+}
+
+// returns a schema.org Dataset description of the payload listed in the given
+// manifest, suitable for encoding as JSON-LD
+func (task transferTask) datasetJSONLD(manifest *datapackage.Package) map[string]any {
+	manifestDescriptor := manifest.Descriptor()
+	resources, _ := manifestDescriptor["resources"].([]any)
+
+	descriptors := make([]map[string]any, 0, len(resources))
+	for _, r := range resources {
+		if descriptor, ok := r.(map[string]any); ok {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+
+	// list delivered files as downloads, at their access URLs if they have
+	// them, and relative to the JSON-LD file otherwise
+	distribution := make([]any, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		if task.MetadataOnly || frictionless.String(descriptor, "destination_path") == "" {
+			continue
+		}
+		contentURL := frictionless.String(descriptor, "access_url")
+		if contentURL == "" {
+			relativePath, err := filepath.Rel(task.DestinationFolder,
+				frictionless.String(descriptor, "destination_path"))
+			if err != nil {
+				continue
+			}
+			contentURL = filepath.ToSlash(relativePath)
+		}
+		download := map[string]any{
+			"@type":      "DataDownload",
+			"name":       filepath.Base(frictionless.String(descriptor, "path")),
+			"contentUrl": contentURL,
+		}
+		if id := frictionless.String(descriptor, "id"); id != "" {
+			download["identifier"] = id
+		}
+		if mediatype := frictionless.String(descriptor, "mediatype"); mediatype != "" {
+			download["encodingFormat"] = mediatype
+		}
+		if numBytes, ok := frictionless.Int(descriptor, "bytes"); ok {
+			download["contentSize"] = fmt.Sprintf("%d B", numBytes)
+		}
+		distribution = append(distribution, download)
+	}
+
+	dataset := map[string]any{
+		"@context":     "https://schema.org/",
+		"@type":        "Dataset",
+		"identifier":   "urn:uuid:" + task.Id.String(),
+		"name":         task.datasetTitle(),
+		"description":  task.datasetJSONLDDescription(),
+		"dateCreated":  manifestDescriptor["created"],
+		"distribution": distribution,
+		"provider": map[string]any{
+			"@type": "Organization",
+			"name":  config.Databases[task.Source].Organization,
+		},
+	}
+	if task.DOI != "" {
+		dataset["identifier"] = "https://doi.org/" + task.DOI
+	}
+
+	// map the credit metadata of the payload's files
+	payloadCredit := gatherCredit(descriptors)
+	if len(payloadCredit.Contributors) > 0 {
+		creators := make([]any, len(payloadCredit.Contributors))
+		for i, contributor := range payloadCredit.Contributors {
+			creators[i] = jsonLDContributor(contributor)
+		}
+		dataset["creator"] = creators
+	}
+	licenses := make([]any, len(payloadCredit.Licenses))
+	for i, license := range payloadCredit.Licenses {
+		licenses[i] = licenseURL(license)
+	}
+	if len(licenses) == 1 {
+		dataset["license"] = licenses[0]
+	} else if len(licenses) > 1 {
+		dataset["license"] = licenses
+	}
+	if len(payloadCredit.Funding) > 0 {
+		funders := make([]any, len(payloadCredit.Funding))
+		for i, funding := range payloadCredit.Funding {
+			funders[i] = jsonLDOrganization(funding.Funder)
+		}
+		dataset["funder"] = funders
+	}
+	if len(payloadCredit.Sources) > 0 {
+		dataset["isBasedOn"] = slices.Clone(payloadCredit.Sources)
+	}
+	if len(task.Tags) > 0 {
+		dataset["keywords"] = slices.Clone(task.Tags)
+	}
+	return dataset
+}
+
+// writes the JSON-LD dataset description for a task's payload (as listed in
+// the given manifest) to the manifest directory, recording its name in the
+// task and returning a transfer that delivers it alongside the manifest
+func (task *transferTask) writeJSONLD(manifest *datapackage.Package) ([]FileTransfer, error) {
+	task.removeJSONLD()
+	if !task.wantsJSONLD() {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(task.datasetJSONLD(manifest), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	jsonLDFile := filepath.Join(config.Service.ManifestDirectory,
+		fmt.Sprintf("dataset-%s.jsonld", task.Id.String()))
+	if err := os.WriteFile(jsonLDFile, data, 0644); err != nil {
+		return nil, fmt.Errorf("creating JSON-LD file: %s", err.Error())
+	}
+	task.JSONLDFile = jsonLDFile
+	return []FileTransfer{
+		{
+			SourcePath:      jsonLDFile,
+			DestinationPath: filepath.Join(task.DestinationFolder, jsonLDFileName),
+		},
+	}, nil
+}
+
+// removes the JSON-LD file written for a task
+func (task *transferTask) removeJSONLD() {
+	if task.JSONLDFile != "" {
+		os.Remove(task.JSONLDFile)
+	}
+	task.JSONLDFile = ""
+}
+
+// removes the JSON-LD file delivered to the given destination endpoint with a
+// retracted manifest (before removing the local copy)
+func (task *transferTask) retractJSONLD(destination endpoints.Endpoint) {
+	if task.JSONLDFile == "" {
+		return
+	}
+	path := filepath.Join(task.DestinationFolder, jsonLDFileName)
+	var err error
+	if deleter, ok := destination.(endpoints.DeletingEndpoint); ok {
+		err = deleter.Delete(path)
+	} else {
+		err = fmt.Errorf("destination endpoint can't delete files")
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't remove JSON-LD file %s: %s", task.Id.String(),
+			path, err.Error()))
+	}
+	task.removeJSONLD()
+}
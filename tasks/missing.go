@@ -0,0 +1,81 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+)
+
+// the reason recorded in manifests for requested files deleted from their
+// source database after they were found
+const missingAtSource = "missing at source"
+
+// returns true if requested files deleted from their source database are
+// omitted from payloads instead of failing transfers
+func lenientMissingFiles() bool {
+	return config.Service.MissingFilePolicy == "lenient"
+}
+
+// returns the IDs of the resources a database reported missing in the given
+// error, or nil if the error doesn't report missing resources
+func missingResourceIds(err error) []string {
+	var missing databases.ResourcesNotFoundError
+	if errors.As(err, &missing) {
+		return missing.ResourceIds
+	}
+	var missingPtr *databases.ResourcesNotFoundError
+	if errors.As(err, &missingPtr) {
+		return missingPtr.ResourceIds
+	}
+	return nil
+}
+
+// returns the given file IDs without any of the given missing ones
+func withoutMissingIds(fileIds, missing []string) []string {
+	return slices.DeleteFunc(slices.Clone(fileIds), func(fileId string) bool {
+		return slices.Contains(missing, fileId)
+	})
+}
+
+// returns descriptions of the requested files omitted from the task's payload
+// because they were deleted from the source database, in the order in which
+// they were requested
+func (task transferTask) missingFiles() []any {
+	var missing []any
+	for _, fileId := range task.MissingFiles {
+		missing = append(missing, map[string]any{"id": fileId, "reason": missingAtSource})
+	}
+	for _, subtask := range task.Subtasks {
+		for _, fileId := range subtask.MissingFiles {
+			missing = append(missing, map[string]any{"id": fileId, "reason": missingAtSource})
+		}
+	}
+	indices := requestIndices(task.FileIds)
+	slices.SortStableFunc(missing, func(a, b any) int {
+		return compareRequestIndices(indices, a.(map[string]any), b.(map[string]any))
+	})
+	return missing
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/units"
+)
+
+// the maximum number of files per source endpoint whose paths are checked by
+// an availability probe
+const probeSampleSize = 10
+
+// returns true if the task's payload is large enough that its sources are
+// probed before staging begins
+func (task transferTask) needsProbe() bool {
+	return config.Service.ProbeMinPayloadSize > 0 && !task.MetadataOnly &&
+		task.PayloadSize >= config.Service.ProbeMinPayloadSize
+}
+
+// checks that the source endpoint of each of the task's subtasks is reachable
+// and can see a sample of the subtask's files, returning a
+// SourceUnavailableError if any can't
+func (task *transferTask) probeSources() error {
+	for i := range task.Subtasks {
+		if err := task.Subtasks[i].probe(); err != nil {
+			return err
+		}
+	}
+	slog.Info(fmt.Sprintf("Task %s: probed %d source endpoint(s) for %s payload", task.Id,
+		len(task.Subtasks), units.FormatGigabytes(task.PayloadSize)))
+	return nil
+}
+
+// checks that the subtask's source endpoint is reachable and can see a sample
+// of its files (files that aren't yet staged are fine, but errors aren't)
+func (subtask *transferSubtask) probe() error {
+	sourceEndpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
+	if err != nil {
+		return &SourceUnavailableError{Endpoint: subtask.SourceEndpoint, Message: err.Error()}
+	}
+	descriptors, err := subtask.endpointDescriptors()
+	if err != nil {
+		return err
+	}
+	if _, err := sourceEndpoint.FilesStaged(sampleDescriptors(descriptors, probeSampleSize)); err != nil {
+		return &SourceUnavailableError{Endpoint: subtask.SourceEndpoint, Message: err.Error()}
+	}
+	return nil
+}
+
+// returns up to n of the given descriptors, evenly spaced and including the
+// first and last
+func sampleDescriptors(descriptors []any, n int) []any {
+	if len(descriptors) <= n {
+		return descriptors
+	}
+	sample := make([]any, n)
+	for i := range sample {
+		sample[i] = descriptors[i*(len(descriptors)-1)/(n-1)]
+	}
+	return sample
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"iter"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+)
+
+// Quotas limit the number of transfers a user (or DTS client) may have in
+// progress at once and the total size of the payloads they may request in any
+// 24-hour period. A new task is refused if either limit has already been
+// reached. Because a payload's size isn't known until its files have been
+// resolved, a task's payload is checked against its daily payload quotas again
+// when it's resolved, counting the payloads of all other tasks at that time
+// (including those resolved concurrently by other workers), and the task fails
+// if its payload exceeds what remains.
+
+// a quota applying to a task, with a function selecting the tasks that count
+// against it
+type taskQuota struct {
+	Subject                string
+	MaxConcurrentTransfers int
+	MaxDailyPayloadSize    float64
+	Counts                 func(task transferTask) bool
+}
+
+// returns the quotas that apply to the given task: that of its user (or the
+// default quota) and that of the DTS client through which it was requested
+// (if the client has one)
+func quotasFor(task transferTask) []taskQuota {
+	userQuota, found := config.Service.Quotas.Users[task.User.Orcid]
+	if !found {
+		userQuota = config.Service.Quotas.Default
+	}
+	quotas := []taskQuota{
+		{
+			Subject:                "user " + task.User.Orcid,
+			MaxConcurrentTransfers: userQuota.MaxConcurrentTransfers,
+			MaxDailyPayloadSize:    userQuota.MaxDailyPayloadSize,
+			Counts: func(t transferTask) bool {
+				return t.User.Orcid == task.User.Orcid
+			},
+		},
+	}
+	if clientQuota, found := config.Service.Quotas.Clients[task.Client]; found && task.Client != "" {
+		quotas = append(quotas, taskQuota{
+			Subject:                "client " + task.Client,
+			MaxConcurrentTransfers: clientQuota.MaxConcurrentTransfers,
+			MaxDailyPayloadSize:    clientQuota.MaxDailyPayloadSize,
+			Counts: func(t transferTask) bool {
+				return t.Client == task.Client
+			},
+		})
+	}
+	return quotas
+}
+
+// checks the given new task against the quotas that apply to it, given the
+// existing tasks, returning a QuotaExceededError if any has been reached
+func checkQuotas(newTask transferTask, tasks map[uuid.UUID]transferTask) error {
+	for _, quota := range quotasFor(newTask) {
+		if quota.MaxConcurrentTransfers == 0 && quota.MaxDailyPayloadSize == 0 {
+			continue
+		}
+		numTransfers := 0
+		for _, task := range tasks {
+			if quota.Counts(task) && !task.Completed() {
+				numTransfers++
+			}
+		}
+		if quota.MaxConcurrentTransfers > 0 && numTransfers >= quota.MaxConcurrentTransfers {
+			return &QuotaExceededError{
+				Subject:      quota.Subject,
+				Transfers:    numTransfers,
+				MaxTransfers: quota.MaxConcurrentTransfers,
+			}
+		}
+		if quota.MaxDailyPayloadSize > 0 {
+			if payloadSize := dailyPayloadSize(quota, maps.Values(tasks)); payloadSize >= quota.MaxDailyPayloadSize {
+				return &QuotaExceededError{
+					Subject:        quota.Subject,
+					PayloadSize:    payloadSize,
+					MaxPayloadSize: quota.MaxDailyPayloadSize,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// returns the total size of the payloads of the given tasks that count against
+// the given quota and were requested in the past day (and haven't failed)
+func dailyPayloadSize(quota taskQuota, tasks iter.Seq[transferTask]) float64 {
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	payloadSize := 0.0
+	for task := range tasks {
+		if quota.Counts(task) && task.StartTime.After(dayAgo) && task.Status.Code != TransferStatusFailed {
+			payloadSize += task.PayloadSize
+		}
+	}
+	return payloadSize
+}
+
+// the payloads of all tasks, refreshed by the task manager each time it
+// updates tasks and amended by each task as it's resolved, against which
+// resolved payloads are checked
+var payloadLedger = make(map[uuid.UUID]transferTask)
+var payloadLedgerMutex sync.Mutex
+
+// replaces the contents of the payload ledger with the payloads of the given
+// tasks
+func recordPayloads(tasks map[uuid.UUID]transferTask) {
+	payloadLedgerMutex.Lock()
+	defer payloadLedgerMutex.Unlock()
+	clear(payloadLedger)
+	maps.Copy(payloadLedger, tasks)
+}
+
+// checks the (resolved) payload of the given task against the daily payload
+// quotas that apply to it, given the payloads of all other tasks in the
+// ledger, returning a QuotaExceededError if it doesn't fit, and otherwise
+// recording it in the ledger
+func reservePayload(task transferTask) error {
+	payloadLedgerMutex.Lock()
+	defer payloadLedgerMutex.Unlock()
+	others := func(yield func(transferTask) bool) {
+		for taskId, other := range payloadLedger {
+			if taskId != task.Id && !yield(other) {
+				return
+			}
+		}
+	}
+	for _, quota := range quotasFor(task) {
+		if quota.MaxDailyPayloadSize == 0 {
+			continue
+		}
+		if used := dailyPayloadSize(quota, others); used+task.PayloadSize > quota.MaxDailyPayloadSize {
+			return &QuotaExceededError{
+				Subject:        quota.Subject,
+				PayloadSize:    used + task.PayloadSize,
+				MaxPayloadSize: quota.MaxDailyPayloadSize,
+			}
+		}
+	}
+	payloadLedger[task.Id] = task
+	return nil
+}
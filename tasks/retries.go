@@ -0,0 +1,138 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/faults"
+)
+
+// Submitting a transfer (or a manifest) to Globus or another provider can
+// fail for reasons that resolve themselves, like a brief outage. Instead of
+// failing its task, such a submission is retried after a backoff delay that
+// doubles with each failure, until the attempts allowed by the service's
+// retry policy are used up. Every attempt is recorded with the task.
+
+// records an attempt to submit a transfer or manifest
+type SubmissionAttempt struct {
+	Time  time.Time // time of the attempt
+	Error string    // error message (empty if the submission succeeded)
+}
+
+// tracks the attempts to submit a transfer or manifest and any pending retry
+type submissionRetries struct {
+	Attempts  []SubmissionAttempt // all attempts, oldest first
+	RetryTime time.Time           // time at which a failed submission is retried (zero if none)
+}
+
+// returns true if a failed submission is waiting to be retried
+func (retries submissionRetries) pending() bool {
+	return !retries.RetryTime.IsZero()
+}
+
+// returns true if a failed submission is waiting to be retried and its
+// backoff delay has elapsed
+func (retries submissionRetries) due() bool {
+	return retries.pending() && !time.Now().Before(retries.RetryTime)
+}
+
+// records a successful submission
+func (retries *submissionRetries) succeed() {
+	retries.Attempts = append(retries.Attempts, SubmissionAttempt{Time: time.Now()})
+	retries.RetryTime = time.Time{}
+}
+
+// records a submission that failed with the given error for the task with the
+// given ID, scheduling a retry and returning nil if the error may be transient
+// and the retry policy allows another attempt, or returning an error otherwise
+func (retries *submissionRetries) fail(taskId uuid.UUID, what string, err error) error {
+	retries.Attempts = append(retries.Attempts, SubmissionAttempt{
+		Time:  time.Now(),
+		Error: err.Error(),
+	})
+	retries.RetryTime = time.Time{}
+	if !transientSubmissionError(err) {
+		return err
+	}
+	numFailures := retries.consecutiveFailures()
+	if numFailures >= config.Service.Retries.MaxAttempts {
+		if numFailures == 1 { // no retry policy
+			return err
+		}
+		return &SubmissionRetriesExhaustedError{What: what, Attempts: numFailures, Err: err}
+	}
+	delay := retryBackoff(numFailures)
+	retries.RetryTime = time.Now().Add(delay)
+	slog.Warn(fmt.Sprintf("Task %s: %s submission failed (attempt %d of %d); retrying in %s: %s",
+		taskId.String(), what, numFailures, config.Service.Retries.MaxAttempts,
+		delay.Round(time.Second), err.Error()))
+	return nil
+}
+
+// returns the number of failed attempts since the last successful submission
+func (retries submissionRetries) consecutiveFailures() int {
+	numFailures := 0
+	for i := len(retries.Attempts) - 1; i >= 0 && retries.Attempts[i].Error != ""; i-- {
+		numFailures++
+	}
+	return numFailures
+}
+
+// returns the delay before retrying a submission that has failed the given
+// number of times in a row, doubling the configured initial backoff for each
+// failure after the first (up to the configured maximum) and applying jitter
+func retryBackoff(numFailures int) time.Duration {
+	policy := config.Service.Retries
+	delay := time.Duration(policy.InitialBackoff) * time.Second
+	maxDelay := time.Duration(policy.MaxBackoff) * time.Second
+	for i := 1; i < numFailures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	jitter := policy.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(float64(delay) * (1 + jitter))
+}
+
+// returns true if the given submission error may be transient, so that the
+// submission is worth retrying
+func transientSubmissionError(err error) bool {
+	var globusErr *globus.GlobusError
+	if errors.As(err, &globusErr) {
+		return globusErr.Transient()
+	}
+	var faultErr *faults.InjectedFaultError
+	if errors.As(err, &faultErr) {
+		return faultErr.Fault == faults.SubmissionFailure
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// returns true if the given rune can appear unaltered in a sanitized path
+func safePathRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '.' || r == '_' || r == '-'
+}
+
+// sanitizes each component of the given (slash-separated) destination path
+// using the given method ("replace" or "encode"), returning the path unaltered
+// for any other method
+func sanitizePath(path, method string) string {
+	if method != "replace" && method != "encode" {
+		return path
+	}
+	components := strings.Split(path, "/")
+	for i, component := range components {
+		var b strings.Builder
+		for _, r := range component {
+			if safePathRune(r) {
+				b.WriteRune(r)
+			} else if method == "replace" {
+				b.WriteByte('_')
+			} else { // percent-encode each byte of the rune
+				for _, c := range []byte(string(r)) {
+					fmt.Fprintf(&b, "%%%02X", c)
+				}
+			}
+		}
+		components[i] = b.String()
+	}
+	return strings.Join(components, "/")
+}
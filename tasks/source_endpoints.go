@@ -0,0 +1,171 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/frictionless"
+)
+
+// Transfer instructions can override the endpoints from which a source
+// database's files are transferred, for databases with several endpoints that
+// mirror one another:
+//
+//   - "source_endpoint" names an endpoint from which all files are transferred
+//   - "exclude_source_endpoints" lists endpoints from which no files are
+//     transferred (e.g. during downtime); their files are transferred from the
+//     endpoint given by "source_endpoint", or from the database's only
+//     remaining endpoint
+//
+// Endpoints can be named by their functional names within the database's
+// configuration (e.g. "nersc" or "emsl" for NMDC) or by their own names. Files
+// reassigned to other endpoints keep their paths unless their database lays
+// out its endpoints differently, in which case the database relocates them or
+// refuses to (e.g. NMDC, whose endpoints hold different files).
+
+// returns a mapping of the source database's endpoints to the endpoints that
+// replace them, as given in the "source_endpoint" and "exclude_source_endpoints"
+// fields of the given transfer instructions (nil if neither is present)
+func sourceEndpointOverrides(source string, instructions map[string]any) (map[string]string, error) {
+	forcedValue, forcing := instructions["source_endpoint"]
+	excludedValue, excluding := instructions["exclude_source_endpoints"]
+	if !forcing && !excluding {
+		return nil, nil
+	}
+
+	available := databaseEndpoints(source)
+	var forced string
+	if forcing {
+		name, ok := forcedValue.(string)
+		if !ok {
+			return nil, &InvalidSourceEndpointError{Message: "source_endpoint must be a string"}
+		}
+		if forced, ok = available[name]; !ok {
+			return nil, &InvalidSourceEndpointError{
+				Message: fmt.Sprintf("'%s' is not an endpoint of database '%s'", name, source),
+			}
+		}
+	}
+	excluded := make(map[string]bool)
+	if excluding {
+		names, ok := excludedValue.([]any)
+		if !ok {
+			return nil, &InvalidSourceEndpointError{Message: "exclude_source_endpoints must be an array of strings"}
+		}
+		for _, n := range names {
+			name, ok := n.(string)
+			if !ok {
+				return nil, &InvalidSourceEndpointError{Message: "exclude_source_endpoints must be an array of strings"}
+			}
+			endpoint, ok := available[name]
+			if !ok {
+				return nil, &InvalidSourceEndpointError{
+					Message: fmt.Sprintf("'%s' is not an endpoint of database '%s'", name, source),
+				}
+			}
+			excluded[endpoint] = true
+		}
+	}
+
+	// determine the endpoint that replaces excluded ones
+	replacement := forced
+	if forced != "" && excluded[forced] {
+		return nil, &InvalidSourceEndpointError{
+			Message: fmt.Sprintf("source endpoint '%s' is also excluded", forced),
+		}
+	}
+	endpoints := slices.Sorted(maps.Values(available))
+	endpoints = slices.Compact(endpoints)
+	if replacement == "" {
+		var remaining []string
+		for _, endpoint := range endpoints {
+			if !excluded[endpoint] {
+				remaining = append(remaining, endpoint)
+			}
+		}
+		switch len(remaining) {
+		case 0:
+			return nil, &InvalidSourceEndpointError{
+				Message: fmt.Sprintf("all endpoints of database '%s' are excluded", source),
+			}
+		case 1:
+			replacement = remaining[0]
+		default:
+			return nil, &InvalidSourceEndpointError{
+				Message: fmt.Sprintf("database '%s' has several remaining endpoints, so source_endpoint must select one",
+					source),
+			}
+		}
+	}
+
+	overrides := make(map[string]string)
+	for _, endpoint := range endpoints {
+		if endpoint != replacement && (forced != "" || excluded[endpoint]) {
+			overrides[endpoint] = replacement
+		}
+	}
+	return overrides, nil
+}
+
+// returns a mapping of the names by which the given database's endpoints can
+// be selected (functional names and endpoint names) to the endpoint names
+func databaseEndpoints(database string) map[string]string {
+	dbConfig := config.Databases[database]
+	available := make(map[string]string)
+	if dbConfig.Endpoint != "" {
+		available[dbConfig.Endpoint] = dbConfig.Endpoint
+	}
+	for functionalName, endpoint := range dbConfig.Endpoints {
+		available[functionalName] = endpoint
+		available[endpoint] = endpoint
+	}
+	return available
+}
+
+// reassigns the given file descriptors from the given source database to
+// source endpoints according to the given overrides, relocating their paths
+// if the database lays out its endpoints differently
+func overrideSourceEndpoints(source databases.Database, descriptors []map[string]any,
+	overrides map[string]string) error {
+	relocator, relocating := source.(databases.EndpointRelocator)
+	for _, descriptor := range descriptors {
+		endpoint, _ := descriptor["endpoint"].(string)
+		replacement, found := overrides[endpoint]
+		if !found {
+			continue
+		}
+		if relocating {
+			path, err := relocator.RelocatePath(frictionless.String(descriptor, "path"), endpoint, replacement)
+			if err != nil {
+				return &InvalidSourceEndpointError{Message: err.Error()}
+			}
+			descriptor["path"] = path
+		}
+		descriptor["endpoint"] = replacement
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/store"
+)
+
+// set if the task store couldn't be read when tasks were loaded, in which case
+// it's never overwritten (which would discard the tasks it holds)
+var storeLoadError error
+
+// loads a map of task IDs to tasks from the task store, importing any tasks
+// from a legacy save file, or creates an empty map if no tasks are available.
+// A task that can't be decoded is dropped without affecting the others. If
+// the store can't be read at all, no tasks are loaded and none are saved.
+func createOrLoadTasks() map[uuid.UUID]transferTask {
+	tasks := make(map[uuid.UUID]transferTask)
+	state, err := store.Load()
+	storeLoadError = err
+	if err != nil {
+		slog.Error(fmt.Sprintf("Reading task store: %s (tasks won't be saved until the service is restarted)",
+			err.Error()))
+		return tasks
+	}
+	for _, key := range state.Skipped {
+		slog.Error(fmt.Sprintf("Task store: skipped unreadable record %s", key))
+	}
+	for _, transfer := range state.Transfers {
+		task, err := decodeTask(transfer)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Task %s: can't restore from task store: %s",
+				transfer.Id.String(), err.Error()))
+			continue
+		}
+		tasks[task.Id] = task
+	}
+
+	// tasks saved by earlier versions of the DTS are moved into the store
+	legacyFile := filepath.Join(config.Service.DataDirectory, legacySaveFile)
+	legacyTasks, legacyStates, foundLegacy := loadLegacySaveFile(legacyFile)
+	if foundLegacy {
+		maps.Copy(tasks, legacyTasks)
+		maps.Copy(state.Databases.Data, legacyStates.Data)
+	}
+
+	if err = databases.Load(state.Databases); err != nil {
+		slog.Error(fmt.Sprintf("Restoring database states: %s", err.Error()))
+	}
+	for taskId, task := range tasks {
+		task.interruptManifest()
+		tasks[taskId] = task
+	}
+
+	// retire the legacy save file only once its tasks are safely stored
+	if foundLegacy {
+		if err = saveTasks(tasks); err != nil {
+			slog.Error(err.Error())
+		} else {
+			importedFile := legacyFile + ".imported"
+			os.Rename(legacyFile, importedFile)
+			slog.Info(fmt.Sprintf("Imported %d tasks from %s (moved to %s)", len(legacyTasks),
+				legacyFile, importedFile))
+		}
+	}
+	slog.Debug(fmt.Sprintf("Restored %d tasks from task store", len(tasks)))
+	return tasks
+}
+
+// saves a map of task IDs to tasks (and the states of all databases) to the
+// task store in a single transaction
+func saveTasks(tasks map[uuid.UUID]transferTask) error {
+	if storeLoadError != nil {
+		return fmt.Errorf("saving tasks: not overwriting a task store that couldn't be read (%s)",
+			storeLoadError.Error())
+	}
+	if len(tasks) > 0 {
+		// leave any previously saved tasks intact if we can't safely write
+		if err := checkDiskSpace(config.Service.DataDirectory); err != nil {
+			return fmt.Errorf("saving tasks: %s", err.Error())
+		}
+	}
+	state := store.State{
+		Transfers: make([]store.Transfer, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		transfer, err := encodeTask(task)
+		if err != nil {
+			return fmt.Errorf("saving task %s: %s", task.Id.String(), err.Error())
+		}
+		state.Transfers = append(state.Transfers, transfer)
+	}
+	databaseStates, err := databases.Save()
+	if err != nil {
+		return fmt.Errorf("saving tasks: %s", err.Error())
+	}
+	state.Databases = databaseStates
+	if err = store.Save(state); err != nil {
+		return fmt.Errorf("saving tasks: %s", err.Error())
+	}
+	slog.Debug(fmt.Sprintf("Saved %d tasks to task store", len(tasks)))
+	return nil
+}
+
+// encodes the given task and its subtasks as separate JSON documents
+func encodeTask(task transferTask) (store.Transfer, error) {
+	transfer := store.Transfer{
+		Id:       task.Id,
+		Subtasks: make([]json.RawMessage, len(task.Subtasks)),
+	}
+	for i, subtask := range task.Subtasks {
+		data, err := json.Marshal(subtask)
+		if err != nil {
+			return transfer, err
+		}
+		transfer.Subtasks[i] = data
+	}
+	task.Subtasks = nil
+	data, err := json.Marshal(task)
+	if err != nil {
+		return transfer, err
+	}
+	transfer.Data = data
+	return transfer, nil
+}
+
+// reassembles a task from its JSON document and those of its subtasks
+func decodeTask(transfer store.Transfer) (transferTask, error) {
+	var task transferTask
+	if err := json.Unmarshal(transfer.Data, &task); err != nil {
+		return task, err
+	}
+	if task.Id != transfer.Id {
+		return task, fmt.Errorf("stored under mismatched ID %s", transfer.Id.String())
+	}
+	task.Subtasks = make([]transferSubtask, len(transfer.Subtasks))
+	for i, data := range transfer.Subtasks {
+		if err := json.Unmarshal(data, &task.Subtasks[i]); err != nil {
+			return task, fmt.Errorf("subtask %d: %s", i, err.Error())
+		}
+	}
+	return task, nil
+}
+
+//--------------------
+// Legacy save files
+//--------------------
+
+// Before the task store existed, tasks were saved to a file in the data
+// directory. Save files begin with a header consisting of a magic string, the
+// schema version of their contents (a big-endian uint32), and a SHA-256
+// checksum of those contents. The contents are a gob-encoded map of task IDs
+// to tasks followed by gob-encoded database save states.
+const (
+	legacySaveFile  = "dts.gob"
+	saveFileMagic   = "DTSSTATE"
+	saveFileVersion = uint32(1) // last schema version written
+)
+
+// functions that decode the contents of save files of each schema version
+var saveFileDecoders = map[uint32]func(contents []byte) (map[uuid.UUID]transferTask, databases.DatabaseSaveStates, error){
+	0: decodeSaveFileV0, // headerless files written before versioning
+	1: decodeSaveFileV1,
+}
+
+// the layouts of tasks and subtasks in headerless (version 0) save files,
+// frozen here so that changes to the current types don't affect how these
+// files are read
+type transferTaskV0 struct {
+	Canceled          bool
+	StartTime         time.Time
+	CompletionTime    time.Time
+	DataDescriptors   []any
+	Description       string
+	Destination       string
+	DestinationFolder string
+	FileIds           []string
+	Id                uuid.UUID
+	Instructions      map[string]any
+	Manifest          uuid.NullUUID
+	ManifestFile      string
+	PayloadSize       float64
+	Source            string
+	Status            transferStatusV0
+	Subtasks          []transferSubtaskV0
+	User              auth.User
+}
+
+type transferSubtaskV0 struct {
+	Destination       string
+	DestinationFolder string
+	Descriptors       []any
+	Source            string
+	SourceEndpoint    string
+	Staging           uuid.NullUUID
+	StagingStatus     databases.StagingStatus
+	Transfer          uuid.NullUUID
+	TransferStatus    transferStatusV0
+	User              auth.User
+}
+
+type transferStatusV0 struct {
+	Code                TransferStatusCode
+	Message             string
+	NumFiles            int
+	NumFilesTransferred int
+	NumFilesSkipped     int
+}
+
+// loads tasks and database states from the given legacy save file, returning
+// true if the file was found and could be read. An unreadable file is set
+// aside for inspection.
+func loadLegacySaveFile(dataFile string) (map[uuid.UUID]transferTask, databases.DatabaseSaveStates, bool) {
+	data, err := os.ReadFile(dataFile)
+	if err != nil {
+		return nil, databases.DatabaseSaveStates{}, false
+	}
+	slog.Debug(fmt.Sprintf("Found previous tasks in %s.", dataFile))
+	tasks, databaseStates, err := decodeSaveFile(data)
+	if err != nil {
+		badFile := dataFile + ".bad"
+		slog.Error(fmt.Sprintf("Reading task file %s: %s (moved to %s)", dataFile,
+			err.Error(), badFile))
+		os.Rename(dataFile, badFile)
+		return nil, databases.DatabaseSaveStates{}, false
+	}
+	return tasks, databaseStates, true
+}
+
+// decodes the contents of a save file, verifying its integrity and migrating
+// its contents from older schema versions as needed
+func decodeSaveFile(data []byte) (map[uuid.UUID]transferTask, databases.DatabaseSaveStates, error) {
+	version := uint32(0) // no header
+	if bytes.HasPrefix(data, []byte(saveFileMagic)) {
+		headerSize := len(saveFileMagic) + 4 + sha256.Size
+		if len(data) < headerSize {
+			return nil, databases.DatabaseSaveStates{}, fmt.Errorf("truncated header")
+		}
+		version = binary.BigEndian.Uint32(data[len(saveFileMagic):])
+		checksum := data[len(saveFileMagic)+4 : headerSize]
+		data = data[headerSize:]
+		if actual := sha256.Sum256(data); !bytes.Equal(checksum, actual[:]) {
+			return nil, databases.DatabaseSaveStates{}, fmt.Errorf("checksum mismatch")
+		}
+	}
+	decode, found := saveFileDecoders[version]
+	if !found {
+		return nil, databases.DatabaseSaveStates{},
+			fmt.Errorf("unsupported schema version %d (last version is %d)",
+				version, saveFileVersion)
+	}
+	return decode(data)
+}
+
+// decodes the contents of a headerless (version 0) save file, converting its
+// tasks to the current layout
+func decodeSaveFileV0(contents []byte) (map[uuid.UUID]transferTask, databases.DatabaseSaveStates, error) {
+	var oldTasks map[uuid.UUID]transferTaskV0
+	var databaseStates databases.DatabaseSaveStates
+	dec := gob.NewDecoder(bytes.NewReader(contents))
+	err := dec.Decode(&oldTasks)
+	if err == nil {
+		err = dec.Decode(&databaseStates)
+	}
+	if err != nil {
+		return nil, databaseStates, err
+	}
+	tasks := make(map[uuid.UUID]transferTask, len(oldTasks))
+	for taskId, oldTask := range oldTasks {
+		task := transferTask{
+			Canceled:          oldTask.Canceled,
+			StartTime:         oldTask.StartTime,
+			CompletionTime:    oldTask.CompletionTime,
+			DataDescriptors:   oldTask.DataDescriptors,
+			Description:       oldTask.Description,
+			Destination:       oldTask.Destination,
+			DestinationFolder: oldTask.DestinationFolder,
+			FileIds:           oldTask.FileIds,
+			Id:                oldTask.Id,
+			Instructions:      oldTask.Instructions,
+			Manifest:          oldTask.Manifest,
+			ManifestFile:      oldTask.ManifestFile,
+			PayloadSize:       oldTask.PayloadSize,
+			Source:            oldTask.Source,
+			Status:            oldTask.Status.migrate(),
+			Subtasks:          make([]transferSubtask, len(oldTask.Subtasks)),
+			User:              oldTask.User,
+		}
+		for i, oldSubtask := range oldTask.Subtasks {
+			task.Subtasks[i] = transferSubtask{
+				Destination:       oldSubtask.Destination,
+				DestinationFolder: oldSubtask.DestinationFolder,
+				Descriptors:       oldSubtask.Descriptors,
+				Source:            oldSubtask.Source,
+				SourceEndpoint:    oldSubtask.SourceEndpoint,
+				Staging:           oldSubtask.Staging,
+				StagingStatus:     oldSubtask.StagingStatus,
+				Transfer:          oldSubtask.Transfer,
+				TransferStatus:    oldSubtask.TransferStatus.migrate(),
+				User:              oldSubtask.User,
+			}
+		}
+		migrateLegacyTask(&task)
+		tasks[taskId] = task
+	}
+	return tasks, databaseStates, nil
+}
+
+// converts a version 0 transfer status to the current layout
+func (status transferStatusV0) migrate() TransferStatus {
+	return TransferStatus{
+		Code:                status.Code,
+		Message:             status.Message,
+		NumFiles:            status.NumFiles,
+		NumFilesTransferred: status.NumFilesTransferred,
+		NumFilesSkipped:     status.NumFilesSkipped,
+	}
+}
+
+// decodes the contents of a version 1 save file. The current layouts of tasks
+// and subtasks only add fields to those of version 1, so they're decoded as
+// they are, and the fields missing from earlier files are filled in.
+func decodeSaveFileV1(contents []byte) (map[uuid.UUID]transferTask, databases.DatabaseSaveStates, error) {
+	var tasks map[uuid.UUID]transferTask
+	var databaseStates databases.DatabaseSaveStates
+	dec := gob.NewDecoder(bytes.NewReader(contents))
+	err := dec.Decode(&tasks)
+	if err == nil {
+		err = dec.Decode(&databaseStates)
+	}
+	if err != nil {
+		return nil, databaseStates, err
+	}
+	for taskId, task := range tasks {
+		migrateLegacyTask(&task)
+		tasks[taskId] = task
+	}
+	return tasks, databaseStates, nil
+}
+
+// fills in fields of a task read from a legacy save file that weren't saved
+// by the version of the DTS that wrote it
+func migrateLegacyTask(task *transferTask) {
+	if task.ProcessingTime.IsZero() && len(task.Subtasks) > 0 {
+		task.ProcessingTime = task.StartTime // best available estimate
+	}
+	now := time.Now()
+	for i := range task.Subtasks {
+		subtask := &task.Subtasks[i]
+		if subtask.TaskId == uuid.Nil {
+			subtask.TaskId = task.Id
+		}
+		// timers of staging and transfers in progress restart with the import
+		if subtask.StagingStartTime.IsZero() && subtask.StagingStatus == databases.StagingStatusActive {
+			subtask.StagingStartTime = now
+		}
+		if subtask.TransferStartTime.IsZero() && subtask.Transfer.Valid {
+			subtask.TransferStartTime = now
+		}
+	}
+}
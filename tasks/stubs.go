@@ -0,0 +1,137 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/frictionless"
+)
+
+// the suffix appended to the path of a file to form the path of the stub that
+// describes it in a metadata-only transfer
+const stubSuffix = ".dts-stub.json"
+
+// returns the path (relative to the destination folder) of the stub that
+// describes the file with the given descriptor path in a metadata-only transfer
+func (subtask *transferSubtask) stubPath(path string) string {
+	return sanitizePath(subtask.sourcePath(path), config.Service.PathSanitization) + stubSuffix
+}
+
+// returns a small metadata record describing the remote file with the given
+// descriptor, allowing it to be retrieved later from its source
+func (subtask *transferSubtask) stub(descriptor map[string]any) map[string]any {
+	path := subtask.sourcePath(frictionless.String(descriptor, "path"))
+	endpoint := config.Endpoints[subtask.SourceEndpoint]
+	stub := map[string]any{
+		"id":          frictionless.String(descriptor, "id"),
+		"name":        frictionless.String(descriptor, "name"),
+		"source":      subtask.Source,
+		"endpoint":    subtask.SourceEndpoint,
+		"endpoint_id": endpoint.Id.String(),
+		"provider":    endpoint.Provider,
+		"path":        path,
+		"transfer_id": subtask.TaskId.String(),
+	}
+	for _, field := range []string{"bytes", "hash", "format", "mediatype"} {
+		if value, found := descriptor[field]; found {
+			stub[field] = value
+		}
+	}
+	if remoteUrl := remoteURL(descriptor, subtask.SourceEndpoint, path); remoteUrl != "" {
+		stub["url"] = remoteUrl
+	}
+	return stub
+}
+
+// returns a URL at which the file with the given descriptor and path (relative
+// to the given source endpoint's root) can be accessed, or an empty string if
+// none is known: the URL in the file's credit metadata if it has one, or the
+// Globus web app's view of the folder containing it at a Globus endpoint
+func remoteURL(descriptor map[string]any, endpointName, path string) string {
+	switch c := descriptor["credit"].(type) {
+	case credit.CreditMetadata:
+		if c.Url != "" {
+			return c.Url
+		}
+	case map[string]any: // (decoded from JSON)
+		if creditUrl := frictionless.String(c, "url"); creditUrl != "" {
+			return creditUrl
+		}
+	}
+	endpoint := config.Endpoints[endpointName]
+	if endpoint.Provider == "globus" {
+		folder := filepath.Join("/", endpoint.Root, filepath.Dir(path)) + "/"
+		return "https://app.globus.org/file-manager?" + url.Values{
+			"origin_id":   {endpoint.Id.String()},
+			"origin_path": {folder},
+		}.Encode()
+	}
+	return ""
+}
+
+// writes stubs describing the files in a metadata-only task's payload to the
+// manifest directory, recording their names in the task and returning
+// transfers that deliver them alongside the manifest
+func (task *transferTask) writeStubs() ([]FileTransfer, error) {
+	var fileXfers []FileTransfer
+	task.StubFiles = nil
+	for i := range task.Subtasks {
+		subtask := &task.Subtasks[i]
+		for _, d := range subtask.Descriptors {
+			descriptor, ok := d.(map[string]any)
+			if !ok {
+				continue
+			}
+			data, err := json.MarshalIndent(subtask.stub(descriptor), "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			stubFile := filepath.Join(config.Service.ManifestDirectory,
+				fmt.Sprintf("stub-%s-%d.json", task.Id.String(), len(task.StubFiles)))
+			if err := os.WriteFile(stubFile, data, 0644); err != nil {
+				return nil, fmt.Errorf("creating stub file: %s", err.Error())
+			}
+			task.StubFiles = append(task.StubFiles, stubFile)
+			fileXfers = append(fileXfers, FileTransfer{
+				SourcePath: stubFile,
+				DestinationPath: filepath.Join(task.DestinationFolder,
+					subtask.stubPath(frictionless.String(descriptor, "path"))),
+			})
+		}
+	}
+	return fileXfers, nil
+}
+
+// removes the stub files written for a metadata-only task
+func (task *transferTask) removeStubs() {
+	for _, stubFile := range task.StubFiles {
+		os.Remove(stubFile)
+	}
+	task.StubFiles = nil
+}
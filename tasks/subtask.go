@@ -22,9 +22,14 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -32,8 +37,21 @@ import (
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/faults"
+	"github.com/kbase/dts/formats"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/tracing"
+	"github.com/kbase/dts/units"
 )
 
+// the mover recorded for subtasks whose files are downloaded directly over HTTPS
+const httpsMover = "https"
+
+// the subfolder of a transfer's destination folder into which delivered files
+// that fail checksum verification are moved
+const quarantineFolder = "quarantine"
+
 // This type tracks subtasks within a transfer (e.g. files transferred from
 // multiple endpoints attached to a single source/destination database pair).
 // It holds multiple (possibly null) UUIDs corresponding to different
@@ -44,20 +62,49 @@ type transferSubtask struct {
 	Descriptors       []any                   // Frictionless file descriptors
 	Source            string                  // name of source database (in config)
 	SourceEndpoint    string                  // name of source endpoint (in config)
+	TaskId            uuid.UUID               // ID of the task to which the subtask belongs
+	SkipChecksums     bool                    // set if file checksums are not submitted
+	SkipSourceErrors  bool                    // set if files with source errors are skipped
+	MetadataOnly      bool                    // set if files are described, not transferred
+	Mover             string                  // provider moving files ("https" for direct downloads)
+	SkippedFiles      map[string]string       // errors for files skipped by the endpoint, by file ID
+	MissingFiles      []string                // IDs of files deleted from the source database before staging
+	CorruptFiles      map[string]string       // quarantined paths of files failing verification, by file ID
+	FilterRules       []FilterRule            // rules selecting contents of directory payloads
+	RelayEndpoint     string                  // name of intermediate endpoint relaying files (if any)
+	RenamedPaths      map[string]string       // sanitized destination paths, by original path
+	Retries           submissionRetries       // attempts to submit the subtask's transfers
+	SubmissionId      uuid.NullUUID           // ID under which the current transfer is submitted (reused by retries)
+	Relayed           bool                    // set once files have arrived at the relay endpoint
 	Staging           uuid.NullUUID           // staging UUID (if any)
+	StagingStartTime  time.Time               // time at which staging began (if any)
 	StagingStatus     databases.StagingStatus // staging status
 	Transfer          uuid.NullUUID           // file transfer UUID (if any)
+	TransferStartTime time.Time               // time at which the current transfer began
 	TransferStatus    TransferStatus          // status of file transfer operation
+	Trace             tracing.SpanContext     // context of the span tracing the task's work
 	User              auth.User               // info about user requesting transfer
 }
 
 func (subtask *transferSubtask) start() error {
+	if subtask.MetadataOnly { // nothing to stage or transfer
+		subtask.TransferStatus = TransferStatus{
+			Code:     TransferStatusSucceeded,
+			NumFiles: len(subtask.Descriptors),
+		}
+		return nil
+	}
+
 	// are the files already staged? (only works for public data)
 	sourceEndpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
 	if err != nil {
 		return err
 	}
-	staged, err := sourceEndpoint.FilesStaged(subtask.Descriptors)
+	descriptors, err := subtask.endpointDescriptors()
+	if err != nil {
+		return err
+	}
+	staged, err := sourceEndpoint.FilesStaged(descriptors)
 	if err != nil {
 		return err
 	}
@@ -73,10 +120,29 @@ func (subtask *transferSubtask) start() error {
 		}
 		fileIds := make([]string, len(subtask.Descriptors))
 		for i, d := range subtask.Descriptors {
-			descriptor := d.(map[string]any)
-			fileIds[i] = descriptor["id"].(string)
+			descriptor, err := fileDescriptor(d)
+			if err != nil {
+				return err
+			}
+			fileIds[i] = descriptor.Id
 		}
 		taskId, err := source.StageFiles(subtask.User.Orcid, fileIds)
+		if len(missingResourceIds(err)) > 0 && lenientMissingFiles() {
+			// drop files deleted from the source database and stage the rest
+			missing, findErr := subtask.findMissingFiles(source, fileIds)
+			if findErr != nil || len(missing) == 0 {
+				return err
+			}
+			subtask.dropMissingFiles(missing)
+			fileIds = withoutMissingIds(fileIds, missing)
+			if len(fileIds) == 0 { // nothing left to stage or transfer
+				subtask.TransferStatus = TransferStatus{
+					Code: TransferStatusSucceeded,
+				}
+				return nil
+			}
+			taskId, err = source.StageFiles(subtask.User.Orcid, fileIds)
+		}
 		if err != nil {
 			return err
 		}
@@ -84,6 +150,7 @@ func (subtask *transferSubtask) start() error {
 			UUID:  taskId,
 			Valid: true,
 		}
+		subtask.StagingStartTime = time.Now()
 		subtask.TransferStatus = TransferStatus{
 			Code:     TransferStatusStaging,
 			NumFiles: len(subtask.Descriptors),
@@ -92,10 +159,43 @@ func (subtask *transferSubtask) start() error {
 	return err
 }
 
+// determines which of the files with the given IDs have been deleted from the
+// given source database by looking each of them up individually (databases
+// can't always tell which files in a staging request are missing)
+func (subtask *transferSubtask) findMissingFiles(source databases.Database,
+	fileIds []string) ([]string, error) {
+	var missing []string
+	for _, fileId := range fileIds {
+		_, err := source.Descriptors(subtask.User.Orcid, []string{fileId})
+		if len(missingResourceIds(err)) > 0 {
+			missing = append(missing, fileId)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// removes the descriptors for the files with the given IDs from the subtask,
+// recording them as missing at source
+func (subtask *transferSubtask) dropMissingFiles(missing []string) {
+	slog.Warn(fmt.Sprintf("Task %s: %d file(s) missing at source before staging",
+		subtask.TaskId, len(missing)))
+	subtask.MissingFiles = append(subtask.MissingFiles, missing...)
+	subtask.Descriptors = slices.DeleteFunc(subtask.Descriptors, func(d any) bool {
+		descriptor, err := fileDescriptor(d)
+		return err == nil && slices.Contains(missing, descriptor.Id)
+	})
+}
+
 // updates the state of a subtask, setting its status as necessary
 func (subtask *transferSubtask) update() error {
 	var err error
-	if subtask.Staging.Valid { // we're staging
+	if subtask.Retries.pending() { // waiting to resubmit a failed transfer
+		if subtask.Retries.due() {
+			err = subtask.beginTransfer()
+		}
+	} else if subtask.Staging.Valid { // we're staging
 		err = subtask.checkStaging()
 	} else if subtask.Transfer.Valid { // we're transferring
 		err = subtask.checkTransfer()
@@ -115,6 +215,11 @@ func (subtask *transferSubtask) checkStaging() error {
 	if err != nil {
 		return err
 	}
+	if faults.Triggered(subtask.TaskId, faults.StagingTimeout) {
+		slog.Warn(faults.InjectedFaultError{TaskId: subtask.TaskId, Fault: faults.StagingTimeout}.Error())
+		subtask.StagingStatus = databases.StagingStatusFailed
+		return nil
+	}
 
 	if subtask.StagingStatus == databases.StagingStatusSucceeded { // staged!
 		if config.Service.DoubleCheckStaging {
@@ -123,7 +228,11 @@ func (subtask *transferSubtask) checkStaging() error {
 			if err != nil {
 				return err
 			}
-			staged, err := endpoint.FilesStaged(subtask.Descriptors)
+			descriptors, err := subtask.endpointDescriptors()
+			if err != nil {
+				return err
+			}
+			staged, err := endpoint.FilesStaged(descriptors)
 			if err != nil {
 				return err
 			}
@@ -132,6 +241,7 @@ func (subtask *transferSubtask) checkStaging() error {
 					subtask.Source, subtask.SourceEndpoint)
 			}
 		}
+		recordStagingDuration(subtask.Source, time.Since(subtask.StagingStartTime))
 		return subtask.beginTransfer() // move along
 	}
 	return nil
@@ -141,26 +251,197 @@ func (subtask *transferSubtask) checkStaging() error {
 // initiates the generation of the file manifest
 func (subtask *transferSubtask) checkTransfer() error {
 	// has the data transfer completed?
-	sourceEndpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
+	endpoint, err := subtask.transferEndpoint()
 	if err != nil {
 		return err
 	}
-	subtask.TransferStatus, err = sourceEndpoint.Status(subtask.Transfer.UUID)
+	if faults.Triggered(subtask.TaskId, faults.TokenExpiry) {
+		return &faults.InjectedFaultError{TaskId: subtask.TaskId, Fault: faults.TokenExpiry}
+	}
+	subtask.TransferStatus, err = endpoint.Status(subtask.Transfer.UUID)
 	if err != nil {
 		return err
 	}
+	subtask.fillTransferProgress()
 	if subtask.TransferStatus.Code == TransferStatusSucceeded ||
 		subtask.TransferStatus.Code == TransferStatusFailed { // transfer finished
+		transferId := subtask.Transfer.UUID
 		subtask.Transfer = uuid.NullUUID{}
+		if subtask.TransferStatus.Code == TransferStatusSucceeded {
+			recordTransferRate(subtask.Source, subtask.TransferStatus.NumBytesTransferred,
+				time.Since(subtask.TransferStartTime))
+			if err := subtask.checkSkippedFiles(endpoint, transferId); err != nil {
+				slog.Warn(fmt.Sprintf("Task %s: couldn't retrieve skipped files: %s",
+					subtask.TaskId.String(), err.Error()))
+			}
+		}
+		if subtask.RelayEndpoint != "" && subtask.TransferStatus.Code == TransferStatusSucceeded {
+			if !subtask.Relayed { // files have reached the relay, so send them along
+				subtask.Relayed = true
+				return subtask.beginTransfer()
+			}
+			subtask.cleanUpRelay()
+		}
+	}
+	return nil
+}
+
+// fills in the size and rate of the subtask's transfer if its endpoint doesn't
+// report them, using the sizes of the subtask's files and the time elapsed since
+// the transfer began
+func (subtask *transferSubtask) fillTransferProgress() {
+	status := &subtask.TransferStatus
+	if status.NumBytes == 0 {
+		status.NumBytes = subtask.payloadBytes()
+	}
+	if status.BytesPerSecond == 0 && status.Code == TransferStatusActive {
+		elapsed := time.Since(subtask.TransferStartTime)
+		if status.NumBytesTransferred > 0 && elapsed > 0 {
+			status.BytesPerSecond = float64(status.NumBytesTransferred) / elapsed.Seconds()
+		}
+	}
+}
+
+// returns the endpoint responsible for the subtask's current transfer: the
+// destination endpoint if it's downloading files directly, the relay endpoint
+// if files are being relayed from it, or the source endpoint
+func (subtask *transferSubtask) transferEndpoint() (endpoints.Endpoint, error) {
+	if subtask.Mover == httpsMover { // destination is fetching files directly
+		return resolveDestinationEndpoint(subtask.Destination)
+	}
+	if subtask.Relayed {
+		return endpoints.NewEndpoint(subtask.RelayEndpoint)
+	}
+	return endpoints.NewEndpoint(subtask.SourceEndpoint)
+}
+
+// records the files skipped because of source errors by the subtask's
+// (successful) transfer with the given ID from the given endpoint, if it skips
+// such files
+func (subtask *transferSubtask) checkSkippedFiles(endpoint Endpoint, transferId uuid.UUID) error {
+	skipper, ok := endpoint.(endpoints.SkippingEndpoint)
+	if !ok || !subtask.SkipSourceErrors {
+		return nil
+	}
+	skippedFiles, err := skipper.SkippedFiles(transferId)
+	if err != nil || len(skippedFiles) == 0 {
+		return err
+	}
+
+	// match the skipped source paths to the subtask's files
+	sourceDb, err := databases.NewDatabase(subtask.Source)
+	if err != nil {
+		return err
+	}
+	sourceFolder := ""
+	if subtask.Relayed {
+		sourceFolder = subtask.relayFolder()
+	}
+	fileIdsForPaths := make(map[string]string)
+	for _, d := range subtask.Descriptors {
+		descriptor, err := fileDescriptor(d)
+		if err != nil {
+			return err
+		}
+		path, err := databases.EndpointPath(sourceDb, descriptor.Path)
+		if err != nil {
+			return err
+		}
+		fileIdsForPaths[filepath.Join(sourceFolder, path)] = descriptor.Id
+	}
+	if subtask.SkippedFiles == nil {
+		subtask.SkippedFiles = make(map[string]string)
+	}
+	for _, skipped := range skippedFiles {
+		if fileId, found := fileIdsForPaths[filepath.Clean(skipped.SourcePath)]; found {
+			subtask.SkippedFiles[fileId] = skipped.Error
+		}
 	}
 	return nil
 }
 
+// verifies the checksums of the subtask's files delivered to the given
+// destination endpoint (if it can compute them), moving any files whose
+// checksums don't match their descriptors into a quarantine subfolder of the
+// destination folder (if the endpoint can move them), and returning the
+// quarantined paths of these files, by file ID
+func (subtask transferSubtask) quarantineCorruptFiles(endpoint Endpoint) (map[string]string, error) {
+	if subtask.SkipChecksums || subtask.MetadataOnly {
+		return nil, nil
+	}
+	checksummer, ok := endpoint.(endpoints.ChecksummingEndpoint)
+	if !ok {
+		return nil, nil
+	}
+	mover, canMove := endpoint.(endpoints.MovingEndpoint)
+	var corruptFiles map[string]string
+	for _, d := range subtask.Descriptors {
+		descriptor, err := fileDescriptor(d)
+		if err != nil {
+			return nil, err
+		}
+		if _, skipped := subtask.SkippedFiles[descriptor.Id]; skipped || descriptor.Recursive {
+			continue
+		}
+		value, algorithm := parseHash(descriptor.Hash)
+		if value == "" || !config.HashAlgorithmAllowed(algorithm) {
+			continue
+		}
+		path := filepath.Join(subtask.DestinationFolder, subtask.destinationPath(descriptor.Path))
+		checksum, err := checksummer.Checksum(path, algorithm)
+		if err != nil || checksum == value {
+			continue // verified, or can't be verified
+		}
+
+		// set the file aside so it isn't mistaken for a valid one
+		quarantinePath := path
+		if canMove {
+			quarantinePath = filepath.Join(subtask.DestinationFolder, quarantineFolder,
+				subtask.destinationPath(descriptor.Path))
+			if err := mover.Move(path, quarantinePath); err != nil {
+				slog.Warn(fmt.Sprintf("Task %s: couldn't quarantine %s: %s",
+					subtask.TaskId.String(), path, err.Error()))
+				quarantinePath = path
+			}
+		}
+		slog.Warn(fmt.Sprintf("Task %s: checksum mismatch for %s (quarantined at %s)",
+			subtask.TaskId.String(), path, quarantinePath))
+		if corruptFiles == nil {
+			corruptFiles = make(map[string]string)
+		}
+		corruptFiles[descriptor.Id] = quarantinePath
+	}
+	return corruptFiles, nil
+}
+
+// returns the folder on the relay endpoint that holds the subtask's files
+func (subtask *transferSubtask) relayFolder() string {
+	return filepath.Join("dts-relay", subtask.DestinationFolder)
+}
+
+// removes the intermediate copies of the subtask's files from its relay
+// endpoint, if the endpoint supports this (failures are logged, not fatal)
+func (subtask *transferSubtask) cleanUpRelay() {
+	endpoint, err := endpoints.NewEndpoint(subtask.RelayEndpoint)
+	if err == nil {
+		if deleter, ok := endpoint.(endpoints.DeletingEndpoint); ok {
+			err = deleter.Delete(subtask.relayFolder())
+		} else {
+			slog.Warn(fmt.Sprintf("Relay endpoint %s can't delete relayed files in %s",
+				subtask.RelayEndpoint, subtask.relayFolder()))
+		}
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Couldn't clean up relayed files in %s on %s: %s",
+			subtask.relayFolder(), subtask.RelayEndpoint, err.Error()))
+	}
+}
+
 // issues a cancellation request to the endpoint associated with the subtask
 func (subtask *transferSubtask) cancel() error {
 	if subtask.Transfer.Valid { // we're transferring
-		// fetch the source endpoint
-		endpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
+		// fetch the endpoint handling the transfer
+		endpoint, err := subtask.transferEndpoint()
 		if err != nil {
 			return err
 		}
@@ -171,11 +452,41 @@ func (subtask *transferSubtask) cancel() error {
 	return nil
 }
 
+// asks the endpoint associated with the subtask to suspend its transfer (if
+// any), provided the endpoint supports this
+func (subtask *transferSubtask) pause() error {
+	if subtask.Transfer.Valid {
+		endpoint, err := subtask.transferEndpoint()
+		if err != nil {
+			return err
+		}
+		if pausable, ok := endpoint.(endpoints.PausableEndpoint); ok {
+			return pausable.Pause(subtask.Transfer.UUID)
+		}
+	}
+	return nil
+}
+
+// asks the endpoint associated with the subtask to resume its suspended
+// transfer (if any), provided the endpoint supports this
+func (subtask *transferSubtask) resume() error {
+	if subtask.Transfer.Valid {
+		endpoint, err := subtask.transferEndpoint()
+		if err != nil {
+			return err
+		}
+		if pausable, ok := endpoint.(endpoints.PausableEndpoint); ok {
+			return pausable.Resume(subtask.Transfer.UUID)
+		}
+	}
+	return nil
+}
+
 // updates the status of a canceled subtask depending on where it is in its
 // lifecycle
 func (subtask *transferSubtask) checkCancellation() error {
 	if subtask.Transfer.Valid {
-		endpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
+		endpoint, err := subtask.transferEndpoint()
 		if err != nil {
 			return err
 		}
@@ -189,43 +500,128 @@ func (subtask *transferSubtask) checkCancellation() error {
 	return nil
 }
 
-// initiates a file transfer on a set of staged files
+// initiates a file transfer on a set of staged files, relaying them through
+// an intermediate endpoint if needed
 func (subtask *transferSubtask) beginTransfer() error {
-	slog.Debug(fmt.Sprintf("Transferring %d file(s) from %s to %s",
-		len(subtask.Descriptors), subtask.SourceEndpoint, subtask.Destination))
-	// assemble a list of file transfers
-	fileXfers := make([]FileTransfer, len(subtask.Descriptors))
-	for i, d := range subtask.Descriptors {
-		descriptor := d.(map[string]any)
-		path := descriptor["path"].(string)
-		destinationPath := filepath.Join(subtask.DestinationFolder, path)
-		fileXfers[i] = FileTransfer{
-			SourcePath:      path,
-			DestinationPath: destinationPath,
-			Hash:            descriptor["hash"].(string),
-		}
+	// figure out the endpoints and folders for this leg of the transfer
+	source, sourceFolder := subtask.SourceEndpoint, ""
+	destination, destinationFolder := subtask.Destination, subtask.DestinationFolder
+	toRelay := subtask.RelayEndpoint != "" && !subtask.Relayed
+	if subtask.Relayed { // relay -> destination
+		source, sourceFolder = subtask.RelayEndpoint, subtask.relayFolder()
+	} else if toRelay { // source -> relay
+		destination, destinationFolder = subtask.RelayEndpoint, subtask.relayFolder()
+	}
+	sourceEndpoint, err := endpoints.NewEndpoint(source)
+	if err != nil {
+		return err
+	}
+	var destinationEndpoint Endpoint
+	destinationEndpointName := destination
+	if toRelay {
+		destinationEndpoint, err = endpoints.NewEndpoint(destination)
+	} else {
+		destinationEndpoint, err = resolveDestinationEndpoint(destination)
+		destinationEndpointName = config.Databases[destination].Endpoint
 	}
-
-	sourceEndpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint)
+	if err != nil {
+		return err
+	}
+	err = checkEncryption(sourceEndpoint, source, destinationEndpointName)
 	if err != nil {
 		return err
 	}
 
-	// figure out the destination endpoint
-	destinationEndpoint, err := resolveDestinationEndpoint(subtask.Destination)
+	slog.Debug(fmt.Sprintf("Transferring %d file(s) from %s to %s",
+		len(subtask.Descriptors), source, destination))
+	sourceDb, err := databases.NewDatabase(subtask.Source)
 	if err != nil {
 		return err
 	}
 
+	// assemble a list of file transfers
+	fileXfers := make([]FileTransfer, len(subtask.Descriptors))
+	for i, d := range subtask.Descriptors {
+		descriptor, err := fileDescriptor(d)
+		if err != nil {
+			return err
+		}
+		path, err := databases.EndpointPath(sourceDb, descriptor.Path) // (decoded)
+		if err != nil {
+			return err
+		}
+		destinationPath := path
+		if !toRelay { // sanitize paths at the final destination if requested
+			destinationPath = sanitizePath(path, config.Service.PathSanitization)
+			if destinationPath != path {
+				if subtask.RenamedPaths == nil {
+					subtask.RenamedPaths = make(map[string]string)
+				}
+				subtask.RenamedPaths[descriptor.Path] = destinationPath
+			}
+		}
+		fileXfers[i] = FileTransfer{
+			SourcePath:      filepath.Join(sourceFolder, path),
+			DestinationPath: filepath.Join(destinationFolder, destinationPath),
+		}
+		if descriptor.Recursive { // directory
+			fileXfers[i].Recursive = true
+			fileXfers[i].FilterRules = subtask.FilterRules
+		} else if !subtask.SkipChecksums {
+			value, algorithm := parseHash(descriptor.Hash) // hash may be missing
+			if config.HashAlgorithmAllowed(algorithm) {
+				fileXfers[i].Hash, fileXfers[i].HashAlgorithm = value, algorithm
+			}
+		}
+	}
+
 	// initiate the transfer
-	transferId, err := sourceEndpoint.Transfer(destinationEndpoint, fileXfers)
+	if faults.Triggered(subtask.TaskId, faults.SubmissionFailure) {
+		return subtask.Retries.fail(subtask.TaskId, "transfer",
+			&faults.InjectedFaultError{TaskId: subtask.TaskId, Fault: faults.SubmissionFailure})
+	}
+	ctx, span := tracing.Start(tracing.ContextWithSpanContext(context.Background(), subtask.Trace),
+		"submit transfer", tracing.SpanKindInternal)
+	span.SetAttribute("dts.task_id", subtask.TaskId.String())
+	span.SetAttribute("dts.source_endpoint", source)
+	span.SetAttribute("dts.destination_endpoint", destination)
+	span.SetAttribute("dts.num_files", len(fileXfers))
+	defer span.End()
+	var transferId uuid.UUID
+	if downloader, fetcher, ok := subtask.directDownload(sourceEndpoint, destinationEndpoint, fileXfers); ok {
+		transferId, err = fetcher.FetchFiles(downloader, fileXfers,
+			units.GigabytesToBytes(config.Service.SmallPayloadMaxSize))
+		subtask.Mover = httpsMover
+	} else if submitter, ok := sourceEndpoint.(endpoints.IdempotentEndpoint); ok {
+		_, skips := sourceEndpoint.(endpoints.SkippingEndpoint)
+		transferId, err = subtask.submitTransfer(ctx, submitter, destinationEndpoint, fileXfers,
+			skips && subtask.SkipSourceErrors)
+		subtask.Mover = sourceEndpoint.Provider()
+	} else if tracer, ok := sourceEndpoint.(endpoints.TracingEndpoint); ok {
+		// (the endpoint propagates our trace context to its provider)
+		_, skips := sourceEndpoint.(endpoints.SkippingEndpoint)
+		transferId, err = tracer.TransferWithContext(ctx, destinationEndpoint, fileXfers,
+			skips && subtask.SkipSourceErrors)
+		subtask.Mover = sourceEndpoint.Provider()
+	} else if skipper, ok := sourceEndpoint.(endpoints.SkippingEndpoint); ok && subtask.SkipSourceErrors {
+		transferId, err = skipper.TransferSkippingErrors(destinationEndpoint, fileXfers)
+		subtask.Mover = sourceEndpoint.Provider()
+	} else {
+		transferId, err = sourceEndpoint.Transfer(destinationEndpoint, fileXfers)
+		subtask.Mover = sourceEndpoint.Provider()
+	}
 	if err != nil {
-		return err
+		span.RecordError(err)
+		return subtask.Retries.fail(subtask.TaskId, "transfer", err)
 	}
+	span.SetAttribute("dts.transfer_id", transferId.String())
+	subtask.Retries.succeed()
+	subtask.SubmissionId = uuid.NullUUID{} // (the next leg gets a new one)
 	subtask.Transfer = uuid.NullUUID{
 		UUID:  transferId,
 		Valid: true,
 	}
+	subtask.TransferStartTime = time.Now()
 	subtask.TransferStatus = TransferStatus{
 		Code:     TransferStatusActive,
 		NumFiles: len(subtask.Descriptors),
@@ -233,3 +629,208 @@ func (subtask *transferSubtask) beginTransfer() error {
 	subtask.Staging = uuid.NullUUID{}
 	return nil
 }
+
+// submits a transfer of the given files to the given endpoint under the
+// subtask's submission ID, obtaining one if it has none, so that a retried
+// submission whose first attempt was accepted (but whose response was lost)
+// isn't accepted twice
+func (subtask *transferSubtask) submitTransfer(ctx context.Context, submitter endpoints.IdempotentEndpoint,
+	destination Endpoint, fileXfers []FileTransfer, skipErrors bool) (uuid.UUID, error) {
+	if !subtask.SubmissionId.Valid {
+		submissionId, err := submitter.SubmissionId(ctx)
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		subtask.SubmissionId = uuid.NullUUID{UUID: submissionId, Valid: true}
+	}
+	return submitter.SubmitTransfer(ctx, subtask.SubmissionId.UUID, destination, fileXfers, skipErrors)
+}
+
+// if the subtask's files can be downloaded directly from the given source
+// endpoint to the given destination endpoint (bypassing a transfer task), returns
+// the source as a downloading endpoint, the destination as a local endpoint that
+// can fetch them, and true; otherwise returns false
+func (subtask *transferSubtask) directDownload(source, destination Endpoint,
+	fileXfers []FileTransfer) (endpoints.DownloadingEndpoint, *local.Endpoint, bool) {
+	if config.Service.SmallPayloadMaxSize <= 0 || subtask.RelayEndpoint != "" ||
+		subtask.payloadBytes() > units.GigabytesToBytes(config.Service.SmallPayloadMaxSize) {
+		return nil, nil, false
+	}
+	for _, fileXfer := range fileXfers {
+		if fileXfer.Recursive {
+			return nil, nil, false
+		}
+	}
+	downloader, ok := source.(endpoints.DownloadingEndpoint)
+	if !ok || !downloader.CanDownload() {
+		return nil, nil, false
+	}
+	fetcher, ok := destination.(*local.Endpoint)
+	if !ok {
+		return nil, nil, false
+	}
+	return downloader, fetcher, true
+}
+
+// fills in the formats of any of the subtask's file descriptors that are
+// missing or unknown, using file names and (for local source endpoints) the
+// contents of the files themselves
+func (subtask *transferSubtask) identifyFormats() {
+	var localEndpoint *local.Endpoint
+	if endpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint); err == nil {
+		localEndpoint, _ = endpoint.(*local.Endpoint)
+	}
+	for _, d := range subtask.Descriptors {
+		descriptor, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+		if format := frictionless.String(descriptor, "format"); format != "" && format != formats.Unknown {
+			continue
+		}
+		path := frictionless.String(descriptor, "path")
+		format := formats.FormatFromFileName(path)
+		if format == formats.Unknown && localEndpoint != nil {
+			if sniffedFormat, err := localEndpoint.FileFormat(subtask.sourcePath(path)); err == nil {
+				format = sniffedFormat
+			} else {
+				slog.Debug(fmt.Sprintf("Couldn't identify format of %s: %s", path, err.Error()))
+			}
+		}
+		descriptor["format"] = format
+	}
+}
+
+// computes the checksums of any of the subtask's files whose descriptors are
+// missing them at the source endpoint or (after transfer) at the destination
+// endpoint, provided either has direct access to its files, returning the
+// resulting descriptor hashes, by file ID
+func (subtask transferSubtask) computeChecksums() map[string]string {
+	var checksummers []endpoints.ChecksummingEndpoint
+	var atDestination []bool
+	if endpoint, err := endpoints.NewEndpoint(subtask.SourceEndpoint); err == nil {
+		if checksummer, ok := endpoint.(endpoints.ChecksummingEndpoint); ok {
+			checksummers = append(checksummers, checksummer)
+			atDestination = append(atDestination, false)
+		}
+	}
+	if endpoint, err := resolveDestinationEndpoint(subtask.Destination); err == nil {
+		if checksummer, ok := endpoint.(endpoints.ChecksummingEndpoint); ok {
+			checksummers = append(checksummers, checksummer)
+			atDestination = append(atDestination, true)
+		}
+	}
+	if len(checksummers) == 0 {
+		return nil
+	}
+
+	// MD5 is the Frictionless default, but isn't permitted in FIPS mode
+	algorithm := "md5"
+	if config.Service.FIPSMode {
+		algorithm = "sha256"
+	}
+
+	var hashes map[string]string
+	for _, d := range subtask.Descriptors {
+		descriptor, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+		if hash := frictionless.String(descriptor, "hash"); hash != "" {
+			if _, existing := parseHash(hash); config.HashAlgorithmAllowed(existing) {
+				continue
+			}
+		}
+		id, path := frictionless.String(descriptor, "id"), frictionless.String(descriptor, "path")
+		for i, checksummer := range checksummers {
+			checksumPath := subtask.sourcePath(path)
+			if atDestination[i] {
+				checksumPath = filepath.Join(subtask.DestinationFolder, subtask.destinationPath(path))
+			}
+			checksum, err := checksummer.Checksum(checksumPath, algorithm)
+			if err == nil {
+				if hashes == nil {
+					hashes = make(map[string]string)
+				}
+				if algorithm == "md5" {
+					hashes[id] = checksum
+				} else {
+					hashes[id] = algorithm + ":" + checksum
+				}
+				break
+			}
+			slog.Debug(fmt.Sprintf("Couldn't compute checksum of %s: %s", path, err.Error()))
+		}
+	}
+	return hashes
+}
+
+// returns the path (relative to the destination folder) at which the file with
+// the given descriptor path is delivered
+func (subtask *transferSubtask) destinationPath(path string) string {
+	if renamed, found := subtask.RenamedPaths[path]; found {
+		return renamed
+	}
+	return subtask.sourcePath(path)
+}
+
+// returns the path (relative to the source endpoint's root) of the file with
+// the given descriptor path, decoded if the source database encodes it
+func (subtask *transferSubtask) sourcePath(path string) string {
+	if source, err := databases.NewDatabase(subtask.Source); err == nil {
+		if decoded, err := databases.EndpointPath(source, path); err == nil {
+			return decoded
+		}
+	}
+	return path
+}
+
+// returns the subtask's descriptors with paths translated to those of files on
+// the source endpoint (copying only those descriptors whose paths change)
+func (subtask *transferSubtask) endpointDescriptors() ([]any, error) {
+	source, err := databases.NewDatabase(subtask.Source)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := source.(databases.PathDecoder); !ok {
+		return subtask.Descriptors, nil
+	}
+	descriptors := make([]any, len(subtask.Descriptors))
+	for i, d := range subtask.Descriptors {
+		descriptors[i] = d
+		descriptor, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+		path := frictionless.String(descriptor, "path")
+		decoded, err := databases.EndpointPath(source, path)
+		if err != nil {
+			return nil, err
+		}
+		if decoded != path {
+			descriptor = maps.Clone(descriptor)
+			descriptor["path"] = decoded
+			descriptors[i] = descriptor
+		}
+	}
+	return descriptors, nil
+}
+
+// splits a Frictionless hash into its value and algorithm, which is given as
+// a prefix ("sha256:...") or otherwise defaults to MD5
+func parseHash(hash string) (value, algorithm string) {
+	if algorithm, value, found := strings.Cut(hash, ":"); found {
+		return value, strings.ToLower(algorithm)
+	}
+	return hash, "md5"
+}
+
+// converts one of a subtask's (untyped) descriptors to a typed file descriptor,
+// returning an error if it's malformed
+func fileDescriptor(d any) (frictionless.Descriptor, error) {
+	descriptor, ok := d.(map[string]any)
+	if !ok {
+		return frictionless.Descriptor{}, fmt.Errorf("invalid file descriptor: %v", d)
+	}
+	return frictionless.DescriptorFromMap(descriptor)
+}
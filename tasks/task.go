@@ -22,10 +22,17 @@
 package tasks
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -35,46 +42,119 @@ import (
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/credit"
 	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
-	//"github.com/kbase/dts/journal"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/journal"
+	"github.com/kbase/dts/manifests"
+	"github.com/kbase/dts/tracing"
 )
 
 // This type tracks the lifecycle of a file transfer task that copies files from
 // a source database to a destination database. A transferTask can have one or
 // more subtasks, depending on how many transfer endpoints are involved.
 type transferTask struct {
-	Canceled          bool              // set if a cancellation request has been made
-	StartTime         time.Time         // time at which the transfer was requested
-	CompletionTime    time.Time         // time at which the transfer completed
-	DataDescriptors   []any             // in-line data descriptors
-	Description       string            // Markdown description of the task
-	Destination       string            // name of destination database (in config) OR custom spec
-	DestinationFolder string            // folder path to which files are transferred
-	FileIds           []string          // IDs of all files being transferred
-	Id                uuid.UUID         // task identifier
-	Instructions      map[string]any    // machine-readable task processing instructions
-	Manifest          uuid.NullUUID     // manifest generation UUID (if any)
-	ManifestFile      string            // name of locally-created manifest file
-	PayloadSize       float64           // Size of payload (gigabytes)
-	Source            string            // name of source database (in config)
-	Status            TransferStatus    // status of file transfer operation
-	Subtasks          []transferSubtask // list of constituent file transfer subtasks
-	User              auth.User         // info about user requesting transfer
+	Allocation               string              // allocation or project to which the task is attributed
+	CallbackURL              string              // URL to which status change events are POSTed (if any)
+	Canceled                 bool                // set if a cancellation request has been made
+	Client                   string              // KBase username of the DTS client used to request the task (if any)
+	StartTime                time.Time           // time at which the transfer was requested
+	ProcessingTime           time.Time           // time at which work on the transfer began
+	StagingEndTime           time.Time           // time at which all of the transfer's files were staged
+	FinalizeTime             time.Time           // time at which all of the transfer's files arrived
+	CompletionTime           time.Time           // time at which the transfer completed
+	DataDescriptors          []any               // in-line data descriptors
+	Datasets                 map[string][]string // IDs of files in requested datasets, by dataset ID
+	Deadline                 time.Time           // time by which the task must complete (if set)
+	Description              string              // Markdown description of the task
+	DescriptionLanguage      string              // IETF BCP 47 language tag of the description (if known)
+	Destination              string              // name of destination database (in config) OR custom spec
+	DestinationFolder        string              // folder path to which files are transferred
+	DOI                      string              // DOI minted for the delivered payload (if any)
+	DroppedResources         []DroppedResource   // file descriptors dropped due to malformed metadata
+	EarlyManifest            uuid.NullUUID       // UUID of manifest transfer sent with payload (if any)
+	EarlyManifestFingerprint string              // fingerprint of the content of the early manifest
+	FinalManifestFile        string              // local copy of the final manifest sent with an early one (if any)
+	EmbargoedUntil           time.Time           // time at which embargoes on requested files lift
+	EndpointOverrides        map[string]string   // source endpoints replacing those assigned to files
+	EstimatedEnd             time.Time           // estimated time of completion (zero if unknown)
+	Expired                  bool                // set if the task was canceled because its deadline passed
+	Exclude                  []string            // IDs or name patterns of files excluded from the payload
+	FileIds                  []string            // IDs of all files being transferred
+	FilterRules              []FilterRule        // rules selecting contents of directory payloads
+	Id                       uuid.UUID           // task identifier
+	Instructions             map[string]any      // machine-readable task processing instructions
+	JSONLDFile               string              // name of locally-created JSON-LD dataset description (if any)
+	Manifest                 uuid.NullUUID       // manifest generation UUID (if any)
+	ManifestInterrupted      bool                // set if the service restarted while a manifest was in flight
+	ManifestResubmissions    int                 // number of times the manifest was resubmitted after a restart
+	ManifestRetries          submissionRetries   // attempts to submit the manifest's transfer
+	MetadataOnly             bool                // set if only metadata describing files is delivered
+	MetadataWarnings         []string            // non-fatal issues found in the payload's metadata
+	MissingFiles             []string            // IDs of requested files deleted from the source database
+	ManifestFile             string              // name of locally-created manifest file
+	Note                     string              // free-text note attached by the requesting user
+	Paused                   bool                // set if the task has been paused
+	PausedStatusCode         TransferStatusCode  // status code of the task when it was paused
+	PayloadSize              float64             // Size of payload (gigabytes)
+	SkipChecksums            bool                // set if file checksums are not submitted/verified
+	SkipSourceErrors         bool                // set if files with source errors are skipped
+	Source                   string              // name of source database (in config)
+	SourceChanges            []string            // IDs of files whose source metadata changed mid-transfer
+	DeliveriesVerified       bool                // set once the checksums of delivered files are verified
+	ChecksumsComputed        bool                // set once missing checksums of delivered files are computed
+	SourceChangesChecked     bool                // set once the source has been checked for metadata changes
+	SourceHashes             map[string]string   // fingerprints of source file descriptors at creation, by ID
+	Status                   TransferStatus      // status of file transfer operation
+	StubFiles                []string            // names of locally-created stub files (metadata-only)
+	Subtasks                 []transferSubtask   // list of constituent file transfer subtasks
+	Tags                     []string            // user-defined labels for grouping tasks
+	Trace                    tracing.SpanContext // context of the span tracing the task's work
+	TraceParentId            tracing.SpanId      // ID of the span of the request that created the task (if any)
+	Translations             []Translation       // translations of the description into other languages
+	Batch                    uuid.UUID           // batch of related tasks created together (if any)
+	ChecksumFiles            map[string]string   // names of locally-created checksum files, by hash algorithm
+	DependsOn                []uuid.UUID         // IDs of tasks that must succeed before this one starts
+	User                     auth.User           // info about user requesting transfer
+	WaitForEmbargo           bool                // set if the task waits for embargoes to lift
+
+	fileDescriptors []map[string]any // resolved file descriptors (not persisted)
+	resolveError    error            // error encountered resolving them, if any (not persisted)
+}
+
+// identifies a file descriptor dropped from a payload and why
+type DroppedResource struct {
+	Id     string // resource ID (or name, if the ID is missing)
+	Reason string // description of the malformed metadata
 }
 
 // computes the size of a payload for a transfer task (in Gigabytes)
 func payloadSize(descriptors []map[string]any) float64 {
 	var size uint64
 	for _, descriptor := range descriptors {
-		size += uint64(descriptor["bytes"].(int))
+		if numBytes, ok := frictionless.Int(descriptor, "bytes"); ok {
+			size += uint64(numBytes)
+		}
 	}
 	return float64(size) / float64(1024*1024*1024)
 }
 
-// starts a task going, initiating staging if needed
-func (task *transferTask) start() error {
+// resolves the descriptors for the files in a task's payload, determining
+// its size. This is done only once: the resolved descriptors (or the error
+// encountered resolving them) are cached on the task.
+func (task *transferTask) resolve() error {
+	if task.fileDescriptors == nil && task.resolveError == nil {
+		task.resolveError = task.resolvePayload()
+	}
+	return task.resolveError
+}
+
+// fetches and sifts through the descriptors for the files in a task's payload
+// (see resolve)
+func (task *transferTask) resolvePayload() error {
 	source, err := databases.NewDatabase(task.Source)
 	if err != nil {
 		return err
@@ -82,15 +162,62 @@ func (task *transferTask) start() error {
 
 	// resolve resource data using file IDs
 	fileDescriptors := make([]map[string]any, 0)
+	task.DataDescriptors = nil
+	task.DroppedResources = nil
+	task.MetadataWarnings = nil
+	task.MissingFiles = nil
 	{
 		descriptors, err := source.Descriptors(task.User.Orcid, task.FileIds)
+		if missing := missingResourceIds(err); len(missing) > 0 && lenientMissingFiles() {
+			// omit files deleted from the source database from the payload
+			fileIds := withoutMissingIds(task.FileIds, missing)
+			if len(fileIds) == 0 {
+				return err
+			}
+			slog.Warn(fmt.Sprintf("Task %s: %d requested file(s) missing at source", task.Id,
+				len(missing)))
+			task.MissingFiles = missing
+			descriptors, err = source.Descriptors(task.User.Orcid, fileIds)
+		}
+		if err != nil {
+			return err
+		}
+
+		// embargoed files either hold up the task or reject it
+		embargoes, err := embargoedFiles(descriptors, time.Now())
 		if err != nil {
 			return err
 		}
+		if len(embargoes) > 0 {
+			if !task.WaitForEmbargo {
+				return &EmbargoedFilesError{Embargoes: embargoes}
+			}
+			for _, until := range embargoes {
+				if until.After(task.EmbargoedUntil) {
+					task.EmbargoedUntil = until
+				}
+			}
+		}
 
 		// sift through the descriptors and separate files from in-line data
 		for _, descriptor := range descriptors {
+			if excluded(descriptor, task.Exclude) {
+				continue
+			}
 			if _, found := descriptor["path"]; found { // file to be transferred
+				if err := frictionless.ValidateFileDescriptor(descriptor); err != nil {
+					dropped := DroppedResource{
+						Id:     frictionless.String(descriptor, "id"),
+						Reason: err.Error(),
+					}
+					if dropped.Id == "" {
+						dropped.Id = frictionless.String(descriptor, "name")
+					}
+					slog.Warn(fmt.Sprintf("Task %s: dropping resource '%s': %s", task.Id,
+						dropped.Id, dropped.Reason))
+					task.DroppedResources = append(task.DroppedResources, dropped)
+					continue
+				}
 				fileDescriptors = append(fileDescriptors, descriptor)
 			} else if _, found := descriptor["data"]; found { // inline data
 				task.DataDescriptors = append(task.DataDescriptors, descriptor)
@@ -101,12 +228,31 @@ func (task *transferTask) start() error {
 		}
 	}
 
+	task.MetadataWarnings = metadataWarnings(fileDescriptors, task.DroppedResources)
+	if err := overrideSourceEndpoints(source, fileDescriptors, task.EndpointOverrides); err != nil {
+		return err
+	}
+
+	// transfer files in the order in which they were requested
+	indices := requestIndices(task.FileIds)
+	slices.SortStableFunc(fileDescriptors, func(a, b map[string]any) int {
+		return compareRequestIndices(indices, a, b)
+	})
+
+	if len(fileDescriptors) == 0 && len(task.DataDescriptors) == 0 {
+		if len(task.DroppedResources) > 0 {
+			return fmt.Errorf("no valid files in the payload (%d dropped due to malformed metadata)",
+				len(task.DroppedResources))
+		}
+		return fmt.Errorf("all requested files were excluded from the payload")
+	}
+
 	// if the database stores its files in more than one location, check that each
 	// resource is associated with a valid endpoint
 	if len(config.Databases[task.Source].Endpoints) > 1 {
 		for _, descriptor := range fileDescriptors {
-			id := descriptor["id"].(string)
-			endpoint := descriptor["endpoint"].(string)
+			id := frictionless.String(descriptor, "id")
+			endpoint := frictionless.String(descriptor, "endpoint")
 			if endpoint == "" {
 				return databases.ResourceEndpointNotFoundError{
 					Database:   task.Source,
@@ -127,11 +273,178 @@ func (task *transferTask) start() error {
 		}
 	}
 
+	// record the licenses of the files, falling back to the source's default
+	fillLicenses(fileDescriptors, config.Databases[task.Source].License)
+
 	// make sure the size of the payload doesn't exceed our specified limit
 	task.PayloadSize = payloadSize(fileDescriptors) // (in GB)
 	if task.PayloadSize > config.Service.MaxPayloadSize {
 		return &PayloadTooLargeError{Size: task.PayloadSize}
 	}
+	if err := reservePayload(*task); err != nil {
+		return err
+	}
+
+	// record the state of the source metadata so we can detect files replaced
+	// upstream during the transfer (only the first time we resolve the payload)
+	if task.SourceHashes == nil {
+		task.SourceHashes = make(map[string]string)
+		for _, descriptor := range fileDescriptors {
+			task.SourceHashes[frictionless.String(descriptor, "id")] = databases.DescriptorFingerprint(descriptor)
+		}
+	}
+
+	task.fileDescriptors = fileDescriptors
+	return nil
+}
+
+// re-fetches the descriptors for the files in the task's payload in the
+// background and records the IDs of any whose source metadata has changed
+// since the task was created, returning true once this is done and false
+// while it's in progress. A failure to check for changes is logged.
+func (task *transferTask) checkSourceChanges() bool {
+	if task.SourceChangesChecked || len(task.SourceHashes) == 0 {
+		return true
+	}
+	source, orcid := task.Source, task.User.Orcid
+	ids := slices.Sorted(maps.Keys(task.SourceHashes))
+	result, done := inBackground(task.Id, "source-changes", func() ([]map[string]any, error) {
+		db, err := databases.NewDatabase(source)
+		if err != nil {
+			return nil, err
+		}
+		return db.Descriptors(orcid, ids)
+	})
+	if !done {
+		return false
+	}
+	task.SourceChangesChecked = true
+	if result.Error != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't check for source metadata changes: %s",
+			task.Id.String(), result.Error.Error()))
+		return true
+	}
+	task.SourceChanges = changedFiles(task.SourceHashes, result.Value)
+	return true
+}
+
+// verifies the checksums of the files delivered by the task's subtasks in the
+// background, quarantining any that don't match their descriptors, and returns
+// true once this is done and false while it's in progress. A failure to verify
+// the files is logged.
+func (task *transferTask) verifyDeliveries() bool {
+	if task.DeliveriesVerified || task.SkipChecksums || task.MetadataOnly {
+		return true
+	}
+	subtasks := slices.Clone(task.Subtasks)
+	result, done := inBackground(task.Id, "verify", func() ([]map[string]string, error) {
+		corruptFiles := make([]map[string]string, len(subtasks))
+		for i, subtask := range subtasks {
+			destination, err := resolveDestinationEndpoint(subtask.Destination)
+			if err != nil {
+				return nil, err
+			}
+			corruptFiles[i], err = subtask.quarantineCorruptFiles(destination)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return corruptFiles, nil
+	})
+	if !done {
+		return false
+	}
+	task.DeliveriesVerified = true
+	if result.Error != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't verify delivered files: %s",
+			task.Id.String(), result.Error.Error()))
+		return true
+	}
+	for i := range task.Subtasks {
+		task.Subtasks[i].CorruptFiles = result.Value[i]
+	}
+	return true
+}
+
+// computes the checksums of the task's delivered files that are missing from
+// their descriptors in the background (if the service is configured to do so),
+// filling them in once this is done and returning true, or returning false
+// while it's in progress
+func (task *transferTask) computeMissingChecksums() bool {
+	if task.ChecksumsComputed || !config.Service.ComputeMissingChecksums {
+		return true
+	}
+	subtasks := slices.Clone(task.Subtasks)
+	result, done := inBackground(task.Id, "checksums", func() ([]map[string]string, error) {
+		hashes := make([]map[string]string, len(subtasks))
+		for i, subtask := range subtasks {
+			hashes[i] = subtask.computeChecksums()
+		}
+		return hashes, nil
+	})
+	if !done {
+		return false
+	}
+	task.ChecksumsComputed = true
+	for i := range task.Subtasks {
+		for _, d := range task.Subtasks[i].Descriptors {
+			if descriptor, ok := d.(map[string]any); ok {
+				if hash, found := result.Value[i][frictionless.String(descriptor, "id")]; found {
+					descriptor["hash"] = hash
+				}
+			}
+		}
+	}
+	return true
+}
+
+// returns the (sorted) IDs of files whose descriptors don't match the given
+// fingerprints, including those missing from the given descriptors
+func changedFiles(fingerprints map[string]string, descriptors []map[string]any) []string {
+	current := make(map[string]string)
+	for _, descriptor := range descriptors {
+		if id, ok := descriptor["id"].(string); ok {
+			current[id] = databases.DescriptorFingerprint(descriptor)
+		}
+	}
+	var changed []string
+	for _, id := range slices.Sorted(maps.Keys(fingerprints)) {
+		if current[id] != fingerprints[id] {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// returns true if the task qualifies for the fast lane, in which it bypasses
+// the queue for bulk transfers (the task's payload must be resolved)
+func (task transferTask) FastLane() bool {
+	maxSize, maxFiles := config.Service.FastLaneMaxPayloadSize, config.Service.FastLaneMaxFiles
+	if maxSize == 0 && maxFiles == 0 { // no fast lane
+		return false
+	}
+	return (maxSize == 0 || task.PayloadSize <= maxSize) &&
+		(maxFiles == 0 || len(task.FileIds) <= maxFiles)
+}
+
+// returns true if the task is waiting for embargoes on its files to lift
+func (task transferTask) Embargoed() bool {
+	return time.Now().Before(task.EmbargoedUntil)
+}
+
+// starts a task going, initiating staging if needed
+func (task *transferTask) start() error {
+	err := task.resolve()
+	if err != nil {
+		return err
+	}
+	if task.Embargoed() { // try again later
+		task.Status.Message = fmt.Sprintf("waiting for embargo on requested files to lift (%s)",
+			task.EmbargoedUntil.Format(time.RFC3339))
+		return nil
+	}
+	task.Status.Message = ""
+	fileDescriptors := task.fileDescriptors
 
 	// determine the destination endpoint and folder
 	// FIXME: this conflicts with our redesign!!
@@ -167,10 +480,25 @@ func (task *transferTask) start() error {
 			Descriptors:       descriptorsForEndpoint,
 			Source:            task.Source,
 			SourceEndpoint:    sourceEndpoint,
+			TaskId:            task.Id,
+			SkipChecksums:     task.SkipChecksums,
+			SkipSourceErrors:  task.SkipSourceErrors || lenientMissingFiles(),
+			MetadataOnly:      task.MetadataOnly,
+			FilterRules:       task.FilterRules,
+			RelayEndpoint:     config.Endpoints[sourceEndpoint].Relay,
+			Trace:             task.Trace,
 			User:              task.User,
 		})
 	}
 
+	// make sure the sources of a large payload are available before staging
+	// any of it, so the task fails now instead of hours from now
+	if task.needsProbe() {
+		if err := task.probeSources(); err != nil {
+			return err
+		}
+	}
+
 	// start the subtasks
 	for i := range task.Subtasks {
 		subErr := task.Subtasks[i].start()
@@ -182,15 +510,27 @@ func (task *transferTask) start() error {
 		return err
 	}
 
+	// if requested, send the manifest along with the payload, falling back to
+	// sending it afterward if this fails
+	if config.Service.EarlyManifest && !task.MetadataOnly {
+		if err := task.sendEarlyManifest(); err != nil {
+			slog.Warn(fmt.Sprintf("Task %s: couldn't send early manifest: %s",
+				task.Id.String(), err.Error()))
+		}
+	}
+
 	// provisionally, we set the tasks's status to "staging"
 	task.Status.Code = TransferStatusStaging
+	task.ProcessingTime = time.Now()
 	return err
 }
 
 // updates the state of a task, setting its status as necessary
 func (task *transferTask) Update() error {
 	var err error
-	if len(task.Subtasks) == 0 { // new task!
+	if task.Paused && !task.Canceled { // hold all work until the task is resumed
+		return nil
+	} else if len(task.Subtasks) == 0 { // new task!
 		err = task.start()
 	} else if task.Canceled { // cancellation requested
 		for i := range task.Subtasks {
@@ -242,56 +582,113 @@ func (task *transferTask) Update() error {
 			task.Status.NumFiles = 0
 			task.Status.NumFilesTransferred = 0
 			task.Status.NumFilesSkipped = 0
+			task.Status.NumBytes = 0
+			task.Status.NumBytesTransferred = 0
+			task.Status.BytesPerSecond = 0
 			for _, subtask := range task.Subtasks {
 				task.Status.NumFiles += subtask.TransferStatus.NumFiles
+				task.Status.NumBytes += subtask.payloadBytes()
 				if subtask.Staging.Valid {
 					subtaskStaging = true
 				} else {
 					task.Status.NumFilesTransferred += subtask.TransferStatus.NumFilesTransferred
 					task.Status.NumFilesSkipped += subtask.TransferStatus.NumFilesSkipped
+					task.Status.NumBytesTransferred += subtask.TransferStatus.NumBytesTransferred
+					if subtask.TransferStatus.Code == TransferStatusActive { // (concurrent transfers)
+						task.Status.BytesPerSecond += subtask.TransferStatus.BytesPerSecond
+					}
 				}
 			}
 		}
 
+		task.EstimatedEnd = task.estimateCompletion()
+
+		if !subtaskFailed && !subtaskStaging && task.StagingEndTime.IsZero() {
+			task.StagingEndTime = time.Now()
+		}
+
 		if subtaskStaging && task.Status.NumFilesTransferred == 0 {
 			task.Status.Code = TransferStatusStaging
 		} else if allTransfersSucceeded { // write a manifest
-			localEndpoint, err := endpoints.NewEndpoint(config.Service.Endpoint)
-			if err != nil {
-				return err
+			if task.FinalizeTime.IsZero() {
+				task.FinalizeTime = time.Now()
 			}
 
-			// generate a manifest for the transfer
-			manifest, err := task.createManifest()
-			if err != nil {
-				return fmt.Errorf("generating manifest file content: %s", err.Error())
+			// if we're low on disk space, try again later
+			if err := checkDiskSpace(config.Service.ManifestDirectory); err != nil {
+				slog.Warn(fmt.Sprintf("Task %s: deferring manifest: %s", task.Id.String(), err.Error()))
+				return nil
 			}
 
-			// write the manifest to disk and begin transferring it to the
-			// destination endpoint
-			task.ManifestFile = filepath.Join(config.Service.ManifestDirectory, fmt.Sprintf("manifest-%s.json", task.Id.String()))
-			err = manifest.SaveDescriptor(task.ManifestFile)
-			if err != nil {
-				return fmt.Errorf("creating manifest file: %s", err.Error())
+			// if a failed manifest submission is waiting to be retried, wait
+			// for its backoff delay to elapse
+			if task.ManifestRetries.pending() && !task.ManifestRetries.due() {
+				return nil
+			}
+
+			// if the manifest was sent along with the payload, wait for it
+			if task.EarlyManifest.Valid {
+				arrived, err := task.earlyManifestArrived()
+				if err != nil || !arrived {
+					return err
+				}
+			}
+
+			// quarantine any delivered files that fail checksum verification,
+			// checking back once their checksums have been computed
+			if !task.verifyDeliveries() {
+				return nil
 			}
 
-			// construct the source/destination file manifest paths
-			fileXfers := []FileTransfer{
-				{
-					SourcePath:      task.ManifestFile,
-					DestinationPath: filepath.Join(task.DestinationFolder, "manifest.json"),
-				},
+			// fill in any checksums missing from the payload's metadata,
+			// checking back once they've been computed
+			if !task.computeMissingChecksums() {
+				return nil
 			}
 
-			// begin transferring the manifest
-			destinationEndpoint, err := resolveDestinationEndpoint(task.Destination)
+			// flag any files that were replaced upstream during the transfer,
+			// checking back once the source has responded
+			if !task.checkSourceChanges() {
+				return nil
+			}
+
+			// mint a draft DOI for the delivered payload (if the destination
+			// wants one), checking back later if DataCite hasn't responded
+			if !task.mintDOI() {
+				return nil
+			}
+
+			// generate a manifest for the transfer
+			manifest, err := task.createManifest()
 			if err != nil {
-				return err
+				return fmt.Errorf("generating manifest file content: %s", err.Error())
 			}
-			task.Manifest.UUID, err = localEndpoint.Transfer(destinationEndpoint, fileXfers)
+
+			// if the manifest sent with the payload is still accurate, put its
+			// final copy in place and we're done
+			if task.EarlyManifest.Valid {
+				if manifestFingerprint(manifest) == task.EarlyManifestFingerprint &&
+					task.finalizeEarlyManifest() {
+					if err := manifest.SaveDescriptor(task.ManifestFile); err != nil {
+						return fmt.Errorf("creating manifest file: %s", err.Error())
+					}
+					task.archiveManifest()
+					task.Manifest = task.EarlyManifest
+					task.EarlyManifest = uuid.NullUUID{}
+					task.Status.Code = TransferStatusFinalizing
+					return nil
+				}
+				task.EarlyManifest = uuid.NullUUID{} // replace it
+			}
+			task.discardFinalManifest()
+
+			// write the manifest to disk and begin transferring it to the
+			// destination endpoint
+			task.Manifest.UUID, err = task.sendManifest(manifest, true)
 			if err != nil {
-				return fmt.Errorf("transferring manifest file: %s", err.Error())
+				return task.ManifestRetries.fail(task.Id, "manifest", err)
 			}
+			task.ManifestRetries.succeed()
 
 			task.Status.Code = TransferStatusFinalizing
 			task.Manifest.Valid = true
@@ -300,6 +697,19 @@ func (task *transferTask) Update() error {
 	return err
 }
 
+// replaces the identity of the user that requested the (completed) task with
+// an anonymous placeholder
+func (task *transferTask) anonymize() {
+	anonymous := auth.User{
+		Name:  auth.AnonymousOrcid,
+		Orcid: auth.AnonymousOrcid,
+	}
+	task.User = anonymous
+	for i := range task.Subtasks {
+		task.Subtasks[i].User = anonymous
+	}
+}
+
 // requests that the task be canceled
 func (task *transferTask) Cancel() error {
 	// mark the task as canceled
@@ -311,6 +721,7 @@ func (task *transferTask) Cancel() error {
 		numFiles += task.Subtasks[i].TransferStatus.NumFiles
 		task.Subtasks[i].cancel()
 	}
+	task.retractEarlyManifest()
 	return nil
 	/*
 		payloadSizeBytes := int64(1024 * 1024 * 1024 * task.PayloadSize)
@@ -326,6 +737,107 @@ func (task *transferTask) Cancel() error {
 	*/
 }
 
+// returns true if the task has a deadline that passed before it completed
+func (task transferTask) Overdue() bool {
+	return !task.Deadline.IsZero() && !task.Completed() && time.Now().After(task.Deadline)
+}
+
+// cancels a task whose deadline has passed, marking it as expired
+func (task *transferTask) Expire() error {
+	err := task.Cancel()
+	task.Expired = true
+	task.Paused = false
+	task.Status.Code = TransferStatusFailed
+	task.Status.Message = fmt.Sprintf("expired: transfer did not complete by its deadline (%s)",
+		task.Deadline.Format(time.RFC3339))
+	task.CompletionTime = time.Now()
+	return err
+}
+
+// returns a transfer journal record for the task with the given status
+func (task transferTask) journalRecord(status string) journal.Record {
+	return journal.Record{
+		Id:          task.Id,
+		Source:      task.Source,
+		Destination: task.Destination,
+		Orcid:       task.User.Orcid,
+		Allocation:  task.Allocation,
+		StartTime:   task.StartTime,
+		StopTime:    time.Now(),
+		Status:      status,
+		PayloadSize: int64(1024 * 1024 * 1024 * task.PayloadSize), // GB -> B
+		NumFiles:    len(task.FileIds),
+		Note:        task.Note,
+		Tags:        task.Tags,
+		Movers:      task.movers(),
+		DOI:         task.DOI,
+	}
+}
+
+// returns the distinct providers that moved the task's files, in sorted order
+func (task transferTask) movers() []string {
+	var movers []string
+	for _, subtask := range task.Subtasks {
+		if subtask.Mover != "" && !slices.Contains(movers, subtask.Mover) {
+			movers = append(movers, subtask.Mover)
+		}
+	}
+	slices.Sort(movers)
+	return movers
+}
+
+// suspends the task, pausing any transfers whose endpoints support it and
+// holding all other work until the task is resumed
+func (task *transferTask) Pause() error {
+	if task.Completed() || task.Canceled {
+		return &CannotPauseError{Id: task.Id, Message: "task has completed or been canceled"}
+	}
+	if task.Paused {
+		return &CannotPauseError{Id: task.Id, Message: "task is already paused"}
+	}
+	if task.Manifest.Valid {
+		return &CannotPauseError{Id: task.Id, Message: "task is being finalized"}
+	}
+	for i := range task.Subtasks {
+		if err := task.Subtasks[i].pause(); err != nil {
+			// resume the transfers we've paused so the task isn't left half-paused
+			for j := range i {
+				if resumeErr := task.Subtasks[j].resume(); resumeErr != nil {
+					slog.Error(fmt.Sprintf("Task %s: couldn't resume transfer after failed pause: %s",
+						task.Id.String(), resumeErr.Error()))
+				}
+			}
+			return err
+		}
+	}
+	task.Paused = true
+	task.PausedStatusCode = task.Status.Code
+	task.Status.Code = TransferStatusInactive
+	return nil
+}
+
+// resumes a paused task
+func (task *transferTask) Resume() error {
+	if !task.Paused {
+		return &CannotPauseError{Id: task.Id, Message: "task is not paused"}
+	}
+	for i := range task.Subtasks {
+		if err := task.Subtasks[i].resume(); err != nil {
+			// pause the transfers we've resumed so the task stays paused
+			for j := range i {
+				if pauseErr := task.Subtasks[j].pause(); pauseErr != nil {
+					slog.Error(fmt.Sprintf("Task %s: couldn't pause transfer after failed resume: %s",
+						task.Id.String(), pauseErr.Error()))
+				}
+			}
+			return err
+		}
+	}
+	task.Paused = false
+	task.Status.Code = task.PausedStatusCode
+	return nil
+}
+
 // returns the duration since the task completed (successfully or otherwise),
 // or 0 if the task has not completed
 func (task transferTask) Age() time.Duration {
@@ -337,6 +849,174 @@ func (task transferTask) Age() time.Duration {
 	}
 }
 
+// returns a summary of the task
+func (task transferTask) Summary() Summary {
+	summary := Summary{
+		Id:             task.Id,
+		Orcid:          task.User.Orcid,
+		Allocation:     task.Allocation,
+		Status:         task.Status,
+		PayloadSize:    task.PayloadSize,
+		Note:           task.Note,
+		Tags:           task.Tags,
+		Batch:          task.Batch,
+		DependsOn:      task.DependsOn,
+		Warnings:       task.warnings(),
+		StartTime:      task.StartTime,
+		ProcessingTime: task.ProcessingTime,
+		CompletionTime: task.CompletionTime,
+		Deadline:       task.Deadline,
+		Expired:        task.Expired,
+		Stages:         task.stages(),
+		Timeline:       task.timeline(),
+	}
+	if !task.Completed() && !task.Canceled {
+		summary.EstimatedEnd = task.EstimatedEnd
+	}
+	return summary
+}
+
+// returns summaries of the stages (subtasks) of the task
+func (task transferTask) stages() []StageSummary {
+	stages := make([]StageSummary, len(task.Subtasks))
+	for i, subtask := range task.Subtasks {
+		files := make([]StageFile, 0, len(subtask.Descriptors))
+		for _, d := range subtask.Descriptors {
+			if descriptor, err := fileDescriptor(d); err == nil {
+				files = append(files, StageFile{
+					Id:             descriptor.Id,
+					Path:           descriptor.Path,
+					SkipReason:     subtask.SkippedFiles[descriptor.Id],
+					QuarantinePath: subtask.CorruptFiles[descriptor.Id],
+				})
+			}
+		}
+		stages[i] = StageSummary{
+			Source:         subtask.Source,
+			SourceEndpoint: subtask.SourceEndpoint,
+			RelayEndpoint:  subtask.RelayEndpoint,
+			Mover:          subtask.Mover,
+			StagingStatus:  subtask.StagingStatus,
+			Status:         subtask.TransferStatus,
+			Files:          files,
+		}
+	}
+	return stages
+}
+
+// returns descriptions of the files skipped by the task because of source
+// errors, in the order in which they were requested
+func (task transferTask) skippedFiles() []any {
+	var skipped []any
+	for _, subtask := range task.Subtasks {
+		for _, fileId := range slices.Sorted(maps.Keys(subtask.SkippedFiles)) {
+			skipped = append(skipped, map[string]any{
+				"id":    fileId,
+				"error": subtask.SkippedFiles[fileId],
+			})
+		}
+	}
+	indices := requestIndices(task.FileIds)
+	slices.SortStableFunc(skipped, func(a, b any) int {
+		return compareRequestIndices(indices, a.(map[string]any), b.(map[string]any))
+	})
+	return skipped
+}
+
+// returns descriptions of the delivered files that failed checksum
+// verification and were quarantined, in the order in which they were requested
+func (task transferTask) corruptFiles() []any {
+	var corrupt []any
+	for _, subtask := range task.Subtasks {
+		for _, fileId := range slices.Sorted(maps.Keys(subtask.CorruptFiles)) {
+			corrupt = append(corrupt, map[string]any{
+				"id":              fileId,
+				"quarantine_path": subtask.CorruptFiles[fileId],
+			})
+		}
+	}
+	indices := requestIndices(task.FileIds)
+	slices.SortStableFunc(corrupt, func(a, b any) int {
+		return compareRequestIndices(indices, a.(map[string]any), b.(map[string]any))
+	})
+	return corrupt
+}
+
+// returns non-fatal issues with the task's payload that users should know
+// about
+func (task transferTask) warnings() []string {
+	warnings := slices.Clone(task.MetadataWarnings)
+	if len(task.SourceChanges) > 0 {
+		warnings = append(warnings, fmt.Sprintf("source metadata changed during transfer for %d file(s)",
+			len(task.SourceChanges)))
+	}
+	numMissing, numSkipped := len(task.MissingFiles), 0
+	for _, subtask := range task.Subtasks {
+		numMissing += len(subtask.MissingFiles)
+		numSkipped += len(subtask.SkippedFiles)
+	}
+	if numMissing > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d file(s) missing at source and not delivered",
+			numMissing))
+	}
+	if numSkipped > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d file(s) skipped because of source errors and not delivered",
+			numSkipped))
+	}
+	return warnings
+}
+
+// returns warnings about the metadata of the given file descriptors, and about
+// the given files dropped from a payload
+func metadataWarnings(descriptors []map[string]any, dropped []DroppedResource) []string {
+	var warnings []string
+	if len(dropped) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d file(s) dropped due to malformed metadata",
+			len(dropped)))
+	}
+	var missingHashes, incompleteCredit int
+	for _, descriptor := range descriptors {
+		if frictionless.String(descriptor, "hash") == "" && !frictionless.Bool(descriptor, "recursive") {
+			missingHashes++
+		}
+		if creditIncomplete(descriptor["credit"]) {
+			incompleteCredit++
+		}
+	}
+	if missingHashes > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d file(s) missing checksums", missingHashes))
+	}
+	if incompleteCredit > 0 {
+		warnings = append(warnings, fmt.Sprintf("credit metadata missing or incomplete for %d file(s)",
+			incompleteCredit))
+	}
+	return warnings
+}
+
+// returns true if the given credit metadata is missing or lacks an identifier
+// or contributors
+func creditIncomplete(metadata any) bool {
+	switch c := metadata.(type) {
+	case credit.CreditMetadata:
+		return c.Identifier == "" || len(c.Contributors) == 0
+	case map[string]any: // (decoded from JSON)
+		contributors, _ := c["contributors"].([]any)
+		return frictionless.String(c, "identifier") == "" || len(contributors) == 0
+	default:
+		return true
+	}
+}
+
+// returns true if the task bears all of the given tags, false otherwise
+func (task transferTask) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
 // returns true if the task has completed (successfully or not), false otherwise
 func (task transferTask) Completed() bool {
 	if task.Status.Code == TransferStatusSucceeded ||
@@ -350,10 +1030,58 @@ func (task transferTask) Completed() bool {
 // creates a DataPackage that serves as the transfer manifest
 func (task *transferTask) createManifest() (*datapackage.Package, error) {
 	// gather all file and data descriptors
+	// (recording the delivered paths of files whose paths were sanitized)
 	descriptors := make([]any, 0)
-	for _, subtask := range task.Subtasks {
-		descriptors = append(descriptors, subtask.Descriptors...)
+	renamedPaths := make(map[string]string)
+	for i := range task.Subtasks {
+		subtask := &task.Subtasks[i]
+		subtask.identifyFormats()
+		for _, d := range subtask.Descriptors {
+			if descriptor, ok := d.(map[string]any); ok {
+				id := frictionless.String(descriptor, "id")
+				if _, skipped := subtask.SkippedFiles[id]; skipped {
+					continue // not delivered
+				}
+				if _, corrupt := subtask.CorruptFiles[id]; corrupt {
+					continue // quarantined
+				}
+				descriptor = maps.Clone(descriptor)
+				normalizeCreditLanguages(descriptor)
+				path := frictionless.String(descriptor, "path")
+				if renamed, found := subtask.RenamedPaths[path]; found {
+					descriptor["path"] = renamed
+					renamedPaths[path] = renamed
+				}
+				if task.MetadataOnly { // the file stays at its source
+					descriptor["stub"] = subtask.stubPath(path)
+				} else { // tell consumers where to find the file
+					destinationPath := filepath.Join(task.DestinationFolder, subtask.destinationPath(path))
+					descriptor["destination_path"] = destinationPath
+					if accessURL := task.accessURL(destinationPath); accessURL != "" {
+						descriptor["access_url"] = accessURL
+					}
+				}
+				d = descriptor
+			}
+			descriptors = append(descriptors, d)
+		}
 	}
+
+	// list files in the order in which they were requested (regardless of the
+	// subtasks that transferred them), followed by in-line data
+	indices := requestIndices(task.FileIds)
+	for _, d := range descriptors {
+		if descriptor, ok := d.(map[string]any); ok {
+			if index, found := indices[frictionless.String(descriptor, "id")]; found {
+				descriptor["index"] = index
+			}
+		}
+	}
+	slices.SortStableFunc(descriptors, func(a, b any) int {
+		aDescriptor, _ := a.(map[string]any)
+		bDescriptor, _ := b.(map[string]any)
+		return compareRequestIndices(indices, aDescriptor, bDescriptor)
+	})
 	descriptors = append(descriptors, task.DataDescriptors...)
 
 	taskUser := map[string]any{
@@ -397,6 +1125,73 @@ func (task *transferTask) createManifest() (*datapackage.Package, error) {
 		"username":     username,
 	}
 
+	if descriptions := task.manifestDescriptions(); len(descriptions) > 0 {
+		descriptor["descriptions"] = descriptions
+	}
+	if len(task.Tags) > 0 {
+		descriptor["tags"] = task.Tags
+	}
+	if task.Batch != uuid.Nil {
+		descriptor["batch"] = task.Batch.String()
+	}
+	if licenses := manifestLicenses(descriptors); len(licenses) > 0 {
+		descriptor["licenses"] = licenses
+	}
+	if task.DOI != "" {
+		descriptor["doi"] = task.DOI
+	}
+	if task.SkipChecksums {
+		descriptor["skip_checksums"] = true
+	}
+	if task.MetadataOnly {
+		descriptor["metadata_only"] = true
+	}
+	if missing := task.missingFiles(); len(missing) > 0 { // record files deleted upstream
+		descriptor["missing_files"] = missing
+	}
+	if skipped := task.skippedFiles(); len(skipped) > 0 { // record undelivered files
+		descriptor["skipped_files"] = skipped
+	}
+	if corrupt := task.corruptFiles(); len(corrupt) > 0 { // record quarantined files
+		descriptor["corrupt_files"] = corrupt
+	}
+	if len(task.Datasets) > 0 { // record dataset expansions
+		datasets := make([]any, 0, len(task.Datasets))
+		for _, datasetId := range slices.Sorted(maps.Keys(task.Datasets)) {
+			datasets = append(datasets, map[string]any{
+				"id":       datasetId,
+				"file_ids": task.Datasets[datasetId],
+			})
+		}
+		descriptor["datasets"] = datasets
+	}
+	if len(task.SourceChanges) > 0 { // flag files replaced upstream mid-transfer
+		descriptor["source_changes"] = task.SourceChanges
+	}
+	if len(renamedPaths) > 0 { // map original file paths to sanitized ones
+		renamed := make([]any, 0, len(renamedPaths))
+		for _, path := range slices.Sorted(maps.Keys(renamedPaths)) {
+			renamed = append(renamed, map[string]any{
+				"original":  path,
+				"sanitized": renamedPaths[path],
+			})
+		}
+		descriptor["renamed_paths"] = renamed
+	}
+	if warnings := task.warnings(); len(warnings) > 0 { // report non-fatal issues
+		descriptor["warnings"] = warnings
+	}
+	if len(task.DroppedResources) > 0 { // report files dropped for malformed metadata
+		dropped := make([]any, len(task.DroppedResources))
+		for i, resource := range task.DroppedResources {
+			dropped[i] = map[string]any{
+				"id":     resource.Id,
+				"reason": resource.Reason,
+			}
+		}
+		descriptor["dropped_resources"] = dropped
+	}
+
 	manifest, err := datapackage.New(descriptor, ".")
 	if err != nil {
 		slog.Error(err.Error())
@@ -405,6 +1200,320 @@ func (task *transferTask) createManifest() (*datapackage.Package, error) {
 	return manifest, nil
 }
 
+// stores the task's manifest in the long-term manifest archive (if it's open),
+// logging any errors, which don't interfere with the transfer
+func (task *transferTask) archiveManifest() {
+	if !manifests.IsOpen() {
+		return
+	}
+	content, err := os.ReadFile(task.ManifestFile)
+	if err == nil {
+		err = manifests.Archive(manifests.Entry{
+			Id:          task.Id,
+			Orcid:       task.User.Orcid,
+			Source:      task.Source,
+			Destination: task.Destination,
+			FileIds:     task.FileIds,
+			Manifest:    content,
+		})
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Task %s: archiving manifest: %s", task.Id.String(), err.Error()))
+	}
+}
+
+// returns the URL at which the file with the given path (relative to the root
+// of the task's destination endpoint) can be accessed, or "" if the task's
+// destination has no configured access URL
+func (task transferTask) accessURL(destinationPath string) string {
+	baseURL := config.Databases[task.Destination].AccessURL
+	if baseURL == "" {
+		return ""
+	}
+	accessURL, err := url.JoinPath(baseURL, strings.Split(filepath.ToSlash(destinationPath), "/")...)
+	if err != nil {
+		return ""
+	}
+	return accessURL
+}
+
+// writes the given manifest to disk (archiving it if it's final) and begins
+// transferring it (and any stubs for a metadata-only task) to the task's
+// destination endpoint, returning the UUID of the transfer
+func (task *transferTask) sendManifest(manifest *datapackage.Package, final bool) (uuid.UUID, error) {
+	localEndpoint, err := endpoints.NewEndpoint(config.Service.Endpoint)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	destinationEndpoint, err := resolveDestinationEndpoint(task.Destination)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	task.ManifestFile = filepath.Join(config.Service.ManifestDirectory, fmt.Sprintf("manifest-%s.json", task.Id.String()))
+	if final {
+		err = manifest.SaveDescriptor(task.ManifestFile)
+	} else {
+		err = saveProvisionalManifest(manifest, task.ManifestFile)
+	}
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("creating manifest file: %s", err.Error())
+	}
+	if final {
+		task.archiveManifest()
+	}
+
+	// construct the source/destination file manifest paths
+	fileXfers := []FileTransfer{
+		{
+			SourcePath:      task.ManifestFile,
+			DestinationPath: filepath.Join(task.DestinationFolder, "manifest.json"),
+		},
+	}
+	if _, canMove := destinationEndpoint.(endpoints.MovingEndpoint); !final && canMove {
+		// deliver the final manifest alongside the provisional one, so it can
+		// be put in place if it doesn't change
+		task.FinalManifestFile = filepath.Join(config.Service.ManifestDirectory,
+			fmt.Sprintf("manifest-%s-final.json", task.Id.String()))
+		if err := manifest.SaveDescriptor(task.FinalManifestFile); err != nil {
+			return uuid.UUID{}, fmt.Errorf("creating manifest file: %s", err.Error())
+		}
+		fileXfers = append(fileXfers, FileTransfer{
+			SourcePath:      task.FinalManifestFile,
+			DestinationPath: filepath.Join(task.DestinationFolder, finalManifestName),
+		})
+	}
+	if task.MetadataOnly { // deliver stubs describing the files with the manifest
+		stubXfers, err := task.writeStubs()
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		fileXfers = append(fileXfers, stubXfers...)
+	}
+	checksumXfers, err := task.writeChecksumFiles()
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	fileXfers = append(fileXfers, checksumXfers...)
+	jsonLDXfers, err := task.writeJSONLD(manifest)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	fileXfers = append(fileXfers, jsonLDXfers...)
+
+	// begin transferring the manifest
+	err = checkEncryption(localEndpoint, config.Service.Endpoint, config.Databases[task.Destination].Endpoint)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	manifestId, err := localEndpoint.Transfer(destinationEndpoint, fileXfers)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("transferring manifest file: %w", err)
+	}
+	return manifestId, nil
+}
+
+// the name of the file (in the destination folder) holding the final copy of a
+// manifest delivered along with its payload, until the manifest is confirmed
+const finalManifestName = ".manifest.final.json"
+
+// writes the given manifest to the file with the given name, flagged as
+// provisional with a "pending" field
+func saveProvisionalManifest(manifest *datapackage.Package, filename string) error {
+	descriptor := manifest.Descriptor()
+	descriptor["pending"] = true
+	provisional, err := datapackage.New(descriptor, ".")
+	if err != nil {
+		return err
+	}
+	return provisional.SaveDescriptor(filename)
+}
+
+// sends a provisional manifest to the task's destination along with its
+// payload, recording its fingerprint so it can be replaced if the final
+// manifest differs
+func (task *transferTask) sendEarlyManifest() error {
+	manifest, err := task.createManifest()
+	if err != nil {
+		return fmt.Errorf("generating manifest file content: %s", err.Error())
+	}
+	manifestId, err := task.sendManifest(manifest, false)
+	if err != nil {
+		return err
+	}
+	task.EarlyManifest = uuid.NullUUID{UUID: manifestId, Valid: true}
+	task.EarlyManifestFingerprint = manifestFingerprint(manifest)
+	return nil
+}
+
+// replaces the provisional manifest sent with the task's payload with the final
+// copy delivered alongside it, returning true if this succeeds or false if the
+// final manifest must be sent (e.g. because the destination endpoint can't
+// move files)
+func (task *transferTask) finalizeEarlyManifest() bool {
+	if task.FinalManifestFile == "" {
+		return false
+	}
+	destination, err := resolveDestinationEndpoint(task.Destination)
+	if err == nil {
+		if mover, ok := destination.(endpoints.MovingEndpoint); ok {
+			err = mover.Move(filepath.Join(task.DestinationFolder, finalManifestName),
+				filepath.Join(task.DestinationFolder, "manifest.json"))
+		} else {
+			err = fmt.Errorf("destination endpoint can't move files")
+		}
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't finalize early manifest: %s", task.Id.String(),
+			err.Error()))
+		task.discardFinalManifest()
+		return false
+	}
+	os.Remove(task.FinalManifestFile)
+	task.FinalManifestFile = ""
+	return true
+}
+
+// removes the final copy of the manifest sent with the task's early manifest
+// (if any) from the task's destination (if the destination endpoint allows it)
+// and from the manifest directory
+func (task *transferTask) discardFinalManifest() {
+	if task.FinalManifestFile == "" {
+		return
+	}
+	os.Remove(task.FinalManifestFile)
+	task.FinalManifestFile = ""
+
+	path := filepath.Join(task.DestinationFolder, finalManifestName)
+	destination, err := resolveDestinationEndpoint(task.Destination)
+	if err == nil {
+		if deleter, ok := destination.(endpoints.DeletingEndpoint); ok {
+			err = deleter.Delete(path)
+		} else {
+			err = fmt.Errorf("destination endpoint can't delete files")
+		}
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't remove final manifest copy %s: %s", task.Id.String(),
+			path, err.Error()))
+	}
+}
+
+// returns true if the transfer of the task's early manifest has finished,
+// discarding the early manifest (so it's replaced) if its transfer failed
+func (task *transferTask) earlyManifestArrived() (bool, error) {
+	localEndpoint, err := endpoints.NewEndpoint(config.Service.Endpoint)
+	if err != nil {
+		return false, err
+	}
+	xferStatus, err := localEndpoint.Status(task.EarlyManifest.UUID)
+	if err != nil {
+		if task.ManifestInterrupted { // lost when the service restarted, so replace it
+			slog.Warn(fmt.Sprintf("Task %s: early manifest transfer lost after restart (%s)",
+				task.Id.String(), err.Error()))
+			task.EarlyManifest = uuid.NullUUID{}
+			task.ManifestInterrupted = false
+			return true, nil
+		}
+		return false, err
+	}
+	task.ManifestInterrupted = false
+	switch xferStatus.Code {
+	case TransferStatusSucceeded:
+		return true, nil
+	case TransferStatusFailed:
+		slog.Warn(fmt.Sprintf("Task %s: early manifest transfer failed: %s", task.Id.String(),
+			xferStatus.Message))
+		task.EarlyManifest = uuid.NullUUID{}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// cancels the transfer of the task's early manifest and removes it from the
+// task's destination (if the destination endpoint allows it), so that a failed
+// or canceled task doesn't leave a manifest describing files that never arrived
+func (task *transferTask) retractEarlyManifest() {
+	if !task.EarlyManifest.Valid {
+		return
+	}
+	manifestId := task.EarlyManifest.UUID
+	task.EarlyManifest = uuid.NullUUID{}
+	if localEndpoint, err := endpoints.NewEndpoint(config.Service.Endpoint); err == nil {
+		localEndpoint.Cancel(manifestId) // (in case it's still underway)
+	}
+	os.Remove(task.ManifestFile)
+	task.ManifestFile = ""
+
+	manifestPath := filepath.Join(task.DestinationFolder, "manifest.json")
+	destination, err := resolveDestinationEndpoint(task.Destination)
+	if err == nil {
+		if deleter, ok := destination.(endpoints.DeletingEndpoint); ok {
+			err = deleter.Delete(manifestPath)
+		} else {
+			err = fmt.Errorf("destination endpoint can't delete files")
+		}
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Task %s: couldn't remove early manifest %s: %s", task.Id.String(),
+			manifestPath, err.Error()))
+	}
+	task.discardFinalManifest()
+	if destination != nil {
+		task.retractChecksumFiles(destination)
+		task.retractJSONLD(destination)
+	} else {
+		task.removeChecksumFiles()
+		task.removeJSONLD()
+	}
+}
+
+// returns a fingerprint of the content of the given manifest (excluding its
+// creation time), which identifies manifests that describe the same transfer
+func manifestFingerprint(manifest *datapackage.Package) string {
+	descriptor := maps.Clone(manifest.Descriptor())
+	delete(descriptor, "created")
+	data, err := json.Marshal(descriptor)
+	if err != nil {
+		return ""
+	}
+	fingerprint := sha256.Sum256(data)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// the number of times a task's manifest is resubmitted after its transfer is
+// lost to a restart of the service, before the task is declared failed
+const maxManifestResubmissions = 3
+
+// regenerates the task's manifest and resubmits it for transfer after its
+// previous transfer was lost (indicated by the given error) to a restart of
+// the service
+func (task *transferTask) resubmitManifest(lostErr error) error {
+	slog.Warn(fmt.Sprintf("Task %s: manifest transfer lost after restart (%s); resubmitting",
+		task.Id.String(), lostErr.Error()))
+	manifest, err := task.createManifest()
+	if err != nil {
+		return fmt.Errorf("regenerating manifest file content: %s", err.Error())
+	}
+	task.Manifest.UUID, err = task.sendManifest(manifest, true)
+	if err != nil {
+		return err
+	}
+	task.ManifestResubmissions++
+	task.ManifestInterrupted = false
+	return nil
+}
+
+// flags the task's manifest transfer (if any) as interrupted, so it's
+// resubmitted if the transfer was lost. This is called for each task restored
+// when the service restarts.
+func (task *transferTask) interruptManifest() {
+	if !task.Completed() && (task.Manifest.Valid || task.EarlyManifest.Valid) {
+		slog.Info(fmt.Sprintf("Task %s: resuming finalization after restart", task.Id.String()))
+		task.ManifestInterrupted = true
+	}
+}
+
 // checks whether the file manifest for a task has been transferred and, if so, finalizes the
 // transfer and marks the task as completed
 func (task *transferTask) checkManifest() error {
@@ -415,8 +1524,16 @@ func (task *transferTask) checkManifest() error {
 	}
 	xferStatus, err := localEndpoint.Status(task.Manifest.UUID)
 	if err != nil {
+		if task.ManifestInterrupted && task.ManifestResubmissions < maxManifestResubmissions {
+			// the manifest transfer was probably lost when the service restarted
+			return task.resubmitManifest(err)
+		}
 		return err
 	}
+	task.ManifestInterrupted = false
+	if xferStatus.Code == TransferStatusSucceeded && !task.publishDOI() {
+		return nil // check back once DataCite has responded
+	}
 	if xferStatus.Code == TransferStatusSucceeded ||
 		xferStatus.Code == TransferStatusFailed {
 		task.CompletionTime = time.Now()
@@ -447,6 +1564,7 @@ func (task *transferTask) checkManifest() error {
 				Source:      task.Source,
 				Destination: task.Destination,
 				Orcid:       task.User.Orcid,
+				Allocation:  task.Allocation,
 				StartTime:   task.StartTime,
 				StopTime:    task.CompletionTime,
 				Status:      statusString,
@@ -460,14 +1578,69 @@ func (task *transferTask) checkManifest() error {
 		*/
 		task.Manifest = uuid.NullUUID{}
 		os.Remove(task.ManifestFile)
+		task.removeStubs()
+		task.removeChecksumFiles()
+		task.removeJSONLD()
 
 		task.ManifestFile = ""
 		task.Status.Code = xferStatus.Code
+		if xferStatus.Code == TransferStatusSucceeded {
+			recordStageTimings(task.timeline())
+		}
 		task.Status.Message = ""
+		if corrupt := task.corruptFiles(); xferStatus.Code == TransferStatusSucceeded && len(corrupt) > 0 {
+			// the payload wasn't delivered intact
+			task.Status.Code = TransferStatusFailed
+			task.Status.Message = fmt.Sprintf("%d file(s) failed checksum verification and were quarantined (see manifest)",
+				len(corrupt))
+		} else if warnings := task.warnings(); xferStatus.Code == TransferStatusSucceeded && len(warnings) > 0 {
+			task.Status.Message = fmt.Sprintf("warning: %s (see manifest)", strings.Join(warnings, "; "))
+		}
 	}
 	return nil
 }
 
+// extracts any filter rules for directory payloads from the "filter_rules"
+// field of the given transfer instructions, which holds an array of objects
+// with "method" ("include" or "exclude"), "name" (a glob pattern), and
+// optional "type" ("file" or "dir") fields, as in the Globus Transfer API
+func filterRulesFromInstructions(instructions map[string]any) ([]FilterRule, error) {
+	value, found := instructions["filter_rules"]
+	if !found {
+		return nil, nil
+	}
+	ruleObjects, ok := value.([]any)
+	if !ok {
+		return nil, &InvalidFilterRulesError{Message: "filter_rules must be an array"}
+	}
+	rules := make([]FilterRule, len(ruleObjects))
+	for i, ruleObject := range ruleObjects {
+		fields, ok := ruleObject.(map[string]any)
+		if !ok {
+			return nil, &InvalidFilterRulesError{Message: fmt.Sprintf("rule %d is not an object", i)}
+		}
+		rules[i].Method, _ = fields["method"].(string)
+		rules[i].Type, _ = fields["type"].(string)
+		rules[i].Name, _ = fields["name"].(string)
+		if rules[i].Method != "include" && rules[i].Method != "exclude" {
+			return nil, &InvalidFilterRulesError{
+				Message: fmt.Sprintf("rule %d has invalid method '%s' (must be include or exclude)", i, rules[i].Method),
+			}
+		}
+		if rules[i].Type != "" && rules[i].Type != "file" && rules[i].Type != "dir" {
+			return nil, &InvalidFilterRulesError{
+				Message: fmt.Sprintf("rule %d has invalid type '%s' (must be file or dir)", i, rules[i].Type),
+			}
+		}
+		if _, err := filepath.Match(rules[i].Name, ""); rules[i].Name == "" || err != nil {
+			return nil, &InvalidFilterRulesError{
+				Message: fmt.Sprintf("rule %d has invalid name pattern '%s'", i, rules[i].Name),
+			}
+		}
+	}
+	return rules, nil
+}
+
 func determineDestinationFolder(task transferTask) (string, error) {
 	// construct a destination folder name
 	if customSpec, err := endpoints.ParseCustomSpec(task.Destination); err == nil { // custom transfer?
@@ -484,14 +1657,220 @@ func determineDestinationFolder(task transferTask) (string, error) {
 	return filepath.Join(username, "dts-"+task.Id.String()), nil
 }
 
+// returns a mapping of the given requested file IDs to their (first) positions
+// in the request
+func requestIndices(fileIds []string) map[string]int {
+	indices := make(map[string]int, len(fileIds))
+	for i, id := range fileIds {
+		if _, found := indices[id]; !found {
+			indices[id] = i
+		}
+	}
+	return indices
+}
+
+// compares two descriptors by the positions of their IDs in a request, placing
+// those that weren't requested last
+func compareRequestIndices(indices map[string]int, a, b map[string]any) int {
+	aIndex, aFound := indices[frictionless.String(a, "id")]
+	bIndex, bFound := indices[frictionless.String(b, "id")]
+	if aFound && bFound {
+		return cmp.Compare(aIndex, bIndex)
+	} else if aFound {
+		return -1
+	} else if bFound {
+		return 1
+	}
+	return 0
+}
+
+// returns true if the given descriptor matches any of the given exclusions,
+// which are resource IDs or glob patterns over resource names
+func excluded(descriptor map[string]any, exclusions []string) bool {
+	id, _ := descriptor["id"].(string)
+	name, _ := descriptor["name"].(string)
+	for _, exclusion := range exclusions {
+		if exclusion == id {
+			return true
+		}
+		if matched, _ := filepath.Match(exclusion, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checks the given exclusions for malformed name patterns
+func validateExclusions(exclusions []string) error {
+	for _, exclusion := range exclusions {
+		if _, err := filepath.Match(exclusion, ""); err != nil {
+			return &InvalidExclusionError{Exclusion: exclusion}
+		}
+	}
+	return nil
+}
+
+// returns the IDs of the files in the given descriptors that are embargoed at
+// the given time, mapped to the times at which their embargoes lift
+func embargoedFiles(descriptors []map[string]any, now time.Time) (map[string]time.Time, error) {
+	embargoes := make(map[string]time.Time)
+	for _, descriptor := range descriptors {
+		value, found := descriptor["embargo_until"]
+		if !found {
+			continue
+		}
+		id, _ := descriptor["id"].(string)
+		until, err := parseEmbargoTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("descriptor '%s' has an invalid embargo_until field: %v",
+				id, value)
+		}
+		if now.Before(until) {
+			embargoes[id] = until
+		}
+	}
+	return embargoes, nil
+}
+
+// parses an embargo time given as an RFC 3339 timestamp or a date
+func parseEmbargoTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if until, err := time.Parse(time.RFC3339, v); err == nil {
+			return until, nil
+		}
+		return time.Parse(time.DateOnly, v)
+	default:
+		return time.Time{}, fmt.Errorf("invalid embargo time: %v", value)
+	}
+}
+
+// returns an error if the destination endpoint with the given name requires
+// encrypted transfers that the given source endpoint can't provide
+func checkEncryption(source Endpoint, sourceName, destinationName string) error {
+	if !config.Endpoints[destinationName].EncryptData {
+		return nil
+	}
+	if encrypting, ok := source.(endpoints.EncryptingEndpoint); ok && encrypting.EncryptsTransfers() {
+		return nil
+	}
+	return &EncryptionRequiredError{Source: sourceName, Destination: destinationName}
+}
+
+// returns an error if an egress policy forbids the transfer of any of the
+// requested files from the specified source to the specified destination
+// (the files' descriptors are fetched with the given function only if a
+// policy governs some, but not all, of the source's files)
+func checkEgressPolicies(spec Specification, fileDescriptors func() ([]map[string]any, error)) error {
+	for _, name := range slices.Sorted(maps.Keys(config.EgressPolicies)) {
+		policy := config.EgressPolicies[name]
+		if policy.Source != spec.Source || slices.Contains(policy.Destinations, spec.Destination) {
+			continue
+		}
+		violation := &EgressPolicyViolationError{
+			Policy:      name,
+			Description: policy.Description,
+			Source:      spec.Source,
+			Destination: spec.Destination,
+		}
+		if len(policy.Match) == 0 {
+			return violation
+		}
+		descriptors, err := fileDescriptors()
+		if err != nil {
+			return err
+		}
+		for _, descriptor := range descriptors {
+			if matchesPolicy(descriptor, policy.Match) {
+				violation.FileIds = append(violation.FileIds, frictionless.String(descriptor, "id"))
+			}
+		}
+		if len(violation.FileIds) > 0 {
+			return violation
+		}
+	}
+	return nil
+}
+
+// returns an EmbargoedFilesError if any of the requested files from the given
+// source database (whose descriptors are fetched with the given function) is
+// embargoed and the task doesn't wait for embargoes to lift (only databases
+// that report embargoes are checked)
+func checkEmbargoes(source databases.Database, spec Specification,
+	fileDescriptors func() ([]map[string]any, error)) error {
+	embargoing, ok := source.(databases.EmbargoingDatabase)
+	if spec.WaitForEmbargo || !ok || !embargoing.ReportsEmbargoes() {
+		return nil
+	}
+	descriptors, err := fileDescriptors()
+	if err != nil {
+		return err
+	}
+	embargoes, err := embargoedFiles(descriptors, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(embargoes) > 0 {
+		return &EmbargoedFilesError{Embargoes: embargoes}
+	}
+	return nil
+}
+
+// returns true if the given descriptor has all of the given field values
+func matchesPolicy(descriptor map[string]any, match map[string]any) bool {
+	for field, value := range match {
+		if descriptorValue, found := descriptor[field]; !found ||
+			fmt.Sprint(descriptorValue) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns an error if the endpoint of the given destination database requires
+// encrypted transfers that any endpoint delivering files from the given source
+// database can't provide
+func checkDatabaseEncryption(source, destination string) error {
+	destinationName := config.Databases[destination].Endpoint
+	if !config.Endpoints[destinationName].EncryptData {
+		return nil
+	}
+	sourceNames := []string{config.Databases[source].Endpoint}
+	if len(config.Databases[source].Endpoints) > 0 {
+		sourceNames = slices.Collect(maps.Values(config.Databases[source].Endpoints))
+	}
+	for _, sourceName := range sourceNames {
+		if relay := config.Endpoints[sourceName].Relay; relay != "" { // relay delivers files
+			sourceName = relay
+		}
+		sourceEndpoint, err := endpoints.NewEndpoint(sourceName)
+		if err != nil {
+			return err
+		}
+		if err := checkEncryption(sourceEndpoint, sourceName, destinationName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func resolveDestinationEndpoint(destination string) (endpoints.Endpoint, error) {
 	// everything's been validated at this point, so no need to check for errors
 	if strings.Contains(destination, ":") { // custom transfer spec
 		customSpec, _ := endpoints.ParseCustomSpec(destination)
 		endpointId, _ := uuid.Parse(customSpec.Id)
-		credential := config.Credentials[customSpec.Credential]
+		credential, err := credentials.Get(customSpec.Credential)
+		if err != nil {
+			return nil, err
+		}
 		clientId, _ := uuid.Parse(credential.Id)
-		return globus.NewEndpoint("Custom endpoint", endpointId, customSpec.Path, clientId, credential.Secret)
+		endpoint, err := globus.NewEndpoint("Custom endpoint", endpointId, customSpec.Path, clientId, credential.Secret)
+		if err == nil {
+			endpoint.(*globus.Endpoint).Credential = customSpec.Credential
+		}
+		return endpoint, err
 	}
 	return endpoints.NewEndpoint(config.Databases[destination].Endpoint)
 }
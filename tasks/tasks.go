@@ -23,13 +23,12 @@ package tasks
 
 import (
 	"bytes"
-	"encoding/gob"
-	"errors"
 	"fmt"
-	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,20 +36,32 @@ import (
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
 	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/databases/essdive"
+	"github.com/kbase/dts/databases/generic"
+	"github.com/kbase/dts/databases/gold"
 	"github.com/kbase/dts/databases/jdp"
 	"github.com/kbase/dts/databases/kbase"
 	"github.com/kbase/dts/databases/nmdc"
 	"github.com/kbase/dts/endpoints"
 	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/endpoints/https"
 	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/endpoints/sftp"
+	"github.com/kbase/dts/faults"
 	"github.com/kbase/dts/journal"
+	"github.com/kbase/dts/simulation"
+	"github.com/kbase/dts/store"
+	"github.com/kbase/dts/tracing"
+	"github.com/kbase/dts/units"
 )
 
 // useful type aliases
 type Database = databases.Database
 type Endpoint = endpoints.Endpoint
 type FileTransfer = endpoints.FileTransfer
+type FilterRule = endpoints.FilterRule
 type TransferStatus = endpoints.TransferStatus
+type TransferStatusCode = endpoints.TransferStatusCode
 
 // useful constants
 const (
@@ -61,6 +72,7 @@ const (
 	TransferStatusFinalizing = endpoints.TransferStatusFinalizing
 	TransferStatusInactive   = endpoints.TransferStatusInactive
 	TransferStatusSucceeded  = endpoints.TransferStatusSucceeded
+	TransferStatusWaiting    = endpoints.TransferStatusWaiting
 )
 
 // starts processing tasks according to the given configuration, returning an
@@ -71,8 +83,14 @@ func Start() error {
 	}
 
 	// if this is the first call to Start(), register our built-in endpoint
-	// and database providers
-	if firstCall {
+	// and database providers (or simulated ones, in simulation mode)
+	if firstCall && config.Service.Simulation.Enabled {
+		err := simulation.Register()
+		if err != nil {
+			return err
+		}
+		firstCall = false
+	} else if firstCall {
 
 		// NOTE: it's okay if these endpoint providers have already been registered,
 		// NOTE: as they can be used in testing
@@ -80,6 +98,12 @@ func Start() error {
 		if err == nil {
 			err = endpoints.RegisterEndpointProvider("local", local.NewEndpoint)
 		}
+		if err == nil {
+			err = endpoints.RegisterEndpointProvider("sftp", sftp.NewEndpoint)
+		}
+		if err == nil {
+			err = endpoints.RegisterEndpointProvider("https", https.NewEndpoint)
+		}
 		if err != nil {
 			if _, matches := err.(*endpoints.AlreadyRegisteredError); !matches {
 				return err
@@ -106,6 +130,26 @@ func Start() error {
 		if err != nil {
 			slog.Error(err.Error())
 		}
+		if _, found := config.Databases["essdive"]; found {
+			err = databases.RegisterDatabase("essdive", essdive.NewDatabase)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		if _, found := config.Databases["gold"]; found {
+			err = databases.RegisterDatabase("gold", gold.NewDatabase)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		for dbName, dbConfig := range config.Databases {
+			if dbConfig.Generic != nil {
+				err = databases.RegisterDatabase(dbName, generic.NewDatabaseFunc(dbName))
+				if err != nil {
+					slog.Error(err.Error())
+				}
+			}
+		}
 
 		firstCall = false
 	}
@@ -126,6 +170,12 @@ func Start() error {
 		return err
 	}
 
+	// open the task store
+	err = store.Init()
+	if err != nil {
+		return err
+	}
+
 	// fire up the transfer journal
 	err = journal.Init()
 	if err != nil {
@@ -134,14 +184,27 @@ func Start() error {
 
 	// allocate channels
 	taskChannels = channelsType{
-		CreateTask:       make(chan transferTask, 32),
-		CancelTask:       make(chan uuid.UUID, 32),
-		GetTaskStatus:    make(chan uuid.UUID, 32),
-		ReturnTaskId:     make(chan uuid.UUID, 32),
-		ReturnTaskStatus: make(chan TransferStatus, 32),
-		Error:            make(chan error, 32),
-		Poll:             make(chan struct{}),
-		Stop:             make(chan struct{}),
+		CreateTask:        make(chan transferTask, 32),
+		CancelTask:        make(chan uuid.UUID, 32),
+		GetTaskStatus:     make(chan uuid.UUID, 32),
+		ListTasks:         make(chan []string, 32),
+		GetTaskSummary:    make(chan uuid.UUID, 32),
+		AnnotateTask:      make(chan annotationRequest, 32),
+		PauseTask:         make(chan uuid.UUID, 32),
+		ResumeTask:        make(chan uuid.UUID, 32),
+		AnonymizeUser:     make(chan string, 32),
+		ReturnTaskId:      make(chan uuid.UUID, 32),
+		ReturnTaskStatus:  make(chan TransferStatus, 32),
+		ReturnTaskList:    make(chan []Summary, 32),
+		ReturnTaskSummary: make(chan Summary, 32),
+		ReturnAnonymized:  make(chan anonymizedTasks, 32),
+		Error:             make(chan error, 32),
+		Poll:              make(chan struct{}),
+		Sweep:             make(chan struct{}),
+		Checkpoint:        make(chan struct{}),
+		LiveFiles:         make(chan map[string]struct{}, 1),
+		Callbacks:         make(chan callbackDelivery, 256),
+		Stop:              make(chan struct{}),
 	}
 
 	// start processing tasks
@@ -153,6 +216,25 @@ func Start() error {
 	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
 	go heartbeat(pollInterval, taskChannels.Poll)
 
+	// start delivering callback events to their URLs
+	go deliverCallbacks(taskChannels.Callbacks)
+
+	// start the janitor, which cleans up orphaned scratch files
+	go janitor(taskChannels.LiveFiles)
+	if config.Service.JanitorInterval > 0 {
+		janitorInterval := time.Duration(config.Service.JanitorInterval) * time.Second
+		go heartbeat(janitorInterval, taskChannels.Sweep)
+	}
+
+	// start monitoring the expiration of upstream credentials
+	go monitorCredentials(credentialCheckInterval)
+
+	// start periodic checkpointing of our state
+	if config.Service.CheckpointInterval > 0 {
+		checkpointInterval := time.Duration(config.Service.CheckpointInterval) * time.Second
+		go heartbeat(checkpointInterval, taskChannels.Checkpoint)
+	}
+
 	// okay, we're running now
 	running = true
 
@@ -173,6 +255,10 @@ func Stop() error {
 		if err != nil {
 			return err
 		}
+		err = store.Finalize()
+		if err != nil {
+			return err
+		}
 		running = false
 	} else {
 		err = &NotRunningError{}
@@ -187,20 +273,69 @@ func Running() bool {
 
 // this type holds a specification used to create a valid transfer task
 type Specification struct {
+	// the allocation or project to which the task's data movement is attributed
+	// (if any)
+	Allocation string
+	// a URL to which signed events are POSTed as the task's status changes
+	// (if any)
+	CallbackURL string
+	// the KBase username of the DTS client through which the task was
+	// requested (if any), whose quota applies to the task along with that of
+	// its user
+	Client string
+	// the time by which the task must complete, after which it is canceled
+	// and marked as expired (if zero, the task has no deadline)
+	Deadline time.Time
 	// a Markdown description of the transfer task
 	Description string
+	// the IETF BCP 47 language tag of the task's description (if known)
+	DescriptionLanguage string
+	// translations of the task's description into other languages
+	Translations []Translation
 	// the name of destination database to which files are transferred (as
 	// specified in the DTS config file) OR a custom destination spec (<provider>:<id>:<credential>)
 	Destination string
 	// machine-readable instructions for processing the payload at its destination
+	// (a "filter_rules" field, if present, selects the contents of directories
+	// in the payload, which are transferred recursively)
 	Instructions map[string]any
 	// an array of identifiers for files to be transferred from Source to Destination
+	// (which may include identifiers for datasets, e.g. "JDP-PROJECT:<id>" or
+	// "NMDC-STUDY:<id>", that the source expands into the identifiers of their
+	// files)
 	FileIds []string
+	// identifiers or glob patterns (over resource names) for files excluded from
+	// those resolved from FileIds
+	Exclude []string
 	// the name of source database from which files are transferred (as specified
 	// in the DTS config file)
 	Source string
+	// if set, file checksums are neither submitted with the transfer nor
+	// verified (this is also the case if either database is configured to
+	// skip checksums)
+	SkipChecksums bool
+	// if set, files whose sources produce errors are skipped instead of
+	// failing the task (for source endpoints that support this)
+	SkipSourceErrors bool
+	// if set, the files aren't transferred: the manifest is delivered with
+	// small stub files describing where the files can be accessed remotely
+	MetadataOnly bool
+	// user-defined labels used to group related tasks
+	Tags []string
+	// the context of the span tracing the request that creates the task (if
+	// any), within whose trace the task's work is traced
+	Trace tracing.SpanContext
+	// a batch shared by related tasks created together, so they can be
+	// tracked as a unit (uuid.Nil if none)
+	Batch uuid.UUID
+	// IDs of existing tasks (belonging to the same user) that must succeed
+	// before this task starts -- if any of them fails, so does this task
+	DependsOn []uuid.UUID
 	// information about the user requesting the task
 	User auth.User
+	// if set, a task requesting embargoed files waits for their embargoes to
+	// lift instead of failing
+	WaitForEmbargo bool
 }
 
 // Creates a new transfer task associated with the user with the specified Orcid
@@ -215,8 +350,20 @@ func Create(spec Specification) (uuid.UUID, error) {
 		return taskId, &NoFilesRequestedError{}
 	}
 
+	// is there time to transfer them?
+	if !spec.Deadline.IsZero() && !spec.Deadline.After(time.Now()) {
+		return taskId, &InvalidDeadlineError{Deadline: spec.Deadline}
+	}
+
+	// check the languages of the task's description
+	descriptionLanguage, translations, err := normalizeDescriptionLanguages(spec.DescriptionLanguage,
+		spec.Translations)
+	if err != nil {
+		return taskId, err
+	}
+
 	// verify the source and destination strings
-	_, err := databases.NewDatabase(spec.Source) // source must refer to a database
+	source, err := databases.NewDatabase(spec.Source) // source must refer to a database
 	if err != nil {
 		return taskId, err
 	}
@@ -228,14 +375,86 @@ func Create(spec Specification) (uuid.UUID, error) {
 		}
 	}
 
+	// make sure the destination's encryption requirements can be met
+	if databases.HaveDatabase(spec.Destination) {
+		if err = checkDatabaseEncryption(spec.Source, spec.Destination); err != nil {
+			return taskId, err
+		}
+	}
+
+	// shed load if we're running out of room for our data and manifests
+	for _, dir := range []string{config.Service.DataDirectory, config.Service.ManifestDirectory} {
+		if err = checkDiskSpace(dir); err != nil {
+			return taskId, err
+		}
+	}
+
+	filterRules, err := filterRulesFromInstructions(spec.Instructions)
+	if err != nil {
+		return taskId, err
+	}
+	endpointOverrides, err := sourceEndpointOverrides(spec.Source, spec.Instructions)
+	if err != nil {
+		return taskId, err
+	}
+	if err = validateExclusions(spec.Exclude); err != nil {
+		return taskId, err
+	}
+
+	// expand any dataset IDs into the IDs of their files
+	fileIds := spec.FileIds
+	var datasets map[string][]string
+	if expander, ok := source.(databases.DatasetExpander); ok {
+		fileIds, datasets, err = expander.ExpandFileIds(spec.User.Orcid, spec.FileIds, spec.Instructions)
+		if err != nil {
+			return taskId, err
+		}
+	}
+
+	// make sure no egress policy forbids the transfer, and that no requested
+	// files are embargoed unless the task waits for them (fetching the files'
+	// descriptors once, and only if needed)
+	descriptors := sync.OnceValues(func() ([]map[string]any, error) {
+		return source.Descriptors(spec.User.Orcid, fileIds)
+	})
+	if err = checkEgressPolicies(spec, descriptors); err != nil {
+		return taskId, err
+	}
+	if err = checkEmbargoes(source, spec, descriptors); err != nil {
+		return taskId, err
+	}
+
+	// either database can opt out of checksums
+	skipChecksums := spec.SkipChecksums || config.Databases[spec.Source].SkipChecksums ||
+		config.Databases[spec.Destination].SkipChecksums
+
 	// create a new task and send it along for processing
 	taskChannels.CreateTask <- transferTask{
-		User:         spec.User,
-		Source:       spec.Source,
-		Destination:  spec.Destination,
-		FileIds:      spec.FileIds,
-		Description:  spec.Description,
-		Instructions: spec.Instructions,
+		Allocation:          spec.Allocation,
+		CallbackURL:         spec.CallbackURL,
+		Client:              spec.Client,
+		Deadline:            spec.Deadline,
+		User:                spec.User,
+		Source:              spec.Source,
+		Destination:         spec.Destination,
+		FileIds:             fileIds,
+		Datasets:            datasets,
+		Exclude:             spec.Exclude,
+		Description:         spec.Description,
+		DescriptionLanguage: descriptionLanguage,
+		Translations:        translations,
+		Instructions:        spec.Instructions,
+		SkipChecksums:       skipChecksums,
+		SkipSourceErrors:    spec.SkipSourceErrors,
+		MetadataOnly:        spec.MetadataOnly,
+		FilterRules:         filterRules,
+		EndpointOverrides:   endpointOverrides,
+		Tags:                spec.Tags,
+		Trace:               tracing.NewSpanContext(spec.Trace),
+		TraceParentId:       spec.Trace.SpanId,
+		Batch:               spec.Batch,
+		DependsOn:           spec.DependsOn,
+		WaitForEmbargo:      spec.WaitForEmbargo,
 	}
 	select {
 	case taskId = <-taskChannels.ReturnTaskId:
@@ -258,6 +477,169 @@ func Status(taskId uuid.UUID) (TransferStatus, error) {
 	return status, err
 }
 
+// this type summarizes a transfer task
+type Summary struct {
+	// the task's identifier
+	Id uuid.UUID
+	// the ORCID of the user who requested the task
+	Orcid string
+	// the allocation or project to which the task is attributed (if any)
+	Allocation string
+	// the task's transfer status
+	Status TransferStatus
+	// the size of the task's payload (gigabytes)
+	PayloadSize float64
+	// a free-text note attached to the task by its owner
+	Note string
+	// user-defined labels associated with the task
+	Tags []string
+	// the batch to which the task belongs (uuid.Nil if none)
+	Batch uuid.UUID
+	// IDs of tasks that must succeed before the task starts
+	DependsOn []uuid.UUID
+	// non-fatal issues with the task's payload (missing checksums, etc)
+	Warnings []string
+	// the time at which the task was requested
+	StartTime time.Time
+	// the time at which work on the task began (zero if it hasn't)
+	ProcessingTime time.Time
+	// the time at which the task completed (zero if it hasn't)
+	CompletionTime time.Time
+	// the time by which the task must complete (zero if it has no deadline)
+	Deadline time.Time
+	// true if the task was canceled because its deadline passed
+	Expired bool
+	// the estimated time at which the task will complete (zero if unknown)
+	EstimatedEnd time.Time
+	// summaries of the task's stages, each moving files from one source
+	// endpoint to the destination
+	Stages []StageSummary
+	// the time spent by the task in each pipeline stage it has entered
+	// (create, staging, transfer, finalize)
+	Timeline []TimelineEntry
+}
+
+// this type summarizes a stage of a transfer task
+type StageSummary struct {
+	// the name of the database providing the stage's files
+	Source string
+	// the name of the endpoint from which the stage's files are transferred
+	SourceEndpoint string
+	// the name of the endpoint relaying the stage's files (if any)
+	RelayEndpoint string
+	// the provider moving the stage's files ("https" for direct downloads),
+	// once their transfer has begun
+	Mover string
+	// the status of the staging of the stage's files at the source
+	StagingStatus databases.StagingStatus
+	// the status of the transfer of the stage's files
+	Status TransferStatus
+	// the files moved by the stage
+	Files []StageFile
+}
+
+// this type identifies a file moved by a stage of a transfer task
+type StageFile struct {
+	// the file's identifier
+	Id string
+	// the file's path, as given in its descriptor
+	Path string
+	// a description of the error that caused the file to be skipped (if it was)
+	SkipReason string
+	// the path to which the file was moved after failing checksum verification
+	// (if it did)
+	QuarantinePath string
+}
+
+// Given a task UUID, returns a summary of the task (or a non-nil error
+// indicating any issues encountered).
+func Summarize(taskId uuid.UUID) (Summary, error) {
+	var summary Summary
+	var err error
+	taskChannels.GetTaskSummary <- taskId
+	select {
+	case summary = <-taskChannels.ReturnTaskSummary:
+	case err = <-taskChannels.Error:
+	}
+	return summary, err
+}
+
+// this type holds changes to the annotations of an existing transfer task
+// (nil fields are left unchanged)
+type Annotation struct {
+	// a new free-text note for the task
+	Note *string
+	// a new set of user-defined labels for the task
+	Tags []string
+}
+
+// Updates the note and/or tags of the task with the given UUID on behalf of
+// the user with the given ORCID, who must own the task. The task's payload is
+// not affected.
+func Annotate(taskId uuid.UUID, orcid string, annotation Annotation) error {
+	taskChannels.AnnotateTask <- annotationRequest{
+		Id:         taskId,
+		Orcid:      orcid,
+		Annotation: annotation,
+	}
+	return <-taskChannels.Error
+}
+
+// Returns summaries of all transfer tasks bearing every one of the given
+// tags (or of all tasks if no tags are given), ordered by creation time.
+func List(tags []string) ([]Summary, error) {
+	var summaries []Summary
+	var err error
+	taskChannels.ListTasks <- tags
+	select {
+	case summaries = <-taskChannels.ReturnTaskList:
+	case err = <-taskChannels.Error:
+	}
+	return summaries, err
+}
+
+// Returns summaries of all transfer tasks belonging to the given batch,
+// ordered by creation time.
+func ListBatch(batch uuid.UUID) ([]Summary, error) {
+	summaries, err := List(nil)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(summaries, func(summary Summary) bool {
+		return summary.Batch != batch
+	}), nil
+}
+
+// Anonymizes the records of the completed transfer tasks requested by the user
+// with the given ORCID, returning the number of tasks anonymized and the number
+// of the user's tasks still in progress, which can be anonymized once they
+// complete.
+func AnonymizeUser(orcid string) (int, int, error) {
+	var anonymized anonymizedTasks
+	var err error
+	taskChannels.AnonymizeUser <- orcid
+	select {
+	case anonymized = <-taskChannels.ReturnAnonymized:
+	case err = <-taskChannels.Error:
+	}
+	return anonymized.NumAnonymized, anonymized.NumActive, err
+}
+
+// Pauses the task with the given UUID. Transfers in progress are suspended
+// if their endpoints support it, and all other work for the task (staging
+// follow-up, new transfers, manifest generation) is held until the task is
+// resumed.
+func Pause(taskId uuid.UUID) error {
+	taskChannels.PauseTask <- taskId
+	return <-taskChannels.Error
+}
+
+// Resumes the paused task with the given UUID.
+func Resume(taskId uuid.UUID) error {
+	taskChannels.ResumeTask <- taskId
+	return <-taskChannels.Error
+}
+
 // Requests that the task with the given UUID be canceled. Clients should check
 // the status of the task separately.
 func Cancel(taskId uuid.UUID) error {
@@ -279,78 +661,43 @@ var firstCall = true          // indicates first call to Start()
 var running bool              // true if tasks are processing, false if not
 var taskChannels channelsType // channels used for processing tasks
 
-// loads a map of task IDs to tasks from a previously saved file if available,
-// or creates an empty map if no such file is available or valid
-func createOrLoadTasks(dataFile string) map[uuid.UUID]transferTask {
-	file, err := os.Open(dataFile)
-	if err != nil {
-		return make(map[uuid.UUID]transferTask)
-	}
-	slog.Debug(fmt.Sprintf("Found previous tasks in %s.", dataFile))
-	defer file.Close()
-	enc := gob.NewDecoder(file)
-	var tasks map[uuid.UUID]transferTask
-	var databaseStates databases.DatabaseSaveStates
-	if err = enc.Decode(&tasks); err == nil {
-		err = enc.Decode(&databaseStates)
-	}
-	if err != nil { // file not readable
-		slog.Error(fmt.Sprintf("Reading task file %s: %s", dataFile, err.Error()))
-		return make(map[uuid.UUID]transferTask)
-	}
-	if err = databases.Load(databaseStates); err != nil {
-		slog.Error(fmt.Sprintf("Restoring database states: %s", err.Error()))
-	}
-	slog.Debug(fmt.Sprintf("Restored %d tasks from %s", len(tasks), dataFile))
-	return tasks
+// this type holds various channels used by the task manager to communicate
+// with its worker goroutine
+type channelsType struct {
+	CreateTask        chan transferTask        // used by client to request task creation
+	CancelTask        chan uuid.UUID           // used by client to request task cancellation
+	GetTaskStatus     chan uuid.UUID           // used by client to request task status
+	ListTasks         chan []string            // used by client to request a list of tasks with given tags
+	GetTaskSummary    chan uuid.UUID           // used by client to request a task summary
+	AnnotateTask      chan annotationRequest   // used by client to update a task's note and tags
+	PauseTask         chan uuid.UUID           // used by client to request that a task be paused
+	ResumeTask        chan uuid.UUID           // used by client to request that a task be resumed
+	AnonymizeUser     chan string              // used by client to anonymize a user's completed tasks
+	ReturnTaskId      chan uuid.UUID           // returns task ID to client
+	ReturnTaskStatus  chan TransferStatus      // returns task status to client
+	ReturnTaskList    chan []Summary           // returns list of task summaries to client
+	ReturnTaskSummary chan Summary             // returns a task summary to client
+	ReturnAnonymized  chan anonymizedTasks     // returns numbers of anonymized tasks to client
+	Error             chan error               // returns error to client
+	Poll              chan struct{}            // carries heartbeat signal for task updates
+	Sweep             chan struct{}            // carries heartbeat signal for scratch file cleanup
+	Checkpoint        chan struct{}            // carries heartbeat signal for saving state
+	LiveFiles         chan map[string]struct{} // carries files referenced by live tasks to the janitor
+	Callbacks         chan callbackDelivery    // carries callback events to their deliverer
+	Stop              chan struct{}            // used by client to stop task management
 }
 
-// saves a map of task IDs to tasks to the given file
-func saveTasks(tasks map[uuid.UUID]transferTask, dataFile string) error {
-	if len(tasks) > 0 {
-		slog.Debug(fmt.Sprintf("Saving %d tasks to %s", len(tasks), dataFile))
-		file, err := os.OpenFile(dataFile, os.O_RDWR|os.O_CREATE, 0644)
-		if err != nil {
-			return fmt.Errorf("opening task file %s: %s", dataFile, err.Error())
-		}
-		enc := gob.NewEncoder(file)
-		if err = enc.Encode(tasks); err == nil {
-			var databaseStates databases.DatabaseSaveStates
-			if databaseStates, err = databases.Save(); err == nil {
-				err = enc.Encode(databaseStates)
-			}
-		}
-		if err != nil {
-			file.Close()
-			os.Remove(dataFile)
-			return fmt.Errorf("saving tasks: %s", err.Error())
-		}
-		err = file.Close()
-		if err != nil {
-			os.Remove(dataFile)
-			return fmt.Errorf("writing task file %s: %s", dataFile, err.Error())
-		}
-		slog.Debug(fmt.Sprintf("Saved %d tasks to %s", len(tasks), dataFile))
-	} else {
-		_, err := os.Stat(dataFile)
-		if !errors.Is(err, fs.ErrNotExist) { // file exists
-			os.Remove(dataFile)
-		}
-	}
-	return nil
+// this type carries an annotation request to the task manager's goroutine
+type annotationRequest struct {
+	Id         uuid.UUID
+	Orcid      string
+	Annotation Annotation
 }
 
-// this type holds various channels used by the task manager to communicate
-// with its worker goroutine
-type channelsType struct {
-	CreateTask       chan transferTask   // used by client to request task creation
-	CancelTask       chan uuid.UUID      // used by client to request task cancellation
-	GetTaskStatus    chan uuid.UUID      // used by client to request task status
-	ReturnTaskId     chan uuid.UUID      // returns task ID to client
-	ReturnTaskStatus chan TransferStatus // returns task status to client
-	Error            chan error          // returns error to client
-	Poll             chan struct{}       // carries heartbeat signal for task updates
-	Stop             chan struct{}       // used by client to stop task management
+// this type holds the numbers of a user's tasks that were anonymized and that
+// couldn't be anonymized because they're still in progress
+type anonymizedTasks struct {
+	NumAnonymized, NumActive int
 }
 
 // this function runs in its own goroutine, using the given local endpoint
@@ -358,17 +705,29 @@ type channelsType struct {
 // the main thread
 func processTasks() {
 	// create or recreate a persistent table of transfer-related tasks
-	dataStore := filepath.Join(config.Service.DataDirectory, "dts.gob")
-	tasks := createOrLoadTasks(dataStore)
+	tasks := createOrLoadTasks()
 
 	// parse the task channels into directional types as needed
 	var createTaskChan <-chan transferTask = taskChannels.CreateTask
 	var cancelTaskChan <-chan uuid.UUID = taskChannels.CancelTask
 	var getTaskStatusChan <-chan uuid.UUID = taskChannels.GetTaskStatus
+	var listTasksChan <-chan []string = taskChannels.ListTasks
+	var getTaskSummaryChan <-chan uuid.UUID = taskChannels.GetTaskSummary
+	var annotateTaskChan <-chan annotationRequest = taskChannels.AnnotateTask
+	var pauseTaskChan <-chan uuid.UUID = taskChannels.PauseTask
+	var resumeTaskChan <-chan uuid.UUID = taskChannels.ResumeTask
+	var anonymizeUserChan <-chan string = taskChannels.AnonymizeUser
 	var returnTaskIdChan chan<- uuid.UUID = taskChannels.ReturnTaskId
 	var returnTaskStatusChan chan<- TransferStatus = taskChannels.ReturnTaskStatus
+	var returnTaskListChan chan<- []Summary = taskChannels.ReturnTaskList
+	var returnTaskSummaryChan chan<- Summary = taskChannels.ReturnTaskSummary
+	var returnAnonymizedChan chan<- anonymizedTasks = taskChannels.ReturnAnonymized
 	var errorChan chan<- error = taskChannels.Error
 	var pollChan <-chan struct{} = taskChannels.Poll
+	var sweepChan <-chan struct{} = taskChannels.Sweep
+	var checkpointChan <-chan struct{} = taskChannels.Checkpoint
+	var liveFilesChan chan<- map[string]struct{} = taskChannels.LiveFiles
+	var callbackChan chan<- callbackDelivery = taskChannels.Callbacks
 	var stopChan <-chan struct{} = taskChannels.Stop
 
 	// the task deletion period is specified in seconds
@@ -379,9 +738,28 @@ func processTasks() {
 	for running {
 		select {
 		case newTask := <-createTaskChan: // Create() called
+			if err := validateDependencies(newTask, tasks); err != nil {
+				errorChan <- err
+				break
+			}
+			if err := checkQuotas(newTask, tasks); err != nil {
+				errorChan <- err
+				break
+			}
 			newTask.Id = uuid.New()
 			newTask.StartTime = time.Now()
+			if len(newTask.DependsOn) > 0 {
+				newTask.Status.Code = TransferStatusWaiting
+			}
 			tasks[newTask.Id] = newTask
+
+			// save the new task before acknowledging it, so it survives a crash
+			if err := saveTasks(tasks); err != nil {
+				delete(tasks, newTask.Id)
+				slog.Error(err.Error())
+				errorChan <- err
+				break
+			}
 			returnTaskIdChan <- newTask.Id
 			slog.Info(fmt.Sprintf("Created new transfer task %s (%d file(s) requested)",
 				newTask.Id.String(), len(newTask.FileIds)))
@@ -407,69 +785,259 @@ func processTasks() {
 				err := &NotFoundError{Id: taskId}
 				errorChan <- err
 			}
+		case tags := <-listTasksChan: // List() called
+			summaries := make([]Summary, 0)
+			for _, task := range tasks {
+				if task.HasTags(tags) {
+					summaries = append(summaries, task.Summary())
+				}
+			}
+			slices.SortFunc(summaries, func(a, b Summary) int {
+				return tasks[a.Id].StartTime.Compare(tasks[b.Id].StartTime)
+			})
+			returnTaskListChan <- summaries
+		case taskId := <-getTaskSummaryChan: // Summarize() called
+			if task, found := tasks[taskId]; found {
+				returnTaskSummaryChan <- task.Summary()
+			} else {
+				err := &NotFoundError{Id: taskId}
+				errorChan <- err
+			}
+		case request := <-annotateTaskChan: // Annotate() called
+			if task, found := tasks[request.Id]; found {
+				if task.User.Orcid != request.Orcid {
+					errorChan <- &NotOwnerError{Id: request.Id, Orcid: request.Orcid}
+				} else {
+					if request.Annotation.Note != nil {
+						task.Note = *request.Annotation.Note
+					}
+					if request.Annotation.Tags != nil {
+						task.Tags = request.Annotation.Tags
+					}
+					tasks[task.Id] = task
+					slog.Info(fmt.Sprintf("Task %s: updated annotations", task.Id.String()))
+					errorChan <- nil
+				}
+			} else {
+				errorChan <- &NotFoundError{Id: request.Id}
+			}
+		case taskId := <-pauseTaskChan: // Pause() called
+			if task, found := tasks[taskId]; found {
+				err := task.Pause()
+				if err == nil {
+					tasks[taskId] = task
+					slog.Info(fmt.Sprintf("Task %s: paused", taskId.String()))
+				}
+				errorChan <- err
+			} else {
+				errorChan <- &NotFoundError{Id: taskId}
+			}
+		case taskId := <-resumeTaskChan: // Resume() called
+			if task, found := tasks[taskId]; found {
+				err := task.Resume()
+				if err == nil {
+					tasks[taskId] = task
+					slog.Info(fmt.Sprintf("Task %s: resumed", taskId.String()))
+				}
+				errorChan <- err
+			} else {
+				errorChan <- &NotFoundError{Id: taskId}
+			}
+		case orcid := <-anonymizeUserChan: // AnonymizeUser() called
+			var anonymized anonymizedTasks
+			for taskId, task := range tasks {
+				if task.User.Orcid != orcid {
+					continue
+				}
+				if !task.Completed() { // still needs the user's identity
+					anonymized.NumActive++
+					continue
+				}
+				task.anonymize()
+				tasks[taskId] = task
+				anonymized.NumAnonymized++
+			}
+			if anonymized.NumAnonymized > 0 {
+				if err := saveTasks(tasks); err != nil {
+					slog.Error(err.Error())
+					errorChan <- err
+					break
+				}
+			}
+			returnAnonymizedChan <- anonymized
 		case <-pollChan: // time to move things along
+			queued := queuedTasks(tasks)
+			var pending []transferTask // tasks to be updated by stage workers
 			for taskId, task := range tasks {
-				if !task.Completed() {
-					oldStatus := task.Status
-					err := task.Update()
-					if err != nil {
-						// We log task update errors but do not propagate them. All
-						// task errors result in a failed status.
-						task.Status.Code = TransferStatusFailed
-						task.Status.Message = err.Error()
-						task.CompletionTime = time.Now()
+				if task.Overdue() { // give up on it
+					if err := task.Expire(); err != nil {
 						slog.Error(fmt.Sprintf("Task %s: %s", task.Id.String(), err.Error()))
 					}
-					if task.Status.Code != oldStatus.Code {
-						switch task.Status.Code {
-						case TransferStatusStaging:
-							slog.Info(fmt.Sprintf("Task %s: staging %d file(s) (%g GB)",
-								task.Id.String(), len(task.FileIds), task.PayloadSize))
-						case TransferStatusActive:
-							slog.Info(fmt.Sprintf("Task %s: beginning transfer (%d file(s), %g GB)",
-								task.Id.String(), len(task.FileIds), task.PayloadSize))
-						case TransferStatusInactive:
-							slog.Info(fmt.Sprintf("Task %s: suspended transfer", task.Id.String()))
-						case TransferStatusFinalizing:
-							slog.Info(fmt.Sprintf("Task %s: finalizing transfer", task.Id.String()))
-						case TransferStatusSucceeded:
-							slog.Info(fmt.Sprintf("Task %s: completed successfully", task.Id.String()))
-						case TransferStatusFailed:
-							slog.Info(fmt.Sprintf("Task %s: failed", task.Id.String()))
-							err := journal.RecordTransfer(journal.Record{
-								Id:          task.Id,
-								Source:      task.Source,
-								Destination: task.Destination,
-								Orcid:       task.User.Orcid,
-								StartTime:   task.StartTime,
-								StopTime:    time.Now(),
-								Status:      "failed",
-								PayloadSize: int64(1024 * 1024 * 1024 * task.PayloadSize), // GB -> B
-								NumFiles:    len(task.FileIds),
-							})
-							if err != nil {
-								slog.Error(err.Error())
-							}
-						}
+					slog.Warn(fmt.Sprintf("Task %s: expired (deadline %s passed)", task.Id.String(),
+						task.Deadline.Format(time.RFC3339)))
+					if err := journal.RecordTransfer(task.journalRecord("expired")); err != nil {
+						slog.Error(err.Error())
 					}
+					task.queueCallback(callbackChan)
+					task.recordTrace()
+					tasks[taskId] = task
+					continue
 				}
-
-				// if the task completed a long enough time go, delete its entry
-				if task.Age() > deleteAfter {
-					slog.Debug(fmt.Sprintf("Task %s: purging transfer record", task.Id.String()))
-					delete(tasks, taskId)
-				} else { // update its entry
+				if _, isQueued := queued[taskId]; isQueued {
+					continue
+				}
+				waiting, err := task.checkDependencies(tasks)
+				if err != nil {
+					task.Status.Code = TransferStatusFailed
+					task.Status.Message = err.Error()
+					task.CompletionTime = time.Now()
+					slog.Info(fmt.Sprintf("Task %s: failed (%s)", task.Id.String(), err.Error()))
+					if err := journal.RecordTransfer(task.journalRecord("failed")); err != nil {
+						slog.Error(err.Error())
+					}
+					task.queueCallback(callbackChan)
+					task.recordTrace()
+				} else if waiting {
 					tasks[taskId] = task
+					continue
+				}
+				if !task.Completed() {
+					pending = append(pending, task)
+					continue
+				}
+				retainTask(tasks, task, deleteAfter)
+			}
+
+			recordPayloads(tasks) // for checking resolved payloads against quotas
+			for _, task := range updateTasks(pending) {
+				if oldStatus := tasks[task.Id].Status; task.Status.Code != oldStatus.Code {
+					switch task.Status.Code {
+					case TransferStatusStaging:
+						slog.Info(fmt.Sprintf("Task %s: staging %d file(s) (%s)",
+							task.Id.String(), len(task.FileIds), units.FormatGigabytes(task.PayloadSize)))
+					case TransferStatusActive:
+						slog.Info(fmt.Sprintf("Task %s: beginning transfer (%d file(s), %s)",
+							task.Id.String(), len(task.FileIds), units.FormatGigabytes(task.PayloadSize)))
+					case TransferStatusInactive:
+						slog.Info(fmt.Sprintf("Task %s: suspended transfer", task.Id.String()))
+					case TransferStatusFinalizing:
+						slog.Info(fmt.Sprintf("Task %s: finalizing transfer", task.Id.String()))
+					case TransferStatusSucceeded:
+						slog.Info(fmt.Sprintf("Task %s: completed successfully in %s", task.Id.String(),
+							units.FormatDuration(task.CompletionTime.Sub(task.StartTime))))
+						err := journal.RecordTransfer(task.journalRecord("succeeded"))
+						if err != nil {
+							slog.Error(err.Error())
+						}
+					case TransferStatusFailed:
+						slog.Info(fmt.Sprintf("Task %s: failed", task.Id.String()))
+						err := journal.RecordTransfer(task.journalRecord("failed"))
+						if err != nil {
+							slog.Error(err.Error())
+						}
+					}
+					task.queueCallback(callbackChan)
+					if task.Completed() {
+						task.recordTrace()
+					}
 				}
+				retainTask(tasks, task, deleteAfter)
+			}
+		case <-checkpointChan: // time to save our state
+			if err := saveTasks(tasks); err != nil {
+				slog.Error(fmt.Sprintf("Checkpointing tasks: %s", err.Error()))
+			}
+		case <-sweepChan: // time to clean up orphaned scratch files
+			liveFiles := make(map[string]struct{})
+			for _, task := range tasks {
+				if !task.Completed() && task.ManifestFile != "" {
+					liveFiles[task.ManifestFile] = struct{}{}
+					for _, stubFile := range task.StubFiles {
+						liveFiles[stubFile] = struct{}{}
+					}
+					for _, checksumFile := range task.ChecksumFiles {
+						liveFiles[checksumFile] = struct{}{}
+					}
+					if task.JSONLDFile != "" {
+						liveFiles[task.JSONLDFile] = struct{}{}
+					}
+				}
+			}
+			select { // don't wait on a janitor that's still sweeping
+			case liveFilesChan <- liveFiles:
+			default:
 			}
 		case <-stopChan: // Stop() called
-			err := saveTasks(tasks, dataStore) // don't forget to save our state!
+			close(liveFilesChan)
+			close(callbackChan)
+			err := saveTasks(tasks) // don't forget to save our state!
 			errorChan <- err
 			running = false
 		}
 	}
 }
 
+// stores the given task in the task table, or deletes its entry if it
+// completed at least the given time ago
+func retainTask(tasks map[uuid.UUID]transferTask, task transferTask, deleteAfter time.Duration) {
+	if task.Age() > deleteAfter {
+		slog.Debug(fmt.Sprintf("Task %s: purging transfer record", task.Id.String()))
+		delete(tasks, task.Id)
+		faults.Clear(task.Id)
+	} else { // update its entry
+		tasks[task.Id] = task
+	}
+}
+
+// this function determines which new tasks must wait in the queue for bulk
+// transfers, returning their IDs. Tasks that qualify for the fast lane are
+// never queued, and bulk tasks are started in order of creation as long as
+// fewer than config.Service.MaxActiveTransfers bulk tasks are in progress.
+func queuedTasks(tasks map[uuid.UUID]transferTask) map[uuid.UUID]struct{} {
+	queued := make(map[uuid.UUID]struct{})
+	if config.Service.MaxActiveTransfers == 0 { // no limit
+		return queued
+	}
+
+	// count bulk tasks in progress and gather new ones in order of creation
+	numActive := 0
+	newTaskIds := make([]uuid.UUID, 0)
+	for taskId, task := range tasks {
+		if task.Completed() || task.Paused {
+			continue
+		}
+		if len(task.Subtasks) == 0 {
+			if task.Status.Code == TransferStatusWaiting {
+				continue
+			}
+			newTaskIds = append(newTaskIds, taskId)
+		} else if !task.FastLane() {
+			numActive++
+		}
+	}
+	slices.SortFunc(newTaskIds, func(a, b uuid.UUID) int {
+		return tasks[a].StartTime.Compare(tasks[b].StartTime)
+	})
+
+	for _, taskId := range newTaskIds {
+		task := tasks[taskId]
+		err := task.resolve()
+		tasks[taskId] = task // the payload (or error) is cached for later polls
+		if err != nil {
+			continue // the error is reported when the task is started
+		}
+		if task.FastLane() || task.Embargoed() {
+			continue
+		}
+		if numActive < config.Service.MaxActiveTransfers {
+			numActive++
+		} else {
+			queued[taskId] = struct{}{}
+		}
+	}
+	return queued
+}
+
 // this function sends a regular pulse on its poll channel until the global
 // variable running is found to be false
 func heartbeat(pollInterval time.Duration, pollChan chan<- struct{}) {
@@ -25,9 +25,23 @@
 package tasks
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,7 +50,19 @@ import (
 
 	"github.com/kbase/dts/auth"
 	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/credentials"
+	"github.com/kbase/dts/credit"
+	"github.com/kbase/dts/databases"
 	"github.com/kbase/dts/dtstest"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/endpoints/globus"
+	"github.com/kbase/dts/endpoints/local"
+	"github.com/kbase/dts/faults"
+	"github.com/kbase/dts/frictionless"
+	"github.com/kbase/dts/manifests"
+	"github.com/kbase/dts/store"
+	"github.com/kbase/dts/tracing"
+	"github.com/kbase/dts/webhooks"
 )
 
 // runs all tests serially
@@ -45,6 +71,12 @@ func TestRunner(t *testing.T) {
 	tester.TestStartAndStop()
 	tester.TestCreateTask()
 	tester.TestCancelTask()
+	tester.TestListTasksByTag()
+	tester.TestAnnotateTask()
+	tester.TestAnonymizeUser()
+	tester.TestPauseAndResumeTask()
+	tester.TestFastLane()
+	tester.TestStageWorkers()
 	tester.TestStopAndRestart()
 }
 
@@ -105,18 +137,54 @@ func setup() {
 			"hash":     "e91f9e974d0e563cab48d4d43a17e08e",
 			"endpoint": "source-endpoint",
 		},
+		"embargoed-file": {
+			"id":            "embargoed-file",
+			"name":          "embargoed-file.dat",
+			"path":          "dir4/embargoed-file.dat",
+			"format":        "text",
+			"bytes":         1024,
+			"hash":          "f91f9e974d0e563cab48d4d43a17e08f",
+			"endpoint":      "source-endpoint",
+			"embargo_until": "2999-01-01T00:00:00Z",
+		},
+		"private-file": {
+			"id":       "private-file",
+			"name":     "private-file.dat",
+			"path":     "dir6/private-file.dat",
+			"format":   "text",
+			"bytes":    1024,
+			"hash":     "a91f9e974d0e563cab48d4d43a17e08a",
+			"endpoint": "source-endpoint",
+			"private":  true,
+		},
+		"malformed-file": {
+			"id":       "malformed-file",
+			"name":     "malformed-file.dat",
+			"path":     "dir5/malformed-file.dat",
+			"format":   "text",
+			"bytes":    "lots",
+			"endpoint": "source-endpoint",
+		},
 	}
 
 	// register test databases/endpoints referred to in config file
 	dtstest.RegisterTestFixturesFromConfig(endpointOptions, testDescriptors)
+	endpoints.RegisterEndpointProvider("pausable", func(name string) (endpoints.Endpoint, error) {
+		return pausableEndpoint_, nil
+	})
 
-	// Create the data and manifest directories
+	// Create the data and manifest directories and open the manifest archive
 	os.Mkdir(config.Service.DataDirectory, 0755)
 	os.Mkdir(config.Service.ManifestDirectory, 0755)
+	err = manifests.Init()
+	if err != nil {
+		log.Panicf("Couldn't open manifest archive: %s", err)
+	}
 }
 
 // this function gets called after all tests have been run
 func breakdown() {
+	manifests.Finalize()
 	if TESTING_DIR != "" {
 		log.Printf("Deleting testing directory %s...\n", TESTING_DIR)
 		os.RemoveAll(TESTING_DIR)
@@ -161,6 +229,13 @@ func (t *SerialTests) TestCreateTask() {
 	assert.Nil(err)
 	assert.True(taskId != uuid.UUID{})
 
+	// the new task should be saved before its ID is returned
+	state, err := store.Load()
+	assert.Nil(err)
+	assert.True(slices.ContainsFunc(state.Transfers, func(transfer store.Transfer) bool {
+		return transfer.Id == taskId
+	}))
+
 	// the initial status of the task should be Unknown
 	status, err := Status(taskId)
 	assert.Nil(err)
@@ -250,6 +325,308 @@ func (t *SerialTests) TestCancelTask() {
 	assert.Nil(err)
 }
 
+func (t *SerialTests) TestListTasksByTag() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	// queue up a couple of tagged tasks
+	for _, tags := range [][]string{{"campaign"}, {"campaign", "pilot"}} {
+		_, err := Create(Specification{
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1", "file2"},
+			Tags:        tags,
+		})
+		assert.Nil(err)
+	}
+
+	summaries, err := List([]string{"campaign"})
+	assert.Nil(err)
+	assert.Equal(2, len(summaries))
+
+	summaries, err = List([]string{"campaign", "pilot"})
+	assert.Nil(err)
+	assert.Equal(1, len(summaries))
+	assert.Equal([]string{"campaign", "pilot"}, summaries[0].Tags)
+
+	summaries, err = List([]string{"nonexistent"})
+	assert.Nil(err)
+	assert.Equal(0, len(summaries))
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestAnnotateTask() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	orcid := "1234-5678-9012-3456"
+	taskId, err := Create(Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: orcid,
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Tags:        []string{"original"},
+	})
+	assert.Nil(err)
+
+	note := "a note"
+	err = Annotate(taskId, orcid, Annotation{Note: &note})
+	assert.Nil(err)
+	summary, err := Summarize(taskId)
+	assert.Nil(err)
+	assert.Equal(note, summary.Note)
+	assert.Equal([]string{"original"}, summary.Tags)
+
+	err = Annotate(taskId, orcid, Annotation{Tags: []string{"updated"}})
+	assert.Nil(err)
+	summary, err = Summarize(taskId)
+	assert.Nil(err)
+	assert.Equal(note, summary.Note)
+	assert.Equal([]string{"updated"}, summary.Tags)
+
+	// only the task's owner can annotate it
+	err = Annotate(taskId, "9999-9999-9999-9999", Annotation{Note: &note})
+	assert.NotNil(err)
+	_, isNotOwner := err.(*NotOwnerError)
+	assert.True(isNotOwner)
+
+	err = Annotate(uuid.New(), orcid, Annotation{Note: &note})
+	assert.NotNil(err)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestAnonymizeUser() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	orcid := "4567-8901-2345-6789"
+	taskId, err := Create(Specification{
+		User: auth.User{
+			Name:  "Jane-bob",
+			Orcid: orcid,
+			Email: "jane-bob@example.com",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+
+	// tasks in progress aren't anonymized...
+	numAnonymized, numActive, err := AnonymizeUser(orcid)
+	assert.Nil(err)
+	assert.Equal(0, numAnonymized)
+	assert.Equal(1, numActive)
+
+	// ...but completed ones are
+	err = Cancel(taskId)
+	assert.Nil(err)
+	assert.Eventually(func() bool {
+		numAnonymized, numActive, err = AnonymizeUser(orcid)
+		return err == nil && numAnonymized == 1 && numActive == 0
+	}, 10*time.Second, 10*time.Millisecond)
+	summary, err := Summarize(taskId)
+	assert.Nil(err)
+	assert.Equal(auth.AnonymousOrcid, summary.Orcid)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestPauseAndResumeTask() {
+	assert := assert.New(t.Test)
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+
+	taskId, err := Create(Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+
+	// pause the task and make sure it doesn't progress
+	err = Pause(taskId)
+	assert.Nil(err)
+	err = Pause(taskId)
+	assert.NotNil(err)
+	time.Sleep(pause + endpointOptions.StagingDuration + endpointOptions.TransferDuration)
+	status, err := Status(taskId)
+	assert.Nil(err)
+	assert.Equal(TransferStatusInactive, status.Code)
+
+	// resume the task and make sure it gets going again
+	err = Resume(taskId)
+	assert.Nil(err)
+	err = Resume(taskId)
+	assert.NotNil(err)
+	time.Sleep(pause + pollInterval)
+	status, err = Status(taskId)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusInactive, status.Code)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestFastLane() {
+	assert := assert.New(t.Test)
+
+	// allow one bulk transfer at a time, with single-file transfers in the fast lane
+	config.Service.MaxActiveTransfers = 1
+	config.Service.FastLaneMaxFiles = 1
+	defer func() {
+		config.Service.MaxActiveTransfers = 0
+		config.Service.FastLaneMaxFiles = 0
+	}()
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+
+	// wait for tasks from previous tests to finish
+	for {
+		summaries, err := List(nil)
+		assert.Nil(err)
+		finished := true
+		for _, summary := range summaries {
+			if summary.Status.Code != TransferStatusSucceeded &&
+				summary.Status.Code != TransferStatusFailed {
+				finished = false
+			}
+		}
+		if finished {
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+
+	createTask := func(fileIds []string) uuid.UUID {
+		taskId, err := Create(Specification{
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     fileIds,
+		})
+		assert.Nil(err)
+		return taskId
+	}
+	bulkTask1 := createTask([]string{"file1", "file2"})
+	time.Sleep(pause)
+	bulkTask2 := createTask([]string{"file1", "file2"})
+	fastTask := createTask([]string{"file3"})
+
+	// the first bulk task and the fast-lane task should get going, while the
+	// second bulk task waits in the queue
+	time.Sleep(pause + pollInterval)
+	status, err := Status(bulkTask1)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusUnknown, status.Code)
+	status, err = Status(bulkTask2)
+	assert.Nil(err)
+	assert.Equal(TransferStatusUnknown, status.Code)
+	status, err = Status(fastTask)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusUnknown, status.Code)
+
+	// once the first bulk task completes, the second one starts
+	for {
+		status, err = Status(bulkTask1)
+		assert.Nil(err)
+		if status.Code == TransferStatusSucceeded || status.Code == TransferStatusFailed {
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+	time.Sleep(pause + pollInterval)
+	status, err = Status(bulkTask2)
+	assert.Nil(err)
+	assert.NotEqual(TransferStatusUnknown, status.Code)
+
+	err = Stop()
+	assert.Nil(err)
+}
+
+func (t *SerialTests) TestStageWorkers() {
+	assert := assert.New(t.Test)
+
+	// update several tasks at once in each stage
+	workers := config.Service.Workers
+	config.Service.Workers.Create = 4
+	config.Service.Workers.Staging = 2
+	config.Service.Workers.Transfer = 4
+	config.Service.Workers.Finalize = 2
+	defer func() {
+		config.Service.Workers = workers
+	}()
+
+	err := Start()
+	assert.Nil(err)
+
+	pollInterval := time.Duration(config.Service.PollInterval) * time.Millisecond
+
+	taskIds := make([]uuid.UUID, 6)
+	for i := range taskIds {
+		taskIds[i], err = Create(Specification{
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1", "file2"},
+		})
+		assert.Nil(err)
+	}
+
+	// every task should proceed through its stages to completion
+	deadline := time.Now().Add(pause + 4*pollInterval +
+		endpointOptions.StagingDuration + 2*endpointOptions.TransferDuration)
+	for _, taskId := range taskIds {
+		summary, err := Summarize(taskId)
+		assert.Nil(err)
+		for summary.Status.Code != TransferStatusSucceeded && time.Now().Before(deadline) {
+			assert.NotEqual(TransferStatusFailed, summary.Status.Code)
+			time.Sleep(pollInterval)
+			summary, err = Summarize(taskId)
+			assert.Nil(err)
+		}
+		assert.Equal(TransferStatusSucceeded, summary.Status.Code)
+		assert.Equal(len(timelineStages), len(summary.Timeline))
+	}
+
+	err = Stop()
+	assert.Nil(err)
+}
+
 func (t *SerialTests) TestStopAndRestart() {
 	assert := assert.New(t.Test)
 
@@ -286,40 +663,2211 @@ func (t *SerialTests) TestStopAndRestart() {
 	assert.Nil(err)
 }
 
-// temporary testing directory
-var TESTING_DIR string
+func (t *SerialTests) TestListBatch() {
+	assert := assert.New(t.Test)
 
-// endpoint testing options
-var endpointOptions = dtstest.EndpointOptions{
-	StagingDuration:  time.Duration(150) * time.Millisecond,
-	TransferDuration: time.Duration(500) * time.Millisecond,
+	err := Start()
+	assert.Nil(err)
+
+	// create a batch of tasks alongside a task outside the batch
+	batch := uuid.New()
+	batchTaskIds := make(map[uuid.UUID]bool)
+	for i := 0; i < 3; i++ {
+		taskId, err := Create(Specification{
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1", "file2"},
+			Batch:       batch,
+		})
+		assert.Nil(err)
+		batchTaskIds[taskId] = true
+	}
+	otherTaskId, err := Create(Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.Nil(err)
+
+	summaries, err := ListBatch(batch)
+	assert.Nil(err)
+	assert.Equal(len(batchTaskIds), len(summaries))
+	for _, summary := range summaries {
+		assert.True(batchTaskIds[summary.Id])
+		assert.NotEqual(otherTaskId, summary.Id)
+		assert.Equal(batch, summary.Batch)
+	}
+
+	summaries, err = ListBatch(uuid.New())
+	assert.Nil(err)
+	assert.Empty(summaries)
+
+	err = Stop()
+	assert.Nil(err)
 }
 
-// a pause to give the task manager a bit of time
-var pause time.Duration = time.Duration(25) * time.Millisecond
+// tests the janitor's removal of orphaned scratch files
+func TestSweepScratchFiles(t *testing.T) {
+	assert := assert.New(t)
 
-// configuration
-const tasksConfig string = `
-service:
-  port: 8080
-  max_connections: 100
-  poll_interval: 50  # milliseconds
-  data_dir: TESTING_DIR/data
-  manifest_dir: TESTING_DIR/manifests
-  delete_after: 2    # seconds
-  endpoint: local-endpoint
-databases:
-  test-source:
-    name: Source Test Database
-    organization: The Source Company
-    endpoint: source-endpoint
-  test-destination:
-    name: Destination Test Database
-    organization: Fabulous Destinations, Inc.
-    endpoint: destination-endpoint
-endpoints:
-  local-endpoint:
-    name: Local endpoint
+	dir := t.TempDir()
+	oldTime := time.Now().Add(-2 * time.Hour)
+	orphaned := filepath.Join(dir, "manifest-orphaned.json")
+	live := filepath.Join(dir, "manifest-live.json")
+	recent := filepath.Join(dir, "manifest-recent.json")
+	other := filepath.Join(dir, "other.json")
+	for _, file := range []string{orphaned, live, recent, other} {
+		err := os.WriteFile(file, []byte("{}"), 0644)
+		assert.Nil(err)
+		if file != recent {
+			err = os.Chtimes(file, oldTime, oldTime)
+			assert.Nil(err)
+		}
+	}
+
+	// scratch directories left behind by endpoints in the data directory
+	dataDir := t.TempDir()
+	staged := filepath.Join(dataDir, "dts-https-orphaned")
+	inUse := filepath.Join(dataDir, "dts-https-in-use")
+	for _, scratchDir := range []string{staged, inUse} {
+		err := os.Mkdir(scratchDir, 0755)
+		assert.Nil(err)
+		file := filepath.Join(scratchDir, "file.dat")
+		err = os.WriteFile(file, []byte("data"), 0644)
+		assert.Nil(err)
+		err = os.Chtimes(scratchDir, oldTime, oldTime)
+		assert.Nil(err)
+		if scratchDir == staged {
+			err = os.Chtimes(file, oldTime, oldTime)
+			assert.Nil(err)
+		}
+	}
+
+	before := Janitor()
+	sweepScratchFiles([]scratchDirectory{
+		{Dir: dir, Patterns: manifestScratchPatterns},
+		{Dir: dataDir, Patterns: dataScratchPatterns},
+	}, map[string]struct{}{live: {}}, time.Hour)
+	after := Janitor()
+
+	// only the old, unreferenced manifest and scratch directory are removed
+	assert.NoFileExists(orphaned)
+	assert.FileExists(live)
+	assert.FileExists(recent)
+	assert.FileExists(other)
+	assert.NoDirExists(staged)
+	assert.DirExists(inUse) // its file is still being written
+	assert.Equal(before.NumSweeps+1, after.NumSweeps)
+	assert.Equal(before.NumFilesRemoved+2, after.NumFilesRemoved)
+	assert.Equal(before.BytesReclaimed+6, after.BytesReclaimed)
+}
+
+// tests that new tasks are refused when disk space runs low
+func TestCreateWithInsufficientDiskSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	minFreeDiskSpace := config.Service.MinFreeDiskSpace
+	config.Service.MinFreeDiskSpace = 1e12 // a petabyte, more or less
+	defer func() {
+		config.Service.MinFreeDiskSpace = minFreeDiskSpace
+	}()
+
+	_, err := Create(Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.NotNil(err)
+	assert.IsType(&InsufficientDiskSpaceError{}, err)
+}
+
+// tests that transfers are refused by destinations requiring encryption that
+// their sources can't provide
+func TestCreateWithUnmetEncryptionRequirement(t *testing.T) {
+	assert := assert.New(t)
+
+	endpointConfig := config.Endpoints["destination-endpoint"]
+	endpointConfig.EncryptData = true
+	config.Endpoints["destination-endpoint"] = endpointConfig
+	defer func() {
+		endpointConfig.EncryptData = false
+		config.Endpoints["destination-endpoint"] = endpointConfig
+	}()
+
+	_, err := Create(Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	})
+	assert.NotNil(err)
+	assert.IsType(&EncryptionRequiredError{}, err)
+}
+
+// tests that transfers are refused if they violate egress policies
+func TestCreateWithEgressPolicyViolation(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "private-file"},
+	}
+	_, err := Create(spec)
+	assert.NotNil(err)
+	if assert.IsType(&EgressPolicyViolationError{}, err) {
+		violation := err.(*EgressPolicyViolationError)
+		assert.Equal("private-data", violation.Policy)
+		assert.Equal([]string{"private-file"}, violation.FileIds)
+	}
+
+	// files not governed by the policy may be transferred
+	source, err := databases.NewDatabase("test-source")
+	assert.Nil(err)
+	err = checkEgressPolicies(spec, func() ([]map[string]any, error) {
+		return source.Descriptors(spec.User.Orcid, []string{"file1", "file2"})
+	})
+	assert.Nil(err)
+}
+
+// tests the rejection of transfers whose deadlines have already passed, and
+// the expiration of overdue tasks
+func TestTransferDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := Specification{
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Deadline:    time.Now().Add(-time.Minute),
+	}
+	_, err := Create(spec)
+	assert.NotNil(err)
+	assert.IsType(&InvalidDeadlineError{}, err)
+
+	task := transferTask{
+		Id:       uuid.New(),
+		Source:   "test-source",
+		FileIds:  []string{"file1", "file2"},
+		Deadline: time.Now().Add(time.Hour),
+	}
+	assert.False(task.Overdue())
+	task.Deadline = time.Now().Add(-time.Second)
+	assert.True(task.Overdue())
+	err = task.Expire()
+	assert.Nil(err)
+	assert.True(task.Expired)
+	assert.True(task.Canceled)
+	assert.Equal(TransferStatusFailed, task.Status.Code)
+	assert.True(strings.HasPrefix(task.Status.Message, "expired"))
+	assert.False(task.Overdue())
+}
+
+// tests the sending, replacement, and retraction of manifests sent along with
+// their payloads
+func TestEarlyManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dts-early",
+		FileIds:           []string{"file1", "file2"},
+		Subtasks: []transferSubtask{
+			{
+				Source:         "test-source",
+				Destination:    "test-destination",
+				SourceEndpoint: "source-endpoint",
+				Descriptors: []any{
+					map[string]any{"id": "file1", "name": "file1.dat", "path": "dir1/file1.dat",
+						"hash": "d91f97974d06563cab48d4d43a17e08a"},
+					map[string]any{"id": "file2", "name": "file2.dat", "path": "dir2/file2.dat",
+						"hash": "d91f9e974d0e563cab48d4d43a17e08a"},
+				},
+			},
+		},
+	}
+	err := task.sendEarlyManifest()
+	assert.Nil(err)
+	assert.True(task.EarlyManifest.Valid)
+	assert.NotEmpty(task.EarlyManifestFingerprint)
+	assert.FileExists(task.ManifestFile)
+	assert.Empty(task.FinalManifestFile) // (destination endpoint can't move files)
+	assert.Eventually(func() bool {
+		arrived, err := task.earlyManifestArrived()
+		return err == nil && arrived
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.True(task.EarlyManifest.Valid)
+
+	// the early manifest is flagged as provisional
+	var delivered map[string]any
+	content, err := os.ReadFile(task.ManifestFile)
+	assert.Nil(err)
+	assert.Nil(json.Unmarshal(content, &delivered))
+	assert.Equal(true, delivered["pending"])
+
+	// an unchanged manifest is put in place from the final copy delivered with
+	// the early one, if the destination endpoint can move files (here it can't,
+	// so it must be sent again)...
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal(task.EarlyManifestFingerprint, manifestFingerprint(manifest))
+	assert.NotContains(manifest.Descriptor(), "pending")
+	assert.False(task.finalizeEarlyManifest())
+
+	// ...but a changed one does
+	task.Subtasks[0].CorruptFiles = map[string]string{"file2": "dts-early/quarantine/dir2/file2.dat"}
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	assert.NotEqual(task.EarlyManifestFingerprint, manifestFingerprint(manifest))
+
+	// canceling the task retracts the early manifest
+	manifestFile := task.ManifestFile
+	err = task.Cancel()
+	assert.Nil(err)
+	assert.False(task.EarlyManifest.Valid)
+	assert.Equal("", task.ManifestFile)
+	assert.NoFileExists(manifestFile)
+}
+
+// a source endpoint that serves files directly
+type downloadingEndpoint struct {
+	Endpoint
+}
+
+func (ep downloadingEndpoint) CanDownload() bool {
+	return true
+}
+
+func (ep downloadingEndpoint) Download(path string, w io.Writer, maxBytes int64) error {
+	return nil
+}
+
+// tests the selection of direct downloads for small payloads, and the
+// recording of the providers that move a task's files
+func TestDirectDownload(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Descriptors: []any{
+			map[string]any{"id": "file1", "path": "dir1/file1.dat", "bytes": 1024},
+		},
+	}
+	fileXfers := []FileTransfer{
+		{SourcePath: "dir1/file1.dat", DestinationPath: "dts-direct/dir1/file1.dat"},
+	}
+	source, destination := downloadingEndpoint{}, &local.Endpoint{}
+
+	// direct downloads are disabled by default
+	_, _, ok := subtask.directDownload(source, destination, fileXfers)
+	assert.False(ok)
+
+	maxSize := config.Service.SmallPayloadMaxSize
+	defer func() { config.Service.SmallPayloadMaxSize = maxSize }()
+	config.Service.SmallPayloadMaxSize = 2048.0 / (1024 * 1024 * 1024)
+	_, fetcher, ok := subtask.directDownload(source, destination, fileXfers)
+	assert.True(ok)
+	assert.Equal(destination, fetcher)
+
+	// ...but not for payloads that are too large, directories, relays, or
+	// destinations that can't fetch files
+	subtask.Descriptors[0].(map[string]any)["bytes"] = 4096
+	_, _, ok = subtask.directDownload(source, destination, fileXfers)
+	assert.False(ok)
+	subtask.Descriptors[0].(map[string]any)["bytes"] = 1024
+	_, _, ok = subtask.directDownload(source, destination,
+		[]FileTransfer{{SourcePath: "dir1", DestinationPath: "dts-direct/dir1", Recursive: true}})
+	assert.False(ok)
+	subtask.RelayEndpoint = "relay-endpoint"
+	_, _, ok = subtask.directDownload(source, destination, fileXfers)
+	assert.False(ok)
+	subtask.RelayEndpoint = ""
+	_, _, ok = subtask.directDownload(source, checksummingEndpoint{}, fileXfers)
+	assert.False(ok)
+
+	task := transferTask{
+		Id: uuid.New(),
+		Subtasks: []transferSubtask{
+			{Mover: "globus"}, {Mover: "https"}, {Mover: "globus"}, {},
+		},
+	}
+	assert.Equal([]string{"globus", "https"}, task.journalRecord("succeeded").Movers)
+	assert.Equal("https", task.stages()[1].Mover)
+}
+
+// tests the estimation of time remaining from transfer progress and history
+func TestEstimateCompletion(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Source: "eta-source",
+		Descriptors: []any{
+			map[string]any{"id": "file1", "path": "dir/file1", "bytes": 1000},
+			map[string]any{"id": "file2", "path": "dir/file2", "bytes": 3000},
+		},
+		Staging:          uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		StagingStartTime: time.Now(),
+	}
+	assert.Equal(int64(4000), subtask.payloadBytes())
+
+	// without history, staging can't be estimated
+	_, ok := subtask.timeRemaining()
+	assert.False(ok)
+
+	// with history, staging and transfer can be estimated
+	recordStagingDuration("eta-source", 10*time.Minute)
+	recordTransferRate("eta-source", 4000, 2*time.Second)
+	remaining, ok := subtask.timeRemaining()
+	assert.True(ok)
+	assert.True(remaining > 9*time.Minute && remaining <= 10*time.Minute+2*time.Second)
+
+	// during a transfer, the observed rate is used
+	subtask.Staging = uuid.NullUUID{}
+	subtask.Transfer = uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	subtask.TransferStartTime = time.Now().Add(-10 * time.Second)
+	subtask.TransferStatus = TransferStatus{
+		Code:                TransferStatusActive,
+		NumBytesTransferred: 1000,
+	}
+	remaining, ok = subtask.timeRemaining()
+	assert.True(ok)
+	assert.InDelta(30, remaining.Seconds(), 1)
+
+	task := transferTask{Subtasks: []transferSubtask{subtask}}
+	eta := task.estimateCompletion()
+	assert.InDelta(30, time.Until(eta).Seconds(), 1)
+
+	// finished subtasks take no more time
+	subtask.Transfer = uuid.NullUUID{}
+	subtask.TransferStatus.Code = TransferStatusSucceeded
+	remaining, ok = subtask.timeRemaining()
+	assert.True(ok)
+	assert.Equal(time.Duration(0), remaining)
+}
+
+// tests the estimation of transfer sizes and rates not reported by endpoints
+func TestTransferProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Descriptors: []any{
+			map[string]any{"id": "file1", "path": "dir/file1", "bytes": 1000},
+			map[string]any{"id": "file2", "path": "dir/file2", "bytes": 3000},
+		},
+		Transfer:          uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		TransferStartTime: time.Now().Add(-10 * time.Second),
+		TransferStatus: TransferStatus{
+			Code:                TransferStatusActive,
+			NumBytesTransferred: 1000,
+		},
+	}
+
+	// sizes and rates not reported by the endpoint are filled in
+	subtask.fillTransferProgress()
+	assert.Equal(int64(4000), subtask.TransferStatus.NumBytes)
+	assert.InDelta(100, subtask.TransferStatus.BytesPerSecond, 5)
+
+	// reported rates are left alone
+	subtask.TransferStatus.BytesPerSecond = 250
+	subtask.fillTransferProgress()
+	assert.Equal(250.0, subtask.TransferStatus.BytesPerSecond)
+
+	// inactive transfers aren't assigned a rate
+	subtask.TransferStatus.Code = TransferStatusInactive
+	subtask.TransferStatus.BytesPerSecond = 0
+	subtask.fillTransferProgress()
+	assert.Equal(0.0, subtask.TransferStatus.BytesPerSecond)
+}
+
+// a source endpoint that reports a fixed set of files skipped because of errors
+type skippingEndpoint struct {
+	Endpoint
+	Skipped []endpoints.SkippedFile
+}
+
+func (ep skippingEndpoint) TransferSkippingErrors(dst Endpoint, files []FileTransfer) (uuid.UUID, error) {
+	return ep.Transfer(dst, files)
+}
+
+func (ep skippingEndpoint) SkippedFiles(id uuid.UUID) ([]endpoints.SkippedFile, error) {
+	return ep.Skipped, nil
+}
+
+// tests the matching of files skipped by an endpoint to a subtask's files, and
+// their reporting
+func TestSkippedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := skippingEndpoint{
+		Skipped: []endpoints.SkippedFile{
+			{SourcePath: "dir2/file2.dat", Error: "FILE_NOT_FOUND: no such file"},
+			{SourcePath: "dir9/unrequested.dat", Error: "PERMISSION_DENIED: nope"},
+		},
+	}
+	task := transferTask{
+		Id:      uuid.New(),
+		FileIds: []string{"file1", "file2"},
+		Subtasks: []transferSubtask{
+			{
+				Source: "test-source",
+				Descriptors: []any{
+					map[string]any{"id": "file1", "path": "dir1/file1.dat"},
+					map[string]any{"id": "file2", "path": "dir2/file2.dat"},
+				},
+			},
+		},
+	}
+
+	// nothing is recorded unless skipping was requested
+	subtask := &task.Subtasks[0]
+	err := subtask.checkSkippedFiles(endpoint, uuid.New())
+	assert.Nil(err)
+	assert.Empty(subtask.SkippedFiles)
+
+	subtask.SkipSourceErrors = true
+	err = subtask.checkSkippedFiles(endpoint, uuid.New())
+	assert.Nil(err)
+	assert.Equal(map[string]string{"file2": "FILE_NOT_FOUND: no such file"}, subtask.SkippedFiles)
+
+	stages := task.stages()
+	assert.Equal("", stages[0].Files[0].SkipReason)
+	assert.Equal("FILE_NOT_FOUND: no such file", stages[0].Files[1].SkipReason)
+	assert.Contains(task.warnings(), "1 file(s) skipped because of source errors and not delivered")
+	assert.Equal([]any{map[string]any{"id": "file2", "error": "FILE_NOT_FOUND: no such file"}},
+		task.skippedFiles())
+}
+
+// a destination endpoint that computes fixed checksums and records moved files
+type checksummingEndpoint struct {
+	Endpoint
+	Checksums map[string]string
+	Moved     map[string]string
+}
+
+func (ep checksummingEndpoint) Checksum(path, algorithm string) (string, error) {
+	if checksum, found := ep.Checksums[path]; found {
+		return checksum, nil
+	}
+	return "", fmt.Errorf("no such file: %s", path)
+}
+
+func (ep checksummingEndpoint) Move(source, destination string) error {
+	ep.Moved[source] = destination
+	return nil
+}
+
+// tests the quarantining of delivered files with mismatched checksums, and
+// their reporting
+func TestQuarantineCorruptFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := checksummingEndpoint{
+		Checksums: map[string]string{
+			"dts-xfer/dir1/file1.dat": "d91f97974d06563cab48d4d43a17e08a", // matches
+			"dts-xfer/dir2/file2.dat": "00000000000000000000000000000000", // doesn't
+		},
+		Moved: make(map[string]string),
+	}
+	task := transferTask{
+		Id:      uuid.New(),
+		FileIds: []string{"file1", "file2", "file3"},
+		Subtasks: []transferSubtask{
+			{
+				Source:            "test-source",
+				DestinationFolder: "dts-xfer",
+				Descriptors: []any{
+					map[string]any{"id": "file1", "path": "dir1/file1.dat", "hash": "d91f97974d06563cab48d4d43a17e08a"},
+					map[string]any{"id": "file2", "path": "dir2/file2.dat", "hash": "d91f9e974d0e563cab48d4d43a17e08a"},
+					map[string]any{"id": "file3", "path": "dir3/file3.dat", "hash": "e91f9e974d0e563cab48d4d43a17e08e"},
+				},
+			},
+		},
+	}
+
+	// nothing is verified if checksums are skipped
+	subtask := &task.Subtasks[0]
+	subtask.SkipChecksums = true
+	corruptFiles, err := subtask.quarantineCorruptFiles(endpoint)
+	assert.Nil(err)
+	assert.Empty(corruptFiles)
+
+	// only the mismatched file is quarantined (file3 can't be checksummed)
+	subtask.SkipChecksums = false
+	corruptFiles, err = subtask.quarantineCorruptFiles(endpoint)
+	assert.Nil(err)
+	quarantinePath := "dts-xfer/quarantine/dir2/file2.dat"
+	assert.Equal(map[string]string{"file2": quarantinePath}, corruptFiles)
+	assert.Equal(map[string]string{"dts-xfer/dir2/file2.dat": quarantinePath}, endpoint.Moved)
+
+	subtask.CorruptFiles = corruptFiles
+	stages := task.stages()
+	assert.Equal("", stages[0].Files[0].QuarantinePath)
+	assert.Equal(quarantinePath, stages[0].Files[1].QuarantinePath)
+	assert.Equal([]any{map[string]any{"id": "file2", "quarantine_path": quarantinePath}},
+		task.corruptFiles())
+
+	// delivered files are verified in the background, and only once
+	subtask.CorruptFiles = nil
+	subtask.Destination = "test-destination"
+	assert.Eventually(task.verifyDeliveries, 10*time.Second, 10*time.Millisecond)
+	assert.True(task.DeliveriesVerified)
+	assert.True(task.verifyDeliveries())
+}
+
+// tests the background computation of checksums missing from descriptors
+func TestComputeMissingChecksums(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptor := map[string]any{"id": "file1", "path": "dir1/file1.dat"}
+	task := transferTask{
+		Id: uuid.New(),
+		Subtasks: []transferSubtask{
+			{
+				Source:         "test-source",
+				SourceEndpoint: "source-endpoint",
+				Destination:    "test-destination",
+				Descriptors:    []any{descriptor},
+			},
+		},
+	}
+
+	// nothing is computed unless the service is configured to do so
+	assert.True(task.computeMissingChecksums())
+	assert.False(task.ChecksumsComputed)
+
+	// checksums are computed in the background (here, neither endpoint can
+	// compute them)
+	config.Service.ComputeMissingChecksums = true
+	defer func() { config.Service.ComputeMissingChecksums = false }()
+	assert.Eventually(task.computeMissingChecksums, 10*time.Second, 10*time.Millisecond)
+	assert.True(task.ChecksumsComputed)
+	assert.NotContains(descriptor, "hash")
+}
+
+// tests the generation of stubs describing files in a metadata-only transfer
+func TestMetadataOnlyStubs(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id:                uuid.New(),
+		DestinationFolder: "dts-stubs",
+		MetadataOnly:      true,
+		Subtasks: []transferSubtask{
+			{
+				Source:         "test-source",
+				SourceEndpoint: "source-endpoint",
+				MetadataOnly:   true,
+				Descriptors: []any{
+					map[string]any{
+						"id":     "file1",
+						"name":   "file1.dat",
+						"path":   "dir1/file1.dat",
+						"bytes":  1024,
+						"hash":   "d91f97974d06563cab48d4d43a17e08a",
+						"credit": map[string]any{"url": "https://example.org/file1.dat"},
+					},
+				},
+			},
+		},
+	}
+	task.Subtasks[0].TaskId = task.Id
+
+	// nothing is staged or transferred
+	subtask := &task.Subtasks[0]
+	err := subtask.start()
+	assert.Nil(err)
+	assert.Equal(TransferStatusSucceeded, subtask.TransferStatus.Code)
+	assert.Equal(1, subtask.TransferStatus.NumFiles)
+	assert.False(subtask.Staging.Valid)
+	assert.False(subtask.Transfer.Valid)
+
+	// a stub is written for each file and delivered next to its path
+	fileXfers, err := task.writeStubs()
+	assert.Nil(err)
+	assert.Len(fileXfers, 1)
+	assert.Len(task.StubFiles, 1)
+	assert.Equal(task.StubFiles[0], fileXfers[0].SourcePath)
+	assert.Equal("dts-stubs/dir1/file1.dat"+stubSuffix, fileXfers[0].DestinationPath)
+
+	data, err := os.ReadFile(task.StubFiles[0])
+	assert.Nil(err)
+	var stub map[string]any
+	err = json.Unmarshal(data, &stub)
+	assert.Nil(err)
+	assert.Equal("file1", stub["id"])
+	assert.Equal("dir1/file1.dat", stub["path"])
+	assert.Equal("source-endpoint", stub["endpoint"])
+	assert.Equal("26d61236-39f6-4742-a374-8ec709347f2f", stub["endpoint_id"])
+	assert.Equal("d91f97974d06563cab48d4d43a17e08a", stub["hash"])
+	assert.Equal(1024.0, stub["bytes"])
+	assert.Equal("https://example.org/file1.dat", stub["url"])
+	assert.Equal(task.Id.String(), stub["transfer_id"])
+
+	// stubs are removed once delivered
+	stubFile := task.StubFiles[0]
+	task.removeStubs()
+	assert.Empty(task.StubFiles)
+	_, err = os.Stat(stubFile)
+	assert.True(os.IsNotExist(err))
+}
+
+// tests the reporting of credential expirations
+func TestCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	statuses := credentialStatuses(map[string]time.Time{
+		"expired":  time.Now().Add(-time.Hour),
+		"expiring": time.Now().Add(24 * time.Hour),
+		"fine":     time.Now().Add(365 * 24 * time.Hour),
+		"timeless": {},
+	}, 7*24*time.Hour)
+	assert.Len(statuses, 4)
+	assert.Equal("expired", statuses[0].Name)
+	assert.True(statuses[0].Expired)
+	assert.False(statuses[0].Expiring)
+	assert.Equal("expiring", statuses[1].Name)
+	assert.False(statuses[1].Expired)
+	assert.True(statuses[1].Expiring)
+	assert.False(statuses[2].Expired || statuses[2].Expiring)
+	assert.True(statuses[3].Expires.IsZero())
+	assert.False(statuses[3].Expired || statuses[3].Expiring)
+	assert.Equal(2, checkCredentials(statuses))
+}
+
+// tests that tasks survive a round trip through the task store, and that a
+// task that can't be decoded doesn't take the others down with it
+func TestTaskStore(t *testing.T) {
+	assert := assert.New(t)
+
+	err := store.Init()
+	assert.Nil(err)
+	defer store.Finalize()
+
+	taskId := uuid.New()
+	tasks := map[uuid.UUID]transferTask{
+		taskId: {
+			Id:      taskId,
+			Source:  "test-source",
+			FileIds: []string{"file1", "file2"},
+			Subtasks: []transferSubtask{
+				{TaskId: taskId, Descriptors: []any{map[string]any{"id": "file1", "bytes": 1024}}},
+				{TaskId: taskId, Descriptors: []any{map[string]any{"id": "file2", "bytes": 2048}}},
+			},
+		},
+	}
+	err = saveTasks(tasks)
+	assert.Nil(err)
+	loadedTasks := createOrLoadTasks()
+	assert.Equal(tasks[taskId].FileIds, loadedTasks[taskId].FileIds)
+	assert.Len(loadedTasks[taskId].Subtasks, 2)
+	descriptor := loadedTasks[taskId].Subtasks[1].Descriptors[0].(map[string]any)
+	numBytes, _ := frictionless.Int(descriptor, "bytes")
+	assert.Equal(2048, numBytes)
+
+	// a corrupted record costs only its own task
+	state, err := store.Load()
+	assert.Nil(err)
+	badId := uuid.New()
+	state.Transfers = append(state.Transfers, store.Transfer{
+		Id:   badId,
+		Data: json.RawMessage(`{"Id": 42}`),
+	})
+	err = store.Save(state)
+	assert.Nil(err)
+	loadedTasks = createOrLoadTasks()
+	assert.Contains(loadedTasks, taskId)
+	assert.NotContains(loadedTasks, badId)
+
+	// a store that can't be read is never overwritten
+	store.Finalize()
+	loadedTasks = createOrLoadTasks()
+	assert.Empty(loadedTasks)
+	err = store.Init()
+	assert.Nil(err)
+	err = saveTasks(map[uuid.UUID]transferTask{})
+	assert.NotNil(err)
+	loadedTasks = createOrLoadTasks()
+	assert.Contains(loadedTasks, taskId)
+
+	err = saveTasks(map[uuid.UUID]transferTask{})
+	assert.Nil(err)
+}
+
+// tests the import of tasks from save files written before the task store
+// existed, and the integrity checks and versioning of those files
+func TestLegacySaveFile(t *testing.T) {
+	assert := assert.New(t)
+
+	err := store.Init()
+	assert.Nil(err)
+	defer store.Finalize()
+
+	taskId := uuid.New()
+	tasks := map[uuid.UUID]transferTask{
+		taskId: {
+			Id:      taskId,
+			Source:  "test-source",
+			FileIds: []string{"file1", "file2"},
+		},
+	}
+	data, err := encodeLegacySaveFile(tasks)
+	assert.Nil(err)
+
+	loadedTasks, _, err := decodeSaveFile(data)
+	assert.Nil(err)
+	assert.Equal(tasks[taskId].FileIds, loadedTasks[taskId].FileIds)
+
+	// corrupted contents are detected
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, _, err = decodeSaveFile(corrupted)
+	assert.NotNil(err)
+
+	// unknown schema versions are rejected
+	future := bytes.Clone(data)
+	binary.BigEndian.PutUint32(future[len(saveFileMagic):], saveFileVersion+1)
+	_, _, err = decodeSaveFile(future)
+	assert.NotNil(err)
+
+	// headerless files from before versioning are converted to the current
+	// layout
+	var contents bytes.Buffer
+	enc := gob.NewEncoder(&contents)
+	err = enc.Encode(map[uuid.UUID]transferTaskV0{
+		taskId: {
+			Id:        taskId,
+			Source:    "test-source",
+			FileIds:   []string{"file1", "file2"},
+			StartTime: time.Now().Add(-time.Hour),
+			Status:    transferStatusV0{Code: TransferStatusActive, NumFiles: 2},
+			Subtasks: []transferSubtaskV0{
+				{
+					Source:         "test-source",
+					Transfer:       uuid.NullUUID{UUID: uuid.New(), Valid: true},
+					TransferStatus: transferStatusV0{Code: TransferStatusActive, NumFiles: 2},
+				},
+			},
+		},
+	})
+	assert.Nil(err)
+	err = enc.Encode(databases.DatabaseSaveStates{})
+	assert.Nil(err)
+	loadedTasks, _, err = decodeSaveFile(contents.Bytes())
+	assert.Nil(err)
+	migratedTask := loadedTasks[taskId]
+	assert.Equal(tasks[taskId].FileIds, migratedTask.FileIds)
+	assert.Equal(TransferStatusActive, migratedTask.Status.Code)
+	assert.Equal(2, migratedTask.Status.NumFiles)
+	assert.Equal(migratedTask.StartTime, migratedTask.ProcessingTime)
+	assert.Len(migratedTask.Subtasks, 1)
+	assert.Equal(taskId, migratedTask.Subtasks[0].TaskId)
+	assert.False(migratedTask.Subtasks[0].TransferStartTime.IsZero())
+
+	// a legacy save file is imported into the store and retired
+	legacyFile := filepath.Join(config.Service.DataDirectory, legacySaveFile)
+	err = os.WriteFile(legacyFile, data, 0644)
+	assert.Nil(err)
+	loadedTasks = createOrLoadTasks()
+	assert.Contains(loadedTasks, taskId)
+	assert.NoFileExists(legacyFile)
+	assert.FileExists(legacyFile + ".imported")
+	state, err := store.Load()
+	assert.Nil(err)
+	assert.Len(state.Transfers, 1)
+	assert.Equal(taskId, state.Transfers[0].Id)
+
+	// an unreadable legacy save file is set aside
+	err = os.WriteFile(legacyFile, corrupted, 0644)
+	assert.Nil(err)
+	loadedTasks = createOrLoadTasks()
+	assert.Len(loadedTasks, 1)
+	assert.NoFileExists(legacyFile)
+	assert.FileExists(legacyFile + ".bad")
+
+	os.Remove(legacyFile + ".imported")
+	os.Remove(legacyFile + ".bad")
+	err = saveTasks(map[uuid.UUID]transferTask{})
+	assert.Nil(err)
+}
+
+// encodes the given tasks and the states of all databases into the contents
+// of a legacy save file
+func encodeLegacySaveFile(tasks map[uuid.UUID]transferTask) ([]byte, error) {
+	var contents bytes.Buffer
+	enc := gob.NewEncoder(&contents)
+	if err := enc.Encode(tasks); err != nil {
+		return nil, err
+	}
+	databaseStates, err := databases.Save()
+	if err != nil {
+		return nil, err
+	}
+	if err = enc.Encode(databaseStates); err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	data.WriteString(saveFileMagic)
+	binary.Write(&data, binary.BigEndian, saveFileVersion)
+	checksum := sha256.Sum256(contents.Bytes())
+	data.Write(checksum[:])
+	data.Write(contents.Bytes())
+	return data.Bytes(), nil
+}
+
+// tests that a manifest records the decision to skip checksums
+func TestManifestRecordsSkippedChecksums(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		DataDescriptors: []any{
+			map[string]any{
+				"name": "metadata",
+				"data": []any{map[string]any{"key": "value"}},
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.NotContains(manifest.Descriptor(), "skip_checksums")
+
+	task.SkipChecksums = true
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	assert.Equal(true, manifest.Descriptor()["skip_checksums"])
+}
+
+// tests the recording of a task's description in several languages, along
+// with the languages of its files' credit descriptions
+func TestManifestRecordsDescriptionLanguages(t *testing.T) {
+	assert := assert.New(t)
+
+	// translations need distinct, well-formed language tags
+	_, _, err := normalizeDescriptionLanguages("klingon!", nil)
+	assert.IsType(&InvalidLanguageError{}, err)
+	_, _, err = normalizeDescriptionLanguages("", []Translation{{Text: "Carottes de sol"}})
+	assert.IsType(&InvalidLanguageError{}, err)
+	_, _, err = normalizeDescriptionLanguages("fr", []Translation{{Language: "FR", Text: "Carottes"}})
+	assert.IsType(&InvalidLanguageError{}, err)
+	language, translations, err := normalizeDescriptionLanguages("en_us",
+		[]Translation{{Language: "pt-br", Text: "Núcleos de solo"}})
+	assert.Nil(err)
+	assert.Equal("en-US", language)
+	assert.Equal([]Translation{{Language: "pt-BR", Text: "Núcleos de solo"}}, translations)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		Description: "Soil cores",
+		Subtasks: []transferSubtask{
+			{
+				Descriptors: []any{
+					map[string]any{
+						"id": "file1", "name": "file1", "path": "dir1/file1.dat",
+						"credit": credit.CreditMetadata{
+							Identifier: "JDP:file1",
+							Descriptions: []credit.Description{
+								{DescriptionText: "Soil core 1", Language: "EN"},
+								{DescriptionText: "Carotte de sol 1", Language: "fr_ca"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// without languages or translations, only the description is recorded
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal("Soil cores", manifest.Descriptor()["description"])
+	assert.NotContains(manifest.Descriptor(), "descriptions")
+
+	task.DescriptionLanguage = language
+	task.Translations = translations
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	assert.Equal("Soil cores", manifest.Descriptor()["description"])
+	assert.Equal([]any{
+		map[string]any{"description": "Soil cores", "language": "en-US"},
+		map[string]any{"description": "Núcleos de solo", "language": "pt-BR"},
+	}, manifest.Descriptor()["descriptions"])
+	resource := manifest.Descriptor()["resources"].([]any)[0].(map[string]any)
+	descriptions := resource["credit"].(credit.CreditMetadata).Descriptions
+	assert.Equal("en", descriptions[0].Language)
+	assert.Equal("fr-CA", descriptions[1].Language)
+
+	// the file's own descriptor is left alone
+	original := task.Subtasks[0].Descriptors[0].(map[string]any)["credit"].(credit.CreditMetadata)
+	assert.Equal("EN", original.Descriptions[0].Language)
+
+	// descriptions are tagged with their languages in JSON-LD and DataCite
+	assert.Equal([]any{
+		map[string]any{"@value": "Soil cores", "@language": "en-US"},
+		map[string]any{"@value": "Núcleos de solo", "@language": "pt-BR"},
+	}, task.datasetJSONLDDescription())
+	dataciteDescriptions := task.dataciteDescriptions()
+	assert.Equal(2, len(dataciteDescriptions))
+	assert.Equal("pt-BR", dataciteDescriptions[1].(map[string]any)["lang"])
+}
+
+// tests the sanitization of destination paths
+func TestSanitizePath(t *testing.T) {
+	assert := assert.New(t)
+	path := "dir 1/Pöhlmann, et al.fasta"
+	assert.Equal(path, sanitizePath(path, ""))
+	assert.Equal(path, sanitizePath(path, "none"))
+	assert.Equal("dir_1/P_hlmann__et_al.fasta", sanitizePath(path, "replace"))
+	assert.Equal("dir%201/P%C3%B6hlmann%2C%20et%20al.fasta", sanitizePath(path, "encode"))
+	assert.Equal("dir1/file1.dat", sanitizePath("dir1/file1.dat", "replace"))
+}
+
+// tests that a manifest records sanitized file paths
+func TestManifestRecordsRenamedPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptor := map[string]any{
+		"id":     "file1",
+		"name":   "file1",
+		"path":   "dir 1/file 1.dat",
+		"format": "text",
+		"bytes":  1024,
+	}
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		Subtasks: []transferSubtask{
+			{
+				Descriptors:    []any{descriptor},
+				SourceEndpoint: "source-endpoint",
+				RenamedPaths:   map[string]string{"dir 1/file 1.dat": "dir_1/file_1.dat"},
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal([]any{
+		map[string]any{"original": "dir 1/file 1.dat", "sanitized": "dir_1/file_1.dat"},
+	}, manifest.Descriptor()["renamed_paths"])
+	resource := manifest.Descriptor()["resources"].([]any)[0].(map[string]any)
+	assert.Equal("dir_1/file_1.dat", resource["path"])
+	assert.Equal("dir 1/file 1.dat", descriptor["path"]) // source descriptor untouched
+}
+
+// tests that a manifest gives the destination paths and (when the destination
+// has an access URL) the access URLs of delivered files
+func TestManifestRecordsAccessURLs(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dts-access",
+		Subtasks: []transferSubtask{
+			{
+				Descriptors: []any{
+					map[string]any{"id": "file1", "name": "file1", "path": "dir 1/file1.dat"},
+				},
+				SourceEndpoint: "source-endpoint",
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	resource := manifest.Descriptor()["resources"].([]any)[0].(map[string]any)
+	assert.Equal("dts-access/dir 1/file1.dat", resource["destination_path"])
+	assert.NotContains(resource, "access_url")
+
+	destination := config.Databases["test-destination"]
+	defer func() { config.Databases["test-destination"] = destination }()
+	withAccessURL := destination
+	withAccessURL.AccessURL = "https://data.example.org/staging/"
+	config.Databases["test-destination"] = withAccessURL
+
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	resource = manifest.Descriptor()["resources"].([]any)[0].(map[string]any)
+	assert.Equal("https://data.example.org/staging/dts-access/dir%201/file1.dat", resource["access_url"])
+
+	// metadata-only manifests describe files that stay at their sources
+	task.MetadataOnly = true
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	resource = manifest.Descriptor()["resources"].([]any)[0].(map[string]any)
+	assert.NotContains(resource, "destination_path")
+	assert.NotContains(resource, "access_url")
+}
+
+// tests the recording of licenses in file descriptors and manifests
+func TestLicenses(t *testing.T) {
+	assert := assert.New(t)
+
+	ccBy := frictionless.ParseLicense("CC-BY-4.0")
+	policy := frictionless.License{Name: "Data-Policy", Path: "https://example.org/policy"}
+	descriptors := []map[string]any{
+		{"id": "file1", "name": "file1", "path": "dir1/file1.dat"},
+		{"id": "file2", "name": "file2", "path": "dir1/file2.dat",
+			"credit": credit.CreditMetadata{License: credit.License{Id: "CC-BY-4.0"}}},
+		{"id": "file3", "name": "file3", "path": "dir1/file3.dat"},
+		{"id": "file4", "name": "file4", "path": "dir1/file4.dat"},
+	}
+	frictionless.SetLicenses(descriptors[2], policy)
+
+	// files without licenses get those in their credit metadata or the default
+	fillLicenses(descriptors, "")
+	assert.Empty(frictionless.Licenses(descriptors[0]))
+	fillLicenses(descriptors, "https://example.org/terms")
+	assert.Equal([]frictionless.License{{Path: "https://example.org/terms"}}, frictionless.Licenses(descriptors[0]))
+	assert.Equal([]frictionless.License{ccBy}, frictionless.Licenses(descriptors[1]))
+	assert.Equal([]frictionless.License{policy}, frictionless.Licenses(descriptors[2]))
+
+	// licenses listed only in descriptors are credited too
+	payloadCredit := gatherCredit(descriptors)
+	assert.Equal([]credit.License{
+		{Url: "https://example.org/terms"},
+		{Id: "CC-BY-4.0"},
+		{Id: "Data-Policy", Url: "https://example.org/policy"},
+	}, payloadCredit.Licenses)
+
+	// the manifest lists the distinct licenses of its files
+	task := transferTask{
+		Id:          uuid.New(),
+		User:        auth.User{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"},
+		Source:      "test-source",
+		Destination: "test-destination",
+		Subtasks: []transferSubtask{
+			{
+				Descriptors:    []any{descriptors[1], descriptors[2], descriptors[3]},
+				SourceEndpoint: "source-endpoint",
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal([]any{ccBy.Map(), policy.Map(), map[string]any{"path": "https://example.org/terms"}},
+		manifest.Descriptor()["licenses"])
+}
+
+// tests the schema.org Dataset description delivered with a manifest
+func TestDatasetJSONLD(t *testing.T) {
+	assert := assert.New(t)
+
+	contributor := credit.Contributor{
+		ContributorType: "Person",
+		ContributorId:   "ORCID:1234-5678-9012-3456",
+		GivenName:       "Joe-bob",
+		FamilyName:      "Briggs",
+		Affiliations:    []credit.Organization{{OrganizationName: "Drive-In Theater"}},
+	}
+	task := transferTask{
+		Id:                uuid.New(),
+		User:              auth.User{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"},
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dts-jsonld",
+		Tags:              []string{"drive-in"},
+		Subtasks: []transferSubtask{
+			{
+				Descriptors: []any{
+					map[string]any{
+						"id": "file1", "name": "file1", "path": "dir1/file1.dat", "bytes": 1024,
+						"mediatype": "text/plain",
+						"credit": credit.CreditMetadata{
+							Identifier:   "JDP:file1",
+							Contributors: []credit.Contributor{contributor},
+							License:      credit.License{Id: "CC-BY-4.0"},
+						},
+					},
+					map[string]any{ // (credit decoded from JSON)
+						"id": "file2", "name": "file2", "path": "dir1/file2.dat",
+						"credit": map[string]any{
+							"identifier": "JDP:file2",
+							"contributors": []any{
+								map[string]any{"contributor_id": "ORCID:1234-5678-9012-3456"},
+							},
+							"license": map[string]any{"id": "CC-BY-4.0"},
+						},
+					},
+				},
+				SourceEndpoint: "source-endpoint",
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	dataset := task.datasetJSONLD(manifest)
+	assert.Equal("https://schema.org/", dataset["@context"])
+	assert.Equal("Dataset", dataset["@type"])
+	assert.Equal("urn:uuid:"+task.Id.String(), dataset["identifier"])
+	assert.NotEmpty(dataset["name"])
+	assert.NotEmpty(dataset["description"])
+	assert.Equal([]string{"drive-in"}, dataset["keywords"])
+
+	// creators and licenses are listed once each
+	creators := dataset["creator"].([]any)
+	assert.Len(creators, 1)
+	creator := creators[0].(map[string]any)
+	assert.Equal("Person", creator["@type"])
+	assert.Equal("Joe-bob Briggs", creator["name"])
+	assert.Equal("ORCID:1234-5678-9012-3456", creator["identifier"])
+	assert.Equal("https://spdx.org/licenses/CC-BY-4.0.html", dataset["license"])
+	assert.Equal([]string{"JDP:file1", "JDP:file2"}, dataset["isBasedOn"])
+
+	// files are listed relative to the JSON-LD file without an access URL
+	distribution := dataset["distribution"].([]any)
+	assert.Len(distribution, 2)
+	download := distribution[0].(map[string]any)
+	assert.Equal("DataDownload", download["@type"])
+	assert.Equal("dir1/file1.dat", download["contentUrl"])
+	assert.Equal("text/plain", download["encodingFormat"])
+	assert.Equal("1024 B", download["contentSize"])
+
+	destination := config.Databases["test-destination"]
+	defer func() { config.Databases["test-destination"] = destination }()
+	withJSONLD := destination
+	withJSONLD.AccessURL = "https://data.example.org/staging"
+	config.Databases["test-destination"] = withJSONLD
+
+	// the description is written only for destinations that want it
+	fileXfers, err := task.writeJSONLD(manifest)
+	assert.Nil(err)
+	assert.Empty(fileXfers)
+	assert.Empty(task.JSONLDFile)
+
+	withJSONLD.DatasetJSONLD = true
+	config.Databases["test-destination"] = withJSONLD
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	fileXfers, err = task.writeJSONLD(manifest)
+	assert.Nil(err)
+	assert.Len(fileXfers, 1)
+	assert.Equal("dts-jsonld/dataset.jsonld", fileXfers[0].DestinationPath)
+	data, err := os.ReadFile(task.JSONLDFile)
+	assert.Nil(err)
+	var written map[string]any
+	assert.Nil(json.Unmarshal(data, &written))
+	download = written["distribution"].([]any)[0].(map[string]any)
+	assert.Equal("https://data.example.org/staging/dts-jsonld/dir1/file1.dat", download["contentUrl"])
+
+	jsonLDFile := task.JSONLDFile
+	task.removeJSONLD()
+	assert.NoFileExists(jsonLDFile)
+	assert.Empty(task.JSONLDFile)
+}
+
+// tests the minting of draft DOIs for delivered payloads with DataCite, and
+// their publication
+func TestMintDOI(t *testing.T) {
+	assert := assert.New(t)
+
+	// a fake DataCite that mints DOIs for valid requests and records updates
+	var minted, updated map[string]any
+	var dataciteMutex sync.Mutex
+	datacite := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "DTS.TEST" || secret != "shhh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal("application/vnd.api+json", r.Header.Get("Content-Type"))
+		var body map[string]any
+		assert.Nil(json.NewDecoder(r.Body).Decode(&body))
+		attributes := body["data"].(map[string]any)["attributes"].(map[string]any)
+		dataciteMutex.Lock()
+		defer dataciteMutex.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/dois":
+			minted = attributes
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"data": {"id": "10.12345/dts-test", "type": "dois"}}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/dois/10.12345/dts-test":
+			updated = attributes
+			fmt.Fprint(w, `{"data": {"id": "10.12345/dts-test", "type": "dois"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer datacite.Close()
+
+	// DataCite requests are made in the background, so we wait for them
+	mintDOI := func(task *transferTask) {
+		for !task.mintDOI() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	publishDOI := func(task *transferTask) {
+		for !task.publishDOI() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	service, destination := config.Service, config.Databases["test-destination"]
+	defer func() {
+		config.Service = service
+		config.Databases["test-destination"] = destination
+		credentials.Reset()
+	}()
+	config.Service.DOI.Endpoint = datacite.URL
+	config.Service.DOI.Prefix = "10.12345"
+	config.Service.DOI.Credential = "datacite"
+	withDOIs := destination
+	withDOIs.MintDOIs = true
+	withDOIs.AccessURL = "https://data.example.org/staging"
+	config.Databases["test-destination"] = withDOIs
+
+	task := transferTask{
+		Id:                uuid.New(),
+		User:              auth.User{Name: "Joe-bob", Orcid: "1234-5678-9012-3456"},
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dts-doi",
+		Subtasks: []transferSubtask{
+			{
+				Descriptors: []any{
+					map[string]any{
+						"id": "file1", "name": "file1", "path": "dir1/file1.dat",
+						"credit": credit.CreditMetadata{
+							Identifier: "JDP:file1",
+							Url:        "https://doi.org/10.99999/file1",
+							Contributors: []credit.Contributor{
+								{
+									ContributorType:  "Person",
+									ContributorId:    "ORCID:2345-6789-0123-4567",
+									GivenName:        "Jane",
+									FamilyName:       "Doe",
+									ContributorRoles: "Data curation,Principal Investigator",
+								},
+							},
+							License: credit.License{Id: "CC-BY-4.0"},
+							Funding: []credit.FundingReference{
+								{
+									Funder:  credit.Organization{OrganizationId: "ROR:01bj3aw27", OrganizationName: "US DOE"},
+									GrantId: "DE-AC02-05CH11231",
+								},
+							},
+						},
+					},
+				},
+				SourceEndpoint: "source-endpoint",
+			},
+		},
+	}
+	mintDOI(&task)
+	assert.Equal("10.12345/dts-test", task.DOI)
+	assert.Empty(task.MetadataWarnings)
+
+	// the DOI is created as a draft described with the payload's credit
+	// metadata
+	assert.Equal("10.12345", minted["prefix"])
+	assert.NotContains(minted, "event")
+	assert.NotContains(minted, "url")
+	assert.Equal("Fabulous Destinations, Inc.", minted["publisher"])
+	creator := minted["creators"].([]any)[0].(map[string]any)
+	assert.Equal("Doe, Jane", creator["name"])
+	assert.Equal("https://orcid.org/2345-6789-0123-4567",
+		creator["nameIdentifiers"].([]any)[0].(map[string]any)["nameIdentifier"])
+	contributors := minted["contributors"].([]any)
+	assert.Equal(2, len(contributors))
+	assert.Equal("DataCurator", contributors[0].(map[string]any)["contributorType"])
+	assert.Equal("ProjectLeader", contributors[1].(map[string]any)["contributorType"])
+	rights := minted["rightsList"].([]any)[0].(map[string]any)
+	assert.Equal("CC-BY-4.0", rights["rightsIdentifier"])
+	funding := minted["fundingReferences"].([]any)[0].(map[string]any)
+	assert.Equal("https://ror.org/01bj3aw27", funding["funderIdentifier"])
+	related := minted["relatedIdentifiers"].([]any)[0].(map[string]any)
+	assert.Equal("10.99999/file1", related["relatedIdentifier"])
+	assert.Equal("DOI", related["relatedIdentifierType"])
+
+	// the DOI is recorded in the manifest, the journal, and callback events
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal("10.12345/dts-test", manifest.Descriptor()["doi"])
+	assert.Equal("10.12345/dts-test", task.journalRecord("succeeded").DOI)
+	task.CallbackURL = "https://example.org/callback"
+	task.Status.Code = TransferStatusSucceeded
+	callbacks := make(chan callbackDelivery, 1)
+	task.queueCallback(callbacks)
+	assert.Equal("10.12345/dts-test", (<-callbacks).Event.DOI)
+
+	// a DOI is minted only once
+	minted = nil
+	mintDOI(&task)
+	assert.Nil(minted)
+
+	// once the payload is delivered, the DOI is published, resolving to the
+	// destination folder
+	publishDOI(&task)
+	assert.Equal("publish", updated["event"])
+	assert.Equal("https://data.example.org/staging/dts-doi", updated["url"])
+	assert.Empty(task.MetadataWarnings)
+
+	// without an access URL, the DOI remains a draft
+	withDOIs.AccessURL = ""
+	config.Databases["test-destination"] = withDOIs
+	updated = nil
+	assert.True(task.publishDOI())
+	assert.Nil(updated)
+
+	// failures are reported as warnings
+	config.Service.DOI.Credential = "nonexistent"
+	task.DOI = ""
+	mintDOI(&task)
+	assert.Empty(task.DOI)
+	assert.Len(task.MetadataWarnings, 1)
+	assert.Contains(task.warnings()[0], "couldn't mint DOI")
+}
+
+// tests that a manifest lists files in the order in which they were requested,
+// even when they're transferred by different subtasks
+func TestManifestPreservesRequestOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptor := func(id string) map[string]any {
+		return map[string]any{
+			"id":     id,
+			"name":   id,
+			"path":   id + ".dat",
+			"format": "text",
+			"bytes":  1024,
+		}
+	}
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file3", "file1", "file2"},
+		Subtasks: []transferSubtask{
+			{
+				Descriptors:    []any{descriptor("file1"), descriptor("file2")},
+				SourceEndpoint: "source-endpoint",
+			},
+			{
+				Descriptors:    []any{descriptor("file3")},
+				SourceEndpoint: "source-endpoint",
+			},
+		},
+		DataDescriptors: []any{
+			map[string]any{
+				"name": "metadata",
+				"data": []any{map[string]any{"key": "value"}},
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	resources := manifest.Descriptor()["resources"].([]any)
+	assert.Len(resources, 4)
+	for i, id := range task.FileIds {
+		resource := resources[i].(map[string]any)
+		assert.Equal(id, resource["id"])
+		assert.Equal(i, resource["index"])
+	}
+	assert.Equal("metadata", resources[3].(map[string]any)["name"])
+	assert.NotContains(task.Subtasks[0].Descriptors[0], "index") // not modified
+}
+
+// tests that a manifest records the expansion of requested datasets
+func TestManifestRecordsDatasets(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		DataDescriptors: []any{
+			map[string]any{
+				"name": "metadata",
+				"data": []any{map[string]any{"key": "value"}},
+			},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.NotContains(manifest.Descriptor(), "datasets")
+
+	task.Datasets = map[string][]string{
+		"JDP-PROJECT:1234": {"file1", "file2"},
+	}
+	manifest, err = task.createManifest()
+	assert.Nil(err)
+	assert.Equal([]any{
+		map[string]any{"id": "JDP-PROJECT:1234", "file_ids": []string{"file1", "file2"}},
+	}, manifest.Descriptor()["datasets"])
+}
+
+// tests the extraction of filter rules from transfer instructions
+func TestFilterRulesFromInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := filterRulesFromInstructions(map[string]any{
+		"filter_rules": []any{
+			map[string]any{"method": "include", "name": "*.fastq.gz"},
+			map[string]any{"method": "exclude", "type": "file", "name": "*"},
+		},
+	})
+	assert.Nil(err)
+	assert.Equal([]FilterRule{
+		{Method: "include", Name: "*.fastq.gz"},
+		{Method: "exclude", Type: "file", Name: "*"},
+	}, rules)
+
+	rules, err = filterRulesFromInstructions(nil)
+	assert.Nil(err)
+	assert.Nil(rules)
+
+	for _, badRules := range []any{
+		"*.fastq.gz",
+		[]any{"*.fastq.gz"},
+		[]any{map[string]any{"method": "ignore", "name": "*"}},
+		[]any{map[string]any{"method": "include", "type": "link", "name": "*"}},
+		[]any{map[string]any{"method": "include", "name": "[*"}},
+		[]any{map[string]any{"method": "include"}},
+	} {
+		_, err = filterRulesFromInstructions(map[string]any{"filter_rules": badRules})
+		assert.IsType(&InvalidFilterRulesError{}, err)
+	}
+}
+
+// tests the selection and exclusion of source endpoints in transfer instructions
+func TestSourceEndpointOverrides(t *testing.T) {
+	assert := assert.New(t)
+
+	// give the source database a second endpoint
+	original := config.Databases["test-source"]
+	dbConfig := original
+	dbConfig.Endpoint = ""
+	dbConfig.Endpoints = map[string]string{
+		"primary":   "source-endpoint",
+		"secondary": "local-endpoint",
+	}
+	config.Databases["test-source"] = dbConfig
+	defer func() { config.Databases["test-source"] = original }()
+
+	overrides, err := sourceEndpointOverrides("test-source", nil)
+	assert.Nil(err)
+	assert.Nil(overrides)
+
+	overrides, err = sourceEndpointOverrides("test-source", map[string]any{
+		"source_endpoint": "secondary",
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]string{"source-endpoint": "local-endpoint"}, overrides)
+
+	overrides, err = sourceEndpointOverrides("test-source", map[string]any{
+		"exclude_source_endpoints": []any{"local-endpoint"},
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]string{"local-endpoint": "source-endpoint"}, overrides)
+
+	source, err := databases.NewDatabase("test-source")
+	assert.Nil(err)
+	descriptors := []map[string]any{
+		{"id": "file1", "path": "dir1/file1.dat", "endpoint": "source-endpoint"},
+		{"id": "file2", "path": "dir2/file2.dat", "endpoint": "local-endpoint"},
+	}
+	err = overrideSourceEndpoints(source, descriptors, overrides)
+	assert.Nil(err)
+	assert.Equal("source-endpoint", descriptors[0]["endpoint"])
+	assert.Equal("source-endpoint", descriptors[1]["endpoint"])
+	assert.Equal("dir2/file2.dat", descriptors[1]["path"])
+
+	// databases that lay out their endpoints differently relocate files...
+	descriptors[1]["endpoint"] = "local-endpoint"
+	err = overrideSourceEndpoints(relocatingDatabase{source}, descriptors, overrides)
+	assert.Nil(err)
+	assert.Equal("source-endpoint", descriptors[1]["endpoint"])
+	assert.Equal("mirror/dir2/file2.dat", descriptors[1]["path"])
+
+	// ...or refuse to
+	descriptors[1]["endpoint"] = "local-endpoint"
+	err = overrideSourceEndpoints(relocatingDatabase{source}, descriptors,
+		map[string]string{"local-endpoint": "destination-endpoint"})
+	assert.IsType(&InvalidSourceEndpointError{}, err)
+
+	for _, badInstructions := range []map[string]any{
+		{"source_endpoint": 1},
+		{"source_endpoint": "destination-endpoint"},
+		{"exclude_source_endpoints": "primary"},
+		{"exclude_source_endpoints": []any{"nowhere"}},
+		{"exclude_source_endpoints": []any{"primary", "secondary"}},
+		{"source_endpoint": "primary", "exclude_source_endpoints": []any{"source-endpoint"}},
+	} {
+		_, err = sourceEndpointOverrides("test-source", badInstructions)
+		assert.IsType(&InvalidSourceEndpointError{}, err)
+	}
+}
+
+// a database that mirrors the files at its local endpoint under a "mirror"
+// folder at its source endpoint
+type relocatingDatabase struct {
+	databases.Database
+}
+
+func (db relocatingDatabase) RelocatePath(path, fromEndpoint, toEndpoint string) (string, error) {
+	if fromEndpoint == "local-endpoint" && toEndpoint == "source-endpoint" {
+		return filepath.Join("mirror", path), nil
+	}
+	return "", &databases.ResourceNotMirroredError{Database: "test-source", Path: path, Endpoint: toEndpoint}
+}
+
+// tests the detection of files whose source metadata changes mid-transfer
+func TestSourceChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+	}
+	err := task.resolve()
+	assert.Nil(err)
+	assert.Len(task.SourceHashes, 2)
+
+	// nothing has changed upstream (checked in the background)
+	assert.Eventually(task.checkSourceChanges, 10*time.Second, 10*time.Millisecond)
+	assert.True(task.SourceChangesChecked)
+	assert.Empty(task.SourceChanges)
+
+	// simulate a file replaced upstream and another removed
+	replaced := maps.Clone(task.fileDescriptors[0])
+	replaced["hash"] = "replaced"
+	changed := changedFiles(task.SourceHashes, []map[string]any{replaced})
+	assert.Equal([]string{"file1", "file2"}, changed)
+	changed = changedFiles(task.SourceHashes, task.fileDescriptors)
+	assert.Empty(changed)
+}
+
+// tests the exclusion of files by ID and name pattern
+func TestExcludedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2", "file3"},
+		Exclude:     []string{"file1", "file3.*"},
+	}
+	err := task.resolve()
+	assert.Nil(err)
+	assert.Len(task.fileDescriptors, 1)
+	assert.Equal("file2", task.fileDescriptors[0]["id"])
+
+	// excluding everything leaves nothing to transfer
+	task = transferTask{
+		Source:  "test-source",
+		FileIds: []string{"file1", "file2"},
+		Exclude: []string{"*.dat"},
+	}
+	err = task.resolve()
+	assert.NotNil(err)
+
+	err = validateExclusions([]string{"file1", "*.dat"})
+	assert.Nil(err)
+	err = validateExclusions([]string{"[*.dat"})
+	assert.IsType(&InvalidExclusionError{}, err)
+}
+
+// tests that files with malformed descriptors are dropped from a payload and
+// reported in its manifest
+func TestDroppedResources(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "malformed-file"},
+	}
+	err := task.resolve()
+	assert.Nil(err)
+	assert.Len(task.fileDescriptors, 1)
+	assert.Equal("file1", task.fileDescriptors[0]["id"])
+	assert.Len(task.DroppedResources, 1)
+	assert.Equal("malformed-file", task.DroppedResources[0].Id)
+
+	// (the manifest needs at least one resource, and we haven't created subtasks)
+	task.DataDescriptors = []any{
+		map[string]any{
+			"name": "metadata",
+			"data": []any{map[string]any{"key": "value"}},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	dropped := manifest.Descriptor()["dropped_resources"].([]any)
+	assert.Len(dropped, 1)
+	assert.Equal("malformed-file", dropped[0].(map[string]any)["id"])
+
+	// a payload with only malformed files can't be transferred
+	task = transferTask{
+		Source:  "test-source",
+		FileIds: []string{"malformed-file"},
+	}
+	err = task.resolve()
+	assert.NotNil(err)
+	assert.Len(task.DroppedResources, 1)
+}
+
+// tests that non-fatal issues with a payload's metadata are reported as
+// warnings in its summary and manifest
+func TestMetadataWarnings(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptors := []map[string]any{
+		{"id": "file1", "path": "file1.dat", "bytes": 1, "hash": "abc"},
+		{"id": "file2", "path": "file2.dat", "bytes": 1},
+		{"id": "dir1", "path": "dir1", "recursive": true},
+	}
+	warnings := metadataWarnings(descriptors, nil)
+	assert.Equal([]string{
+		"1 file(s) missing checksums",
+		"credit metadata missing or incomplete for 3 file(s)",
+	}, warnings)
+
+	complete := credit.CreditMetadata{
+		Identifier:   "JDP:1234",
+		Contributors: []credit.Contributor{{Name: "Joe-bob"}},
+	}
+	assert.False(creditIncomplete(complete))
+	assert.True(creditIncomplete(credit.CreditMetadata{Identifier: "JDP:1234"}))
+	assert.False(creditIncomplete(map[string]any{
+		"identifier":   "JDP:1234",
+		"contributors": []any{map[string]any{"name": "Joe-bob"}},
+	}))
+	assert.True(creditIncomplete(nil))
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "malformed-file"},
+	}
+	err := task.resolve()
+	assert.Nil(err)
+	task.SourceChanges = []string{"file1"}
+	warnings = task.Summary().Warnings
+	assert.Equal([]string{
+		"1 file(s) dropped due to malformed metadata",
+		"credit metadata missing or incomplete for 1 file(s)",
+		"source metadata changed during transfer for 1 file(s)",
+	}, warnings)
+
+	task.DataDescriptors = []any{
+		map[string]any{
+			"name": "metadata",
+			"data": []any{map[string]any{"key": "value"}},
+		},
+	}
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	assert.Equal(warnings, manifest.Descriptor()["warnings"])
+}
+
+// tests that the payloads of queued tasks (or errors resolving them) are
+// resolved once and cached, rather than fetched from their sources on every
+// poll
+func TestQueuedTaskResolution(t *testing.T) {
+	assert := assert.New(t)
+
+	config.Service.MaxActiveTransfers = 1
+	defer func() {
+		config.Service.MaxActiveTransfers = 0
+	}()
+
+	user := auth.User{
+		Name:  "Joe-bob",
+		Orcid: "1234-5678-9012-3456",
+	}
+	active := transferTask{
+		Id:       uuid.New(),
+		User:     user,
+		Source:   "test-source",
+		FileIds:  []string{"file1"},
+		Subtasks: []transferSubtask{{}},
+		Status:   TransferStatus{Code: TransferStatusActive},
+	}
+	queued := transferTask{
+		Id:          uuid.New(),
+		User:        user,
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		StartTime:   time.Now(),
+	}
+	unresolvable := transferTask{
+		Id:          uuid.New(),
+		User:        user,
+		Source:      "nonexistent-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1"},
+		StartTime:   time.Now(),
+	}
+	tasks := map[uuid.UUID]transferTask{
+		active.Id:       active,
+		queued.Id:       queued,
+		unresolvable.Id: unresolvable,
+	}
+	assert.Equal(map[uuid.UUID]struct{}{queued.Id: {}}, queuedTasks(tasks))
+	assert.Len(tasks[queued.Id].fileDescriptors, 2)
+	assert.NotNil(tasks[unresolvable.Id].resolveError)
+
+	// later polls don't consult the sources again
+	queued, unresolvable = tasks[queued.Id], tasks[unresolvable.Id]
+	queued.Source, unresolvable.Source = "nonexistent-source", "test-source"
+	tasks[queued.Id], tasks[unresolvable.Id] = queued, unresolvable
+	assert.Equal(map[uuid.UUID]struct{}{queued.Id: {}}, queuedTasks(tasks))
+	assert.Len(tasks[queued.Id].fileDescriptors, 2)
+	assert.NotNil(tasks[unresolvable.Id].resolveError)
+}
+
+// a database that reports embargoes on its files
+type embargoingDatabase struct {
+	databases.Database
+}
+
+func (db embargoingDatabase) ReportsEmbargoes() bool {
+	return true
+}
+
+// tests that tasks requesting embargoed files fail or wait for the embargoes
+// to lift
+func TestEmbargoedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "embargoed-file"},
+	}
+	err := task.resolve()
+	assert.IsType(&EmbargoedFilesError{}, err)
+	assert.Contains(err.Error(), "embargoed-file")
+
+	// a waiting task is held until its embargoes lift
+	task = transferTask{
+		Id:             uuid.New(),
+		User:           task.User,
+		Source:         "test-source",
+		Destination:    "test-destination",
+		FileIds:        task.FileIds,
+		WaitForEmbargo: true,
+	}
+	err = task.start()
+	assert.Nil(err)
+	assert.True(task.Embargoed())
+	assert.Empty(task.Subtasks)
+	assert.Contains(task.Status.Message, "embargo")
+
+	// transfers of embargoed files from databases that report embargoes are
+	// refused when they're requested
+	spec := Specification{
+		User:    task.User,
+		Source:  "test-source",
+		FileIds: task.FileIds,
+	}
+	source, err := databases.NewDatabase("test-source")
+	assert.Nil(err)
+	descriptors := func() ([]map[string]any, error) {
+		return source.Descriptors(spec.User.Orcid, spec.FileIds)
+	}
+	assert.Nil(checkEmbargoes(source, spec, descriptors)) // doesn't report them
+	err = checkEmbargoes(embargoingDatabase{source}, spec, descriptors)
+	assert.IsType(&EmbargoedFilesError{}, err)
+	spec.WaitForEmbargo = true
+	assert.Nil(checkEmbargoes(embargoingDatabase{source}, spec, descriptors))
+
+	// lifted embargoes and malformed embargo times
+	now := time.Now()
+	embargoes, err := embargoedFiles([]map[string]any{
+		{"id": "past", "embargo_until": "2000-01-01"},
+		{"id": "future", "embargo_until": now.Add(time.Hour).Format(time.RFC3339)},
+		{"id": "none"},
+	}, now)
+	assert.Nil(err)
+	assert.Equal([]string{"future"}, slices.Collect(maps.Keys(embargoes)))
+	_, err = embargoedFiles([]map[string]any{{"id": "bad", "embargo_until": "soon"}}, now)
+	assert.NotNil(err)
+}
+
+// tests the re-verification of the files delivered by a completed transfer
+func TestVerifyDelivery(t *testing.T) {
+	assert := assert.New(t)
+
+	taskId := uuid.New()
+	err := manifests.Archive(manifests.Entry{
+		Id:          taskId,
+		Orcid:       "1234-5678-9012-3456",
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2"},
+		Manifest: json.RawMessage(`{"name": "manifest", "resources": [
+			{"id": "file1", "path": "dir1/file1.dat", "hash": "d91f97974d06563cab48d4d43a17e08a"},
+			{"id": "file2", "path": "dir2/file2.dat", "hash": "d91f9e974d0e563cab48d4d43a17e08a"},
+			{"name": "inline", "data": {"answer": 42}}
+		]}`),
+	})
+	assert.Nil(err)
+
+	// the test destination endpoint has all files but can't compute checksums
+	report, err := Verify(taskId)
+	assert.Nil(err)
+	assert.Equal(filepath.Join("testuser", "dts-"+taskId.String()), report.DestinationFolder)
+	assert.Equal(2, report.NumFiles)
+	assert.Equal(0, report.NumMissing)
+	assert.Equal(2, report.NumUnverified)
+	assert.True(report.Passed())
+	assert.Equal("file1", report.Files[0].Id)
+	assert.Equal(filepath.Join(report.DestinationFolder, "dir1/file1.dat"), report.Files[0].Path)
+
+	// transfers without archived manifests can't be verified
+	_, err = Verify(uuid.New())
+	assert.IsType(&manifests.NotFoundError{}, err)
+}
+
+// tests the injection of faults into a transfer's submission and status checks
+func TestInjectedFaults(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1"},
+	}
+
+	// faults can't be injected unless enabled
+	err := faults.Inject(task.Id, faults.SubmissionFailure, 1)
+	assert.IsType(&faults.NotEnabledError{}, err)
+
+	config.Service.FaultInjection = true
+	defer func() { config.Service.FaultInjection = false }()
+	err = faults.Inject(task.Id, "meteor_strike", 1)
+	assert.IsType(&faults.InvalidFaultError{}, err)
+
+	// the first submission fails, and a second attempt succeeds
+	err = faults.Inject(task.Id, faults.SubmissionFailure, 1)
+	assert.Nil(err)
+	err = task.start()
+	assert.IsType(&faults.InjectedFaultError{}, err)
+	assert.Empty(faults.Injected(task.Id))
+	err = task.start()
+	assert.Nil(err)
+	assert.Equal(TransferStatusActive, task.Subtasks[0].TransferStatus.Code)
+
+	// an expired token surfaces as an error when checking on the transfer
+	err = faults.Inject(task.Id, faults.TokenExpiry, 0)
+	assert.Nil(err)
+	err = task.Subtasks[0].update()
+	assert.IsType(&faults.InjectedFaultError{}, err)
+	assert.Contains(err.Error(), "access token expired")
+	assert.Equal([]faults.Fault{faults.TokenExpiry}, faults.Injected(task.Id))
+	faults.Clear(task.Id)
+	err = task.Subtasks[0].update()
+	assert.Nil(err)
+}
+
+// tests the retrying of failed transfer submissions with backoff
+func TestSubmissionRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	retries := config.Service.Retries
+	config.Service.FaultInjection = true
+	defer func() {
+		config.Service.Retries = retries
+		config.Service.FaultInjection = false
+	}()
+
+	newTask := func() transferTask {
+		return transferTask{
+			Id: uuid.New(),
+			User: auth.User{
+				Name:  "Joe-bob",
+				Orcid: "1234-5678-9012-3456",
+			},
+			Source:      "test-source",
+			Destination: "test-destination",
+			FileIds:     []string{"file1"},
+		}
+	}
+
+	// backoff delays double up to the maximum, within the jitter
+	config.Service.Retries.InitialBackoff = 10
+	config.Service.Retries.MaxBackoff = 30
+	config.Service.Retries.Jitter = 0
+	assert.Equal(10*time.Second, retryBackoff(1))
+	assert.Equal(20*time.Second, retryBackoff(2))
+	assert.Equal(30*time.Second, retryBackoff(5))
+	config.Service.Retries.Jitter = 0.5
+	for range 10 {
+		delay := retryBackoff(1)
+		assert.True(delay >= 5*time.Second && delay <= 15*time.Second)
+	}
+
+	// failed submissions are retried after their backoff delays and recorded
+	config.Service.Retries.MaxAttempts = 3
+	config.Service.Retries.InitialBackoff = 0
+	config.Service.Retries.Jitter = 0
+	task := newTask()
+	err := faults.Inject(task.Id, faults.SubmissionFailure, 2)
+	assert.Nil(err)
+	err = task.start()
+	assert.Nil(err)
+	subtask := &task.Subtasks[0]
+	assert.True(subtask.Retries.pending())
+	assert.False(subtask.Transfer.Valid)
+	err = subtask.update()
+	assert.Nil(err)
+	assert.True(subtask.Retries.pending())
+	err = subtask.update()
+	assert.Nil(err)
+	assert.False(subtask.Retries.pending())
+	assert.True(subtask.Transfer.Valid)
+	assert.Equal(3, len(subtask.Retries.Attempts))
+	assert.Contains(subtask.Retries.Attempts[0].Error, "transfer submission failed")
+	assert.Empty(subtask.Retries.Attempts[2].Error)
+
+	// the task fails once the policy is exhausted
+	task = newTask()
+	err = faults.Inject(task.Id, faults.SubmissionFailure, 0)
+	assert.Nil(err)
+	defer faults.Clear(task.Id)
+	err = task.start()
+	assert.Nil(err)
+	subtask = &task.Subtasks[0]
+	assert.Nil(subtask.update())
+	err = subtask.update()
+	assert.IsType(&SubmissionRetriesExhaustedError{}, err)
+	assert.Equal(3, len(subtask.Retries.Attempts))
+	assert.False(subtask.Retries.pending())
+
+	// errors that aren't transient aren't retried
+	assert.False(transientSubmissionError(&globus.GlobusError{Code: "ClientError.NotFound"}))
+	assert.True(transientSubmissionError(&globus.GlobusError{Code: "ServiceUnavailable"}))
+	assert.True(transientSubmissionError(fmt.Errorf("transferring manifest file: %w",
+		&globus.GlobusError{Code: "ExternalError.DirListingFailed"})))
+	assert.False(transientSubmissionError(&EmbargoedFilesError{}))
+}
+
+// a source endpoint that accepts a transfer at most once per submission ID,
+// failing its first submission after accepting it
+type idempotentEndpoint struct {
+	Endpoint
+	SubmissionIds []uuid.UUID             // IDs handed out, in order
+	Submitted     map[uuid.UUID]uuid.UUID // transfer IDs by submission ID
+}
+
+func (ep *idempotentEndpoint) SubmissionId(ctx context.Context) (uuid.UUID, error) {
+	submissionId := uuid.New()
+	ep.SubmissionIds = append(ep.SubmissionIds, submissionId)
+	return submissionId, nil
+}
+
+func (ep *idempotentEndpoint) SubmitTransfer(ctx context.Context, submissionId uuid.UUID,
+	dst Endpoint, files []FileTransfer, skipErrors bool) (uuid.UUID, error) {
+	if transferId, found := ep.Submitted[submissionId]; found {
+		return transferId, nil
+	}
+	ep.Submitted[submissionId] = uuid.New()
+	return uuid.UUID{}, context.DeadlineExceeded // (the response is lost)
+}
+
+// tests that retried transfer submissions reuse their submission IDs
+func TestIdempotentSubmission(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := &idempotentEndpoint{Submitted: make(map[uuid.UUID]uuid.UUID)}
+	subtask := transferSubtask{TaskId: uuid.New()}
+
+	// the first attempt is accepted, but its response is lost...
+	_, err := subtask.submitTransfer(context.Background(), endpoint, nil, nil, false)
+	assert.NotNil(err)
+	assert.True(subtask.SubmissionId.Valid)
+
+	// ...so the retry gets the transfer it began instead of beginning another
+	transferId, err := subtask.submitTransfer(context.Background(), endpoint, nil, nil, false)
+	assert.Nil(err)
+	assert.Equal(endpoint.Submitted[subtask.SubmissionId.UUID], transferId)
+	assert.Equal(1, len(endpoint.SubmissionIds))
+	assert.Equal(1, len(endpoint.Submitted))
+}
+
+// tests the relaying of a subtask's files through an intermediate endpoint
+func TestRelayedSubtask(t *testing.T) {
+	assert := assert.New(t)
+
+	subtask := transferSubtask{
+		Destination:       "test-destination",
+		DestinationFolder: "testuser/dts-relay-test",
+		Descriptors: []any{
+			map[string]any{
+				"id":   "file1",
+				"path": "dir1/file1.dat",
+				"hash": "d91f97974d06563cab48d4d43a17e08a",
+			},
+		},
+		Source:         "test-source",
+		SourceEndpoint: "source-endpoint",
+		RelayEndpoint:  "local-endpoint",
+	}
+
+	// the first leg carries files to the relay endpoint
+	err := subtask.beginTransfer()
+	assert.Nil(err)
+	assert.True(subtask.Transfer.Valid)
+	assert.False(subtask.Relayed)
+	firstLeg := subtask.Transfer.UUID
+
+	// once they arrive, the second leg carries them to the destination
+	time.Sleep(pause + endpointOptions.TransferDuration)
+	err = subtask.checkTransfer()
+	assert.Nil(err)
+	assert.True(subtask.Relayed)
+	assert.True(subtask.Transfer.Valid)
+	assert.NotEqual(firstLeg, subtask.Transfer.UUID)
+	assert.Equal(TransferStatusActive, subtask.TransferStatus.Code)
+
+	time.Sleep(pause + endpointOptions.TransferDuration)
+	err = subtask.checkTransfer()
+	assert.Nil(err)
+	assert.False(subtask.Transfer.Valid)
+	assert.Equal(TransferStatusSucceeded, subtask.TransferStatus.Code)
+}
+
+// temporary testing directory
+var TESTING_DIR string
+
+// endpoint testing options
+var endpointOptions = dtstest.EndpointOptions{
+	StagingDuration:  time.Duration(150) * time.Millisecond,
+	TransferDuration: time.Duration(500) * time.Millisecond,
+}
+
+// a pause to give the task manager a bit of time
+var pause time.Duration = time.Duration(25) * time.Millisecond
+
+// configuration
+const tasksConfig string = `
+service:
+  port: 8080
+  max_connections: 100
+  poll_interval: 50  # milliseconds
+  data_dir: TESTING_DIR/data
+  manifest_dir: TESTING_DIR/manifests
+  delete_after: 2    # seconds
+  endpoint: local-endpoint
+  quotas:
+    clients:
+      test-client:
+        max_concurrent_transfers: 2
+        max_daily_payload_size: 1
+credentials:
+  datacite:
+    id: DTS.TEST
+    secret: shhh
+databases:
+  test-source:
+    name: Source Test Database
+    organization: The Source Company
+    endpoint: source-endpoint
+  test-destination:
+    name: Destination Test Database
+    organization: Fabulous Destinations, Inc.
+    endpoint: destination-endpoint
+egress_policies:
+  private-data:
+    description: private data may not leave the source database
+    source: test-source
+    match:
+      private: true
+    destinations: [test-source]
+endpoints:
+  local-endpoint:
+    name: Local endpoint
     id: 8816ec2d-4a48-4ded-b68a-5ab46a4417b6
     provider: test
   source-endpoint:
@@ -332,4 +2880,654 @@ endpoints:
     id: f1865b86-2c64-4b8b-99f3-5aaa945ec3d9
     provider: test
     root: DESTINATION_ROOT
+  pausable-endpoint:
+    name: Pausable endpoint
+    id: 5b0f1e52-8c1d-4f7e-9a3b-2d6c4e8f0a17
+    provider: pausable
 `
+
+// an endpoint that pauses and resumes transfers, failing to do so for a
+// given transfer
+type pausableEndpoint struct {
+	*dtstest.Endpoint
+	Failing uuid.UUID
+	Paused  map[uuid.UUID]bool
+}
+
+func (ep *pausableEndpoint) Pause(id uuid.UUID) error {
+	if id == ep.Failing {
+		return fmt.Errorf("can't pause transfer %s", id.String())
+	}
+	ep.Paused[id] = true
+	return nil
+}
+
+func (ep *pausableEndpoint) Resume(id uuid.UUID) error {
+	if id == ep.Failing {
+		return fmt.Errorf("can't resume transfer %s", id.String())
+	}
+	delete(ep.Paused, id)
+	return nil
+}
+
+var pausableEndpoint_ = &pausableEndpoint{
+	Endpoint: dtstest.NewEndpoint(dtstest.EndpointOptions{}, ""),
+	Paused:   make(map[uuid.UUID]bool),
+}
+
+// tests that a task whose transfers can't all be paused (or resumed) is left
+// as it was
+func TestPartialPauseAndResume(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id:     uuid.New(),
+		Status: TransferStatus{Code: TransferStatusActive},
+		Subtasks: []transferSubtask{
+			{SourceEndpoint: "pausable-endpoint", Transfer: uuid.NullUUID{UUID: uuid.New(), Valid: true}},
+			{SourceEndpoint: "pausable-endpoint", Transfer: uuid.NullUUID{UUID: uuid.New(), Valid: true}},
+		},
+	}
+	pausableEndpoint_.Failing = task.Subtasks[1].Transfer.UUID
+	err := task.Pause()
+	assert.NotNil(err)
+	assert.False(task.Paused)
+	assert.Empty(pausableEndpoint_.Paused)
+
+	pausableEndpoint_.Failing = uuid.Nil
+	err = task.Pause()
+	assert.Nil(err)
+	assert.Len(pausableEndpoint_.Paused, 2)
+
+	pausableEndpoint_.Failing = task.Subtasks[1].Transfer.UUID
+	err = task.Resume()
+	assert.NotNil(err)
+	assert.True(task.Paused)
+	assert.Len(pausableEndpoint_.Paused, 2)
+
+	pausableEndpoint_.Failing = uuid.Nil
+	err = task.Resume()
+	assert.Nil(err)
+	assert.False(task.Paused)
+	assert.Empty(pausableEndpoint_.Paused)
+}
+
+// tests the validation of dependencies between transfers and waiting on them
+func TestDependencies(t *testing.T) {
+	assert := assert.New(t)
+
+	user := auth.User{
+		Name:  "Joe-bob",
+		Orcid: "1234-5678-9012-3456",
+	}
+	reference := transferTask{Id: uuid.New(), User: user, Status: TransferStatus{Code: TransferStatusActive}}
+	other := transferTask{Id: uuid.New(), User: auth.User{Orcid: "0000-0000-0000-0000"}}
+	tasks := map[uuid.UUID]transferTask{reference.Id: reference, other.Id: other}
+
+	// dependencies must exist and belong to the same user
+	reads := transferTask{User: user, DependsOn: []uuid.UUID{reference.Id}}
+	assert.Nil(validateDependencies(reads, tasks))
+	err := validateDependencies(transferTask{User: user, DependsOn: []uuid.UUID{uuid.New()}}, tasks)
+	assert.IsType(&InvalidDependencyError{}, err)
+	err = validateDependencies(transferTask{User: user, DependsOn: []uuid.UUID{other.Id}}, tasks)
+	assert.IsType(&InvalidDependencyError{}, err)
+
+	// a task waits while its dependencies are in progress...
+	reads.Id = uuid.New()
+	reads.Status.Code = TransferStatusWaiting
+	waiting, err := reads.checkDependencies(tasks)
+	assert.Nil(err)
+	assert.True(waiting)
+	assert.Equal(TransferStatusWaiting, reads.Status.Code)
+	assert.Contains(reads.Status.Message, "waiting for 1 of 1")
+
+	// ...and is released once they succeed
+	reference.Status.Code = TransferStatusSucceeded
+	tasks[reference.Id] = reference
+	waiting, err = reads.checkDependencies(tasks)
+	assert.Nil(err)
+	assert.True(waiting) // starts on the next poll
+	assert.Equal(TransferStatusUnknown, reads.Status.Code)
+	waiting, err = reads.checkDependencies(tasks)
+	assert.Nil(err)
+	assert.False(waiting)
+
+	// a task fails if a dependency fails
+	reads.Status.Code = TransferStatusWaiting
+	reference.Status.Code = TransferStatusFailed
+	tasks[reference.Id] = reference
+	_, err = reads.checkDependencies(tasks)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), reference.Id.String())
+
+	// ...or has been purged
+	delete(tasks, reference.Id)
+	_, err = reads.checkDependencies(tasks)
+	assert.NotNil(err)
+}
+
+// tests the enforcement of concurrent transfer and daily payload quotas
+func TestQuotas(t *testing.T) {
+	assert := assert.New(t)
+
+	defaultQuota := config.Service.Quotas.Default
+	defer func() {
+		config.Service.Quotas.Default = defaultQuota
+	}()
+
+	user := auth.User{
+		Name:  "Joe-bob",
+		Orcid: "1234-5678-9012-3456",
+	}
+	other := auth.User{Orcid: "0000-0000-0000-0000"}
+	active := transferTask{
+		Id:          uuid.New(),
+		User:        user,
+		StartTime:   time.Now(),
+		PayloadSize: 0.25,
+		Status:      TransferStatus{Code: TransferStatusActive},
+	}
+	tasks := map[uuid.UUID]transferTask{active.Id: active}
+
+	// by default, there are no quotas
+	err := checkQuotas(transferTask{User: user}, tasks)
+	assert.Nil(err)
+
+	// a user's concurrent transfers can be limited
+	config.Service.Quotas.Default.MaxConcurrentTransfers = 1
+	err = checkQuotas(transferTask{User: user}, tasks)
+	assert.IsType(&QuotaExceededError{}, err)
+	assert.Contains(err.Error(), "1 transfer(s) in progress")
+	err = checkQuotas(transferTask{User: other}, tasks)
+	assert.Nil(err)
+
+	// ...as can the payloads they request each day
+	config.Service.Quotas.Default = defaultQuota
+	config.Service.Quotas.Default.MaxDailyPayloadSize = 2
+	err = checkQuotas(transferTask{User: user}, tasks)
+	assert.Nil(err)
+
+	// a client's quota is shared by the users it represents (test-client may
+	// have 2 transfers in progress and request 1 GB per day)
+	viaClient := transferTask{
+		Id:          uuid.New(),
+		User:        other,
+		Client:      "test-client",
+		StartTime:   time.Now(),
+		PayloadSize: 0.5,
+		Status:      TransferStatus{Code: TransferStatusActive},
+	}
+	tasks[viaClient.Id] = viaClient
+	err = checkQuotas(transferTask{User: user, Client: "test-client"}, tasks)
+	assert.Nil(err)
+
+	viaClient.Id = uuid.New()
+	tasks[viaClient.Id] = viaClient
+	err = checkQuotas(transferTask{User: user, Client: "test-client"}, tasks)
+	assert.IsType(&QuotaExceededError{}, err)
+	assert.Contains(err.Error(), "client test-client")
+
+	// completed transfers don't count against concurrent transfer limits, and
+	// failed or day-old transfers don't count against daily payload limits
+	viaClient.Status.Code = TransferStatusFailed
+	tasks[viaClient.Id] = viaClient
+	err = checkQuotas(transferTask{User: user, Client: "test-client"}, tasks)
+	assert.Nil(err)
+	viaClient.Id = uuid.New()
+	viaClient.Status.Code = TransferStatusSucceeded
+	viaClient.StartTime = time.Now().Add(-25 * time.Hour)
+	tasks[viaClient.Id] = viaClient
+	err = checkQuotas(transferTask{User: user, Client: "test-client"}, tasks)
+	assert.Nil(err)
+
+	// a transfer can't be created once a daily payload limit is reached
+	viaClient.Id = uuid.New()
+	viaClient.StartTime = time.Now()
+	tasks[viaClient.Id] = viaClient
+	err = checkQuotas(transferTask{User: user, Client: "test-client"}, tasks)
+	assert.IsType(&QuotaExceededError{}, err)
+	assert.Contains(err.Error(), "requested in the past day")
+
+	// a resolved payload is checked against the payloads of all other tasks,
+	// including those resolved after the task was created
+	defer recordPayloads(nil)
+	config.Service.Quotas.Default = defaultQuota
+	config.Service.Quotas.Default.MaxDailyPayloadSize = 1
+	active.PayloadSize = 0.25
+	recordPayloads(map[uuid.UUID]transferTask{active.Id: active})
+	first := transferTask{
+		Id:          uuid.New(),
+		User:        user,
+		StartTime:   time.Now(),
+		PayloadSize: 0.5,
+	}
+	second := first
+	second.Id = uuid.New()
+	assert.Nil(reservePayload(first))
+	err = reservePayload(second)
+	assert.IsType(&QuotaExceededError{}, err)
+	assert.Contains(err.Error(), "requested in the past day")
+
+	// ...but not against its own earlier reservation
+	assert.Nil(reservePayload(first))
+}
+
+// tests the generation of checksum files for the files delivered by a transfer
+func TestWriteChecksumFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id:                uuid.New(),
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dest",
+		Subtasks: []transferSubtask{
+			{
+				Source: "test-source",
+				Descriptors: []any{
+					map[string]any{"id": "file1", "name": "file1", "path": "dir1/file1.dat",
+						"hash": "d91f97974d06563cab48d4d43a17e08a"},
+					map[string]any{"id": "file2", "name": "file2", "path": "dir 2/file2.dat",
+						"hash": "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+					map[string]any{"id": "file3", "name": "file3", "path": "dir3/file3.dat"}, // no hash
+					map[string]any{"id": "file4", "name": "file4", "path": "dir4/file4.dat",
+						"hash": "a91f9e974d0e563cab48d4d43a17e08a"}, // skipped
+					map[string]any{"id": "file5", "name": "file5", "path": "dir5/file5.dat",
+						"hash": "b91f9e974d0e563cab48d4d43a17e08a"}, // corrupt
+				},
+				RenamedPaths: map[string]string{"dir 2/file2.dat": "dir_2/file2.dat"},
+				SkippedFiles: map[string]string{"file4": "source error"},
+				CorruptFiles: map[string]string{"file5": "checksum mismatch"},
+			},
+		},
+	}
+
+	// files are written for each hash algorithm, listing delivered paths
+	fileXfers, err := task.writeChecksumFiles()
+	assert.Nil(err)
+	defer task.removeChecksumFiles()
+	assert.Len(fileXfers, 2)
+	assert.Equal(filepath.Join("dest", "md5sums.txt"), fileXfers[0].DestinationPath)
+	assert.Equal(filepath.Join("dest", "sha256sums.txt"), fileXfers[1].DestinationPath)
+	content, err := os.ReadFile(task.ChecksumFiles["md5"])
+	assert.Nil(err)
+	assert.Equal("d91f97974d06563cab48d4d43a17e08a  dir1/file1.dat\n", string(content))
+	content, err = os.ReadFile(task.ChecksumFiles["sha256"])
+	assert.Nil(err)
+	assert.Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  dir_2/file2.dat\n",
+		string(content))
+
+	// awkward paths are escaped as sha256sum et al expect
+	assert.Equal("\\abc  dir\\\\file\\n.dat", checksumLine("abc", "dir\\file\n.dat"))
+
+	// no checksums are listed for tasks that skip them
+	task.SkipChecksums = true
+	fileXfers, err = task.writeChecksumFiles()
+	assert.Nil(err)
+	assert.Empty(fileXfers)
+	assert.Empty(task.ChecksumFiles)
+}
+
+// tests that requested files deleted from a source database are dropped from
+// a payload and reported in its manifest
+func TestMissingFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	notFound := databases.ResourcesNotFoundError{
+		Database:    "test-source",
+		ResourceIds: []string{"file2"},
+	}
+	assert.Equal([]string{"file2"}, missingResourceIds(notFound))
+	assert.Equal([]string{"file2"}, missingResourceIds(&notFound))
+	assert.Nil(missingResourceIds(fmt.Errorf("something else went wrong")))
+	assert.Equal([]string{"file1", "file3"},
+		withoutMissingIds([]string{"file1", "file2", "file3"}, []string{"file2"}))
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:      "test-source",
+		Destination: "test-destination",
+		FileIds:     []string{"file1", "file2", "file3"},
+	}
+	err := task.resolve()
+	assert.Nil(err)
+	task.MissingFiles = []string{"file3"} // (as if deleted before resolution)
+	task.Subtasks = []transferSubtask{
+		{
+			TaskId: task.Id,
+			Descriptors: []any{
+				task.fileDescriptors[0],
+				task.fileDescriptors[1],
+			},
+		},
+	}
+	task.Subtasks[0].dropMissingFiles([]string{"file1"})
+	assert.Len(task.Subtasks[0].Descriptors, 1)
+	assert.Equal([]string{"file1"}, task.Subtasks[0].MissingFiles)
+
+	manifest, err := task.createManifest()
+	assert.Nil(err)
+	missing := manifest.Descriptor()["missing_files"].([]any)
+	assert.Len(missing, 2)
+	assert.Equal("file1", missing[0].(map[string]any)["id"])
+	assert.Equal(missingAtSource, missing[0].(map[string]any)["reason"])
+	assert.Equal("file3", missing[1].(map[string]any)["id"])
+	assert.Contains(task.warnings(), "2 file(s) missing at source and not delivered")
+}
+
+// tests that the sources of large payloads are probed before staging begins
+func TestProbeSources(t *testing.T) {
+	assert := assert.New(t)
+
+	descriptors := make([]any, 25)
+	for i := range descriptors {
+		descriptors[i] = i
+	}
+	sample := sampleDescriptors(descriptors, 5)
+	assert.Equal([]any{0, 6, 12, 18, 24}, sample)
+	assert.Len(sampleDescriptors(descriptors[:3], 5), 3)
+
+	task := transferTask{
+		Id:          uuid.New(),
+		Source:      "test-source",
+		Destination: "test-destination",
+		PayloadSize: 2,
+	}
+	assert.False(task.needsProbe())
+	config.Service.ProbeMinPayloadSize = 1
+	defer func() { config.Service.ProbeMinPayloadSize = 0 }()
+	assert.True(task.needsProbe())
+
+	task.Subtasks = []transferSubtask{
+		{
+			Source:         "test-source",
+			SourceEndpoint: "source-endpoint",
+			Descriptors:    []any{map[string]any{"id": "file1", "path": "dir/file1.txt"}},
+		},
+	}
+	assert.Nil(task.probeSources())
+
+	// a source endpoint that can't see a file fails the probe
+	task.Subtasks[0].Descriptors = append(task.Subtasks[0].Descriptors,
+		map[string]any{"id": "no-such-file", "path": "dir/no-such-file.txt"})
+	assert.IsType(&SourceUnavailableError{}, task.probeSources())
+
+	task.Subtasks[0].SourceEndpoint = "no-such-endpoint"
+	assert.IsType(&SourceUnavailableError{}, task.probeSources())
+}
+
+// tests the recording of the time spent by tasks in each pipeline stage
+func TestStageTimings(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Now().Add(-time.Hour)
+	task := transferTask{
+		StartTime:      start,
+		ProcessingTime: start.Add(time.Minute),
+	}
+
+	// a staging task has finished creation and is partway through staging
+	timeline := task.timeline()
+	assert.Equal(2, len(timeline))
+	assert.Equal("create", timeline[0].Stage)
+	assert.Equal(time.Minute, timeline[0].Duration())
+	assert.Equal("staging", timeline[1].Stage)
+	assert.True(timeline[1].End.IsZero())
+	assert.InDelta(59*60, timeline[1].Duration().Seconds(), 1)
+
+	// a task that fails during transfer ends in its transfer stage
+	task.StagingEndTime = start.Add(31 * time.Minute)
+	task.CompletionTime = start.Add(40 * time.Minute)
+	timeline = task.timeline()
+	assert.Equal(3, len(timeline))
+	assert.Equal("transfer", timeline[2].Stage)
+	assert.Equal(9*time.Minute, timeline[2].Duration())
+
+	// a successful task passes through all stages
+	task.FinalizeTime = start.Add(50 * time.Minute)
+	task.CompletionTime = start.Add(52 * time.Minute)
+	timeline = task.timeline()
+	assert.Equal(4, len(timeline))
+	durations := []time.Duration{time.Minute, 30 * time.Minute, 19 * time.Minute, 2 * time.Minute}
+	for i, entry := range timeline {
+		assert.Equal(timelineStages[i], entry.Stage)
+		assert.Equal(durations[i], entry.Duration())
+	}
+
+	// timings are aggregated over successful tasks
+	before := StageTimings()
+	recordStageTimings(timeline)
+	task.FinalizeTime = start.Add(48 * time.Minute)
+	recordStageTimings(task.timeline())
+	after := StageTimings()
+	assert.Equal(len(timelineStages), len(after))
+	assert.Equal("staging", after[1].Stage)
+	assert.Equal(before[1].NumTasks+2, after[1].NumTasks)
+	assert.Equal(before[1].Total+60*time.Minute, after[1].Total)
+	assert.Equal(before[3].Total+6*time.Minute, after[3].Total)
+	assert.True(after[3].Max >= 4*time.Minute)
+	if before[3].NumTasks == 0 {
+		assert.Equal(3*time.Minute, after[3].Mean())
+	}
+
+	// the timeline appears in the task's summary
+	assert.Equal(4, len(task.Summary().Timeline))
+}
+
+// tests whether a completed task records spans for itself and its stages
+// within the trace of the request that created it
+func TestRecordTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	var spans []map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]any `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.Nil(json.NewDecoder(r.Body).Decode(&request))
+		spans = append(spans, request.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}))
+	defer collector.Close()
+	config.Service.Tracing.OTLPEndpoint = collector.URL
+	defer func() { config.Service.Tracing.OTLPEndpoint = "" }()
+	assert.Nil(tracing.Init())
+
+	parent := tracing.NewSpanContext(tracing.SpanContext{})
+	start := time.Now().Add(-time.Hour)
+	task := transferTask{
+		Id:             uuid.New(),
+		Source:         "jdp",
+		Destination:    "kbase",
+		Status:         TransferStatus{Code: TransferStatusSucceeded},
+		StartTime:      start,
+		ProcessingTime: start.Add(time.Minute),
+		StagingEndTime: start.Add(30 * time.Minute),
+		FinalizeTime:   start.Add(50 * time.Minute),
+		CompletionTime: start.Add(52 * time.Minute),
+		Trace:          tracing.NewSpanContext(parent),
+		TraceParentId:  parent.SpanId,
+	}
+	task.recordTrace()
+
+	// a task without a trace (created before tracing was introduced) isn't traced
+	untraced := task
+	untraced.Trace = tracing.SpanContext{}
+	untraced.recordTrace()
+
+	assert.Nil(tracing.Finalize())
+	assert.Equal(5, len(spans))
+	assert.Equal("transfer", spans[0]["name"])
+	assert.Equal(parent.TraceId.String(), spans[0]["traceId"])
+	assert.Equal(parent.SpanId.String(), spans[0]["parentSpanId"])
+	for i, stage := range timelineStages {
+		assert.Equal(stage, spans[i+1]["name"])
+		assert.Equal(parent.TraceId.String(), spans[i+1]["traceId"])
+		assert.Equal(task.Trace.SpanId.String(), spans[i+1]["parentSpanId"])
+	}
+}
+
+// tests the resubmission of manifests whose transfers were lost when the
+// service restarted
+func TestRestartFinalization(t *testing.T) {
+	assert := assert.New(t)
+
+	task := transferTask{
+		Id: uuid.New(),
+		User: auth.User{
+			Name:  "Joe-bob",
+			Orcid: "1234-5678-9012-3456",
+		},
+		Source:            "test-source",
+		Destination:       "test-destination",
+		DestinationFolder: "dts-restart",
+		FileIds:           []string{"file1"},
+		Status:            TransferStatus{Code: TransferStatusFinalizing},
+		Manifest:          uuid.NullUUID{UUID: uuid.New(), Valid: true}, // lost transfer
+		Subtasks: []transferSubtask{
+			{
+				Source:         "test-source",
+				Destination:    "test-destination",
+				SourceEndpoint: "source-endpoint",
+				Descriptors: []any{
+					map[string]any{"id": "file1", "name": "file1.dat", "path": "dir1/file1.dat",
+						"hash": "d91f97974d06563cab48d4d43a17e08a"},
+				},
+				TransferStatus: TransferStatus{Code: TransferStatusSucceeded},
+			},
+		},
+	}
+
+	// without a restart, a lost manifest transfer fails the task
+	lostId := task.Manifest.UUID
+	assert.NotNil(task.checkManifest())
+
+	// after a restart, the manifest is regenerated and resubmitted
+	task.interruptManifest()
+	assert.True(task.ManifestInterrupted)
+	err := task.checkManifest()
+	assert.Nil(err)
+	assert.NotEqual(lostId, task.Manifest.UUID)
+	assert.Equal(1, task.ManifestResubmissions)
+	assert.False(task.ManifestInterrupted)
+	assert.FileExists(task.ManifestFile)
+
+	assert.Eventually(func() bool {
+		err := task.checkManifest()
+		return err == nil && task.Completed()
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(TransferStatusSucceeded, task.Status.Code)
+
+	// a task that has exhausted its resubmissions fails
+	task.Status.Code = TransferStatusFinalizing
+	task.Manifest = uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	task.ManifestResubmissions = maxManifestResubmissions
+	task.interruptManifest()
+	assert.NotNil(task.checkManifest())
+}
+
+// tests the delivery of signed callback events for changes in task status
+func TestCallbacks(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := config.Service.CallbackSecret
+	retryInterval := callbackRetryInterval
+	config.Service.CallbackSecret = "callback-secret"
+	config.Service.AllowPrivateWebhooks = true // (the receiver is local)
+	callbackRetryInterval = time.Millisecond
+	defer func() {
+		config.Service.CallbackSecret = secret
+		config.Service.AllowPrivateWebhooks = false
+		callbackRetryInterval = retryInterval
+	}()
+
+	// the receiver rejects the first attempt to deliver each event
+	attempts := make(map[string]int)
+	received := make(chan CallbackEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(webhooks.Signature(body, "callback-secret"), r.Header.Get("X-DTS-Signature"))
+		event := r.Header.Get("X-DTS-Event")
+		attempts[event]++
+		if attempts[event] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var callbackEvent CallbackEvent
+		assert.Nil(json.Unmarshal(body, &callbackEvent))
+		received <- callbackEvent
+	}))
+	defer server.Close()
+
+	callbacks := make(chan callbackDelivery, 10)
+	go deliverCallbacks(callbacks)
+	defer close(callbacks)
+
+	task := transferTask{
+		Id:          uuid.New(),
+		CallbackURL: server.URL,
+		Status:      TransferStatus{Code: TransferStatusActive, NumFiles: 2},
+	}
+	task.queueCallback(callbacks)
+	task.Status.Code = TransferStatusUnknown // no event for this one
+	task.queueCallback(callbacks)
+	task.Canceled = true
+	task.Status.Code = TransferStatusFailed
+	task.queueCallback(callbacks)
+
+	for _, expected := range []string{"active", "canceled"} {
+		select {
+		case event := <-received:
+			assert.Equal(task.Id, event.Id)
+			assert.Equal(expected, event.Event)
+			assert.Equal(2, event.NumFiles)
+		case <-time.After(5 * time.Second):
+			assert.Fail("callback event not delivered", expected)
+		}
+	}
+
+	// a URL that doesn't respond doesn't hold up events for other URLs
+	unblock := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer slowServer.Close()
+	defer close(unblock)
+	slowTask := transferTask{
+		Id:          uuid.New(),
+		CallbackURL: slowServer.URL,
+		Status:      TransferStatus{Code: TransferStatusActive},
+	}
+	slowTask.queueCallback(callbacks)
+	succeeded := task
+	succeeded.Status.Code = TransferStatusSucceeded
+	succeeded.queueCallback(callbacks)
+	select {
+	case event := <-received:
+		assert.Equal(task.Id, event.Id)
+		assert.Equal("succeeded", event.Event)
+	case <-time.After(5 * time.Second):
+		assert.Fail("callback event held up by an unresponsive URL")
+	}
+
+	// events aren't delivered to private addresses unless they're allowed
+	config.Service.AllowPrivateWebhooks = false
+	err := postCallback(callbackDelivery{URL: server.URL, Event: CallbackEvent{Id: task.Id}})
+	assert.IsType(&webhooks.InvalidURLError{}, err)
+
+	// expired tasks notify their clients that they've expired
+	task.Expired = true
+	assert.Equal("expired", task.callbackEvent())
+
+	// tasks without callback URLs queue no events
+	task.CallbackURL = ""
+	task.queueCallback(callbacks)
+	assert.Empty(callbacks)
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/tracing"
+)
+
+// Each transfer task passes through four stages:
+//
+//  1. create: from the task's request to the start of work on it (including
+//     any time spent in the queue or waiting for dependencies)
+//  2. staging: from the start of work until all of its files are staged
+//  3. transfer: from the end of staging until all of its files have arrived
+//  4. finalize: from the arrival of its files until its manifest is delivered
+//
+// A task records the time at which it enters each stage, and the time spent
+// in each stage by successful tasks is aggregated in memory so administrators
+// can see where transfers spend their time. When a task completes, its stages
+// are also recorded as spans within the trace of the request that created it.
+
+// names of the stages through which a task passes, in order
+var timelineStages = []string{"create", "staging", "transfer", "finalize"}
+
+// this type describes the time spent by a task in one of its stages
+type TimelineEntry struct {
+	// the name of the stage (create, staging, transfer, or finalize)
+	Stage string
+	// the time at which the task entered the stage
+	Start time.Time
+	// the time at which the task left the stage (zero if it hasn't)
+	End time.Time
+}
+
+// returns the time spent by a task in the stage, or the time it has spent so
+// far if it hasn't left the stage
+func (entry TimelineEntry) Duration() time.Duration {
+	if entry.End.IsZero() {
+		return time.Since(entry.Start)
+	}
+	return entry.End.Sub(entry.Start)
+}
+
+// this type aggregates the time spent by successful tasks in a stage
+type StageTiming struct {
+	// the name of the stage (create, staging, transfer, or finalize)
+	Stage string
+	// the number of tasks that passed through the stage
+	NumTasks int
+	// the total time spent in the stage by those tasks
+	Total time.Duration
+	// the longest time spent in the stage by a single task
+	Max time.Duration
+}
+
+// returns the average time spent in the stage by a task, or 0 if no tasks
+// have passed through it
+func (timing StageTiming) Mean() time.Duration {
+	if timing.NumTasks == 0 {
+		return 0
+	}
+	return timing.Total / time.Duration(timing.NumTasks)
+}
+
+// Returns aggregated timings for the stages of all transfer tasks that have
+// succeeded since the service started, in stage order.
+func StageTimings() []StageTiming {
+	stageTimingMutex.Lock()
+	defer stageTimingMutex.Unlock()
+	timings := make([]StageTiming, len(timelineStages))
+	for i, stage := range timelineStages {
+		timings[i] = stageTimings[stage]
+		timings[i].Stage = stage
+	}
+	return timings
+}
+
+//-----------
+// Internals
+//-----------
+
+var stageTimings = make(map[string]StageTiming)
+var stageTimingMutex sync.Mutex
+
+// returns the timeline for the task: an entry for each stage it has entered,
+// in order
+func (task transferTask) timeline() []TimelineEntry {
+	boundaries := []time.Time{
+		task.StartTime,
+		task.ProcessingTime,
+		task.StagingEndTime,
+		task.FinalizeTime,
+		task.CompletionTime,
+	}
+	var timeline []TimelineEntry
+	for i, stage := range timelineStages {
+		if boundaries[i].IsZero() {
+			break
+		}
+		entry := TimelineEntry{
+			Stage: stage,
+			Start: boundaries[i],
+			End:   boundaries[i+1],
+		}
+		if entry.End.IsZero() { // the task is in this stage, or ended in it
+			entry.End = task.CompletionTime
+			timeline = append(timeline, entry)
+			break
+		}
+		timeline = append(timeline, entry)
+	}
+	return timeline
+}
+
+// adds the time spent by a successful task in each of its stages to the
+// aggregated stage timings
+func recordStageTimings(timeline []TimelineEntry) {
+	stageTimingMutex.Lock()
+	defer stageTimingMutex.Unlock()
+	for _, entry := range timeline {
+		if entry.End.IsZero() {
+			continue
+		}
+		duration := entry.Duration()
+		timing := stageTimings[entry.Stage]
+		timing.NumTasks++
+		timing.Total += duration
+		timing.Max = max(timing.Max, duration)
+		stageTimings[entry.Stage] = timing
+	}
+}
+
+// records spans for a completed task and each of the stages it entered, as
+// part of the trace of the request that created it (tasks created before
+// tracing was introduced aren't traced)
+func (task transferTask) recordTrace() {
+	if !task.Trace.IsValid() {
+		return
+	}
+	span := tracing.Span{
+		Name:      "transfer",
+		Kind:      tracing.SpanKindInternal,
+		Context:   task.Trace,
+		ParentId:  task.TraceParentId,
+		StartTime: task.StartTime,
+		EndTime:   task.CompletionTime,
+		Attributes: map[string]any{
+			"dts.task_id":      task.Id.String(),
+			"dts.source":       task.Source,
+			"dts.destination":  task.Destination,
+			"dts.num_files":    len(task.FileIds),
+			"dts.payload_size": task.PayloadSize,
+			"dts.expired":      task.Expired,
+		},
+	}
+	if task.Status.Code != TransferStatusSucceeded {
+		span.Error = task.Status.Message
+		if span.Error == "" {
+			span.Error = "transfer failed"
+		}
+	}
+	tracing.Record(span)
+	for _, entry := range task.timeline() {
+		tracing.Record(tracing.Span{
+			Name:      entry.Stage,
+			Kind:      tracing.SpanKindInternal,
+			Context:   tracing.NewSpanContext(task.Trace),
+			ParentId:  task.Trace.SpanId,
+			StartTime: entry.Start,
+			EndTime:   entry.End,
+		})
+	}
+}
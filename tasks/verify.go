@@ -0,0 +1,201 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kbase/dts/auth"
+	"github.com/kbase/dts/config"
+	"github.com/kbase/dts/databases"
+	"github.com/kbase/dts/endpoints"
+	"github.com/kbase/dts/manifests"
+)
+
+// results of checksum verification for a delivered file
+const (
+	ChecksumVerified   = "verified"   // checksum matches the one in the manifest
+	ChecksumMismatch   = "mismatch"   // checksum differs from the one in the manifest
+	ChecksumUnverified = "unverified" // checksum couldn't be computed or compared
+)
+
+// the verification status of a single file delivered by a transfer
+type FileVerification struct {
+	// the file's ID in the source database
+	Id string `json:"id"`
+	// the file's path relative to the destination endpoint's root
+	Path string `json:"path"`
+	// true if the file is present at the destination
+	Present bool `json:"present"`
+	// the outcome of checksum verification (see above)
+	Checksum string `json:"checksum"`
+}
+
+// a report on the state of the files delivered by a completed transfer
+type VerificationReport struct {
+	// transfer ID
+	Id uuid.UUID `json:"id"`
+	// name of destination database (or custom destination spec)
+	Destination string `json:"destination"`
+	// folder (relative to the destination endpoint's root) holding the files
+	DestinationFolder string `json:"destination_folder"`
+	// time at which the verification was performed
+	Time time.Time `json:"time"`
+	// statistics
+	NumFiles      int `json:"num_files"`
+	NumMissing    int `json:"num_missing"`
+	NumMismatched int `json:"num_mismatched"`
+	NumUnverified int `json:"num_unverified"`
+	// verification statuses for individual files
+	Files []FileVerification `json:"files"`
+}
+
+// returns true if all delivered files are present and none has a mismatched
+// checksum
+func (r VerificationReport) Passed() bool {
+	return r.NumMissing == 0 && r.NumMismatched == 0
+}
+
+// Re-verifies the presence and checksums of the files delivered by the
+// completed transfer with the given ID, using its archived manifest to
+// determine which files should be present at its destination. Checksums can
+// only be verified at endpoints that compute them.
+func Verify(taskId uuid.UUID) (VerificationReport, error) {
+	entry, err := manifests.Fetch(taskId)
+	if err != nil {
+		return VerificationReport{}, err
+	}
+	var manifest struct {
+		Resources    []map[string]any `json:"resources"`
+		RenamedPaths []struct {
+			Sanitized string `json:"sanitized"`
+		} `json:"renamed_paths"`
+	}
+	if err := json.Unmarshal(entry.Manifest, &manifest); err != nil {
+		return VerificationReport{}, fmt.Errorf("reading archived manifest: %s", err.Error())
+	}
+
+	// reconstruct the transfer's destination folder and endpoint
+	task := transferTask{
+		Id:          entry.Id,
+		Destination: entry.Destination,
+		User:        auth.User{Orcid: entry.Orcid},
+	}
+	folder, err := determineDestinationFolder(task)
+	if err != nil {
+		return VerificationReport{}, err
+	}
+	destination, err := resolveDestinationEndpoint(entry.Destination)
+	if err != nil {
+		return VerificationReport{}, err
+	}
+
+	report := VerificationReport{
+		Id:                entry.Id,
+		Destination:       entry.Destination,
+		DestinationFolder: folder,
+		Time:              time.Now(),
+		Files:             make([]FileVerification, 0),
+	}
+	// resource paths that weren't sanitized may be encoded by the source
+	sanitized := make(map[string]bool)
+	for _, renamed := range manifest.RenamedPaths {
+		sanitized[renamed.Sanitized] = true
+	}
+	source, sourceErr := databases.NewDatabase(entry.Source)
+
+	for _, resource := range manifest.Resources {
+		path, isFile := resource["path"].(string)
+		if !isFile { // in-line data
+			continue
+		}
+		if stub, isStub := resource["stub"].(string); isStub { // metadata-only transfer
+			path = stub
+			resource = map[string]any{"id": resource["id"]} // (no checksum to verify)
+		} else if !sanitized[path] && sourceErr == nil {
+			if path, err = databases.EndpointPath(source, path); err != nil {
+				return VerificationReport{}, err
+			}
+		}
+		id, _ := resource["id"].(string)
+		file, err := verifyFile(destination, filepath.Join(folder, path), resource)
+		if err != nil {
+			return VerificationReport{}, err
+		}
+		file.Id = id
+		report.Files = append(report.Files, file)
+		report.NumFiles++
+		if !file.Present {
+			report.NumMissing++
+		} else if file.Checksum == ChecksumMismatch {
+			report.NumMismatched++
+		} else if file.Checksum == ChecksumUnverified {
+			report.NumUnverified++
+		}
+	}
+	return report, nil
+}
+
+// checks the presence and checksum of the file with the given path at the given
+// destination endpoint against its manifest resource
+func verifyFile(destination endpoints.Endpoint, path string, resource map[string]any) (FileVerification, error) {
+	file := FileVerification{
+		Path:     path,
+		Checksum: ChecksumUnverified,
+	}
+	id, _ := resource["id"].(string)
+	present, err := destination.FilesStaged([]any{map[string]any{"id": id, "path": path}})
+	if err != nil {
+		return file, err
+	}
+	file.Present = present
+	if !present {
+		return file, nil
+	}
+
+	// verify the checksum if we can
+	hash, _ := resource["hash"].(string)
+	recursive, _ := resource["recursive"].(bool)
+	checksummer, canChecksum := destination.(endpoints.ChecksummingEndpoint)
+	if hash == "" || recursive || !canChecksum {
+		return file, nil
+	}
+	value, algorithm := parseHash(hash)
+	if !config.HashAlgorithmAllowed(algorithm) {
+		return file, nil
+	}
+	checksum, err := checksummer.Checksum(path, algorithm)
+	if err != nil {
+		return file, nil // can't compute it
+	}
+	if checksum == value {
+		file.Checksum = ChecksumVerified
+	} else {
+		file.Checksum = ChecksumMismatch
+	}
+	return file, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/config"
+)
+
+// Each time the task manager polls its tasks, it groups those needing updates
+// by pipeline stage (see timing.go) and hands each group to a pool of workers
+// whose size is configured for that stage. Stages are processed one after
+// another, and each task belongs to exactly one stage, so a task is never
+// updated by more than one worker at a time and its steps happen in order.
+
+//-----------
+// Internals
+//-----------
+
+// returns the pipeline stage in which the task is next updated
+func (task transferTask) pipelineStage() string {
+	switch {
+	case len(task.Subtasks) == 0:
+		return "create"
+	case task.Manifest.Valid:
+		return "finalize"
+	case task.StagingEndTime.IsZero():
+		return "staging"
+	default:
+		return "transfer"
+	}
+}
+
+// returns the number of workers configured for the given pipeline stage
+func numStageWorkers(stage string) int {
+	var numWorkers int
+	switch stage {
+	case "create":
+		numWorkers = config.Service.Workers.Create
+	case "staging":
+		numWorkers = config.Service.Workers.Staging
+	case "transfer":
+		numWorkers = config.Service.Workers.Transfer
+	case "finalize":
+		numWorkers = config.Service.Workers.Finalize
+	}
+	return max(numWorkers, 1)
+}
+
+// updates the given tasks stage by stage, using the configured number of
+// workers for each stage, and returns them, oldest first within each stage
+func updateTasks(pending []transferTask) []transferTask {
+	updated := make([]transferTask, 0, len(pending))
+	for _, stage := range timelineStages {
+		var batch []transferTask
+		for _, task := range pending {
+			if task.pipelineStage() == stage {
+				batch = append(batch, task)
+			}
+		}
+		slices.SortFunc(batch, func(a, b transferTask) int {
+			return a.StartTime.Compare(b.StartTime)
+		})
+
+		indices := make(chan int)
+		var wg sync.WaitGroup
+		for range min(numStageWorkers(stage), len(batch)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indices {
+					updateTask(&batch[i])
+				}
+			}()
+		}
+		for i := range batch {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+
+		updated = append(updated, batch...)
+	}
+	return updated
+}
+
+// updates the given task, marking it as failed if the update fails
+func updateTask(task *transferTask) {
+	err := task.Update()
+	if err != nil {
+		// We log task update errors but do not propagate them. All
+		// task errors result in a failed status.
+		task.Status.Code = TransferStatusFailed
+		task.Status.Message = err.Error()
+		task.CompletionTime = time.Now()
+		slog.Error(fmt.Sprintf("Task %s: %s", task.Id.String(), err.Error()))
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"fmt"
+)
+
+// indicates that a traceparent header is malformed
+type InvalidTraceParentError struct {
+	TraceParent string
+}
+
+func (e InvalidTraceParentError) Error() string {
+	return fmt.Sprintf("Invalid traceparent header: %s", e.TraceParent)
+}
+
+// indicates that an OTLP collector rejected exported spans
+type ExportError struct {
+	Endpoint string
+	Status   int
+	Message  string
+}
+
+func (e ExportError) Error() string {
+	return fmt.Sprintf("Couldn't export spans to %s (status %d): %s", e.Endpoint, e.Status, e.Message)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"net/http"
+)
+
+// Returns an HTTP transport that records a client span for each request sent
+// through the given transport (or http.DefaultTransport if it's nil) when
+// tracing is enabled, propagating the span's context to the server in the
+// request's traceparent header. A request whose context carries a span
+// context is traced within that span's trace.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{Next: next}
+}
+
+//-----------
+// Internals
+//-----------
+
+type transport struct {
+	Next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.Next.RoundTrip(req)
+	}
+	ctx, span := Start(req.Context(), req.Method, SpanKindClient)
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", span.Context.TraceParent())
+
+	// record the URL without credentials or query parameters, which can hold
+	// secrets
+	u := *req.URL
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("server.address", req.URL.Hostname())
+	span.SetAttribute("url.full", u.String())
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			span.Error = resp.Status
+		}
+	}
+	span.End()
+	return resp, err
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kbase/dts/config"
+)
+
+// starts exporting spans to the OTLP collector configured in the service's
+// tracing settings, if any (if none, spans are discarded)
+func Init() error {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	if exporter_ != nil || config.Service.Tracing.OTLPEndpoint == "" {
+		return nil
+	}
+	endpoint, err := url.JoinPath(config.Service.Tracing.OTLPEndpoint, "v1", "traces")
+	if err != nil {
+		return err
+	}
+	exporter_ = &exporter{
+		Endpoint:    endpoint,
+		ServiceName: config.Service.Tracing.ServiceName,
+		Client: http.Client{
+			Transport: config.HttpTransport(),
+			Timeout:   exportTimeout,
+		},
+		Stop: make(chan chan error),
+	}
+	go exporter_.run(time.Duration(config.Service.Tracing.ExportInterval) * time.Second)
+	return nil
+}
+
+// stops exporting spans, first exporting any that remain queued
+func Finalize() error {
+	mutex_.Lock()
+	e := exporter_
+	exporter_ = nil
+	mutex_.Unlock()
+	if e == nil {
+		return nil
+	}
+	done := make(chan error)
+	e.Stop <- done
+	return <-done
+}
+
+// returns true if spans are being exported, false if they're discarded
+func Enabled() bool {
+	mutex_.Lock()
+	defer mutex_.Unlock()
+	return exporter_ != nil
+}
+
+// queues the given finished span for export, discarding it if tracing is
+// disabled or its trace isn't sampled (this can be used to record spans for
+// operations that have already happened, like the stages of a completed
+// transfer)
+func Record(span Span) {
+	mutex_.Lock()
+	e := exporter_
+	mutex_.Unlock()
+	if e == nil || !span.Context.IsValid() || span.Context.Unsampled {
+		return
+	}
+	e.enqueue(span)
+}
+
+//-----------
+// Internals
+//-----------
+
+const (
+	// maximum number of spans awaiting export, past which spans are dropped
+	maxQueuedSpans = 4096
+	// maximum number of spans sent to the collector in a single request
+	maxExportedSpans = 512
+	// time allowed for the collector to accept a request
+	exportTimeout = 10 * time.Second
+	// OTLP status codes
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+var exporter_ *exporter
+var mutex_ sync.Mutex
+
+// this type queues finished spans and periodically sends them to an OTLP/HTTP
+// collector, using the JSON encoding of the OTLP protocol
+type exporter struct {
+	// URL to which spans are POSTed
+	Endpoint string
+	// name identifying the service in exported spans
+	ServiceName string
+	// client used to send spans
+	Client http.Client
+	// channel on which the exporter is asked to stop
+	Stop chan chan error
+	// spans awaiting export, and the number dropped because too many were
+	Mutex   sync.Mutex
+	Queue   []Span
+	Dropped int
+}
+
+func (e *exporter) enqueue(span Span) {
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+	if len(e.Queue) >= maxQueuedSpans {
+		e.Dropped++
+		return
+	}
+	e.Queue = append(e.Queue, span)
+}
+
+// exports queued spans at the given interval until asked to stop
+func (e *exporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.export(); err != nil {
+				slog.Warn(err.Error())
+			}
+		case done := <-e.Stop:
+			done <- e.export()
+			return
+		}
+	}
+}
+
+// sends all queued spans to the collector
+func (e *exporter) export() error {
+	e.Mutex.Lock()
+	spans, dropped := e.Queue, e.Dropped
+	e.Queue, e.Dropped = nil, 0
+	e.Mutex.Unlock()
+	if dropped > 0 {
+		slog.Warn(fmt.Sprintf("Dropped %d span(s) awaiting export to %s", dropped, e.Endpoint))
+	}
+	for batch := range slices.Chunk(spans, maxExportedSpans) {
+		if err := e.send(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// POSTs the given spans to the collector
+func (e *exporter) send(spans []Span) error {
+	body, err := json.Marshal(otlpTraces(e.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.Client.Post(e.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &ExportError{
+			Endpoint: e.Endpoint,
+			Status:   resp.StatusCode,
+			Message:  string(message),
+		}
+	}
+	return nil
+}
+
+// types for the JSON encoding of an OTLP trace export request
+// (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding)
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// returns an OTLP export request for the given spans, recorded by the service
+// with the given name
+func otlpTraces(serviceName string, spans []Span) otlpExportRequest {
+	otlpSpans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		otlpSpans[i] = otlpSpan{
+			TraceId:           span.Context.TraceId.String(),
+			SpanId:            span.Context.SpanId.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusOk},
+		}
+		if span.ParentId.IsValid() {
+			otlpSpans[i].ParentSpanId = span.ParentId.String()
+		}
+		if span.Error != "" {
+			otlpSpans[i].Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+	}
+	return otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]any{"service.name": serviceName}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "github.com/kbase/dts"},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// returns OTLP attributes (sorted by key) for the given attribute values
+func otlpAttributes(attributes map[string]any) []otlpAttribute {
+	keys := slices.Sorted(maps.Keys(attributes))
+	otlpAttrs := make([]otlpAttribute, len(keys))
+	for i, key := range keys {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprintf("%v", v)}
+		}
+		otlpAttrs[i] = otlpAttribute{Key: key, Value: value}
+	}
+	return otlpAttrs
+}
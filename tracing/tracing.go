@@ -0,0 +1,241 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// This package records traces of the requests handled by the DTS and of the
+// transfers they create, exporting their spans to an OpenTelemetry collector
+// with OTLP/HTTP. Trace contexts are propagated between services with W3C
+// traceparent headers (https://www.w3.org/TR/trace-context/), so the work done
+// by the DTS for a request (searching databases, staging files, submitting
+// transfers) appears within the trace of the client that made it.
+//
+// The package deliberately implements only what the DTS needs instead of using
+// the OpenTelemetry SDK (go.opentelemetry.io/otel with otlptracehttp and
+// otelhttp). Current releases of the SDK require a newer Go than the DTS builds
+// with, and they bring gRPC, protobuf, and genproto into the vendor tree. The
+// DTS also persists the span contexts of its transfers in its task store across
+// restarts, which calls for its own serializable types anyway. The
+// compatibility surface is kept small and stable: traceparent headers (W3C
+// Trace Context Level 1, without tracestate or baggage), and spans exported in
+// the JSON encoding of OTLP/HTTP (OTLP 1.x), which the OpenTelemetry Collector
+// accepts. Metrics and logs aren't exported. If the DTS needs more than this, it
+// should switch to the SDK rather than grow this package.
+
+// a 16-byte identifier shared by all spans in a trace
+type TraceId [16]byte
+
+// returns the lower-case hexadecimal representation of the trace ID
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// returns true if the trace ID is nonzero, false if not
+func (id TraceId) IsValid() bool {
+	return id != TraceId{}
+}
+
+// an 8-byte identifier for a span within a trace
+type SpanId [8]byte
+
+// returns the lower-case hexadecimal representation of the span ID
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// returns true if the span ID is nonzero, false if not
+func (id SpanId) IsValid() bool {
+	return id != SpanId{}
+}
+
+// the identifying context of a span, which is propagated to its children
+type SpanContext struct {
+	// the trace to which the span belongs
+	TraceId TraceId
+	// the span's identifier
+	SpanId SpanId
+	// set if the trace isn't sampled (its spans are propagated but not
+	// exported), as decided by the service that started it
+	Unsampled bool
+}
+
+// returns true if the span context identifies a span, false if not
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+// returns a W3C traceparent header value that propagates the span context
+// (and whether its trace is sampled), or an empty string if the span context
+// is invalid
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := traceFlagSampled
+	if sc.Unsampled {
+		flags = 0
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceId, sc.SpanId, flags)
+}
+
+// parses the given W3C traceparent header value, returning the span context
+// it propagates or an error if the value is malformed
+func ParseTraceParent(traceParent string) (SpanContext, error) {
+	var sc SpanContext
+	fields := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" ||
+		(fields[0] == "00" && len(fields) != 4) {
+		return sc, &InvalidTraceParentError{TraceParent: traceParent}
+	}
+	traceId, err := hex.DecodeString(fields[1])
+	if err != nil || len(traceId) != len(sc.TraceId) {
+		return sc, &InvalidTraceParentError{TraceParent: traceParent}
+	}
+	spanId, err := hex.DecodeString(fields[2])
+	if err != nil || len(spanId) != len(sc.SpanId) {
+		return sc, &InvalidTraceParentError{TraceParent: traceParent}
+	}
+	flags, err := hex.DecodeString(fields[3])
+	if err != nil || len(flags) != 1 {
+		return sc, &InvalidTraceParentError{TraceParent: traceParent}
+	}
+	copy(sc.TraceId[:], traceId)
+	copy(sc.SpanId[:], spanId)
+	sc.Unsampled = flags[0]&traceFlagSampled == 0
+	if !sc.IsValid() {
+		return SpanContext{}, &InvalidTraceParentError{TraceParent: traceParent}
+	}
+	return sc, nil
+}
+
+// returns a new span context for a child of the span with the given context
+// (sampled only if its parent is), or for the root span of a new (sampled)
+// trace if the given context is invalid
+func NewSpanContext(parent SpanContext) SpanContext {
+	sc := SpanContext{TraceId: parent.TraceId, Unsampled: parent.Unsampled}
+	if !parent.IsValid() {
+		rand.Read(sc.TraceId[:])
+		sc.Unsampled = false
+	}
+	rand.Read(sc.SpanId[:])
+	return sc
+}
+
+// the relationship of a span to the work it describes
+type SpanKind int
+
+const (
+	// an operation internal to the DTS (e.g. a stage of a transfer)
+	SpanKindInternal SpanKind = iota + 1
+	// the handling of a request made to the DTS
+	SpanKindServer
+	// a request made by the DTS to another service
+	SpanKindClient
+)
+
+// a timed operation within a trace
+type Span struct {
+	// the name of the operation
+	Name string
+	// the relationship of the span to the operation
+	Kind SpanKind
+	// the span's identifying context
+	Context SpanContext
+	// the identifier of the span's parent (invalid for a root span)
+	ParentId SpanId
+	// the times at which the operation began and ended
+	StartTime, EndTime time.Time
+	// attributes describing the operation
+	Attributes map[string]any
+	// a message describing the failure of the operation (if it failed)
+	Error string
+}
+
+// starts a span with the given name and kind as a child of the span whose
+// context is carried by the given context (or as the root of a new trace if
+// it carries none), returning a context carrying the new span's context and
+// the span itself, which is exported when it is ended
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		Name:      name,
+		Kind:      kind,
+		Context:   NewSpanContext(parent),
+		ParentId:  parent.SpanId,
+		StartTime: time.Now(),
+	}
+	return ContextWithSpanContext(ctx, span.Context), span
+}
+
+// sets the attribute with the given key to the given value, which should be
+// a string, bool, integer, or floating point number
+func (span *Span) SetAttribute(key string, value any) {
+	if span.Attributes == nil {
+		span.Attributes = make(map[string]any)
+	}
+	span.Attributes[key] = value
+}
+
+// marks the operation described by the span as having failed with the given
+// error
+func (span *Span) RecordError(err error) {
+	if err != nil {
+		span.Error = err.Error()
+	}
+}
+
+// ends the span, queueing it for export
+func (span *Span) End() {
+	span.EndTime = time.Now()
+	Record(*span)
+}
+
+// returns a context derived from the given context that carries the given
+// span context
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// returns the span context carried by the given context, or an invalid span
+// context if it carries none
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+//-----------
+// Internals
+//-----------
+
+// the key under which a span context is stored in a context.Context
+type spanContextKey struct{}
+
+// the W3C trace flag indicating that the caller may have recorded its span
+const traceFlagSampled = 0x01
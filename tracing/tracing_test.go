@@ -0,0 +1,166 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+)
+
+// tests the parsing and formatting of W3C traceparent headers
+func TestTraceParent(t *testing.T) {
+	assert := assert.New(t)
+
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Nil(err)
+	assert.True(sc.IsValid())
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceId.String())
+	assert.Equal("00f067aa0ba902b7", sc.SpanId.String())
+	assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+	assert.False(sc.Unsampled)
+
+	// a caller's decision not to sample its trace is honored and propagated
+	sc, err = ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.Nil(err)
+	assert.True(sc.Unsampled)
+	assert.True(NewSpanContext(sc).Unsampled)
+	assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", sc.TraceParent())
+	assert.False(NewSpanContext(SpanContext{}).Unsampled)
+
+	for _, traceParent := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, err := ParseTraceParent(traceParent)
+		assert.NotNil(err, "Invalid traceparent %s was accepted", traceParent)
+	}
+	assert.Equal("", SpanContext{}.TraceParent())
+}
+
+// tests the starting of root and child spans
+func TestStartSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	// a span without a parent starts a new trace
+	ctx, root := Start(context.Background(), "root", SpanKindServer)
+	assert.True(root.Context.IsValid())
+	assert.False(root.ParentId.IsValid())
+	assert.Equal(root.Context, SpanContextFromContext(ctx))
+
+	// its children belong to the same trace
+	_, child := Start(ctx, "child", SpanKindInternal)
+	assert.Equal(root.Context.TraceId, child.Context.TraceId)
+	assert.Equal(root.Context.SpanId, child.ParentId)
+	assert.NotEqual(root.Context.SpanId, child.Context.SpanId)
+
+	child.RecordError(errors.New("oops"))
+	assert.Equal("oops", child.Error)
+}
+
+// tests the export of spans to an OTLP collector
+func TestExportSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	// set up a collector that gathers exported spans
+	var mutex sync.Mutex
+	var requests []otlpExportRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/traces", r.URL.Path)
+		var request otlpExportRequest
+		assert.Nil(json.NewDecoder(r.Body).Decode(&request))
+		mutex.Lock()
+		requests = append(requests, request)
+		mutex.Unlock()
+	}))
+	defer collector.Close()
+
+	// set up a server whose requests are traced
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	config.Service.Tracing.OTLPEndpoint = collector.URL
+	config.Service.Tracing.ServiceName = "dts-test"
+	config.Service.Tracing.ExportInterval = 60
+	assert.Nil(Init())
+	assert.True(Enabled())
+
+	ctx, span := Start(context.Background(), "GET /api/v1/files", SpanKindServer)
+	client := http.Client{Transport: Transport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/files?token=secret", http.NoBody)
+	resp, err := client.Do(req)
+	assert.Nil(err)
+	resp.Body.Close()
+	span.SetAttribute("http.response.status_code", 200)
+	span.End()
+
+	// spans in traces that aren't sampled aren't exported
+	unsampled := SpanContext{Unsampled: true}
+	unsampled.TraceId[0], unsampled.SpanId[0] = 1, 1
+	_, skipped := Start(ContextWithSpanContext(context.Background(), unsampled), "skipped", SpanKindInternal)
+	skipped.End()
+
+	assert.Nil(Finalize())
+	assert.False(Enabled())
+
+	// the server span and the client span were exported in a single request
+	assert.Len(requests, 1)
+	resourceSpans := requests[0].ResourceSpans[0]
+	assert.Equal("service.name", resourceSpans.Resource.Attributes[0].Key)
+	assert.Equal("dts-test", resourceSpans.Resource.Attributes[0].Value["stringValue"])
+	spans := resourceSpans.ScopeSpans[0].Spans
+	assert.Len(spans, 2)
+	clientSpan, serverSpan := spans[0], spans[1]
+	assert.Equal(span.Context.TraceId.String(), serverSpan.TraceId)
+	assert.Equal(SpanKindServer, serverSpan.Kind)
+	assert.Equal("", serverSpan.ParentSpanId)
+	assert.Equal(SpanKindClient, clientSpan.Kind)
+	assert.Equal(serverSpan.TraceId, clientSpan.TraceId)
+	assert.Equal(serverSpan.SpanId, clientSpan.ParentSpanId)
+	assert.Equal("00-"+clientSpan.TraceId+"-"+clientSpan.SpanId+"-01", traceParent)
+	for _, attribute := range clientSpan.Attributes {
+		if attribute.Key == "url.full" {
+			assert.Equal(server.URL+"/files", attribute.Value["stringValue"])
+		}
+	}
+
+	// spans recorded while tracing is disabled are discarded
+	Record(*span)
+	assert.Nil(Finalize())
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package units formats file sizes and durations for people, so that status
+// messages, errors, and logs read consistently (e.g. "1.2 TiB", "3h 12m").
+// Responses meant for programs should carry raw numeric values alongside any
+// such text.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// number of bytes in a gigabyte as the DTS reckons payload sizes
+const BytesPerGigabyte = 1024 * 1024 * 1024
+
+// number of bytes in a megabyte, as used for reporting transfer rates
+const BytesPerMegabyte = 1024 * 1024
+
+// binary prefixes for sizes
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// Formats the given number of bytes using binary prefixes, with one decimal
+// place for sizes of 1 KiB or more (e.g. "512 B", "1.2 TiB").
+func FormatBytes(n int64) string {
+	if n < 0 {
+		return "-" + FormatBytes(-n)
+	}
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n)
+	unit := 0
+	for size >= 1024 && unit < len(sizeUnits)-1 {
+		size /= 1024
+		unit++
+	}
+	// don't round up to a value that belongs to the next unit ("1024.0 KiB")
+	if math.Round(size*10)/10 >= 1024 && unit < len(sizeUnits)-1 {
+		size /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", size, sizeUnits[unit])
+}
+
+// Formats the given size in gigabytes (as used for payload sizes and disk
+// space) using binary prefixes.
+func FormatGigabytes(gigabytes float64) string {
+	return FormatBytes(GigabytesToBytes(gigabytes))
+}
+
+// Converts the given size in gigabytes to bytes.
+func GigabytesToBytes(gigabytes float64) int64 {
+	return int64(math.Round(gigabytes * BytesPerGigabyte))
+}
+
+// Converts the given number of bytes (or rate in bytes per second) to
+// megabytes (or megabytes per second), rounded to two decimal places.
+func BytesToMegabytes(bytes float64) float64 {
+	return math.Round(bytes/BytesPerMegabyte*100) / 100
+}
+
+// Formats the given duration using its two largest nonzero units of days,
+// hours, minutes, and seconds (e.g. "3h 12m", "2d 4h", "45s"). Durations under
+// a second are formatted as "<1s".
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+	if d < time.Second {
+		return "<1s"
+	}
+	seconds := int64(d.Round(time.Second) / time.Second)
+	parts := []struct {
+		value  int64
+		suffix string
+	}{
+		{seconds / 86400, "d"},
+		{seconds % 86400 / 3600, "h"},
+		{seconds % 3600 / 60, "m"},
+		{seconds % 60, "s"},
+	}
+	var fields []string
+	for _, part := range parts {
+		if part.value > 0 {
+			fields = append(fields, fmt.Sprintf("%d%s", part.value, part.suffix))
+		} else if len(fields) > 0 { // don't skip a zero unit between two others
+			break
+		}
+		if len(fields) == 2 {
+			break
+		}
+	}
+	return strings.Join(fields, " ")
+}
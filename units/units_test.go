@@ -0,0 +1,65 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package units
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("0 B", FormatBytes(0))
+	assert.Equal("512 B", FormatBytes(512))
+	assert.Equal("1.0 KiB", FormatBytes(1024))
+	assert.Equal("1.5 MiB", FormatBytes(3*512*1024))
+	assert.Equal("1.2 TiB", FormatBytes(1300000000000))
+	assert.Equal("1.0 MiB", FormatBytes(1024*1024-1))
+	assert.Equal("-2.0 KiB", FormatBytes(-2048))
+}
+
+func TestFormatGigabytes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("2.0 GiB", FormatGigabytes(2))
+	assert.Equal("512.0 MiB", FormatGigabytes(0.5))
+	assert.Equal(int64(BytesPerGigabyte/4), GigabytesToBytes(0.25))
+}
+
+func TestBytesToMegabytes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0.0, BytesToMegabytes(0))
+	assert.Equal(2.0, BytesToMegabytes(2*BytesPerMegabyte))
+	assert.Equal(0.5, BytesToMegabytes(512*1024))
+	assert.Equal(1.33, BytesToMegabytes(1.333*BytesPerMegabyte))
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("<1s", FormatDuration(300*time.Millisecond))
+	assert.Equal("45s", FormatDuration(45*time.Second))
+	assert.Equal("1m 5s", FormatDuration(65*time.Second))
+	assert.Equal("3h 12m", FormatDuration(3*time.Hour+12*time.Minute+30*time.Second))
+	assert.Equal("2d 4h", FormatDuration(52*time.Hour+3*time.Minute))
+	assert.Equal("1h", FormatDuration(time.Hour+20*time.Second))
+}
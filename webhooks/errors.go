@@ -0,0 +1,46 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package webhooks
+
+import (
+	"fmt"
+)
+
+// indicates that a webhook URL can't be used, either because it's malformed
+// or because it refers to a private or local address
+type InvalidURLError struct {
+	URL, Reason string
+}
+
+func (e InvalidURLError) Error() string {
+	return fmt.Sprintf("Invalid webhook URL %s: %s", e.URL, e.Reason)
+}
+
+// indicates that a webhook responded to a request with an unsuccessful status
+type StatusError struct {
+	URL    string
+	Status int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Webhook %s responded with status %d", e.URL, e.Status)
+}
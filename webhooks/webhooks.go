@@ -0,0 +1,154 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kbase/dts/config"
+)
+
+// This package delivers webhook requests: JSON bodies POSTed to URLs supplied
+// by users (e.g. with transfer requests or saved searches). Each request is
+// signed with the service's callback secret: its X-DTS-Signature header holds
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of the request body, so
+// receivers can verify that it comes from the DTS. Because the URLs come from
+// users, requests are sent only to public addresses (unless the service allows
+// private ones), and redirects are not followed.
+
+// returns the signature for the given request body, computed with the given
+// secret
+func Signature(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// returns an error if the given URL can't be used for a webhook: it must be an
+// http or https URL whose host isn't a private or local address (unless the
+// service allows them)
+func ValidateURL(webhookUrl string) error {
+	u, err := url.Parse(webhookUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return &InvalidURLError{URL: webhookUrl, Reason: "not an absolute http or https URL"}
+	}
+	if config.Service.AllowPrivateWebhooks {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &InvalidURLError{URL: webhookUrl, Reason: "local hosts are not allowed"}
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublic(ip) {
+		return &InvalidURLError{URL: webhookUrl, Reason: "private addresses are not allowed"}
+	}
+	return nil
+}
+
+// POSTs the given JSON body, signed with the service's callback secret, to the
+// given webhook URL along with the given additional headers
+func Post(webhookUrl string, body []byte, headers map[string]string) error {
+	if err := ValidateURL(webhookUrl); err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-DTS-Signature", Signature(body, config.Service.CallbackSecret))
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := client().Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return &StatusError{URL: webhookUrl, Status: response.StatusCode}
+	}
+	return nil
+}
+
+//-----------
+// Internals
+//-----------
+
+// the time allowed for a webhook to respond to a request
+var timeout = 30 * time.Second
+
+// returns an HTTP client that connects only to public addresses (unless the
+// service allows private ones), checking the addresses to which host names
+// resolve at connection time, and that doesn't follow redirects
+func client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !config.Service.AllowPrivateWebhooks && (ip == nil || !isPublic(ip)) {
+				return &InvalidURLError{URL: address, Reason: "private addresses are not allowed"}
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	transport.Proxy = nil // a proxy would connect on our behalf, unchecked
+	transport.TLSClientConfig = config.TLSConfig()
+	transport.DisableKeepAlives = true
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// returns true if the given IP address is a public unicast address
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// the shared address space used by carrier-grade NATs (RFC 6598)
+var sharedAddressSpace = &net.IPNet{
+	IP:   net.IPv4(100, 64, 0, 0),
+	Mask: net.CIDRMask(10, 32),
+}
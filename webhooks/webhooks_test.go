@@ -0,0 +1,107 @@
+// Copyright (c) 2023 The KBase Project and its Contributors
+// Copyright (c) 2023 Cohere Consulting, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is furnished to do
+// so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kbase/dts/config"
+)
+
+// tests the validation of webhook URLs
+func TestValidateURL(t *testing.T) {
+	assert := assert.New(t)
+	config.Service.AllowPrivateWebhooks = false
+
+	assert.Nil(ValidateURL("https://example.com/hook"))
+	assert.Nil(ValidateURL("http://203.0.113.7:8080/hook"))
+	for _, badUrl := range []string{
+		"",
+		"ftp://example.com/hook",
+		"/relative/hook",
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://10.1.2.3/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://100.64.0.1/hook",
+	} {
+		assert.IsType(&InvalidURLError{}, ValidateURL(badUrl), badUrl)
+	}
+
+	config.Service.AllowPrivateWebhooks = true
+	defer func() { config.Service.AllowPrivateWebhooks = false }()
+	assert.Nil(ValidateURL("http://localhost:8080/hook"))
+	assert.NotNil(ValidateURL("ftp://example.com/hook"))
+}
+
+// tests the signing and delivery of webhook requests, which reach private
+// addresses only if the service allows them
+func TestPost(t *testing.T) {
+	assert := assert.New(t)
+	config.Service.CallbackSecret = "secret"
+	defer func() { config.Service.CallbackSecret = "" }()
+
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		assert.Equal(Signature(received, "secret"), r.Header.Get("X-DTS-Signature"))
+		assert.Equal("test", r.Header.Get("X-DTS-Event"))
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hook", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	config.Service.AllowPrivateWebhooks = false
+	err := Post(server.URL+"/hook", []byte(`{}`), map[string]string{"X-DTS-Event": "test"})
+	assert.IsType(&InvalidURLError{}, err)
+	assert.Nil(received)
+
+	config.Service.AllowPrivateWebhooks = true
+	defer func() { config.Service.AllowPrivateWebhooks = false }()
+	err = Post(server.URL+"/hook", []byte(`{"id": 1}`), map[string]string{"X-DTS-Event": "test"})
+	assert.Nil(err)
+	assert.Equal(`{"id": 1}`, string(received))
+
+	// redirects aren't followed
+	err = Post(server.URL+"/redirect", []byte(`{}`), map[string]string{"X-DTS-Event": "test"})
+	assert.IsType(&StatusError{}, err)
+}
+
+// tests that connections to private addresses are refused even when a
+// webhook's host name looks public
+func TestDialPrivateAddress(t *testing.T) {
+	assert := assert.New(t)
+	config.Service.AllowPrivateWebhooks = false
+
+	dialer := client().Transport.(*http.Transport)
+	_, err := dialer.DialContext(context.Background(), "tcp", "127.0.0.1:9")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "private addresses are not allowed")
+}